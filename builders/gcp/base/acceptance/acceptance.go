@@ -36,6 +36,7 @@ const (
 	javaFF           = "google.java.functions-framework"
 	javaGradle       = "google.java.gradle"
 	javaGraalVM      = "google.java.graalvm"
+	javaJlink        = "google.java.jlink"
 	javaMaven        = "google.java.maven"
 	javaNativeImage  = "google.java.native-image"
 	javaRuntime      = "google.java.runtime"
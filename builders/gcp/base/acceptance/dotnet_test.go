@@ -54,8 +54,8 @@ func TestAcceptanceDotNet(t *testing.T) {
 		},
 		{
 			Name: "simple dotnet app with runtime version",
-			// .NET 3.1 is not supported on Ubuntu 22.04.
-			SkipStacks:        []string{"google.22", "google.min.22", "google.gae.22"},
+			// .NET 3.1 is not supported on Ubuntu 22.04 and later.
+			SkipStacks:        []string{"google.22", "google.min.22", "google.gae.22", "google.24"},
 			App:               "simple",
 			Path:              "/version?want=3.1.30",
 			Env:               []string{"GOOGLE_ASP_NET_CORE_VERSION=3.1.30"},
@@ -66,8 +66,8 @@ func TestAcceptanceDotNet(t *testing.T) {
 			Name: "simple prebuilt dotnet app",
 			// simple_prebuilt is a dotnet 3 app.
 			VersionInclusionConstraint: "3",
-			// .NET 3.1 is not supported on Ubuntu 22.04.
-			SkipStacks:        []string{"google.22", "google.min.22", "google.gae.22"},
+			// .NET 3.1 is not supported on Ubuntu 22.04 and later.
+			SkipStacks:        []string{"google.22", "google.min.22", "google.gae.22", "google.24"},
 			App:               "simple_prebuilt",
 			Env:               []string{"GOOGLE_ENTRYPOINT=./simple"},
 			MustUse:           []string{dotnetRuntime},
@@ -84,8 +84,8 @@ func TestAcceptanceDotNet(t *testing.T) {
 		},
 		{
 			Name: "Dev mode",
-			// Hot reloading only works on .NET 3.1, which is not supported on Ubuntu 22.04.
-			SkipStacks:          []string{"google.22", "google.min.22", "google.gae.22"},
+			// Hot reloading only works on .NET 3.1, which is not supported on Ubuntu 22.04 and later.
+			SkipStacks:          []string{"google.22", "google.min.22", "google.gae.22", "google.24"},
 			App:                 "simple",
 			Env:                 []string{"GOOGLE_DEVMODE=1", "GOOGLE_DOTNET_SDK_VERSION=3.1.x"},
 			MustUse:             []string{dotnetSDK, dotnetRuntime, dotnetPublish},
@@ -154,6 +154,13 @@ func TestAcceptanceJava(t *testing.T) {
 			MustUse:    []string{javaGradle, javaRuntime, entrypoint},
 			MustNotUse: []string{javaEntrypoint},
 		},
+		{
+			Name:       "Java 17 maven with jlink",
+			App:        "hello_quarkus_maven",
+			Env:        []string{"GOOGLE_RUNTIME_VERSION=17", "GOOGLE_JAVA_JLINK=true"},
+			MustUse:    []string{javaMaven, javaRuntime, javaJlink, javaEntrypoint},
+			MustNotUse: []string{entrypoint},
+		},
 		{
 			Name:    "Exploded Jar",
 			App:     "exploded_jar",
@@ -128,8 +128,8 @@ func TestAcceptanceNodeJs(t *testing.T) {
 			Path:                       "/version?want=5.5.1",
 			MustUse:                    []string{nodeRuntime, nodeNPM},
 			MustOutput:                 []string{"npm --version\n\n5.5.1"},
-			// nodejs@8 is not available on Ubuntu 22.04
-			SkipStacks: []string{"google.22", "google.min.22", "google.gae.22"},
+			// nodejs@8 is not available on Ubuntu 22.04 and later
+			SkipStacks: []string{"google.22", "google.min.22", "google.gae.22", "google.24"},
 		},
 	}
 	for _, tc := range acceptance.FilterTests(t, imageCtx, testCases) {
@@ -32,7 +32,7 @@ func TestAcceptance(t *testing.T) {
 		{
 			// Ubuntu 22 only supports php82 which is still being released.
 			// Need to enable this test back once php82 is released.
-			SkipStacks: []string{"google.22", "google.min.22", "google.gae.22"},
+			SkipStacks: []string{"google.22", "google.min.22", "google.gae.22", "google.24"},
 			Name:       "simple path",
 			App:        "simple",
 			MustMatch:  "PASS_INDEX",
@@ -42,7 +42,7 @@ func TestAcceptance(t *testing.T) {
 		{
 			// Ubuntu 22 only supports php82 which is still being released.
 			// Need to enable this test back once php82 is released.
-			SkipStacks: []string{"google.22", "google.min.22", "google.gae.22"},
+			SkipStacks: []string{"google.22", "google.min.22", "google.gae.22", "google.24"},
 			Name:       "entrypoint from procfile web",
 			App:        "entrypoint",
 			MustMatch:  "PASS_INDEX",
@@ -51,7 +51,7 @@ func TestAcceptance(t *testing.T) {
 		{
 			// Ubuntu 22 only supports php82 which is still being released.
 			// Need to enable this test back once php82 is released.
-			SkipStacks: []string{"google.22", "google.min.22", "google.gae.22"},
+			SkipStacks: []string{"google.22", "google.min.22", "google.gae.22", "google.24"},
 			Name:       "entrypoint from procfile custom",
 			App:        "entrypoint",
 			MustMatch:  "PASS_CUSTOM",
@@ -61,7 +61,7 @@ func TestAcceptance(t *testing.T) {
 		{
 			// Ubuntu 22 only supports php82 which is still being released.
 			// Need to enable this test back once php82 is released.
-			SkipStacks: []string{"google.22", "google.min.22", "google.gae.22"},
+			SkipStacks: []string{"google.22", "google.min.22", "google.gae.22", "google.24"},
 			Name:       "entrypoint from env",
 			App:        "simple",
 			MustMatch:  "PASS_INDEX",
@@ -71,7 +71,7 @@ func TestAcceptance(t *testing.T) {
 		{
 			// Ubuntu 22 only supports php82 which is still being released.
 			// Need to enable this test back once php82 is released.
-			SkipStacks: []string{"google.22", "google.min.22", "google.gae.22"},
+			SkipStacks: []string{"google.22", "google.min.22", "google.gae.22", "google.24"},
 			Name:       "custom path",
 			App:        "simple",
 			Path:       "/custom",
@@ -82,7 +82,7 @@ func TestAcceptance(t *testing.T) {
 		{
 			// Ubuntu 22 only supports php82 which is still being released.
 			// Need to enable this test back once php82 is released.
-			SkipStacks: []string{"google.22", "google.min.22", "google.gae.22"},
+			SkipStacks: []string{"google.22", "google.min.22", "google.gae.22", "google.24"},
 			Name:       "php ini config",
 			App:        "php_ini_config",
 			MustMatch:  "PASS_PHP_INI",
@@ -91,7 +91,7 @@ func TestAcceptance(t *testing.T) {
 		},
 		{
 			// Ubuntu 22 only supports php82 And does not support the version 7.4.27.
-			SkipStacks: []string{"google.22", "google.min.22", "google.gae.22"},
+			SkipStacks: []string{"google.22", "google.min.22", "google.gae.22", "google.24"},
 			Name:       "runtime version 7.4.27",
 			App:        "simple",
 			Path:       "/version?want=7.4.27",
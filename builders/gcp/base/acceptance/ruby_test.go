@@ -46,8 +46,8 @@ func TestAcceptanceRuby(t *testing.T) {
 	testCases := []acceptance.Test{
 		{
 			Name: "using bundler 1",
-			// Bundler 1 is common with older versions of Ruby which are not supported on Ubuntu 22.04.
-			SkipStacks:      []string{"google.22", "google.min.22", "google.gae.22"},
+			// Bundler 1 is common with older versions of Ruby which are not supported on Ubuntu 22.04 and later.
+			SkipStacks:      []string{"google.22", "google.min.22", "google.gae.22", "google.24"},
 			App:             "simple",
 			MustUse:         []string{rubyRuntime, rubyBundle, entrypoint},
 			EnableCacheTest: true,
@@ -90,6 +90,14 @@ func TestAcceptanceRuby(t *testing.T) {
 			Env:     []string{"GOOGLE_RUNTIME_VERSION=3.1.0"},
 			MustUse: []string{rubyRuntime, rubyBundle, entrypoint},
 		},
+		{
+			Name: "OpenSSL 3",
+			App:  "simple",
+			Path: "/openssl?want=OpenSSL%203",
+			// Ruby links against the OpenSSL of the stack.
+			Stacks:  []string{"google.22", "google.24"},
+			MustUse: []string{rubyRuntime, rubyBundle, entrypoint},
+		},
 		{
 			Name:            "rails",
 			App:             "rails",
@@ -51,6 +51,14 @@ func TestAcceptance(t *testing.T) {
 			MustUse:    []string{goRuntime, goBuild},
 			MustNotUse: []string{goPath},
 		},
+		{
+			Name:           "Build command",
+			App:            "build_command",
+			Env:            []string{"GOOGLE_BUILD_COMMAND=make build", "GOOGLE_BUILD_OUTPUT=bin/server"},
+			MustUse:        []string{goRuntime, goBuild, goMod},
+			MustNotUse:     []string{goPath},
+			FilesMustExist: []string{"/layers/google.go.build/bin/main", "/workspace/bin/server"},
+		},
 		{
 			Name: "Go.mod and vendor",
 			// go mod and vendor cannot be used together before go 1.14
@@ -137,6 +145,12 @@ func TestFailures(t *testing.T) {
 			MustMatch:              `Tip: "GOOGLE_BUILDABLE" env var configures which Go package is built`,
 			SkipBuilderOutputMatch: true,
 		},
+		{
+			Name:      "build command without expected output",
+			App:       "build_command",
+			Env:       []string{"GOOGLE_BUILD_COMMAND=make build"},
+			MustMatch: "GOOGLE_BUILD_COMMAND completed but did not produce the expected output",
+		},
 		{
 			Name: "bad runtime version",
 			// This test only runs against a single version of Go as it is unlikely to break across versions.
@@ -26,6 +26,7 @@ func init() {
 
 const (
 	npm  = "google.nodejs.npm"
+	pnpm = "google.nodejs.pnpm"
 	yarn = "google.nodejs.yarn"
 )
 
@@ -65,6 +66,12 @@ func TestAcceptance(t *testing.T) {
 			MustUse:    []string{yarn},
 			MustNotUse: []string{npm},
 		},
+		{
+			Name:       "function without framework and with pnpm",
+			App:        "no_framework_pnpm",
+			MustUse:    []string{pnpm},
+			MustNotUse: []string{npm, yarn},
+		},
 		{
 			Name:       "function with framework",
 			App:        "with_framework",
@@ -21,8 +21,10 @@ import (
 
 const (
 	entrypoint  = "google.config.entrypoint"
+	nodeBun     = "google.nodejs.bun"
 	nodeFF      = "google.nodejs.functions-framework"
 	nodeNPM     = "google.nodejs.npm"
+	nodePNPM    = "google.nodejs.pnpm"
 	nodeRuntime = "google.nodejs.runtime"
 	nodeYarn    = "google.nodejs.yarn"
 )
@@ -87,6 +89,13 @@ func TestAcceptance(t *testing.T) {
 			Env:     []string{"GOOGLE_ENTRYPOINT=node custom.js"},
 			MustUse: []string{nodeRuntime, nodeNPM, entrypoint},
 		},
+		{
+			Name:           "build command",
+			App:            "build_command",
+			Env:            []string{"GOOGLE_BUILD_COMMAND=npm run custom-build", "GOOGLE_BUILD_OUTPUT=dist/server.js"},
+			MustUse:        []string{nodeRuntime, nodeNPM},
+			FilesMustExist: []string{"/workspace/dist/server.js"},
+		},
 		{
 			Name:       "yarn",
 			App:        "yarn",
@@ -122,6 +131,43 @@ func TestAcceptance(t *testing.T) {
 			Env:                        []string{"GOOGLE_RUNTIME_VERSION=16.9.1"},
 			MustUse:                    []string{nodeRuntime, nodeNPM},
 		},
+		{
+			Name:       "pnpm",
+			App:        "pnpm",
+			MustUse:    []string{nodeRuntime, nodePNPM},
+			MustNotUse: []string{nodeNPM, nodeYarn},
+		},
+		{
+			Name:       "bun",
+			App:        "bun",
+			MustUse:    []string{nodeRuntime, nodeBun},
+			MustNotUse: []string{nodeNPM, nodePNPM, nodeYarn},
+		},
+		{
+			Name:           "monorepo with source root marker",
+			App:            "monorepo",
+			MustUse:        []string{nodeRuntime, nodeNPM},
+			FilesMustExist: []string{"/workspace/services/web/server.js"},
+		},
+		{
+			Name:    "monorepo with subdirectory",
+			App:     "monorepo",
+			Env:     []string{"GOOGLE_SUBDIRECTORY=services/worker"},
+			MustUse: []string{nodeRuntime, nodeNPM},
+		},
+		{
+			Name:    "monorepo with application dir",
+			App:     "monorepo",
+			Env:     []string{"GOOGLE_APPLICATION_DIR=services/worker"},
+			MustUse: []string{nodeRuntime, nodeNPM},
+		},
+		{
+			Name:                       "npm workspace",
+			VersionInclusionConstraint: ">= 16.0.0",
+			App:                        "workspaces",
+			Env:                        []string{"GOOGLE_NODEJS_WORKSPACE=@acme/api"},
+			MustUse:                    []string{nodeRuntime, nodeNPM},
+		},
 		{
 			Name:       "without package.json",
 			App:        "no_package",
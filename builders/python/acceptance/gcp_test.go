@@ -23,6 +23,7 @@ const (
 	entrypoint    = "google.config.entrypoint"
 	pythonFF      = "google.python.functions-framework"
 	pythonPIP     = "google.python.pip"
+	pythonPipenv  = "google.python.pipenv"
 	pythonRuntime = "google.python.runtime"
 )
 
@@ -62,6 +63,20 @@ func TestAcceptancePython(t *testing.T) {
 			Env:     []string{"GOOGLE_ENTRYPOINT=FOO=bar gunicorn -b :8080 main:app"},
 			MustUse: []string{pythonRuntime, pythonPIP, entrypoint},
 		},
+		{
+			Name:            "dependencies installed with uv",
+			App:             "simple",
+			Env:             []string{"GOOGLE_PYTHON_INSTALLER=uv"},
+			MustUse:         []string{pythonRuntime, pythonPIP, entrypoint},
+			EnableCacheTest: true,
+		},
+		{
+			Name:            "pipenv",
+			App:             "pipenv",
+			MustUse:         []string{pythonRuntime, pythonPipenv, entrypoint},
+			MustNotUse:      []string{pythonPIP},
+			EnableCacheTest: true,
+		},
 		{
 			Name:    "python with client-side scripts correctly builds as a python app",
 			App:     "scripts",
@@ -0,0 +1,3 @@
+module example.com/build_command
+
+go 1.16
@@ -0,0 +1,27 @@
+/**
+ * Copyright 2023 Google LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/**
+ * @fileoverview Build script that emits the server into dist/, run via GOOGLE_BUILD_COMMAND.
+ */
+
+'use strict';
+
+const fs = require('fs');
+const path = require('path');
+
+fs.mkdirSync(path.join(__dirname, 'dist'), {recursive: true});
+fs.copyFileSync(path.join(__dirname, 'server.js'), path.join(__dirname, 'dist', 'server.js'));
@@ -50,3 +50,14 @@ get '/version' do
 
   "PASS"
 end
+
+get '/openssl' do
+  want = params['want']
+  return "FAIL: ?want must not be empty" unless want
+
+  require 'openssl'
+  got = OpenSSL::OPENSSL_LIBRARY_VERSION
+  return "FAIL: OPENSSL_LIBRARY_VERSION=#{got}, want #{want}" unless got.start_with?(want)
+
+  "PASS"
+end
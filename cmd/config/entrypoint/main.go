@@ -19,17 +19,13 @@ package main
 import (
 	"fmt"
 	"os"
-	"regexp"
-	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/appengine"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/appyaml"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
-)
-
-var (
-	processRe = regexp.MustCompile(`(?m)^(\w+):\s*(.+)$`)
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/procfile"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runimage"
 )
 
 func main() {
@@ -46,12 +42,12 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 	if os.Getenv(env.Entrypoint) != "" {
 		return gcp.OptInEnvSet(env.Entrypoint), nil
 	}
-	procExists, err := ctx.FileExists("Procfile")
+	procExists, err := ctx.FileExists(procfile.File)
 	if err != nil {
 		return nil, err
 	}
 	if procExists {
-		return gcp.OptInFileFound("Procfile"), nil
+		return gcp.OptInFileFound(procfile.File), nil
 	}
 	if entrypoint, _ := appyaml.EntrypointIfExists(ctx.ApplicationRoot()); entrypoint != "" {
 		ctx.Logf("Using entrypoint from app.yaml.")
@@ -74,18 +70,27 @@ func buildFn(ctx *gcp.Context) error {
 		return appengine.Build(ctx, runtime, nil)
 	}
 
+	// The entrypoints below are run in a shell, which the minimal run image does not provide.
+	minRunImage, err := runimage.IsMin()
+	if err != nil {
+		return err
+	}
+	if minRunImage {
+		return gcp.UserErrorf("%s, Procfile and app.yaml entrypoints are run in a shell, which is not available with %s=%s", env.Entrypoint, env.RunImageVariant, runimage.Min)
+	}
+
 	if entrypoint := os.Getenv(env.Entrypoint); entrypoint != "" {
 		ctx.AddProcess(gcp.WebProcess, []string{entrypoint}, gcp.AsDefaultProcess())
 		ctx.Logf("Using entrypoint from environment variable %s: %s", env.Entrypoint, entrypoint)
 		return nil
 	}
 
-	procExists, err := ctx.FileExists("Procfile")
+	procExists, err := ctx.FileExists(procfile.File)
 	if err != nil {
 		return err
 	}
 	if procExists {
-		b, err := ctx.ReadFile("Procfile")
+		b, err := ctx.ReadFile(procfile.File)
 		if err != nil {
 			return err
 		}
@@ -107,37 +112,27 @@ func buildFn(ctx *gcp.Context) error {
 		"%s not set, no valid entrypoint config in Procfile or app.yaml.", env.Entrypoint))
 }
 
-// addProcfileProcesses adds all processes from the given Procfile contents.
+// addProcfileProcesses adds all processes from the given Procfile contents, the web process is the
+// default process.
 func addProcfileProcesses(ctx *gcp.Context, content string) error {
-	matches := processRe.FindAllStringSubmatch(content, -1)
-	if len(matches) == 0 {
-		return gcp.UserErrorf("did not find any processes in Procfile")
+	processes, err := procfile.Parse(ctx, content)
+	if err != nil {
+		return err
 	}
-
-	found := make(map[string]bool, len(matches))
-	for _, match := range matches {
-		// Sanity check, if this fails there is a mistake in the regex.
-		// One group for overall match and two subgroups.
-		if len(match) != 3 {
-			return gcp.InternalErrorf("invalid process match, want slice of two strings, got: %v", match)
-		}
-		name, command := match[1], strings.TrimSpace(match[2])
-		if found[name] {
-			ctx.Warnf("Skipping duplicate %s process: %s", gcp.WebProcess, command)
-			continue
+	if _, err := procfile.Find(processes, gcp.WebProcess); err != nil {
+		return err
+	}
+	for _, p := range processes {
+		if err := procfile.Validate(ctx, p); err != nil {
+			return err
 		}
-		found[name] = true
-
-		if name == gcp.WebProcess {
-			ctx.Logf("Using entrypoint from Procfile: %s", command)
-			ctx.AddProcess(name, []string{command}, gcp.AsDefaultProcess())
+		if p.Type == gcp.WebProcess {
+			ctx.Logf("Using entrypoint from Procfile: %s", p.Command)
+			ctx.AddProcess(p.Type, []string{p.Command}, gcp.AsDefaultProcess())
 		} else {
-			ctx.AddProcess(name, []string{command})
+			ctx.Logf("Adding %s process from Procfile: %s", p.Type, p.Command)
+			ctx.AddProcess(p.Type, []string{p.Command})
 		}
 	}
-
-	if !found[gcp.WebProcess] {
-		return gcp.UserErrorf("web process not found in Procfile: %#v", matches)
-	}
 	return nil
 }
@@ -160,6 +160,22 @@ web: bar
 				{Type: "web", Command: "foo bar baz", Default: true},
 			},
 		},
+		{
+			name: "worker, release and cron with hyphens",
+			content: `web: bundle exec puma
+sidekiq-worker: bundle exec sidekiq
+
+# Run before each release.
+release: bundle exec rake db:migrate
+cron_2: bundle exec clockwork clock.rb
+`,
+			want: []libcnb.Process{
+				{Type: "web", Command: "bundle exec puma", Default: true},
+				{Type: "sidekiq-worker", Command: "bundle exec sidekiq"},
+				{Type: "release", Command: "bundle exec rake db:migrate"},
+				{Type: "cron_2", Command: "bundle exec clockwork clock.rb"},
+			},
+		},
 		{
 			name: "multiple",
 			content: `web:     foo bar
@@ -208,6 +224,14 @@ func TestAddProcfileWebProcessesError(t *testing.T) {
 			name:    "comment",
 			content: "# web: java",
 		},
+		{
+			name:    "invalid shell syntax",
+			content: "web: java -jar 'app.jar",
+		},
+		{
+			name:    "invalid shell syntax in worker",
+			content: "web: java -jar app.jar\nworker: if true; then java -jar worker.jar",
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -218,3 +242,37 @@ func TestAddProcfileWebProcessesError(t *testing.T) {
 		})
 	}
 }
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name         string
+		envs         []string
+		wantExitCode int
+	}{
+		{
+			name: "GOOGLE_ENTRYPOINT",
+			envs: []string{"GOOGLE_ENTRYPOINT=./app --port 8080"},
+		},
+		{
+			name:         "GOOGLE_ENTRYPOINT with min run image",
+			envs:         []string{"GOOGLE_ENTRYPOINT=./app --port 8080", "GOOGLE_RUN_IMAGE_VARIANT=min"},
+			wantExitCode: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []buildpacktest.Option{
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithEnvs(tc.envs...),
+			}
+
+			result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, result: %#v", err, result)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements config/release buildpack.
+// The release buildpack registers the release process that runs once before each deployment.
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/procfile"
+)
+
+// releaseProcess is the process type of the command that runs before each deployment, e.g. to
+// migrate the database which is not reachable at build time.
+const releaseProcess = "release"
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	if strings.TrimSpace(os.Getenv(env.ReleaseCommand)) != "" {
+		return gcp.OptInEnvSet(env.ReleaseCommand), nil
+	}
+	p, err := procfileRelease(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if p != nil {
+		return gcp.OptIn(fmt.Sprintf("found %s process in %s", releaseProcess, procfile.File)), nil
+	}
+	return gcp.OptOut(fmt.Sprintf("%s not set and no %s process in %s", env.ReleaseCommand, releaseProcess, procfile.File)), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	p := procfile.Process{Type: releaseProcess, Command: strings.TrimSpace(os.Getenv(env.ReleaseCommand))}
+	source := env.ReleaseCommand
+	if p.Command == "" {
+		pp, err := procfileRelease(ctx)
+		if err != nil {
+			return err
+		}
+		if pp == nil {
+			return gcp.UserErrorf("%s not set and no %s process in %s", env.ReleaseCommand, releaseProcess, procfile.File)
+		}
+		p, source = *pp, procfile.File
+	}
+	if err := procfile.Validate(ctx, p); err != nil {
+		return err
+	}
+
+	ctx.AddProcess(releaseProcess, []string{p.Command})
+	ctx.Logf("Using %s command from %s: %s", releaseProcess, source, p.Command)
+	ctx.Logf("The %s process is not run during the build, run it before each deployment with the /cnb/process/%s entrypoint, for example as a Cloud Run job.", releaseProcess, releaseProcess)
+	return nil
+}
+
+// procfileRelease returns the release process of the Procfile, or nil if there is none.
+func procfileRelease(ctx *gcp.Context) (*procfile.Process, error) {
+	exists, err := ctx.FileExists(procfile.File)
+	if err != nil || !exists {
+		return nil, err
+	}
+	b, err := ctx.ReadFile(procfile.File)
+	if err != nil {
+		return nil, err
+	}
+	processes, err := procfile.Parse(ctx, string(b))
+	if err != nil {
+		return nil, err
+	}
+	p, err := procfile.Find(processes, releaseProcess)
+	if err != nil {
+		// The Procfile does not declare a release process.
+		return nil, nil
+	}
+	return &p, nil
+}
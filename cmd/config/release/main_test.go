@@ -0,0 +1,119 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		env   []string
+		files map[string]string
+		want  int
+	}{
+		{
+			name: "with GOOGLE_RELEASE_COMMAND",
+			env:  []string{env.ReleaseCommand + "=python manage.py migrate"},
+			want: 0,
+		},
+		{
+			name: "with release process in Procfile",
+			files: map[string]string{
+				"Procfile": "web: gunicorn main:app\nrelease: python manage.py migrate",
+			},
+			want: 0,
+		},
+		{
+			name: "without release process in Procfile",
+			files: map[string]string{
+				"Procfile": "web: gunicorn main:app\nworker: celery worker",
+			},
+			want: 100,
+		},
+		{
+			name: "without GOOGLE_RELEASE_COMMAND or Procfile",
+			want: 100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buildpacktest.TestDetect(t, detectFn, tc.name, tc.files, tc.env, tc.want)
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name         string
+		envs         []string
+		files        map[string]string
+		wantExitCode int
+		wantOutput   string
+	}{
+		{
+			name:       "from GOOGLE_RELEASE_COMMAND",
+			envs:       []string{env.ReleaseCommand + "=bundle exec rails db:migrate"},
+			wantOutput: "Using release command from GOOGLE_RELEASE_COMMAND: bundle exec rails db:migrate",
+		},
+		{
+			name: "from Procfile",
+			files: map[string]string{
+				"Procfile": "web: bundle exec puma\nrelease: bundle exec rails db:migrate\n",
+			},
+			wantOutput: "Using release command from Procfile: bundle exec rails db:migrate",
+		},
+		{
+			name: "GOOGLE_RELEASE_COMMAND takes precedence over Procfile",
+			envs: []string{env.ReleaseCommand + "=npx prisma migrate deploy"},
+			files: map[string]string{
+				"Procfile": "web: node server.js\nrelease: node migrate.js\n",
+			},
+			wantOutput: "Using release command from GOOGLE_RELEASE_COMMAND: npx prisma migrate deploy",
+		},
+		{
+			name:         "invalid shell syntax",
+			envs:         []string{env.ReleaseCommand + "=python manage.py migrate && "},
+			wantExitCode: 1,
+			wantOutput:   "invalid shell syntax in the release process",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []buildpacktest.Option{
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithEnvs(tc.envs...),
+				buildpacktest.WithFiles(tc.files),
+			}
+
+			result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, result: %#v", err, result)
+			}
+
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+			if !strings.Contains(result.Output, tc.wantOutput) {
+				t.Errorf("build output does not contain %q, got:\n%s", tc.wantOutput, result.Output)
+			}
+		})
+	}
+}
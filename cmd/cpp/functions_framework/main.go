@@ -132,6 +132,11 @@ func buildFn(ctx *gcp.Context) error {
 	if err != nil {
 		return fmt.Errorf("creating %v layer: %w", mainLayerName, err)
 	}
+	// The target is compiled into the function binary, so the image can only serve one function.
+	target, err := ctx.SingleFunctionTarget("C++")
+	if err != nil {
+		return err
+	}
 	if err := ctx.SetFunctionsEnvVars(mainLayer); err != nil {
 		return err
 	}
@@ -141,7 +146,11 @@ func buildFn(ctx *gcp.Context) error {
 		return fmt.Errorf("creating %v layer: %w", buildLayerName, err)
 	}
 
-	fn := extractFnInfo(os.Getenv(env.FunctionTarget), os.Getenv(env.FunctionSignatureType))
+	signature := os.Getenv(env.FunctionSignatureType)
+	if target.SignatureType != "" {
+		signature = target.SignatureType
+	}
+	fn := extractFnInfo(target.Target, signature)
 	if err := createMainCppFile(ctx, fn, filepath.Join(mainLayer.Path, "main.cc")); err != nil {
 		return err
 	}
@@ -240,8 +249,16 @@ func installVcpkg(ctx *gcp.Context) (string, error) {
 	}
 	ctx.CacheMiss(vcpkgLayerName)
 	ctx.Logf("Installing vcpkg %s", vcpkgVersion)
-	command := fmt.Sprintf("curl --fail --show-error --silent --location --retry 3 %s | tar xz --directory %s --strip-components=1", vcpkgURL, vcpkg.Path)
-	if _, err := ctx.Exec([]string{"bash", "-c", command}, gcp.WithUserAttribution); err != nil {
+	tmpDir, err := ctx.TempDir("vcpkg")
+	if err != nil {
+		return "", err
+	}
+	defer ctx.RemoveAll(tmpDir)
+	archive := filepath.Join(tmpDir, "vcpkg.tar.gz")
+	if err := ctx.Download(vcpkgURL, archive); err != nil {
+		return "", err
+	}
+	if _, err := ctx.Exec([]string{"tar", "xzf", archive, "--directory", vcpkg.Path, "--strip-components=1"}, gcp.WithUserAttribution); err != nil {
 		return "", err
 	}
 
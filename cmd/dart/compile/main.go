@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/dart"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
@@ -66,13 +67,17 @@ func buildFn(ctx *gcp.Context) error {
 		return fmt.Errorf("unable to find a valid buildable: %w", err)
 	}
 
-	// Build the application.
-	bld := []string{"dart", "compile", "exe", buildable, "-o", outBin}
+	// Build the application ahead-of-time into a self-contained executable, the run image does not
+	// need the Dart SDK.
+	bld := []string{"dart", "compile", "exe"}
+	bld = append(bld, strings.Fields(os.Getenv(env.DartCompileFlags))...)
+	bld = append(bld, buildable, "-o", outBin)
 	if _, err := ctx.Exec(bld, gcp.WithUserAttribution); err != nil {
 		return err
 	}
 
-	ctx.AddWebProcess([]string{"/bin/bash", "-c", outBin})
+	// The executable is started directly so that the run image does not need a shell.
+	ctx.AddWebProcess([]string{outBin})
 	return nil
 }
 
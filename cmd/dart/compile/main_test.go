@@ -18,6 +18,7 @@ import (
 	"testing"
 
 	"github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
 )
 
 func TestDetect(t *testing.T) {
@@ -45,3 +46,47 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name         string
+		envs         []string
+		wantCommands []string
+	}{
+		{
+			name:         "default buildable",
+			wantCommands: []string{"dart compile exe bin/server.dart -o .*/server"},
+		},
+		{
+			name:         "compile flags",
+			envs:         []string{"GOOGLE_DART_COMPILE_FLAGS=--define=ENV=prod --verbosity=warning"},
+			wantCommands: []string{"dart compile exe --define=ENV=prod --verbosity=warning bin/server.dart -o .*/server"},
+		},
+		{
+			name:         "buildable",
+			envs:         []string{"GOOGLE_BUILDABLE=bin/main.dart"},
+			wantCommands: []string{"dart compile exe bin/main.dart -o .*/server"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []buildpacktest.Option{
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithEnvs(tc.envs...),
+				buildpacktest.WithFiles(map[string]string{"bin/server.dart": "", "pubspec.yaml": "name: app"}),
+				buildpacktest.WithExecMocks(mockprocess.New("dart compile exe")),
+			}
+
+			result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+			if err != nil {
+				t.Fatalf("error running build: %v, result: %#v", err, result)
+			}
+
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not", cmd)
+				}
+			}
+		})
+	}
+}
@@ -19,13 +19,20 @@ package main
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 )
 
 const (
 	pubLayer    = "pub"
 	pubCacheEnv = "PUB_CACHE"
+	pubspecLock = "pubspec.lock"
+	// dependencyHashKey is the metadata key of the pubspec.lock hash of the cached packages.
+	dependencyHashKey = "dependency_hash"
+
+	cacheFormatVersion = "v1"
 )
 
 func main() {
@@ -52,8 +59,39 @@ func buildFn(ctx *gcp.Context) error {
 	if err := os.Setenv(pubCacheEnv, ml.Path); err != nil {
 		return fmt.Errorf("setting env %s=%s: %w", pubCacheEnv, pubLayer, err)
 	}
-	if _, err := ctx.Exec([]string{"dart", "pub", "get"}, gcp.WithUserAttribution); err != nil {
+
+	lockExists, err := ctx.FileExists(pubspecLock)
+	if err != nil {
+		return err
+	}
+	if !lockExists {
+		// Without a lock file the resolved versions may change on every build, the cache is only
+		// used to avoid downloading packages that did not change.
+		ctx.Debugf("%s not found, resolving dependencies", pubspecLock)
+		if _, err := ctx.Exec([]string{"dart", "pub", "get"}, gcp.WithUserAttribution); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	hit, hash, err := cache.CheckCache(ctx, ml, cache.WithFormatVersion(cacheFormatVersion), dependencyHashKey, cache.WithFiles(filepath.Join(ctx.ApplicationRoot(), pubspecLock)), cache.WithStrings(ctx.StackID()))
+	if err != nil {
+		return fmt.Errorf("checking %v layer cache: %w", pubLayer, err)
+	}
+	cmd := []string{"dart", "pub", "get", "--enforce-lockfile"}
+	if hit {
+		ctx.CacheHit(pubLayer)
+		// All the packages of pubspec.lock are in the cache, nothing needs to be downloaded.
+		cmd = append(cmd, "--offline")
+	} else {
+		ctx.CacheMiss(pubLayer)
+		if err := ctx.ClearLayer(ml); err != nil {
+			return fmt.Errorf("clearing layer %q: %w", pubLayer, err)
+		}
+	}
+	if _, err := ctx.Exec(cmd, gcp.WithUserAttribution); err != nil {
 		return err
 	}
+	ctx.SetMetadata(ml, dependencyHashKey, hash)
 	return nil
 }
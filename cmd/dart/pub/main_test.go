@@ -18,6 +18,9 @@ import (
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/cacheformat"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 )
 
 func TestDetect(t *testing.T) {
@@ -48,3 +51,59 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name            string
+		files           map[string]string
+		wantCommands    []string
+		skippedCommands []string
+	}{
+		{
+			name: "with pubspec.lock",
+			files: map[string]string{
+				"pubspec.yaml": "name: app",
+				"pubspec.lock": "packages: {}",
+			},
+			wantCommands:    []string{"dart pub get --enforce-lockfile"},
+			skippedCommands: []string{"--offline"},
+		},
+		{
+			name: "without pubspec.lock",
+			files: map[string]string{
+				"pubspec.yaml": "name: app",
+			},
+			wantCommands:    []string{"dart pub get"},
+			skippedCommands: []string{"--enforce-lockfile"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []buildpacktest.Option{
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithFiles(tc.files),
+				buildpacktest.WithExecMocks(mockprocess.New("dart pub get")),
+			}
+
+			result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+			if err != nil {
+				t.Fatalf("error running build: %v, result: %#v", err, result)
+			}
+
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not", cmd)
+				}
+			}
+			for _, cmd := range tc.skippedCommands {
+				if result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to not be executed, but it was", cmd)
+				}
+			}
+		})
+	}
+}
+
+func TestCacheFormatVersion(t *testing.T) {
+	cacheformat.Check(t, "testdata/cache_format.golden", cache.WithFormatVersion(cacheFormatVersion), "main.go")
+}
@@ -18,9 +18,7 @@ package main
 
 import (
 	"fmt"
-	"os"
 
-	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 )
 
@@ -33,10 +31,7 @@ func main() {
 }
 
 func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
-	if _, ok := os.LookupEnv(env.FunctionTarget); ok {
-		return gcp.OptInEnvSet(env.FunctionTarget), nil
-	}
-	return gcp.OptOutEnvNotSet(env.FunctionTarget), nil
+	return gcp.DetectFunction(ctx)
 }
 
 func buildFn(ctx *gcp.Context) error {
@@ -44,6 +39,10 @@ func buildFn(ctx *gcp.Context) error {
 	if err != nil {
 		return fmt.Errorf("creating %v layer: %w", layerName, err)
 	}
+	// The web process is added by the .NET buildpacks, so the image can only serve one function.
+	if _, err := ctx.SingleFunctionTarget(".NET"); err != nil {
+		return err
+	}
 	if err := ctx.SetFunctionsEnvVars(l); err != nil {
 		return err
 	}
@@ -33,6 +33,11 @@ func TestDetect(t *testing.T) {
 			env:  []string{"GOOGLE_FUNCTION_TARGET=HelloWorld.Function"},
 			want: 0,
 		},
+		{
+			name:  "with functions.yaml",
+			files: map[string]string{"functions.yaml": "functions:\n- target: HelloWorld.Function\n"},
+			want:  0,
+		},
 		{
 			name: "without target",
 			want: 100,
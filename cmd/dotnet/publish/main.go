@@ -19,15 +19,18 @@ package main
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"path"
 	"path/filepath"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/apt"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/dotnet"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runimage"
 	"github.com/buildpacks/libcnb"
 )
 
@@ -35,8 +38,13 @@ const (
 	cacheTag          = "prod dependencies"
 	dependencyHashKey = "dependency_hash"
 	versionKey        = "version"
+	// aotToolchainLayer holds the native toolchain when the build image does not provide one.
+	aotToolchainLayer = "aot_toolchain"
 )
 
+// lookPath finds executables on the build image, it is a var for testing.
+var lookPath = exec.LookPath
+
 func main() {
 	gcp.Main(detectFn, buildFn)
 }
@@ -61,6 +69,43 @@ func buildFn(ctx *gcp.Context) error {
 	if err != nil {
 		return fmt.Errorf("finding project: %w", err)
 	}
+	aot, err := dotnet.PublishAOT()
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	if aot && devmode.Enabled(ctx) {
+		ctx.Warnf("%s is not supported in development mode, the application runs with `dotnet watch`.", dotnet.EnvPublishAOT)
+		aot = false
+	}
+	minRunImage, err := runimage.IsMin()
+	if err != nil {
+		return err
+	}
+	if minRunImage && devmode.Enabled(ctx) {
+		ctx.Warnf("%s=%s is not supported in development mode, the application runs with `dotnet watch`.", env.RunImageVariant, runimage.Min)
+		minRunImage = false
+	}
+	if minRunImage {
+		// The minimal run image has neither the .NET runtime nor a shell.
+		if !aot {
+			return gcp.UserErrorf("%s=%s requires a native executable, set %s=true to publish the application with Native AOT", env.RunImageVariant, runimage.Min, dotnet.EnvPublishAOT)
+		}
+		if os.Getenv(env.Entrypoint) != "" {
+			return gcp.UserErrorf("%s is run in a shell, which is not available with %s=%s", env.Entrypoint, env.RunImageVariant, runimage.Min)
+		}
+	}
+	execEnv := []string{"DOTNET_CLI_TELEMETRY_OPTOUT=true"}
+	var aotArgs []string
+	if aot {
+		toolchainEnv, err := installAOTToolchain(ctx)
+		if err != nil {
+			return fmt.Errorf("installing Native AOT toolchain: %w", err)
+		}
+		execEnv = append(execEnv, toolchainEnv...)
+		// The runtime identifier must be the same for restore and publish.
+		aotArgs = []string{"--runtime", dotnet.AOTRuntimeIdentifier(ctx), "/p:PublishAot=true"}
+	}
+
 	ctx.Logf("Installing application dependencies.")
 	pkgLayer, err := ctx.Layer("packages", gcp.BuildLayer, gcp.CacheLayer)
 	if err != nil {
@@ -79,8 +124,10 @@ func buildFn(ctx *gcp.Context) error {
 	}
 
 	// Run restore regardless of cache status because it generates files expected by publish.
-	cmd := []string{"dotnet", "restore", "--packages", pkgLayer.Path, proj}
-	if _, err := ctx.Exec(cmd, gcp.WithEnv("DOTNET_CLI_TELEMETRY_OPTOUT=true"), gcp.WithUserAttribution); err != nil {
+	cmd := []string{"dotnet", "restore", "--packages", pkgLayer.Path}
+	cmd = append(cmd, aotArgs...)
+	cmd = append(cmd, proj)
+	if _, err := ctx.Exec(cmd, gcp.WithEnv(execEnv...), gcp.WithUserAttribution); err != nil {
 		return err
 	}
 
@@ -109,8 +156,9 @@ func buildFn(ctx *gcp.Context) error {
 		"--output", outputDirectory,
 		"--no-restore",
 		"--packages", pkgLayer.Path,
-		proj,
 	}
+	cmd = append(cmd, aotArgs...)
+	cmd = append(cmd, proj)
 
 	if args := os.Getenv(env.BuildArgs); args != "" {
 		// Use bash to excute the command to avoid havnig to parse the build arguments.
@@ -118,16 +166,19 @@ func buildFn(ctx *gcp.Context) error {
 		cmd = []string{"/bin/bash", "-c", strings.Join(append(cmd, args), " ")}
 	}
 
-	if _, err := ctx.Exec(cmd, gcp.WithEnv("DOTNET_CLI_TELEMETRY_OPTOUT=true"), gcp.WithUserAttribution); err != nil {
+	if _, err := ctx.Exec(cmd, gcp.WithEnv(execEnv...), gcp.WithUserAttribution); err != nil {
 		return err
 	}
 
-	// Set GOOGLE_ASP_NET_CORE_VERSION, so subsequent buildpacks know which runtime version to install
-	runtimeVersion, err := dotnet.GetRuntimeVersion(ctx, outputDirectory)
-	if err != nil {
-		return gcp.InternalErrorf("getting runtime version: %v", err)
+	// Native executables do not need the .NET runtime.
+	if !aot {
+		// Set GOOGLE_ASP_NET_CORE_VERSION, so subsequent buildpacks know which runtime version to install
+		runtimeVersion, err := dotnet.GetRuntimeVersion(ctx, outputDirectory)
+		if err != nil {
+			return gcp.InternalErrorf("getting runtime version: %v", err)
+		}
+		binLayer.BuildEnvironment.Default(dotnet.EnvRuntimeVersion, runtimeVersion)
 	}
-	binLayer.BuildEnvironment.Default(dotnet.EnvRuntimeVersion, runtimeVersion)
 
 	// `dotnet publish` output originally went to ctx.ApplicationRoot()/bin/.  This was moved into a
 	// layer, but we create a symlink in the original location for backwards compatability.
@@ -140,7 +191,7 @@ func buildFn(ctx *gcp.Context) error {
 	if entrypoint != "" {
 		entrypoint = "exec " + entrypoint
 	} else {
-		ep, err := getEntrypoint(ctx, outputDirectory, proj)
+		ep, err := getEntrypoint(ctx, outputDirectory, proj, aot)
 		if err != nil {
 			return fmt.Errorf("getting entrypoint: %w", err)
 		}
@@ -149,6 +200,21 @@ func buildFn(ctx *gcp.Context) error {
 	}
 	binLayer.LaunchEnvironment.Default("DOTNET_RUNNING_IN_CONTAINER", "true")
 
+	// Run the native executable directly as there is no shell to change directories.
+	if minRunImage {
+		exe, err := entrypointFile(ctx, outputDirectory, proj, aot)
+		if err != nil {
+			return fmt.Errorf("getting entrypoint: %w", err)
+		}
+		if err := runimage.CheckDynamicDeps(exe); err != nil {
+			return err
+		}
+		// ICU is not installed in the minimal run image.
+		binLayer.LaunchEnvironment.Default("DOTNET_SYSTEM_GLOBALIZATION_INVARIANT", "true")
+		ctx.AddProcess(gcp.WebProcess, []string{exe}, gcp.AsDirectProcess(), gcp.AsDefaultProcess(), gcp.WithWorkingDirectory(filepath.Dir(exe)))
+		return nil
+	}
+
 	// Configure the entrypoint for production.
 	if !devmode.Enabled(ctx) {
 		ctx.AddWebProcess([]string{"/bin/bash", "-c", entrypoint})
@@ -162,14 +228,26 @@ func buildFn(ctx *gcp.Context) error {
 }
 
 // getEntrypoint retrieves the appropriate entrypoint for this build.
-// * Check the output directory for a binary or a library with the same name as the project file (e.g. app.csproj --> app or app.dll).
+// * Check the output directory for a binary or a library with the same name as the project file (e.g. app.csproj --> app or app.dll), Native AOT builds only produce the binary.
 // * If not found, parse the project file for an AssemblyName field and check for the associated binary or library file in the output directory.
 // * If not found, return user error.
-func getEntrypoint(ctx *gcp.Context, bin, proj string) (string, error) {
+func getEntrypoint(ctx *gcp.Context, bin, proj string, aot bool) (string, error) {
+	ep, err := entrypointFile(ctx, bin, proj, aot)
+	if err != nil {
+		return "", err
+	}
+	if aot {
+		return fmt.Sprintf("cd %s && exec ./%s", path.Dir(ep), path.Base(ep)), nil
+	}
+	return fmt.Sprintf("cd %s && exec dotnet %s", path.Dir(ep), path.Base(ep)), nil
+}
+
+// entrypointFile returns the path of the native executable or library that getEntrypoint runs.
+func entrypointFile(ctx *gcp.Context, bin, proj string, aot bool) (string, error) {
 	ctx.Logf("Determining entrypoint from output directory %s and project file %s", bin, proj)
 	p := strings.TrimSuffix(filepath.Base(proj), filepath.Ext(proj))
 
-	ep, err := getEntrypointCmd(ctx, filepath.Join(bin, p))
+	ep, err := outputFile(ctx, filepath.Join(bin, p), aot)
 	if err != nil {
 		return "", err
 	}
@@ -182,7 +260,7 @@ func getEntrypoint(ctx *gcp.Context, bin, proj string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("getting assembly name: %w", err)
 	}
-	ep, err = getEntrypointCmd(ctx, filepath.Join(bin, an))
+	ep, err = outputFile(ctx, filepath.Join(bin, an), aot)
 	if err != nil {
 		return "", err
 	}
@@ -194,43 +272,50 @@ func getEntrypoint(ctx *gcp.Context, bin, proj string) (string, error) {
 	return "", gcp.UserErrorf("unable to find executable produced from %s, try setting the AssemblyName property", proj)
 }
 
-func getEntrypointCmd(ctx *gcp.Context, ep string) (string, error) {
-	dll := ep + ".dll"
-	dllExists, err := ctx.FileExists(dll)
-	if err != nil {
-		return "", err
+// outputFile returns the path of the native executable or library with the given base path if it
+// exists, or an empty string.
+func outputFile(ctx *gcp.Context, base string, aot bool) (string, error) {
+	f := base
+	if !aot {
+		f += ".dll"
 	}
-	if dllExists {
-		return fmt.Sprintf("cd %s && exec dotnet %s", path.Dir(dll), path.Base(dll)), nil
+	exists, err := ctx.FileExists(f)
+	if err != nil || !exists {
+		return "", err
 	}
-	return "", nil
+	return f, nil
 }
 
-func checkCache(ctx *gcp.Context, l *libcnb.Layer) (bool, error) {
-	// We cache all *.*proj files, as if we just cache just the main one, we would miss any changes
-	// to other libraries implemented as part of the app. As many apps are structured such that the
-	// main app only depends on the local binaries, that root project file would change very
-	// infrequently while the associated library files would change significantly more often, as
-	// that's where the primary implementation is done.
-	projectFiles, err := dotnet.ProjectFiles(ctx, ".")
-	if err != nil {
-		return false, err
+// installAOTToolchain installs the native toolchain needed by Native AOT in a build layer unless
+// the build image provides it, and returns the environment needed to use it.
+func installAOTToolchain(ctx *gcp.Context) ([]string, error) {
+	if _, err := lookPath("clang"); err == nil {
+		return nil, nil
 	}
-	globalJSON := filepath.Join(ctx.ApplicationRoot(), "global.json")
-	globalJSONExists, err := ctx.FileExists(globalJSON)
+	ctx.Logf("clang not found in the build image, installing %v", dotnet.AOTToolchainPackages)
+	l, err := ctx.Layer(aotToolchainLayer, gcp.BuildLayer, gcp.CacheLayer)
 	if err != nil {
-		return false, err
+		return nil, fmt.Errorf("creating %v layer: %w", aotToolchainLayer, err)
 	}
-	if globalJSONExists {
-		projectFiles = append(projectFiles, globalJSON)
+	if err := apt.InstallCached(ctx, l, dotnet.AOTToolchainPackages); err != nil {
+		return nil, err
 	}
+	return apt.ExecEnv(ctx, l), nil
+}
+
+func checkCache(ctx *gcp.Context, l *libcnb.Layer) (bool, error) {
 	result, err := ctx.Exec([]string{"dotnet", "--version"})
 	if err != nil {
 		return false, err
 	}
 	currentVersion := result.Stdout
 
-	hash, err := cache.Hash(ctx, cache.WithStrings(currentVersion), cache.WithFiles(projectFiles...))
+	// We cache all *.*proj files, as if we just cache just the main one, we would miss any changes
+	// to other libraries implemented as part of the app. As many apps are structured such that the
+	// main app only depends on the local binaries, that root project file would change very
+	// infrequently while the associated library files would change significantly more often, as
+	// that's where the primary implementation is done.
+	hash, err := cache.Hash(ctx, cache.WithStrings(currentVersion), cache.WithGlob(ctx.ApplicationRoot(), "*.csproj", "*.fsproj", "*.vbproj", "/global.json"))
 	if err != nil {
 		return false, fmt.Errorf("computing dependency hash: %w", err)
 	}
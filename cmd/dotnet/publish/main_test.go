@@ -16,6 +16,7 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -23,6 +24,7 @@ import (
 	"text/template"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 )
 
@@ -113,6 +115,7 @@ func TestGetEntrypoint(t *testing.T) {
 		exe  string
 		proj string
 		data string
+		aot  bool
 		want string
 	}{
 		{
@@ -153,6 +156,27 @@ func TestGetEntrypoint(t *testing.T) {
 	</Project>`,
 			want: "cd {{.Tmp}} && exec dotnet customapp.dll",
 		},
+		{
+			name: "native executable from project file",
+			exe:  "myapp",
+			proj: "myapp.proj",
+			aot:  true,
+			want: "cd {{.Tmp}} && exec ./myapp",
+		},
+		{
+			name: "native executable from assembly name",
+			exe:  "customapp",
+			proj: "myapp.proj",
+			data: `<Project Sdk="Microsoft.NET.Sdk.Web">
+
+		<PropertyGroup>
+			<AssemblyName>customapp</AssemblyName>
+		</PropertyGroup>
+
+	</Project>`,
+			aot:  true,
+			want: "cd {{.Tmp}} && exec ./customapp",
+		},
 	}
 
 	for _, tc := range tcs {
@@ -181,7 +205,7 @@ func TestGetEntrypoint(t *testing.T) {
 				t.Fatalf("writing proj file: %v", err)
 			}
 
-			ep, err := getEntrypoint(ctx, tmpDir, proj)
+			ep, err := getEntrypoint(ctx, tmpDir, proj, tc.aot)
 			if err != nil {
 				t.Fatalf("getting entrypoint: %v", err)
 			}
@@ -279,6 +303,110 @@ func TestDetect(t *testing.T) {
 	}
 }
 
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name            string
+		envs            []string
+		hasClang        bool
+		wantExitCode    int
+		wantCommands    []string
+		skippedCommands []string
+	}{
+		{
+			name:     "native AOT",
+			envs:     []string{"GOOGLE_DOTNET_PUBLISH_AOT=true", "CNB_TARGET_ARCH=amd64"},
+			hasClang: true,
+			wantCommands: []string{
+				"dotnet restore --packages .* --runtime linux-x64 /p:PublishAot=true .*app.csproj",
+				"dotnet publish .* --runtime linux-x64 /p:PublishAot=true .*app.csproj",
+			},
+			skippedCommands: []string{"apt-get"},
+		},
+		{
+			name:     "native AOT on arm64",
+			envs:     []string{"GOOGLE_DOTNET_PUBLISH_AOT=true", "CNB_TARGET_ARCH=arm64"},
+			hasClang: true,
+			wantCommands: []string{
+				"dotnet restore --packages .* --runtime linux-arm64 /p:PublishAot=true .*app.csproj",
+				"dotnet publish .* --runtime linux-arm64 /p:PublishAot=true .*app.csproj",
+			},
+		},
+		{
+			name: "native AOT installs toolchain",
+			envs: []string{"GOOGLE_DOTNET_PUBLISH_AOT=true"},
+			wantCommands: []string{
+				"apt-get .* install clang zlib1g-dev",
+				"dotnet publish .* /p:PublishAot=true",
+			},
+		},
+		{
+			name:            "JIT",
+			envs:            []string{"GOOGLE_ASP_NET_CORE_VERSION=8.0.0"},
+			hasClang:        true,
+			wantCommands:    []string{"dotnet publish"},
+			skippedCommands: []string{"PublishAot"},
+		},
+		{
+			name:            "min run image requires native AOT",
+			envs:            []string{"GOOGLE_RUN_IMAGE_VARIANT=min"},
+			hasClang:        true,
+			wantExitCode:    1,
+			skippedCommands: []string{"dotnet restore", "dotnet publish"},
+		},
+		{
+			name:            "min run image does not support entrypoint",
+			envs:            []string{"GOOGLE_RUN_IMAGE_VARIANT=min", "GOOGLE_DOTNET_PUBLISH_AOT=true"},
+			hasClang:        true,
+			wantExitCode:    1,
+			skippedCommands: []string{"dotnet restore", "dotnet publish"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			origLookPath := lookPath
+			t.Cleanup(func() { lookPath = origLookPath })
+			lookPath = func(file string) (string, error) {
+				if tc.hasClang {
+					return "/usr/bin/" + file, nil
+				}
+				return "", errors.New("executable file not found in $PATH")
+			}
+			opts := []buildpacktest.Option{
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithEnvs(append(tc.envs, "GOOGLE_ENTRYPOINT=./app")...),
+				buildpacktest.WithFiles(map[string]string{"app.csproj": "<Project Sdk=\"Microsoft.NET.Sdk.Web\"></Project>"}),
+				buildpacktest.WithExecMocks(
+					mockprocess.New("find", mockprocess.WithStdout("./app.csproj")),
+					mockprocess.New("dotnet --version", mockprocess.WithStdout("8.0.100")),
+					mockprocess.New("dotnet restore"),
+					mockprocess.New("dotnet publish"),
+					mockprocess.New("apt-get"),
+					mockprocess.New("dpkg"),
+				),
+			}
+
+			result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, result: %#v", err, result)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not", cmd)
+				}
+			}
+			for _, cmd := range tc.skippedCommands {
+				if result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to not be executed, but it was", cmd)
+				}
+			}
+		})
+	}
+}
+
 func TestDeleteFolder(t *testing.T) {
 	testCases := []struct {
 		name         string
@@ -35,7 +35,7 @@ const (
 )
 
 func main() {
-	gcp.Main(detectFn, buildFn)
+	gcp.Main(runtime.WithStackCompatibilityCheck(runtime.DotnetSDK, detectFn), buildFn)
 }
 
 func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
@@ -71,6 +71,14 @@ func buildFn(ctx *gcp.Context) error {
 		// in DevMode we install the SDK into the application image so we don't need the runtime.
 		return nil
 	}
+	aot, err := dotnet.PublishAOT()
+	if err != nil {
+		return gcp.UserErrorf("%v", err)
+	}
+	if aot {
+		ctx.Logf("Not installing the .NET runtime, the application is published as a native executable.")
+		return nil
+	}
 
 	runtimeVersion, err := dotnet.GetRuntimeVersion(ctx, ctx.ApplicationRoot())
 	if err != nil {
@@ -22,10 +22,13 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildcommand"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/golang"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runimage"
 )
 
 const (
@@ -49,10 +52,9 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 }
 
 func buildFn(ctx *gcp.Context) error {
-	// Keep GOCACHE in Devmode for faster rebuilds.
-	cl, err := ctx.Layer("gocache", gcp.BuildLayer, gcp.LaunchLayerIfDevMode)
+	cl, err := golang.NewGoBuildCacheLayer(ctx)
 	if err != nil {
-		return fmt.Errorf("creating layer: %w", err)
+		return err
 	}
 	if devmode.Enabled(ctx) {
 		cl.LaunchEnvironment.Override("GOCACHE", cl.Path)
@@ -66,8 +68,26 @@ func buildFn(ctx *gcp.Context) error {
 	}
 	bl.LaunchEnvironment.Prepend("PATH", string(os.PathListSeparator), bl.Path)
 	outBin := filepath.Join(bl.Path, golang.OutBin)
+	minRunImage, err := runimage.IsMin()
+	if err != nil {
+		return err
+	}
+	// The modules in go.mod are compiled into the binary so they are recorded in the SBOM of its layer.
+	if sbom, err := golang.GoModSBOMEntries(ctx); err != nil {
+		ctx.Warnf("Failed to read go.mod, skipping SBOM entries: %v", err)
+	} else {
+		ctx.AddSBOMEntries(bl, sbom...)
+	}
+
+	if _, ok := buildcommand.Command(); ok {
+		return runBuildCommand(ctx, outBin, minRunImage)
+	}
 
-	buildable, err := goBuildable(ctx)
+	w, err := golang.ReadGoWork(ctx)
+	if err != nil {
+		return err
+	}
+	buildable, err := goBuildable(ctx, w)
 	if err != nil {
 		return fmt.Errorf("unable to find a valid buildable: %w", err)
 	}
@@ -82,9 +102,33 @@ func buildFn(ctx *gcp.Context) error {
 	if workdir == "" {
 		workdir = ctx.ApplicationRoot()
 	}
-	if _, err := ctx.Exec(bld, gcp.WithEnv("GOCACHE="+cl.Path), gcp.WithWorkDir(workdir), gcp.WithMessageProducer(printTipsAndKeepStderrTail(ctx)), gcp.WithUserAttribution); err != nil {
+	buildEnv := []string{"GOCACHE=" + cl.Path}
+	if w != nil {
+		buildEnv = append(buildEnv, w.Env()...)
+	}
+	// The minimal run image has no system packages to link against, build a static binary unless
+	// cgo is explicitly enabled.
+	if minRunImage && os.Getenv("CGO_ENABLED") == "" {
+		buildEnv = append(buildEnv, "CGO_ENABLED=0")
+	}
+	if _, err := ctx.Exec(bld, gcp.WithEnv(buildEnv...), gcp.WithWorkDir(workdir), gcp.WithMessageProducer(printTipsAndKeepStderrTail(ctx)), gcp.WithUserAttribution); err != nil {
 		return err
 	}
+	patterns := []string{"./..."}
+	if w != nil {
+		patterns = w.Patterns()
+	}
+	if err := golang.Vulncheck(ctx, cl.Path, patterns, gcp.WithEnv(buildEnv...), gcp.WithWorkDir(workdir)); err != nil {
+		return err
+	}
+	if _, err := cache.Prune(ctx, cl, cache.ByFile); err != nil {
+		return err
+	}
+	if minRunImage {
+		if err := runimage.CheckDynamicDeps(outBin); err != nil {
+			return err
+		}
+	}
 
 	// Configure the entrypoint for production. Use the full path to save `skaffold debug`
 	// from fetching the remote container image (tens to hundreds of megabytes), which is slow.
@@ -107,16 +151,47 @@ func buildFn(ctx *gcp.Context) error {
 	return nil
 }
 
-func goBuildable(ctx *gcp.Context) (string, error) {
+// runBuildCommand runs the user-provided build command in place of `go build` and copies the
+// resulting binary into the bin layer.
+func runBuildCommand(ctx *gcp.Context, outBin string, minRunImage bool) error {
+	if devmode.Enabled(ctx) {
+		ctx.Warnf("Development mode file watching is not supported with %s.", env.BuildCommand)
+	}
+	outputs, err := buildcommand.Run(ctx, buildcommand.Config{
+		CacheEnv:      map[string]string{"GOCACHE": "go-build"},
+		DefaultOutput: golang.OutBin,
+	})
+	if err != nil {
+		return err
+	}
+	if len(outputs) > 1 {
+		return gcp.UserErrorf("%s matched multiple files %v, expected a single binary", env.BuildOutput, outputs)
+	}
+	if _, err := ctx.Exec([]string{"cp", outputs[0], outBin}, gcp.WithUserAttribution); err != nil {
+		return err
+	}
+	if minRunImage {
+		if err := runimage.CheckDynamicDeps(outBin); err != nil {
+			return err
+		}
+	}
+	ctx.AddWebProcess([]string{outBin})
+	return nil
+}
+
+func goBuildable(ctx *gcp.Context, w *golang.GoWork) (string, error) {
 	// The user tells us what to build.
 	if buildable, ok := os.LookupEnv(env.Buildable); ok {
+		if w != nil {
+			return w.ResolveBuildable(ctx, buildable)
+		}
 		return buildable, nil
 	}
 
 	// We have to guess which package/file to build.
 	// `go build` will by default build the `.` package
 	// but we try to be smarter by searching for a valid buildable.
-	buildables, err := searchBuildables(ctx)
+	buildables, err := searchBuildables(ctx, w)
 	if err != nil {
 		return "", err
 	}
@@ -125,14 +200,32 @@ func goBuildable(ctx *gcp.Context) (string, error) {
 		return buildables[0], nil
 	}
 
+	// The application root is not a package of a workspace that does not use it as a module.
+	if w != nil && !w.UsesRoot() {
+		if len(buildables) == 0 {
+			return "", gcp.UserErrorf("no main package found in the modules of %s %v", golang.GoWorkFile, w.Use)
+		}
+		return "", gcp.UserErrorf("found multiple main packages %v in the modules of %s, set %s to the package to build", buildables, golang.GoWorkFile, env.Buildable)
+	}
+
 	// Found no buildable or multiple buildables. Let Go build the default package.
 	return ".", nil
 }
 
 // searchBuildables searches the source for all the files that contain
 // a `main()` entrypoint.
-func searchBuildables(ctx *gcp.Context) ([]string, error) {
-	result, err := ctx.Exec([]string{"go", "list", "-f", `{{if eq .Name "main"}}{{.Dir}}{{end}}`, "./..."}, gcp.WithUserAttribution)
+func searchBuildables(ctx *gcp.Context, w *golang.GoWork) ([]string, error) {
+	cmd := []string{"go", "list", "-f", `{{if eq .Name "main"}}{{.Dir}}{{end}}`}
+	var opts []gcp.ExecOption
+	if w != nil {
+		// The ./... pattern only matches the packages of the module at the application root, the
+		// packages of each workspace module must be listed explicitly.
+		cmd = append(cmd, w.Patterns()...)
+		opts = append(opts, gcp.WithEnv(w.Env()...))
+	} else {
+		cmd = append(cmd, "./...")
+	}
+	result, err := ctx.Exec(cmd, append(opts, gcp.WithUserAttribution)...)
 	if err != nil {
 		return nil, err
 	}
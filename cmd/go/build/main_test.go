@@ -21,6 +21,7 @@ import (
 	"testing"
 
 	"github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
 )
 
 func TestDetect(t *testing.T) {
@@ -49,6 +50,111 @@ func TestDetect(t *testing.T) {
 	}
 }
 
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name            string
+		envs            []string
+		files           map[string]string
+		mocks           []*mockprocess.Mock
+		wantExitCode    int
+		wantCommands    []string
+		skippedCommands []string
+	}{
+		{
+			name:            "build command",
+			envs:            []string{"GOOGLE_BUILD_COMMAND=make build"},
+			files:           map[string]string{"main.go": "", "main": ""},
+			wantCommands:    []string{"bash -c make build", "cp .*main"},
+			skippedCommands: []string{"go build", "go list"},
+		},
+		{
+			name:         "build command with custom output",
+			envs:         []string{"GOOGLE_BUILD_COMMAND=make build", "GOOGLE_BUILD_OUTPUT=bin/server"},
+			files:        map[string]string{"main.go": "", "bin/server": ""},
+			wantCommands: []string{"bash -c make build", "cp .*bin/server"},
+		},
+		{
+			name:         "build command without output",
+			envs:         []string{"GOOGLE_BUILD_COMMAND=make build"},
+			files:        map[string]string{"main.go": ""},
+			wantExitCode: 1,
+		},
+		{
+			name:            "build command fails",
+			envs:            []string{"GOOGLE_BUILD_COMMAND=make build"},
+			files:           map[string]string{"main.go": "", "main": ""},
+			mocks:           []*mockprocess.Mock{mockprocess.New("make build", mockprocess.WithExitCode(2))},
+			wantExitCode:    1,
+			skippedCommands: []string{"cp .*main"},
+		},
+		{
+			name:         "build command output not an executable with min run image",
+			envs:         []string{"GOOGLE_BUILD_COMMAND=make build", "GOOGLE_RUN_IMAGE_VARIANT=min"},
+			files:        map[string]string{"main.go": "", "main": "#!/bin/sh\n"},
+			wantExitCode: 1,
+			wantCommands: []string{"bash -c make build", "cp .*main"},
+		},
+		{
+			name:            "invalid run image variant",
+			envs:            []string{"GOOGLE_RUN_IMAGE_VARIANT=tiny"},
+			files:           map[string]string{"main.go": ""},
+			wantExitCode:    1,
+			skippedCommands: []string{"go build"},
+		},
+		{
+			name:         "workspace buildable relative to a module",
+			envs:         []string{"GOOGLE_BUILDABLE=./cmd/server"},
+			files:        map[string]string{"go.work": "go 1.21\nuse ./api\n", "api/cmd/server/main.go": ""},
+			mocks:        []*mockprocess.Mock{mockprocess.New("go build")},
+			wantCommands: []string{"go build -o .* ./api/cmd/server"},
+		},
+		{
+			name:  "workspace with multiple main packages",
+			files: map[string]string{"go.work": "go 1.21\nuse (\n\t./api\n\t./worker\n)\n", "api/main.go": "", "worker/main.go": ""},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New("go list", mockprocess.WithStdout("/workspace/api\n/workspace/worker\n")),
+				mockprocess.New("go build"),
+			},
+			wantExitCode:    1,
+			wantCommands:    []string{"go list .* ./api/... ./worker/..."},
+			skippedCommands: []string{"go build"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mocks := tc.mocks
+			if len(mocks) == 0 {
+				mocks = []*mockprocess.Mock{mockprocess.New("make build")}
+			}
+			opts := []buildpacktest.Option{
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithEnvs(tc.envs...),
+				buildpacktest.WithFiles(tc.files),
+				buildpacktest.WithExecMocks(mocks...),
+			}
+
+			result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, result: %#v", err, result)
+			}
+
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not", cmd)
+				}
+			}
+			for _, cmd := range tc.skippedCommands {
+				if result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to not be executed, but it was", cmd)
+				}
+			}
+		})
+	}
+}
+
 func TestGoBuildFlags(t *testing.T) {
 	oldEnv := os.Environ()
 	t.Cleanup(func() {
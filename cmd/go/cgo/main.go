@@ -0,0 +1,81 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements go/cgo buildpack.
+// The cgo buildpack installs the C toolchain and the apt packages needed to build with CGO.
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/apt"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// cgoLayer holds the declared packages, the shared libraries are needed at launch time.
+	cgoLayer = "cgo"
+	// toolchainLayer holds the C compiler when the build image does not provide one.
+	toolchainLayer = "cgo_toolchain"
+)
+
+// toolchainPackages are the packages needed to compile and link C code.
+var toolchainPackages = []string{"gcc", "libc6-dev", "pkg-config"}
+
+// lookPath finds executables on the build image, it is a var for testing.
+var lookPath = exec.LookPath
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	if os.Getenv(env.GoCGOPackages) != "" {
+		return gcp.OptInEnvSet(env.GoCGOPackages), nil
+	}
+	if os.Getenv("CGO_ENABLED") == "1" {
+		return gcp.OptInEnvSet("CGO_ENABLED"), nil
+	}
+	return gcp.OptOut(fmt.Sprintf("neither %s nor CGO_ENABLED=1 is set", env.GoCGOPackages)), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	cc := "gcc"
+	if _, err := lookPath(cc); err != nil {
+		ctx.Logf("gcc not found in the build image, installing %v", toolchainPackages)
+		tl, err := ctx.Layer(toolchainLayer, gcp.BuildLayer, gcp.CacheLayer)
+		if err != nil {
+			return fmt.Errorf("creating %v layer: %w", toolchainLayer, err)
+		}
+		if err := apt.InstallCached(ctx, tl, toolchainPackages); err != nil {
+			return err
+		}
+		cc = filepath.Join(tl.Path, "usr", "bin", "gcc")
+	}
+
+	l, err := ctx.Layer(cgoLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", cgoLayer, err)
+	}
+	if err := apt.InstallCached(ctx, l, apt.ParsePackages(os.Getenv(env.GoCGOPackages))); err != nil {
+		return err
+	}
+	l.BuildEnvironment.Override("CGO_ENABLED", "1")
+	l.BuildEnvironment.Default("CC", cc)
+	return nil
+}
@@ -0,0 +1,123 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name string
+		envs []string
+		want int
+	}{
+		{
+			name: "packages",
+			envs: []string{"GOOGLE_GO_CGO_PACKAGES=libsqlite3-dev"},
+			want: 0,
+		},
+		{
+			name: "CGO_ENABLED",
+			envs: []string{"CGO_ENABLED=1"},
+			want: 0,
+		},
+		{
+			name: "CGO disabled",
+			envs: []string{"CGO_ENABLED=0"},
+			want: 100,
+		},
+		{
+			name: "no env",
+			want: 100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buildpacktest.TestDetect(t, detectFn, tc.name, map[string]string{"main.go": ""}, tc.envs, tc.want)
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name            string
+		envs            []string
+		hasGCC          bool
+		wantCommands    []string
+		skippedCommands []string
+	}{
+		{
+			name:   "packages",
+			envs:   []string{"GOOGLE_GO_CGO_PACKAGES=libvips-dev, libsqlite3-dev"},
+			hasGCC: true,
+			wantCommands: []string{
+				"apt-get .* update",
+				"apt-get .* --download-only --reinstall --no-install-recommends install libsqlite3-dev libvips-dev",
+			},
+			skippedCommands: []string{"install gcc"},
+		},
+		{
+			name: "toolchain",
+			envs: []string{"CGO_ENABLED=1"},
+			wantCommands: []string{
+				"apt-get .* install gcc libc6-dev pkg-config",
+			},
+		},
+		{
+			name:            "nothing to install",
+			envs:            []string{"CGO_ENABLED=1"},
+			hasGCC:          true,
+			skippedCommands: []string{"apt-get"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			origLookPath := lookPath
+			t.Cleanup(func() { lookPath = origLookPath })
+			lookPath = func(file string) (string, error) {
+				if tc.hasGCC {
+					return "/usr/bin/" + file, nil
+				}
+				return "", errors.New("executable file not found in $PATH")
+			}
+			opts := []buildpacktest.Option{
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithEnvs(tc.envs...),
+				buildpacktest.WithExecMocks(mockprocess.New("apt-get"), mockprocess.New("dpkg")),
+			}
+
+			result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+			if err != nil {
+				t.Fatalf("error running build: %v, result: %#v", err, result)
+			}
+
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not", cmd)
+				}
+			}
+			for _, cmd := range tc.skippedCommands {
+				if result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to not be executed, but it was", cmd)
+				}
+			}
+		})
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"strings"
 	"text/template"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cloudfunctions"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/fileutil"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
@@ -71,10 +72,7 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 	if golang.IsGo111Runtime() {
 		return gcp.OptOut("Incompatible with go111"), nil
 	}
-	if _, ok := os.LookupEnv(env.FunctionTarget); ok {
-		return gcp.OptInEnvSet(env.FunctionTarget), nil
-	}
-	return gcp.OptOutEnvNotSet(env.FunctionTarget), nil
+	return gcp.DetectFunction(ctx)
 }
 
 func buildFn(ctx *gcp.Context) error {
@@ -85,9 +83,13 @@ func buildFn(ctx *gcp.Context) error {
 	if err := ctx.SetFunctionsEnvVars(l); err != nil {
 		return err
 	}
-	ctx.AddWebProcess([]string{golang.OutBin})
-
-	fnTarget := os.Getenv(env.FunctionTarget)
+	if err := ctx.AddFunctionWebProcesses([]string{golang.OutBin}); err != nil {
+		return err
+	}
+	targets, err := ctx.FunctionTargets()
+	if err != nil {
+		return err
+	}
 
 	// Move the function source code into a subdirectory in order to construct the app in the main application root.
 	if err := ctx.RemoveAll(fnSourceDir); err != nil {
@@ -113,9 +115,13 @@ func buildFn(ctx *gcp.Context) error {
 	if err != nil {
 		return gcp.UserErrorf("error extracting package name: %v", err)
 	}
+	if _, ok := pkg.Imports[functionsFrameworkFunctionsPackage]; !ok && len(targets) > 1 {
+		// Functions that are not registered declaratively are compiled into the binary by name.
+		return gcp.UserErrorf("multiple function targets require functions registered with the %s package", functionsFrameworkFunctionsPackage)
+	}
 	fn := fnInfo{
 		Source:  fnSource,
-		Target:  fnTarget,
+		Target:  targets[0].Target,
 		Package: pkg.Name,
 		Imports: pkg.Imports,
 	}
@@ -200,10 +206,14 @@ func createMainGoMod(ctx *gcp.Context, fn fnInfo) error {
 		return fmt.Errorf("checking for functions framework dependency in go.mod: %w", err)
 	}
 	if version == "" {
-		if _, err := ctx.Exec([]string{"go", "mod", "edit", "-require", fmt.Sprintf("%s@%s", functionsFrameworkModule, functionsFrameworkVersion)}); err != nil {
+		if version, err = defaultFrameworkVersion(ctx); err != nil {
 			return err
 		}
-		version = functionsFrameworkVersion
+		if _, err := ctx.Exec([]string{"go", "mod", "edit", "-require", fmt.Sprintf("%s@%s", functionsFrameworkModule, version)}); err != nil {
+			return err
+		}
+	} else {
+		cloudfunctions.WarnIfFrameworkVersionIgnored(ctx, "go.mod")
 	}
 
 	if err := createMainGoFile(ctx, fn, filepath.Join(ctx.ApplicationRoot(), "main.go"), version); err != nil {
@@ -358,6 +368,7 @@ func createMainVendored(ctx *gcp.Context, fn fnInfo) error {
 	requestedFrameworkVersion := "v0.0.0"
 	if fnFrameworkVendoredPathExists {
 		ctx.Logf("Found function with vendored dependencies including functions-framework")
+		cloudfunctions.WarnIfFrameworkVersionIgnored(ctx, "the vendor directory")
 		if _, err := ctx.Exec([]string{"cp", "-r", fnVendoredPath, appPath}, gcp.WithUserTimingAttribution); err != nil {
 			return err
 		}
@@ -374,12 +385,16 @@ func createMainVendored(ctx *gcp.Context, fn fnInfo) error {
 		if err != nil {
 			return fmt.Errorf("creating temp directory: %w", err)
 		}
+		version, err := defaultFrameworkVersion(ctx)
+		if err != nil {
+			return err
+		}
 
 		cvt := filepath.Join(ctx.BuildpackRoot(), "converter", "without-framework")
 		cmd := []string{
 			fmt.Sprintf("cp --archive %s/. %s", cvt, ffDepsDir),
 			// The only dependency is the functions framework.
-			fmt.Sprintf("go mod edit -require %s@%s", functionsFrameworkModule, functionsFrameworkVersion),
+			fmt.Sprintf("go mod edit -require %s@%s", functionsFrameworkModule, version),
 			// Download dependencies and generate the go.sum file.
 			"go mod tidy",
 			// Prepare the vendor folder.
@@ -392,12 +407,35 @@ func createMainVendored(ctx *gcp.Context, fn fnInfo) error {
 		}
 
 		// Since the user didn't pin it, we want the current version of the framework.
-		requestedFrameworkVersion = functionsFrameworkVersion
+		requestedFrameworkVersion = version
 	}
 
 	return createMainGoFile(ctx, fn, filepath.Join(appPath, "main.go"), requestedFrameworkVersion)
 }
 
+// defaultFrameworkVersion returns the version of the framework required for functions that do not
+// depend on it, which is selected by GOOGLE_FUNCTIONS_FRAMEWORK_VERSION.
+func defaultFrameworkVersion(ctx *gcp.Context) (string, error) {
+	version, err := cloudfunctions.FrameworkVersion(ctx, "go")
+	if err != nil {
+		return "", err
+	}
+	switch version {
+	case cloudfunctions.FrameworkStable:
+		return functionsFrameworkVersion, nil
+	case cloudfunctions.FrameworkLatest:
+		res, err := golang.ExecWithGoproxyFallback(ctx, []string{"go", "list", "-m", "-f", "{{.Version}}", functionsFrameworkModule + "@latest"}, gcp.WithUserAttribution)
+		if err != nil {
+			return "", fmt.Errorf("finding the latest version of %s: %w", functionsFrameworkModule, err)
+		}
+		latest := strings.TrimSpace(res.Stdout)
+		ctx.Logf("The latest version of %s is %s.", functionsFrameworkModule, latest)
+		return latest, nil
+	default:
+		return "v" + version, nil
+	}
+}
+
 func createMainGoFile(ctx *gcp.Context, fn fnInfo, main, version string) error {
 	f, err := ctx.CreateFile(main)
 	if err != nil {
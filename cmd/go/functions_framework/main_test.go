@@ -53,6 +53,7 @@ func TestBuild(t *testing.T) {
 		app          string
 		envs         []string
 		fnPkgName    string
+		declarative  bool
 		opts         []bpt.Option
 		mocks        []*mockprocess.Mock
 		wantExitCode int // 0 if unspecified
@@ -81,6 +82,57 @@ func TestBuild(t *testing.T) {
 				"go mod tidy",
 			},
 		},
+		{
+			name:      "go mod function without framework with pinned version",
+			app:       "no_framework",
+			envs:      []string{"GOOGLE_FUNCTION_TARGET=Func", "GOOGLE_FUNCTIONS_FRAMEWORK_VERSION=1.6.1"},
+			fnPkgName: "myfunc",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^go list -m$`, mockprocess.WithStdout("example.com/myfunc")),
+			},
+			wantCommands: []string{
+				fmt.Sprintf("go mod edit -require %s@v1.6.1", functionsFrameworkModule),
+			},
+		},
+		{
+			name:      "go mod function without framework with latest version",
+			app:       "no_framework",
+			envs:      []string{"GOOGLE_FUNCTION_TARGET=Func", "GOOGLE_FUNCTIONS_FRAMEWORK_VERSION=latest"},
+			fnPkgName: "myfunc",
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^go list -m$`, mockprocess.WithStdout("example.com/myfunc")),
+				mockprocess.New(`^go list -m -f {{.Version}} .*@latest$`, mockprocess.WithStdout("v1.8.0")),
+			},
+			wantCommands: []string{
+				fmt.Sprintf("go mod edit -require %s@v1.8.0", functionsFrameworkModule),
+			},
+		},
+		{
+			name:         "go mod function without framework with unsupported version",
+			app:          "no_framework",
+			envs:         []string{"GOOGLE_FUNCTION_TARGET=Func", "GOOGLE_FUNCTIONS_FRAMEWORK_VERSION=0.9.0"},
+			fnPkgName:    "myfunc",
+			mocks:        []*mockprocess.Mock{mockprocess.New(`^go list -m$`, mockprocess.WithStdout("example.com/myfunc"))},
+			wantExitCode: 1,
+		},
+		{
+			name:        "declarative function with multiple targets",
+			app:         "with_framework",
+			envs:        []string{"GOOGLE_FUNCTION_TARGET=HelloHTTP,HelloEvent"},
+			fnPkgName:   "myfunc",
+			declarative: true,
+			mocks: []*mockprocess.Mock{
+				mockprocess.New(`^go list -m$`, mockprocess.WithStdout("example.com/myfunc")),
+			},
+			wantCommands: []string{"go mod tidy"},
+		},
+		{
+			name:         "non-declarative function with multiple targets",
+			app:          "with_framework",
+			envs:         []string{"GOOGLE_FUNCTION_TARGET=HelloHTTP,HelloEvent"},
+			fnPkgName:    "myfunc",
+			wantExitCode: 1,
+		},
 		{
 			name:         "vendored function",
 			app:          "no_framework_vendored_no_go_mod",
@@ -123,8 +175,12 @@ func TestBuild(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
+			pkg := fmt.Sprintf(`{"name":"%s"}`, tc.fnPkgName)
+			if tc.declarative {
+				pkg = fmt.Sprintf(`{"name":"%s","imports":{"%s":{}}}`, tc.fnPkgName, functionsFrameworkFunctionsPackage)
+			}
 			mocks := []*mockprocess.Mock{
-				mockprocess.New("get_package", mockprocess.WithStdout(fmt.Sprintf(`{"name":"%s"}`, tc.fnPkgName))),
+				mockprocess.New("get_package", mockprocess.WithStdout(pkg)),
 			}
 			mocks = append(mocks, tc.mocks...)
 
@@ -13,7 +13,7 @@
 // limitations under the License.
 
 // Implements go/gomod buildpack.
-// The gomod buildpack downloads modules specified in go.mod.
+// The gomod buildpack downloads modules specified in go.mod, or in the modules of a go.work workspace.
 package main
 
 import (
@@ -36,7 +36,14 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 	if goModExists {
 		return gcp.OptInFileFound("go.mod"), nil
 	}
-	return gcp.OptOutFileNotFound("go.mod"), nil
+	goWorkExists, err := ctx.FileExists(golang.GoWorkFile)
+	if err != nil {
+		return nil, err
+	}
+	if goWorkExists {
+		return gcp.OptInFileFound(golang.GoWorkFile), nil
+	}
+	return gcp.OptOut("neither go.mod nor go.work found"), nil
 }
 
 func buildFn(ctx *gcp.Context) error {
@@ -64,6 +71,17 @@ func buildFn(ctx *gcp.Context) error {
 		ctx.Warnf(`Ignoring "vendor" directory: To use vendor directory, the Go runtime must be 1.14+ and go.mod must contain a "go 1.14"+ entry. See https://cloud.google.com/appengine/docs/standard/go/specifying-dependencies#vendoring_dependencies.`)
 	}
 
+	w, err := golang.ReadGoWork(ctx)
+	if err != nil {
+		return err
+	}
+	if w != nil {
+		if err := downloadWorkspaceModules(ctx, w, l.Path); err != nil {
+			return err
+		}
+		return golang.SaveRemoteCache(ctx, l)
+	}
+
 	goModIsWriteable, err := ctx.IsWritable("go.mod")
 	if err != nil {
 		return err
@@ -98,5 +116,17 @@ func buildFn(ctx *gcp.Context) error {
 		return fmt.Errorf("running go mod download: %w", err)
 	}
 
+	return golang.SaveRemoteCache(ctx, l)
+}
+
+// downloadWorkspaceModules downloads the modules required by the modules of a go.work workspace.
+// `go mod tidy` is not supported in workspace mode so each module must have a complete go.sum, or
+// the workspace a go.work.sum.
+func downloadWorkspaceModules(ctx *gcp.Context, w *golang.GoWork, gopath string) error {
+	ctx.Logf("Downloading modules of the workspace modules %v", w.Use)
+	env := append([]string{"GOPATH=" + gopath, "GO111MODULE=on"}, w.Env()...)
+	if _, err := golang.ExecWithGoproxyFallback(ctx, []string{"go", "mod", "download"}, gcp.WithEnv(env...), gcp.WithUserAttribution); err != nil {
+		return fmt.Errorf("running go mod download: %w", err)
+	}
 	return nil
 }
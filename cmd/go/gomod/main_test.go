@@ -33,6 +33,13 @@ func TestDetect(t *testing.T) {
 			},
 			want: 0,
 		},
+		{
+			name: "with go.work",
+			files: map[string]string{
+				"go.work": "go 1.21\n\nuse ./api\n",
+			},
+			want: 0,
+		},
 		{
 			name:  "without go.mod",
 			files: map[string]string{},
@@ -23,8 +23,10 @@ import (
 	"os"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/golang"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
 	"github.com/buildpacks/libcnb"
 )
@@ -32,9 +34,10 @@ import (
 const (
 	// goVersionURL is a URL to a JSON file that contains the latest Go version names.
 	goVersionURL = "https://golang.org/dl/?mode=json"
-	goURL        = "https://dl.google.com/go/go%s.linux-amd64.tar.gz"
+	goURL        = "https://dl.google.com/go/go%s.linux-%s.tar.gz"
 	goLayer      = "go"
 	versionKey   = "version"
+	archKey      = "arch"
 	envGoVersion = "GOOGLE_GO_VERSION"
 )
 
@@ -68,7 +71,12 @@ func buildFn(ctx *gcp.Context) error {
 
 	// Check metadata layer to see if correct version of Go is already installed.
 	metaVersion := ctx.GetMetadata(grl, versionKey)
-	if version == metaVersion {
+	metaArch := ctx.GetMetadata(grl, archKey)
+	if metaArch == "" {
+		// Layers cached before arm64 support were all installed for amd64.
+		metaArch = gcp.ArchAMD64
+	}
+	if version == metaVersion && metaArch == ctx.Arch() {
 		ctx.CacheHit(goLayer)
 	} else {
 		ctx.CacheMiss(goLayer)
@@ -76,22 +84,22 @@ func buildFn(ctx *gcp.Context) error {
 			return fmt.Errorf("clearing layer %q: %w", grl.Name, err)
 		}
 
-		archiveURL := fmt.Sprintf(goURL, version)
+		archiveURL := fmt.Sprintf(goURL, version, ctx.Arch())
 		code, err := ctx.HTTPStatus(archiveURL)
 		if err != nil {
 			return err
 		}
 		if code != http.StatusOK {
-			return gcp.UserErrorf("Runtime version %s does not exist at %s (status %d). You can specify the version with %s.", version, archiveURL, code, env.RuntimeVersion)
+			return gcp.UserErrorf("Runtime version %s does not exist at %s (status %d). You can specify the version with %s.", version, archiveURL, code, env.RuntimeVersion).WithCode(buildererror.CodeInvalidRuntimeVersion)
 		}
 
 		// Download and install Go in layer.
 		ctx.Logf("Installing Go v%s", version)
-		command := fmt.Sprintf("curl --fail --show-error --silent --location --retry 3 %s | tar xz --directory %s --strip-components=1", archiveURL, grl.Path)
-		if _, err := ctx.Exec([]string{"bash", "-c", command}, gcp.WithUserAttribution); err != nil {
+		if err := runtime.DownloadTarball(ctx, archiveURL, archiveURL+".sha256", grl.Path, 1); err != nil {
 			return err
 		}
 		ctx.SetMetadata(grl, versionKey, version)
+		ctx.SetMetadata(grl, archKey, ctx.Arch())
 	}
 
 	ctx.AddBOMEntry(libcnb.BOMEntry{
@@ -114,7 +122,14 @@ func runtimeVersion(ctx *gcp.Context) (string, error) {
 		ctx.Logf("Using runtime version from %s: %s", env.RuntimeVersion, version)
 		return version, nil
 	}
-	version, err := latestGoVersion(ctx)
+	version, err := golang.ToolchainVersion(ctx)
+	if err != nil {
+		return "", err
+	}
+	if version != "" {
+		return version, nil
+	}
+	version, err = latestGoVersion(ctx)
 	if err != nil {
 		return "", fmt.Errorf("getting latest version: %w", err)
 	}
@@ -129,11 +144,11 @@ type goReleases []struct {
 
 // latestGoVersion returns the latest version of Go
 func latestGoVersion(ctx *gcp.Context) (string, error) {
-	result, err := ctx.Exec([]string{"curl", "--fail", "--show-error", "--silent", "--location", goVersionURL}, gcp.WithUserAttribution)
+	body, err := ctx.DownloadBytes(goVersionURL)
 	if err != nil {
 		return "", err
 	}
-	return parseVersionJSON(result.Stdout)
+	return parseVersionJSON(string(body))
 }
 
 func parseVersionJSON(jsonStr string) (string, error) {
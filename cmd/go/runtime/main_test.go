@@ -15,7 +15,9 @@
 package main
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
@@ -176,3 +178,22 @@ func TestRuntimeVersion(t *testing.T) {
 		})
 	}
 }
+
+func TestRuntimeVersionFromToolchain(t *testing.T) {
+	t.Setenv("GOOGLE_GO_VERSION", "")
+	t.Setenv("GOOGLE_RUNTIME_VERSION", "")
+	t.Setenv("GOTOOLCHAIN", "")
+	dir := t.TempDir()
+	goMod := "module example.com/app\n\ngo 1.21\n\ntoolchain go1.22.3\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0644); err != nil {
+		t.Fatalf("writing go.mod: %v", err)
+	}
+
+	v, err := runtimeVersion(gcp.NewContext(gcp.WithApplicationRoot(dir)))
+	if err != nil {
+		t.Fatalf("runtimeVersion() failed: %v", err)
+	}
+	if want := "1.22.3"; v != want {
+		t.Errorf("runtimeVersion() = %q, want %q", v, want)
+	}
+}
@@ -17,12 +17,22 @@ package main
 
 import (
 	"fmt"
+	"path/filepath"
+	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/java"
 )
 
+const (
+	// startClassKey is the manifest entry of Spring Boot jars that holds the application main class.
+	startClassKey = "Start-Class"
+	// jarLauncherSuffix matches the Main-Class of Spring Boot jars that use the default launcher,
+	// e.g. org.springframework.boot.loader.JarLauncher or org.springframework.boot.loader.launch.JarLauncher.
+	jarLauncherSuffix = ".JarLauncher"
+)
+
 func main() {
 	gcp.Main(detectFn, buildFn)
 }
@@ -54,6 +64,90 @@ func buildFn(ctx *gcp.Context) error {
 	}
 
 	// Configure the entrypoint for production.
+	layered, err := springBootLayers(ctx, executable)
+	if err != nil {
+		return err
+	}
+	if len(layered) > 0 {
+		command = layered
+	}
 	ctx.AddWebProcess(command)
 	return nil
 }
+
+// springBootLayers extracts a Spring Boot layered jar into one launch layer per jar layer and
+// returns the command that runs the application from the extracted layers. Each layer is exported
+// as a separate image layer, so rebuilds that only change application code reuse the unchanged
+// dependency layers. It returns a nil command if the jar is not a layered Spring Boot jar.
+func springBootLayers(ctx *gcp.Context, jar string) ([]string, error) {
+	layers, err := java.SpringBootLayers(jar)
+	if err != nil || len(layers) == 0 {
+		return nil, err
+	}
+	// Jars with a custom launcher, e.g. PropertiesLauncher, may depend on how the launcher builds
+	// the class path, so they keep running with java -jar.
+	main, err := java.MainManifestEntry(jar)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(main, jarLauncherSuffix) {
+		ctx.Debugf("Not extracting layers of %s, it uses the custom launcher %s.", jar, main)
+		return nil, nil
+	}
+	startClass, err := java.FindManifestValueFromJar(jar, startClassKey)
+	if err != nil {
+		return nil, err
+	}
+	if startClass == "" {
+		return nil, gcp.UserErrorf("no %s manifest entry found in Spring Boot jar %s", startClassKey, jar)
+	}
+
+	dir, err := ctx.TempDir("spring-boot-layers")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := ctx.Exec([]string{"java", "-Djarmode=layertools", "-jar", jar, "extract", "--destination", dir}, gcp.WithUserAttribution); err != nil {
+		return nil, err
+	}
+
+	var classes, libs []string
+	for _, name := range layers {
+		extracted := filepath.Join(dir, name)
+		// Layers without content, e.g. snapshot-dependencies, are not extracted.
+		exists, err := ctx.FileExists(extracted)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+		l, err := ctx.Layer(name, gcp.LaunchLayer)
+		if err != nil {
+			return nil, fmt.Errorf("creating %v layer: %w", name, err)
+		}
+		if err := ctx.RemoveAll(l.Path); err != nil {
+			return nil, err
+		}
+		if err := ctx.Rename(extracted, l.Path); err != nil {
+			return nil, err
+		}
+		if exists, err := ctx.FileExists(l.Path, "BOOT-INF", "classes"); err != nil {
+			return nil, err
+		} else if exists {
+			classes = append(classes, filepath.Join(l.Path, "BOOT-INF", "classes"))
+		}
+		if exists, err := ctx.FileExists(l.Path, "BOOT-INF", "lib"); err != nil {
+			return nil, err
+		} else if exists {
+			libs = append(libs, filepath.Join(l.Path, "BOOT-INF", "lib", "*"))
+		}
+	}
+	if len(classes) == 0 && len(libs) == 0 {
+		return nil, gcp.UserErrorf("extracting Spring Boot jar %s did not produce any classes", jar)
+	}
+
+	// The application classes take precedence over dependencies, as with the Spring Boot launcher.
+	classPath := strings.Join(append(classes, libs...), ":")
+	ctx.Logf("Running %s from the extracted layers of %s.", startClass, jar)
+	return []string{"java", "-cp", classPath, startClass}, nil
+}
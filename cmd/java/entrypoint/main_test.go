@@ -15,12 +15,172 @@
 package main
 
 import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+const (
+	layersIndex = `- "dependencies":
+  - "BOOT-INF/lib/"
+- "spring-boot-loader":
+  - "org/"
+- "snapshot-dependencies":
+- "application":
+  - "BOOT-INF/classes/"
+  - "META-INF/"
+`
+	layeredManifest = "Main-Class: org.springframework.boot.loader.JarLauncher\nStart-Class: com.example.Application\nSpring-Boot-Layers-Index: BOOT-INF/layers.idx\n"
 )
 
 func TestDetect(t *testing.T) {
 	// The buildpack always opts in.
 	buildpacktest.TestDetect(t, detectFn, "no files", map[string]string{}, []string{}, 0)
 }
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name            string
+		files           map[string]string
+		mocks           []*mockprocess.Mock
+		wantExitCode    int
+		wantCommands    []string
+		skippedCommands []string
+	}{
+		{
+			name:            "executable jar",
+			files:           map[string]string{"target/app.jar": jar(t, map[string]string{"META-INF/MANIFEST.MF": "Main-Class: com.example.Main\n"})},
+			skippedCommands: []string{"layertools"},
+		},
+		{
+			name:            "spring boot jar without layers",
+			files:           map[string]string{"target/app.jar": jar(t, map[string]string{"META-INF/MANIFEST.MF": "Main-Class: org.springframework.boot.loader.JarLauncher\nStart-Class: com.example.Application\n"})},
+			skippedCommands: []string{"layertools"},
+		},
+		{
+			name: "layered jar with custom launcher",
+			files: map[string]string{"target/app.jar": jar(t, map[string]string{
+				"META-INF/MANIFEST.MF": "Main-Class: org.springframework.boot.loader.PropertiesLauncher\nStart-Class: com.example.Application\nSpring-Boot-Layers-Index: BOOT-INF/layers.idx\n",
+				"BOOT-INF/layers.idx":  layersIndex,
+			})},
+			skippedCommands: []string{"layertools"},
+		},
+		{
+			name: "layered jar without start class",
+			files: map[string]string{"target/app.jar": jar(t, map[string]string{
+				"META-INF/MANIFEST.MF": "Main-Class: org.springframework.boot.loader.JarLauncher\nSpring-Boot-Layers-Index: BOOT-INF/layers.idx\n",
+				"BOOT-INF/layers.idx":  layersIndex,
+			})},
+			wantExitCode:    1,
+			skippedCommands: []string{"layertools"},
+		},
+		{
+			name: "layer extraction fails",
+			files: map[string]string{"target/app.jar": jar(t, map[string]string{
+				"META-INF/MANIFEST.MF": layeredManifest,
+				"BOOT-INF/layers.idx":  layersIndex,
+			})},
+			mocks:        []*mockprocess.Mock{mockprocess.New("layertools", mockprocess.WithExitCode(1))},
+			wantExitCode: 1,
+			wantCommands: []string{"java -Djarmode=layertools -jar .*target/app.jar extract --destination"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []buildpacktest.Option{
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithFiles(tc.files),
+			}
+			if len(tc.mocks) > 0 {
+				opts = append(opts, buildpacktest.WithExecMocks(tc.mocks...))
+			}
+
+			result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, result: %#v", err, result)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not", cmd)
+				}
+			}
+			for _, cmd := range tc.skippedCommands {
+				if result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to not be executed, but it was", cmd)
+				}
+			}
+		})
+	}
+}
+
+func TestSpringBootLayers(t *testing.T) {
+	jarPath := filepath.Join(t.TempDir(), "app.jar")
+	content := jar(t, map[string]string{"META-INF/MANIFEST.MF": layeredManifest, "BOOT-INF/layers.idx": layersIndex})
+	if err := ioutil.WriteFile(jarPath, []byte(content), 0644); err != nil {
+		t.Fatalf("writing %s: %v", jarPath, err)
+	}
+	layers := t.TempDir()
+	// Simulate the extraction of a jar without snapshot dependencies.
+	extract := func(name string, args ...string) *exec.Cmd {
+		dest := args[len(args)-1]
+		script := fmt.Sprintf("mkdir -p %[1]s/dependencies/BOOT-INF/lib %[1]s/spring-boot-loader/org %[1]s/application/BOOT-INF/classes", dest)
+		return exec.Command("sh", "-c", script)
+	}
+	ctx := gcp.NewContext(
+		gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layers}}),
+		gcp.WithExecCmd(extract),
+	)
+
+	got, err := springBootLayers(ctx, jarPath)
+	if err != nil {
+		t.Fatalf("springBootLayers() got error: %v", err)
+	}
+
+	classPath := filepath.Join(layers, "application", "BOOT-INF", "classes") + ":" + filepath.Join(layers, "dependencies", "BOOT-INF", "lib", "*")
+	want := []string{"java", "-cp", classPath, "com.example.Application"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("springBootLayers() mismatch (-want +got):\n%s", diff)
+	}
+	var launchLayers []string
+	for _, l := range ctx.Layers() {
+		if l.Launch {
+			launchLayers = append(launchLayers, l.Name)
+		}
+	}
+	if diff := cmp.Diff([]string{"dependencies", "spring-boot-loader", "application"}, launchLayers); diff != "" {
+		t.Errorf("launch layers mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// jar returns the content of a jar with the given files.
+func jar(t *testing.T, files map[string]string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("creating %s: %v", name, err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatalf("writing %s: %v", name, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("closing jar: %v", err)
+	}
+	return buf.String()
+}
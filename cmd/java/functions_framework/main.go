@@ -17,12 +17,14 @@
 package main
 
 import (
+	"encoding/xml"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
-	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cloudfunctions"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/java"
 	"github.com/buildpacks/libcnb"
@@ -30,22 +32,22 @@ import (
 
 const (
 	layerName                     = "functions-framework"
-	javaFunctionInvokerURLBase    = "https://maven-central.storage-download.googleapis.com/maven2/com/google/cloud/functions/invoker/java-function-invoker/"
 	defaultFrameworkVersion       = "1.1.0"
-	functionsFrameworkURLTemplate = javaFunctionInvokerURLBase + "%[1]s/java-function-invoker-%[1]s.jar"
+	functionsFrameworkURLTemplate = "%[1]s/java-function-invoker-%[1]s.jar"
 	versionKey                    = "version"
 	invokerMain                   = "com.google.cloud.functions.invoker.runner.Invoker"
 )
 
+// javaFunctionInvokerURLBase is the Maven repository directory of the invoker, it is a var for
+// testing.
+var javaFunctionInvokerURLBase = "https://maven-central.storage-download.googleapis.com/maven2/com/google/cloud/functions/invoker/java-function-invoker/"
+
 func main() {
 	gcp.Main(detectFn, buildFn)
 }
 
 func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
-	if _, ok := os.LookupEnv(env.FunctionTarget); ok {
-		return gcp.OptInEnvSet(env.FunctionTarget), nil
-	}
-	return gcp.OptOutEnvNotSet(env.FunctionTarget), nil
+	return gcp.DetectFunction(ctx)
 }
 
 func buildFn(ctx *gcp.Context) error {
@@ -74,18 +76,21 @@ func buildFn(ctx *gcp.Context) error {
 	// Success here doesn't guarantee that the function will execute. It might not implement one of the
 	// required interfaces, for example. But it eliminates the commonest problem of specifying the wrong target.
 	// We use an ExecUser* method so that the time taken by the javap command is counted as user time.
-	target := os.Getenv(env.FunctionTarget)
-	if result, err := ctx.Exec([]string{"javap", "-classpath", classpath, target}, gcp.WithUserAttribution); err != nil {
-		// The javap error output will typically be "Error: class not found: foo.Bar".
-		return gcp.UserErrorf("build succeeded but did not produce the class %q specified as the function target: %s", target, result.Combined)
+	targets, err := ctx.FunctionTargets()
+	if err != nil {
+		return err
+	}
+	for _, t := range targets {
+		if result, err := ctx.Exec([]string{"javap", "-classpath", classpath, t.Target}, gcp.WithUserAttribution); err != nil {
+			// The javap error output will typically be "Error: class not found: foo.Bar".
+			return gcp.UserErrorf("build succeeded but did not produce the class %q specified as the function target: %s", t.Target, result.Combined).WithCode(buildererror.CodeFunctionTargetNotFound)
+		}
 	}
 
 	launcherSource := filepath.Join(ctx.BuildpackRoot(), "launch.sh")
 	launcherTarget := filepath.Join(layer.Path, "launch.sh")
 	createLauncher(ctx, launcherSource, launcherTarget)
-	ctx.AddWebProcess([]string{launcherTarget, "java", "-jar", ffPath, "--classpath", classpath})
-
-	return nil
+	return ctx.AddFunctionWebProcesses([]string{launcherTarget, "java", "-jar", ffPath, "--classpath", classpath})
 }
 
 func createLauncher(ctx *gcp.Context, launcherSource, launcherTarget string) error {
@@ -146,14 +151,18 @@ func mavenClasspath(ctx *gcp.Context) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	settingsArgs, err := java.MavenSettingsArgs(ctx, ".")
+	if err != nil {
+		return "", err
+	}
 
 	// Copy the dependencies of the function (`<dependencies>` in pom.xml) into target/dependency.
-	if _, err := ctx.Exec([]string{mvn, "--batch-mode", "dependency:copy-dependencies", "-Dmdep.prependGroupId", "-DincludeScope=runtime"}, gcp.WithUserAttribution); err != nil {
+	if _, err := ctx.Exec(append([]string{mvn, "--batch-mode", "dependency:copy-dependencies", "-Dmdep.prependGroupId", "-DincludeScope=runtime"}, settingsArgs...), gcp.WithUserAttribution); err != nil {
 		return "", err
 	}
 
 	// Extract the final jar name from the user's pom.xml definitions.
-	execResult, err := ctx.Exec([]string{mvn, "help:evaluate", "-q", "-DforceStdout", "-Dexpression=project.build.finalName"}, gcp.WithUserAttribution)
+	execResult, err := ctx.Exec(append([]string{mvn, "help:evaluate", "-q", "-DforceStdout", "-Dexpression=project.build.finalName"}, settingsArgs...), gcp.WithUserAttribution)
 	if err != nil {
 		return "", err
 	}
@@ -269,6 +278,7 @@ func installFunctionsFramework(ctx *gcp.Context, layer *libcnb.Layer) (string, e
 		}
 	}
 	if len(jars) == 1 && isInvokerJar(ctx, jars[0]) {
+		cloudfunctions.WarnIfFrameworkVersionIgnored(ctx, "the build dependencies")
 		if err := ctx.ClearLayer(layer); err != nil {
 			return "", fmt.Errorf("clearing layer %q: %w", layer.Name, err)
 		}
@@ -277,7 +287,10 @@ func installFunctionsFramework(ctx *gcp.Context, layer *libcnb.Layer) (string, e
 		return jars[0], nil
 	}
 
-	frameworkVersion := defaultFrameworkVersion
+	frameworkVersion, err := frameworkVersion(ctx)
+	if err != nil {
+		return "", err
+	}
 
 	// Install functions-framework.
 	metaVersion := ctx.GetMetadata(layer, versionKey)
@@ -304,13 +317,40 @@ func isInvokerJar(ctx *gcp.Context, jar string) bool {
 	return err == nil && main == invokerMain
 }
 
+// frameworkVersion returns the version of the invoker to install, which is selected by
+// GOOGLE_FUNCTIONS_FRAMEWORK_VERSION.
+func frameworkVersion(ctx *gcp.Context) (string, error) {
+	version, err := cloudfunctions.FrameworkVersion(ctx, "java")
+	if err != nil {
+		return "", err
+	}
+	switch version {
+	case cloudfunctions.FrameworkStable:
+		return defaultFrameworkVersion, nil
+	case cloudfunctions.FrameworkLatest:
+		body, err := ctx.DownloadBytes(javaFunctionInvokerURLBase + "maven-metadata.xml")
+		if err != nil {
+			return "", gcp.InternalErrorf("fetching functions framework metadata: %v", err)
+		}
+		var metadata struct {
+			Release string `xml:"versioning>release"`
+		}
+		if err := xml.Unmarshal(body, &metadata); err != nil || metadata.Release == "" {
+			return "", gcp.InternalErrorf("finding the latest functions framework release in maven-metadata.xml: %v", err)
+		}
+		ctx.Logf("The latest version of the functions framework invoker is %s.", metadata.Release)
+		return metadata.Release, nil
+	default:
+		return version, nil
+	}
+}
+
 // installFramework downloads the functions framework invoker jar and saves it in the provided layer.
 func installFramework(ctx *gcp.Context, layer *libcnb.Layer, version string) error {
-	url := fmt.Sprintf(functionsFrameworkURLTemplate, version)
+	url := javaFunctionInvokerURLBase + fmt.Sprintf(functionsFrameworkURLTemplate, version)
 	ffName := filepath.Join(layer.Path, "functions-framework.jar")
-	result, err := ctx.Exec([]string{"curl", "--silent", "--fail", "--show-error", "--output", ffName, url})
-	if err != nil {
-		return gcp.InternalErrorf("fetching functions framework jar: %v\n%s", err, result.Stderr)
+	if err := ctx.Download(url, ffName); err != nil {
+		return gcp.InternalErrorf("fetching functions framework jar: %v", err)
 	}
 	return nil
 }
@@ -15,9 +15,14 @@
 package main
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
 )
 
 func TestDetect(t *testing.T) {
@@ -33,6 +38,11 @@ func TestDetect(t *testing.T) {
 			env:  []string{"GOOGLE_FUNCTION_TARGET=HelloWorld"},
 			want: 0,
 		},
+		{
+			name:  "with functions.yaml",
+			files: map[string]string{"functions.yaml": "functions:\n- target: HelloWorld\n"},
+			want:  0,
+		},
 		{
 			name: "without target",
 			want: 100,
@@ -44,3 +54,77 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name         string
+		env          []string
+		mocks        []*mockprocess.Mock
+		wantVersion  string
+		wantCommands []string
+		wantExitCode int
+	}{
+		{
+			name:        "default version",
+			wantVersion: defaultFrameworkVersion,
+		},
+		{
+			name:        "pinned version",
+			env:         []string{"GOOGLE_FUNCTIONS_FRAMEWORK_VERSION=1.3.1"},
+			wantVersion: "1.3.1",
+		},
+		{
+			name:        "latest version",
+			env:         []string{"GOOGLE_FUNCTIONS_FRAMEWORK_VERSION=latest"},
+			wantVersion: "1.3.2",
+		},
+		{
+			name:         "multiple targets",
+			env:          []string{"GOOGLE_FUNCTION_TARGET=HelloWorld,HelloEvent"},
+			wantVersion:  defaultFrameworkVersion,
+			wantCommands: []string{"javap -classpath hello.jar HelloWorld", "javap -classpath hello.jar HelloEvent"},
+		},
+		{
+			name:         "unsupported version",
+			env:          []string{"GOOGLE_FUNCTIONS_FRAMEWORK_VERSION=0.9.0"},
+			wantExitCode: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasSuffix(r.URL.Path, "maven-metadata.xml") {
+					fmt.Fprint(w, `<metadata><versioning><latest>1.4.0-SNAPSHOT</latest><release>1.3.2</release></versioning></metadata>`)
+				}
+			}))
+			defer svr.Close()
+			origURL := javaFunctionInvokerURLBase
+			javaFunctionInvokerURLBase = svr.URL + "/"
+			t.Cleanup(func() { javaFunctionInvokerURLBase = origURL })
+			opts := []buildpacktest.Option{
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithEnvs(append([]string{"GOOGLE_FUNCTION_TARGET=HelloWorld"}, tc.env...)...),
+				buildpacktest.WithFiles(map[string]string{"hello.jar": ""}),
+				buildpacktest.WithExecMocks(append(tc.mocks, mockprocess.New(`^javap`))...),
+			}
+			result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d, logs: %s", result.ExitCode, tc.wantExitCode, result.Output)
+			}
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, build output: %s", cmd, result.Output)
+				}
+			}
+			if tc.wantVersion == "" {
+				return
+			}
+			if want := "java-function-invoker-" + tc.wantVersion + ".jar"; !strings.Contains(result.Output, want) {
+				t.Errorf("expected the invoker to be downloaded with %q, build output: %s", want, result.Output)
+			}
+		})
+	}
+}
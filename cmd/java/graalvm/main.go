@@ -18,11 +18,11 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
-	"github.com/buildpacks/libcnb"
 )
 
 const (
@@ -35,11 +35,6 @@ const (
 	versionKey     = "version"
 )
 
-var (
-	providesGraalvm = []libcnb.BuildPlanProvide{{Name: "graalvm"}}
-	planProvides    = libcnb.BuildPlan{Provides: providesGraalvm}
-)
-
 func main() {
 	gcp.Main(detectFn, buildFn)
 }
@@ -47,12 +42,16 @@ func main() {
 func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 	useNativeImage, err := env.IsUsingNativeImage()
 	if err != nil {
-		return nil, gcp.UserErrorf("failed to parse GOOGLE_JAVA_USE_NATIVE_IMAGE: %v", err)
+		return nil, gcp.UserErrorf("failed to parse native image setting: %v", err)
 	}
 
 	if useNativeImage {
 		ctx.Warnf("The GraalVM Native Image buildpack is enabled. Note: This is under development and not ready for use.")
-		return gcp.OptInEnvSet(env.UseNativeImage, gcp.WithBuildPlans(planProvides)), nil
+		envName := env.UseNativeImage
+		if _, ok := os.LookupEnv(env.NativeImage); ok {
+			envName = env.NativeImage
+		}
+		return gcp.OptInEnvSet(envName, gcp.WithBuildPlanEntry(gcp.Provide("graalvm"))), nil
 	}
 
 	return gcp.OptOutEnvNotSet(env.UseNativeImage), nil
@@ -86,10 +85,16 @@ func installGraalVM(ctx *gcp.Context) error {
 
 	// Install graalvm into layer.
 	archiveURL := fmt.Sprintf(graalvmURL, graalvmVersion)
-	command := fmt.Sprintf(
-		"curl --fail --show-error --silent --location %s "+
-			"| tar xz --directory %s --strip-components=1", archiveURL, graalLayer.Path)
-	if _, err := ctx.Exec([]string{"bash", "-c", command}, gcp.WithUserAttribution); err != nil {
+	tmpDir, err := ctx.TempDir("graalvm")
+	if err != nil {
+		return err
+	}
+	defer ctx.RemoveAll(tmpDir)
+	archive := filepath.Join(tmpDir, "graalvm.tar.gz")
+	if err := ctx.Download(archiveURL, archive); err != nil {
+		return err
+	}
+	if _, err := ctx.Exec([]string{"tar", "xzf", archive, "--directory", graalLayer.Path, "--strip-components=1"}, gcp.WithUserAttribution); err != nil {
 		return err
 	}
 
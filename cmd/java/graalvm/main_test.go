@@ -42,6 +42,16 @@ func TestDetect(t *testing.T) {
 			env:  []string{"GOOGLE_JAVA_USE_NATIVE_IMAGE=1"},
 			want: 0,
 		},
+		{
+			name: "native image env var set to true",
+			env:  []string{"GOOGLE_JAVA_NATIVE_IMAGE=true"},
+			want: 0,
+		},
+		{
+			name: "native image env var takes precedence",
+			env:  []string{"GOOGLE_JAVA_NATIVE_IMAGE=false", "GOOGLE_JAVA_USE_NATIVE_IMAGE=true"},
+			want: 100,
+		},
 		{
 			name: "GraalVM env var set to False",
 			env:  []string{"GOOGLE_JAVA_USE_NATIVE_IMAGE=False"},
@@ -23,32 +23,63 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildcommand"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/java"
+	"github.com/buildpacks/libcnb"
 )
 
 const (
-	gradleDistroURL = "https://services.gradle.org/distributions/gradle-%s-bin.zip"
-	gradleLayer     = "gradle"
-	cacheLayer      = "cache"
-	versionKey      = "version"
+	gradleDistroURL    = "https://services.gradle.org/distributions/gradle-%s-bin.zip"
+	gradleLayer        = "gradle"
+	cacheLayer         = "cache"
+	wrapperLayer       = "gradle_wrapper"
+	versionKey         = "version"
+	distributionURLKey = "distribution_url"
+
+	// wrapperProperties is the file that pins the Gradle distribution used by the wrapper.
+	wrapperProperties = "gradle/wrapper/gradle-wrapper.properties"
 )
 
+// gradleProperties are written to the Gradle user home to enable the configuration cache, which
+// skips the configuration phase when the build scripts and their inputs have not changed. Problems
+// are reported as warnings so that builds using incompatible plugins do not fail, and the cache
+// can be disabled with --no-configuration-cache in GOOGLE_GRADLE_ARGS.
+var gradleProperties = strings.Join([]string{
+	"org.gradle.configuration-cache=true",
+	"org.gradle.configuration-cache.problems=warn",
+	// Gradle versions older than 8.1 use the unstable property names.
+	"org.gradle.unsafe.configuration-cache=true",
+	"org.gradle.unsafe.configuration-cache-problems=warn",
+}, "\n") + "\n"
+
+// reproducibleInitScript is written to the init.d directory of the Gradle user home in a
+// reproducible build so that all the archives have fixed timestamps and a stable entry order.
+const reproducibleInitScript = `allprojects {
+    tasks.withType(AbstractArchiveTask).configureEach {
+        preserveFileTimestamps = false
+        reproducibleFileOrder = true
+    }
+}
+`
+
 func main() {
 	gcp.Main(detectFn, buildFn)
 }
 
 func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
-	buildGradleExists, err := ctx.FileExists("build.gradle")
+	// A module of a multi-module project has its own build script.
+	dir := os.Getenv(env.JavaModule)
+	buildGradleExists, err := ctx.FileExists(dir, "build.gradle")
 	if err != nil {
 		return nil, err
 	}
 	if buildGradleExists {
 		return gcp.OptInFileFound("build.gradle"), nil
 	}
-	buildGradleKTSExists, err := ctx.FileExists("build.gradle.kts")
+	buildGradleKTSExists, err := ctx.FileExists(dir, "build.gradle.kts")
 	if err != nil {
 		return nil, err
 	}
@@ -74,16 +105,54 @@ func buildFn(ctx *gcp.Context) error {
 	if err := ctx.RemoveAll(homeGradle); err != nil {
 		return err
 	}
+	if err := ctx.MkdirAll(gradleCachedRepo.Path, 0755); err != nil {
+		return err
+	}
 	if err := ctx.Symlink(gradleCachedRepo.Path, homeGradle); err != nil {
 		return err
 	}
+	if err := ctx.WriteFile(filepath.Join(gradleCachedRepo.Path, "gradle.properties"), []byte(gradleProperties), 0644); err != nil {
+		return err
+	}
+	// The Gradle user home is cached, so the init script of a previous reproducible build must be
+	// removed.
+	initScript := filepath.Join(gradleCachedRepo.Path, "init.d", "reproducible.gradle")
+	if ctx.ReproducibleBuild() {
+		if err := ctx.MkdirAll(filepath.Dir(initScript), 0755); err != nil {
+			return err
+		}
+		if err := ctx.WriteFile(initScript, []byte(reproducibleInitScript), 0644); err != nil {
+			return err
+		}
+	} else if err := ctx.RemoveAll(initScript); err != nil {
+		return err
+	}
 
-	gradle, err := provisionOrDetectGradle(ctx)
+	module, err := java.SelectedModule(ctx, "gradlew")
 	if err != nil {
 		return err
 	}
+	projectDir := "."
+	if module != nil {
+		projectDir = module.ProjectDir
+	}
+
+	gradle, err := provisionOrDetectGradle(ctx, gradleCachedRepo, projectDir)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := buildcommand.Command(); ok {
+		return runBuildCommand(ctx, gradle, module)
+	}
 
-	command := []string{gradle, "clean", "assemble", "-x", "test", "--build-cache"}
+	// Only build the selected module, Gradle also builds the projects it depends on.
+	tasks := []string{"clean", "assemble"}
+	if module != nil && module.GradlePath() != "" {
+		tasks = []string{module.GradlePath() + ":clean", module.GradlePath() + ":assemble"}
+	}
+	command := append([]string{gradle}, tasks...)
+	command = append(command, "-x", "test", "--build-cache")
 
 	if buildArgs := os.Getenv(env.BuildArgs); buildArgs != "" {
 		if strings.Contains(buildArgs, "project-cache-dir") {
@@ -91,12 +160,13 @@ func buildFn(ctx *gcp.Context) error {
 		}
 		command = append(command, buildArgs)
 	}
+	command = append(command, strings.Fields(os.Getenv(env.GradleArgs))...)
 
 	if !ctx.Debug() && !devmode.Enabled(ctx) {
 		command = append(command, "--quiet")
 	}
 
-	if _, err := ctx.Exec(command, gcp.WithUserAttribution); err != nil {
+	if _, err := ctx.Exec(command, gcp.WithWorkDir(filepath.Join(ctx.ApplicationRoot(), projectDir)), gcp.WithUserAttribution); err != nil {
 		return err
 	}
 
@@ -108,12 +178,36 @@ func buildFn(ctx *gcp.Context) error {
 	return nil
 }
 
-func provisionOrDetectGradle(ctx *gcp.Context) (string, error) {
-	gradlewExists, err := ctx.FileExists("gradlew")
+// runBuildCommand runs the user-provided build command in place of `gradle assemble`. HOME is left
+// untouched so that ~/.gradle keeps pointing at the gradle cache layer.
+func runBuildCommand(ctx *gcp.Context, gradle string, module *java.Module) error {
+	outputDir := os.Getenv(env.Buildable)
+	if module != nil {
+		outputDir = module.Dir
+	}
+	cfg := buildcommand.Config{
+		Home:          ctx.HomeDir(),
+		DefaultOutput: filepath.Join(outputDir, "build", "libs", "*.jar"),
+	}
+	if filepath.IsAbs(gradle) {
+		// Gradle was installed into a layer; make it available to the command.
+		cfg.Env = append(cfg.Env, fmt.Sprintf("PATH=%s%c%s", filepath.Dir(gradle), os.PathListSeparator, os.Getenv("PATH")))
+	}
+	_, err := buildcommand.Run(ctx, cfg)
+	return err
+}
+
+// provisionOrDetectGradle returns the command that runs Gradle in the given project directory,
+// relative to the application root.
+func provisionOrDetectGradle(ctx *gcp.Context, gradleCachedRepo *libcnb.Layer, projectDir string) (string, error) {
+	gradlewExists, err := ctx.FileExists(projectDir, "gradlew")
 	if err != nil {
 		return "", err
 	}
 	if gradlewExists {
+		if err := cacheWrapperDistribution(ctx, gradleCachedRepo, projectDir); err != nil {
+			return "", err
+		}
 		return "./gradlew", nil
 	}
 	installed, err := gradleInstalled(ctx)
@@ -130,6 +224,60 @@ func provisionOrDetectGradle(ctx *gcp.Context) (string, error) {
 	return gradle, nil
 }
 
+// cacheWrapperDistribution keeps the Gradle distributions downloaded by the wrapper in a dedicated
+// cached layer. The layer is kept while the distribution pinned in gradle-wrapper.properties does
+// not change, independently of the expiration of the dependency cache.
+func cacheWrapperDistribution(ctx *gcp.Context, gradleCachedRepo *libcnb.Layer, projectDir string) error {
+	wl, err := ctx.Layer(wrapperLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayerIfDevMode)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", wrapperLayer, err)
+	}
+	distributionURL, err := wrapperDistributionURL(ctx, projectDir)
+	if err != nil {
+		return err
+	}
+	if distributionURL != "" && distributionURL == ctx.GetMetadata(wl, distributionURLKey) {
+		ctx.CacheHit(wrapperLayer)
+	} else {
+		ctx.CacheMiss(wrapperLayer)
+		if err := ctx.ClearLayer(wl); err != nil {
+			return fmt.Errorf("clearing layer %q: %w", wl.Name, err)
+		}
+		ctx.SetMetadata(wl, distributionURLKey, distributionURL)
+	}
+	if err := ctx.MkdirAll(wl.Path, 0755); err != nil {
+		return err
+	}
+	// ~/.gradle links to the cache layer, the wrapper stores its distributions in ~/.gradle/wrapper.
+	cacheWrapper := filepath.Join(gradleCachedRepo.Path, "wrapper")
+	if err := ctx.RemoveAll(cacheWrapper); err != nil {
+		return err
+	}
+	return ctx.Symlink(wl.Path, cacheWrapper)
+}
+
+// wrapperDistributionURL returns the distributionUrl of the gradle-wrapper.properties of the
+// project in projectDir, or an empty string if the file or the property does not exist.
+func wrapperDistributionURL(ctx *gcp.Context, projectDir string) (string, error) {
+	path := filepath.Join(ctx.ApplicationRoot(), projectDir, wrapperProperties)
+	exists, err := ctx.FileExists(path)
+	if err != nil || !exists {
+		return "", err
+	}
+	content, err := ctx.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		kv := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(kv) == 2 && strings.TrimSpace(kv[0]) == "distributionUrl" {
+			// Colons are escaped in properties files, e.g. https\://services.gradle.org.
+			return strings.ReplaceAll(strings.TrimSpace(kv[1]), "\\:", ":"), nil
+		}
+	}
+	return "", nil
+}
+
 func gradleInstalled(ctx *gcp.Context) (bool, error) {
 	result, err := ctx.Exec([]string{"bash", "-c", "command -v gradle || true"})
 	if err != nil {
@@ -176,8 +324,7 @@ func installGradle(ctx *gcp.Context) (string, error) {
 	gradleZip := filepath.Join(tmpDir, "gradle.zip")
 	defer ctx.RemoveAll(gradleZip)
 
-	curl := fmt.Sprintf("curl --fail --show-error --silent --location --retry 3 %s --output %s", downloadURL, gradleZip)
-	if _, err := ctx.Exec([]string{"bash", "-c", curl}, gcp.WithUserAttribution); err != nil {
+	if err := ctx.Download(downloadURL, gradleZip); err != nil {
 		return "", err
 	}
 
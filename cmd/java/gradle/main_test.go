@@ -15,15 +15,20 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 )
 
 func TestDetect(t *testing.T) {
 	testCases := []struct {
 		name  string
 		files map[string]string
+		env   []string
 		want  int
 	}{
 		{
@@ -40,6 +45,15 @@ func TestDetect(t *testing.T) {
 			},
 			want: 0,
 		},
+		{
+			name: "module build.gradle",
+			files: map[string]string{
+				"settings.gradle":           "",
+				"services/api/build.gradle": "",
+			},
+			env:  []string{"GOOGLE_JAVA_MODULE=services/api"},
+			want: 0,
+		},
 		{
 			name:  "no files",
 			files: map[string]string{},
@@ -48,7 +62,136 @@ func TestDetect(t *testing.T) {
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			buildpacktest.TestDetect(t, detectFn, tc.name, tc.files, []string{}, tc.want)
+			buildpacktest.TestDetect(t, detectFn, tc.name, tc.files, tc.env, tc.want)
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name         string
+		files        map[string]string
+		envs         []string
+		wantExitCode int
+		wantCommands []string
+	}{
+		{
+			name: "gradle wrapper",
+			files: map[string]string{
+				"build.gradle": "",
+				"gradlew":      "",
+			},
+			wantCommands: []string{"./gradlew clean assemble -x test --build-cache"},
+		},
+		{
+			name: "gradle args",
+			files: map[string]string{
+				"build.gradle": "",
+				"gradlew":      "",
+			},
+			envs:         []string{"GOOGLE_GRADLE_ARGS=--parallel  -Pprofile=prod"},
+			wantCommands: []string{"./gradlew clean assemble -x test --build-cache --parallel -Pprofile=prod"},
+		},
+		{
+			name: "module",
+			files: map[string]string{
+				"settings.gradle":           "",
+				"gradlew":                   "",
+				"services/api/build.gradle": "",
+			},
+			envs:         []string{"GOOGLE_JAVA_MODULE=services/api"},
+			wantCommands: []string{"./gradlew :services:api:clean :services:api:assemble -x test --build-cache"},
+		},
+		{
+			name: "build command with buildable",
+			files: map[string]string{
+				"settings.gradle":        "",
+				"gradlew":                "",
+				"api/build.gradle":       "",
+				"api/build/libs/app.jar": "",
+			},
+			envs:         []string{"GOOGLE_BUILD_COMMAND=./gradlew :api:bootJar", "GOOGLE_BUILDABLE=api"},
+			wantCommands: []string{"bash -c ./gradlew :api:bootJar"},
+		},
+		{
+			name: "build command output outside buildable",
+			files: map[string]string{
+				"settings.gradle":    "",
+				"gradlew":            "",
+				"api/build.gradle":   "",
+				"build/libs/app.jar": "",
+			},
+			envs:         []string{"GOOGLE_BUILD_COMMAND=./gradlew bootJar", "GOOGLE_BUILDABLE=api"},
+			wantExitCode: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []buildpacktest.Option{
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithFiles(tc.files),
+				// ~/.gradle is replaced with a symlink to the cache layer.
+				buildpacktest.WithEnvs(append(tc.envs, "HOME="+t.TempDir())...),
+				buildpacktest.WithExecMocks(mockprocess.New("./gradlew")),
+			}
+
+			result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, result: %#v", err, result)
+			}
+
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not, build output: %s", cmd, result.Output)
+				}
+			}
+		})
+	}
+}
+
+func TestWrapperDistributionURL(t *testing.T) {
+	testCases := []struct {
+		name       string
+		properties string
+		want       string
+	}{
+		{
+			name: "no wrapper properties",
+		},
+		{
+			name:       "escaped distribution url",
+			properties: "distributionBase=GRADLE_USER_HOME\ndistributionUrl=https\\://services.gradle.org/distributions/gradle-8.2-bin.zip\n",
+			want:       "https://services.gradle.org/distributions/gradle-8.2-bin.zip",
+		},
+		{
+			name:       "no distribution url",
+			properties: "distributionBase=GRADLE_USER_HOME\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tc.properties != "" {
+				path := filepath.Join(dir, wrapperProperties)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("creating %s: %v", filepath.Dir(path), err)
+				}
+				if err := os.WriteFile(path, []byte(tc.properties), 0644); err != nil {
+					t.Fatalf("writing %s: %v", path, err)
+				}
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+
+			got, err := wrapperDistributionURL(ctx, ".")
+			if err != nil {
+				t.Fatalf("wrapperDistributionURL() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("wrapperDistributionURL() = %q, want %q", got, tc.want)
+			}
 		})
 	}
 }
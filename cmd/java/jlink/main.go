@@ -0,0 +1,255 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements java/jlink buildpack.
+// The jlink buildpack creates a custom Java runtime that only contains the modules the application requires.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/java"
+)
+
+const (
+	jreLayer = "jre"
+	// modulesKey is the layer metadata key of the JDK version and modules of the runtime.
+	modulesKey = "modules"
+	cacheTag   = "jlink runtime"
+	// allModules links all the modules of the JDK when the application cannot be analyzed.
+	allModules = "ALL-MODULE-PATH"
+	// springBootClassesKey is the manifest entry of Spring Boot executable jars that holds the
+	// application classes, its dependencies are nested jars in BOOT-INF/lib.
+	springBootClassesKey = "Spring-Boot-Classes"
+)
+
+var (
+	// defaultModules are service providers that jdeps cannot find, they are added if the JDK has
+	// them. jdk.crypto.ec provides the elliptic curves used by most TLS connections.
+	defaultModules = []string{"jdk.crypto.ec"}
+	// agentModules are required by the Java agents of the observability buildpacks.
+	agentModules = []string{"java.instrument", "jdk.management"}
+)
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	enabled, err := java.JlinkEnabled()
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return gcp.OptOutEnvNotSet(env.JavaJlink), nil
+	}
+	return gcp.OptInEnvSet(env.JavaJlink), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	version, available, err := jdkModules(ctx)
+	if err != nil {
+		return err
+	}
+	modules, err := requiredModules(ctx, featureVersion(version))
+	if err != nil {
+		return err
+	}
+	if modules == nil {
+		modules = []string{allModules}
+	} else {
+		extra := defaultModules
+		if agentsEnabled() {
+			extra = append(append([]string{}, defaultModules...), agentModules...)
+		}
+		for _, m := range extra {
+			if available[m] {
+				modules = append(modules, m)
+			}
+		}
+	}
+	for _, m := range strings.Split(os.Getenv(env.JavaJlinkAddModules), ",") {
+		if m = strings.TrimSpace(m); m == "" {
+			continue
+		}
+		if !available[m] {
+			return gcp.UserErrorf("module %q of %s is not in the JDK %s", m, env.JavaJlinkAddModules, version)
+		}
+		modules = append(modules, m)
+	}
+	modules = sortedUnique(modules)
+
+	l, err := ctx.Layer(jreLayer, gcp.LaunchLayer, gcp.CacheLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", jreLayer, err)
+	}
+	key := fmt.Sprintf("%s %s", version, strings.Join(modules, ","))
+	if ctx.GetMetadata(l, modulesKey) == key {
+		ctx.CacheHit(cacheTag)
+		ctx.Logf("Using the cached Java runtime with modules %s.", strings.Join(modules, ","))
+		return nil
+	}
+	ctx.CacheMiss(cacheTag)
+	if err := ctx.ClearLayer(l); err != nil {
+		return fmt.Errorf("clearing layer %q: %w", l.Name, err)
+	}
+	// jlink refuses to write into an existing directory.
+	if err := ctx.RemoveAll(l.Path); err != nil {
+		return err
+	}
+	ctx.Logf("Creating a Java runtime with modules %s.", strings.Join(modules, ","))
+	cmd := []string{
+		"jlink",
+		"--add-modules", strings.Join(modules, ","),
+		"--strip-debug",
+		"--no-man-pages",
+		"--no-header-files",
+		"--compress=2",
+		"--output", l.Path,
+	}
+	if _, err := ctx.Exec(cmd, gcp.WithUserAttribution); err != nil {
+		return err
+	}
+	ctx.SetMetadata(l, modulesKey, key)
+	return nil
+}
+
+// jdkModules returns the version of the JDK on the PATH and the set of its modules.
+func jdkModules(ctx *gcp.Context) (string, map[string]bool, error) {
+	result, err := ctx.Exec([]string{"java", "--list-modules"})
+	if err != nil {
+		return "", nil, err
+	}
+	var version string
+	modules := map[string]bool{}
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		// Each line is a module and its version, e.g. "java.base@17.0.9".
+		parts := strings.SplitN(strings.TrimSpace(line), "@", 2)
+		if parts[0] == "" {
+			continue
+		}
+		modules[parts[0]] = true
+		if parts[0] == "java.base" && len(parts) == 2 {
+			version = parts[1]
+		}
+	}
+	if version == "" {
+		return "", nil, gcp.InternalErrorf("finding the version of java.base in %q", result.Stdout)
+	}
+	return version, modules, nil
+}
+
+// featureVersion returns the feature release of a JDK version, e.g. "17" for "17.0.9".
+func featureVersion(version string) string {
+	return strings.FieldsFunc(version, func(r rune) bool { return r == '.' || r == '+' || r == '-' })[0]
+}
+
+// requiredModules returns the modules jdeps finds the application jar and its dependencies
+// require, or nil if there is no jar to analyze.
+func requiredModules(ctx *gcp.Context, feature string) ([]string, error) {
+	// Functions run on the Functions Framework invoker, which is not part of the application jar.
+	if os.Getenv(env.FunctionTarget) != "" {
+		ctx.Logf("Linking all the modules of the JDK for the Functions Framework.")
+		return nil, nil
+	}
+	jar, err := java.ExecutableJar(ctx)
+	if err != nil {
+		ctx.Warnf("Linking all the modules of the JDK, failed to find the application jar: %v", err)
+		return nil, nil
+	}
+	inputs, err := jdepsInputs(ctx, jar)
+	if err != nil {
+		return nil, err
+	}
+	cmd := append([]string{"jdeps", "--ignore-missing-deps", "--print-module-deps", "-q", "--multi-release", feature}, inputs...)
+	result, err := ctx.Exec(cmd, gcp.WithUserAttribution)
+	if err != nil {
+		return nil, err
+	}
+	modules := []string{"java.base"}
+	for _, m := range strings.Split(strings.TrimSpace(result.Stdout), ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			modules = append(modules, m)
+		}
+	}
+	return modules, nil
+}
+
+// jdepsInputs returns the classes and jars that make up the application.
+func jdepsInputs(ctx *gcp.Context, jar string) ([]string, error) {
+	classes, err := java.FindManifestValueFromJar(jar, springBootClassesKey)
+	if err != nil {
+		return nil, err
+	}
+	if classes == "" {
+		classPath, err := java.ClassPathFromJar(jar)
+		if err != nil {
+			return nil, err
+		}
+		inputs := []string{jar}
+		for _, p := range classPath {
+			exists, err := ctx.FileExists(p)
+			if err != nil {
+				return nil, err
+			}
+			if exists {
+				inputs = append(inputs, p)
+			}
+		}
+		return inputs, nil
+	}
+
+	// jdeps does not read nested jars, so Spring Boot jars are extracted.
+	dir, err := ctx.TempDir("jdeps")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := ctx.Exec([]string{"unzip", "-q", jar, "-d", dir}, gcp.WithUserAttribution); err != nil {
+		return nil, err
+	}
+	libs, err := ctx.Glob(filepath.Join(dir, "BOOT-INF", "lib", "*.jar"))
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{filepath.Join(dir, classes)}, libs...), nil
+}
+
+// agentsEnabled returns true if a buildpack may add a Java agent to the application.
+func agentsEnabled() bool {
+	for _, e := range []string{env.InstallObservabilityAgents, env.OTelEnabled} {
+		if enabled, err := env.IsPresentAndTrue(e); err == nil && enabled {
+			return true
+		}
+	}
+	return os.Getenv(env.APMAgent) != ""
+}
+
+func sortedUnique(modules []string) []string {
+	seen := map[string]bool{}
+	var result []string
+	for _, m := range modules {
+		if !seen[m] {
+			seen[m] = true
+			result = append(result, m)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,188 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+)
+
+const listModules = `java.base@17.0.9
+java.instrument@17.0.9
+java.logging@17.0.9
+java.naming@17.0.9
+java.sql@17.0.9
+jdk.crypto.ec@17.0.9
+jdk.management@17.0.9
+`
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name string
+		envs []string
+		want int
+	}{
+		{
+			name: "enabled",
+			envs: []string{"GOOGLE_JAVA_JLINK=true"},
+			want: 0,
+		},
+		{
+			name: "disabled",
+			envs: []string{"GOOGLE_JAVA_JLINK=false"},
+			want: 100,
+		},
+		{
+			name: "not set",
+			want: 100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buildpacktest.TestDetect(t, detectFn, tc.name, map[string]string{}, tc.envs, tc.want)
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name            string
+		envs            []string
+		files           map[string]string
+		wantExitCode    int
+		wantCommands    []string
+		skippedCommands []string
+	}{
+		{
+			name:         "executable jar",
+			files:        map[string]string{"target/app.jar": jar(t, "Main-Class: com.example.Main\n")},
+			wantCommands: []string{"jdeps .* --multi-release 17 .*target/app.jar", "jlink --add-modules java.base,java.sql,jdk.crypto.ec "},
+		},
+		{
+			name:  "jar with class path",
+			files: map[string]string{"target/app.jar": jar(t, "Main-Class: com.example.Main\nClass-Path: lib/dep.jar lib/missing.jar\n"), "target/lib/dep.jar": ""},
+			wantCommands: []string{
+				"jdeps .*target/app.jar .*target/lib/dep.jar",
+				"jlink --add-modules java.base,java.sql,jdk.crypto.ec ",
+			},
+		},
+		{
+			name:         "spring boot jar",
+			files:        map[string]string{"target/app.jar": jar(t, "Main-Class: org.springframework.boot.loader.JarLauncher\nSpring-Boot-Classes: BOOT-INF/classes/\n")},
+			wantCommands: []string{"unzip -q .*target/app.jar", "jdeps .*BOOT-INF/classes"},
+		},
+		{
+			name:            "function",
+			envs:            []string{"GOOGLE_FUNCTION_TARGET=com.example.Function"},
+			files:           map[string]string{"target/app.jar": jar(t, "Main-Class: com.example.Main\n")},
+			wantCommands:    []string{"jlink --add-modules ALL-MODULE-PATH "},
+			skippedCommands: []string{"jdeps"},
+		},
+		{
+			name:            "no jar",
+			wantCommands:    []string{"jlink --add-modules ALL-MODULE-PATH "},
+			skippedCommands: []string{"jdeps"},
+		},
+		{
+			name:         "additional modules",
+			envs:         []string{"GOOGLE_JAVA_JLINK_ADD_MODULES=java.naming, java.logging"},
+			files:        map[string]string{"target/app.jar": jar(t, "Main-Class: com.example.Main\n")},
+			wantCommands: []string{"jlink --add-modules java.base,java.logging,java.naming,java.sql,jdk.crypto.ec "},
+		},
+		{
+			name:            "unknown additional module",
+			envs:            []string{"GOOGLE_JAVA_JLINK_ADD_MODULES=java.desktop"},
+			files:           map[string]string{"target/app.jar": jar(t, "Main-Class: com.example.Main\n")},
+			wantExitCode:    1,
+			skippedCommands: []string{"jlink"},
+		},
+		{
+			name:         "java agents",
+			envs:         []string{"GOOGLE_OTEL_ENABLED=true"},
+			files:        map[string]string{"target/app.jar": jar(t, "Main-Class: com.example.Main\n")},
+			wantCommands: []string{"jlink --add-modules java.base,java.instrument,java.sql,jdk.crypto.ec,jdk.management "},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []buildpacktest.Option{
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithEnvs(append(tc.envs, "GOOGLE_JAVA_JLINK=true")...),
+				buildpacktest.WithFiles(tc.files),
+				buildpacktest.WithExecMocks(
+					mockprocess.New("java --list-modules", mockprocess.WithStdout(listModules)),
+					mockprocess.New("jdeps", mockprocess.WithStdout("java.base,java.sql\n")),
+					mockprocess.New("unzip"),
+					mockprocess.New("jlink"),
+				),
+			}
+
+			result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, result: %#v", err, result)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not", cmd)
+				}
+			}
+			for _, cmd := range tc.skippedCommands {
+				if result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to not be executed, but it was", cmd)
+				}
+			}
+		})
+	}
+}
+
+func TestFeatureVersion(t *testing.T) {
+	testCases := map[string]string{
+		"17.0.9":    "17",
+		"21":        "21",
+		"21+35":     "21",
+		"22-ea":     "22",
+		"11.0.21.1": "11",
+	}
+	for version, want := range testCases {
+		if got := featureVersion(version); got != want {
+			t.Errorf("featureVersion(%q) = %q, want %q", version, got, want)
+		}
+	}
+}
+
+// jar returns the content of a jar with the given manifest.
+func jar(t *testing.T, manifest string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	f, err := w.Create("META-INF/MANIFEST.MF")
+	if err != nil {
+		t.Fatalf("creating manifest: %v", err)
+	}
+	if _, err := f.Write([]byte(manifest)); err != nil {
+		t.Fatalf("writing manifest: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("closing jar: %v", err)
+	}
+	return buf.String()
+}
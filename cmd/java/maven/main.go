@@ -24,11 +24,15 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildcommand"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/java"
+	"github.com/buildpacks/libcnb"
 )
 
 const (
@@ -38,6 +42,13 @@ const (
 	mavenLayer   = "maven"
 	m2Layer      = "m2"
 	versionKey   = "version"
+	// dependenciesKey is the m2 layer metadata key of the hash of the inputs that determine the
+	// dependencies downloaded into the local repository.
+	dependenciesKey = "dependencies"
+
+	// cacheFormatVersion identifies the layout of the cached m2 layer. Bump it whenever the way the
+	// layer is populated changes.
+	cacheFormatVersion = "v1"
 )
 
 func main() {
@@ -87,19 +98,48 @@ func buildFn(ctx *gcp.Context) error {
 		return err
 	}
 
-	mvn, err := provisionOrDetectMaven(ctx)
+	module, err := java.SelectedModule(ctx, "mvnw")
 	if err != nil {
 		return err
 	}
+	if module != nil && os.Getenv(env.Buildable) != "" {
+		return gcp.UserErrorf("%s and %s cannot be used together", env.JavaModule, env.Buildable)
+	}
+	projectDir := "."
+	if module != nil {
+		projectDir = module.ProjectDir
+	}
 
-	command := []string{mvn, "clean", "package", "--batch-mode", "-DskipTests", "-Dhttp.keepAlive=false"}
+	mvn, err := provisionOrDetectMaven(ctx, projectDir)
+	if err != nil {
+		return err
+	}
 
-	pomPath, err := pomFilePath(ctx)
+	settingsArgs, err := java.MavenSettingsArgs(ctx, projectDir)
 	if err != nil {
 		return err
 	}
-	if pomPath != "" {
-		command = append(command, fmt.Sprintf("-f=%s", pomPath))
+
+	if _, ok := buildcommand.Command(); ok {
+		return runBuildCommand(ctx, mvn, module, settingsArgs)
+	}
+
+	command := append([]string{mvn, "clean", "package", "--batch-mode", "-DskipTests", "-Dhttp.keepAlive=false"}, settingsArgs...)
+
+	if module != nil {
+		// Build the module and the modules it depends on from the project directory, so that
+		// dependencies on sibling modules are resolved without installing them.
+		if path := module.Path(); path != "." {
+			command = append(command, "--projects", path, "--also-make")
+		}
+	} else {
+		pomPath, err := pomFilePath(ctx)
+		if err != nil {
+			return err
+		}
+		if pomPath != "" {
+			command = append(command, fmt.Sprintf("-f=%s", pomPath))
+		}
 	}
 
 	if buildArgs := os.Getenv(env.BuildArgs); buildArgs != "" {
@@ -109,13 +149,37 @@ func buildFn(ctx *gcp.Context) error {
 		command = append(command, strings.Fields(buildArgs)...)
 	}
 
+	if ctx.ReproducibleBuild() {
+		// Maven plugins use this property in place of the build time in the archives they create.
+		command = append(command, "-Dproject.build.outputTimestamp="+ctx.SourceDateEpoch().Format(time.RFC3339))
+	}
+
+	// The local repository already contains all the dependencies and plugins if it was populated
+	// by a build of the same pom.xml files, so Maven does not need to check the remote repositories.
+	warm, depsKey, err := checkDependencies(ctx, m2CachedRepo, mvn)
+	if err != nil {
+		return err
+	}
+	if warm && !devmode.Enabled(ctx) {
+		ctx.Logf("Maven dependencies are cached, building offline.")
+		command = append(command, "--offline")
+	}
+
 	if !ctx.Debug() && !devmode.Enabled(ctx) {
 		command = append(command, "--quiet")
 	}
 
-	if _, err := ctx.Exec(command, gcp.WithStdoutTail, gcp.WithUserAttribution); err != nil {
+	if _, err := ctx.Exec(command, gcp.WithWorkDir(filepath.Join(ctx.ApplicationRoot(), projectDir)), gcp.WithStdoutTail, gcp.WithStreamingOutput, gcp.WithUserAttribution); err != nil {
+		return err
+	}
+	pruned, err := cache.Prune(ctx, m2CachedRepo, cache.ByDirectory)
+	if err != nil {
 		return err
 	}
+	// The next build cannot run offline if dependencies were pruned from the repository.
+	if pruned == 0 {
+		ctx.SetMetadata(m2CachedRepo, dependenciesKey, depsKey)
+	}
 
 	// Store the build steps in a script to be run on each file change.
 	if devmode.Enabled(ctx) {
@@ -125,14 +189,59 @@ func buildFn(ctx *gcp.Context) error {
 	return nil
 }
 
-func provisionOrDetectMaven(ctx *gcp.Context) (string, error) {
-	mvnwExists, err := ctx.FileExists("mvnw")
+// checkDependencies reports whether the m2 layer was populated by a successful build of the same
+// pom.xml files and build arguments. Changed inputs do not clear the layer: Maven reuses the
+// artifacts that are still needed and downloads the missing ones. The current key is returned so
+// that it can be stored once the build succeeds.
+func checkDependencies(ctx *gcp.Context, m2CachedRepo *libcnb.Layer, mvn string) (bool, string, error) {
+	hit, key, err := cache.CheckCache(ctx, m2CachedRepo, cache.WithFormatVersion(cacheFormatVersion), dependenciesKey,
+		cache.WithStrings(mvn),
+		cache.WithEnvVars(env.Buildable, env.JavaModule, env.BuildArgs),
+		cache.WithGlob(ctx.ApplicationRoot(), "pom.xml", ".mvn/extensions.xml", "!target"))
+	if err != nil {
+		return false, "", fmt.Errorf("computing the dependencies cache key: %w", err)
+	}
+	if hit {
+		ctx.CacheHit(m2Layer)
+	} else {
+		ctx.CacheMiss(m2Layer)
+	}
+	return hit, key, nil
+}
+
+// runBuildCommand runs the user-provided build command in place of `mvn package`. HOME is left
+// untouched so that ~/.m2 keeps pointing at the m2 cache layer. The settings arguments are passed
+// in MAVEN_ARGS, which is read by Maven 3.9+.
+func runBuildCommand(ctx *gcp.Context, mvn string, module *java.Module, settingsArgs []string) error {
+	outputDir := os.Getenv(env.Buildable)
+	if module != nil {
+		outputDir = module.Dir
+	}
+	cfg := buildcommand.Config{
+		Home:          ctx.HomeDir(),
+		DefaultOutput: filepath.Join(outputDir, "target", "*.jar"),
+	}
+	if filepath.IsAbs(mvn) {
+		// Maven was installed into a layer; make it available to the command.
+		cfg.Env = append(cfg.Env, fmt.Sprintf("PATH=%s%c%s", filepath.Dir(mvn), os.PathListSeparator, os.Getenv("PATH")))
+	}
+	if len(settingsArgs) > 0 {
+		cfg.Env = append(cfg.Env, "MAVEN_ARGS="+strings.Join(settingsArgs, " "))
+	}
+	_, err := buildcommand.Run(ctx, cfg)
+	return err
+}
+
+// provisionOrDetectMaven returns the command that runs Maven in the given project directory,
+// relative to the application root.
+func provisionOrDetectMaven(ctx *gcp.Context, projectDir string) (string, error) {
+	mvnwExists, err := ctx.FileExists(projectDir, "mvnw")
 	if err != nil {
 		return "", err
 	}
 	if mvnwExists {
 		// With CRLF endings, the "\r" gets seen as part of the shebang target, which doesn't exist.
-		if err := ensureUnixLineEndings(ctx, "mvnw"); err != nil {
+		if err := ensureUnixLineEndings(ctx, projectDir, "mvnw"); err != nil {
 			return "", fmt.Errorf("ensuring unix newline characters: %w", err)
 		}
 		return "./mvnw", nil
@@ -217,8 +326,16 @@ func installMaven(ctx *gcp.Context) (string, error) {
 	if code != http.StatusOK {
 		return "", gcp.UserErrorf("Maven version %s does not exist at %s (status %d).", mavenVersion, archiveURL, code)
 	}
-	command := fmt.Sprintf("curl --fail --show-error --silent --location --retry 3 %s | tar xz --directory %s --strip-components=1", archiveURL, mvnl.Path)
-	if _, err := ctx.Exec([]string{"bash", "-c", command}, gcp.WithUserAttribution); err != nil {
+	tmpDir, err := ctx.TempDir("maven")
+	if err != nil {
+		return "", err
+	}
+	defer ctx.RemoveAll(tmpDir)
+	archive := filepath.Join(tmpDir, "maven.tar.gz")
+	if err := ctx.Download(archiveURL, archive); err != nil {
+		return "", err
+	}
+	if _, err := ctx.Exec([]string{"tar", "xzf", archive, "--directory", mvnl.Path, "--strip-components=1"}, gcp.WithUserAttribution); err != nil {
 		return "", err
 	}
 
@@ -251,8 +368,11 @@ func ensureUnixLineEndings(ctx *gcp.Context, file ...string) error {
 }
 
 func pomFilePath(ctx *gcp.Context) (string, error) {
-	buildable := os.Getenv(env.Buildable)
-	pomPath := filepath.Join(buildable, "pom.xml")
+	dir := os.Getenv(env.Buildable)
+	if module := os.Getenv(env.JavaModule); module != "" {
+		dir = module
+	}
+	pomPath := filepath.Join(dir, "pom.xml")
 	pomExists, err := ctx.FileExists(pomPath)
 	if err != nil {
 		return "", err
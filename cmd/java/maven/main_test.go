@@ -17,11 +17,16 @@ package main
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/cacheformat"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
 )
 
 func TestDetect(t *testing.T) {
@@ -50,6 +55,15 @@ func TestDetect(t *testing.T) {
 			files: map[string]string{},
 			want:  100,
 		},
+		{
+			name: "use GOOGLE_JAVA_MODULE",
+			files: map[string]string{
+				"backend/mvnw":                 "",
+				"backend/services/api/pom.xml": "",
+			},
+			env:  []string{"GOOGLE_JAVA_MODULE=backend/services/api"},
+			want: 0,
+		},
 		{
 			name: "use GOOGLE_BUILDABLE",
 			files: map[string]string{
@@ -66,6 +80,78 @@ func TestDetect(t *testing.T) {
 	}
 }
 
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name         string
+		files        map[string]string
+		envs         []string
+		wantExitCode int
+		wantCommands []string
+	}{
+		{
+			name: "maven wrapper",
+			files: map[string]string{
+				"mvnw":    "",
+				"pom.xml": "",
+			},
+			wantCommands: []string{"./mvnw clean package --batch-mode -DskipTests -Dhttp.keepAlive=false -f=pom.xml"},
+		},
+		{
+			name: "module",
+			files: map[string]string{
+				"backend/mvnw":                 "",
+				"backend/pom.xml":              "",
+				"backend/services/api/pom.xml": "",
+			},
+			envs:         []string{"GOOGLE_JAVA_MODULE=backend/services/api"},
+			wantCommands: []string{"./mvnw clean package --batch-mode -DskipTests -Dhttp.keepAlive=false --projects services/api --also-make"},
+		},
+		{
+			name: "reproducible build",
+			files: map[string]string{
+				"mvnw":    "",
+				"pom.xml": "",
+			},
+			envs:         []string{"GOOGLE_REPRODUCIBLE_BUILD=true", "SOURCE_DATE_EPOCH=1700000000"},
+			wantCommands: []string{"./mvnw clean package --batch-mode -DskipTests -Dhttp.keepAlive=false -f=pom.xml -Dproject.build.outputTimestamp=2023-11-14T22:13:20Z"},
+		},
+		{
+			name: "module with buildable",
+			files: map[string]string{
+				"pom.xml":     "",
+				"api/pom.xml": "",
+			},
+			envs:         []string{"GOOGLE_JAVA_MODULE=api", "GOOGLE_BUILDABLE=api"},
+			wantExitCode: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []buildpacktest.Option{
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithFiles(tc.files),
+				// ~/.m2 is replaced with a symlink to the m2 layer.
+				buildpacktest.WithEnvs(append(tc.envs, "HOME="+t.TempDir())...),
+				buildpacktest.WithExecMocks(mockprocess.New("./mvnw")),
+			}
+
+			result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, result: %#v", err, result)
+			}
+
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not, build output: %s", cmd, result.Output)
+				}
+			}
+		})
+	}
+}
+
 func TestCrLfRewrite(t *testing.T) {
 	testCases := []struct {
 		name          string
@@ -108,7 +194,7 @@ func TestCrLfRewrite(t *testing.T) {
 			}
 
 			if string(newContent) != tc.expectContent {
-				t.Fatal("Unexpected content '%s', want '%s'",
+				t.Fatalf("Unexpected content '%s', want '%s'",
 					strconv.QuoteToASCII(string(newContent)),
 					strconv.QuoteToASCII(tc.expectContent))
 			}
@@ -116,3 +202,47 @@ func TestCrLfRewrite(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckDependencies(t *testing.T) {
+	appDir := t.TempDir()
+	writePom := func(content string) {
+		t.Helper()
+		if err := ioutil.WriteFile(filepath.Join(appDir, "pom.xml"), []byte(content), 0644); err != nil {
+			t.Fatalf("writing pom.xml: %v", err)
+		}
+	}
+	ctx := gcp.NewContext(
+		gcp.WithApplicationRoot(appDir),
+		gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}),
+	)
+	l, err := ctx.Layer(m2Layer)
+	if err != nil {
+		t.Fatalf("creating layer: %v", err)
+	}
+
+	writePom("<project><artifactId>a</artifactId></project>")
+	warm, key, err := checkDependencies(ctx, l, "mvn")
+	if err != nil {
+		t.Fatalf("checkDependencies() got error: %v", err)
+	}
+	if warm {
+		t.Errorf("checkDependencies() of an empty layer = true, want false")
+	}
+	ctx.SetMetadata(l, dependenciesKey, key)
+
+	if warm, _, err = checkDependencies(ctx, l, "mvn"); err != nil || !warm {
+		t.Errorf("checkDependencies() with unchanged pom.xml = %t, %v, want true", warm, err)
+	}
+	if warm, _, err = checkDependencies(ctx, l, "./mvnw"); err != nil || warm {
+		t.Errorf("checkDependencies() with a different mvn = %t, %v, want false", warm, err)
+	}
+
+	writePom("<project><artifactId>b</artifactId></project>")
+	if warm, _, err = checkDependencies(ctx, l, "mvn"); err != nil || warm {
+		t.Errorf("checkDependencies() with changed pom.xml = %t, %v, want false", warm, err)
+	}
+}
+
+func TestCacheFormatVersion(t *testing.T) {
+	cacheformat.Check(t, "testdata/cache_format.golden", cache.WithFormatVersion(cacheFormatVersion), "main.go")
+}
@@ -25,24 +25,19 @@ import (
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/java"
-	"github.com/buildpacks/libcnb"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runimage"
 )
 
 const (
 	invokerMain = "com.google.cloud.functions.invoker.runner.Invoker"
 )
 
-var (
-	requiresGraalvm = []libcnb.BuildPlanRequire{{Name: "graalvm"}}
-	planRequires    = libcnb.BuildPlan{Requires: requiresGraalvm}
-)
-
 func main() {
 	gcp.Main(detectFn, buildFn)
 }
 
 func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
-	return gcp.OptInAlways(gcp.WithBuildPlans(planRequires)), nil
+	return gcp.OptInAlways(gcp.WithBuildPlanEntry(gcp.Require("graalvm", nil))), nil
 }
 
 func buildFn(ctx *gcp.Context) error {
@@ -50,6 +45,19 @@ func buildFn(ctx *gcp.Context) error {
 	if err != nil {
 		return err
 	}
+	minRunImage, err := runimage.IsMin()
+	if err != nil {
+		return err
+	}
+	if minRunImage {
+		exe := entrypoint[0]
+		if !filepath.IsAbs(exe) {
+			exe = filepath.Join(ctx.ApplicationRoot(), exe)
+		}
+		if err := runimage.CheckDynamicDeps(exe); err != nil {
+			return err
+		}
+	}
 
 	ctx.AddWebProcess(entrypoint)
 	return nil
@@ -74,6 +82,11 @@ func createImage(ctx *gcp.Context) ([]string, error) {
 		return buildMaven(ctx, buildProfile)
 	}
 
+	// Quarkus and Micronaut build native executables with their own Maven plugin.
+	if args, ok := frameworkNativeArgs(ctx, pom); ok {
+		return buildMaven(ctx, "", args...)
+	}
+
 	// The presence of the `spring-boot-maven-plugin` may not always guarantee that
 	// the project will generate a Spring-Boot fat JAR. In the case where a Spring
 	// Boot fat JAR is not found, we fall through to the default mode of building a
@@ -134,16 +147,21 @@ func buildCommandLine(ctx *gcp.Context, buildArgs []string) ([]string, error) {
 }
 
 // buildMaven runs the Maven native-image build and returns the image entrypoint.
-func buildMaven(ctx *gcp.Context, buildProfile string) ([]string, error) {
+func buildMaven(ctx *gcp.Context, buildProfile string, args ...string) ([]string, error) {
 	mvn, err := java.MvnCmd(ctx)
 	if err != nil {
 		return nil, err
 	}
-	command := []string{mvn, "package", "-DskipTests", "--batch-mode", "-Dhttp.keepAlive=false"}
+	settingsArgs, err := java.MavenSettingsArgs(ctx, ".")
+	if err != nil {
+		return nil, err
+	}
+	command := append([]string{mvn, "package", "-DskipTests", "--batch-mode", "-Dhttp.keepAlive=false"}, settingsArgs...)
 
 	if buildProfile != "" {
 		command = append(command, "-P"+buildProfile)
 	}
+	command = append(command, args...)
 
 	if _, err := ctx.Exec(command, gcp.WithUserAttribution); err != nil {
 		return nil, err
@@ -177,12 +195,16 @@ func parsePomFile(ctx *gcp.Context) (*java.MavenProject, error) {
 	if err != nil {
 		return nil, err
 	}
-	if _, err := ctx.Exec([]string{
+	settingsArgs, err := java.MavenSettingsArgs(ctx, ".")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := ctx.Exec(append([]string{
 		mvn,
 		"help:effective-pom",
 		"--batch-mode",
 		"-Dhttp.keepAlive=false",
-		"-Doutput=" + effectivePomPath}, gcp.WithUserAttribution); err != nil {
+		"-Doutput=" + effectivePomPath}, settingsArgs...), gcp.WithUserAttribution); err != nil {
 		return nil, err
 	}
 
@@ -204,8 +226,10 @@ func parsePomFile(ctx *gcp.Context) (*java.MavenProject, error) {
 func findNativeBuildProfile(ctx *gcp.Context, project *java.MavenProject) (string, bool) {
 	for _, profile := range project.Profiles {
 		for _, plugin := range profile.Plugins {
-			if plugin.GroupID == "org.graalvm.nativeimage" &&
-				plugin.ArtifactID == "native-image-maven-plugin" {
+			if (plugin.GroupID == "org.graalvm.nativeimage" && plugin.ArtifactID == "native-image-maven-plugin") ||
+				// The Native Build Tools plugin replaces native-image-maven-plugin, it is used by
+				// the native profile of Spring Boot 3.
+				(plugin.GroupID == "org.graalvm.buildtools" && plugin.ArtifactID == "native-maven-plugin") {
 				return profile.ID, true
 			}
 		}
@@ -215,6 +239,22 @@ func findNativeBuildProfile(ctx *gcp.Context, project *java.MavenProject) (strin
 	return "", false
 }
 
+// frameworkNativeArgs returns the Maven arguments that make the Quarkus or Micronaut Maven plugin
+// build a native executable, and a bool which returns true if either plugin is defined.
+func frameworkNativeArgs(ctx *gcp.Context, project *java.MavenProject) ([]string, bool) {
+	for _, plugin := range project.Plugins {
+		switch {
+		case (plugin.GroupID == "io.quarkus" || plugin.GroupID == "io.quarkus.platform") && plugin.ArtifactID == "quarkus-maven-plugin":
+			ctx.Logf("Building a Quarkus native executable")
+			return []string{"-Dquarkus.package.type=native"}, true
+		case (plugin.GroupID == "io.micronaut.maven" || plugin.GroupID == "io.micronaut.build") && plugin.ArtifactID == "micronaut-maven-plugin":
+			ctx.Logf("Building a Micronaut native executable")
+			return []string{"-Dpackaging=native-image"}, true
+		}
+	}
+	return nil, false
+}
+
 // springBootPluginDefined checks if the spring-boot-maven-plugin is defined.
 func springBootPluginDefined(ctx *gcp.Context, project *java.MavenProject) bool {
 	for _, plugin := range project.Plugins {
@@ -20,6 +20,7 @@ import (
 	"io/ioutil"
 	"path"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"testing"
 
@@ -119,6 +120,102 @@ func TestFindSpringBootPlugin(t *testing.T) {
 	}
 }
 
+func TestFindNativeBuildProfile(t *testing.T) {
+	testCases := []struct {
+		name         string
+		mavenProject *java.MavenProject
+		want         string
+		wantOK       bool
+	}{
+		{
+			name: "native-image-maven-plugin",
+			mavenProject: &java.MavenProject{
+				Profiles: []java.MavenProfile{{ID: "graal", Plugins: []java.MavenPlugin{
+					{GroupID: "org.graalvm.nativeimage", ArtifactID: "native-image-maven-plugin"},
+				}}},
+			},
+			want:   "graal",
+			wantOK: true,
+		},
+		{
+			name: "native build tools plugin",
+			mavenProject: &java.MavenProject{
+				Profiles: []java.MavenProfile{
+					{ID: "dev"},
+					{ID: "native", Plugins: []java.MavenPlugin{
+						{GroupID: "org.graalvm.buildtools", ArtifactID: "native-maven-plugin"},
+					}},
+				},
+			},
+			want:   "native",
+			wantOK: true,
+		},
+		{
+			name: "plugin outside profile",
+			mavenProject: &java.MavenProject{
+				Plugins: []java.MavenPlugin{
+					{GroupID: "org.graalvm.buildtools", ArtifactID: "native-maven-plugin"},
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := findNativeBuildProfile(gcp.NewContext(), tc.mavenProject)
+			if got != tc.want || ok != tc.wantOK {
+				t.Errorf("findNativeBuildProfile() = (%q, %t), want (%q, %t)", got, ok, tc.want, tc.wantOK)
+			}
+		})
+	}
+}
+
+func TestFrameworkNativeArgs(t *testing.T) {
+	testCases := []struct {
+		name         string
+		mavenProject *java.MavenProject
+		want         []string
+		wantOK       bool
+	}{
+		{
+			name: "quarkus",
+			mavenProject: &java.MavenProject{
+				Plugins: []java.MavenPlugin{
+					{GroupID: "io.quarkus.platform", ArtifactID: "quarkus-maven-plugin"},
+				},
+			},
+			want:   []string{"-Dquarkus.package.type=native"},
+			wantOK: true,
+		},
+		{
+			name: "micronaut",
+			mavenProject: &java.MavenProject{
+				Plugins: []java.MavenPlugin{
+					{GroupID: "org.apache.maven.plugins", ArtifactID: "maven-compiler-plugin"},
+					{GroupID: "io.micronaut.maven", ArtifactID: "micronaut-maven-plugin"},
+				},
+			},
+			want:   []string{"-Dpackaging=native-image"},
+			wantOK: true,
+		},
+		{
+			name: "spring boot",
+			mavenProject: &java.MavenProject{
+				Plugins: []java.MavenPlugin{
+					{GroupID: "org.springframework.boot", ArtifactID: "spring-boot-maven-plugin"},
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := frameworkNativeArgs(gcp.NewContext(), tc.mavenProject)
+			if !reflect.DeepEqual(got, tc.want) || ok != tc.wantOK {
+				t.Errorf("frameworkNativeArgs() = (%v, %t), want (%v, %t)", got, ok, tc.want, tc.wantOK)
+			}
+		})
+	}
+}
+
 func TestGetClasspathAndMainFromSpringBoot(t *testing.T) {
 	testCases := []struct {
 		name          string
@@ -20,11 +20,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/java"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runimage"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
+	"github.com/buildpacks/libcnb"
 )
 
 const (
@@ -76,19 +80,49 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 }
 
 func buildFn(ctx *gcp.Context) error {
-	featureVersion := defaultFeatureVersion
-	if v := os.Getenv(env.RuntimeVersion); v != "" {
-		featureVersion = v
-		ctx.Logf("Using requested runtime feature version: %s", featureVersion)
+	featureVersion, err := runtimeVersion(ctx)
+	if err != nil {
+		return err
+	}
+	jlink, err := java.JlinkEnabled()
+	if err != nil {
+		return err
+	}
+	var l *libcnb.Layer
+	if jlink {
+		// The application runs on the runtime created by the java/jlink buildpack, the JDK is only
+		// needed to build it.
+		l, err = ctx.Layer(javaLayer, gcp.BuildLayer, gcp.CacheLayer)
 	} else {
-		ctx.Logf("Using latest Java %s runtime version. You can specify a different version with %s: https://github.com/GoogleCloudPlatform/buildpacks#configuration", defaultFeatureVersion, env.RuntimeVersion)
+		l, err = ctx.Layer(javaLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayerUnlessSkipRuntimeLaunch)
 	}
-	l, err := ctx.Layer(javaLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayerUnlessSkipRuntimeLaunch)
 	if err != nil {
 		return fmt.Errorf("creating %v layer: %w", javaLayer, err)
 	}
-	_, err = runtime.InstallTarballIfNotCached(ctx, runtime.OpenJDK, featureVersion, l)
-	return err
+	if _, err := runtime.InstallTarballIfNotCached(ctx, runtime.OpenJDK, featureVersion, l); err != nil {
+		return err
+	}
+	minRunImage, err := runimage.IsMin()
+	if err != nil || !minRunImage {
+		return err
+	}
+	// The JVM is loaded by the launcher and not linked to it, so it is checked separately.
+	return runimage.CheckDynamicDeps(filepath.Join(l.Path, "bin", "java"), filepath.Join(l.Path, "lib", "server", "libjvm.so"))
+}
+
+// runtimeVersion returns the requested Java version, from GOOGLE_RUNTIME_VERSION or from the
+// application's version files, falling back to the default feature version.
+func runtimeVersion(ctx *gcp.Context) (string, error) {
+	if v := os.Getenv(env.RuntimeVersion); v != "" {
+		ctx.Logf("Using requested runtime feature version: %s", v)
+		return v, nil
+	}
+	v, err := java.RuntimeVersion(ctx)
+	if err != nil || v != "" {
+		return v, err
+	}
+	ctx.Logf("Using latest Java %s runtime version. You can specify a different version with %s: https://github.com/GoogleCloudPlatform/buildpacks#configuration", defaultFeatureVersion, env.RuntimeVersion)
+	return defaultFeatureVersion, nil
 }
 
 type binaryPkg struct {
@@ -15,10 +15,13 @@
 package main
 
 import (
+	"io/ioutil"
+	"path/filepath"
 	"reflect"
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 )
 
 func TestDetect(t *testing.T) {
@@ -99,6 +102,50 @@ func TestDetect(t *testing.T) {
 	}
 }
 
+func TestRuntimeVersion(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		env   string
+		want  string
+	}{
+		{
+			name: "default",
+			want: defaultFeatureVersion,
+		},
+		{
+			name:  "version file",
+			files: map[string]string{".sdkmanrc": "java=17.0.9-tem"},
+			want:  "17",
+		},
+		{
+			name:  "env var takes precedence",
+			files: map[string]string{".sdkmanrc": "java=17.0.9-tem"},
+			env:   "21",
+			want:  "21",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("GOOGLE_RUNTIME_VERSION", tc.env)
+			dir := t.TempDir()
+			for name, content := range tc.files {
+				if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatalf("writing %s: %v", name, err)
+				}
+			}
+
+			got, err := runtimeVersion(gcp.NewContext(gcp.WithApplicationRoot(dir)))
+			if err != nil {
+				t.Fatalf("runtimeVersion() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("runtimeVersion() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
 func TestParseVersionJSON(t *testing.T) {
 	testCases := []struct {
 		name         string
@@ -0,0 +1,200 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements nodejs/bun buildpack.
+// The bun buildpack installs Bun, installs dependencies using Bun and runs the app with Bun.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/ar"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildcommand"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+)
+
+const (
+	bunLayer   = "bun_engine"
+	cacheLayer = "bun_cache"
+
+	// cacheKey is the metadata key of the cache layer that holds its cache key.
+	cacheKey = "cache"
+
+	// cacheFormatVersion identifies the layout of the cached bun_cache layer. Bump it whenever the
+	// way the layer is populated changes.
+	cacheFormatVersion = "v1"
+)
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	pkgJSONExists, err := ctx.FileExists("package.json")
+	if err != nil {
+		return nil, err
+	}
+	if !pkgJSONExists {
+		return gcp.OptOutFileNotFound("package.json"), nil
+	}
+
+	for _, f := range []string{nodejs.BunLock, nodejs.BunConfig} {
+		exists, err := ctx.FileExists(f)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return gcp.OptInFileFound(f), nil
+		}
+	}
+	return gcp.OptOut(fmt.Sprintf("neither %s nor %s found", nodejs.BunLock, nodejs.BunConfig)), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	pjs, err := nodejs.ReadPackageJSONIfExists(ctx.ApplicationRoot())
+	if err != nil {
+		return err
+	}
+	bl, err := ctx.Layer(bunLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", bunLayer, err)
+	}
+	bunVersion, err := nodejs.InstallBunLayer(ctx, bl, pjs)
+	if err != nil {
+		return fmt.Errorf("installing bun: %w", err)
+	}
+
+	cacheDir, err := installCacheDir(ctx, bunVersion)
+	if err != nil {
+		return err
+	}
+
+	if err := ar.GenerateNPMConfig(ctx); err != nil {
+		return fmt.Errorf("generating Artifact Registry credentials: %w", err)
+	}
+	if err := nodejs.ConfigureNPMRegistry(ctx); err != nil {
+		return fmt.Errorf("configuring npm registry: %w", err)
+	}
+
+	lockExists, err := ctx.FileExists(nodejs.BunLock)
+	if err != nil {
+		return err
+	}
+	// The global cache is content-addressable, so it is kept across lockfile changes.
+	cacheEnv := gcp.WithEnv("BUN_INSTALL_CACHE_DIR=" + cacheDir)
+
+	nodeEnv := nodejs.NodeEnv()
+	_, customBuild := buildcommand.Command()
+	buildScript := nodejs.BuildScript(pjs)
+	gcpBuild := buildScript != "" || customBuild
+	// Install the devDependencies regardless of NODE_ENV if the app is built so that the build has
+	// access to them. They are pruned from the final app below.
+	production := nodeEnv == nodejs.EnvProduction && !gcpBuild
+	if result, err := ctx.Exec(installCommand(lockExists, production), cacheEnv, gcp.WithUserAttribution); err != nil {
+		return nodejs.LockfileError(result, err, nodejs.BunLock, "bun install")
+	}
+
+	if gcpBuild {
+		if err := nodejs.RunGCPBuild(ctx, "bun", buildScript); err != nil {
+			return err
+		}
+	}
+
+	if !production && nodejs.HasDevDependencies(pjs) {
+		prune, err := nodejs.PruneDevDependencies(ctx)
+		if err != nil {
+			return err
+		}
+		if prune {
+			// Bun has no prune command, so the production dependencies are reinstalled from the
+			// cache instead.
+			ctx.Logf("Pruning devDependencies")
+			if err := ctx.RemoveAll("node_modules"); err != nil {
+				return err
+			}
+			if _, err := ctx.Exec(installCommand(lockExists, true), cacheEnv, gcp.WithUserAttribution); err != nil {
+				return err
+			}
+		}
+	}
+
+	el, err := ctx.Layer("env", gcp.BuildLayer, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating layer: %w", err)
+	}
+	el.SharedEnvironment.Prepend("PATH", string(os.PathListSeparator), filepath.Join(ctx.ApplicationRoot(), "node_modules", ".bin"))
+	el.SharedEnvironment.Default("NODE_ENV", nodejs.NodeEnv())
+	if err := nodejs.ConfigureSourceMaps(ctx, el); err != nil {
+		return err
+	}
+
+	// Configure the entrypoint for production.
+	cmd := []string{"bun", "run", "start"}
+
+	if !devmode.Enabled(ctx) {
+		ctx.AddWebProcess(cmd)
+		return nil
+	}
+
+	// Configure the entrypoint and metadata for dev mode.
+	if err := devmode.AddFileWatcherProcess(ctx, devmode.Config{
+		RunCmd: cmd,
+		Ext:    devmode.NodeWatchedExtensions,
+	}); err != nil {
+		return fmt.Errorf("adding devmode file watcher: %w", err)
+	}
+	devmode.AddSyncMetadata(ctx, devmode.NodeSyncRules)
+
+	return nil
+}
+
+// installCommand returns the bun install command. The lockfile is never updated during the build.
+func installCommand(lockExists, production bool) []string {
+	cmd := []string{"bun", "install"}
+	if lockExists {
+		cmd = append(cmd, "--frozen-lockfile")
+	}
+	if production {
+		cmd = append(cmd, "--production")
+	}
+	return cmd
+}
+
+// installCacheDir returns the path of the Bun global install cache in a cached layer. The cache is
+// cleared when the version of Bun changes because the cache layout is not stable across versions.
+func installCacheDir(ctx *gcp.Context, bunVersion string) (string, error) {
+	cl, err := ctx.Layer(cacheLayer, gcp.BuildLayer, gcp.CacheLayer)
+	if err != nil {
+		return "", fmt.Errorf("creating %v layer: %w", cacheLayer, err)
+	}
+	hit, key, err := cache.CheckCache(ctx, cl, cache.WithFormatVersion(cacheFormatVersion), cacheKey, cache.WithStrings(bunVersion))
+	if err != nil {
+		return "", fmt.Errorf("checking cache: %w", err)
+	}
+	if hit {
+		ctx.CacheHit(cacheLayer)
+	} else {
+		ctx.CacheMiss(cacheLayer)
+		if err := ctx.ClearLayer(cl); err != nil {
+			return "", fmt.Errorf("clearing layer %q: %w", cacheLayer, err)
+		}
+		ctx.SetMetadata(cl, cacheKey, key)
+	}
+	return filepath.Join(cl.Path, "install"), nil
+}
@@ -0,0 +1,188 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/cacheformat"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  int
+	}{
+		{
+			name: "without package",
+			files: map[string]string{
+				"index.js":  "",
+				"bun.lockb": "",
+			},
+			want: 100,
+		},
+		{
+			name: "with package without bun files",
+			files: map[string]string{
+				"index.js":     "",
+				"package.json": "",
+			},
+			want: 100,
+		},
+		{
+			name: "with package and bun lock",
+			files: map[string]string{
+				"index.js":     "",
+				"package.json": "",
+				"bun.lockb":    "",
+			},
+			want: 0,
+		},
+		{
+			name: "with package and bunfig",
+			files: map[string]string{
+				"index.js":     "",
+				"package.json": "",
+				"bunfig.toml":  "",
+			},
+			want: 0,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buildpacktest.TestDetect(t, detectFn, tc.name, tc.files, []string{}, tc.want)
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name            string
+		envs            []string
+		files           map[string]string
+		wantCommands    []string
+		skippedCommands []string
+	}{
+		{
+			name: "install production dependencies",
+			files: map[string]string{
+				"package.json": `{"engines": {"bun": "1.0.3"}}`,
+				"bun.lockb":    "",
+			},
+			wantCommands: []string{
+				"npm install --global --quiet",
+				"bun install --frozen-lockfile --production",
+			},
+			skippedCommands: []string{"bun run gcp-build"},
+		},
+		{
+			name: "gcp-build script",
+			files: map[string]string{
+				"package.json": `{"engines": {"bun": "1.0.3"}, "scripts": {"gcp-build": "tsc"}, "devDependencies": {"typescript": "^5.0.0"}}`,
+				"bun.lockb":    "",
+			},
+			wantCommands: []string{"bun run gcp-build", "bun install --frozen-lockfile --production"},
+		},
+		{
+			name: "gcp-build script retains devDependencies outside production",
+			envs: []string{"NODE_ENV=development"},
+			files: map[string]string{
+				"package.json": `{"engines": {"bun": "1.0.3"}, "scripts": {"gcp-build": "tsc"}, "devDependencies": {"typescript": "^5.0.0"}}`,
+				"bun.lockb":    "",
+			},
+			wantCommands:    []string{"bun run gcp-build"},
+			skippedCommands: []string{"--production"},
+		},
+		{
+			name: "build command replaces gcp-build",
+			envs: []string{"GOOGLE_BUILD_COMMAND=bun run custom-build"},
+			files: map[string]string{
+				"package.json": `{"engines": {"bun": "1.0.3"}, "scripts": {"gcp-build": "tsc", "custom-build": "bun build.ts"}}`,
+				"bunfig.toml":  "",
+			},
+			wantCommands:    []string{"bash -c bun run custom-build"},
+			skippedCommands: []string{"bun run gcp-build"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []buildpacktest.Option{
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithEnvs(tc.envs...),
+				buildpacktest.WithFiles(tc.files),
+				// Installing bun from the npm registry is mocked out.
+				buildpacktest.WithExecMocks(mockprocess.New("npm install --global")),
+			}
+
+			result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+			if err != nil {
+				t.Fatalf("error running build: %v, result: %#v", err, result)
+			}
+
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not", cmd)
+				}
+			}
+			for _, cmd := range tc.skippedCommands {
+				if result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to not be executed, but it was", cmd)
+				}
+			}
+		})
+	}
+}
+
+func TestInstallCommand(t *testing.T) {
+	testCases := []struct {
+		name       string
+		lockExists bool
+		production bool
+		want       []string
+	}{
+		{
+			name: "without lockfile",
+			want: []string{"bun", "install"},
+		},
+		{
+			name:       "with lockfile",
+			lockExists: true,
+			want:       []string{"bun", "install", "--frozen-lockfile"},
+		},
+		{
+			name:       "production",
+			lockExists: true,
+			production: true,
+			want:       []string{"bun", "install", "--frozen-lockfile", "--production"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := installCommand(tc.lockExists, tc.production)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("installCommand(%v, %v) mismatch (-want +got):\n%s", tc.lockExists, tc.production, diff)
+			}
+		})
+	}
+}
+
+func TestCacheFormatVersion(t *testing.T) {
+	cacheformat.Check(t, "testdata/cache_format.golden", cache.WithFormatVersion(cacheFormatVersion), "main.go")
+}
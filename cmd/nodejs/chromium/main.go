@@ -0,0 +1,164 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements nodejs/chromium buildpack.
+// The chromium buildpack installs a pinned headless Chromium and the shared libraries it needs, so
+// that Puppeteer and Playwright applications can launch a browser without a custom image.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/apt"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// chromiumVersion is the Chrome for Testing release that is installed. Update it together with
+	// the puppeteer versions it is tested with.
+	chromiumVersion = "121.0.6167.85"
+	chromiumURL     = "https://storage.googleapis.com/chrome-for-testing-public/%s/linux64/chrome-linux64.zip"
+
+	// chromiumLayer holds the browser, it is used at build and launch time.
+	chromiumLayer = "chromium"
+	// libsLayer holds the shared libraries and fonts of the browser that are not in the run image.
+	libsLayer  = "chromium_libs"
+	versionKey = "version"
+)
+
+// chromiumPackages are the packages that provide the shared libraries and fonts Chromium loads.
+var chromiumPackages = []string{
+	"fontconfig",
+	"fonts-liberation",
+	"libasound2",
+	"libatk-bridge2.0-0",
+	"libatk1.0-0",
+	"libcups2",
+	"libdrm2",
+	"libgbm1",
+	"libgtk-3-0",
+	"libnspr4",
+	"libnss3",
+	"libpango-1.0-0",
+	"libxcomposite1",
+	"libxdamage1",
+	"libxfixes3",
+	"libxkbcommon0",
+	"libxrandr2",
+	"libxshmfence1",
+}
+
+// fontsConf is a fontconfig configuration that adds the fonts extracted into the libraries layer to
+// the fonts of the image, if any.
+const fontsConf = `<?xml version="1.0"?>
+<!DOCTYPE fontconfig SYSTEM "fonts.dtd">
+<fontconfig>
+  <include ignore_missing="yes">/etc/fonts/fonts.conf</include>
+  <dir>%s</dir>
+  <cachedir>/tmp/fontconfig</cachedir>
+</fontconfig>
+`
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	enabled, err := env.IsPresentAndTrue(env.NodeChromium)
+	if err != nil {
+		return nil, gcp.UserErrorf("parsing %s: %v", env.NodeChromium, err)
+	}
+	if !enabled {
+		return gcp.OptOutEnvNotSet(env.NodeChromium), nil
+	}
+	return gcp.OptInEnvSet(env.NodeChromium), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	if arch := ctx.Arch(); arch != gcp.ArchAMD64 {
+		return gcp.UserErrorf("%s is not supported on %s, Chromium builds are only available for %s", env.NodeChromium, arch, gcp.ArchAMD64)
+	}
+
+	ll, err := ctx.Layer(libsLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", libsLayer, err)
+	}
+	if err := apt.InstallCached(ctx, ll, chromiumPackages); err != nil {
+		return err
+	}
+	fontsConfPath := filepath.Join(ll.Path, "fonts.conf")
+	if err := ctx.WriteFile(fontsConfPath, []byte(fmt.Sprintf(fontsConf, filepath.Join(ll.Path, "usr", "share", "fonts"))), 0644); err != nil {
+		return err
+	}
+	ll.SharedEnvironment.Default("FONTCONFIG_FILE", fontsConfPath)
+
+	l, err := ctx.Layer(chromiumLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", chromiumLayer, err)
+	}
+	if err := installChromium(ctx, l); err != nil {
+		return err
+	}
+	configureEnv(l, filepath.Join(l.Path, "chrome-linux64", "chrome"))
+	ctx.AddBOMEntry(libcnb.BOMEntry{
+		Name:     chromiumLayer,
+		Metadata: map[string]interface{}{"version": chromiumVersion},
+		Launch:   true,
+		Build:    true,
+	})
+	return nil
+}
+
+// installChromium downloads and extracts Chromium into the layer unless the pinned version is
+// already there.
+func installChromium(ctx *gcp.Context, l *libcnb.Layer) error {
+	if ctx.GetMetadata(l, versionKey) == chromiumVersion {
+		ctx.CacheHit(chromiumLayer)
+		return nil
+	}
+	ctx.CacheMiss(chromiumLayer)
+	if err := ctx.ClearLayer(l); err != nil {
+		return fmt.Errorf("clearing layer %q: %w", l.Name, err)
+	}
+	ctx.Logf("Installing Chromium %s", chromiumVersion)
+	tmpDir, err := ctx.TempDir("chromium")
+	if err != nil {
+		return err
+	}
+	defer ctx.RemoveAll(tmpDir)
+	archive := filepath.Join(tmpDir, "chrome-linux64.zip")
+	if err := ctx.Download(fmt.Sprintf(chromiumURL, chromiumVersion), archive); err != nil {
+		return err
+	}
+	if _, err := ctx.Exec([]string{"unzip", "-q", archive, "-d", l.Path}, gcp.WithUserAttribution); err != nil {
+		return fmt.Errorf("extracting Chromium: %w", err)
+	}
+	ctx.SetMetadata(l, versionKey, chromiumVersion)
+	return nil
+}
+
+// configureEnv points Puppeteer and the tools that look for Chrome to the installed binary, and
+// skips the browser downloads of the npm packages that would otherwise be installed next.
+func configureEnv(l *libcnb.Layer, chrome string) {
+	l.SharedEnvironment.Default("PUPPETEER_EXECUTABLE_PATH", chrome)
+	l.SharedEnvironment.Default("CHROME_PATH", chrome)
+	l.SharedEnvironment.Append("PATH", string(os.PathListSeparator), filepath.Dir(chrome))
+	l.BuildEnvironment.Default("PUPPETEER_SKIP_DOWNLOAD", "true")
+	l.BuildEnvironment.Default("PUPPETEER_SKIP_CHROMIUM_DOWNLOAD", "true")
+	l.BuildEnvironment.Default("PLAYWRIGHT_SKIP_BROWSER_DOWNLOAD", "1")
+}
@@ -0,0 +1,79 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name string
+		envs []string
+		want int
+	}{
+		{
+			name: "enabled",
+			envs: []string{"GOOGLE_NODE_CHROMIUM=true"},
+			want: 0,
+		},
+		{
+			name: "disabled",
+			envs: []string{"GOOGLE_NODE_CHROMIUM=false"},
+			want: 100,
+		},
+		{
+			name: "not set",
+			want: 100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buildpacktest.TestDetect(t, detectFn, tc.name, map[string]string{"package.json": "{}"}, tc.envs, tc.want)
+		})
+	}
+}
+
+func TestConfigureEnv(t *testing.T) {
+	l := &libcnb.Layer{
+		Path:              "/layers/chromium",
+		BuildEnvironment:  libcnb.Environment{},
+		SharedEnvironment: libcnb.Environment{},
+	}
+
+	configureEnv(l, "/layers/chromium/chrome-linux64/chrome")
+
+	wantShared := libcnb.Environment{
+		"PUPPETEER_EXECUTABLE_PATH.default": "/layers/chromium/chrome-linux64/chrome",
+		"CHROME_PATH.default":               "/layers/chromium/chrome-linux64/chrome",
+		"PATH.append":                       "/layers/chromium/chrome-linux64",
+		"PATH.delim":                        ":",
+	}
+	if diff := cmp.Diff(wantShared, l.SharedEnvironment); diff != "" {
+		t.Errorf("configureEnv() shared environment mismatch (-want +got):\n%s", diff)
+	}
+	wantBuild := libcnb.Environment{
+		"PUPPETEER_SKIP_DOWNLOAD.default":          "true",
+		"PUPPETEER_SKIP_CHROMIUM_DOWNLOAD.default": "true",
+		"PLAYWRIGHT_SKIP_BROWSER_DOWNLOAD.default": "1",
+	}
+	if diff := cmp.Diff(wantBuild, l.BuildEnvironment); diff != "" {
+		t.Errorf("configureEnv() build environment mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,430 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Scans function source files for code patterns that misbehave when a single
+// instance handles several requests at once. The findings are heuristics and
+// are printed to stdout as a JSON array of
+//   {"file": ..., "line": ..., "column": ..., "rule": ..., "message": ...}
+//
+// The script uses the acorn parser bundled with Node.js, which is only
+// reachable with the --expose-internals flag:
+//   node --expose-internals concurrency.js index.js [more.js...]
+//
+// Files that fail to parse are skipped with a note on stderr. The script exits
+// with a non-zero code only if the parser is unavailable.
+
+'use strict';
+
+const fs = require('fs');
+
+let acorn;
+try {
+  acorn = require('internal/deps/acorn/acorn/dist/acorn');
+} catch (e) {
+  console.error(`JavaScript parser is unavailable: ${e.message}`);
+  process.exit(2);
+}
+
+const SQLITE_MODULES = new Set(['sqlite3', 'better-sqlite3', 'sqlite']);
+
+/**
+ * Parses the source as a module, falling back to a script, using the newest
+ * ECMAScript version the bundled parser supports.
+ * @param {string} source the source code.
+ * @return {!Object} the ESTree AST.
+ */
+function parse(source) {
+  let lastErr;
+  for (const ecmaVersion of ['latest', 2022, 2020]) {
+    for (const sourceType of ['module', 'script']) {
+      try {
+        return acorn.parse(source, {
+          ecmaVersion,
+          sourceType,
+          locations: true,
+          allowHashBang: true,
+          allowReturnOutsideFunction: sourceType === 'script',
+        });
+      } catch (e) {
+        lastErr = e;
+      }
+    }
+  }
+  throw lastErr;
+}
+
+/**
+ * Calls fn for each direct child node of node.
+ * @param {!Object} node an AST node.
+ * @param {function(!Object, string)} fn called with the child and its key.
+ */
+function forEachChild(node, fn) {
+  for (const key of Object.keys(node)) {
+    if (key === 'loc') {
+      continue;
+    }
+    const value = node[key];
+    if (Array.isArray(value)) {
+      for (const v of value) {
+        if (v && typeof v.type === 'string') {
+          fn(v, key);
+        }
+      }
+    } else if (value && typeof value.type === 'string') {
+      fn(value, key);
+    }
+  }
+}
+
+function isFunction(node) {
+  return (
+    node.type === 'FunctionDeclaration' ||
+    node.type === 'FunctionExpression' ||
+    node.type === 'ArrowFunctionExpression'
+  );
+}
+
+/**
+ * Adds the identifiers bound by a declaration pattern to names.
+ * @param {?Object} pattern an identifier or destructuring pattern.
+ * @param {!Set<string>} names the set to add to.
+ */
+function addPatternNames(pattern, names) {
+  if (!pattern) {
+    return;
+  }
+  switch (pattern.type) {
+    case 'Identifier':
+      names.add(pattern.name);
+      break;
+    case 'ObjectPattern':
+      for (const p of pattern.properties) {
+        addPatternNames(p.type === 'RestElement' ? p.argument : p.value, names);
+      }
+      break;
+    case 'ArrayPattern':
+      for (const e of pattern.elements) {
+        addPatternNames(e, names);
+      }
+      break;
+    case 'RestElement':
+      addPatternNames(pattern.argument, names);
+      break;
+    case 'AssignmentPattern':
+      addPatternNames(pattern.left, names);
+      break;
+  }
+}
+
+/**
+ * Returns the names declared by a function: its parameters, its own name and
+ * every declaration in its body outside nested functions. Block scoping is
+ * approximated by function scoping.
+ * @param {!Object} fn a function node.
+ * @return {!Set<string>} the declared names.
+ */
+function functionScope(fn) {
+  const names = new Set();
+  if (fn.id) {
+    names.add(fn.id.name);
+  }
+  for (const p of fn.params) {
+    addPatternNames(p, names);
+  }
+  const visit = (node) => {
+    if (node.type === 'VariableDeclaration') {
+      for (const d of node.declarations) {
+        addPatternNames(d.id, names);
+      }
+    } else if (node.type === 'FunctionDeclaration') {
+      names.add(node.id.name);
+      return;
+    } else if (node.type === 'ClassDeclaration' && node.id) {
+      names.add(node.id.name);
+    } else if (node.type === 'CatchClause') {
+      addPatternNames(node.param, names);
+    }
+    if (isFunction(node)) {
+      return;
+    }
+    forEachChild(node, visit);
+  };
+  forEachChild(fn.body, visit);
+  return names;
+}
+
+/**
+ * Returns the names of mutable variables declared at the top level.
+ * @param {!Object} program the program node.
+ * @return {!Set<string>} the names declared with var or let.
+ */
+function moduleVariables(program) {
+  const names = new Set();
+  for (const stmt of program.body) {
+    let decl = stmt;
+    if (stmt.type === 'ExportNamedDeclaration' && stmt.declaration) {
+      decl = stmt.declaration;
+    }
+    if (decl.type === 'VariableDeclaration' && decl.kind !== 'const') {
+      for (const d of decl.declarations) {
+        addPatternNames(d.id, names);
+      }
+    }
+  }
+  return names;
+}
+
+/**
+ * Returns true if the expression references the identifier.
+ * @param {?Object} node an AST node.
+ * @param {string} name the identifier name.
+ * @return {boolean} whether name is referenced.
+ */
+function references(node, name) {
+  if (!node) {
+    return false;
+  }
+  if (node.type === 'Identifier') {
+    return node.name === name;
+  }
+  let found = false;
+  forEachChild(node, (child) => {
+    found = found || references(child, name);
+  });
+  return found;
+}
+
+/**
+ * Returns true if the assignment to name looks like lazy initialization, e.g.
+ * `client = client || new Client()`, `client ??= new Client()` or an
+ * assignment guarded by `if (!client)`. These are safe and recommended.
+ * @param {!Object} node the assignment node.
+ * @param {string} name the assigned identifier.
+ * @param {!Array<!Object>} ancestors the enclosing nodes, innermost last.
+ * @return {boolean} whether the assignment is a lazy initialization.
+ */
+function isLazyInit(node, name, ancestors) {
+  if (node.type === 'AssignmentExpression') {
+    if (node.operator === '||=' || node.operator === '??=') {
+      return true;
+    }
+    const right = node.right;
+    if (
+      node.operator === '=' &&
+      right.type === 'LogicalExpression' &&
+      references(right.left, name)
+    ) {
+      return true;
+    }
+  }
+  for (let i = ancestors.length - 1; i >= 0; i--) {
+    const a = ancestors[i];
+    if (isFunction(a)) {
+      break;
+    }
+    if (
+      (a.type === 'IfStatement' || a.type === 'ConditionalExpression') &&
+      references(a.test, name)
+    ) {
+      return true;
+    }
+  }
+  return false;
+}
+
+/**
+ * Returns the name of the called function or constructor, e.g. `Database` for
+ * `new sqlite3.Database(...)`.
+ * @param {!Object} callee the callee node.
+ * @return {string} the name, or an empty string.
+ */
+function calleeName(callee) {
+  if (callee.type === 'Identifier') {
+    return callee.name;
+  }
+  if (callee.type === 'MemberExpression' && !callee.computed) {
+    return callee.property.name;
+  }
+  return '';
+}
+
+/**
+ * Returns true if the expression is a path under /tmp, either as a literal or
+ * built from os.tmpdir().
+ * @param {?Object} node the expression.
+ * @return {boolean} whether the path is in /tmp.
+ */
+function isTmpPath(node) {
+  if (!node) {
+    return false;
+  }
+  if (node.type === 'Literal' && typeof node.value === 'string') {
+    return node.value === '/tmp' || node.value.startsWith('/tmp/');
+  }
+  if (node.type === 'TemplateLiteral') {
+    const head = node.quasis[0].value.cooked || '';
+    if (head.startsWith('/tmp/')) {
+      return true;
+    }
+    return node.expressions.some(isTmpPath);
+  }
+  if (node.type === 'CallExpression') {
+    if (calleeName(node.callee) === 'tmpdir') {
+      return true;
+    }
+    return node.arguments.length > 0 && isTmpPath(node.arguments[0]);
+  }
+  if (node.type === 'BinaryExpression' && node.operator === '+') {
+    return isTmpPath(node.left);
+  }
+  return false;
+}
+
+/**
+ * Returns true if the program loads one of the sqlite modules.
+ * @param {!Object} program the program node.
+ * @return {boolean} whether sqlite is used.
+ */
+function usesSqlite(program) {
+  let found = false;
+  const visit = (node) => {
+    if (found) {
+      return;
+    }
+    if (
+      node.type === 'ImportDeclaration' &&
+      SQLITE_MODULES.has(node.source.value)
+    ) {
+      found = true;
+    } else if (
+      node.type === 'CallExpression' &&
+      calleeName(node.callee) === 'require' &&
+      node.arguments.length > 0 &&
+      SQLITE_MODULES.has(node.arguments[0].value)
+    ) {
+      found = true;
+    }
+    forEachChild(node, visit);
+  };
+  visit(program);
+  return found;
+}
+
+/**
+ * Returns the concurrency findings for a parsed program.
+ * @param {!Object} program the program node.
+ * @param {string} file the file name used in findings.
+ * @return {!Array<!Object>} the findings.
+ */
+function lint(program, file) {
+  const findings = [];
+  const report = (node, rule, message) => {
+    findings.push({
+      file,
+      line: node.loc.start.line,
+      column: node.loc.start.column + 1,
+      rule,
+      message,
+    });
+  };
+  const globals = moduleVariables(program);
+  const sqlite = usesSqlite(program);
+
+  const ancestors = [];
+  const scopes = [];
+  const isModuleVariable = (name) =>
+    globals.has(name) && !scopes.some((s) => s.has(name));
+
+  const checkWrite = (node, target) => {
+    if (scopes.length === 0 || target.type !== 'Identifier') {
+      return;
+    }
+    const name = target.name;
+    if (!isModuleVariable(name) || isLazyInit(node, name, ancestors)) {
+      return;
+    }
+    report(
+      node,
+      'module-state',
+      `module-level variable "${name}" is modified inside a function; ` +
+        'concurrent requests share it and may overwrite each other'
+    );
+  };
+
+  const visit = (node) => {
+    if (node.type === 'AssignmentExpression') {
+      checkWrite(node, node.left);
+    } else if (node.type === 'UpdateExpression') {
+      checkWrite(node, node.argument);
+    } else if (
+      node.type === 'CallExpression' &&
+      node.callee.type === 'MemberExpression' &&
+      !node.callee.computed &&
+      node.callee.object.type === 'Identifier' &&
+      node.callee.object.name === 'process' &&
+      node.callee.property.name === 'chdir'
+    ) {
+      report(
+        node,
+        'process-chdir',
+        'process.chdir changes the working directory of every request ' +
+          'handled by this instance'
+      );
+    } else if (
+      sqlite &&
+      (node.type === 'NewExpression' || node.type === 'CallExpression') &&
+      calleeName(node.callee) !== 'require' &&
+      node.arguments.length > 0 &&
+      isTmpPath(node.arguments[0]) &&
+      /Database|sqlite/i.test(calleeName(node.callee))
+    ) {
+      report(
+        node,
+        'sqlite-tmp',
+        'SQLite database in /tmp is held in instance memory and shared by ' +
+          'concurrent requests; it is not persisted across instances'
+      );
+    }
+
+    const fn = isFunction(node);
+    if (fn) {
+      scopes.push(functionScope(node));
+    }
+    ancestors.push(node);
+    forEachChild(node, visit);
+    ancestors.pop();
+    if (fn) {
+      scopes.pop();
+    }
+  };
+  visit(program);
+  return findings;
+}
+
+function main(files) {
+  const findings = [];
+  for (const file of files) {
+    let program;
+    try {
+      program = parse(fs.readFileSync(file, 'utf8'));
+    } catch (e) {
+      console.error(`Skipping ${file}: ${e.message}`);
+      continue;
+    }
+    findings.push(...lint(program, file));
+  }
+  console.log(JSON.stringify(findings));
+}
+
+main(process.argv.slice(2));
@@ -17,13 +17,17 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/ar"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cloudfunctions"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
@@ -35,7 +39,11 @@ const (
 	functionsFrameworkPackage = "@google-cloud/functions-framework"
 
 	// nodeJSHeadroomMB is the amount of memory we'll set aside before computing the max memory size.
-	nodeJSHeadroomMB int = 64
+	nodeJSHeadroomMB int = nodejs.HeapHeadroomMB
+
+	// cacheFormatVersion identifies the layout of the cached functions-framework layer. Bump it
+	// whenever the way the layer is populated changes.
+	cacheFormatVersion = "v2"
 )
 
 func main() {
@@ -46,10 +54,19 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 	if nodejs.IsNodeJS8Runtime() {
 		return gcp.OptOut("Incompatible with nodejs8"), nil
 	}
-	if _, ok := os.LookupEnv(env.FunctionTarget); ok {
-		return gcp.OptInEnvSet(env.FunctionTarget), nil
+	return gcp.DetectFunction(ctx)
+}
+
+// missingMainError returns the error for a function source file that does not exist. The hint
+// points the "main" field in package.json to the only .js file of the application if there is one.
+func missingMainError(ctx *gcp.Context, fnFile string) error {
+	candidate := "<file>.js"
+	if jsFiles, err := ctx.Glob("*.js"); err == nil && len(jsFiles) == 1 {
+		candidate = jsFiles[0]
 	}
-	return gcp.OptOutEnvNotSet(env.FunctionTarget), nil
+	return gcp.UserErrorf("%s does not exist", fnFile).
+		WithCode(buildererror.CodeNodejsMissingMain).
+		WithHint(`Set the "main" field in package.json to the file that exports your function, or create index.js.`, "npm pkg set main="+candidate)
 }
 
 // buildFn sets up the execution environment for the function.
@@ -86,12 +103,21 @@ func buildFn(ctx *gcp.Context) error {
 		}
 	}
 
+	// Functions written in TypeScript are loaded from the compiled file.
+	compiled, err := compileTypeScript(ctx, pjs)
+	if err != nil {
+		return err
+	}
+	if compiled != "" {
+		fnFile = compiled
+	}
+
 	fnFileExists, err := ctx.FileExists(fnFile)
 	if err != nil {
 		return err
 	}
 	if !fnFileExists {
-		return gcp.UserErrorf("%s does not exist", fnFile)
+		return missingMainError(ctx, fnFile)
 	}
 
 	yarnPnP, err := usingYarnModuleResolution(ctx)
@@ -120,6 +146,14 @@ func buildFn(ctx *gcp.Context) error {
 		}
 	}
 
+	lint, err := nodejs.ConcurrencyLintEnabled()
+	if err != nil {
+		return err
+	}
+	if lint {
+		nodejs.LintConcurrency(ctx, filepath.Join(ctx.BuildpackRoot(), "lint", "concurrency.js"), fnFile)
+	}
+
 	l, err := ctx.Layer(layerName, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayer)
 	if err != nil {
 		return fmt.Errorf("creating %v layer: %w", layerName, err)
@@ -141,6 +175,7 @@ func buildFn(ctx *gcp.Context) error {
 		ff = "yarn functions-framework"
 	} else if hasFrameworkDependency {
 		ctx.Logf("Handling functions with dependency on functions-framework.")
+		cloudfunctions.WarnIfFrameworkVersionIgnored(ctx, "package.json")
 		if err := ctx.ClearLayer(l); err != nil {
 			return fmt.Errorf("clearing layer %q: %w", l.Name, err)
 		}
@@ -166,50 +201,104 @@ func buildFn(ctx *gcp.Context) error {
 	}
 
 	// Get and set the valid value for --max-old-space-size node_options.
-	// Keep the existing behaviour if the value is not provided or invalid
+	// Otherwise it is derived from the container memory limit at launch.
 	if size, err := getMaxOldSpaceSize(); err != nil {
 		return err
 	} else if size > 0 {
 		l.LaunchEnvironment.Prepend("NODE_OPTIONS", " ", fmt.Sprintf("--max-old-space-size=%d", size))
 	}
+	if err := nodejs.AddHeapSizeExecD(ctx); err != nil {
+		return err
+	}
 
 	if err := ctx.SetFunctionsEnvVars(l); err != nil {
 		return err
 	}
-	ctx.AddWebProcess([]string{"/bin/bash", "-c", ff})
-	return nil
+	if compiled != "" {
+		// The framework loads the function from the file set as its source instead of the "main" field
+		// of package.json, which may point to the TypeScript source.
+		l.LaunchEnvironment.Default(env.FunctionSourceLaunch, filepath.Join(ctx.ApplicationRoot(), compiled))
+		if err := nodejs.ConfigureSourceMaps(ctx, l); err != nil {
+			return err
+		}
+	}
+	return ctx.AddFunctionWebProcesses([]string{"/bin/bash", "-c", ff})
 }
 
-// installFunctionsFramework downloads the functions-framework package to node_modules in the given layer.
+// installFunctionsFramework downloads the functions-framework package to node_modules in the given
+// layer. The version locked in the converter is installed unless GOOGLE_FUNCTIONS_FRAMEWORK_VERSION
+// selects another one.
 func installFunctionsFramework(ctx *gcp.Context, l *libcnb.Layer) error {
 	cvt := filepath.Join(ctx.BuildpackRoot(), "converter", "without-framework")
 	pjs := filepath.Join(cvt, "package.json")
 	pljs := filepath.Join(cvt, nodejs.PackageLock)
 
-	cached, err := nodejs.CheckOrClearCache(ctx, l, cache.WithStrings(nodejs.EnvProduction), cache.WithFiles(pjs, pljs))
+	version, err := cloudfunctions.FrameworkVersion(ctx, "nodejs")
+	if err != nil {
+		return err
+	}
+	if version == cloudfunctions.FrameworkLatest {
+		result, err := ctx.Exec([]string{"npm", "view", functionsFrameworkPackage + "@latest", "version"}, gcp.WithUserAttribution)
+		if err != nil {
+			return fmt.Errorf("finding the latest version of %s: %w", functionsFrameworkPackage, err)
+		}
+		version = strings.TrimSpace(result.Stdout)
+		ctx.Logf("The latest version of %s is %s.", functionsFrameworkPackage, version)
+	}
+	pinned := version != cloudfunctions.FrameworkStable
+
+	cacheOpts := []cache.Option{cache.WithStrings(nodejs.EnvProduction)}
+	if pinned {
+		cacheOpts = append(cacheOpts, cache.WithStrings(functionsFrameworkPackage+"@"+version))
+	} else {
+		cacheOpts = append(cacheOpts, cache.WithFiles(pjs, pljs))
+	}
+	cached, err := nodejs.CheckOrClearCache(ctx, l, cache.WithFormatVersion(cacheFormatVersion), cacheOpts...)
 	if err != nil {
 		return fmt.Errorf("checking cache: %w", err)
 	}
 	if cached {
 		return nil
 	}
-	installCmd, err := nodejs.NPMInstallCommand(ctx)
-	if err != nil {
-		return err
-	}
-	// NPM expects package.json and the lock file in the prefix directory.
-	if _, err := ctx.Exec([]string{"cp", "-t", l.Path, pjs, pljs}, gcp.WithUserTimingAttribution); err != nil {
-		return err
+	installCmd := "install"
+	if pinned {
+		// There is no lock file for other versions, npm resolves the dependencies of the framework.
+		if err := writePinnedPackageJSON(ctx, filepath.Join(l.Path, "package.json"), version); err != nil {
+			return err
+		}
+	} else {
+		if installCmd, err = nodejs.NPMInstallCommand(ctx); err != nil {
+			return err
+		}
+		// NPM expects package.json and the lock file in the prefix directory.
+		if _, err := ctx.Exec([]string{"cp", "-t", l.Path, pjs, pljs}, gcp.WithUserTimingAttribution); err != nil {
+			return err
+		}
 	}
 	if err := ar.GenerateNPMConfig(ctx); err != nil {
 		return fmt.Errorf("generating Artifact Registry credentials: %w", err)
 	}
+	if err := nodejs.ConfigureNPMRegistry(ctx); err != nil {
+		return fmt.Errorf("configuring npm registry: %w", err)
+	}
 	if _, err := ctx.Exec([]string{"npm", installCmd, "--quiet", "--production", "--prefix", l.Path}, gcp.WithUserAttribution); err != nil {
 		return err
 	}
 	return nil
 }
 
+// writePinnedPackageJSON writes a package.json that depends on the given version of the framework.
+func writePinnedPackageJSON(ctx *gcp.Context, path, version string) error {
+	b, err := json.MarshalIndent(map[string]interface{}{
+		"name":         "functions-framework",
+		"dependencies": map[string]string{functionsFrameworkPackage: version},
+	}, "", "  ")
+	if err != nil {
+		return gcp.InternalErrorf("encoding package.json: %v", err)
+	}
+	return ctx.WriteFile(path, b, 0644)
+}
+
 // getMaxOldSpaceSize returns the memory size specified by (GOOGLE_CONTAINER_MEMORY_HINT_MB - nodeJSHeadroomMB),
 // or 0 if env var is not specified.
 func getMaxOldSpaceSize() (int, error) {
@@ -15,12 +15,19 @@
 package main
 
 import (
+	"encoding/json"
 	"os"
+	"os/exec"
 	"strconv"
 	"strings"
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/cacheformat"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/testdata"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestDetect(t *testing.T) {
@@ -146,3 +153,64 @@ func setEnv(t *testing.T, keyVal string) {
 		}
 	})
 }
+
+func TestCacheFormatVersion(t *testing.T) {
+	cacheformat.Check(t, "testdata/cache_format.golden", cache.WithFormatVersion(cacheFormatVersion), "main.go")
+}
+
+func TestConcurrencyLint(t *testing.T) {
+	if _, err := exec.LookPath("node"); err != nil {
+		t.Skip("node is not installed")
+	}
+	testCases := []struct {
+		file string
+		want []string
+	}{
+		{
+			file: "module_state.js",
+			want: []string{"module-state:5", "module-state:6"},
+		},
+		{
+			file: "local_state.js",
+		},
+		{
+			file: "lazy_init.mjs",
+		},
+		{
+			file: "chdir.js",
+			want: []string{"process-chdir:4"},
+		},
+		{
+			file: "sqlite_tmp.js",
+			want: []string{"sqlite-tmp:6", "sqlite-tmp:7"},
+		},
+		{
+			file: "sqlite_memory.js",
+		},
+	}
+	script := testdata.MustGetPath("lint/concurrency.js")
+	for _, tc := range testCases {
+		t.Run(tc.file, func(t *testing.T) {
+			cmd := exec.Command("node", "--expose-internals", script, tc.file)
+			cmd.Dir = testdata.MustGetPath("testdata/concurrency")
+			out, err := cmd.Output()
+			if err != nil {
+				t.Fatalf("running %v: %v", cmd.Args, err)
+			}
+			var findings []nodejs.ConcurrencyFinding
+			if err := json.Unmarshal(out, &findings); err != nil {
+				t.Fatalf("parsing output %q: %v", out, err)
+			}
+			var got []string
+			for _, f := range findings {
+				if f.File != tc.file {
+					t.Errorf("finding %+v has file %q, want %q", f, f.File, tc.file)
+				}
+				got = append(got, f.Rule+":"+strconv.Itoa(f.Line))
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("concurrency lint of %s returned unexpected findings (-want, +got):\n%s", tc.file, diff)
+			}
+		})
+	}
+}
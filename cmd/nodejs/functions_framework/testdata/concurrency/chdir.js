@@ -0,0 +1,6 @@
+const path = require('path');
+
+exports.handler = (req, res) => {
+  process.chdir(path.join('/workspace', req.query.dir));
+  res.send(process.cwd());
+};
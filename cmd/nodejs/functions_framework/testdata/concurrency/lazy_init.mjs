@@ -0,0 +1,11 @@
+import {Storage} from '@google-cloud/storage';
+
+let storage;
+let bucket = null;
+
+export async function handler(req, res) {
+  storage ??= new Storage();
+  bucket = bucket || storage.bucket('my-bucket');
+  const [files] = await bucket.getFiles();
+  res.send(files.map((f) => f.name));
+}
@@ -0,0 +1,20 @@
+let client;
+let counter = 0;
+const cache = new Map();
+
+function getClient() {
+  if (!client) {
+    client = {connected: true};
+  }
+  return client;
+}
+
+exports.handler = async (req, res) => {
+  let counter = 0;
+  counter++;
+  const user = req.body.user;
+  cache.set(user, Date.now());
+  res.send(`Hello ${user} ${counter} ${getClient().connected}`);
+};
+
+counter = 1;
@@ -0,0 +1,8 @@
+let requestCount = 0;
+var currentUser;
+
+exports.handler = (req, res) => {
+  requestCount++;
+  currentUser = req.body.user;
+  res.send(`Hello ${currentUser}, request ${requestCount}`);
+};
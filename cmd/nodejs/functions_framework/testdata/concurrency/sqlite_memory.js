@@ -0,0 +1,9 @@
+const sqlite3 = require('sqlite3');
+const fs = require('fs');
+
+const db = new sqlite3.Database(':memory:');
+
+exports.handler = (req, res) => {
+  fs.writeFileSync('/tmp/scratch.txt', req.body.text);
+  db.all('SELECT 1', (err, rows) => res.send(rows));
+};
@@ -0,0 +1,11 @@
+const os = require('os');
+const path = require('path');
+const sqlite3 = require('sqlite3');
+const Database = require('better-sqlite3');
+
+const db = new sqlite3.Database('/tmp/app.db');
+const other = new Database(path.join(os.tmpdir(), 'other.db'));
+
+exports.handler = (req, res) => {
+  db.all('SELECT * FROM users', (err, rows) => res.send({rows, other}));
+};
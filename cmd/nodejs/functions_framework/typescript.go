@@ -0,0 +1,254 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	tsConfig        = "tsconfig.json"
+	typescriptLayer = "typescript"
+	// productionModules is where the production node_modules directory is moved while the function
+	// is compiled with the devDependencies.
+	productionModules = ".node_modules.production"
+
+	// typescriptCacheFormatVersion identifies the layout of the cached typescript layer. Bump it
+	// whenever the way the layer is populated changes.
+	typescriptCacheFormatVersion = "v1"
+)
+
+var (
+	// defaultTSEntries are the sources of the function if the "main" field of package.json does not
+	// point to a TypeScript file.
+	defaultTSEntries = []string{"index.ts", "src/index.ts"}
+	// defaultOutDirs are the directories searched for the compiled function when the outDir
+	// compiler option is unknown.
+	defaultOutDirs = []string{"lib", "dist", "build"}
+)
+
+// tsConfigJSON is the part of the resolved tsconfig.json that determines where the function is
+// compiled to.
+type tsConfigJSON struct {
+	CompilerOptions struct {
+		OutDir  string `json:"outDir"`
+		RootDir string `json:"rootDir"`
+	} `json:"compilerOptions"`
+}
+
+// compileTypeScript compiles a function written in TypeScript and returns the path of the compiled
+// entry, relative to the application root. The devDependencies of package.json are installed in a
+// build-only layer, which replaces node_modules while the build script or tsc runs, so that they
+// are not part of the function image. It returns an empty string if the function does not have a
+// tsconfig.json file or is already compiled, either prebuilt or by a gcp-build script.
+func compileTypeScript(ctx *gcp.Context, pjs *nodejs.PackageJSON) (string, error) {
+	tsExists, err := ctx.FileExists(ctx.ApplicationRoot(), tsConfig)
+	if err != nil || !tsExists || pjs == nil {
+		return "", err
+	}
+	if pjs.Scripts.GCPBuild != "" {
+		ctx.Debugf("Skipping TypeScript compilation, the function is built by the gcp-build script.")
+		return "", nil
+	}
+	if pjs.Main != "" && !isTypeScript(pjs.Main) {
+		compiled, err := ctx.FileExists(ctx.ApplicationRoot(), pjs.Main)
+		if err != nil {
+			return "", err
+		}
+		if compiled {
+			ctx.Debugf("Skipping TypeScript compilation, %s already exists.", pjs.Main)
+			return "", nil
+		}
+	}
+
+	l, err := ctx.Layer(typescriptLayer, gcp.BuildLayer, gcp.CacheLayer)
+	if err != nil {
+		return "", fmt.Errorf("creating %v layer: %w", typescriptLayer, err)
+	}
+	if err := installDevDependencies(ctx, l); err != nil {
+		return "", err
+	}
+
+	var cfg tsConfigJSON
+	outDirs := defaultOutDirs
+	tsc := filepath.Join(l.Path, "node_modules", ".bin", "tsc")
+	tscExists, err := ctx.FileExists(tsc)
+	if err != nil {
+		return "", err
+	}
+	compile := func() error {
+		if pjs.Scripts.Build != "" {
+			ctx.Logf("Compiling the function with the build script.")
+			if _, err := ctx.Exec([]string{"npm", "run", "build"}, gcp.WithUserAttribution); err != nil {
+				return nodejs.GCPBuildError(err, "npm run build")
+			}
+		} else if tscExists {
+			ctx.Logf("Compiling the function with tsc.")
+			if _, err := ctx.Exec([]string{tsc, "--project", tsConfig}, gcp.WithUserAttribution); err != nil {
+				return err
+			}
+		} else {
+			return gcp.UserErrorf("compiling the TypeScript function requires typescript in the devDependencies of package.json, or a build script")
+		}
+		if !tscExists {
+			return nil
+		}
+		// tsc resolves the configuration, including the files it extends, which may be packages.
+		result, err := ctx.Exec([]string{tsc, "--showConfig", "--project", tsConfig}, gcp.WithUserAttribution)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal([]byte(result.Stdout), &cfg); err != nil {
+			return gcp.InternalErrorf("parsing the output of tsc --showConfig: %v", err)
+		}
+		outDirs = []string{cfg.CompilerOptions.OutDir}
+		return nil
+	}
+	if err := withDevDependencies(ctx, filepath.Join(l.Path, "node_modules"), compile); err != nil {
+		return "", err
+	}
+
+	candidates := compiledEntryCandidates(pjs.Main, outDirs, cfg.CompilerOptions.RootDir)
+	for _, c := range candidates {
+		exists, err := ctx.FileExists(ctx.ApplicationRoot(), c)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			ctx.Logf("Loading the function from %s.", c)
+			return c, nil
+		}
+	}
+	return "", gcp.UserErrorf("compiled function not found, expected one of %s, set the \"main\" field in package.json to the compiled file", strings.Join(candidates, ", "))
+}
+
+// installDevDependencies installs all the dependencies of package.json, including devDependencies,
+// in the node_modules directory of the layer unless they are cached. Install scripts of
+// package.json, e.g. prepare, are not run since the sources are not in the layer.
+func installDevDependencies(ctx *gcp.Context, l *libcnb.Layer) error {
+	files := []string{filepath.Join(ctx.ApplicationRoot(), "package.json")}
+	installCmd := "install"
+	lockExists, err := ctx.FileExists(ctx.ApplicationRoot(), nodejs.PackageLock)
+	if err != nil {
+		return err
+	}
+	if lockExists {
+		files = append(files, filepath.Join(ctx.ApplicationRoot(), nodejs.PackageLock))
+		installCmd = "ci"
+	}
+	cached, err := nodejs.CheckOrClearCache(ctx, l, cache.WithFormatVersion(typescriptCacheFormatVersion), cache.WithStrings(nodejs.EnvDevelopment), cache.WithFiles(files...))
+	if err != nil {
+		return fmt.Errorf("checking cache: %w", err)
+	}
+	if cached {
+		return nil
+	}
+	ctx.Logf("Installing devDependencies to compile the function.")
+	// NPM expects package.json and the lock file in the prefix directory.
+	if _, err := ctx.Exec(append([]string{"cp", "-t", l.Path}, files...), gcp.WithUserTimingAttribution); err != nil {
+		return err
+	}
+	if _, err := ctx.Exec([]string{"npm", installCmd, "--quiet", "--ignore-scripts", "--prefix", l.Path}, gcp.WithEnv("NODE_ENV="+nodejs.EnvDevelopment), gcp.WithUserAttribution); err != nil {
+		return err
+	}
+	return nil
+}
+
+// withDevDependencies runs fn with node_modules replaced by the given directory of devDependencies,
+// and restores the production node_modules afterwards.
+func withDevDependencies(ctx *gcp.Context, devModules string, fn func() error) error {
+	nm := filepath.Join(ctx.ApplicationRoot(), "node_modules")
+	prod := filepath.Join(ctx.ApplicationRoot(), productionModules)
+	nmExists, err := ctx.FileExists(nm)
+	if err != nil {
+		return err
+	}
+	if nmExists {
+		if err := ctx.Rename(nm, prod); err != nil {
+			return err
+		}
+	}
+	if err := ctx.Symlink(devModules, nm); err != nil {
+		return err
+	}
+	fnErr := fn()
+	if err := ctx.RemoveAll(nm); err != nil {
+		return err
+	}
+	if nmExists {
+		if err := ctx.Rename(prod, nm); err != nil {
+			return err
+		}
+	}
+	return fnErr
+}
+
+// isTypeScript returns true if the file is a TypeScript source file.
+func isTypeScript(file string) bool {
+	return strings.HasSuffix(file, ".ts")
+}
+
+// compiledEntryCandidates returns the files that the entry of the function may be compiled to, in
+// order of preference. The entry is the "main" field of package.json if it is set, or one of the
+// default TypeScript sources. tsc writes the files under rootDir, the common directory of the
+// sources by default, to outDir, or next to the sources if outDir is not set.
+func compiledEntryCandidates(main string, outDirs []string, rootDir string) []string {
+	var candidates []string
+	seen := map[string]bool{}
+	add := func(c string) {
+		c = filepath.Clean(c)
+		if !seen[c] {
+			seen[c] = true
+			candidates = append(candidates, c)
+		}
+	}
+
+	if main != "" && !isTypeScript(main) {
+		add(main)
+		return candidates
+	}
+	sources := defaultTSEntries
+	if main != "" {
+		sources = []string{main}
+	}
+	for _, src := range sources {
+		js := strings.TrimSuffix(filepath.Clean(src), ".ts") + ".js"
+		for _, out := range outDirs {
+			if out == "" {
+				add(js)
+				continue
+			}
+			if rootDir != "" {
+				if rel, err := filepath.Rel(rootDir, js); err == nil && !strings.HasPrefix(rel, "..") {
+					add(filepath.Join(out, rel))
+				}
+				continue
+			}
+			add(filepath.Join(out, js))
+			if parts := strings.SplitN(js, string(filepath.Separator), 2); len(parts) == 2 {
+				add(filepath.Join(out, parts[1]))
+			}
+		}
+	}
+	return candidates
+}
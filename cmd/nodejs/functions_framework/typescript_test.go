@@ -0,0 +1,111 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/cacheformat"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCompiledEntryCandidates(t *testing.T) {
+	testCases := []struct {
+		name    string
+		main    string
+		outDirs []string
+		rootDir string
+		want    []string
+	}{
+		{
+			name:    "compiled main",
+			main:    "build/src/index.js",
+			outDirs: []string{"build"},
+			want:    []string{"build/src/index.js"},
+		},
+		{
+			name:    "typescript main with rootDir",
+			main:    "src/index.ts",
+			outDirs: []string{"./lib"},
+			rootDir: "./src",
+			want:    []string{"lib/index.js"},
+		},
+		{
+			name:    "typescript main without rootDir",
+			main:    "src/function.ts",
+			outDirs: []string{"dist"},
+			want:    []string{"dist/src/function.js", "dist/function.js"},
+		},
+		{
+			name:    "no outDir",
+			main:    "index.ts",
+			outDirs: []string{""},
+			want:    []string{"index.js"},
+		},
+		{
+			name:    "default entries",
+			outDirs: []string{"lib", "dist"},
+			want:    []string{"lib/index.js", "dist/index.js", "lib/src/index.js", "dist/src/index.js"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := compiledEntryCandidates(tc.main, tc.outDirs, tc.rootDir)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("compiledEntryCandidates(%q, %v, %q) mismatch (-want +got):\n%s", tc.main, tc.outDirs, tc.rootDir, diff)
+			}
+		})
+	}
+}
+
+func TestWithDevDependencies(t *testing.T) {
+	root := t.TempDir()
+	dev := filepath.Join(t.TempDir(), "node_modules")
+	for _, dir := range []string{filepath.Join(root, "node_modules", "express"), filepath.Join(dev, "typescript")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("creating %s: %v", dir, err)
+		}
+	}
+	ctx := gcp.NewContext(gcp.WithApplicationRoot(root))
+	wantErr := errors.New("compilation failed")
+
+	err := withDevDependencies(ctx, dev, func() error {
+		if _, err := os.Stat(filepath.Join(root, "node_modules", "typescript")); err != nil {
+			t.Errorf("devDependencies not available while compiling: %v", err)
+		}
+		return wantErr
+	})
+	if err != wantErr {
+		t.Errorf("withDevDependencies() got error: %v, want %v", err, wantErr)
+	}
+	if _, err := os.Stat(filepath.Join(root, "node_modules", "express")); err != nil {
+		t.Errorf("production node_modules not restored: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "node_modules", "typescript")); !os.IsNotExist(err) {
+		t.Errorf("devDependencies still in node_modules, stat error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, productionModules)); !os.IsNotExist(err) {
+		t.Errorf("%s still exists, stat error: %v", productionModules, err)
+	}
+}
+
+func TestTypeScriptCacheFormatVersion(t *testing.T) {
+	cacheformat.Check(t, "testdata/typescript_cache_format.golden", cache.WithFormatVersion(typescriptCacheFormatVersion), "typescript.go")
+}
@@ -0,0 +1,26 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements the heap-size exec.d executable of the Node.js function buildpacks.
+// It sets the maximum heap size of Node.js in NODE_OPTIONS from the container memory limit at launch.
+package main
+
+import (
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/execd"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+)
+
+func main() {
+	execd.Main(nodejs.HeapSizeEnv)
+}
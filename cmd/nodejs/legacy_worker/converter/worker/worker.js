@@ -39,6 +39,9 @@
 //     port to which logs are written. If not specified, logs are written to
 //     stdout and stderr.
 //   - X_GOOGLE_FUNCTION_TRIGGER_TYPE - the trigger type of the client function.
+//   - X_GOOGLE_FUNCTION_MODULE_TYPE - 'module' if the function file is an ES
+//     module, which is loaded with a dynamic import() rather than require().
+//     Files with the .mjs extension are always loaded as ES modules.
 
 var FUNCTION_NAME = process.env.X_GOOGLE_FUNCTION_NAME;
 var FUNCTION_VERSION = process.env.X_GOOGLE_FUNCTION_VERSION;
@@ -49,6 +52,7 @@ var express = require('express');
 var fs = require('fs');
 var http = require('http');
 var onFinished = require('on-finished');
+var url = require('url');
 var util = require('util');
 
 var CODE_LOCATION_DIR = process.env.X_GOOGLE_CODE_LOCATION;
@@ -63,6 +67,7 @@ var SUPERVISOR_INTERNAL_PORT = process.env.X_GOOGLE_SUPERVISOR_INTERNAL_PORT ?
     process.env.X_GOOGLE_SUPERVISOR_INTERNAL_PORT :
     null;
 var FUNCTION_TRIGGER_TYPE = process.env.X_GOOGLE_FUNCTION_TRIGGER_TYPE;
+var FUNCTION_MODULE_TYPE = process.env.X_GOOGLE_FUNCTION_MODULE_TYPE;
 var FUNCTION_TIMEOUT_SEC = process.env.X_GOOGLE_FUNCTION_TIMEOUT_SEC;
 var WORKER_PORT = process.env.X_GOOGLE_WORKER_PORT;
 var NEW_FUNCTION_SIGNATURE = process.env.X_GOOGLE_NEW_FUNCTION_SIGNATURE &&
@@ -398,11 +403,96 @@ var processNodeModuleDefinition = function() {
 };
 
 /**
- * Returns user's function from function file.
+ * Returns whether the given function file is an ES module.
+ * @param {string} functionFileRelativePath
+ * @return {boolean}
+ */
+var isESModule = function(functionFileRelativePath) {
+  return FUNCTION_MODULE_TYPE === 'module' ||
+      functionFileRelativePath.endsWith('.mjs');
+};
+
+/**
+ * Imports the ES module at the given absolute path.
+ * import() is wrapped in a Function so that this file can still be parsed by
+ * Node.js versions that do not support it.
+ * @param {string} absolutePath
+ * @return {!Promise<!Object>}
+ */
+var importModule = function(absolutePath) {
+  var specifier = url.pathToFileURL ?
+      url.pathToFileURL(absolutePath).href :
+      'file://' + absolutePath;
+  return new Function('specifier', 'return import(specifier)')(specifier);
+};
+
+/**
+ * Returns user's function from the loaded function module.
  * Returns null if function can't be retrieved.
  * @param {string} functionFileRelativePath
+ * @param {!Object} functionCode
  * @return {?Function}
  */
+var resolveUserFunction = function(functionFileRelativePath, functionCode) {
+  var userFunction =
+      ENTRY_POINT.split('.').reduce(function(code, entryPointPart) {
+        if (typeof code === 'undefined') {
+          return undefined;
+        } else {
+          return code[entryPointPart];
+        }
+      }, functionCode);
+
+  if (typeof userFunction === 'undefined') {
+    if (functionCode.hasOwnProperty('function')) {
+      userFunction = functionCode['function'];
+    } else {
+      processUserCodeError(
+          'Node.js module defined by file ' + functionFileRelativePath +
+          ' is expected to export function named ' + ENTRY_POINT);
+      return null;
+    }
+  }
+
+  if (typeof userFunction != 'function') {
+    processUserCodeError(
+        'The function exported from file ' + functionFileRelativePath +
+        ' as ' + ENTRY_POINT +
+        ' needs to be of type function. Got: ' + typeof userFunction);
+    return null;
+  }
+
+  return userFunction;
+};
+
+/**
+ * Handles an error which happened when loading the function file.
+ * @param {string} functionFileRelativePath
+ * @param {!Error} ex
+ */
+var processLoadError = function(functionFileRelativePath, ex) {
+  var errorDetails = getErrorDetails(ex);
+  var additionalHint = '';
+  if (errorDetails.includes('Cannot find module') ||
+      errorDetails.includes('Cannot find package')) {
+    additionalHint =
+        'Did you list all required modules in the package.json ' +
+        'dependencies?\n';
+  } else {
+    additionalHint = 'Is there a syntax error in your code?\n';
+  }
+  processUserCodeError(
+      'Code in file ' + functionFileRelativePath + ' can\'t be loaded.\n' +
+      additionalHint + 'Detailed stack trace: ' + errorDetails);
+};
+
+/**
+ * Returns user's function from function file.
+ * Returns null if function can't be retrieved. ES modules are loaded
+ * asynchronously, in which case a promise of the function is returned.
+ * @param {string} functionFileRelativePath
+ * @return {?Function|!Promise<?Function>}
+ */
 var getUserFunction = function(functionFileRelativePath) {
   if (!fs.existsSync(getCodeAbsolutePath(functionFileRelativePath))) {
     processUserCodeError(
@@ -411,64 +501,59 @@ var getUserFunction = function(functionFileRelativePath) {
     return null;
   }
 
-  try {
-    var functionCode = require(getCodeAbsolutePath(functionFileRelativePath));
-    var userFunction =
-        ENTRY_POINT.split('.').reduce(function(code, entryPointPart) {
-          if (typeof code === 'undefined') {
-            return undefined;
-          } else {
-            return code[entryPointPart];
-          }
-        }, functionCode);
-
-    if (typeof userFunction === 'undefined') {
-      if (functionCode.hasOwnProperty('function')) {
-        userFunction = functionCode['function'];
-      } else {
-        processUserCodeError(
-            'Node.js module defined by file ' + functionFileRelativePath +
-            ' is expected to export function named ' + ENTRY_POINT);
-        return null;
-      }
-    }
-
-    if (typeof userFunction != 'function') {
-      processUserCodeError(
-          'The function exported from file ' + functionFileRelativePath +
-          ' as ' + ENTRY_POINT +
-          ' needs to be of type function. Got: ' + typeof userFunction);
+  if (isESModule(functionFileRelativePath)) {
+    var imported;
+    try {
+      imported = importModule(getCodeAbsolutePath(functionFileRelativePath));
+    } catch (ex) {
+      // Node.js versions without ES module support throw synchronously.
+      processLoadError(functionFileRelativePath, ex);
       return null;
     }
+    return imported.then(
+        function(namespace) {
+          // Functions exported with module.exports from a CommonJS dependency
+          // are only available as the default export.
+          var functionCode =
+              typeof namespace[ENTRY_POINT.split('.')[0]] === 'undefined' &&
+                  namespace.default ?
+              namespace.default :
+              namespace;
+          return resolveUserFunction(functionFileRelativePath, functionCode);
+        },
+        function(ex) {
+          processLoadError(functionFileRelativePath, ex);
+          return null;
+        });
+  }
 
-    return userFunction;
+  try {
+    var functionCode = require(getCodeAbsolutePath(functionFileRelativePath));
+    return resolveUserFunction(functionFileRelativePath, functionCode);
   } catch (ex) {
-    var errorDetails = getErrorDetails(ex);
-    var additionalHint = '';
-    if (errorDetails.includes('Cannot find module')) {
-      additionalHint =
-          'Did you list all required modules in the package.json ' +
-          'dependencies?\n';
-    } else {
-      additionalHint = 'Is there a syntax error in your code?\n';
-    }
-    processUserCodeError(
-        'Code in file ' + functionFileRelativePath + ' can\'t be loaded.\n' +
-        additionalHint + 'Detailed stack trace: ' + errorDetails);
+    processLoadError(functionFileRelativePath, ex);
     return null;
   }
 };
 
 /**
  * Loads user's code.
- * @return {boolean} Whether user's code has been loaded successfully.
+ * @return {boolean|!Promise<boolean>} Whether user's code has been loaded
+ *     successfully, or a promise of it if the code is an ES module.
  */
 var loadUserCode = function() {
   var functionFileRelativePath = processNodeModuleDefinition();
   if (!functionFileRelativePath) {
     return false;
   }
-  userFunction = getUserFunction(functionFileRelativePath);
+  var loaded = getUserFunction(functionFileRelativePath);
+  if (loaded && typeof loaded.then === 'function') {
+    return loaded.then(function(fn) {
+      userFunction = fn;
+      return !!userFunction;
+    });
+  }
+  userFunction = loaded;
   return !!userFunction;
 };
 
@@ -687,15 +772,16 @@ app.use(bodyParser.urlencoded(urlEncodedOptions));
 app.use(bodyParser.raw(rawBodySavingOptions));
 
 app.get('/load', function(req, res) {
-  var ready = userFunction || loadUserCode();
-  callAfterFlushingLogs(function() {
-    if (ready) {
-      res.send('User function is ready');
-    } else {
-      // A non-transient error occurred when loading user code.
-      res.set(FUNCTION_STATUS_HEADER_FIELD, 'load_error');
-      res.status(500).send(userCodeError);
-    }
+  Promise.resolve(userFunction || loadUserCode()).then(function(ready) {
+    callAfterFlushingLogs(function() {
+      if (ready) {
+        res.send('User function is ready');
+      } else {
+        // A non-transient error occurred when loading user code.
+        res.set(FUNCTION_STATUS_HEADER_FIELD, 'load_error');
+        res.status(500).send(userCodeError);
+      }
+    });
   });
 });
 
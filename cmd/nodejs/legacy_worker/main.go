@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
@@ -30,6 +31,10 @@ import (
 
 const (
 	layerName = "legacy-worker"
+
+	// cacheFormatVersion identifies the layout of the cached legacy-worker layer. Bump it
+	// whenever the way the layer is populated changes.
+	cacheFormatVersion = "v2"
 )
 
 func main() {
@@ -116,13 +121,17 @@ func buildFn(ctx *gcp.Context) error {
 		// This should never happen because this env var is used by the detect phase.
 		return gcp.InternalErrorf("required env var %s not found", env.FunctionTarget)
 	}
-	signature := os.Getenv(env.FunctionSignatureType)
-	if signature == "http" || signature == "" {
-		// The name of the HTTP signature type is slightly different for worker.js
-		// than that of Functions Frameworks.
-		signature = "HTTP_TRIGGER"
+	trigger, err := triggerType(os.Getenv(env.FunctionSignatureType))
+	if err != nil {
+		return err
+	}
+	l.LaunchEnvironment.Default("X_GOOGLE_FUNCTION_TRIGGER_TYPE", trigger)
+	// worker.js loads ES modules with a dynamic import() instead of require().
+	moduleType := "commonjs"
+	if nodejs.IsESModule(fnFile, pjs) {
+		moduleType = "module"
 	}
-	l.LaunchEnvironment.Default("X_GOOGLE_FUNCTION_TRIGGER_TYPE", signature)
+	l.LaunchEnvironment.Default("X_GOOGLE_FUNCTION_MODULE_TYPE", moduleType)
 	l.LaunchEnvironment.Default("X_GOOGLE_CODE_LOCATION", ctx.ApplicationRoot())
 
 	// TODO(b/184077805) this can be removed after the corresponding code from worker.js is removed
@@ -131,13 +140,33 @@ func buildFn(ctx *gcp.Context) error {
 	l.LaunchEnvironment.Default("X_GOOGLE_WORKER_PORT", 8091)
 	l.LaunchEnvironment.Default("WORKER_PORT", 8091)
 
-	// TODO(b/181987135) historically worker.js was run with the --max-old-space-size to set the heap
-	// size. We should replicate this behaviour via the NODE_OPTIONS env var.
+	// Historically worker.js was run with the --max-old-space-size to set the heap size, it is now set
+	// in NODE_OPTIONS from the container memory limit at launch.
+	if err := nodejs.AddHeapSizeExecD(ctx); err != nil {
+		return err
+	}
 	worker := filepath.Join(l.Path, "worker.js")
 	ctx.AddWebProcess([]string{"node", worker})
 	return nil
 }
 
+// triggerType translates a Functions Framework signature type into the trigger type of worker.js,
+// which serves either HTTP functions or background functions that take (data, context, callback).
+func triggerType(signature string) (string, error) {
+	switch strings.ToLower(signature) {
+	case "", "http":
+		// The name of the HTTP signature type is slightly different for worker.js
+		// than that of Functions Frameworks.
+		return "HTTP_TRIGGER", nil
+	case "cloudevent", "typed":
+		// These functions take a single CloudEvent or typed argument, which worker.js cannot pass.
+		return "", gcp.UserErrorf("%s=%s is not supported by the legacy Node.js 8 worker, use a newer Node.js runtime to deploy the function with the Functions Framework", env.FunctionSignatureType, signature)
+	default:
+		// worker.js serves any other trigger type as a background function.
+		return signature, nil
+	}
+}
+
 // installLegacyWorker copies worker.js and installs its dependencies in the given layer.
 func installLegacyWorker(ctx *gcp.Context, l *libcnb.Layer) error {
 	ctx.Logf("Configuring the legacy Google Cloud Functions worker.js.")
@@ -146,7 +175,7 @@ func installLegacyWorker(ctx *gcp.Context, l *libcnb.Layer) error {
 	pjs := filepath.Join(cvt, "package.json")
 	wjs := filepath.Join(cvt, "worker.js")
 
-	cached, err := nodejs.CheckOrClearCache(ctx, l, cache.WithStrings(nodejs.EnvProduction), cache.WithFiles(pjs, wjs))
+	cached, err := nodejs.CheckOrClearCache(ctx, l, cache.WithFormatVersion(cacheFormatVersion), cache.WithStrings(nodejs.EnvProduction), cache.WithFiles(pjs, wjs))
 	if err != nil {
 		return fmt.Errorf("checking cache: %w", err)
 	}
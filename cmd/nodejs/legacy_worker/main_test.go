@@ -18,6 +18,9 @@ import (
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/cacheformat"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 )
 
 func TestDetect(t *testing.T) {
@@ -59,3 +62,134 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestTriggerType(t *testing.T) {
+	testCases := []struct {
+		signature string
+		want      string
+		wantErr   bool
+	}{
+		{
+			signature: "",
+			want:      "HTTP_TRIGGER",
+		},
+		{
+			signature: "http",
+			want:      "HTTP_TRIGGER",
+		},
+		{
+			signature: "event",
+			want:      "event",
+		},
+		{
+			signature: "cloudevent",
+			wantErr:   true,
+		},
+		{
+			signature: "typed",
+			wantErr:   true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.signature, func(t *testing.T) {
+			got, err := triggerType(tc.signature)
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("triggerType(%q) got error: %v, want error? %v", tc.signature, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("triggerType(%q) = %q, want %q", tc.signature, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name         string
+		files        map[string]string
+		envs         []string
+		wantExitCode int
+		wantLaunch   map[string]string
+		wantProcess  string
+	}{
+		{
+			name:  "http function",
+			files: map[string]string{"index.js": "exports.helloWorld = () => {}"},
+			envs:  []string{"GOOGLE_FUNCTION_TARGET=helloWorld"},
+			wantLaunch: map[string]string{
+				"X_GOOGLE_FUNCTION_NAME":         "helloWorld",
+				"X_GOOGLE_FUNCTION_TRIGGER_TYPE": "HTTP_TRIGGER",
+				"X_GOOGLE_FUNCTION_MODULE_TYPE":  "commonjs",
+			},
+			wantProcess: "^node .*legacy-worker/worker.js$",
+		},
+		{
+			name: "background function with node_modules",
+			files: map[string]string{
+				"function.js":               "exports.onEvent = () => {}",
+				"node_modules/.keep":        "",
+				"package.json":              `{"type": "module"}`,
+				"node_modules/dep/index.js": "",
+			},
+			envs: []string{"GOOGLE_FUNCTION_TARGET=onEvent", "GOOGLE_FUNCTION_SIGNATURE_TYPE=event"},
+			wantLaunch: map[string]string{
+				"X_GOOGLE_FUNCTION_TRIGGER_TYPE": "event",
+				"X_GOOGLE_FUNCTION_MODULE_TYPE":  "module",
+				"NODE_PATH":                      "node_modules",
+			},
+			wantProcess: "worker.js$",
+		},
+		{
+			name:         "cloudevent function",
+			files:        map[string]string{"index.js": "exports.onEvent = () => {}"},
+			envs:         []string{"GOOGLE_FUNCTION_TARGET=onEvent", "GOOGLE_FUNCTION_SIGNATURE_TYPE=cloudevent"},
+			wantExitCode: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []buildpacktest.Option{
+				buildpacktest.WithInProcess(),
+				buildpacktest.WithFiles(tc.files),
+				buildpacktest.WithBuildpackFiles(map[string]string{
+					"converter/worker/package.json": `{"name": "worker"}`,
+					"converter/worker/worker.js":    "// worker",
+					"exec.d/heap-size":              "heap-size binary",
+				}),
+				buildpacktest.WithEnvs(tc.envs...),
+				buildpacktest.WithExecMocks(
+					mockprocess.New("^node --check"),
+					mockprocess.New("^npm --version", mockprocess.WithStdout("6.14.18")),
+					mockprocess.New("^npm ci"),
+				),
+			}
+
+			result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, result: %#v", err, result)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Fatalf("build exit code mismatch, got: %d, want: %d, output: %s", result.ExitCode, tc.wantExitCode, result.Output)
+			}
+			if tc.wantExitCode != 0 {
+				return
+			}
+
+			if !result.FileExistsInLayer("execd", "exec.d/heap-size") {
+				t.Errorf("exec.d/heap-size not found in layer execd, build output: %s", result.Output)
+			}
+			for name, value := range tc.wantLaunch {
+				if !result.LaunchEnvContains(name, value) {
+					t.Errorf("launch env %s does not contain %q", name, value)
+				}
+			}
+			if !result.ProcessRegistered("web", tc.wantProcess) {
+				t.Errorf("web process matching %q not registered", tc.wantProcess)
+			}
+		})
+	}
+}
+
+func TestCacheFormatVersion(t *testing.T) {
+	cacheformat.Check(t, "testdata/cache_format.golden", cache.WithFormatVersion(cacheFormatVersion), "main.go")
+}
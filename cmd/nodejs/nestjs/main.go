@@ -0,0 +1,105 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements nodejs/nestjs buildpack.
+// The nestjs buildpack checks that NestJS applications are compiled by their build script and
+// starts them with node when package.json does not have a start script, instead of the Nest CLI or
+// ts-node which are meant for development.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+)
+
+const (
+	// nestCLIConfig is the configuration file of the Nest CLI, present in all NestJS projects.
+	nestCLIConfig = "nest-cli.json"
+	// outDir is the directory that `nest build` writes the compiled application to.
+	outDir           = "dist"
+	defaultEntryFile = "main"
+)
+
+// nestCLIJSON is the part of nest-cli.json that configures the entry file of the application.
+type nestCLIJSON struct {
+	EntryFile string `json:"entryFile"`
+}
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	exists, err := ctx.FileExists(ctx.ApplicationRoot(), nestCLIConfig)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return gcp.OptOutFileNotFound(nestCLIConfig), nil
+	}
+	return gcp.OptInFileFound(nestCLIConfig), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	if devmode.Enabled(ctx) {
+		ctx.Debugf("Using the start script in dev mode.")
+		return nil
+	}
+	pjs, err := nodejs.ReadPackageJSONIfExists(ctx.ApplicationRoot())
+	if err != nil {
+		return err
+	}
+	if pjs == nil || nodejs.BuildScript(pjs) == "" {
+		ctx.Warnf("package.json does not have a build script, add \"build\": \"nest build\" to compile the NestJS application.")
+	}
+
+	entry, err := entryPoint(ctx)
+	if err != nil {
+		return err
+	}
+	exists, err := ctx.FileExists(ctx.ApplicationRoot(), entry)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return gcp.UserErrorf("%s not found, make sure the build script compiles the NestJS application to %s", entry, outDir)
+	}
+
+	if pjs != nil && pjs.Scripts.Start != "" {
+		ctx.Logf("Starting the NestJS application with the start script.")
+		return nil
+	}
+	ctx.Logf("Starting the NestJS application with %s.", entry)
+	ctx.AddProcess(gcp.WebProcess, []string{"node", entry}, gcp.AsDirectProcess(), gcp.AsDefaultProcess())
+	return nil
+}
+
+// entryPoint returns the path, relative to the application root, of the compiled entry file set in
+// nest-cli.json, dist/main.js by default.
+func entryPoint(ctx *gcp.Context) (string, error) {
+	b, err := ctx.ReadFile(filepath.Join(ctx.ApplicationRoot(), nestCLIConfig))
+	if err != nil {
+		return "", err
+	}
+	cfg := nestCLIJSON{EntryFile: defaultEntryFile}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return "", gcp.UserErrorf("parsing %s: %v", nestCLIConfig, err)
+	}
+	return filepath.Join(outDir, fmt.Sprintf("%s.js", cfg.EntryFile)), nil
+}
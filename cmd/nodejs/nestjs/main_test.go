@@ -0,0 +1,113 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+)
+
+const nestPackageJSON = `{"scripts": {"build": "nest build"}, "dependencies": {"@nestjs/core": "^10.0.0"}, "devDependencies": {"@nestjs/cli": "^10.0.0"}}`
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  int
+	}{
+		{
+			name:  "nest-cli.json",
+			files: map[string]string{"package.json": nestPackageJSON, "nest-cli.json": "{}"},
+			want:  0,
+		},
+		{
+			name:  "no nest-cli.json",
+			files: map[string]string{"package.json": nestPackageJSON},
+			want:  100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buildpacktest.TestDetect(t, detectFn, tc.name, tc.files, nil, tc.want)
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name         string
+		files        map[string]string
+		wantOutput   []string
+		wantExitCode int
+	}{
+		{
+			name: "no start script",
+			files: map[string]string{
+				"package.json":  nestPackageJSON,
+				"nest-cli.json": `{"collection": "@nestjs/schematics", "sourceRoot": "src"}`,
+				"dist/main.js":  "",
+			},
+			wantOutput: []string{"Starting the NestJS application with dist/main.js."},
+		},
+		{
+			name: "entry file",
+			files: map[string]string{
+				"package.json":   nestPackageJSON,
+				"nest-cli.json":  `{"entryFile": "server"}`,
+				"dist/server.js": "",
+			},
+			wantOutput: []string{"Starting the NestJS application with dist/server.js."},
+		},
+		{
+			name: "start script",
+			files: map[string]string{
+				"package.json":  `{"scripts": {"build": "nest build", "start": "node dist/main"}, "dependencies": {"@nestjs/core": "^10.0.0"}}`,
+				"nest-cli.json": "{}",
+				"dist/main.js":  "",
+			},
+			wantOutput: []string{"Starting the NestJS application with the start script."},
+		},
+		{
+			name: "not compiled",
+			files: map[string]string{
+				"package.json":  `{"dependencies": {"@nestjs/core": "^10.0.0"}}`,
+				"nest-cli.json": "{}",
+			},
+			wantOutput: []string{
+				"package.json does not have a build script",
+				"dist/main.js not found",
+			},
+			wantExitCode: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := buildpacktest.RunBuild(t, buildFn, buildpacktest.WithTestName(tc.name), buildpacktest.WithFiles(tc.files))
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, result: %#v", err, result)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code = %d, want %d", result.ExitCode, tc.wantExitCode)
+			}
+			for _, want := range tc.wantOutput {
+				if !strings.Contains(result.Output, want) {
+					t.Errorf("build output = %q, want it to contain %q", result.Output, want)
+				}
+			}
+		})
+	}
+}
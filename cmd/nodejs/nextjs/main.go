@@ -0,0 +1,96 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements nodejs/nextjs buildpack.
+// The nextjs buildpack starts Next.js applications built with `output: "standalone"` with the
+// standalone server, which only needs the files traced by `next build`.
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+)
+
+const (
+	layerName = "nextjs"
+	// standaloneDir is the directory written by `next build` with `output: "standalone"`.
+	standaloneDir = ".next/standalone"
+	serverJS      = "server.js"
+)
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	pjs, err := nodejs.ReadPackageJSONIfExists(ctx.ApplicationRoot())
+	if err != nil {
+		return nil, err
+	}
+	if nodejs.Framework(pjs) != nodejs.NextJS {
+		return gcp.OptOut("next not found in package.json dependencies"), nil
+	}
+	return gcp.OptIn("found next in package.json dependencies"), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	if devmode.Enabled(ctx) {
+		ctx.Debugf("Using the start script in dev mode.")
+		return nil
+	}
+	standalone := filepath.Join(ctx.ApplicationRoot(), standaloneDir)
+	serverExists, err := ctx.FileExists(standalone, serverJS)
+	if err != nil {
+		return err
+	}
+	if !serverExists {
+		ctx.Logf("%s not found, starting the application with the start script. Set output: \"standalone\" in next.config.js to only include the files needed at run time.", filepath.Join(standaloneDir, serverJS))
+		return nil
+	}
+
+	l, err := ctx.Layer(layerName, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", layerName, err)
+	}
+	ctx.Logf("Copying the Next.js standalone server.")
+	if _, err := ctx.Exec([]string{"cp", "--archive", standalone + "/.", l.Path}, gcp.WithUserTimingAttribution); err != nil {
+		return err
+	}
+	// The standalone server does not include the static assets, which it serves from .next/static and
+	// public relative to server.js.
+	for _, dir := range []string{".next/static", "public"} {
+		exists, err := ctx.FileExists(ctx.ApplicationRoot(), dir)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		dest := filepath.Join(l.Path, dir)
+		if err := ctx.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		if _, err := ctx.Exec([]string{"cp", "--archive", filepath.Join(ctx.ApplicationRoot(), dir), dest}, gcp.WithUserTimingAttribution); err != nil {
+			return err
+		}
+	}
+	// The server listens on HOSTNAME, which is the container name by default.
+	l.LaunchEnvironment.Override("HOSTNAME", "0.0.0.0")
+	ctx.AddProcess(gcp.WebProcess, []string{"node", serverJS}, gcp.AsDirectProcess(), gcp.AsDefaultProcess(), gcp.WithWorkingDirectory(l.Path))
+	return nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+)
+
+const nextPackageJSON = `{"scripts": {"build": "next build", "start": "next start"}, "dependencies": {"next": "^13.4.0"}}`
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  int
+	}{
+		{
+			name:  "next dependency",
+			files: map[string]string{"package.json": nextPackageJSON},
+			want:  0,
+		},
+		{
+			name:  "no next dependency",
+			files: map[string]string{"package.json": `{"dependencies": {"express": "^4.18.2"}}`},
+			want:  100,
+		},
+		{
+			name:  "no package.json",
+			files: map[string]string{"index.js": ""},
+			want:  100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buildpacktest.TestDetect(t, detectFn, tc.name, tc.files, nil, tc.want)
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name            string
+		files           map[string]string
+		wantCommands    []string
+		skippedCommands []string
+	}{
+		{
+			name: "standalone output",
+			files: map[string]string{
+				"package.json":                  nextPackageJSON,
+				".next/standalone/server.js":    "",
+				".next/standalone/package.json": "{}",
+				".next/static/chunks/main.js":   "",
+				"public/favicon.ico":            "",
+			},
+			wantCommands: []string{
+				"cp --archive .*/.next/standalone/. .*nextjs",
+				"cp --archive .*/.next/static .*nextjs/.next/static",
+				"cp --archive .*/public .*nextjs/public",
+			},
+		},
+		{
+			name: "standalone output without public",
+			files: map[string]string{
+				"package.json":                nextPackageJSON,
+				".next/standalone/server.js":  "",
+				".next/static/chunks/main.js": "",
+			},
+			wantCommands:    []string{"cp --archive .*/.next/standalone/. .*nextjs"},
+			skippedCommands: []string{"cp --archive .*/public"},
+		},
+		{
+			name: "no standalone output",
+			files: map[string]string{
+				"package.json":                nextPackageJSON,
+				".next/static/chunks/main.js": "",
+			},
+			skippedCommands: []string{"cp"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := buildpacktest.RunBuild(t, buildFn, buildpacktest.WithTestName(tc.name), buildpacktest.WithFiles(tc.files))
+			if err != nil {
+				t.Fatalf("error running build: %v, result: %#v", err, result)
+			}
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not", cmd)
+				}
+			}
+			for _, cmd := range tc.skippedCommands {
+				if result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to not be executed, but it was", cmd)
+				}
+			}
+		})
+	}
+}
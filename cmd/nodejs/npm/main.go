@@ -20,17 +20,24 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/ar"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildcommand"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildermetrics"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
 )
 
 const (
 	cacheTag = "prod dependencies"
+
+	// cacheFormatVersion identifies the layout of the cached npm_modules layer. Bump it
+	// whenever the way the layer is populated changes.
+	cacheFormatVersion = "v1"
 )
 
 func main() {
@@ -60,6 +67,9 @@ func buildFn(ctx *gcp.Context) error {
 	if err := ar.GenerateNPMConfig(ctx); err != nil {
 		return fmt.Errorf("generating Artifact Registry credentials: %w", err)
 	}
+	if err := nodejs.ConfigureNPMRegistry(ctx); err != nil {
+		return fmt.Errorf("configuring npm registry: %w", err)
+	}
 
 	pjs, err := nodejs.ReadPackageJSONIfExists(ctx.ApplicationRoot())
 	if err != nil {
@@ -68,6 +78,16 @@ func buildFn(ctx *gcp.Context) error {
 	if err := upgradeNPM(ctx, pjs); err != nil {
 		return err
 	}
+	// Dependencies of all workspaces are installed and hoisted at the root, only the selected
+	// workspace is built and started.
+	ws, err := nodejs.SelectedWorkspace(ctx, pjs)
+	if err != nil {
+		return err
+	}
+	buildPJS := pjs
+	if ws != nil {
+		buildPJS = ws.PackageJSON
+	}
 
 	lockfile, err := nodejs.EnsureLockfile(ctx)
 	if err != nil {
@@ -75,11 +95,14 @@ func buildFn(ctx *gcp.Context) error {
 	}
 
 	nodeEnv := nodejs.NodeEnv()
-	gcpBuild := nodejs.HasGCPBuild(pjs)
+	_, customBuild := buildcommand.Command()
+	buildScript := nodejs.BuildScript(buildPJS)
+	gcpBuild := buildScript != "" || customBuild
 	if gcpBuild {
 		nodeEnv = nodejs.EnvDevelopment
 	}
-	cached, err := nodejs.CheckOrClearCache(ctx, ml, cache.WithStrings(nodeEnv), cache.WithFiles("package.json", lockfile))
+	devInstalled := nodeEnv != nodejs.EnvProduction
+	cached, err := nodejs.CheckOrClearCache(ctx, ml, cache.WithFormatVersion(cacheFormatVersion), cache.WithStrings(nodeEnv), cache.WithFiles("package.json", lockfile))
 	if err != nil {
 		return fmt.Errorf("checking cache: %w", err)
 	}
@@ -101,8 +124,8 @@ func buildFn(ctx *gcp.Context) error {
 			return err
 		}
 
-		if _, err := ctx.Exec([]string{"npm", installCmd, "--quiet"}, gcp.WithEnv("NODE_ENV="+nodeEnv), gcp.WithUserAttribution); err != nil {
-			return err
+		if result, err := ctx.Exec([]string{"npm", installCmd, "--quiet"}, gcp.WithEnv("NODE_ENV="+nodeEnv), gcp.WithStreamingOutput, gcp.WithNetworkRetry, gcp.WithUserAttribution); err != nil {
+			return nodejs.LockfileError(result, err, lockfile, "npm install")
 		}
 
 		// Ensure node_modules exists even if no dependencies were installed.
@@ -112,35 +135,79 @@ func buildFn(ctx *gcp.Context) error {
 		if _, err := ctx.Exec([]string{"cp", "--archive", "node_modules", nm}, gcp.WithUserTimingAttribution); err != nil {
 			return err
 		}
+		if err := nodejs.SaveRemoteCache(ctx, ml); err != nil {
+			return err
+		}
 	}
 
 	if gcpBuild {
-		if _, err := ctx.Exec([]string{"npm", "run", "gcp-build"}, gcp.WithUserAttribution); err != nil {
-			return err
+		if customBuild {
+			if _, err := buildcommand.Run(ctx, nodejs.BuildCommandConfig()); err != nil {
+				return err
+			}
+		} else {
+			cmd := []string{"npm", "run", buildScript}
+			if ws != nil {
+				cmd = append(cmd, ws.Flag())
+			}
+			if _, err := ctx.Exec(cmd, gcp.WithUserAttribution); err != nil {
+				return nodejs.GCPBuildError(err, strings.Join(cmd, " "))
+			}
+			buildermetrics.GlobalBuilderMetrics().GetCounter(buildermetrics.NpmGcpBuildUsageCounterID).Increment(1)
 		}
-		buildermetrics.GlobalBuilderMetrics().GetCounter(buildermetrics.NpmGcpBuildUsageCounterID).Increment(1)
+	}
 
-		shouldPrune, err := shouldPrune(ctx, pjs)
+	if devInstalled {
+		pruneCmds, err := pruneCommands(ctx, pjs, ws)
 		if err != nil {
 			return err
 		}
-		if shouldPrune {
-			// npm prune deletes devDependencies from node_modules
-			if _, err := ctx.Exec([]string{"npm", "prune", "--production"}, gcp.WithUserAttribution); err != nil {
+		// npm prune deletes devDependencies from node_modules
+		for _, cmd := range pruneCmds {
+			if _, err := ctx.Exec(cmd, gcp.WithUserAttribution); err != nil {
 				return err
 			}
 		}
+		devInstalled = len(pruneCmds) == 0
+	}
+
+	if err := nodejs.RemoveModulesCache(ctx); err != nil {
+		return err
+	}
+
+	if err := nodejs.NPMAudit(ctx); err != nil {
+		return err
+	}
+
+	// node_modules is in the application directory so its packages are part of the launch SBOM.
+	if sbom, err := nodejs.NPMLockSBOMEntries(filepath.Join(ctx.ApplicationRoot(), lockfile), devInstalled); err != nil {
+		ctx.Warnf("Failed to read %s, skipping SBOM entries: %v", lockfile, err)
+	} else {
+		ctx.AddLaunchSBOMEntries(sbom...)
 	}
 
 	el, err := ctx.Layer("env", gcp.BuildLayer, gcp.LaunchLayer)
 	if err != nil {
 		return fmt.Errorf("creating layer: %w", err)
 	}
-	el.SharedEnvironment.Prepend("PATH", string(os.PathListSeparator), filepath.Join(ctx.ApplicationRoot(), "node_modules", ".bin"))
+	binPath := filepath.Join(ctx.ApplicationRoot(), "node_modules", ".bin")
+	if ws != nil {
+		binPath = filepath.Join(ctx.ApplicationRoot(), ws.Dir, "node_modules", ".bin") + string(os.PathListSeparator) + binPath
+		// The workspace runs from its own directory, NODE_PATH lets tools that do not resolve modules
+		// from parent directories find the hoisted dependencies.
+		el.SharedEnvironment.Default("NODE_PATH", filepath.Join(ctx.ApplicationRoot(), "node_modules"))
+	}
+	el.SharedEnvironment.Prepend("PATH", string(os.PathListSeparator), binPath)
 	el.SharedEnvironment.Default("NODE_ENV", nodejs.NodeEnv())
+	if err := nodejs.ConfigureSourceMaps(ctx, el); err != nil {
+		return err
+	}
 
 	// Configure the entrypoint for production.
 	cmd := []string{"npm", "start"}
+	if ws != nil {
+		cmd = append(cmd, ws.Flag())
+	}
 
 	if !devmode.Enabled(ctx) {
 		ctx.AddWebProcess(cmd)
@@ -159,20 +226,27 @@ func buildFn(ctx *gcp.Context) error {
 	return nil
 }
 
-func shouldPrune(ctx *gcp.Context, pjs *nodejs.PackageJSON) (bool, error) {
+// pruneCommands returns the commands that delete the devDependencies from node_modules, or nil if
+// they are retained.
+func pruneCommands(ctx *gcp.Context, pjs *nodejs.PackageJSON, ws *nodejs.Workspace) ([][]string, error) {
 	// if there are no devDependencies, there is no need to prune.
-	if !nodejs.HasDevDependencies(pjs) {
-		return false, nil
+	if !nodejs.HasDevDependencies(pjs) && (ws == nil || !nodejs.HasDevDependencies(ws.PackageJSON)) {
+		return nil, nil
 	}
-	if nodeEnv := nodejs.NodeEnv(); nodeEnv != nodejs.EnvProduction {
-		ctx.Logf("Retaining devDependencies because $NODE_ENV=%q.", nodeEnv)
-		return false, nil
+	prune, err := nodejs.PruneDevDependencies(ctx)
+	if err != nil || !prune {
+		return nil, err
+	}
+	// Deduplicating may change the installed versions, so it only runs when explicitly requested.
+	dedupe, err := env.IsPresentAndTrue(env.NodePruneDev)
+	if err != nil {
+		return nil, gcp.UserErrorf("%v", err)
 	}
-	canPrune, err := nodejs.SupportsNPMPrune(ctx)
-	if err == nil && !canPrune {
+	cmds, err := nodejs.NPMPruneCommands(ctx, dedupe)
+	if err == nil && cmds == nil {
 		ctx.Warnf("Retaining devDependencies because the version of NPM you are using does not support 'npm prune'.")
 	}
-	return canPrune, err
+	return cmds, err
 }
 
 func upgradeNPM(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
@@ -15,9 +15,13 @@
 package main
 
 import (
+	"strings"
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/cacheformat"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 )
 
 func TestDetect(t *testing.T) {
@@ -48,3 +52,217 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name            string
+		envs            []string
+		files           map[string]string
+		mocks           []*mockprocess.Mock
+		wantExitCode    int
+		wantCommands    []string
+		skippedCommands []string
+		wantOutput      []string
+	}{
+		{
+			name: "gcp-build script",
+			files: map[string]string{
+				"package.json":      `{"scripts": {"gcp-build": "tsc"}}`,
+				"package-lock.json": "{}",
+			},
+			wantCommands: []string{"npm run gcp-build"},
+		},
+		{
+			name: "Next.js build script",
+			files: map[string]string{
+				"package.json":      `{"scripts": {"build": "next build", "start": "next start"}, "dependencies": {"next": "^13.4.0"}}`,
+				"package-lock.json": "{}",
+			},
+			wantCommands: []string{"npm ci", "npm run build"},
+		},
+		{
+			name: "build script without framework",
+			files: map[string]string{
+				"package.json":      `{"scripts": {"build": "tsc"}}`,
+				"package-lock.json": "{}",
+			},
+			skippedCommands: []string{"npm run build"},
+		},
+		{
+			name: "gcp-build script of workspace",
+			envs: []string{"GOOGLE_NODEJS_WORKSPACE=@acme/api"},
+			files: map[string]string{
+				"package.json":              `{"workspaces": ["packages/*"]}`,
+				"package-lock.json":         "{}",
+				"packages/api/package.json": `{"name": "@acme/api", "scripts": {"gcp-build": "tsc"}}`,
+				"packages/web/package.json": `{"name": "@acme/web"}`,
+			},
+			wantCommands: []string{"npm ci", "npm run gcp-build --workspace=packages/api"},
+		},
+		{
+			name: "unknown workspace",
+			envs: []string{"GOOGLE_NODEJS_WORKSPACE=packages/missing"},
+			files: map[string]string{
+				"package.json":              `{"workspaces": ["packages/*"]}`,
+				"package-lock.json":         "{}",
+				"packages/api/package.json": `{"name": "@acme/api"}`,
+			},
+			wantExitCode:    1,
+			skippedCommands: []string{"npm ci"},
+		},
+		{
+			name: "build command replaces gcp-build",
+			envs: []string{"GOOGLE_BUILD_COMMAND=npm run custom-build"},
+			files: map[string]string{
+				"package.json":      `{"scripts": {"gcp-build": "tsc", "custom-build": "node build.js"}}`,
+				"package-lock.json": "{}",
+			},
+			wantCommands:    []string{"npm ci", "bash -c npm run custom-build"},
+			skippedCommands: []string{"npm run gcp-build"},
+		},
+		{
+			name: "build command with expected output",
+			envs: []string{"GOOGLE_BUILD_COMMAND=npm run custom-build", "GOOGLE_BUILD_OUTPUT=dist"},
+			files: map[string]string{
+				"package.json":      `{"scripts": {"custom-build": "node build.js"}}`,
+				"package-lock.json": "{}",
+				"dist/server.js":    "",
+			},
+			wantCommands: []string{"bash -c npm run custom-build"},
+		},
+		{
+			name: "build command without expected output",
+			envs: []string{"GOOGLE_BUILD_COMMAND=npm run custom-build", "GOOGLE_BUILD_OUTPUT=dist"},
+			files: map[string]string{
+				"package.json":      `{"scripts": {"custom-build": "node build.js"}}`,
+				"package-lock.json": "{}",
+			},
+			wantExitCode: 1,
+		},
+		{
+			name: "gcp-build script fails",
+			files: map[string]string{
+				"package.json":      `{"scripts": {"gcp-build": "tsc"}}`,
+				"package-lock.json": "{}",
+			},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New("npm run gcp-build", mockprocess.WithStderr("error TS2322"), mockprocess.WithExitCode(2)),
+			},
+			wantExitCode: 1,
+			wantOutput:   []string{"How to fix:", "Run: npm run gcp-build"},
+		},
+		{
+			name: "devDependencies pruned after gcp-build",
+			files: map[string]string{
+				"package.json":      `{"scripts": {"gcp-build": "tsc"}, "devDependencies": {"typescript": "^5.0.0"}}`,
+				"package-lock.json": "{}",
+			},
+			wantCommands:    []string{"npm run gcp-build", "npm prune --omit=dev"},
+			skippedCommands: []string{"npm dedupe"},
+		},
+		{
+			name: "devDependencies retained",
+			envs: []string{"GOOGLE_NODE_PRUNE_DEV=false"},
+			files: map[string]string{
+				"package.json":      `{"scripts": {"gcp-build": "tsc"}, "devDependencies": {"typescript": "^5.0.0"}}`,
+				"package-lock.json": "{}",
+			},
+			wantCommands:    []string{"npm run gcp-build"},
+			skippedCommands: []string{"npm prune"},
+		},
+		{
+			name: "devDependencies pruned and deduplicated regardless of NODE_ENV",
+			envs: []string{"GOOGLE_NODE_PRUNE_DEV=true", "NODE_ENV=development"},
+			files: map[string]string{
+				"package.json":      `{"devDependencies": {"typescript": "^5.0.0"}}`,
+				"package-lock.json": "{}",
+			},
+			wantCommands: []string{"npm prune --omit=dev", "npm dedupe --omit=dev"},
+		},
+		{
+			name: "invalid prune setting",
+			envs: []string{"GOOGLE_NODE_PRUNE_DEV=sometimes"},
+			files: map[string]string{
+				"package.json":      `{"scripts": {"gcp-build": "tsc"}, "devDependencies": {"typescript": "^5.0.0"}}`,
+				"package-lock.json": "{}",
+			},
+			wantExitCode: 1,
+		},
+		{
+			name: "audit disabled by default",
+			files: map[string]string{
+				"package.json":      `{}`,
+				"package-lock.json": "{}",
+			},
+			skippedCommands: []string{"npm audit --json --omit=dev"},
+		},
+		{
+			name: "audit below severity gate",
+			envs: []string{"GOOGLE_NODEJS_AUDIT=critical"},
+			files: map[string]string{
+				"package.json":      `{}`,
+				"package-lock.json": "{}",
+			},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New("npm audit", mockprocess.WithStdout(auditReport("high")), mockprocess.WithExitCode(1)),
+			},
+			wantCommands: []string{"npm audit --json --omit=dev"},
+			wantOutput:   []string{"1 high"},
+		},
+		{
+			name: "audit fails severity gate",
+			envs: []string{"GOOGLE_NODEJS_AUDIT=high"},
+			files: map[string]string{
+				"package.json":      `{}`,
+				"package-lock.json": "{}",
+			},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New("npm audit", mockprocess.WithStdout(auditReport("critical")), mockprocess.WithExitCode(1)),
+			},
+			wantExitCode: 1,
+			wantOutput:   []string{"GHSA-xvch-5gv4-984h", "Run: npm audit fix"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []buildpacktest.Option{
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithEnvs(tc.envs...),
+				buildpacktest.WithFiles(tc.files),
+				buildpacktest.WithExecMocks(append(tc.mocks, mockprocess.New("npm --version", mockprocess.WithStdout("8.3.1")))...),
+			}
+
+			result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, result: %#v", err, result)
+			}
+
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not", cmd)
+				}
+			}
+			for _, cmd := range tc.skippedCommands {
+				if result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to not be executed, but it was", cmd)
+				}
+			}
+			for _, want := range tc.wantOutput {
+				if !strings.Contains(result.Output, want) {
+					t.Errorf("build output does not contain %q:\n%s", want, result.Output)
+				}
+			}
+		})
+	}
+}
+
+func auditReport(severity string) string {
+	return `{"auditReportVersion": 2, "vulnerabilities": {"minimist": {"via": [{"source": 1097677, "name": "minimist", "title": "Prototype Pollution in minimist", "url": "https://github.com/advisories/GHSA-xvch-5gv4-984h", "severity": "` + severity + `"}]}}}`
+}
+
+func TestCacheFormatVersion(t *testing.T) {
+	cacheformat.Check(t, "testdata/cache_format.golden", cache.WithFormatVersion(cacheFormatVersion), "main.go")
+}
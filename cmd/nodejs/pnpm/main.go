@@ -0,0 +1,193 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements nodejs/pnpm buildpack.
+// The pnpm buildpack installs dependencies using pnpm and installs pnpm itself.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/ar"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildcommand"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+)
+
+const (
+	pnpmLayer  = "pnpm_engine"
+	storeLayer = "pnpm_store"
+
+	// storeKey is the metadata key of the store layer that holds its cache key.
+	storeKey = "store"
+
+	// cacheFormatVersion identifies the layout of the cached pnpm_store layer. Bump it whenever the
+	// way the layer is populated changes.
+	cacheFormatVersion = "v1"
+)
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	pkgJSONExists, err := ctx.FileExists("package.json")
+	if err != nil {
+		return nil, err
+	}
+	if !pkgJSONExists {
+		return gcp.OptOutFileNotFound("package.json"), nil
+	}
+
+	pnpmLockExists, err := ctx.FileExists(nodejs.PNPMLock)
+	if err != nil {
+		return nil, err
+	}
+	if !pnpmLockExists {
+		return gcp.OptOutFileNotFound(nodejs.PNPMLock), nil
+	}
+
+	return gcp.OptIn("found pnpm-lock.yaml and package.json"), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	pjs, err := nodejs.ReadPackageJSONIfExists(ctx.ApplicationRoot())
+	if err != nil {
+		return err
+	}
+	pl, err := ctx.Layer(pnpmLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", pnpmLayer, err)
+	}
+	pnpmVersion, err := nodejs.InstallPNPMLayer(ctx, pl, pjs)
+	if err != nil {
+		return fmt.Errorf("installing pnpm: %w", err)
+	}
+
+	store, err := storeDir(ctx, pnpmVersion)
+	if err != nil {
+		return err
+	}
+
+	if err := ar.GenerateNPMConfig(ctx); err != nil {
+		return fmt.Errorf("generating Artifact Registry credentials: %w", err)
+	}
+	if err := nodejs.ConfigureNPMRegistry(ctx); err != nil {
+		return fmt.Errorf("configuring npm registry: %w", err)
+	}
+
+	nodeEnv := nodejs.NodeEnv()
+	_, customBuild := buildcommand.Command()
+	buildScript := nodejs.BuildScript(pjs)
+	gcpBuild := buildScript != "" || customBuild
+	if gcpBuild {
+		// Install the devDependencies regardless of NODE_ENV so that the build has access to them. They
+		// are pruned from the final app below.
+		nodeEnv = nodejs.EnvDevelopment
+	}
+
+	// The store is content-addressable, so it is kept across lockfile changes and only the packages
+	// no longer referenced are removed after the install.
+	storeFlag := fmt.Sprintf("--store-dir=%s", store)
+	cmd := []string{"pnpm", "install", "--frozen-lockfile", "--prefer-offline", storeFlag}
+	if result, err := ctx.Exec(cmd, gcp.WithEnv("NODE_ENV="+nodeEnv), gcp.WithUserAttribution); err != nil {
+		return nodejs.LockfileError(result, err, nodejs.PNPMLock, "pnpm install")
+	}
+	if _, err := ctx.Exec([]string{"pnpm", "store", "prune", storeFlag}, gcp.WithUserTimingAttribution); err != nil {
+		return err
+	}
+
+	if gcpBuild {
+		if err := nodejs.RunGCPBuild(ctx, "pnpm", buildScript); err != nil {
+			return err
+		}
+	}
+
+	if nodeEnv != nodejs.EnvProduction && nodejs.HasDevDependencies(pjs) {
+		prune, err := nodejs.PruneDevDependencies(ctx)
+		if err != nil {
+			return err
+		}
+		if prune {
+			ctx.Logf("Pruning devDependencies")
+			if _, err := ctx.Exec([]string{"pnpm", "prune", "--prod", storeFlag}, gcp.WithUserAttribution); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := nodejs.RemoveModulesCache(ctx); err != nil {
+		return err
+	}
+
+	el, err := ctx.Layer("env", gcp.BuildLayer, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating layer: %w", err)
+	}
+	el.SharedEnvironment.Prepend("PATH", string(os.PathListSeparator), filepath.Join(ctx.ApplicationRoot(), "node_modules", ".bin"))
+	el.SharedEnvironment.Default("NODE_ENV", nodejs.NodeEnv())
+	if err := nodejs.ConfigureSourceMaps(ctx, el); err != nil {
+		return err
+	}
+
+	// Configure the entrypoint for production.
+	cmd = []string{"pnpm", "start"}
+
+	if !devmode.Enabled(ctx) {
+		ctx.AddWebProcess(cmd)
+		return nil
+	}
+
+	// Configure the entrypoint and metadata for dev mode.
+	if err := devmode.AddFileWatcherProcess(ctx, devmode.Config{
+		RunCmd: cmd,
+		Ext:    devmode.NodeWatchedExtensions,
+	}); err != nil {
+		return fmt.Errorf("adding devmode file watcher: %w", err)
+	}
+	devmode.AddSyncMetadata(ctx, devmode.NodeSyncRules)
+
+	return nil
+}
+
+// storeDir returns the path of the pnpm store in a cached layer. The store is cleared when the
+// major version of pnpm changes because each major version uses a different store layout.
+func storeDir(ctx *gcp.Context, pnpmVersion string) (string, error) {
+	sl, err := ctx.Layer(storeLayer, gcp.BuildLayer, gcp.CacheLayer)
+	if err != nil {
+		return "", fmt.Errorf("creating %v layer: %w", storeLayer, err)
+	}
+	major, err := nodejs.PNPMMajorVersion(pnpmVersion)
+	if err != nil {
+		return "", err
+	}
+	hit, key, err := cache.CheckCache(ctx, sl, cache.WithFormatVersion(cacheFormatVersion), storeKey, cache.WithStrings(major))
+	if err != nil {
+		return "", fmt.Errorf("checking cache: %w", err)
+	}
+	if hit {
+		ctx.CacheHit(storeLayer)
+	} else {
+		ctx.CacheMiss(storeLayer)
+		if err := ctx.ClearLayer(sl); err != nil {
+			return "", fmt.Errorf("clearing layer %q: %w", storeLayer, err)
+		}
+		ctx.SetMetadata(sl, storeKey, key)
+	}
+	return filepath.Join(sl.Path, "store"), nil
+}
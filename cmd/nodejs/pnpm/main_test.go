@@ -0,0 +1,189 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/cacheformat"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  int
+	}{
+		{
+			name: "without package without pnpm lock",
+			files: map[string]string{
+				"index.js": "",
+			},
+			want: 100,
+		},
+		{
+			name: "with package without pnpm lock",
+			files: map[string]string{
+				"index.js":     "",
+				"package.json": "",
+			},
+			want: 100,
+		},
+		{
+			name: "without package with pnpm lock",
+			files: map[string]string{
+				"index.js":       "",
+				"pnpm-lock.yaml": "",
+			},
+			want: 100,
+		},
+		{
+			name: "with pnpm lock and package",
+			files: map[string]string{
+				"index.js":       "",
+				"pnpm-lock.yaml": "",
+				"package.json":   "",
+			},
+			want: 0,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buildpacktest.TestDetect(t, detectFn, tc.name, tc.files, []string{}, tc.want)
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name            string
+		envs            []string
+		files           map[string]string
+		wantCommands    []string
+		skippedCommands []string
+	}{
+		{
+			name: "install dependencies",
+			files: map[string]string{
+				"package.json":   `{"engines": {"pnpm": "8.6.2"}}`,
+				"pnpm-lock.yaml": "",
+			},
+			wantCommands: []string{
+				"npm install --global --quiet",
+				"pnpm install --frozen-lockfile --prefer-offline",
+				"pnpm store prune",
+			},
+			skippedCommands: []string{"pnpm run gcp-build", "pnpm prune --prod"},
+		},
+		{
+			name: "gcp-build script",
+			files: map[string]string{
+				"package.json":   `{"engines": {"pnpm": "8.6.2"}, "scripts": {"gcp-build": "tsc"}, "devDependencies": {"typescript": "^5.0.0"}}`,
+				"pnpm-lock.yaml": "",
+			},
+			wantCommands: []string{"pnpm run gcp-build", "pnpm prune --prod"},
+		},
+		{
+			name: "gcp-build script retains devDependencies outside production",
+			envs: []string{"NODE_ENV=development"},
+			files: map[string]string{
+				"package.json":   `{"engines": {"pnpm": "8.6.2"}, "scripts": {"gcp-build": "tsc"}, "devDependencies": {"typescript": "^5.0.0"}}`,
+				"pnpm-lock.yaml": "",
+			},
+			wantCommands:    []string{"pnpm run gcp-build"},
+			skippedCommands: []string{"pnpm prune --prod"},
+		},
+		{
+			name: "devDependencies pruned outside production when requested",
+			envs: []string{"NODE_ENV=development", "GOOGLE_NODE_PRUNE_DEV=true"},
+			files: map[string]string{
+				"package.json":   `{"engines": {"pnpm": "8.6.2"}, "devDependencies": {"typescript": "^5.0.0"}}`,
+				"pnpm-lock.yaml": "",
+			},
+			wantCommands: []string{"pnpm prune --prod"},
+		},
+		{
+			name: "gcp-build script retains devDependencies when requested",
+			envs: []string{"GOOGLE_NODE_PRUNE_DEV=false"},
+			files: map[string]string{
+				"package.json":   `{"engines": {"pnpm": "8.6.2"}, "scripts": {"gcp-build": "tsc"}, "devDependencies": {"typescript": "^5.0.0"}}`,
+				"pnpm-lock.yaml": "",
+			},
+			wantCommands:    []string{"pnpm run gcp-build"},
+			skippedCommands: []string{"pnpm prune --prod"},
+		},
+		{
+			name: "build command replaces gcp-build",
+			envs: []string{"GOOGLE_BUILD_COMMAND=pnpm run custom-build"},
+			files: map[string]string{
+				"package.json":   `{"engines": {"pnpm": "8.6.2"}, "scripts": {"gcp-build": "tsc", "custom-build": "node build.js"}}`,
+				"pnpm-lock.yaml": "",
+			},
+			wantCommands:    []string{"bash -c pnpm run custom-build"},
+			skippedCommands: []string{"pnpm run gcp-build"},
+		},
+		{
+			name: "packageManager installs pnpm with corepack",
+			files: map[string]string{
+				"package.json":   `{"packageManager": "pnpm@9.1.0"}`,
+				"pnpm-lock.yaml": "",
+			},
+			wantCommands: []string{
+				"corepack enable --install-directory .*/bin pnpm",
+				"corepack prepare pnpm@9.1.0",
+				"pnpm install --frozen-lockfile --prefer-offline",
+			},
+			skippedCommands: []string{"npm install --global"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []buildpacktest.Option{
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithEnvs(tc.envs...),
+				buildpacktest.WithFiles(tc.files),
+				// Installing pnpm from the npm registry is mocked out.
+				buildpacktest.WithExecMocks(
+					mockprocess.New("npm install --global"),
+					mockprocess.New(`^node -v$`, mockprocess.WithStdout("v20.5.0")),
+					mockprocess.New(`^pnpm --version$`, mockprocess.WithStdout("9.1.0")),
+				),
+			}
+
+			result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+			if err != nil {
+				t.Fatalf("error running build: %v, result: %#v", err, result)
+			}
+
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not", cmd)
+				}
+			}
+			for _, cmd := range tc.skippedCommands {
+				if result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to not be executed, but it was", cmd)
+				}
+			}
+		})
+	}
+}
+
+func TestCacheFormatVersion(t *testing.T) {
+	cacheformat.Check(t, "testdata/cache_format.golden", cache.WithFormatVersion(cacheFormatVersion), "main.go")
+}
@@ -28,7 +28,7 @@ import (
 const nodeLayer = "node"
 
 func main() {
-	gcp.Main(detectFn, buildFn)
+	gcp.Main(runtime.WithStackCompatibilityCheck(runtime.Nodejs, detectFn), buildFn)
 }
 
 func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
@@ -71,6 +71,8 @@ func buildFn(ctx *gcp.Context) error {
 	if err != nil {
 		return fmt.Errorf("creating %v layer: %w", nodeLayer, err)
 	}
-	_, err = runtime.InstallTarballIfNotCached(ctx, runtime.Nodejs, version, nrl)
-	return err
+	if _, err := runtime.InstallTarballIfNotCached(ctx, runtime.Nodejs, version, nrl); err != nil {
+		return nodejs.EnginesError(err, pjs, version)
+	}
+	return nil
 }
@@ -0,0 +1,81 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements nodejs/ssr buildpack.
+// The ssr buildpack starts Nuxt, Remix and SvelteKit applications with the server generated by
+// their build, and warns when they are configured to build for another platform.
+package main
+
+import (
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+)
+
+// frameworks are the frameworks whose applications are started by this buildpack.
+var frameworks = map[string]string{
+	nodejs.Nuxt:      "Nuxt",
+	nodejs.Remix:     "Remix",
+	nodejs.SvelteKit: "SvelteKit",
+}
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	pjs, err := nodejs.ReadPackageJSONIfExists(ctx.ApplicationRoot())
+	if err != nil {
+		return nil, err
+	}
+	f := nodejs.Framework(pjs)
+	if _, ok := frameworks[f]; !ok {
+		return gcp.OptOut("no Nuxt, Remix or SvelteKit dependency found in package.json"), nil
+	}
+	return gcp.OptIn(fmt.Sprintf("found %s in package.json dependencies", f)), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	if devmode.Enabled(ctx) {
+		ctx.Debugf("Using the start script in dev mode.")
+		return nil
+	}
+	pjs, err := nodejs.ReadPackageJSONIfExists(ctx.ApplicationRoot())
+	if err != nil {
+		return err
+	}
+	name := frameworks[nodejs.Framework(pjs)]
+
+	warning, err := nodejs.AdapterWarning(ctx, pjs)
+	if err != nil {
+		return err
+	}
+	if warning != "" {
+		ctx.Warnf(warning)
+	}
+
+	cmd, err := nodejs.FrameworkServer(ctx, pjs)
+	if err != nil {
+		return err
+	}
+	if cmd == nil {
+		ctx.Logf("Starting the %s application with the start script.", name)
+		return nil
+	}
+	ctx.Logf("Starting the %s application with %q.", name, cmd)
+	ctx.AddProcess(gcp.WebProcess, cmd, gcp.AsDirectProcess(), gcp.AsDefaultProcess())
+	return nil
+}
@@ -0,0 +1,133 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+)
+
+const (
+	nuxtPackageJSON      = `{"scripts": {"build": "nuxt build"}, "devDependencies": {"nuxt": "^3.8.0"}}`
+	remixPackageJSON     = `{"scripts": {"build": "remix build", "start": "remix-serve build"}, "dependencies": {"@remix-run/node": "^2.2.0", "@remix-run/serve": "^2.2.0"}, "devDependencies": {"@remix-run/dev": "^2.2.0"}}`
+	svelteKitPackageJSON = `{"scripts": {"build": "vite build"}, "devDependencies": {"@sveltejs/kit": "^1.27.0", "@sveltejs/adapter-node": "^1.3.1"}}`
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  int
+	}{
+		{
+			name:  "nuxt",
+			files: map[string]string{"package.json": nuxtPackageJSON},
+			want:  0,
+		},
+		{
+			name:  "remix",
+			files: map[string]string{"package.json": remixPackageJSON},
+			want:  0,
+		},
+		{
+			name:  "sveltekit",
+			files: map[string]string{"package.json": svelteKitPackageJSON},
+			want:  0,
+		},
+		{
+			name:  "next",
+			files: map[string]string{"package.json": `{"dependencies": {"next": "^13.4.0"}}`},
+			want:  100,
+		},
+		{
+			name:  "no package.json",
+			files: map[string]string{"index.js": ""},
+			want:  100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buildpacktest.TestDetect(t, detectFn, tc.name, tc.files, nil, tc.want)
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name         string
+		files        map[string]string
+		wantOutput   []string
+		wantExitCode int
+	}{
+		{
+			name: "nuxt",
+			files: map[string]string{
+				"package.json":             nuxtPackageJSON,
+				".output/server/index.mjs": "",
+			},
+			wantOutput: []string{`Starting the Nuxt application with ["node" ".output/server/index.mjs"]`},
+		},
+		{
+			name: "remix",
+			files: map[string]string{
+				"package.json":   remixPackageJSON,
+				"build/index.js": "",
+			},
+			wantOutput: []string{`Starting the Remix application with ["remix-serve" "build/index.js"]`},
+		},
+		{
+			name: "sveltekit",
+			files: map[string]string{
+				"package.json":   svelteKitPackageJSON,
+				"build/index.js": "",
+			},
+			wantOutput: []string{`Starting the SvelteKit application with ["node" "build/index.js"]`},
+		},
+		{
+			name: "sveltekit adapter-static",
+			files: map[string]string{
+				"package.json": `{"scripts": {"build": "vite build"}, "devDependencies": {"@sveltejs/kit": "^1.27.0", "@sveltejs/adapter-static": "^2.0.3"}}`,
+			},
+			wantOutput: []string{
+				"SvelteKit is configured with @sveltejs/adapter-static",
+				"Starting the SvelteKit application with the start script.",
+			},
+		},
+		{
+			name:         "nuxt without output",
+			files:        map[string]string{"package.json": nuxtPackageJSON},
+			wantOutput:   []string{".output/server/index.mjs"},
+			wantExitCode: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := buildpacktest.RunBuild(t, buildFn, buildpacktest.WithTestName(tc.name), buildpacktest.WithFiles(tc.files))
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, result: %#v", err, result)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code = %d, want %d", result.ExitCode, tc.wantExitCode)
+			}
+			for _, want := range tc.wantOutput {
+				if !strings.Contains(result.Output, want) {
+					t.Errorf("build output = %q, want it to contain %q", result.Output, want)
+				}
+			}
+		})
+	}
+}
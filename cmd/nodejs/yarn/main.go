@@ -22,15 +22,22 @@ import (
 	"path/filepath"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/ar"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildcommand"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+	"github.com/buildpacks/libcnb"
 )
 
 const (
-	cacheTag  = "prod dependencies"
-	yarnLayer = "yarn_engine"
+	cacheTag       = "prod dependencies"
+	yarnLayer      = "yarn_engine"
+	yarnCacheLayer = "yarn_cache"
+
+	// cacheFormatVersion identifies the layout of the cached yarn_modules layer. Bump it
+	// whenever the way the layer is populated changes.
+	cacheFormatVersion = "v1"
 )
 
 func main() {
@@ -66,9 +73,11 @@ func buildFn(ctx *gcp.Context) error {
 		return fmt.Errorf("installing Yarn: %w", err)
 	}
 
-	if yarn2, err := nodejs.IsYarn2(ctx.ApplicationRoot()); err != nil {
+	yarn2, err := nodejs.IsYarn2(ctx.ApplicationRoot())
+	if err != nil {
 		return err
-	} else if yarn2 {
+	}
+	if yarn2 {
 		if err := yarn2InstallModules(ctx, pjs); err != nil {
 			return err
 		}
@@ -77,6 +86,13 @@ func buildFn(ctx *gcp.Context) error {
 			return err
 		}
 	}
+	if err := nodejs.RemoveModulesCache(ctx); err != nil {
+		return err
+	}
+
+	if err := nodejs.YarnAudit(ctx, yarn2); err != nil {
+		return err
+	}
 
 	el, err := ctx.Layer("env", gcp.BuildLayer, gcp.LaunchLayer)
 	if err != nil {
@@ -84,6 +100,9 @@ func buildFn(ctx *gcp.Context) error {
 	}
 	el.SharedEnvironment.Prepend("PATH", string(os.PathListSeparator), filepath.Join(ctx.ApplicationRoot(), "node_modules", ".bin"))
 	el.SharedEnvironment.Default("NODE_ENV", nodejs.NodeEnv())
+	if err := nodejs.ConfigureSourceMaps(ctx, el); err != nil {
+		return err
+	}
 
 	// Configure the entrypoint for production.
 	cmd := []string{"yarn", "run", "start"}
@@ -119,8 +138,11 @@ func yarn1InstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 	if err := ar.GenerateNPMConfig(ctx); err != nil {
 		return fmt.Errorf("generating Artifact Registry credentials: %w", err)
 	}
+	if err := nodejs.ConfigureNPMRegistry(ctx); err != nil {
+		return fmt.Errorf("configuring npm registry: %w", err)
+	}
 
-	_, err = nodejs.CheckOrClearCache(ctx, ml, cache.WithFiles("package.json", nodejs.YarnLock))
+	_, err = nodejs.CheckOrClearCache(ctx, ml, cache.WithFormatVersion(cacheFormatVersion), cache.WithFiles("package.json", nodejs.YarnLock))
 	if err != nil {
 		return fmt.Errorf("checking cache: %w", err)
 	}
@@ -163,7 +185,9 @@ func yarn1InstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 	if freezeLockfile {
 		cmd = append(cmd, "--frozen-lockfile")
 	}
-	gcpBuild := nodejs.HasGCPBuild(pjs)
+	_, customBuild := buildcommand.Command()
+	buildScript := nodejs.BuildScript(pjs)
+	gcpBuild := buildScript != "" || customBuild
 	if gcpBuild {
 		// Setting --production=false causes the devDependencies to be installed regardless of the
 		// NODE_ENV value. The allows the customer's lifecycle hooks to access to them. We purge the
@@ -173,33 +197,34 @@ func yarn1InstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 
 	// Add the layer's node_modules/.bin to the path so it is available in postinstall scripts.
 	nodeBin := filepath.Join(layerModules, ".bin")
-	if _, err := ctx.Exec(cmd, gcp.WithUserAttribution, gcp.WithEnv(fmt.Sprintf("PATH=%s:%s", os.Getenv("PATH"), nodeBin))); err != nil {
-		return err
+	if result, err := ctx.Exec(cmd, gcp.WithUserAttribution, gcp.WithEnv(fmt.Sprintf("PATH=%s:%s", os.Getenv("PATH"), nodeBin))); err != nil {
+		return nodejs.LockfileError(result, err, nodejs.YarnLock, "yarn install")
 	}
 
 	if gcpBuild {
-		if _, err := ctx.Exec([]string{"yarn", "run", "gcp-build"}, gcp.WithUserAttribution); err != nil {
+		if err := nodejs.RunGCPBuild(ctx, "yarn", buildScript); err != nil {
 			return err
 		}
-
-		// If there was a gcp-build script we installed all the devDependencies above. We should try to
-		// prune them from the final app image.
-		nodeEnv := nodejs.NodeEnv()
-		if nodejs.NodeEnv() != nodejs.EnvProduction {
-			ctx.Logf("Retaining devDependencies because NODE_ENV=%q", nodeEnv)
-		} else {
-			// For Yarn1, setting `--production=true` causes all `devDependencies` to be deleted.
-			ctx.Logf("Pruning devDependencies")
-			cmd := []string{"yarn", "install", "--ignore-scripts", "--prefer-offline", "--production=true", locationFlag}
-			if freezeLockfile {
-				cmd = append(cmd, "--frozen-lockfile")
-			}
-			if _, err := ctx.Exec(cmd, gcp.WithUserAttribution); err != nil {
-				return err
-			}
-		}
 	}
 
+	// If there was a gcp-build script or NODE_ENV is not production we installed all the
+	// devDependencies above. We should try to prune them from the final app image.
+	if !gcpBuild && nodejs.NodeEnv() == nodejs.EnvProduction {
+		return nil
+	}
+	prune, err := nodejs.PruneDevDependencies(ctx)
+	if err != nil || !prune {
+		return err
+	}
+	// For Yarn1, setting `--production=true` causes all `devDependencies` to be deleted.
+	ctx.Logf("Pruning devDependencies")
+	cmd = []string{"yarn", "install", "--ignore-scripts", "--prefer-offline", "--production=true", locationFlag}
+	if freezeLockfile {
+		cmd = append(cmd, "--frozen-lockfile")
+	}
+	if _, err := ctx.Exec(cmd, gcp.WithUserAttribution); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -207,25 +232,66 @@ func yarn2InstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 	if err := ar.GenerateYarnConfig(ctx); err != nil {
 		return fmt.Errorf("generating Artifact Registry credentials: %w", err)
 	}
+	if err := nodejs.ConfigureYarnRegistry(ctx); err != nil {
+		return fmt.Errorf("configuring Yarn registry: %w", err)
+	}
+	rc, err := nodejs.ReadYarnRC(ctx.ApplicationRoot())
+	if err != nil {
+		return err
+	}
 
 	cmd := []string{"yarn", "install", "--immutable"}
-	yarnCacheExists, err := ctx.FileExists(ctx.ApplicationRoot(), ".yarn", "cache")
+	var installEnv []string
+	var cacheLayer *libcnb.Layer
+	yarnCacheExists, err := ctx.FileExists(ctx.ApplicationRoot(), rc.CacheDir())
 	if err != nil {
 		return err
 	}
-	// In Plug'n'Play mode (https://yarnpkg.com/features/pnp) all dependencies must be included in
-	// the Yarn cache. The --immutable-cache option will abort the install with an error if anything
-	// is missing or out of date.
 	if yarnCacheExists {
+		// In zero-install projects (https://yarnpkg.com/features/caching#zero-installs) all
+		// dependencies must be included in the Yarn cache. The --immutable-cache option will abort the
+		// install with an error if anything is missing or out of date.
 		cmd = append(cmd, "--immutable-cache")
+	} else {
+		// Otherwise keep the downloaded archives in a layer across builds. In Plug'n'Play mode the
+		// dependencies are loaded from the archives at run time so the layer is also needed at launch.
+		cl, err := ctx.Layer(yarnCacheLayer, gcp.BuildLayer, gcp.CacheLayer)
+		if err != nil {
+			return fmt.Errorf("creating %v layer: %w", yarnCacheLayer, err)
+		}
+		cl.Launch = rc.IsPnP()
+		installEnv = append(installEnv, "YARN_CACHE_FOLDER="+cl.Path, "YARN_ENABLE_GLOBAL_CACHE=false")
+		if !rc.IsPnP() {
+			cacheLayer = cl
+		}
 	}
-	if _, err := ctx.Exec(cmd, gcp.WithUserAttribution); err != nil {
-		return err
+	if result, err := ctx.Exec(cmd, gcp.WithEnv(installEnv...), gcp.WithUserAttribution); err != nil {
+		return nodejs.LockfileError(result, err, nodejs.YarnLock, "yarn install")
+	}
+	// The cache is only pruned if it is not needed at run time: pruning could delete archives that
+	// Plug'n'Play loads dependencies from.
+	if cacheLayer != nil {
+		if _, err := cache.Prune(ctx, cacheLayer, cache.ByFile); err != nil {
+			return err
+		}
 	}
 
-	// Run the gcp-build script if it exists.
-	if nodejs.HasGCPBuild(pjs) {
-		if _, err := ctx.Exec([]string{"yarn", "run", "gcp-build"}, gcp.WithUserAttribution); err != nil {
+	if rc.IsPnP() {
+		nodeOptions, err := nodejs.PnPNodeOptions(ctx.ApplicationRoot())
+		if err != nil {
+			return err
+		}
+		el, err := ctx.Layer("pnp_env", gcp.BuildLayer, gcp.LaunchLayer)
+		if err != nil {
+			return fmt.Errorf("creating layer: %w", err)
+		}
+		el.SharedEnvironment.Append("NODE_OPTIONS", " ", nodeOptions)
+	}
+
+	// Run the build script if it exists.
+	buildScript := nodejs.BuildScript(pjs)
+	if _, customBuild := buildcommand.Command(); customBuild || buildScript != "" {
+		if err := nodejs.RunGCPBuild(ctx, "yarn", buildScript); err != nil {
 			return err
 		}
 	}
@@ -235,10 +301,9 @@ func yarn2InstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 		return nil
 	}
 
-	nodeEnv := nodejs.NodeEnv()
-	if nodeEnv != nodejs.EnvProduction {
-		ctx.Logf("Retaining devDependencies because NODE_ENV=%q", nodeEnv)
-		return nil
+	prune, err := nodejs.PruneDevDependencies(ctx)
+	if err != nil || !prune {
+		return err
 	}
 	hasWorkPlugin, err := nodejs.HasYarnWorkspacePlugin(ctx)
 	if err != nil {
@@ -250,7 +315,7 @@ func yarn2InstallModules(ctx *gcp.Context, pjs *nodejs.PackageJSON) error {
 	}
 	// For Yarn2, dependency pruning is via the workspaces plugin.
 	ctx.Logf("Pruning devDependencies")
-	if _, err := ctx.Exec([]string{"yarn", "workspaces", "focus", "--all", "--production"}, gcp.WithUserAttribution); err != nil {
+	if _, err := ctx.Exec([]string{"yarn", "workspaces", "focus", "--all", "--production"}, gcp.WithEnv(installEnv...), gcp.WithUserAttribution); err != nil {
 		return err
 	}
 	return nil
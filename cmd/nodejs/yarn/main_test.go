@@ -18,6 +18,8 @@ import (
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/cacheformat"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 )
 
 func TestDetect(t *testing.T) {
@@ -65,3 +67,7 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestCacheFormatVersion(t *testing.T) {
+	cacheformat.Check(t, "testdata/cache_format.golden", cache.WithFormatVersion(cacheFormatVersion), "main.go")
+}
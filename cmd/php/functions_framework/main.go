@@ -54,10 +54,7 @@ func main() {
 }
 
 func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
-	if _, ok := os.LookupEnv(env.FunctionTarget); ok {
-		return gcp.OptInEnvSet(env.FunctionTarget), nil
-	}
-	return gcp.OptOutEnvNotSet(env.FunctionTarget), nil
+	return gcp.DetectFunction(ctx)
 }
 
 func buildFn(ctx *gcp.Context) error {
@@ -87,7 +84,9 @@ func buildFn(ctx *gcp.Context) error {
 		}
 	}
 
-	ctx.AddWebProcess([]string{"/bin/bash", "-c", fmt.Sprintf("php -S 0.0.0.0:${PORT} %s", routerScript)})
+	if err := ctx.AddFunctionWebProcesses([]string{"/bin/bash", "-c", fmt.Sprintf("php -S 0.0.0.0:${PORT} %s", routerScript)}); err != nil {
+		return err
+	}
 
 	l, err := ctx.Layer("functions-framework", gcp.BuildLayer, gcp.LaunchLayer)
 	if err != nil {
@@ -33,7 +33,7 @@ const (
 )
 
 func main() {
-	gcp.Main(detectFn, buildFn)
+	gcp.Main(runtime.WithStackCompatibilityCheck(runtime.PHP, detectFn), buildFn)
 }
 
 func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
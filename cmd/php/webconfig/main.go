@@ -94,7 +94,7 @@ func buildFn(ctx *gcp.Context) error {
 			"--pid1LogFilePath", filepath.Join(l.Path, pid1Log),
 			// Ideally, we should be able to use the path of the nginx layer and not hardcode it here.
 			// This needs some investigation on how to pass values between build steps of buildpacks.
-			"--mimeTypesPath", filepath.Join("/layers/google.utils.nginx/nginx", "conf/mime.types"),
+			"--mimeTypesPath", nginx.MimeTypesPath,
 			"--customAppSocket", filepath.Join(l.Path, appSocket),
 		}
 
@@ -0,0 +1,149 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements python/asgi buildpack.
+// The asgi buildpack starts FastAPI, Starlette and Quart applications with uvicorn when no
+// entrypoint is set, and installs uvicorn if it is missing from requirements.txt.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/procfile"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/python"
+)
+
+const layerName = "uvicorn"
+
+var (
+	// candidates are the files searched for the application object, in order, with the module that
+	// uvicorn imports for each of them.
+	candidates = []struct {
+		file   string
+		module string
+	}{
+		{"main.py", "main"},
+		{"app.py", "app"},
+		{"app/main.py", "app.main"},
+		{"src/main.py", "src.main"},
+	}
+
+	// appRegexp matches the module level assignment of an ASGI application object named app, e.g.
+	// `app = FastAPI()` or `app: Starlette = Starlette(routes=routes)`.
+	appRegexp     = regexp.MustCompile(`(?m)^app\s*(:\s*[\w.]+\s*)?=\s*(fastapi\.|starlette\.applications\.|quart\.)?(FastAPI|Starlette|Quart)\(`)
+	uvicornRegexp = regexp.MustCompile(`(?mi)^uvicorn\b`)
+)
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	if os.Getenv(env.Entrypoint) != "" {
+		return gcp.OptOut("custom entrypoint present"), nil
+	}
+	procExists, err := ctx.FileExists(ctx.ApplicationRoot(), procfile.File)
+	if err != nil {
+		return nil, err
+	}
+	if procExists {
+		return gcp.OptOut("Procfile present"), nil
+	}
+	file, _, err := findApplication(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if file == "" {
+		return gcp.OptOut("no ASGI application object found"), nil
+	}
+	return gcp.OptIn(fmt.Sprintf("found ASGI application in %s", file), gcp.WithBuildPlans(python.RequirementsProvidesPlan)), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	file, app, err := findApplication(ctx)
+	if err != nil {
+		return err
+	}
+	if file == "" {
+		return gcp.UserErrorf("no ASGI application object found, set %s to start the application", env.Entrypoint)
+	}
+
+	hasUvicorn, err := uvicornInRequirements(ctx)
+	if err != nil {
+		return err
+	}
+	if !hasUvicorn {
+		l, err := ctx.Layer(layerName, gcp.BuildLayer)
+		if err != nil {
+			return fmt.Errorf("creating %v layer: %w", layerName, err)
+		}
+		// The pip install is performed by the pip buildpack; see python.InstallRequirements.
+		ctx.Debugf("Adding uvicorn requirements.txt to the list of requirements files to install.")
+		r := filepath.Join(ctx.BuildpackRoot(), "requirements.txt")
+		l.BuildEnvironment.Append(python.RequirementsFilesEnv, string(os.PathListSeparator), r)
+	}
+
+	cmd := serverCommand(app)
+	ctx.Logf("Starting the application in %s with uvicorn: %q", file, cmd)
+	ctx.AddProcess(gcp.WebProcess, []string{cmd}, gcp.AsDefaultProcess())
+	return nil
+}
+
+// findApplication returns the first candidate file that defines an ASGI application object and the
+// import string of the object, e.g. "app.main:app", or empty strings if there is none.
+func findApplication(ctx *gcp.Context) (string, string, error) {
+	for _, c := range candidates {
+		path := filepath.Join(ctx.ApplicationRoot(), c.file)
+		exists, err := ctx.FileExists(path)
+		if err != nil {
+			return "", "", err
+		}
+		if !exists {
+			continue
+		}
+		content, err := ctx.ReadFile(path)
+		if err != nil {
+			return "", "", err
+		}
+		if appRegexp.Match(content) {
+			return c.file, c.module + ":app", nil
+		}
+	}
+	return "", "", nil
+}
+
+// uvicornInRequirements returns whether requirements.txt lists uvicorn.
+func uvicornInRequirements(ctx *gcp.Context) (bool, error) {
+	path := filepath.Join(ctx.ApplicationRoot(), "requirements.txt")
+	exists, err := ctx.FileExists(path)
+	if err != nil || !exists {
+		return false, err
+	}
+	content, err := ctx.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	return uvicornRegexp.Match(content), nil
+}
+
+// serverCommand returns the shell command that starts uvicorn on $PORT with a worker per CPU,
+// unless WEB_CONCURRENCY sets the number of workers.
+func serverCommand(app string) string {
+	return fmt.Sprintf("uvicorn %s --host 0.0.0.0 --port $PORT --workers ${WEB_CONCURRENCY:-$(nproc)}", app)
+}
@@ -0,0 +1,127 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+)
+
+const fastapiApp = `from fastapi import FastAPI
+
+app = FastAPI()
+`
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		envs  []string
+		want  int
+	}{
+		{
+			name:  "fastapi in main.py",
+			files: map[string]string{"main.py": fastapiApp, "requirements.txt": "fastapi"},
+			want:  0,
+		},
+		{
+			name:  "starlette in app/main.py",
+			files: map[string]string{"app/main.py": "app = Starlette(routes=routes)\n"},
+			want:  0,
+		},
+		{
+			name:  "flask",
+			files: map[string]string{"main.py": "app = Flask(__name__)\n"},
+			want:  100,
+		},
+		{
+			name:  "entrypoint set",
+			files: map[string]string{"main.py": fastapiApp},
+			envs:  []string{"GOOGLE_ENTRYPOINT=uvicorn main:app --port $PORT"},
+			want:  100,
+		},
+		{
+			name:  "Procfile",
+			files: map[string]string{"main.py": fastapiApp, "Procfile": "web: uvicorn main:app"},
+			want:  100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buildpacktest.TestDetect(t, detectFn, tc.name, tc.files, tc.envs, tc.want)
+		})
+	}
+}
+
+func TestAppRegexp(t *testing.T) {
+	testCases := []struct {
+		content string
+		want    bool
+	}{
+		{content: "app = FastAPI()", want: true},
+		{content: "app = fastapi.FastAPI(title=\"api\")", want: true},
+		{content: "app: FastAPI = FastAPI()", want: true},
+		{content: "app = Quart(__name__)", want: true},
+		{content: "import os\napp = Starlette(debug=True)\n", want: true},
+		{content: "    app = FastAPI()", want: false},
+		{content: "api = FastAPI()", want: false},
+		{content: "app = Flask(__name__)", want: false},
+	}
+	for _, tc := range testCases {
+		if got := appRegexp.MatchString(tc.content); got != tc.want {
+			t.Errorf("appRegexp.MatchString(%q) = %t, want %t", tc.content, got, tc.want)
+		}
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name       string
+		files      map[string]string
+		wantOutput []string
+	}{
+		{
+			name:  "main.py without uvicorn",
+			files: map[string]string{"main.py": fastapiApp, "requirements.txt": "fastapi\n"},
+			wantOutput: []string{
+				"Adding uvicorn requirements.txt",
+				"uvicorn main:app --host 0.0.0.0 --port $PORT --workers ${WEB_CONCURRENCY:-$(nproc)}",
+			},
+		},
+		{
+			name:       "app package with uvicorn",
+			files:      map[string]string{"app/main.py": fastapiApp, "requirements.txt": "fastapi\nuvicorn[standard]==0.23.2\n"},
+			wantOutput: []string{"uvicorn app.main:app --host 0.0.0.0"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := buildpacktest.RunBuild(t, buildFn, buildpacktest.WithTestName(tc.name), buildpacktest.WithFiles(tc.files))
+			if err != nil {
+				t.Fatalf("error running build: %v, result: %#v", err, result)
+			}
+			for _, want := range tc.wantOutput {
+				if !strings.Contains(result.Output, want) {
+					t.Errorf("build output = %q, want it to contain %q", result.Output, want)
+				}
+			}
+			if strings.Contains(tc.files["requirements.txt"], "uvicorn") && strings.Contains(result.Output, "Adding uvicorn requirements.txt") {
+				t.Errorf("build output = %q, want uvicorn from requirements.txt to be used", result.Output)
+			}
+		})
+	}
+}
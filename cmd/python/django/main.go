@@ -0,0 +1,198 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements python/django buildpack.
+// The django buildpack collects the static files of a Django project and starts it with gunicorn,
+// using the uvicorn worker for ASGI projects, when no entrypoint is set. gunicorn is installed by
+// python/webserver when it is not in requirements.txt.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/procfile"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/python"
+)
+
+const (
+	managePy = "manage.py"
+	// threads is the number of threads of each gunicorn worker of a WSGI application.
+	threads = 8
+)
+
+var (
+	// settingsModuleRegexp matches the default settings module set by manage.py, e.g.
+	// os.environ.setdefault("DJANGO_SETTINGS_MODULE", "mysite.settings").
+	settingsModuleRegexp = regexp.MustCompile(`DJANGO_SETTINGS_MODULE['"]\s*,\s*['"]([\w.]+)['"]`)
+	uvicornRegexp        = regexp.MustCompile(`(?mi)^uvicorn\b`)
+	// gunicornRegexp matches gunicorn in a requirements file, but not packages such as
+	// gunicorn-worker.
+	gunicornRegexp = regexp.MustCompile(`(?mi)^gunicorn\b([^-]|$)`)
+)
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	manageExists, err := ctx.FileExists(ctx.ApplicationRoot(), managePy)
+	if err != nil {
+		return nil, err
+	}
+	if !manageExists {
+		return gcp.OptOutFileNotFound(managePy), nil
+	}
+	return gcp.OptInFileFound(managePy), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	collectStatic, err := env.IsPresentAndTrue(env.DjangoCollectStatic)
+	if err != nil {
+		return gcp.UserErrorf("parsing %s: %v", env.DjangoCollectStatic, err)
+	}
+	if collectStatic {
+		ctx.Logf("Collecting static files.")
+		if _, err := ctx.Exec([]string{"python3", managePy, "collectstatic", "--noinput"}, gcp.WithUserAttribution); err != nil {
+			return err
+		}
+	}
+
+	custom, err := hasCustomEntrypoint(ctx)
+	if err != nil {
+		return err
+	}
+	if custom {
+		ctx.Debugf("Using the custom entrypoint of the application.")
+		return nil
+	}
+	module, asgi, err := applicationModule(ctx)
+	if err != nil {
+		return err
+	}
+	if module == "" {
+		ctx.Logf("No wsgi.py or asgi.py found for the settings module of %s, set %s to start the application.", managePy, env.Entrypoint)
+		return nil
+	}
+	gunicorn, err := gunicornInstalled(ctx)
+	if err != nil {
+		return err
+	}
+	if !gunicorn {
+		return gcp.UserErrorf("gunicorn is needed to start the application but it is not installed, add gunicorn to requirements.txt or set %s to start the application", env.Entrypoint)
+	}
+	cmd := serverCommand(module, asgi)
+	ctx.Logf("Starting the application with gunicorn: %q", cmd)
+	ctx.AddProcess(gcp.WebProcess, []string{cmd}, gcp.AsDefaultProcess())
+	return nil
+}
+
+// hasCustomEntrypoint returns whether the entrypoint is set with GOOGLE_ENTRYPOINT or a Procfile,
+// which the entrypoint buildpack uses instead of the generated one.
+func hasCustomEntrypoint(ctx *gcp.Context) (bool, error) {
+	if os.Getenv(env.Entrypoint) != "" {
+		return true, nil
+	}
+	return ctx.FileExists(ctx.ApplicationRoot(), procfile.File)
+}
+
+// applicationModule returns the Python module of the application callable of the project, and
+// whether it is an ASGI application. ASGI is used when the project has an asgi.py and uvicorn is in
+// requirements.txt, WSGI otherwise. The module is empty if the project has neither.
+func applicationModule(ctx *gcp.Context) (string, bool, error) {
+	content, err := ctx.ReadFile(filepath.Join(ctx.ApplicationRoot(), managePy))
+	if err != nil {
+		return "", false, err
+	}
+	m := settingsModuleRegexp.FindStringSubmatch(string(content))
+	if m == nil {
+		return "", false, nil
+	}
+	// The settings module may be a package, e.g. mysite.settings.production, the project is the
+	// top-level package that holds the settings, wsgi.py and asgi.py.
+	project := strings.SplitN(m[1], ".", 2)[0]
+	asgiExists, err := ctx.FileExists(ctx.ApplicationRoot(), project, "asgi.py")
+	if err != nil {
+		return "", false, err
+	}
+	if asgiExists {
+		uvicorn, err := requirementsContain(ctx, uvicornRegexp)
+		if err != nil {
+			return "", false, err
+		}
+		if uvicorn {
+			return project + ".asgi", true, nil
+		}
+	}
+	wsgiExists, err := ctx.FileExists(ctx.ApplicationRoot(), project, "wsgi.py")
+	if err != nil {
+		return "", false, err
+	}
+	if wsgiExists {
+		return project + ".wsgi", false, nil
+	}
+	return "", false, nil
+}
+
+// requirementsContain returns whether requirements.txt matches the regexp.
+func requirementsContain(ctx *gcp.Context, re *regexp.Regexp) (bool, error) {
+	return fileMatches(ctx, filepath.Join(ctx.ApplicationRoot(), "requirements.txt"), re)
+}
+
+// gunicornInstalled returns whether gunicorn is in requirements.txt or in a requirements file
+// added by a previous buildpack, such as python/webserver, which installs it when it is missing.
+func gunicornInstalled(ctx *gcp.Context) (bool, error) {
+	paths := []string{filepath.Join(ctx.ApplicationRoot(), "requirements.txt")}
+	if files := os.Getenv(python.RequirementsFilesEnv); files != "" {
+		paths = append(paths, filepath.SplitList(files)...)
+	}
+	for _, path := range paths {
+		found, err := fileMatches(ctx, path, gunicornRegexp)
+		if err != nil || found {
+			return found, err
+		}
+	}
+	return false, nil
+}
+
+// fileMatches returns whether the file exists and matches the regexp.
+func fileMatches(ctx *gcp.Context, path string, re *regexp.Regexp) (bool, error) {
+	exists, err := ctx.FileExists(path)
+	if err != nil || !exists {
+		return false, err
+	}
+	content, err := ctx.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	return re.Match(content), nil
+}
+
+// serverCommand returns the shell command that starts gunicorn on $PORT. It runs a single worker
+// unless WEB_CONCURRENCY is set, WSGI workers handle concurrent requests with threads and ASGI
+// workers with the uvicorn event loop. The timeout is disabled to let the platform enforce it.
+func serverCommand(module string, asgi bool) string {
+	cmd := "gunicorn --bind :$PORT --workers ${WEB_CONCURRENCY:-1}"
+	if asgi {
+		cmd += " --worker-class uvicorn.workers.UvicornWorker"
+	} else {
+		cmd += fmt.Sprintf(" --threads %d", threads)
+	}
+	return fmt.Sprintf("%s --timeout 0 %s:application", cmd, module)
+}
@@ -0,0 +1,189 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/python"
+)
+
+const manage = `import os
+import sys
+
+def main():
+    os.environ.setdefault('DJANGO_SETTINGS_MODULE', 'mysite.settings')
+`
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  int
+	}{
+		{
+			name:  "manage.py",
+			files: map[string]string{"manage.py": manage, "requirements.txt": "Django"},
+			want:  0,
+		},
+		{
+			name:  "no manage.py",
+			files: map[string]string{"main.py": "", "requirements.txt": "flask"},
+			want:  100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buildpacktest.TestDetect(t, detectFn, tc.name, tc.files, nil, tc.want)
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name            string
+		files           map[string]string
+		envs            []string
+		wantCommands    []string
+		skippedCommands []string
+		// webserverRequirements is the content of a requirements file added by a previous buildpack.
+		webserverRequirements string
+		wantOutput            string
+		wantErr               bool
+	}{
+		{
+			name: "wsgi",
+			files: map[string]string{
+				"manage.py":        manage,
+				"mysite/wsgi.py":   "",
+				"mysite/asgi.py":   "",
+				"requirements.txt": "Django==4.2\ngunicorn==22.0.0\n",
+			},
+			skippedCommands: []string{"collectstatic"},
+			wantOutput:      "gunicorn --bind :$PORT --workers ${WEB_CONCURRENCY:-1} --threads 8 --timeout 0 mysite.wsgi:application",
+		},
+		{
+			name: "asgi with uvicorn",
+			files: map[string]string{
+				"manage.py":        manage,
+				"mysite/wsgi.py":   "",
+				"mysite/asgi.py":   "",
+				"requirements.txt": "Django==4.2\ngunicorn\nuvicorn[standard]\n",
+			},
+			wantOutput: "gunicorn --bind :$PORT --workers ${WEB_CONCURRENCY:-1} --worker-class uvicorn.workers.UvicornWorker --timeout 0 mysite.asgi:application",
+		},
+		{
+			name: "settings package",
+			files: map[string]string{
+				"manage.py":        strings.Replace(manage, "mysite.settings", "mysite.settings.production", 1),
+				"mysite/wsgi.py":   "",
+				"requirements.txt": "Django\ngunicorn\n",
+			},
+			wantOutput: "mysite.wsgi:application",
+		},
+		{
+			name: "collectstatic",
+			files: map[string]string{
+				"manage.py":        manage,
+				"mysite/wsgi.py":   "",
+				"requirements.txt": "Django\ngunicorn\n",
+			},
+			envs:         []string{"GOOGLE_DJANGO_COLLECTSTATIC=true"},
+			wantCommands: []string{"python3 manage.py collectstatic --noinput"},
+			wantOutput:   "mysite.wsgi:application",
+		},
+		{
+			name: "gunicorn installed by the webserver buildpack",
+			files: map[string]string{
+				"manage.py":        manage,
+				"mysite/wsgi.py":   "",
+				"requirements.txt": "Django\n",
+			},
+			webserverRequirements: "gunicorn==20.1.0\n",
+			wantOutput:            "mysite.wsgi:application",
+		},
+		{
+			name: "gunicorn not installed",
+			files: map[string]string{
+				"manage.py":        manage,
+				"mysite/wsgi.py":   "",
+				"requirements.txt": "Django\ngunicorn-worker\n",
+			},
+			wantErr: true,
+		},
+		{
+			name: "custom entrypoint",
+			files: map[string]string{
+				"manage.py":      manage,
+				"mysite/wsgi.py": "",
+			},
+			envs:       []string{"GOOGLE_ENTRYPOINT=gunicorn mysite.wsgi"},
+			wantOutput: "",
+		},
+		{
+			name:       "no wsgi.py",
+			files:      map[string]string{"manage.py": manage},
+			wantOutput: "No wsgi.py or asgi.py found",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			envs := tc.envs
+			if tc.webserverRequirements != "" {
+				path := filepath.Join(t.TempDir(), "requirements.txt")
+				if err := os.WriteFile(path, []byte(tc.webserverRequirements), 0644); err != nil {
+					t.Fatalf("writing %s: %v", path, err)
+				}
+				envs = append(envs, python.RequirementsFilesEnv+"="+path)
+			}
+			opts := []buildpacktest.Option{
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithFiles(tc.files),
+				buildpacktest.WithEnvs(envs...),
+				buildpacktest.WithExecMocks(mockprocess.New("collectstatic")),
+			}
+
+			result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("RunBuild() got error: %v, want error: %v, result: %#v", err, tc.wantErr, result)
+			}
+			if tc.wantErr {
+				return
+			}
+
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not", cmd)
+				}
+			}
+			for _, cmd := range tc.skippedCommands {
+				if result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to not be executed, but it was", cmd)
+				}
+			}
+			if !strings.Contains(result.Output, tc.wantOutput) {
+				t.Errorf("build output = %q, want it to contain %q", result.Output, tc.wantOutput)
+			}
+			if tc.wantOutput == "" && strings.Contains(result.Output, "gunicorn") {
+				t.Errorf("build output = %q, want no generated entrypoint", result.Output)
+			}
+		})
+	}
+}
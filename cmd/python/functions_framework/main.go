@@ -22,9 +22,12 @@ import (
 	"path/filepath"
 	"regexp"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cloudfunctions"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/python"
+	"github.com/buildpacks/libcnb"
 )
 
 const (
@@ -41,10 +44,7 @@ func main() {
 }
 
 func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
-	if _, ok := os.LookupEnv(env.FunctionTarget); ok {
-		return gcp.OptInEnvSet(env.FunctionTarget, gcp.WithBuildPlans(python.RequirementsProvidesPlan)), nil
-	}
-	return gcp.OptOutEnvNotSet(env.FunctionTarget), nil
+	return gcp.DetectFunction(ctx, gcp.WithBuildPlans(python.RequirementsProvidesPlan))
 }
 
 func buildFn(ctx *gcp.Context) error {
@@ -78,6 +78,7 @@ func buildFn(ctx *gcp.Context) error {
 	}
 	if hasFrameworkDependency {
 		ctx.Logf("Handling functions with dependency on functions-framework.")
+		cloudfunctions.WarnIfFrameworkVersionIgnored(ctx, "requirements.txt")
 		if err := ctx.ClearLayer(l); err != nil {
 			return fmt.Errorf("clearing layer %q: %w", l.Name, err)
 		}
@@ -86,17 +87,46 @@ func buildFn(ctx *gcp.Context) error {
 
 		// The pip install is performed by the pip buildpack; see python.InstallRequirements.
 		ctx.Debugf("Adding functions-framework requirements.txt to the list of requirements files to install.")
-		r := filepath.Join(ctx.BuildpackRoot(), "converter", "requirements.txt")
+		r, err := frameworkRequirements(ctx, l)
+		if err != nil {
+			return err
+		}
 		l.BuildEnvironment.Append(python.RequirementsFilesEnv, string(os.PathListSeparator), r)
 	}
 
 	if err := ctx.SetFunctionsEnvVars(l); err != nil {
 		return err
 	}
-	ctx.AddWebProcess([]string{"functions-framework"})
+	if err := ctx.AddFunctionWebProcesses([]string{"functions-framework"}); err != nil {
+		return err
+	}
 	return nil
 }
 
+// frameworkRequirements returns the requirements file that installs the functions-framework: the
+// one of the converter, which pins the framework and its dependencies, or one written to the layer
+// for the version selected by GOOGLE_FUNCTIONS_FRAMEWORK_VERSION.
+func frameworkRequirements(ctx *gcp.Context, l *libcnb.Layer) (string, error) {
+	version, err := cloudfunctions.FrameworkVersion(ctx, "python")
+	if err != nil {
+		return "", err
+	}
+	var req string
+	switch version {
+	case cloudfunctions.FrameworkStable:
+		return filepath.Join(ctx.BuildpackRoot(), "converter", "requirements.txt"), nil
+	case cloudfunctions.FrameworkLatest:
+		req = "functions-framework\n"
+	default:
+		req = fmt.Sprintf("functions-framework==%s\n", version)
+	}
+	r := filepath.Join(l.Path, "requirements.txt")
+	if err := ctx.WriteFile(r, []byte(req), 0644); err != nil {
+		return "", err
+	}
+	return r, nil
+}
+
 func validateSource(ctx *gcp.Context) error {
 	// Fail if the default|custom source file doesn't exist, otherwise the app will fail at runtime but still build here.
 	fnSource, ok := os.LookupEnv(env.FunctionSource)
@@ -106,7 +136,7 @@ func validateSource(ctx *gcp.Context) error {
 			return err
 		}
 		if !mainPYExists {
-			return gcp.UserErrorf("missing main.py and %s not specified. Either create the function in main.py or specify %s to point to the file that contains the function", env.FunctionSource, env.FunctionSource)
+			return gcp.UserErrorf("missing main.py and %s not specified. Either create the function in main.py or specify %s to point to the file that contains the function", env.FunctionSource, env.FunctionSource).WithCode(buildererror.CodePythonMissingMain)
 		}
 	} else {
 		fnSourceExists, err := ctx.FileExists(fnSource)
@@ -114,7 +144,7 @@ func validateSource(ctx *gcp.Context) error {
 			return err
 		}
 		if !fnSourceExists {
-			return gcp.UserErrorf("%s specified file %q but it does not exist", env.FunctionSource, fnSource)
+			return gcp.UserErrorf("%s specified file %q but it does not exist", env.FunctionSource, fnSource).WithCode(buildererror.CodePythonMissingMain)
 		}
 	}
 	return nil
@@ -15,9 +15,14 @@
 package main
 
 import (
+	"io/ioutil"
+	"path/filepath"
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
 )
 
 func TestContainsFF(t *testing.T) {
@@ -92,3 +97,54 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestFrameworkRequirements(t *testing.T) {
+	testCases := []struct {
+		name        string
+		version     string
+		wantContent string
+	}{
+		{
+			name: "stable",
+		},
+		{
+			name:        "latest",
+			version:     "latest",
+			wantContent: "functions-framework\n",
+		},
+		{
+			name:        "pinned",
+			version:     "3.4.0",
+			wantContent: "functions-framework==3.4.0\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(env.FunctionsFrameworkVersion, tc.version)
+			bpRoot := t.TempDir()
+			ctx := gcp.NewContext(gcp.WithBuildpackRoot(bpRoot))
+			l := &libcnb.Layer{Path: t.TempDir()}
+
+			got, err := frameworkRequirements(ctx, l)
+			if err != nil {
+				t.Fatalf("frameworkRequirements() got error: %v", err)
+			}
+			if tc.wantContent == "" {
+				if want := filepath.Join(bpRoot, "converter", "requirements.txt"); got != want {
+					t.Errorf("frameworkRequirements() = %q, want %q", got, want)
+				}
+				return
+			}
+			if want := filepath.Join(l.Path, "requirements.txt"); got != want {
+				t.Errorf("frameworkRequirements() = %q, want %q", got, want)
+			}
+			b, err := ioutil.ReadFile(got)
+			if err != nil {
+				t.Fatalf("reading %s: %v", got, err)
+			}
+			if string(b) != tc.wantContent {
+				t.Errorf("%s content = %q, want %q", got, b, tc.wantContent)
+			}
+		})
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/python"
 	"github.com/buildpacks/libcnb"
@@ -79,6 +80,14 @@ func buildFn(ctx *gcp.Context) error {
 	if err := python.InstallRequirements(ctx, l, reqs...); err != nil {
 		return fmt.Errorf("installing dependencies: %w", err)
 	}
+	if sbom, err := python.SBOMEntries(l.Path); err != nil {
+		ctx.Warnf("Failed to list installed packages, skipping SBOM entries: %v", err)
+	} else {
+		ctx.AddSBOMEntries(l, sbom...)
+		if err := python.Audit(ctx, sbom); err != nil {
+			return err
+		}
+	}
 
 	ctx.Logf("Checking for incompatible dependencies.")
 	result, err := ctx.Exec([]string{"python3", "-m", "pip", "check"}, gcp.WithUserAttribution)
@@ -97,6 +106,6 @@ func buildFn(ctx *gcp.Context) error {
 		ctx.Warnf("Found incompatible dependencies: %q", result.Stdout)
 		return nil
 	}
-	return gcp.UserErrorf("found incompatible dependencies: %q", result.Stdout)
+	return gcp.UserErrorf("found incompatible dependencies: %q", result.Stdout).WithCode(buildererror.CodePythonDependencyConflict)
 
 }
@@ -0,0 +1,175 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements python/pipenv buildpack.
+// The pipenv buildpack installs dependencies from Pipfile.lock using pipenv.
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/ar"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/python"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	pipenvLayer = "pipenv"
+	venvLayer   = "venv"
+
+	pipfile     = "Pipfile"
+	pipfileLock = "Pipfile.lock"
+
+	// pipenvVersion is the version of pipenv used to install dependencies.
+	pipenvVersion = "2023.9.8"
+
+	versionKey      = "version"
+	dependenciesKey = "dependencies"
+
+	// cacheFormatVersion identifies the layout of the cached venv layer. Bump it whenever the way
+	// the layer is populated changes.
+	cacheFormatVersion = "v1"
+)
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	pipfileExists, err := ctx.FileExists(pipfile)
+	if err != nil {
+		return nil, err
+	}
+	if !pipfileExists {
+		return gcp.OptOutFileNotFound(pipfile), nil
+	}
+	return gcp.OptInFileFound(pipfile), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	lockExists, err := ctx.FileExists(pipfileLock)
+	if err != nil {
+		return err
+	}
+	if !lockExists {
+		return gcp.UserErrorf("%s not found, run `pipenv lock` and include %s in the source to install dependencies with pipenv", pipfileLock, pipfileLock)
+	}
+
+	vl, err := ctx.Layer(venvLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", venvLayer, err)
+	}
+	// The VIRTUAL_ENV variable is usually set by the virtual environment's activate script, the
+	// layer bin directory is added to PATH by lifecycle.
+	vl.SharedEnvironment.Override("VIRTUAL_ENV", vl.Path)
+
+	pipenv, userBase, err := installPipenv(ctx)
+	if err != nil {
+		return fmt.Errorf("installing pipenv: %w", err)
+	}
+
+	pyVer, err := python.Version(ctx)
+	if err != nil {
+		return err
+	}
+	hit, key, err := cache.CheckCache(ctx, vl, cache.WithFormatVersion(cacheFormatVersion), dependenciesKey, cache.WithStrings(pyVer), cache.WithFiles(pipfileLock))
+	if err != nil {
+		return fmt.Errorf("checking cache: %w", err)
+	}
+	if hit {
+		ctx.CacheHit(venvLayer)
+		ctx.Logf("Dependencies cache hit, skipping installation.")
+		return addSBOMEntries(ctx, vl)
+	}
+	ctx.CacheMiss(venvLayer)
+	if err := ctx.ClearLayer(vl); err != nil {
+		return fmt.Errorf("clearing layer %q: %w", venvLayer, err)
+	}
+
+	if err := ar.GeneratePythonConfig(ctx); err != nil {
+		return fmt.Errorf("generating Artifact Registry credentials: %w", err)
+	}
+
+	if _, err := ctx.Exec([]string{"python3", "-m", "venv", vl.Path}, gcp.WithUserTimingAttribution); err != nil {
+		return err
+	}
+	ctx.Logf("Installing application dependencies.")
+	// pipenv installs into the active virtual environment. --deploy fails the build if Pipfile.lock
+	// is out of date with Pipfile.
+	if _, err := ctx.Exec([]string{pipenv, "install", "--deploy"},
+		gcp.WithEnv("VIRTUAL_ENV="+vl.Path, userBase, "PIPENV_VERBOSITY=-1", "PIPENV_NOSPIN=1"),
+		gcp.WithUserAttribution); err != nil {
+		return err
+	}
+
+	ctx.SetMetadata(vl, dependenciesKey, key)
+	return addSBOMEntries(ctx, vl)
+}
+
+// addSBOMEntries records the packages installed in the virtual environment in the layer SBOM and
+// audits them if GOOGLE_PYTHON_AUDIT is set.
+func addSBOMEntries(ctx *gcp.Context, vl *libcnb.Layer) error {
+	sbom, err := python.SBOMEntries(vl.Path)
+	if err != nil {
+		ctx.Warnf("Failed to list installed packages, skipping SBOM entries: %v", err)
+		return nil
+	}
+	ctx.AddSBOMEntries(vl, sbom...)
+	return python.Audit(ctx, sbom)
+}
+
+// installPipenv installs pipenv in a build-only layer if it is not already cached. It returns the
+// path of the pipenv executable and the PYTHONUSERBASE setting that pipenv must be run with.
+func installPipenv(ctx *gcp.Context) (string, string, error) {
+	pl, err := ctx.Layer(pipenvLayer, gcp.BuildLayer, gcp.CacheLayer)
+	if err != nil {
+		return "", "", fmt.Errorf("creating %v layer: %w", pipenvLayer, err)
+	}
+	// pipenv is installed into the user site-packages of the layer rather than into the virtual
+	// environment of the application so that it is not part of the final image.
+	userBase := "PYTHONUSERBASE=" + pl.Path
+	pipenv := filepath.Join(pl.Path, "bin", "pipenv")
+
+	if ctx.GetMetadata(pl, versionKey) == pipenvVersion {
+		ctx.CacheHit(pipenvLayer)
+	} else {
+		ctx.CacheMiss(pipenvLayer)
+		if err := ctx.ClearLayer(pl); err != nil {
+			return "", "", fmt.Errorf("clearing layer %q: %w", pipenvLayer, err)
+		}
+		ctx.Logf("Installing pipenv v%s", pipenvVersion)
+		cmd := []string{
+			"python3", "-m", "pip", "install",
+			"--user",
+			"--no-warn-script-location",
+			"--disable-pip-version-check",
+			"--no-cache-dir",
+			fmt.Sprintf("pipenv==%s", pipenvVersion),
+		}
+		if _, err := ctx.Exec(cmd, gcp.WithEnv(userBase), gcp.WithUserTimingAttribution); err != nil {
+			return "", "", err
+		}
+	}
+
+	ctx.SetMetadata(pl, versionKey, pipenvVersion)
+	ctx.AddBOMEntry(libcnb.BOMEntry{
+		Name:     pipenvLayer,
+		Metadata: map[string]interface{}{"version": pipenvVersion},
+		Build:    true,
+	})
+	return pipenv, userBase, nil
+}
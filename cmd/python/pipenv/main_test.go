@@ -0,0 +1,127 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/cacheformat"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  int
+	}{
+		{
+			name: "Pipfile",
+			files: map[string]string{
+				"main.py": "",
+				"Pipfile": "",
+			},
+			want: 0,
+		},
+		{
+			name: "Pipfile and Pipfile.lock",
+			files: map[string]string{
+				"main.py":      "",
+				"Pipfile":      "",
+				"Pipfile.lock": "",
+			},
+			want: 0,
+		},
+		{
+			name: "requirements file",
+			files: map[string]string{
+				"main.py":          "",
+				"requirements.txt": "",
+			},
+			want: 100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buildpacktest.TestDetect(t, detectFn, tc.name, tc.files, []string{}, tc.want)
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name            string
+		files           map[string]string
+		wantExitCode    int
+		wantCommands    []string
+		skippedCommands []string
+	}{
+		{
+			name: "install dependencies",
+			files: map[string]string{
+				"Pipfile":      "",
+				"Pipfile.lock": "{}",
+			},
+			wantCommands: []string{
+				"python3 -m pip install --user .*pipenv==",
+				"python3 -m venv",
+				"pipenv install --deploy",
+			},
+		},
+		{
+			name: "missing Pipfile.lock",
+			files: map[string]string{
+				"Pipfile": "",
+			},
+			wantExitCode:    1,
+			skippedCommands: []string{"pipenv install"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []buildpacktest.Option{
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithFiles(tc.files),
+				// Installing pipenv from PyPI is mocked out.
+				buildpacktest.WithExecMocks(mockprocess.New("python3 -m pip install")),
+			}
+
+			result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, result: %#v", err, result)
+			}
+
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not", cmd)
+				}
+			}
+			for _, cmd := range tc.skippedCommands {
+				if result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to not be executed, but it was", cmd)
+				}
+			}
+		})
+	}
+}
+
+func TestCacheFormatVersion(t *testing.T) {
+	cacheformat.Check(t, "testdata/cache_format.golden", cache.WithFormatVersion(cacheFormatVersion), "main.go")
+}
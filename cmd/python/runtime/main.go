@@ -22,6 +22,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/python"
@@ -104,6 +105,13 @@ func buildFn(ctx *gcp.Context) error {
 		return err
 	}
 
+	// In dev mode the source changes without a rebuild, so it is not precompiled.
+	if !devmode.Enabled(ctx) {
+		if err := python.CompileApplication(ctx, path, layer); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
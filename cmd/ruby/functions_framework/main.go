@@ -48,10 +48,7 @@ func main() {
 }
 
 func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
-	if _, ok := os.LookupEnv(env.FunctionTarget); ok {
-		return gcp.OptInEnvSet(env.FunctionTarget), nil
-	}
-	return gcp.OptOutEnvNotSet(env.FunctionTarget), nil
+	return gcp.DetectFunction(ctx)
 }
 
 func buildFn(ctx *gcp.Context) error {
@@ -74,17 +71,21 @@ func buildFn(ctx *gcp.Context) error {
 		return err
 	}
 	if version.GreaterThan(validateTargetVersion) || version.Equal(validateTargetVersion) {
-		if err := validateTarget(ctx, source); err != nil {
+		targets, err := ctx.FunctionTargets()
+		if err != nil {
 			return err
 		}
+		for _, target := range targets {
+			if err := validateTarget(ctx, source, target); err != nil {
+				return err
+			}
+		}
 	}
 	if version.LessThan(recommendedVersion) {
 		ctx.Warnf("Found a deprecated version of functions-framework (%s); consider updating your Gemfile to use functions_framework %s or later.", version, recommendedVersion)
 	}
 
-	ctx.AddWebProcess([]string{"bundle", "exec", "functions-framework-ruby"})
-
-	return nil
+	return ctx.AddFunctionWebProcesses([]string{"bundle", "exec", "functions-framework-ruby"})
 }
 
 // validateSource validates the existence of and returns the source file
@@ -129,14 +130,15 @@ func frameworkVersion(ctx *gcp.Context) (*semver.Version, error) {
 }
 
 // validateTarget validates that the given target is defined and can be executed
-func validateTarget(ctx *gcp.Context, source string) error {
-	target := os.Getenv(env.FunctionTarget)
-	cmd := []string{"bundle", "exec", "functions-framework-ruby", "--quiet", "--verify", "--source", source, "--target", target}
-	if fnSig, ok := os.LookupEnv(env.FunctionSignatureType); ok {
+func validateTarget(ctx *gcp.Context, source string, target gcp.FunctionTarget) error {
+	cmd := []string{"bundle", "exec", "functions-framework-ruby", "--quiet", "--verify", "--source", source, "--target", target.Target}
+	if target.SignatureType != "" {
+		cmd = append(cmd, "--signature-type", target.SignatureType)
+	} else if fnSig, ok := os.LookupEnv(env.FunctionSignatureType); ok {
 		cmd = append(cmd, "--signature-type", fnSig)
 	}
 	if result, err := ctx.Exec(cmd, gcp.WithEnv("MALLOC_ARENA_MAX=2", "LANG=C.utf8", "RACK_ENV=production"), gcp.WithUserAttribution); err != nil {
-		return gcp.UserErrorf("failed to verify function target %q in source %q: %s", target, source, result.Stderr)
+		return gcp.UserErrorf("failed to verify function target %q in source %q: %s", target.Target, source, result.Stderr)
 	}
 	return nil
 }
@@ -45,5 +45,5 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 }
 
 func buildFn(ctx *gcp.Context) error {
-	return fmt.Errorf("for Ruby, an entrypoint must be manually set, either with %q env var or by creating a %q file", env.Entrypoint, "Procfile")
+	return fmt.Errorf("for Ruby, an entrypoint must be manually set, either with %q env var or by creating a %q file, or an application server must be selected with %q env var", env.Entrypoint, "Procfile", env.RubyServer)
 }
@@ -13,19 +13,34 @@
 // limitations under the License.
 
 // Implements ruby/rails buildpack.
-// The rails buildpack precompiles assets using Rails.
+// The rails buildpack precompiles assets and bootsnap caches, and sets the environment Rails needs
+// to serve static files and log to stdout in a container.
 package main
 
 import (
 	"fmt"
+	"path/filepath"
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/ruby"
+	"github.com/buildpacks/libcnb"
 )
 
 const (
-	yarnLayer = "yarn"
+	yarnLayer   = "yarn"
+	cacheLayer  = "rails_cache"
+	launchLayer = "rails"
+
+	// assetsCacheDir is where sprockets caches compiled assets between precompilations.
+	assetsCacheDir = "tmp/cache/assets"
+)
+
+var (
+	precompileEnv = []string{"RAILS_ENV=production", "MALLOC_ARENA_MAX=2", "RAILS_LOG_TO_STDOUT=true", "LANG=C.utf8"}
+
+	// bootsnapDirs are the application directories precompiled by bootsnap, if they exist.
+	bootsnapDirs = []string{"app", "lib"}
 )
 
 func main() {
@@ -40,46 +55,153 @@ func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
 	if !railsExists {
 		return gcp.OptOutFileNotFound("bin/rails"), nil
 	}
+	return gcp.OptInFileFound("bin/rails"), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
 	needsPrecompile, err := ruby.NeedsRailsAssetPrecompile(ctx)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if needsPrecompile {
+		if err := precompileAssets(ctx); err != nil {
+			return err
+		}
+	} else {
+		ctx.Logf("Rails assets do not need precompilation.")
+	}
+
+	usesBootsnap, err := hasBootsnap(ctx)
+	if err != nil {
+		return err
 	}
-	if !needsPrecompile {
-		return gcp.OptOut("Rails assets do not need precompilation"), nil
+	if usesBootsnap {
+		precompileBootsnap(ctx)
 	}
-	return gcp.OptIn("found Rails assets to precompile"), nil
+
+	l, err := ctx.Layer(launchLayer, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", launchLayer, err)
+	}
+	// Rails only serves public/ and logs to log/production.log by default, which are handled by a
+	// reverse proxy and lost respectively in a container.
+	l.LaunchEnvironment.Default("RAILS_SERVE_STATIC_FILES", "true")
+	l.LaunchEnvironment.Default("RAILS_LOG_TO_STDOUT", "true")
+	return nil
 }
 
-func buildFn(ctx *gcp.Context) error {
+func precompileAssets(ctx *gcp.Context) error {
 	ctx.Logf("Running Rails asset precompilation")
 
-	// Install Yarn as it is needed for asset precompilation.
+	// Install Yarn as it is needed to precompile assets bundled with package.json dependencies.
 	if err := installYarn(ctx); err != nil {
 		return fmt.Errorf("installing Yarn: %w", err)
 	}
 
+	cl, err := ctx.Layer(cacheLayer, gcp.BuildLayer, gcp.CacheLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", cacheLayer, err)
+	}
+	if err := restoreAssetsCache(ctx, cl); err != nil {
+		return err
+	}
+
 	// It is common practise in Ruby asset precompilation to ignore non-zero exit codes.
 	result, err := ctx.Exec([]string{"bundle", "exec", "ruby", "bin/rails", "assets:precompile"},
-		gcp.WithEnv("RAILS_ENV=production", "MALLOC_ARENA_MAX=2", "RAILS_LOG_TO_STDOUT=true", "LANG=C.utf8"), gcp.WithUserAttribution)
+		gcp.WithEnv(precompileEnv...), gcp.WithUserAttribution)
 	if err != nil && result != nil && result.ExitCode != 0 {
 		ctx.Logf("WARNING: Asset precompilation returned non-zero exit code %d. Ignoring.", result.ExitCode)
-		return nil
-	}
-	if err != nil && result != nil {
+	} else if err != nil && result != nil {
 		return gcp.UserErrorf(result.Combined)
-	}
-	if err != nil {
+	} else if err != nil {
 		return gcp.InternalErrorf("asset precompilation failed: %v", err)
 	}
 
+	return saveAssetsCache(ctx, cl)
+}
+
+// restoreAssetsCache copies the assets cache of the previous build into the application to speed
+// up asset precompilation.
+func restoreAssetsCache(ctx *gcp.Context, cl *libcnb.Layer) error {
+	cached, err := ctx.FileExists(cl.Path, "assets")
+	if err != nil || !cached {
+		return err
+	}
+	ctx.CacheHit(cacheLayer)
+	dest := filepath.Join(ctx.ApplicationRoot(), assetsCacheDir)
+	if err := ctx.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	if err := ctx.RemoveAll(dest); err != nil {
+		return err
+	}
+	if _, err := ctx.Exec([]string{"cp", "--archive", filepath.Join(cl.Path, "assets"), dest}, gcp.WithUserTimingAttribution); err != nil {
+		return err
+	}
 	return nil
 }
 
+// saveAssetsCache moves the assets cache into the cache layer, as it is not needed at run time.
+func saveAssetsCache(ctx *gcp.Context, cl *libcnb.Layer) error {
+	src := filepath.Join(ctx.ApplicationRoot(), assetsCacheDir)
+	exists, err := ctx.FileExists(src)
+	if err != nil || !exists {
+		return err
+	}
+	dest := filepath.Join(cl.Path, "assets")
+	if err := ctx.RemoveAll(dest); err != nil {
+		return err
+	}
+	if _, err := ctx.Exec([]string{"cp", "--archive", src, dest}, gcp.WithUserTimingAttribution); err != nil {
+		return err
+	}
+	return ctx.RemoveAll(src)
+}
+
+// hasBootsnap returns true if bootsnap is locked in Gemfile.lock or gems.locked.
+func hasBootsnap(ctx *gcp.Context) (bool, error) {
+	for _, lockFile := range []string{"Gemfile.lock", "gems.locked"} {
+		found, err := ruby.HasGem(filepath.Join(ctx.ApplicationRoot(), lockFile), "bootsnap")
+		if err != nil {
+			return false, gcp.UserErrorf("reading %s: %v", lockFile, err)
+		}
+		if found {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// precompileBootsnap populates the bootsnap cache in tmp/cache/bootsnap, which is shipped with
+// the application, so that the first boot does not pay for compiling Ruby files and gems.
+func precompileBootsnap(ctx *gcp.Context) {
+	cmd := []string{"bundle", "exec", "bootsnap", "precompile", "--gemfile"}
+	for _, dir := range bootsnapDirs {
+		exists, err := ctx.FileExists(ctx.ApplicationRoot(), dir)
+		if err != nil {
+			ctx.Warnf("Checking %s: %v", dir, err)
+			continue
+		}
+		if exists {
+			cmd = append(cmd, dir+"/")
+		}
+	}
+	ctx.Logf("Precompiling bootsnap cache")
+	// A missing bootsnap cache only slows down the first boot, so failures are not fatal.
+	if _, err := ctx.Exec(cmd, gcp.WithEnv(precompileEnv...), gcp.WithUserAttribution); err != nil {
+		ctx.Warnf("Bootsnap precompilation failed, the cache will be populated at boot: %v", err)
+	}
+}
+
 func installYarn(ctx *gcp.Context) error {
 	pjs, err := nodejs.ReadPackageJSONIfExists(ctx.ApplicationRoot())
 	if err != nil {
 		return err
 	}
+	if pjs == nil {
+		ctx.Debugf("No package.json found, skipping Yarn installation.")
+		return nil
+	}
 	yrl, err := ctx.Layer(yarnLayer, gcp.BuildLayer, gcp.CacheLayer)
 	if err != nil {
 		return fmt.Errorf("creating %v layer: %w", yarnLayer, err)
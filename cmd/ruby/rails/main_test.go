@@ -15,16 +15,57 @@
 package main
 
 import (
+	"strings"
 	"testing"
 
 	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
 )
 
+const bootsnapLock = `GEM
+  remote: https://rubygems.org/
+  specs:
+    bootsnap (1.17.0)
+      msgpack (~> 1.2)
+
+DEPENDENCIES
+  bootsnap
+`
+
 func TestDetect(t *testing.T) {
 	testCases := []struct {
 		name  string
 		files map[string]string
 		want  int
+	}{
+		{
+			name: "bin/rails",
+			files: map[string]string{
+				"bin/rails": "",
+			},
+			want: 0,
+		},
+		{
+			name:  "no bin/rails",
+			files: map[string]string{},
+			want:  100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buildpacktest.TestDetect(t, detectFn, tc.name, tc.files, []string{}, tc.want)
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name            string
+		files           map[string]string
+		mocks           []*mockprocess.Mock
+		wantCommands    []string
+		skippedCommands []string
+		wantOutput      string
 	}{
 		{
 			name: "needs asset precompile",
@@ -32,14 +73,25 @@ func TestDetect(t *testing.T) {
 				"bin/rails":   "",
 				"app/assets/": "",
 			},
-			want: 0,
+			wantCommands:    []string{"bundle exec ruby bin/rails assets:precompile"},
+			skippedCommands: []string{"bootsnap"},
+		},
+		{
+			name: "asset precompile error is ignored",
+			files: map[string]string{
+				"bin/rails":   "",
+				"app/assets/": "",
+			},
+			mocks:        []*mockprocess.Mock{mockprocess.New("assets:precompile", mockprocess.WithExitCode(1))},
+			wantCommands: []string{"bundle exec ruby bin/rails assets:precompile"},
+			wantOutput:   "Asset precompilation returned non-zero exit code 1",
 		},
 		{
 			name: "no asset precompile because no assets dir",
 			files: map[string]string{
 				"bin/rails": "",
 			},
-			want: 100,
+			skippedCommands: []string{"assets:precompile"},
 		},
 		{
 			name: "no asset precompile because manifest yaml",
@@ -48,7 +100,7 @@ func TestDetect(t *testing.T) {
 				"app/assets/":                "",
 				"public/assets/manifest.yml": "",
 			},
-			want: 100,
+			skippedCommands: []string{"assets:precompile"},
 		},
 		{
 			name: "no asset precompile because manifest json",
@@ -57,7 +109,7 @@ func TestDetect(t *testing.T) {
 				"app/assets/":                     "",
 				"public/assets/manifest-foo.json": "",
 			},
-			want: 100,
+			skippedCommands: []string{"assets:precompile"},
 		},
 		{
 			name: "no asset precompile because sprockets manifest json",
@@ -66,17 +118,69 @@ func TestDetect(t *testing.T) {
 				"app/assets/": "",
 				"public/assets/.sprockets-manifest-foo.json": "",
 			},
-			want: 100,
+			skippedCommands: []string{"assets:precompile"},
 		},
 		{
-			name:  "no bin/rails",
-			files: map[string]string{},
-			want:  100,
+			name: "bootsnap in Gemfile.lock",
+			files: map[string]string{
+				"bin/rails":         "",
+				"app/models/foo.rb": "",
+				"Gemfile.lock":      bootsnapLock,
+			},
+			wantCommands:    []string{"bundle exec bootsnap precompile --gemfile app/"},
+			skippedCommands: []string{"assets:precompile", "--gemfile app/ lib/"},
+		},
+		{
+			name: "bootsnap in gems.locked",
+			files: map[string]string{
+				"bin/rails":   "",
+				"app/assets/": "",
+				"lib/foo.rb":  "",
+				"gems.locked": bootsnapLock,
+			},
+			wantCommands: []string{
+				"bundle exec ruby bin/rails assets:precompile",
+				"bundle exec bootsnap precompile --gemfile app/ lib/",
+			},
+		},
+		{
+			name: "bootsnap error is ignored",
+			files: map[string]string{
+				"bin/rails":    "",
+				"Gemfile.lock": bootsnapLock,
+			},
+			mocks:        []*mockprocess.Mock{mockprocess.New("bootsnap precompile", mockprocess.WithExitCode(1))},
+			wantCommands: []string{"bundle exec bootsnap precompile --gemfile"},
+			wantOutput:   "Bootsnap precompilation failed",
 		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			buildpacktest.TestDetect(t, detectFn, tc.name, tc.files, []string{}, tc.want)
+			mocks := append(tc.mocks, mockprocess.New("yarn"))
+			opts := []buildpacktest.Option{
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithFiles(tc.files),
+				buildpacktest.WithExecMocks(mocks...),
+			}
+
+			result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+			if err != nil {
+				t.Fatalf("error running build: %v, result: %#v", err, result)
+			}
+
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not", cmd)
+				}
+			}
+			for _, cmd := range tc.skippedCommands {
+				if result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to not be executed, but it was", cmd)
+				}
+			}
+			if !strings.Contains(result.Output, tc.wantOutput) {
+				t.Errorf("build output = %q, want it to contain %q", result.Output, tc.wantOutput)
+			}
 		})
 	}
 }
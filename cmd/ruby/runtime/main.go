@@ -31,6 +31,7 @@ import (
 var osNodeVersionMap = map[string]string{
 	"ubuntu1804": "12.22.12",
 	"ubuntu2204": "*",
+	"ubuntu2404": "*",
 }
 
 // Rails apps using the "webpack" gem require Node.js for asset precompilation.
@@ -39,7 +40,7 @@ func getRailsNodeVersion(ctx *gcp.Context) string {
 }
 
 func main() {
-	gcp.Main(detectFn, buildFn)
+	gcp.Main(runtime.WithStackCompatibilityCheck(runtime.Ruby, detectFn), buildFn)
 }
 
 func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
@@ -94,7 +95,7 @@ func buildFn(ctx *gcp.Context) error {
 		return err
 	}
 
-	versionInstalled, _ := runtime.ResolveVersion(runtime.Ruby, version, runtime.OSForStack(ctx.StackID()))
+	versionInstalled, _ := runtime.ResolveStackVersion(ctx, runtime.Ruby, version)
 	// Store the installed Ruby version for subsequent buildpacks (like RubyGems) that depend on it.
 	rl.BuildEnvironment.Override(ruby.RubyVersionKey, versionInstalled)
 
@@ -0,0 +1,57 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements ruby/server buildpack.
+// The server buildpack starts the application with puma, unicorn or falcon when no entrypoint is set.
+package main
+
+import (
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/ruby"
+)
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	s, err := ruby.DetectServer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		return gcp.OptOut(fmt.Sprintf("no application server configuration found and %s not set", env.RubyServer)), nil
+	}
+	if s.Config != "" {
+		return gcp.OptInFileFound(s.Config), nil
+	}
+	return gcp.OptInEnvSet(env.RubyServer), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	s, err := ruby.DetectServer(ctx)
+	if err != nil {
+		return err
+	}
+	if s == nil {
+		return gcp.UserErrorf("no application server configuration found and %s not set", env.RubyServer)
+	}
+	cmd := s.Command()
+	ctx.Logf("Starting the application with %s: %q", s.Name, cmd)
+	ctx.AddProcess(gcp.WebProcess, []string{cmd}, gcp.AsDefaultProcess())
+	return nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		envs  []string
+		want  int
+	}{
+		{
+			name:  "puma config",
+			files: map[string]string{"config.ru": "", "config/puma.rb": ""},
+			want:  0,
+		},
+		{
+			name:  "falcon config",
+			files: map[string]string{"config.ru": "", "falcon.rb": ""},
+			want:  0,
+		},
+		{
+			name:  "server env",
+			files: map[string]string{"config.ru": ""},
+			envs:  []string{"GOOGLE_RUBY_SERVER=unicorn"},
+			want:  0,
+		},
+		{
+			name:  "no server",
+			files: map[string]string{"config.ru": ""},
+			want:  100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buildpacktest.TestDetect(t, detectFn, tc.name, tc.files, tc.envs, tc.want)
+		})
+	}
+}
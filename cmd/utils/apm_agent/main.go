@@ -0,0 +1,249 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements utils/apm-agent buildpack.
+// The apm-agent buildpack installs the agent of the application performance monitoring vendor set
+// in GOOGLE_APM_AGENT for the application language and loads it at launch.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/agentutil"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/fetch"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	layerName = "apm-agent"
+
+	// cacheFormatVersion identifies the layout of the cached agent layer. Bump it whenever the way
+	// the layer is populated changes.
+	cacheFormatVersion = "v1"
+
+	javaAgentJar = "agent.jar"
+)
+
+// versionRegexp matches the versions that can be set in GOOGLE_APM_AGENT_VERSION, as they are used
+// in download URLs and package specifiers.
+var versionRegexp = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z.+_-]*$`)
+
+// agent describes how to install the agent of a vendor for each supported language. Supporting a
+// new vendor only requires adding an entry to agents.
+type agent struct {
+	// versions are the default versions of the agent for each language.
+	versions map[string]string
+	// javaURL is the download URL of the Java agent jar, formatted with the version.
+	javaURL string
+	// nodejsPackage is the npm package of the Node.js agent, it is loaded with --require.
+	nodejsPackage string
+	// nodejsRequire is the module required before the application, relative to node_modules.
+	nodejsRequire string
+	// pythonPackage is the PyPI package of the Python agent.
+	pythonPackage string
+	// pythonBootstrap is the directory that contains the sitecustomize module of the Python agent,
+	// relative to the installation directory. It is added to PYTHONPATH like the agent launcher does.
+	pythonBootstrap string
+	// launchDefaults are default launch env vars needed by the agent.
+	launchDefaults map[string]string
+	// passthroughEnv are the agent settings that are kept as launch defaults when they are set at
+	// build time. Settings containing credentials must not be included, they would be stored in the
+	// image.
+	passthroughEnv []string
+}
+
+var agents = map[string]agent{
+	"datadog": {
+		versions:        map[string]string{"java": "1.21.0", "nodejs": "4.17.0", "python": "2.0.2"},
+		javaURL:         "https://repo1.maven.org/maven2/com/datadoghq/dd-java-agent/%[1]s/dd-java-agent-%[1]s.jar",
+		nodejsPackage:   "dd-trace",
+		nodejsRequire:   "dd-trace/init.js",
+		pythonPackage:   "ddtrace",
+		pythonBootstrap: "ddtrace/bootstrap",
+		passthroughEnv:  []string{"DD_AGENT_HOST", "DD_ENV", "DD_SERVICE", "DD_SITE", "DD_TRACE_AGENT_PORT", "DD_VERSION"},
+	},
+	"newrelic": {
+		versions:        map[string]string{"java": "8.6.0", "nodejs": "11.3.0", "python": "9.1.0"},
+		javaURL:         "https://download.newrelic.com/newrelic/java-agent/newrelic-agent/%[1]s/newrelic-agent-%[1]s.jar",
+		nodejsPackage:   "newrelic",
+		nodejsRequire:   "newrelic/index.js",
+		pythonPackage:   "newrelic",
+		pythonBootstrap: "newrelic/bootstrap",
+		// The agents are configured with NEW_RELIC_* env vars instead of a configuration file.
+		launchDefaults: map[string]string{"NEW_RELIC_NO_CONFIG_FILE": "true"},
+		passthroughEnv: []string{"NEW_RELIC_APP_NAME", "NEW_RELIC_HOST", "NEW_RELIC_LOG_LEVEL"},
+	},
+}
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	if os.Getenv(env.APMAgent) == "" {
+		return gcp.OptOutEnvNotSet(env.APMAgent), nil
+	}
+	return gcp.OptInEnvSet(env.APMAgent), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	name := strings.ToLower(strings.TrimSpace(os.Getenv(env.APMAgent)))
+	a, ok := agents[name]
+	if !ok {
+		return gcp.UserErrorf("unsupported %s %q, must be one of: %s", env.APMAgent, name, strings.Join(agentNames(), ", "))
+	}
+	language, err := agentutil.AppLanguage(ctx)
+	if err != nil {
+		return err
+	}
+	version, ok := a.versions[language]
+	if !ok {
+		ctx.Warnf("%s is set but the %s agent is not supported for this application, only Node.js, Python and Java applications are supported.", env.APMAgent, name)
+		return nil
+	}
+	if v := strings.TrimSpace(os.Getenv(env.APMAgentVersion)); v != "" {
+		if !versionRegexp.MatchString(v) {
+			return gcp.UserErrorf("invalid %s %q", env.APMAgentVersion, v)
+		}
+		version = v
+	}
+
+	l, err := ctx.Layer(layerName, gcp.CacheLayer, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", layerName, err)
+	}
+	ctx.Logf("Installing the %s %s agent %s.", name, language, version)
+	switch language {
+	case agentutil.NodeJS:
+		err = installNodeJS(ctx, l, a, version)
+	case agentutil.Python:
+		err = installPython(ctx, l, a, version)
+	case agentutil.Java:
+		err = installJava(ctx, l, a, version)
+	}
+	if err != nil {
+		return err
+	}
+	ctx.AddBOMEntry(libcnb.BOMEntry{
+		Name:     name,
+		Metadata: map[string]interface{}{"language": language, "version": version},
+		Launch:   true,
+	})
+	for k, v := range a.launchDefaults {
+		l.LaunchEnvironment.Default(k, v)
+	}
+	for _, k := range a.passthroughEnv {
+		if v, ok := os.LookupEnv(k); ok {
+			ctx.Logf("Using %s=%s at launch.", k, v)
+			l.LaunchEnvironment.Default(k, v)
+		}
+	}
+	return nil
+}
+
+func agentNames() []string {
+	var names []string
+	for name := range agents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// installNodeJS installs the agent with npm and requires it before the application with
+// NODE_OPTIONS.
+func installNodeJS(ctx *gcp.Context, l *libcnb.Layer, a agent, version string) error {
+	l.LaunchEnvironment.Append("NODE_OPTIONS", " ", "--require "+filepath.Join(l.Path, "node_modules", a.nodejsRequire))
+
+	pkg := fmt.Sprintf("%s@%s", a.nodejsPackage, version)
+	cached, err := nodejs.CheckOrClearCache(ctx, l, cache.WithFormatVersion(cacheFormatVersion), cache.WithStrings(pkg))
+	if err != nil {
+		return fmt.Errorf("checking cache: %w", err)
+	}
+	if cached {
+		return nil
+	}
+	_, err = ctx.Exec([]string{"npm", "install", "--quiet", "--production", "--no-save", "--prefix", l.Path, pkg}, gcp.WithNetworkRetry, gcp.WithUserAttribution)
+	return err
+}
+
+// installPython installs the agent in a separate directory added to PYTHONPATH, so that the
+// application dependencies are left untouched, and loads it with the sitecustomize module of the
+// agent.
+func installPython(ctx *gcp.Context, l *libcnb.Layer, a agent, version string) error {
+	libDir := filepath.Join(l.Path, "lib")
+	l.LaunchEnvironment.Prepend("PYTHONPATH", string(os.PathListSeparator), filepath.Join(libDir, a.pythonBootstrap)+string(os.PathListSeparator)+libDir)
+
+	pkg := fmt.Sprintf("%s==%s", a.pythonPackage, version)
+	hit, err := checkCache(ctx, l, pkg)
+	if err != nil || hit {
+		return err
+	}
+	if _, err := ctx.Exec([]string{
+		"python3", "-m", "pip", "install",
+		"--target", libDir,
+		"--disable-pip-version-check",
+		"--no-warn-script-location",
+		"--no-cache-dir",
+		pkg,
+	}, gcp.WithNetworkRetry, gcp.WithUserAttribution); err != nil {
+		return err
+	}
+	return nil
+}
+
+// installJava downloads the agent jar and loads it with JAVA_TOOL_OPTIONS.
+func installJava(ctx *gcp.Context, l *libcnb.Layer, a agent, version string) error {
+	jar := filepath.Join(l.Path, javaAgentJar)
+	l.LaunchEnvironment.Append("JAVA_TOOL_OPTIONS", " ", "-javaagent:"+jar)
+
+	url := fmt.Sprintf(a.javaURL, version)
+	hit, err := checkCache(ctx, l, url)
+	if err != nil || hit {
+		return err
+	}
+	ctx.Logf("Downloading %s.", url)
+	if err := fetch.File(url, jar, ""); err != nil {
+		return gcp.UserErrorf("fetching the agent from %s, check that %s is a valid version: %v", url, version, err)
+	}
+	return nil
+}
+
+// checkCache returns true if the layer contains the given agent, otherwise it clears the layer and
+// stores the key of the agent that will be installed in it.
+func checkCache(ctx *gcp.Context, l *libcnb.Layer, agent string) (bool, error) {
+	hit, key, err := cache.CheckCache(ctx, l, cache.WithFormatVersion(cacheFormatVersion), "agent", cache.WithStrings(agent))
+	if err != nil {
+		return false, err
+	}
+	if hit {
+		ctx.CacheHit(l.Name)
+		return true, nil
+	}
+	ctx.CacheMiss(l.Name)
+	if err := ctx.ClearLayer(l); err != nil {
+		return false, fmt.Errorf("clearing layer %q: %w", l.Name, err)
+	}
+	ctx.SetMetadata(l, "agent", key)
+	return false, nil
+}
@@ -0,0 +1,150 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/cacheformat"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name string
+		env  []string
+		want int
+	}{
+		{
+			name: "agent set",
+			env:  []string{"GOOGLE_APM_AGENT=datadog"},
+			want: 0,
+		},
+		{
+			name: "not set",
+			want: 100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buildpacktest.TestDetect(t, detectFn, tc.name, map[string]string{}, tc.env, tc.want)
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name           string
+		envs           []string
+		files          map[string]string
+		wantCommands   []string
+		wantOutput     []string
+		dontWantOutput []string
+		wantExitCode   int
+	}{
+		{
+			name:         "datadog nodejs",
+			envs:         []string{"GOOGLE_APM_AGENT=datadog"},
+			files:        map[string]string{"package.json": "{}"},
+			wantCommands: []string{"npm install --quiet --production --no-save --prefix .* dd-trace@4.17.0"},
+		},
+		{
+			name:         "newrelic nodejs with version",
+			envs:         []string{"GOOGLE_APM_AGENT=newrelic", "GOOGLE_APM_AGENT_VERSION=11.5.0"},
+			files:        map[string]string{"package.json": "{}"},
+			wantCommands: []string{"npm install .* newrelic@11.5.0"},
+		},
+		{
+			name:         "datadog python",
+			envs:         []string{"GOOGLE_APM_AGENT=datadog", "GOOGLE_RUNTIME=python311"},
+			wantCommands: []string{"python3 -m pip install --target .* ddtrace==2.0.2"},
+		},
+		{
+			name:         "newrelic python",
+			envs:         []string{"GOOGLE_APM_AGENT=NewRelic"},
+			files:        map[string]string{"requirements.txt": "flask"},
+			wantCommands: []string{"python3 -m pip install --target .* newrelic==9.1.0"},
+		},
+		{
+			name:           "passthrough",
+			envs:           []string{"GOOGLE_APM_AGENT=datadog", "DD_SERVICE=shop", "DD_API_KEY=secret"},
+			files:          map[string]string{"package.json": "{}"},
+			wantOutput:     []string{"Using DD_SERVICE=shop at launch."},
+			dontWantOutput: []string{"DD_API_KEY"},
+		},
+		{
+			name:       "unsupported language",
+			envs:       []string{"GOOGLE_APM_AGENT=datadog", "GOOGLE_RUNTIME=go121"},
+			wantOutput: []string{"the datadog agent is not supported for this application"},
+		},
+		{
+			name:         "unsupported agent",
+			envs:         []string{"GOOGLE_APM_AGENT=dynatrace"},
+			files:        map[string]string{"package.json": "{}"},
+			wantOutput:   []string{`unsupported GOOGLE_APM_AGENT "dynatrace", must be one of: datadog, newrelic`},
+			wantExitCode: 1,
+		},
+		{
+			name:         "invalid version",
+			envs:         []string{"GOOGLE_APM_AGENT=datadog", "GOOGLE_APM_AGENT_VERSION=1.0/../2"},
+			files:        map[string]string{"package.json": "{}"},
+			wantOutput:   []string{`invalid GOOGLE_APM_AGENT_VERSION "1.0/../2"`},
+			wantExitCode: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []buildpacktest.Option{
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithEnvs(tc.envs...),
+				buildpacktest.WithFiles(tc.files),
+				buildpacktest.WithExecMocks(
+					mockprocess.New(`^node -v$`, mockprocess.WithStdout("v20.5.0")),
+					mockprocess.New(`^npm install`),
+					mockprocess.New(`pip install`),
+				),
+			}
+			result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, build output: %s", cmd, result.Output)
+				}
+			}
+			for _, want := range tc.wantOutput {
+				if !strings.Contains(result.Output, want) {
+					t.Errorf("build output does not contain %q, got: %s", want, result.Output)
+				}
+			}
+			for _, dontWant := range tc.dontWantOutput {
+				if strings.Contains(result.Output, dontWant) {
+					t.Errorf("build output contains %q, got: %s", dontWant, result.Output)
+				}
+			}
+		})
+	}
+}
+
+func TestCacheFormatVersion(t *testing.T) {
+	cacheformat.Check(t, "testdata/cache_format.golden", cache.WithFormatVersion(cacheFormatVersion), "main.go")
+}
@@ -0,0 +1,111 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements utils/apt buildpack.
+// The apt buildpack installs the system packages listed in the Aptfile and GOOGLE_SYSTEM_PACKAGES
+// into a layer that is available to the following buildpacks and to the application at launch.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/apt"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// aptfile lists one package per line, lines starting with # are comments.
+	aptfile = "Aptfile"
+	// aptLayer holds the extracted packages, it is used at build and launch time.
+	aptLayer = "apt"
+)
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	aptfileExists, err := ctx.FileExists(ctx.ApplicationRoot(), aptfile)
+	if err != nil {
+		return nil, err
+	}
+	if aptfileExists {
+		return gcp.OptInFileFound(aptfile), nil
+	}
+	if os.Getenv(env.SystemPackages) != "" {
+		return gcp.OptInEnvSet(env.SystemPackages), nil
+	}
+	return gcp.OptOut(fmt.Sprintf("neither %s nor %s is set", aptfile, env.SystemPackages)), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	packages, err := packagesToInstall(ctx)
+	if err != nil {
+		return err
+	}
+	if len(packages) == 0 {
+		ctx.Logf("No system packages to install.")
+		return nil
+	}
+	l, err := ctx.Layer(aptLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", aptLayer, err)
+	}
+	return apt.InstallCached(ctx, l, packages)
+}
+
+// packagesToInstall returns the packages listed in the Aptfile and in GOOGLE_SYSTEM_PACKAGES.
+func packagesToInstall(ctx *gcp.Context) ([]string, error) {
+	list := os.Getenv(env.SystemPackages)
+	path := filepath.Join(ctx.ApplicationRoot(), aptfile)
+	aptfileExists, err := ctx.FileExists(path)
+	if err != nil {
+		return nil, err
+	}
+	if aptfileExists {
+		content, err := ctx.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		packages, err := parseAptfile(string(content))
+		if err != nil {
+			return nil, err
+		}
+		list += " " + strings.Join(packages, " ")
+	}
+	return apt.ParsePackages(list), nil
+}
+
+// parseAptfile returns the packages listed in the content of an Aptfile. Only package names are
+// supported, the .deb URLs accepted by other Aptfile implementations are rejected.
+func parseAptfile(content string) ([]string, error) {
+	var packages []string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, p := range strings.Fields(line) {
+			if strings.Contains(p, "://") || strings.HasSuffix(p, ".deb") {
+				return nil, gcp.UserErrorf("unsupported %s entry %q: only package names are supported", aptfile, p)
+			}
+			packages = append(packages, p)
+		}
+	}
+	return packages, nil
+}
@@ -0,0 +1,160 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		envs  []string
+		want  int
+	}{
+		{
+			name:  "Aptfile",
+			files: map[string]string{"Aptfile": "libvips"},
+			want:  0,
+		},
+		{
+			name: "env var",
+			envs: []string{"GOOGLE_SYSTEM_PACKAGES=ffmpeg"},
+			want: 0,
+		},
+		{
+			name:  "neither",
+			files: map[string]string{"index.js": ""},
+			want:  100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buildpacktest.TestDetect(t, detectFn, tc.name, tc.files, tc.envs, tc.want)
+		})
+	}
+}
+
+func TestParseAptfile(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "empty",
+			content: "",
+		},
+		{
+			name:    "comments and blank lines",
+			content: "# Image processing\nlibvips\n\n  # Video\nffmpeg  \n",
+			want:    []string{"libvips", "ffmpeg"},
+		},
+		{
+			name:    "several packages per line",
+			content: "imagemagick ghostscript\n",
+			want:    []string{"imagemagick", "ghostscript"},
+		},
+		{
+			name:    "deb URL",
+			content: "https://example.com/pool/libfoo_1.0_amd64.deb\n",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseAptfile(tc.content)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("parseAptfile(%q) got error: %v, want error: %v", tc.content, err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("parseAptfile(%q) mismatch (-want +got):\n%s", tc.content, diff)
+			}
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name            string
+		files           map[string]string
+		envs            []string
+		wantCommands    []string
+		skippedCommands []string
+		wantExitCode    int
+	}{
+		{
+			name:  "Aptfile",
+			files: map[string]string{"Aptfile": "# Sharp\nlibvips\nffmpeg\n"},
+			wantCommands: []string{
+				"apt-get .* update",
+				"apt-get .* --download-only --reinstall --no-install-recommends install ffmpeg libvips",
+			},
+		},
+		{
+			name:  "Aptfile and env var",
+			files: map[string]string{"Aptfile": "libvips\n"},
+			envs:  []string{"GOOGLE_SYSTEM_PACKAGES=imagemagick, libvips"},
+			wantCommands: []string{
+				"apt-get .* install imagemagick libvips",
+			},
+		},
+		{
+			name:            "only comments",
+			files:           map[string]string{"Aptfile": "# nothing yet\n"},
+			skippedCommands: []string{"apt-get"},
+		},
+		{
+			name:            "unsupported entry",
+			files:           map[string]string{"Aptfile": "https://example.com/libfoo.deb\n"},
+			skippedCommands: []string{"apt-get"},
+			wantExitCode:    1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []buildpacktest.Option{
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithFiles(tc.files),
+				buildpacktest.WithEnvs(tc.envs...),
+				buildpacktest.WithExecMocks(mockprocess.New("apt-get"), mockprocess.New("dpkg")),
+			}
+
+			result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, result: %#v", err, result)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not", cmd)
+				}
+			}
+			for _, cmd := range tc.skippedCommands {
+				if result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to not be executed, but it was", cmd)
+				}
+			}
+		})
+	}
+}
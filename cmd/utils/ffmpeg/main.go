@@ -0,0 +1,101 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements utils/ffmpeg buildpack.
+// The ffmpeg buildpack installs static ffmpeg and ffprobe executables when GOOGLE_FFMPEG is true.
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	ffmpegVersion = "6.0.1"
+	ffmpegLayer   = "ffmpeg"
+	versionKey    = "version"
+)
+
+// ffmpegURL is the download URL of the static build for a version and an architecture, it is a var
+// for testing.
+var ffmpegURL = "https://johnvansickle.com/ffmpeg/releases/ffmpeg-%s-%s-static.tar.xz"
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	enabled, err := env.IsPresentAndTrue(env.FFmpeg)
+	if err != nil {
+		return nil, gcp.UserErrorf("parsing %s: %v", env.FFmpeg, err)
+	}
+	if !enabled {
+		return gcp.OptOutEnvNotSet(env.FFmpeg), nil
+	}
+	return gcp.OptInEnvSet(env.FFmpeg), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	l, err := ctx.Layer(ffmpegLayer, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", ffmpegLayer, err)
+	}
+	if ctx.GetMetadata(l, versionKey) == ffmpegVersion {
+		ctx.CacheHit(ffmpegLayer)
+	} else {
+		ctx.CacheMiss(ffmpegLayer)
+		if err := install(ctx, l); err != nil {
+			return err
+		}
+	}
+	ctx.AddBOMEntry(libcnb.BOMEntry{
+		Name:     ffmpegLayer,
+		Metadata: map[string]interface{}{"version": ffmpegVersion},
+		Launch:   true,
+		Build:    true,
+	})
+	return nil
+}
+
+// install extracts the static build into the bin directory of the layer, which the lifecycle adds
+// to the PATH of the following buildpacks and of the application.
+func install(ctx *gcp.Context, l *libcnb.Layer) error {
+	if err := ctx.ClearLayer(l); err != nil {
+		return fmt.Errorf("clearing layer %q: %w", l.Name, err)
+	}
+	ctx.Logf("Installing ffmpeg %s", ffmpegVersion)
+	tmpDir, err := ctx.TempDir("ffmpeg")
+	if err != nil {
+		return err
+	}
+	defer ctx.RemoveAll(tmpDir)
+	archive := filepath.Join(tmpDir, "ffmpeg.tar.xz")
+	if err := ctx.Download(fmt.Sprintf(ffmpegURL, ffmpegVersion, ctx.Arch()), archive); err != nil {
+		return err
+	}
+	binDir := filepath.Join(l.Path, "bin")
+	if err := ctx.MkdirAll(binDir, 0755); err != nil {
+		return err
+	}
+	// The archive has a single top-level directory with the executables, documentation and license.
+	if _, err := ctx.Exec([]string{"tar", "xJf", archive, "--directory", binDir, "--strip-components=1", "--wildcards", "*/ffmpeg", "*/ffprobe"}, gcp.WithUserAttribution); err != nil {
+		return fmt.Errorf("extracting ffmpeg: %w", err)
+	}
+	ctx.SetMetadata(l, versionKey, ffmpegVersion)
+	return nil
+}
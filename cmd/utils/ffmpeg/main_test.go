@@ -0,0 +1,80 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name string
+		envs []string
+		want int
+	}{
+		{
+			name: "enabled",
+			envs: []string{"GOOGLE_FFMPEG=true"},
+			want: 0,
+		},
+		{
+			name: "disabled",
+			envs: []string{"GOOGLE_FFMPEG=false"},
+			want: 100,
+		},
+		{
+			name: "not set",
+			want: 100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buildpacktest.TestDetect(t, detectFn, tc.name, map[string]string{"index.js": ""}, tc.envs, tc.want)
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ffmpeg-"+ffmpegVersion+"-amd64-static.tar.xz" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("archive"))
+	}))
+	t.Cleanup(svr.Close)
+	origURL := ffmpegURL
+	ffmpegURL = svr.URL + "/ffmpeg-%s-%s-static.tar.xz"
+	t.Cleanup(func() { ffmpegURL = origURL })
+
+	t.Run("install", func(t *testing.T) {
+		result, err := buildpacktest.RunBuild(t, buildFn,
+			buildpacktest.WithTestName("install"),
+			buildpacktest.WithEnvs("GOOGLE_FFMPEG=true", "CNB_TARGET_ARCH=amd64"),
+			buildpacktest.WithExecMocks(mockprocess.New("tar")),
+		)
+		if err != nil {
+			t.Fatalf("error running build: %v, result: %#v", err, result)
+		}
+		if cmd := "tar xJf .*ffmpeg.tar.xz --directory .*ffmpeg/bin --strip-components=1"; !result.CommandExecuted(cmd) {
+			t.Errorf("expected command %q to be executed, but it was not", cmd)
+		}
+	})
+}
@@ -0,0 +1,150 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements utils/observability-agents buildpack.
+// The observability-agents buildpack installs the Cloud Profiler and Cloud Trace agents of the
+// application language and loads them at launch when GOOGLE_INSTALL_OBSERVABILITY_AGENTS is true.
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/agentutil"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/fetch"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	layerName = "observability-agents"
+
+	// cacheFormatVersion identifies the layout of the cached agents layer. Bump it whenever the way
+	// the layer is populated changes.
+	cacheFormatVersion = "v1"
+
+	nodejsProfilerPackage = "@google-cloud/profiler@6.0.0"
+	nodejsTracePackage    = "@google-cloud/trace-agent@7.1.2"
+	// nodejsBootstrap is loaded with --require before the application to start the agents. The trace
+	// agent must be started before any other module is loaded.
+	nodejsBootstrap = `require('@google-cloud/trace-agent').start();
+require('@google-cloud/profiler').start().catch((err) => {
+  console.error('Failed to start Cloud Profiler:', err);
+});
+`
+
+	javaProfilerURL = "https://storage.googleapis.com/cloud-profiler/java/latest/profiler_java_agent.tar.gz"
+	// javaProfilerAgent is the native agent in the Cloud Profiler archive.
+	javaProfilerAgent = "profiler_java_agent.so"
+)
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	install, err := env.IsPresentAndTrue(env.InstallObservabilityAgents)
+	if err != nil {
+		return nil, gcp.UserErrorf("parsing %s: %v", env.InstallObservabilityAgents, err)
+	}
+	if !install {
+		return gcp.OptOutEnvNotSet(env.InstallObservabilityAgents), nil
+	}
+	return gcp.OptInEnvSet(env.InstallObservabilityAgents), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	language, err := agentutil.AppLanguage(ctx)
+	if err != nil {
+		return err
+	}
+	switch language {
+	case agentutil.NodeJS:
+		return installNodeJSAgents(ctx)
+	case agentutil.Java:
+		return installJavaAgents(ctx)
+	default:
+		ctx.Warnf("%s is set but observability agents are not supported for this application, only Node.js and Java applications are supported.", env.InstallObservabilityAgents)
+		return nil
+	}
+}
+
+// installNodeJSAgents installs the Cloud Profiler and Cloud Trace agents with npm and requires them
+// before the application with NODE_OPTIONS.
+func installNodeJSAgents(ctx *gcp.Context) error {
+	l, err := agentsLayer(ctx)
+	if err != nil {
+		return err
+	}
+	bootstrap := filepath.Join(l.Path, "agents.js")
+	l.LaunchEnvironment.Append("NODE_OPTIONS", " ", "--require "+bootstrap)
+
+	cached, err := nodejs.CheckOrClearCache(ctx, l, cache.WithFormatVersion(cacheFormatVersion), cache.WithStrings(nodejsProfilerPackage, nodejsTracePackage, nodejsBootstrap))
+	if err != nil {
+		return fmt.Errorf("checking cache: %w", err)
+	}
+	if cached {
+		return nil
+	}
+	ctx.Logf("Installing %s and %s.", nodejsProfilerPackage, nodejsTracePackage)
+	if _, err := ctx.Exec([]string{"npm", "install", "--quiet", "--production", "--no-save", "--prefix", l.Path, nodejsProfilerPackage, nodejsTracePackage}, gcp.WithUserAttribution); err != nil {
+		return err
+	}
+	return ctx.WriteFile(bootstrap, []byte(nodejsBootstrap), 0644)
+}
+
+// installJavaAgents installs the Cloud Profiler agent and loads it with JAVA_TOOL_OPTIONS.
+func installJavaAgents(ctx *gcp.Context) error {
+	l, err := agentsLayer(ctx)
+	if err != nil {
+		return err
+	}
+	agent := filepath.Join(l.Path, javaProfilerAgent)
+	l.LaunchEnvironment.Append("JAVA_TOOL_OPTIONS", " ", fmt.Sprintf("-agentpath:%s=-logtostderr,-cprof_enable_heap_sampling=true", agent))
+
+	hit, key, err := cache.CheckCache(ctx, l, cache.WithFormatVersion(cacheFormatVersion), "agents", cache.WithStrings(javaProfilerURL))
+	if err != nil {
+		return err
+	}
+	if hit {
+		ctx.CacheHit(l.Name)
+		return nil
+	}
+	ctx.CacheMiss(l.Name)
+	if err := ctx.ClearLayer(l); err != nil {
+		return fmt.Errorf("clearing layer %q: %w", l.Name, err)
+	}
+	ctx.Logf("Installing the Cloud Profiler Java agent from %s.", javaProfilerURL)
+	if err := fetch.Tarball(javaProfilerURL, l.Path, 0); err != nil {
+		return gcp.InternalErrorf("fetching the Cloud Profiler Java agent: %v", err)
+	}
+	if exists, err := ctx.FileExists(agent); err != nil {
+		return err
+	} else if !exists {
+		return gcp.InternalErrorf("the Cloud Profiler Java agent archive does not contain %s", javaProfilerAgent)
+	}
+	ctx.SetMetadata(l, "agents", key)
+	return nil
+}
+
+func agentsLayer(ctx *gcp.Context) (*libcnb.Layer, error) {
+	l, err := ctx.Layer(layerName, gcp.CacheLayer, gcp.LaunchLayer)
+	if err != nil {
+		return nil, fmt.Errorf("creating %v layer: %w", layerName, err)
+	}
+	return l, nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/cacheformat"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name string
+		env  []string
+		want int
+	}{
+		{
+			name: "enabled",
+			env:  []string{"GOOGLE_INSTALL_OBSERVABILITY_AGENTS=true"},
+			want: 0,
+		},
+		{
+			name: "disabled",
+			env:  []string{"GOOGLE_INSTALL_OBSERVABILITY_AGENTS=false"},
+			want: 100,
+		},
+		{
+			name: "not set",
+			want: 100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buildpacktest.TestDetect(t, detectFn, tc.name, map[string]string{}, tc.env, tc.want)
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name         string
+		envs         []string
+		files        map[string]string
+		wantCommands []string
+		wantOutput   string
+	}{
+		{
+			name:         "nodejs from package.json",
+			files:        map[string]string{"package.json": "{}"},
+			wantCommands: []string{"npm install --quiet --production --no-save --prefix .* " + nodejsProfilerPackage + " " + nodejsTracePackage},
+		},
+		{
+			name:         "nodejs from runtime",
+			envs:         []string{"GOOGLE_RUNTIME=nodejs20"},
+			wantCommands: []string{"npm install"},
+		},
+		{
+			name:       "unsupported language",
+			envs:       []string{"GOOGLE_RUNTIME=go121"},
+			files:      map[string]string{"go.mod": "module example.com/app"},
+			wantOutput: "observability agents are not supported",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []buildpacktest.Option{
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithEnvs(append([]string{"GOOGLE_INSTALL_OBSERVABILITY_AGENTS=true"}, tc.envs...)...),
+				buildpacktest.WithFiles(tc.files),
+				buildpacktest.WithExecMocks(
+					mockprocess.New(`^node -v$`, mockprocess.WithStdout("v20.5.0")),
+					mockprocess.New(`^npm install`),
+				),
+			}
+			result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+			if err != nil {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
+			}
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, build output: %s", cmd, result.Output)
+				}
+			}
+			if tc.wantOutput != "" && !strings.Contains(result.Output, tc.wantOutput) {
+				t.Errorf("build output does not contain %q, got: %s", tc.wantOutput, result.Output)
+			}
+		})
+	}
+}
+
+func TestCacheFormatVersion(t *testing.T) {
+	cacheformat.Check(t, "testdata/cache_format.golden", cache.WithFormatVersion(cacheFormatVersion), "main.go")
+}
@@ -0,0 +1,233 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements utils/otel buildpack.
+// The otel buildpack installs the OpenTelemetry auto-instrumentation of the application language
+// and loads it at launch when GOOGLE_OTEL_ENABLED is true.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/agentutil"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/fetch"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	layerName = "otel"
+
+	// cacheFormatVersion identifies the layout of the cached instrumentation layer. Bump it whenever
+	// the way the layer is populated changes.
+	cacheFormatVersion = "v1"
+
+	nodejsAPIPackage     = "@opentelemetry/api@1.6.0"
+	nodejsAutoPackage    = "@opentelemetry/auto-instrumentations-node@0.39.4"
+	nodejsRegisterModule = "node_modules/@opentelemetry/auto-instrumentations-node/build/src/register.js"
+
+	pythonDistroPackage   = "opentelemetry-distro==0.41b0"
+	pythonExporterPackage = "opentelemetry-exporter-otlp-proto-http==1.20.0"
+	// pythonSiteCustomize is imported by the interpreter at startup because its directory is in
+	// PYTHONPATH, it initializes the auto-instrumentation like the opentelemetry-instrument command.
+	pythonSiteCustomize = "from opentelemetry.instrumentation.auto_instrumentation import sitecustomize  # noqa: F401\n"
+
+	javaAgentURL = "https://github.com/open-telemetry/opentelemetry-java-instrumentation/releases/download/v1.31.0/opentelemetry-javaagent.jar"
+	javaAgentJar = "opentelemetry-javaagent.jar"
+)
+
+// passthroughEnv are the OpenTelemetry settings that are kept as launch defaults when they are set
+// at build time. OTEL_EXPORTER_OTLP_HEADERS is not included as it usually contains credentials that
+// must not be stored in the image.
+var passthroughEnv = []string{
+	"OTEL_EXPORTER_OTLP_ENDPOINT",
+	"OTEL_EXPORTER_OTLP_PROTOCOL",
+	"OTEL_LOGS_EXPORTER",
+	"OTEL_METRICS_EXPORTER",
+	"OTEL_PROPAGATORS",
+	"OTEL_RESOURCE_ATTRIBUTES",
+	"OTEL_SERVICE_NAME",
+	"OTEL_TRACES_EXPORTER",
+	"OTEL_TRACES_SAMPLER",
+	"OTEL_TRACES_SAMPLER_ARG",
+}
+
+// pythonDependencyFiles are the files that declare the dependencies of a Python application, the
+// instrumentation libraries to install depend on them.
+var pythonDependencyFiles = []string{"requirements.txt", "Pipfile.lock", "pyproject.toml", "poetry.lock"}
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	enabled, err := env.IsPresentAndTrue(env.OTelEnabled)
+	if err != nil {
+		return nil, gcp.UserErrorf("parsing %s: %v", env.OTelEnabled, err)
+	}
+	if !enabled {
+		return gcp.OptOutEnvNotSet(env.OTelEnabled), nil
+	}
+	return gcp.OptInEnvSet(env.OTelEnabled), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	language, err := agentutil.AppLanguage(ctx)
+	if err != nil {
+		return err
+	}
+	var install func(*gcp.Context, *libcnb.Layer) error
+	switch language {
+	case agentutil.NodeJS:
+		install = installNodeJS
+	case agentutil.Python:
+		install = installPython
+	case agentutil.Java:
+		install = installJava
+	default:
+		ctx.Warnf("%s is set but OpenTelemetry auto-instrumentation is not supported for this application, only Node.js, Python and Java applications are supported.", env.OTelEnabled)
+		return nil
+	}
+	l, err := ctx.Layer(layerName, gcp.CacheLayer, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", layerName, err)
+	}
+	if err := install(ctx, l); err != nil {
+		return err
+	}
+	for _, name := range passthroughEnv {
+		if v, ok := os.LookupEnv(name); ok {
+			ctx.Logf("Using %s=%s at launch.", name, v)
+			l.LaunchEnvironment.Default(name, v)
+		}
+	}
+	return nil
+}
+
+// installNodeJS installs the OpenTelemetry auto-instrumentations with npm and registers them before
+// the application with NODE_OPTIONS.
+func installNodeJS(ctx *gcp.Context, l *libcnb.Layer) error {
+	l.LaunchEnvironment.Append("NODE_OPTIONS", " ", "--require "+filepath.Join(l.Path, nodejsRegisterModule))
+
+	cached, err := nodejs.CheckOrClearCache(ctx, l, cache.WithFormatVersion(cacheFormatVersion), cache.WithStrings(nodejsAPIPackage, nodejsAutoPackage))
+	if err != nil {
+		return fmt.Errorf("checking cache: %w", err)
+	}
+	if cached {
+		return nil
+	}
+	ctx.Logf("Installing %s.", nodejsAutoPackage)
+	_, err = ctx.Exec([]string{"npm", "install", "--quiet", "--production", "--no-save", "--prefix", l.Path, nodejsAPIPackage, nodejsAutoPackage}, gcp.WithNetworkRetry, gcp.WithUserAttribution)
+	return err
+}
+
+// installPython installs the OpenTelemetry distro and the instrumentation libraries of the
+// dependencies of the application, and initializes them at startup with a sitecustomize module.
+// The packages are installed in a separate directory added to PYTHONPATH, not in the environment
+// of the application, so that the application dependencies are left untouched.
+func installPython(ctx *gcp.Context, l *libcnb.Layer) error {
+	libDir := filepath.Join(l.Path, "lib")
+	l.LaunchEnvironment.Prepend("PYTHONPATH", string(os.PathListSeparator), libDir)
+
+	opts := []cache.Option{cache.WithStrings(pythonDistroPackage, pythonExporterPackage, pythonSiteCustomize)}
+	for _, f := range pythonDependencyFiles {
+		if exists, err := ctx.FileExists(f); err != nil {
+			return err
+		} else if exists {
+			opts = append(opts, cache.WithFiles(f))
+		}
+	}
+	hit, key, err := cache.CheckCache(ctx, l, cache.WithFormatVersion(cacheFormatVersion), "otel", opts...)
+	if err != nil {
+		return err
+	}
+	if hit {
+		ctx.CacheHit(l.Name)
+		return nil
+	}
+	ctx.CacheMiss(l.Name)
+	if err := ctx.ClearLayer(l); err != nil {
+		return fmt.Errorf("clearing layer %q: %w", l.Name, err)
+	}
+
+	if err := ctx.MkdirAll(libDir, 0755); err != nil {
+		return err
+	}
+	ctx.Logf("Installing %s.", pythonDistroPackage)
+	if err := pipInstall(ctx, libDir, pythonDistroPackage, pythonExporterPackage); err != nil {
+		return err
+	}
+	// The bootstrap command lists the instrumentation libraries of the installed packages, the
+	// application dependencies are already installed by the previous buildpacks.
+	result, err := ctx.Exec([]string{"python3", "-m", "opentelemetry.instrumentation.bootstrap", "--action", "requirements"},
+		gcp.WithEnv("PYTHONPATH="+libDir), gcp.WithUserAttribution)
+	if err != nil {
+		return err
+	}
+	if instrumentations := strings.Fields(result.Stdout); len(instrumentations) > 0 {
+		ctx.Logf("Installing instrumentation libraries: %s.", strings.Join(instrumentations, ", "))
+		if err := pipInstall(ctx, libDir, instrumentations...); err != nil {
+			return err
+		}
+	}
+	if err := ctx.WriteFile(filepath.Join(libDir, "sitecustomize.py"), []byte(pythonSiteCustomize), 0644); err != nil {
+		return err
+	}
+	ctx.SetMetadata(l, "otel", key)
+	return nil
+}
+
+func pipInstall(ctx *gcp.Context, target string, packages ...string) error {
+	cmd := append([]string{
+		"python3", "-m", "pip", "install",
+		"--target", target,
+		"--upgrade",
+		"--disable-pip-version-check",
+		"--no-warn-script-location",
+		"--no-cache-dir",
+	}, packages...)
+	_, err := ctx.Exec(cmd, gcp.WithNetworkRetry, gcp.WithUserAttribution)
+	return err
+}
+
+// installJava installs the OpenTelemetry Java agent and loads it with JAVA_TOOL_OPTIONS.
+func installJava(ctx *gcp.Context, l *libcnb.Layer) error {
+	agent := filepath.Join(l.Path, javaAgentJar)
+	l.LaunchEnvironment.Append("JAVA_TOOL_OPTIONS", " ", "-javaagent:"+agent)
+
+	hit, key, err := cache.CheckCache(ctx, l, cache.WithFormatVersion(cacheFormatVersion), "otel", cache.WithStrings(javaAgentURL))
+	if err != nil {
+		return err
+	}
+	if hit {
+		ctx.CacheHit(l.Name)
+		return nil
+	}
+	ctx.CacheMiss(l.Name)
+	if err := ctx.ClearLayer(l); err != nil {
+		return fmt.Errorf("clearing layer %q: %w", l.Name, err)
+	}
+	ctx.Logf("Installing the OpenTelemetry Java agent from %s.", javaAgentURL)
+	if err := fetch.File(javaAgentURL, agent, ""); err != nil {
+		return gcp.InternalErrorf("fetching the OpenTelemetry Java agent: %v", err)
+	}
+	ctx.SetMetadata(l, "otel", key)
+	return nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/cacheformat"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name string
+		env  []string
+		want int
+	}{
+		{
+			name: "enabled",
+			env:  []string{"GOOGLE_OTEL_ENABLED=true"},
+			want: 0,
+		},
+		{
+			name: "disabled",
+			env:  []string{"GOOGLE_OTEL_ENABLED=false"},
+			want: 100,
+		},
+		{
+			name: "not set",
+			want: 100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buildpacktest.TestDetect(t, detectFn, tc.name, map[string]string{}, tc.env, tc.want)
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name           string
+		envs           []string
+		files          map[string]string
+		wantCommands   []string
+		wantOutput     []string
+		dontWantOutput []string
+	}{
+		{
+			name:         "nodejs",
+			files:        map[string]string{"package.json": "{}"},
+			wantCommands: []string{"npm install --quiet --production --no-save --prefix .* " + nodejsAPIPackage + " " + nodejsAutoPackage},
+		},
+		{
+			name:  "python",
+			envs:  []string{"GOOGLE_RUNTIME=python311"},
+			files: map[string]string{"requirements.txt": "flask"},
+			wantCommands: []string{
+				"python3 -m pip install --target .* " + pythonDistroPackage + " " + pythonExporterPackage,
+				"python3 -m opentelemetry.instrumentation.bootstrap --action requirements",
+				"python3 -m pip install --target .* opentelemetry-instrumentation-flask==0.41b0",
+			},
+		},
+		{
+			name:       "otlp endpoint passthrough",
+			envs:       []string{"OTEL_EXPORTER_OTLP_ENDPOINT=http://collector:4318", "OTEL_SERVICE_NAME=shop", "OTEL_EXPORTER_OTLP_HEADERS=authorization=secret"},
+			files:      map[string]string{"package.json": "{}"},
+			wantOutput: []string{"Using OTEL_EXPORTER_OTLP_ENDPOINT=http://collector:4318 at launch.", "Using OTEL_SERVICE_NAME=shop at launch."},
+			// Headers usually contain credentials that must not be stored in the image.
+			dontWantOutput: []string{"Using OTEL_EXPORTER_OTLP_HEADERS"},
+		},
+		{
+			name:       "unsupported language",
+			envs:       []string{"GOOGLE_RUNTIME=go121"},
+			files:      map[string]string{"go.mod": "module example.com/app"},
+			wantOutput: []string{"OpenTelemetry auto-instrumentation is not supported"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []buildpacktest.Option{
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithEnvs(append([]string{"GOOGLE_OTEL_ENABLED=true"}, tc.envs...)...),
+				buildpacktest.WithFiles(tc.files),
+				buildpacktest.WithExecMocks(
+					mockprocess.New(`^node -v$`, mockprocess.WithStdout("v20.5.0")),
+					mockprocess.New(`^npm install`),
+					mockprocess.New(`opentelemetry.instrumentation.bootstrap`, mockprocess.WithStdout("opentelemetry-instrumentation-flask==0.41b0\n")),
+					mockprocess.New(`pip install`),
+				),
+			}
+			result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+			if err != nil {
+				t.Fatalf("error running build: %v, logs: %s", err, result.Output)
+			}
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, build output: %s", cmd, result.Output)
+				}
+			}
+			for _, want := range tc.wantOutput {
+				if !strings.Contains(result.Output, want) {
+					t.Errorf("build output does not contain %q, got: %s", want, result.Output)
+				}
+			}
+			for _, dontWant := range tc.dontWantOutput {
+				if strings.Contains(result.Output, dontWant) {
+					t.Errorf("build output contains %q, got: %s", dontWant, result.Output)
+				}
+			}
+		})
+	}
+}
+
+func TestCacheFormatVersion(t *testing.T) {
+	cacheformat.Check(t, "testdata/cache_format.golden", cache.WithFormatVersion(cacheFormatVersion), "main.go")
+}
@@ -0,0 +1,232 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements utils/vulnerability-scan buildpack.
+// The vulnerability-scan buildpack scans the launch layers of the other buildpacks and the
+// application directory for known vulnerabilities when GOOGLE_VULNERABILITY_SCAN is set, and adds
+// an in-toto vulnerability attestation of the scan to the image.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/audit"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	attestationLayer = "attestation"
+	attestationFile  = "vulnerabilities.intoto.json"
+	// attestationLabel is the image label that holds the path of the attestation in the image.
+	attestationLabel = "vulnerability-attestation"
+
+	inTotoStatementType = "https://in-toto.io/Statement/v0.1"
+	// vulnPredicateType is the cosign vulnerability attestation predicate, see
+	// https://github.com/sigstore/cosign/blob/main/specs/COSIGN_VULN_ATTESTATION_SPEC.md.
+	vulnPredicateType = "https://cosign.sigstore.dev/attestation/vuln/v1"
+)
+
+// scanTime returns the start and end times of the scan, it is a var for testing.
+var scanTime = time.Now
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	cfg, err := audit.ParseConfig(env.VulnerabilityScan)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.Enabled {
+		return gcp.OptOutEnvNotSet(env.VulnerabilityScan), nil
+	}
+	return gcp.OptInEnvSet(env.VulnerabilityScan), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	cfg, err := audit.ParseConfig(env.VulnerabilityScan)
+	if err != nil {
+		return err
+	}
+	s, err := selectScanner()
+	if err != nil {
+		return err
+	}
+	al, err := ctx.Layer(attestationLayer, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", attestationLayer, err)
+	}
+	// The layers of each buildpack are in a directory named after it, the directories of the
+	// buildpacks that ran before this one are next to its own.
+	ownDir := filepath.Dir(al.Path)
+	dirs, err := launchLayerDirs(filepath.Dir(ownDir), ownDir)
+	if err != nil {
+		return err
+	}
+	dirs = append(dirs, ctx.ApplicationRoot())
+
+	bin, err := s.install(ctx)
+	if err != nil {
+		return err
+	}
+	started := scanTime()
+	ctx.Logf("Scanning %d directories for vulnerabilities with %s.", len(dirs), s.name())
+	res, err := s.scan(ctx, bin, dirs)
+	if err != nil {
+		return err
+	}
+
+	statement, err := newAttestation(ctx.BuildpackID(), s, res, started, scanTime())
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(statement, "", "  ")
+	if err != nil {
+		return gcp.InternalErrorf("encoding attestation: %v", err)
+	}
+	path := filepath.Join(al.Path, attestationFile)
+	if err := ctx.WriteFile(path, b, 0644); err != nil {
+		return err
+	}
+	ctx.AddLabel(attestationLabel, path)
+	return cfg.Check(ctx, audit.Report{Scanner: s.name(), Findings: res.findings})
+}
+
+// layerTypes is the content of a layer metadata file, see
+// https://github.com/buildpacks/spec/blob/main/buildpack.md#layer-content-metadata-toml.
+type layerTypes struct {
+	Types struct {
+		Launch bool `toml:"launch"`
+	} `toml:"types"`
+	// Launch is the type flag of buildpack API versions older than 0.6.
+	Launch bool `toml:"launch"`
+}
+
+// launchLayerDirs returns the directories of the launch layers of all the buildpacks in root,
+// except the one in skip.
+func launchLayerDirs(root, skip string) ([]string, error) {
+	tomls, err := filepath.Glob(filepath.Join(root, "*", "*.toml"))
+	if err != nil {
+		return nil, gcp.InternalErrorf("listing layers in %s: %v", root, err)
+	}
+	var dirs []string
+	for _, t := range tomls {
+		if filepath.Dir(t) == skip {
+			continue
+		}
+		dir := strings.TrimSuffix(t, ".toml")
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+			// Buildpack-level files such as store.toml do not describe a layer.
+			continue
+		}
+		var lt layerTypes
+		if _, err := toml.DecodeFile(t, &lt); err != nil {
+			return nil, gcp.InternalErrorf("reading %s: %v", t, err)
+		}
+		if lt.Types.Launch || lt.Launch {
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+type inTotoStatement struct {
+	Type          string          `json:"_type"`
+	PredicateType string          `json:"predicateType"`
+	Subject       []inTotoSubject `json:"subject"`
+	Predicate     vulnPredicate   `json:"predicate"`
+}
+
+type inTotoSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+type vulnPredicate struct {
+	Invocation vulnInvocation `json:"invocation"`
+	Scanner    vulnScanner    `json:"scanner"`
+	Metadata   vulnMetadata   `json:"metadata"`
+}
+
+type vulnInvocation struct {
+	BuilderID string `json:"builder.id"`
+}
+
+type vulnScanner struct {
+	URI     string          `json:"uri"`
+	Version string          `json:"version"`
+	Result  json.RawMessage `json:"result"`
+}
+
+type vulnMetadata struct {
+	ScanStartedOn  string `json:"scanStartedOn"`
+	ScanFinishedOn string `json:"scanFinishedOn"`
+}
+
+// newAttestation returns the in-toto statement of the scan. Its subjects are the files in which the
+// scanner found packages, e.g. lockfiles, with their digests.
+func newAttestation(builderID string, s scanner, res *scanResult, started, finished time.Time) (*inTotoStatement, error) {
+	subjects := []inTotoSubject{}
+	for _, path := range res.sources {
+		// Sources can also be directories, e.g. git repositories, which have no digest.
+		if fi, err := os.Stat(path); err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		digest, err := fileDigest(path)
+		if err != nil {
+			return nil, err
+		}
+		subjects = append(subjects, inTotoSubject{Name: path, Digest: map[string]string{"sha256": digest}})
+	}
+	return &inTotoStatement{
+		Type:          inTotoStatementType,
+		PredicateType: vulnPredicateType,
+		Subject:       subjects,
+		Predicate: vulnPredicate{
+			Invocation: vulnInvocation{BuilderID: builderID},
+			Scanner:    vulnScanner{URI: s.uri(), Version: s.version(), Result: res.raw},
+			Metadata: vulnMetadata{
+				ScanStartedOn:  started.UTC().Format(time.RFC3339),
+				ScanFinishedOn: finished.UTC().Format(time.RFC3339),
+			},
+		},
+	}, nil
+}
+
+// fileDigest returns the hex-encoded SHA-256 digest of a file.
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", gcp.InternalErrorf("opening %s: %v", path, err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", gcp.InternalErrorf("reading %s: %v", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
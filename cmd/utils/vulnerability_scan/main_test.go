@@ -0,0 +1,317 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/testserver"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/audit"
+	"github.com/google/go-cmp/cmp"
+)
+
+const osvReport = `{
+  "results": [
+    {
+      "source": {"path": "/workspace/package-lock.json", "type": "lockfile"},
+      "packages": [
+        {
+          "package": {"name": "minimist", "version": "1.2.5", "ecosystem": "npm"},
+          "vulnerabilities": [
+            {"id": "GHSA-xvch-5gv4-984h", "summary": "Prototype Pollution in minimist", "aliases": ["CVE-2021-44906"]},
+            {"id": "CVE-2021-44906", "summary": "minimist prototype pollution"}
+          ],
+          "groups": [{"ids": ["GHSA-xvch-5gv4-984h", "CVE-2021-44906"], "max_severity": "9.8"}]
+        },
+        {
+          "package": {"name": "qs", "version": "6.7.0", "ecosystem": "npm"},
+          "vulnerabilities": [{"id": "GHSA-hrpp-h998-j3pp", "summary": "qs vulnerable to Prototype Pollution"}],
+          "groups": [{"ids": ["GHSA-hrpp-h998-j3pp"], "max_severity": "7.5"}]
+        }
+      ]
+    },
+    {
+      "source": {"path": "/layers/google.python.pip/pip/lib/python3.11/site-packages", "type": "artifact"},
+      "packages": [
+        {
+          "package": {"name": "jinja2", "version": "3.1.2", "ecosystem": "PyPI"},
+          "vulnerabilities": [{"id": "GHSA-h5c8-rqwp-cp95", "summary": "Jinja vulnerable to HTML attribute injection"}],
+          "groups": [{"ids": ["GHSA-h5c8-rqwp-cp95"], "max_severity": ""}]
+        }
+      ]
+    }
+  ]
+}`
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name string
+		env  []string
+		want int
+	}{
+		{
+			name: "enabled",
+			env:  []string{"GOOGLE_VULNERABILITY_SCAN=true"},
+			want: 0,
+		},
+		{
+			name: "severity gate",
+			env:  []string{"GOOGLE_VULNERABILITY_SCAN=high"},
+			want: 0,
+		},
+		{
+			name: "disabled",
+			env:  []string{"GOOGLE_VULNERABILITY_SCAN=false"},
+			want: 100,
+		},
+		{
+			name: "not set",
+			want: 100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buildpacktest.TestDetect(t, detectFn, tc.name, map[string]string{}, tc.env, tc.want)
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name         string
+		envs         []string
+		mocks        []*mockprocess.Mock
+		wantExitCode int
+		wantCommands []string
+		wantOutput   []string
+	}{
+		{
+			name: "report findings",
+			envs: []string{"GOOGLE_VULNERABILITY_SCAN=true"},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New("osv-scanner --format json --recursive", mockprocess.WithStdout(osvReport), mockprocess.WithExitCode(1)),
+			},
+			wantCommands: []string{"osv-scanner --format json --recursive"},
+			wantOutput:   []string{"osv-scanner found 3 vulnerabilities", "google.vulnerability-attestation"},
+		},
+		{
+			name: "fail on critical findings",
+			envs: []string{"GOOGLE_VULNERABILITY_SCAN=critical"},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New("osv-scanner --format json --recursive", mockprocess.WithStdout(osvReport), mockprocess.WithExitCode(1)),
+			},
+			wantExitCode: 1,
+			wantOutput:   []string{"GHSA-xvch-5gv4-984h"},
+		},
+		{
+			name: "no packages",
+			envs: []string{"GOOGLE_VULNERABILITY_SCAN=fail"},
+			mocks: []*mockprocess.Mock{
+				mockprocess.New("osv-scanner --format json --recursive", mockprocess.WithExitCode(128)),
+			},
+			wantOutput: []string{"osv-scanner found no packages to scan"},
+		},
+		{
+			name:         "unknown scanner",
+			envs:         []string{"GOOGLE_VULNERABILITY_SCAN=true", "GOOGLE_VULNERABILITY_SCANNER=grype"},
+			wantExitCode: 1,
+		},
+		{
+			name:         "trivy on unsupported architecture",
+			envs:         []string{"GOOGLE_VULNERABILITY_SCAN=true", "GOOGLE_VULNERABILITY_SCANNER=trivy", "CNB_TARGET_ARCH=s390x"},
+			wantExitCode: 1,
+			wantOutput:   []string{"trivy is not available for s390x"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testserver.New(t, testserver.WithJSON("#!/bin/sh\n"), testserver.WithMockURL(&osvScannerURL))
+
+			result, err := buildpacktest.RunBuild(t, buildFn,
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithEnvs(tc.envs...),
+				buildpacktest.WithExecMocks(tc.mocks...),
+			)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, result: %#v", err, result)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not", cmd)
+				}
+			}
+			for _, want := range tc.wantOutput {
+				if !strings.Contains(result.Output, want) {
+					t.Errorf("output does not contain %q:\n%s", want, result.Output)
+				}
+			}
+		})
+	}
+}
+
+func TestParseOSVScanner(t *testing.T) {
+	want := []audit.Finding{
+		{ID: "GHSA-xvch-5gv4-984h", Package: "minimist", Version: "1.2.5", Severity: audit.SeverityCritical, Title: "Prototype Pollution in minimist", URL: "https://osv.dev/vulnerability/GHSA-xvch-5gv4-984h"},
+		{ID: "GHSA-hrpp-h998-j3pp", Package: "qs", Version: "6.7.0", Severity: audit.SeverityHigh, Title: "qs vulnerable to Prototype Pollution", URL: "https://osv.dev/vulnerability/GHSA-hrpp-h998-j3pp"},
+		{ID: "GHSA-h5c8-rqwp-cp95", Package: "jinja2", Version: "3.1.2", Severity: audit.SeverityUnknown, Title: "Jinja vulnerable to HTML attribute injection", URL: "https://osv.dev/vulnerability/GHSA-h5c8-rqwp-cp95"},
+	}
+	wantSources := []string{"/layers/google.python.pip/pip/lib/python3.11/site-packages", "/workspace/package-lock.json"}
+
+	got, err := parseOSVScanner(osvReport)
+	if err != nil {
+		t.Fatalf("parseOSVScanner() got error: %v", err)
+	}
+	if diff := cmp.Diff(want, got.findings); diff != "" {
+		t.Errorf("parseOSVScanner() findings mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(wantSources, got.sources); diff != "" {
+		t.Errorf("parseOSVScanner() sources mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseTrivy(t *testing.T) {
+	out := `{
+  "SchemaVersion": 2,
+  "Results": [
+    {
+      "Target": "package-lock.json",
+      "Class": "lang-pkgs",
+      "Type": "npm",
+      "Vulnerabilities": [
+        {"VulnerabilityID": "CVE-2021-44906", "PkgName": "minimist", "InstalledVersion": "1.2.5", "FixedVersion": "1.2.6", "Severity": "CRITICAL", "Title": "minimist: prototype pollution", "PrimaryURL": "https://avd.aquasec.com/nvd/cve-2021-44906"},
+        {"VulnerabilityID": "CVE-2022-24999", "PkgName": "qs", "InstalledVersion": "6.7.0", "Severity": "HIGH", "Title": "express: qs prototype pollution", "PrimaryURL": "https://avd.aquasec.com/nvd/cve-2022-24999"}
+      ]
+    },
+    {"Target": "node_modules/left-pad/package.json", "Class": "lang-pkgs", "Type": "node-pkg"}
+  ]
+}`
+	want := []audit.Finding{
+		{ID: "CVE-2021-44906", Package: "minimist", Version: "1.2.5", Severity: audit.SeverityCritical, Title: "minimist: prototype pollution (fixed in 1.2.6)", URL: "https://avd.aquasec.com/nvd/cve-2021-44906"},
+		{ID: "CVE-2022-24999", Package: "qs", Version: "6.7.0", Severity: audit.SeverityHigh, Title: "express: qs prototype pollution", URL: "https://avd.aquasec.com/nvd/cve-2022-24999"},
+	}
+	wantSources := []string{"/workspace/package-lock.json", "/workspace/node_modules/left-pad/package.json"}
+
+	got, err := parseTrivy(out, "/workspace")
+	if err != nil {
+		t.Fatalf("parseTrivy() got error: %v", err)
+	}
+	if diff := cmp.Diff(want, got.findings); diff != "" {
+		t.Errorf("parseTrivy() findings mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(wantSources, got.sources); diff != "" {
+		t.Errorf("parseTrivy() sources mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCVSSSeverity(t *testing.T) {
+	testCases := []struct {
+		score string
+		want  audit.Severity
+	}{
+		{score: "9.8", want: audit.SeverityCritical},
+		{score: "7.0", want: audit.SeverityHigh},
+		{score: "5.3", want: audit.SeverityModerate},
+		{score: "2.1", want: audit.SeverityLow},
+		{score: "0", want: audit.SeverityUnknown},
+		{score: "", want: audit.SeverityUnknown},
+		{score: "CVSS:3.1/AV:N", want: audit.SeverityUnknown},
+	}
+	for _, tc := range testCases {
+		if got := cvssSeverity(tc.score); got != tc.want {
+			t.Errorf("cvssSeverity(%q) = %v, want %v", tc.score, got, tc.want)
+		}
+	}
+}
+
+func TestLaunchLayerDirs(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"google.nodejs.runtime/nodejs.toml":      "[types]\nlaunch = true\nbuild = true\n",
+		"google.nodejs.runtime/npm_cache.toml":   "[types]\ncache = true\n",
+		"google.nodejs.runtime/store.toml":       "[metadata]\n",
+		"google.python.pip/pip.toml":             "launch = true\n",
+		"google.utils.vulnerability-scan/a.toml": "[types]\nlaunch = true\n",
+		"google.nodejs.runtime/nodejs/bin/node":  "",
+		"google.nodejs.runtime/npm_cache/index":  "",
+		"google.python.pip/pip/lib/site.py":      "",
+		"google.utils.vulnerability-scan/a/file": "",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{
+		filepath.Join(root, "google.nodejs.runtime", "nodejs"),
+		filepath.Join(root, "google.python.pip", "pip"),
+	}
+
+	got, err := launchLayerDirs(root, filepath.Join(root, "google.utils.vulnerability-scan"))
+	if err != nil {
+		t.Fatalf("launchLayerDirs() got error: %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("launchLayerDirs() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestNewAttestation(t *testing.T) {
+	dir := t.TempDir()
+	lockfile := filepath.Join(dir, "package-lock.json")
+	if err := ioutil.WriteFile(lockfile, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	res := &scanResult{
+		sources: []string{lockfile, dir, filepath.Join(dir, "missing.json")},
+		raw:     json.RawMessage(`{"results":[]}`),
+	}
+	started := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	got, err := newAttestation("google.utils.vulnerability-scan", &osvScanner{}, res, started, started.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("newAttestation() got error: %v", err)
+	}
+	want := &inTotoStatement{
+		Type:          "https://in-toto.io/Statement/v0.1",
+		PredicateType: "https://cosign.sigstore.dev/attestation/vuln/v1",
+		Subject: []inTotoSubject{{
+			Name:   lockfile,
+			Digest: map[string]string{"sha256": "44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a"},
+		}},
+		Predicate: vulnPredicate{
+			Invocation: vulnInvocation{BuilderID: "google.utils.vulnerability-scan"},
+			Scanner:    vulnScanner{URI: "https://github.com/google/osv-scanner", Version: osvScannerVersion, Result: json.RawMessage(`{"results":[]}`)},
+			Metadata:   vulnMetadata{ScanStartedOn: "2023-06-01T12:00:00Z", ScanFinishedOn: "2023-06-01T12:01:00Z"},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("newAttestation() mismatch (-want +got):\n%s", diff)
+	}
+}
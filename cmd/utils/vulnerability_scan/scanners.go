@@ -0,0 +1,364 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/audit"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/fetch"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	versionKey = "version"
+	archKey    = "arch"
+
+	osvScannerVersion = "1.9.0"
+	osvScannerLayer   = "osv_scanner"
+	// osvNoPackagesExitCode is the exit code of osv-scanner when it finds no package to scan.
+	osvNoPackagesExitCode = 128
+
+	trivyVersion    = "0.56.2"
+	trivyLayer      = "trivy"
+	trivyCacheLayer = "trivy_cache"
+)
+
+var (
+	// osvScannerURL and trivyURL are the download URLs of the scanner releases for an architecture,
+	// they are vars for testing.
+	osvScannerURL = "https://github.com/google/osv-scanner/releases/download/v" + osvScannerVersion + "/osv-scanner_linux_%s"
+	trivyURL      = "https://github.com/aquasecurity/trivy/releases/download/v" + trivyVersion + "/trivy_" + trivyVersion + "_Linux-%s.tar.gz"
+
+	// trivyArchs are the names of the architectures in the trivy release archives.
+	trivyArchs = map[string]string{
+		gcp.ArchAMD64: "64bit",
+		gcp.ArchARM64: "ARM64",
+	}
+)
+
+// scanner is a vulnerability scanner that can be selected with GOOGLE_VULNERABILITY_SCANNER.
+type scanner interface {
+	// name is the value of GOOGLE_VULNERABILITY_SCANNER that selects the scanner.
+	name() string
+	// uri and version identify the scanner in the attestation.
+	uri() string
+	version() string
+	// install installs the scanner if it is not already cached and returns its executable.
+	install(ctx *gcp.Context) (string, error)
+	// scan scans the given directories recursively.
+	scan(ctx *gcp.Context, bin string, dirs []string) (*scanResult, error)
+}
+
+// scanResult is the result of a scan.
+type scanResult struct {
+	findings []audit.Finding
+	// sources are the paths of the files in which the scanner found packages, e.g. lockfiles.
+	sources []string
+	// raw is the JSON report of the scanner.
+	raw json.RawMessage
+}
+
+// selectScanner returns the scanner selected by GOOGLE_VULNERABILITY_SCANNER.
+func selectScanner() (scanner, error) {
+	switch v := strings.ToLower(strings.TrimSpace(os.Getenv(env.VulnerabilityScanner))); v {
+	case "", "osv-scanner":
+		return &osvScanner{}, nil
+	case "trivy":
+		return &trivyScanner{}, nil
+	default:
+		return nil, gcp.UserErrorf("invalid %s %q, must be one of %q or %q", env.VulnerabilityScanner, os.Getenv(env.VulnerabilityScanner), "osv-scanner", "trivy")
+	}
+}
+
+// installBinary installs the executable of a scanner in a build-only layer if the given version is
+// not already cached. The download function downloads it into the bin directory of the layer.
+func installBinary(ctx *gcp.Context, layer, name, version string, download func(binDir string) error) (string, error) {
+	l, err := ctx.Layer(layer, gcp.BuildLayer, gcp.CacheLayer)
+	if err != nil {
+		return "", fmt.Errorf("creating %v layer: %w", layer, err)
+	}
+	binDir := filepath.Join(l.Path, "bin")
+	if ctx.GetMetadata(l, versionKey) == version && ctx.GetMetadata(l, archKey) == ctx.Arch() {
+		ctx.CacheHit(layer)
+	} else {
+		ctx.CacheMiss(layer)
+		if err := ctx.ClearLayer(l); err != nil {
+			return "", fmt.Errorf("clearing layer %q: %w", layer, err)
+		}
+		ctx.Logf("Installing %s v%s", name, version)
+		if err := ctx.MkdirAll(binDir, 0755); err != nil {
+			return "", err
+		}
+		if err := download(binDir); err != nil {
+			return "", err
+		}
+		ctx.SetMetadata(l, versionKey, version)
+		ctx.SetMetadata(l, archKey, ctx.Arch())
+	}
+	ctx.AddBOMEntry(libcnb.BOMEntry{
+		Name:     name,
+		Metadata: map[string]interface{}{"version": version},
+		Build:    true,
+	})
+	return filepath.Join(binDir, name), nil
+}
+
+// osvScanner scans with https://github.com/google/osv-scanner, which queries the OSV database.
+type osvScanner struct{}
+
+func (s *osvScanner) name() string    { return "osv-scanner" }
+func (s *osvScanner) uri() string     { return "https://github.com/google/osv-scanner" }
+func (s *osvScanner) version() string { return osvScannerVersion }
+
+func (s *osvScanner) install(ctx *gcp.Context) (string, error) {
+	return installBinary(ctx, osvScannerLayer, s.name(), osvScannerVersion, func(binDir string) error {
+		bin := filepath.Join(binDir, s.name())
+		if err := ctx.Download(fmt.Sprintf(osvScannerURL, ctx.Arch()), bin); err != nil {
+			return err
+		}
+		if err := os.Chmod(bin, 0755); err != nil {
+			return gcp.InternalErrorf("making %s executable: %v", bin, err)
+		}
+		return nil
+	})
+}
+
+func (s *osvScanner) scan(ctx *gcp.Context, bin string, dirs []string) (*scanResult, error) {
+	cmd := append([]string{bin, "--format", "json", "--recursive"}, dirs...)
+	result, err := ctx.Exec(cmd, gcp.WithUserAttribution)
+	if err != nil {
+		// osv-scanner exits with a non-zero status when it finds vulnerabilities or no packages.
+		switch {
+		case result != nil && result.ExitCode == osvNoPackagesExitCode:
+			ctx.Logf("osv-scanner found no packages to scan.")
+			return &scanResult{raw: json.RawMessage(`{"results":[]}`)}, nil
+		case result == nil || strings.TrimSpace(result.Stdout) == "":
+			return nil, err
+		}
+	}
+	res, err := parseOSVScanner(result.Stdout)
+	if err != nil {
+		return nil, gcp.InternalErrorf("parsing osv-scanner output: %v", err)
+	}
+	return res, nil
+}
+
+// parseOSVScanner parses the JSON report of osv-scanner. Each group of aliased vulnerabilities of
+// a package is a finding, with the maximum CVSS score of the group as its severity.
+func parseOSVScanner(out string) (*scanResult, error) {
+	var report struct {
+		Results []struct {
+			Source struct {
+				Path string `json:"path"`
+			} `json:"source"`
+			Packages []struct {
+				Package struct {
+					Name    string `json:"name"`
+					Version string `json:"version"`
+				} `json:"package"`
+				Vulnerabilities []struct {
+					ID      string `json:"id"`
+					Summary string `json:"summary"`
+				} `json:"vulnerabilities"`
+				Groups []struct {
+					IDs         []string `json:"ids"`
+					MaxSeverity string   `json:"max_severity"`
+				} `json:"groups"`
+			} `json:"packages"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		return nil, err
+	}
+	res := &scanResult{raw: json.RawMessage(out)}
+	for _, r := range report.Results {
+		res.sources = append(res.sources, r.Source.Path)
+		for _, p := range r.Packages {
+			summaries := map[string]string{}
+			for _, v := range p.Vulnerabilities {
+				summaries[v.ID] = v.Summary
+			}
+			for _, g := range p.Groups {
+				if len(g.IDs) == 0 {
+					continue
+				}
+				id := g.IDs[0]
+				res.findings = append(res.findings, audit.Finding{
+					ID:       id,
+					Package:  p.Package.Name,
+					Version:  p.Package.Version,
+					Severity: cvssSeverity(g.MaxSeverity),
+					Title:    summaries[id],
+					URL:      "https://osv.dev/vulnerability/" + id,
+				})
+			}
+		}
+	}
+	res.normalize()
+	return res, nil
+}
+
+// cvssSeverity returns the severity rating of a CVSS score, see
+// https://nvd.nist.gov/vuln-metrics/cvss.
+func cvssSeverity(score string) audit.Severity {
+	f, err := strconv.ParseFloat(score, 64)
+	switch {
+	case err != nil || f <= 0:
+		return audit.SeverityUnknown
+	case f >= 9:
+		return audit.SeverityCritical
+	case f >= 7:
+		return audit.SeverityHigh
+	case f >= 4:
+		return audit.SeverityModerate
+	default:
+		return audit.SeverityLow
+	}
+}
+
+// trivyScanner scans with https://github.com/aquasecurity/trivy. The vulnerability database of
+// Trivy is kept in a cached layer, Trivy updates it when it is older than a day.
+type trivyScanner struct {
+	cacheDir string
+}
+
+func (s *trivyScanner) name() string    { return "trivy" }
+func (s *trivyScanner) uri() string     { return "https://github.com/aquasecurity/trivy" }
+func (s *trivyScanner) version() string { return trivyVersion }
+
+func (s *trivyScanner) install(ctx *gcp.Context) (string, error) {
+	arch, ok := trivyArchs[ctx.Arch()]
+	if !ok {
+		return "", gcp.UserErrorf("%s is not available for %s, use osv-scanner", s.name(), ctx.Arch())
+	}
+	bin, err := installBinary(ctx, trivyLayer, s.name(), trivyVersion, func(binDir string) error {
+		archive := filepath.Join(binDir, "trivy.tar.gz")
+		if err := ctx.Download(fmt.Sprintf(trivyURL, arch), archive); err != nil {
+			return err
+		}
+		if err := fetch.ExtractTarball(archive, binDir, 0); err != nil {
+			return err
+		}
+		return ctx.RemoveAll(archive)
+	})
+	if err != nil {
+		return "", err
+	}
+	cl, err := ctx.Layer(trivyCacheLayer, gcp.BuildLayer, gcp.CacheLayer)
+	if err != nil {
+		return "", fmt.Errorf("creating %v layer: %w", trivyCacheLayer, err)
+	}
+	s.cacheDir = cl.Path
+	return bin, nil
+}
+
+func (s *trivyScanner) scan(ctx *gcp.Context, bin string, dirs []string) (*scanResult, error) {
+	res := &scanResult{}
+	var reports []json.RawMessage
+	// Trivy scans a single directory at a time.
+	for _, dir := range dirs {
+		result, err := ctx.Exec([]string{bin, "filesystem", "--quiet", "--format", "json", "--scanners", "vuln", "--cache-dir", s.cacheDir, dir}, gcp.WithUserAttribution)
+		if err != nil {
+			return nil, err
+		}
+		r, err := parseTrivy(result.Stdout, dir)
+		if err != nil {
+			return nil, gcp.InternalErrorf("parsing trivy output: %v", err)
+		}
+		res.findings = append(res.findings, r.findings...)
+		res.sources = append(res.sources, r.sources...)
+		reports = append(reports, r.raw)
+	}
+	raw, err := json.Marshal(reports)
+	if err != nil {
+		return nil, gcp.InternalErrorf("encoding trivy reports: %v", err)
+	}
+	res.raw = raw
+	res.normalize()
+	return res, nil
+}
+
+// parseTrivy parses the JSON report of a Trivy scan of the directory.
+func parseTrivy(out, dir string) (*scanResult, error) {
+	var report struct {
+		Results []struct {
+			Target          string `json:"Target"`
+			Vulnerabilities []struct {
+				VulnerabilityID  string `json:"VulnerabilityID"`
+				PkgName          string `json:"PkgName"`
+				InstalledVersion string `json:"InstalledVersion"`
+				FixedVersion     string `json:"FixedVersion"`
+				Severity         string `json:"Severity"`
+				Title            string `json:"Title"`
+				PrimaryURL       string `json:"PrimaryURL"`
+			} `json:"Vulnerabilities"`
+		} `json:"Results"`
+	}
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		return nil, err
+	}
+	res := &scanResult{raw: json.RawMessage(out)}
+	for _, r := range report.Results {
+		res.sources = append(res.sources, filepath.Join(dir, r.Target))
+		for _, v := range r.Vulnerabilities {
+			title := v.Title
+			if v.FixedVersion != "" {
+				title = strings.TrimSpace(fmt.Sprintf("%s (fixed in %s)", title, v.FixedVersion))
+			}
+			res.findings = append(res.findings, audit.Finding{
+				ID:       v.VulnerabilityID,
+				Package:  v.PkgName,
+				Version:  v.InstalledVersion,
+				Severity: audit.ParseSeverity(v.Severity),
+				Title:    title,
+				URL:      v.PrimaryURL,
+			})
+		}
+	}
+	return res, nil
+}
+
+// normalize sorts the sources and removes duplicate sources and findings, which are reported once
+// for each file in which a package is found.
+func (r *scanResult) normalize() {
+	seen := map[string]bool{}
+	var findings []audit.Finding
+	for _, f := range r.findings {
+		key := f.ID + " " + f.Package + "@" + f.Version
+		if !seen[key] {
+			seen[key] = true
+			findings = append(findings, f)
+		}
+	}
+	r.findings = findings
+
+	sort.Strings(r.sources)
+	var sources []string
+	for i, s := range r.sources {
+		if i == 0 || s != r.sources[i-1] {
+			sources = append(sources, s)
+		}
+	}
+	r.sources = sources
+}
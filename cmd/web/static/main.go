@@ -0,0 +1,247 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements web/static buildpack.
+// The static buildpack builds a static site and serves it with nginx.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildcommand"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/fetch"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nginx"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/static"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// staticLayer holds the nginx config that serves the site.
+	staticLayer = "static"
+	nginxConf   = "nginx.conf"
+	// portPlaceholder is replaced with $PORT when the container starts.
+	portPlaceholder = "__PORT__"
+	defaultPort     = "8080"
+
+	// hugoVersion is the version of Hugo used to build Hugo sites.
+	hugoVersion = "0.119.0"
+	hugoLayer   = "hugo"
+	versionKey  = "version"
+	archKey     = "arch"
+)
+
+// hugoURL is the download URL of Hugo release archives for a version and an architecture, it is a
+// var for testing.
+var hugoURL = "https://github.com/gohugoio/hugo/releases/download/v%[1]s/hugo_extended_%[1]s_linux-%[2]s.tar.gz"
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) (gcp.DetectResult, error) {
+	if os.Getenv(env.StaticOutputDir) != "" {
+		return gcp.OptInEnvSet(env.StaticOutputDir), nil
+	}
+	exists, err := ctx.FileExists(static.ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return gcp.OptInFileFound(static.ConfigFile), nil
+	}
+	client, err := static.ClientApp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if client != "" {
+		return gcp.OptIn(fmt.Sprintf("found a client-side %s application that does not start a server", client)), nil
+	}
+	return gcp.OptOut(fmt.Sprintf("neither %s found nor %s set", static.ConfigFile, env.StaticOutputDir)), nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	cfg, err := static.ReadConfig(ctx.ApplicationRoot())
+	if err != nil {
+		return err
+	}
+	if err := configureClientApp(ctx, cfg); err != nil {
+		return err
+	}
+	fw, err := static.DetectFramework(ctx)
+	if err != nil {
+		return err
+	}
+	if err := buildSite(ctx, cfg, fw); err != nil {
+		return err
+	}
+
+	output, err := static.OutputDir(ctx, cfg, fw)
+	if err != nil {
+		return err
+	}
+	root := filepath.Join(ctx.ApplicationRoot(), output)
+	index, err := ctx.FileExists(root, "index.html")
+	if err != nil {
+		return err
+	}
+	if !index {
+		ctx.Warnf("%s does not contain an index.html file, requests for / will fail.", output)
+	}
+	ctx.Logf("Serving the static site from %s", output)
+
+	l, err := ctx.Layer(staticLayer, gcp.LaunchLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", staticLayer, err)
+	}
+	conf := filepath.Join(l.Path, nginxConf)
+	if err := writeNginxConfig(conf, nginx.StaticConfig{
+		Port:          portPlaceholder,
+		Root:          root,
+		MimeTypesPath: nginx.MimeTypesPath,
+		SPA:           cfg.SPA,
+	}); err != nil {
+		return err
+	}
+
+	// $PORT is only known when the container starts, the config is copied to /tmp with the port set.
+	cmd := fmt.Sprintf(`sed "s/%s/${PORT:-%s}/g" %s > /tmp/%s && exec nginx -p /tmp -e stderr -c /tmp/%s`, portPlaceholder, defaultPort, conf, nginxConf, nginxConf)
+	ctx.AddProcess(gcp.WebProcess, []string{cmd}, gcp.AsDefaultProcess())
+	return nil
+}
+
+// configureClientApp serves client-side applications without a static.yaml file as single-page
+// applications, since they usually handle routing in the browser.
+func configureClientApp(ctx *gcp.Context, cfg *static.Config) error {
+	exists, err := ctx.FileExists(ctx.ApplicationRoot(), static.ConfigFile)
+	if err != nil || exists {
+		return err
+	}
+	client, err := static.ClientApp(ctx)
+	if err != nil || client == "" {
+		return err
+	}
+	ctx.Logf("Serving the %s application as a single-page application, set spa: false in %s to disable.", client, static.ConfigFile)
+	cfg.SPA = true
+	return nil
+}
+
+// buildSite runs the command that builds the site. GOOGLE_BUILD_COMMAND takes precedence over the
+// build command of static.yaml, which takes precedence over the build of the detected framework.
+func buildSite(ctx *gcp.Context, cfg *static.Config, fw *static.Framework) error {
+	if _, ok := buildcommand.Command(); ok {
+		_, err := buildcommand.Run(ctx, buildcommand.Config{})
+		return err
+	}
+	if cfg.Build != "" {
+		ctx.Logf("Running build command from %s: %q", static.ConfigFile, cfg.Build)
+		_, err := ctx.Exec([]string{"bash", "-c", cfg.Build}, gcp.WithUserAttribution)
+		return err
+	}
+	if fw == nil {
+		ctx.Logf("No static site generator detected, skipping build.")
+		return nil
+	}
+
+	ctx.Logf("Building the site with %s.", fw.Name)
+	cmd := fw.Command
+	switch fw.Name {
+	case static.NPM:
+		if err := installNPMDependencies(ctx); err != nil {
+			return err
+		}
+	case static.Hugo:
+		bin, err := installHugo(ctx)
+		if err != nil {
+			return err
+		}
+		cmd = append([]string{bin}, cmd[1:]...)
+	case static.Jekyll:
+		gemfile, err := ctx.FileExists("Gemfile")
+		if err != nil {
+			return err
+		}
+		if !gemfile {
+			return gcp.UserErrorf("building a Jekyll site requires a Gemfile that includes the jekyll gem")
+		}
+	}
+	_, err := ctx.Exec(cmd, gcp.WithEnv(fw.Env...), gcp.WithUserAttribution)
+	return err
+}
+
+// installNPMDependencies installs the dependencies of package.json, including devDependencies
+// which usually contain the build tools.
+func installNPMDependencies(ctx *gcp.Context) error {
+	installCmd := "install"
+	for _, lockfile := range []string{"package-lock.json", "npm-shrinkwrap.json"} {
+		exists, err := ctx.FileExists(lockfile)
+		if err != nil {
+			return err
+		}
+		if exists {
+			installCmd = "ci"
+			break
+		}
+	}
+	_, err := ctx.Exec([]string{"npm", installCmd, "--quiet"}, gcp.WithEnv("NODE_ENV=development"), gcp.WithUserAttribution)
+	return err
+}
+
+// installHugo installs Hugo in a build-only layer if it is not already cached and returns the path
+// of the hugo binary.
+func installHugo(ctx *gcp.Context) (string, error) {
+	l, err := ctx.Layer(hugoLayer, gcp.BuildLayer, gcp.CacheLayer)
+	if err != nil {
+		return "", fmt.Errorf("creating %v layer: %w", hugoLayer, err)
+	}
+	if ctx.GetMetadata(l, versionKey) == hugoVersion && ctx.GetMetadata(l, archKey) == ctx.Arch() {
+		ctx.CacheHit(hugoLayer)
+	} else {
+		ctx.CacheMiss(hugoLayer)
+		if err := ctx.ClearLayer(l); err != nil {
+			return "", fmt.Errorf("clearing layer %q: %w", hugoLayer, err)
+		}
+		ctx.Logf("Installing Hugo v%s", hugoVersion)
+		binDir := filepath.Join(l.Path, "bin")
+		if err := ctx.MkdirAll(binDir, 0755); err != nil {
+			return "", err
+		}
+		if err := fetch.Tarball(fmt.Sprintf(hugoURL, hugoVersion, ctx.Arch()), binDir, 0); err != nil {
+			return "", gcp.InternalErrorf("fetching Hugo v%s: %v", hugoVersion, err)
+		}
+		ctx.SetMetadata(l, versionKey, hugoVersion)
+		ctx.SetMetadata(l, archKey, ctx.Arch())
+	}
+	ctx.AddBOMEntry(libcnb.BOMEntry{
+		Name:     hugoLayer,
+		Metadata: map[string]interface{}{"version": hugoVersion},
+		Build:    true,
+	})
+	return filepath.Join(l.Path, "bin", "hugo"), nil
+}
+
+func writeNginxConfig(path string, conf nginx.StaticConfig) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return gcp.InternalErrorf("creating %s: %v", path, err)
+	}
+	defer f.Close()
+	if err := nginx.StaticTemplate.Execute(f, conf); err != nil {
+		return fmt.Errorf("writing nginx config file: %w", err)
+	}
+	return nil
+}
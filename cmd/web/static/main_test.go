@@ -0,0 +1,286 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	buildpacktest "github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktest"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	"github.com/GoogleCloudPlatform/buildpacks/internal/testserver"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nginx"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/testdata"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		env   []string
+		want  int
+	}{
+		{
+			name: "static.yaml",
+			files: map[string]string{
+				"static.yaml": "output: dist",
+			},
+			want: 0,
+		},
+		{
+			name: "output directory env",
+			files: map[string]string{
+				"index.html": "",
+			},
+			env:  []string{env.StaticOutputDir + "=."},
+			want: 0,
+		},
+		{
+			name: "no static.yaml",
+			files: map[string]string{
+				"index.html":   "",
+				"package.json": `{"scripts": {"build": "vite build"}}`,
+			},
+			want: 100,
+		},
+		{
+			name: "client-side react application",
+			files: map[string]string{
+				"package.json": `{"scripts": {"start": "react-scripts start", "build": "react-scripts build"}, "dependencies": {"react": "^18.2.0", "react-scripts": "5.0.1"}}`,
+			},
+			want: 0,
+		},
+		{
+			name: "react application with a server",
+			files: map[string]string{
+				"package.json": `{"scripts": {"start": "node server.js", "build": "vite build"}, "dependencies": {"express": "^4.18.2", "react": "^18.2.0"}}`,
+			},
+			want: 100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buildpacktest.TestDetect(t, detectFn, tc.name, tc.files, tc.env, tc.want)
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name            string
+		files           map[string]string
+		envs            []string
+		mocks           []*mockprocess.Mock
+		wantExitCode    int
+		wantCommands    []string
+		skippedCommands []string
+		wantOutput      string
+	}{
+		{
+			name: "plain site",
+			files: map[string]string{
+				"static.yaml": "",
+				"index.html":  "",
+			},
+			wantOutput: "Serving the static site from .",
+		},
+		{
+			name: "npm build",
+			files: map[string]string{
+				"static.yaml":       "spa: true",
+				"package.json":      `{"scripts": {"build": "vite build"}}`,
+				"package-lock.json": "{}",
+				"dist/index.html":   "",
+			},
+			wantCommands: []string{"npm ci --quiet", "npm run build"},
+			wantOutput:   "Serving the static site from dist",
+		},
+		{
+			name: "npm build without lockfile",
+			files: map[string]string{
+				"static.yaml":      "",
+				"package.json":     `{"scripts": {"build": "react-scripts build"}}`,
+				"build/index.html": "",
+			},
+			wantCommands: []string{"npm install --quiet", "npm run build"},
+			wantOutput:   "Serving the static site from build",
+		},
+		{
+			name: "client-side angular application",
+			files: map[string]string{
+				"package.json":                   `{"scripts": {"start": "ng serve", "build": "ng build"}, "dependencies": {"@angular/core": "^17.0.0"}}`,
+				"angular.json":                   `{"projects": {"my-app": {"projectType": "application", "architect": {"build": {"options": {"outputPath": "dist/my-app"}}}}}}`,
+				"package-lock.json":              "{}",
+				"dist/my-app/browser/index.html": "",
+			},
+			wantCommands: []string{"npm ci --quiet", "npm run build"},
+			wantOutput:   "Serving the static site from dist/my-app/browser",
+		},
+		{
+			name: "hugo",
+			files: map[string]string{
+				"static.yaml":       "",
+				"hugo.toml":         "",
+				"public/index.html": "",
+			},
+			wantCommands: []string{"hugo --minify"},
+			wantOutput:   "Serving the static site from public",
+		},
+		{
+			name: "jekyll",
+			files: map[string]string{
+				"static.yaml":      "",
+				"_config.yml":      "",
+				"Gemfile":          "",
+				"_site/index.html": "",
+			},
+			wantCommands: []string{"bundle exec jekyll build"},
+			wantOutput:   "Serving the static site from _site",
+		},
+		{
+			name: "jekyll without Gemfile",
+			files: map[string]string{
+				"static.yaml": "",
+				"_config.yml": "",
+			},
+			wantExitCode:    1,
+			skippedCommands: []string{"jekyll build"},
+		},
+		{
+			name: "build command from static.yaml",
+			files: map[string]string{
+				"static.yaml":       "build: make site\noutput: site",
+				"package.json":      `{"scripts": {"build": "vite build"}}`,
+				"site/index.html":   "",
+				"dist/index.html":   "",
+				"package-lock.json": "{}",
+			},
+			wantCommands:    []string{"bash -c make site"},
+			skippedCommands: []string{"npm run build"},
+			wantOutput:      "Serving the static site from site",
+		},
+		{
+			name: "output directory env takes precedence",
+			files: map[string]string{
+				"static.yaml":    "output: dist",
+				"www/index.html": "",
+			},
+			envs:       []string{env.StaticOutputDir + "=www"},
+			wantOutput: "Serving the static site from www",
+		},
+		{
+			name: "missing output directory",
+			files: map[string]string{
+				"static.yaml": "output: dist",
+			},
+			wantExitCode: 1,
+			wantOutput:   "output directory dist not found",
+		},
+		{
+			name: "unknown static.yaml field",
+			files: map[string]string{
+				"static.yaml": "outptu: dist",
+			},
+			wantExitCode: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testserver.New(t,
+				testserver.WithFile(testdata.MustGetPath("testdata/dummy-hugo.tar.gz")),
+				testserver.WithMockURL(&hugoURL),
+			)
+			opts := []buildpacktest.Option{
+				buildpacktest.WithTestName(tc.name),
+				buildpacktest.WithFiles(tc.files),
+				buildpacktest.WithEnvs(tc.envs...),
+				buildpacktest.WithExecMocks(
+					mockprocess.New("^npm"),
+					mockprocess.New("hugo --minify"),
+					mockprocess.New("^bundle exec jekyll"),
+					mockprocess.New("^bash -c"),
+				),
+			}
+
+			result, err := buildpacktest.RunBuild(t, buildFn, opts...)
+			if err != nil && tc.wantExitCode == 0 {
+				t.Fatalf("error running build: %v, result: %#v", err, result)
+			}
+
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("build exit code mismatch, got: %d, want: %d", result.ExitCode, tc.wantExitCode)
+			}
+			for _, cmd := range tc.wantCommands {
+				if !result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to be executed, but it was not", cmd)
+				}
+			}
+			for _, cmd := range tc.skippedCommands {
+				if result.CommandExecuted(cmd) {
+					t.Errorf("expected command %q to not be executed, but it was", cmd)
+				}
+			}
+			if !strings.Contains(result.Output, tc.wantOutput) {
+				t.Errorf("build output does not contain %q, got:\n%s", tc.wantOutput, result.Output)
+			}
+		})
+	}
+}
+
+func TestWriteNginxConfig(t *testing.T) {
+	testCases := []struct {
+		name     string
+		spa      bool
+		wantFall string
+	}{
+		{
+			name:     "static site",
+			wantFall: "try_files	$uri $uri/ $uri.html =404;",
+		},
+		{
+			name:     "single-page application",
+			spa:      true,
+			wantFall: "try_files	$uri $uri/ /index.html;",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), nginxConf)
+			conf := nginx.StaticConfig{Port: portPlaceholder, Root: "/workspace/dist", MimeTypesPath: nginx.MimeTypesPath, SPA: tc.spa}
+
+			if err := writeNginxConfig(path, conf); err != nil {
+				t.Fatalf("writeNginxConfig() got error: %v", err)
+			}
+
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatalf("reading %s: %v", path, err)
+			}
+			for _, want := range []string{
+				"listen	" + portPlaceholder + " default_server;",
+				"root	/workspace/dist;",
+				"include	" + nginx.MimeTypesPath + ";",
+				tc.wantFall,
+			} {
+				if !strings.Contains(string(b), want) {
+					t.Errorf("nginx config does not contain %q, got:\n%s", want, b)
+				}
+			}
+		})
+	}
+}
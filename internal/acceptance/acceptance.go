@@ -46,6 +46,7 @@ import (
 	"github.com/rs/xid"
 
 	"github.com/GoogleCloudPlatform/buildpacks/internal/checktools"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
 )
 
 const (
@@ -70,6 +71,8 @@ var (
 	cloudbuild          bool   // Use cloudbuild network; required for Cloud Build.
 	runtimeVersion      string // A runtime version which will be applied to tests that do not explicilty set a version.
 	runtimeName         string // The name of the runtime (aka the language name such as 'go' or 'dotnet'). Used to properly set GOOGLE_RUNTIME.
+	detectGoldens       string // Path to directory containing the expected detect output of each test; optional.
+	updateDetectGoldens bool   // If true, writes the detect output of each test to detectGoldens instead of comparing it.
 	specialChars        = regexp.MustCompile("[^a-zA-Z0-9]+")
 )
 
@@ -103,10 +106,18 @@ func DefineFlags() {
 	flag.StringVar(&packBin, "pack", "pack", "Path to pack binary.")
 	flag.StringVar(&structureBin, "structure-test", "container-structure-test", "Path to container-structure-test.")
 	flag.StringVar(&lifecycle, "lifecycle", "", "Location of lifecycle archive. Overrides builder.toml if specified.")
-	flag.BoolVar(&pullImages, "pull-images", true, "Pull stack images before running the tests.")
+	flag.BoolVar(&pullImages, "pull-images", true, "Pull stack images before running the tests. Images that are not in the local daemon are always pulled.")
 	flag.BoolVar(&cloudbuild, "cloudbuild", false, "Use cloudbuild network; required for Cloud Build.")
 	flag.StringVar(&runtimeVersion, "runtime-version", "", "A default runtime version which will be applied to the tests that do not explicitly set a version.")
 	flag.StringVar(&runtimeName, "runtime-name", "", "The name of the runtime (aka the language name such as 'go' or 'dotnet'). Used to properly set GOOGLE_RUNTIME.")
+	flag.StringVar(&containerRuntimeName, "container-runtime", "docker", "Container CLI used to manage the images and containers of the tests: docker, podman or nerdctl.")
+	flag.StringVar(&dockerHost, "docker-host", "", "Docker API endpoint used by pack to build images, e.g. unix:///run/user/1000/podman/podman.sock. Defaults to the API of the podman service with -container-runtime=podman.")
+	flag.StringVar(&dockerConfig, "docker-config", "", "Location of a Docker config directory with the credentials of the registries to pull the builder and run images from.")
+	flag.StringVar(&registryUsername, "registry-username", "", "Username to log in to the registries of the builder and run images, e.g. oauth2accesstoken or _json_key for Artifact Registry.")
+	flag.StringVar(&registryPasswordFile, "registry-password-file", "", "Location of a file containing the password or token of -registry-username.")
+	flag.StringVar(&targetPlatform, "platform", "", "Platform of the builder, run and application images, e.g. linux/arm64. Images are pulled and containers are run for this platform, which requires QEMU emulation on hosts of another architecture. Defaults to the platform of the container runtime.")
+	flag.StringVar(&detectGoldens, "detect-goldens", "", "Location of the golden files with the expected detect output of each test. Detect output is not checked if empty.")
+	flag.BoolVar(&updateDetectGoldens, "update-detect-goldens", false, "Write the detect output of each test to -detect-goldens instead of comparing it.")
 
 }
 
@@ -168,6 +179,12 @@ type Test struct {
 	MustOutputCached []string
 	// MustNotOutputCached specifies strings to not be found in the build logs of a cached build.
 	MustNotOutputCached []string
+	// MustMatchLogs specifies lines, in order, to be found in the stdout and stderr of the run
+	// container once the requests to the application are complete.
+	MustMatchLogs []LogMatch
+	// MustNotMatchLogs specifies regular expressions that must not match any line of the stdout and
+	// stderr of the run container.
+	MustNotMatchLogs []string
 	// MustRebuildOnChange specifies a file that, when changed in Dev Mode, triggers a rebuild.
 	MustRebuildOnChange string
 	// MustMatchStatusCode specifies the HTTP status code hitting the function endpoint should return.
@@ -176,6 +193,9 @@ type Test struct {
 	FlakyBuildAttempts int
 	// RequestType specifies the payload of the request used to test the function.
 	RequestType requestType
+	// Requests specifies HTTP requests to send in order, with assertions on their responses, in place
+	// of the default request that checks MustMatch and MustMatchStatusCode.
+	Requests []Request
 	// BOM specifies the list of bill-of-material entries expected in the built image metadata.
 	BOM []BOMEntry
 	// Setup is a function that sets up the source directory before test.
@@ -188,6 +208,9 @@ type Test struct {
 	// SkipStacks is slice of buildpack stack IDs that this test case should not be run on. This is
 	// useful for excluding apps that do not compile on the min stack.
 	SkipStacks []string
+	// Stacks is a slice of buildpack stack IDs that this test case only runs on, e.g. to test the
+	// system libraries of a stack. The test case runs on all stacks if empty.
+	Stacks []string
 }
 
 // SetupContext is passed into the Test.Setup function, it gives the setupFunc implementor access
@@ -298,6 +321,8 @@ type FailureTest struct {
 	MustMatch string
 	// SkipBuilderOutputMatch is true if the MustMatch string is not expected in $BUILDER_OUTPUT.
 	SkipBuilderOutputMatch bool
+	// MustMatchCode specifies the error code that the failure in $BUILDER_OUTPUT must have.
+	MustMatchCode buildererror.Code
 	// Setup is a function that sets up the source directory before test.
 	Setup setupFunc
 	// VersionInclusionConstraint is a 'semver' inclusion filter for runtime versions. The FilterTest
@@ -349,6 +374,11 @@ func TestBuildFailure(t *testing.T, imageCtx ImageContext, cfg FailureTest) {
 		t.Errorf("Expected regexp %q not found in BUILDER_OUTPUT", r)
 		t.Logf("BUILDER_OUTPUT: %v", builderOutput)
 	}
+	expectedCodeLog := "Expected error code included in error output: true"
+	if !cfg.SkipBuilderOutputMatch && cfg.MustMatchCode != "" && !strings.Contains(builderOutput, expectedCodeLog) {
+		t.Errorf("Expected error code %q not found in BUILDER_OUTPUT", cfg.MustMatchCode)
+		t.Logf("BUILDER_OUTPUT: %v", builderOutput)
+	}
 }
 
 // invokeApp performs an HTTP GET or sends a Cloud Event payload to the app.
@@ -358,40 +388,42 @@ func invokeApp(t *testing.T, cfg Test, image string, cache bool) {
 	containerID, host, port, cleanup := startContainer(t, image, cfg.Entrypoint, cfg.RunEnv, cache)
 	defer cleanup()
 
-	// Check that the application responds with `PASS`.
-	start := time.Now()
-
 	reqType := HTTPType
 	if cfg.RequestType != "" {
 		reqType = cfg.RequestType
 	}
 
-	body, status, statusCode, err := sendRequest(host, port, cfg.Path, reqType)
-
-	if err != nil {
-		t.Fatalf("Unable to invoke app: %v", err)
-	}
+	if len(cfg.Requests) > 0 {
+		sendRequests(t, host, port, cfg.Path, cfg.Requests)
+	} else {
+		// Check that the application responds with `PASS`.
+		start := time.Now()
+		body, status, statusCode, err := sendRequest(host, port, cfg.Path, reqType)
+		if err != nil {
+			t.Fatalf("Unable to invoke app: %v", err)
+		}
 
-	t.Logf("Got response: status %v, body %q (in %s)", status, body, time.Since(start))
+		t.Logf("Got response: status %v, body %q (in %s)", status, body, time.Since(start))
 
-	wantCode := http.StatusOK
-	if cfg.MustMatchStatusCode != 0 {
-		wantCode = cfg.MustMatchStatusCode
-	}
-	if statusCode != wantCode {
-		t.Errorf("Unexpected status code: got %d, want %d", statusCode, wantCode)
-	}
-	if reqType == HTTPType && cfg.MustMatch == "" {
-		cfg.MustMatch = "PASS"
-	}
-	if !strings.HasSuffix(body, cfg.MustMatch) {
-		t.Errorf("Response body does not contain suffix: got %q, want %q", body, cfg.MustMatch)
+		wantCode := http.StatusOK
+		if cfg.MustMatchStatusCode != 0 {
+			wantCode = cfg.MustMatchStatusCode
+		}
+		if statusCode != wantCode {
+			t.Errorf("Unexpected status code: got %d, want %d", statusCode, wantCode)
+		}
+		if reqType == HTTPType && cfg.MustMatch == "" {
+			cfg.MustMatch = "PASS"
+		}
+		if !strings.HasSuffix(body, cfg.MustMatch) {
+			t.Errorf("Response body does not contain suffix: got %q, want %q", body, cfg.MustMatch)
+		}
 	}
 
 	if cfg.MustRebuildOnChange != "" {
-		start = time.Now()
+		start := time.Now()
 		// Modify a source file in the running container.
-		if _, err := runOutput("docker", "exec", containerID, "sed", "-i", "s/PASS/UPDATED/", cfg.MustRebuildOnChange); err != nil {
+		if _, err := runOutput(container("exec", containerID, "sed", "-i", "s/PASS/UPDATED/", cfg.MustRebuildOnChange)...); err != nil {
 			t.Fatalf("Unable to modify a source file in the running container %q: %v", containerID, err)
 		}
 
@@ -419,6 +451,8 @@ func invokeApp(t *testing.T, cfg Test, image string, cache bool) {
 			}
 		}
 	}
+
+	verifyContainerLogs(t, containerID, cfg.MustMatchLogs, cfg.MustNotMatchLogs)
 }
 
 // sendRequest makes an http call to a given host:port/path
@@ -569,7 +603,7 @@ func runCombinedOutput(args ...string) (string, error) {
 // runDockerLogs returns the logs for a container, the lineLimit parameter
 // controls the maximum number of lines read from the log
 func runDockerLogs(containerID string, lineLimit int) (string, error) {
-	return runCombinedOutput("docker", "logs", "--tail", string(lineLimit), containerID)
+	return runCombinedOutput(container("logs", "--tail", strconv.Itoa(lineLimit), containerID)...)
 }
 
 // cleanUpImage attempts to delete an image from the Docker daemon.
@@ -579,7 +613,7 @@ func cleanUpImage(t *testing.T, name string) {
 	if keepArtifacts {
 		return
 	}
-	if _, err := runOutput("docker", "rmi", "-f", name); err != nil {
+	if _, err := runOutput(container("rmi", "-f", name)...); err != nil {
 		t.Logf("Failed to clean up image: %v", err)
 	}
 }
@@ -598,26 +632,37 @@ func cleanUpImage(t *testing.T, name string) {
 func ProvisionImages(t *testing.T) (ImageContext, func()) {
 	t.Helper()
 
-	if err := checktools.Installed(); err != nil {
+	if err := setUpContainerRuntime(); err != nil {
+		t.Fatalf("Error setting up container runtime: %v", err)
+	}
+	if err := checktools.Installed(containerCLI.name()); err != nil {
 		t.Fatalf("Error checking tools: %v", err)
 	}
 	if err := checktools.PackVersion(); err != nil {
 		t.Fatalf("Error checking pack version: %v", err)
 	}
 
+	if err := setUpPackDockerHost(); err != nil {
+		t.Fatalf("Error setting up the Docker API for pack: %v", err)
+	}
+	if err := setUpRegistryAuth(); err != nil {
+		t.Fatalf("Error setting up registry credentials: %v", err)
+	}
+
 	builderName := generateRandomImageName(builderPrefix)
 
 	if builderImage != "" {
 		t.Logf("Testing existing builder image: %s", builderImage)
-		if pullImages {
-			if _, err := runOutput("docker", "pull", builderImage); err != nil {
-				t.Fatalf("Error pulling %s: %v", builderImage, err)
-			}
+		if err := pullImage(builderImage); err != nil {
+			t.Fatalf("Error pulling %s: %v", builderImage, err)
 		}
 		// Pack cache is based on builder name; retag with a unique name.
-		if _, err := runOutput("docker", "tag", builderImage, builderName); err != nil {
+		if _, err := runOutput(container("tag", builderImage, builderName)...); err != nil {
 			t.Fatalf("Error tagging %s as %s: %v", builderImage, builderName, err)
 		}
+		if err := verifyImagePlatform(builderName); err != nil {
+			t.Fatalf("Error verifying platform of builder %s: %v", builderImage, err)
+		}
 		runName, cleanUpRun, err := provisionRunImageFromBuilder(builderName)
 		if err != nil {
 			t.Fatalf("Error provisioning run image for builder %q: %v", builderName, err)
@@ -655,11 +700,8 @@ func ProvisionImages(t *testing.T) (ImageContext, func()) {
 	}
 	// Pull images once in the beginning to prevent them from changing in the middle of testing.
 	// The images are intentionally not cleaned up to prevent conflicts across different test targets.
-	if pullImages {
-		buildName := builderConfig.Stack.BuildImage
-		if _, err := runOutput("docker", "pull", buildName); err != nil {
-			t.Fatalf("Error pulling %s: %v", buildName, err)
-		}
+	if err := pullImage(builderConfig.Stack.BuildImage); err != nil {
+		t.Fatalf("Error pulling %s: %v", builderConfig.Stack.BuildImage, err)
 	}
 	runName, cleanUpRun, err := provisionRunImageFromTOML(builderConfig)
 	if err != nil {
@@ -682,6 +724,9 @@ func ProvisionImages(t *testing.T) (ImageContext, func()) {
 		t.Fatalf("Error creating builder: %v, logs:\nstdout: %s\nstderr:%s", err, outb.String(), errb.String())
 	}
 	t.Logf("Successfully created builder: %s (in %s)", builderName, time.Since(start))
+	if err := verifyImagePlatform(builderName); err != nil {
+		t.Fatalf("Error verifying platform of builder: %v", err)
+	}
 
 	imageCtx := ImageContext{
 		StackID:      builderConfig.Stack.ID,
@@ -701,10 +746,8 @@ func provisionRunImageFromTOML(builderConfig *builderTOML) (string, func(t *test
 	if runImageOverride != "" {
 		runName = runImageOverride
 	}
-	if pullImages {
-		if _, err := runOutput("docker", "pull", runName); err != nil {
-			return "", nil, fmt.Errorf("pulling %q: %w", runName, err)
-		}
+	if err := pullImage(runName); err != nil {
+		return "", nil, err
 	}
 	if runName == builderConfig.Stack.RunImage {
 		// when the run image name is the one defined in the builderconfig, do not verify the stack ids
@@ -723,10 +766,8 @@ func provisionRunImageFromBuilder(builderName string) (string, func(t *testing.T
 	if runImageOverride != "" {
 		runName = runImageOverride
 	}
-	if pullImages {
-		if _, err := runOutput("docker", "pull", runName); err != nil {
-			return "", nil, fmt.Errorf("pulling %q: %w", runName, err)
-		}
+	if err := pullImage(runName); err != nil {
+		return "", nil, err
 	}
 	if builderDefinedRunImage == runName {
 		// when the run image is the one defined for the builder, do not verify the stack ids match
@@ -766,7 +807,7 @@ func provisionImageWithMatchingStackID(fromImage, stackID string) (string, func(
 }
 
 func getImageStackID(image string) (string, error) {
-	out, err := runOutput("docker", "inspect", `--format={{index .Config.Labels "io.buildpacks.stack.id"}}`, image)
+	out, err := runOutput(container("inspect", `--format={{index .Config.Labels "io.buildpacks.stack.id"}}`, image)...)
 	if err != nil {
 		return "", fmt.Errorf("getting stack id from docker inspect: %w", err)
 	}
@@ -775,7 +816,18 @@ func getImageStackID(image string) (string, error) {
 
 func newImageWithStackID(fromImage, stackID string) (string, error) {
 	newImage := generateRandomImageName(fromImage)
-	_, err := runCombinedOutput("bash", "-c", fmt.Sprintf(`echo "FROM %s" | docker build --label io.buildpacks.stack.id="%s" -t "%s" -`, fromImage, stackID, newImage))
+	dir, err := ioutil.TempDir("", "stack-id-")
+	if err != nil {
+		return "", fmt.Errorf("creating build context: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	dockerfile := filepath.Join(dir, "Dockerfile")
+	if err := ioutil.WriteFile(dockerfile, []byte(fmt.Sprintf("FROM %s\n", fromImage)), 0644); err != nil {
+		return "", fmt.Errorf("writing %s: %v", dockerfile, err)
+	}
+	args := append([]string{"build"}, platformArgs()...)
+	args = append(args, "--label", "io.buildpacks.stack.id="+stackID, "-t", newImage, "-f", dockerfile, dir)
+	_, err = runCombinedOutput(container(args...)...)
 	if err != nil {
 		return "", fmt.Errorf("changing stack id label on %q: %v", fromImage, err)
 	}
@@ -850,7 +902,7 @@ func updateLifecycle(config, uri string) (string, error) {
 // runImageFromMetadata returns the run image name from the metadata of the given image.
 func runImageFromMetadata(image string) (string, error) {
 	format := "--format={{(index (index .Config.Labels) \"io.buildpacks.builder.metadata\")}}"
-	out, err := runOutput("docker", "inspect", image, format)
+	out, err := runOutput(container("inspect", image, format)...)
 	if err != nil {
 		return "", fmt.Errorf("reading builder metadata: %v", err)
 	}
@@ -919,6 +971,11 @@ func buildCommand(srcDir, image, builderName, runName string, env map[string]str
 	if !cache {
 		args = append(args, "--clear-cache")
 	}
+	if packDockerHost != "" {
+		// Mount the Docker API of $DOCKER_HOST in the lifecycle container instead of the default
+		// Docker socket.
+		args = append(args, "--docker-host=inherit")
+	}
 	for k, v := range env {
 		args = append(args, "--env", fmt.Sprintf("%s=%s", k, v))
 	}
@@ -971,6 +1028,9 @@ func buildApp(t *testing.T, srcDir, image, builderName, runName string, env map[
 			break
 		}
 	}
+	if err := verifyImagePlatform(image); err != nil {
+		t.Fatalf("Error verifying platform of application %s: %v", image, err)
+	}
 
 	// Check that expected output is found in the logs.
 	mustOutput := cfg.MustOutput
@@ -991,6 +1051,8 @@ func buildApp(t *testing.T, srcDir, image, builderName, runName string, env map[
 		}
 	}
 
+	verifyDetectGolden(t, cfg.Name, outb.String())
+
 	// Scan for incorrect cache hits/misses.
 	if cache {
 		if strings.Contains(errb.String(), cacheMissMessage) {
@@ -1100,7 +1162,7 @@ func verifyBuildMetadata(t *testing.T, image string, mustUse, mustNotUse []strin
 	t.Helper()
 
 	start := time.Now()
-	out, err := runOutput("docker", "inspect", "--format={{index .Config.Labels \"io.buildpacks.build.metadata\"}}", image)
+	out, err := runOutput(container("inspect", "--format={{index .Config.Labels \"io.buildpacks.build.metadata\"}}", image)...)
 	if err != nil {
 		t.Fatalf("Error reading build metadata: %v", err)
 	}
@@ -1148,7 +1210,8 @@ func startContainer(t *testing.T, image, entrypoint string, env []string, cache
 	t.Helper()
 
 	containerName := xid.New().String()
-	command := []string{"docker", "run", "--detach", fmt.Sprintf("--name=%s", containerName)}
+	command := container("run", "--detach", fmt.Sprintf("--name=%s", containerName))
+	command = append(command, platformArgs()...)
 	for _, e := range env {
 		command = append(command, "--env", e)
 	}
@@ -1170,7 +1233,7 @@ func startContainer(t *testing.T, image, entrypoint string, env []string, cache
 
 	host, port := getHostAndPortForApp(t, id, containerName)
 	return id, host, port, func() {
-		if _, err := runOutput("docker", "stop", id); err != nil {
+		if _, err := runOutput(container("stop", id)...); err != nil {
 			t.Logf("Failed to stop container: %v", err)
 		}
 		if t.Failed() {
@@ -1180,7 +1243,7 @@ func startContainer(t *testing.T, image, entrypoint string, env []string, cache
 		if keepArtifacts {
 			return
 		}
-		if _, err := runOutput("docker", "rm", "-f", id); err != nil {
+		if _, err := runOutput(container("rm", "-f", id)...); err != nil {
 			t.Logf("Failed to clean up container: %v", err)
 		}
 	}
@@ -1229,7 +1292,7 @@ func hostPort(t *testing.T, id string) int {
 	t.Helper()
 
 	format := "--format={{(index (index .NetworkSettings.Ports \"8080/tcp\") 0).HostPort}}"
-	portstr, err := runOutput("docker", "inspect", id, format)
+	portstr, err := runOutput(container("inspect", id, format)...)
 	if err != nil {
 		t.Fatalf("Error getting port: %v", err)
 	}
@@ -1303,7 +1366,7 @@ func cleanUpVolumes(t *testing.T, image string) {
 	}
 	prefix = "pack-cache-" + prefix
 
-	if _, err := runOutput("docker", "volume", "rm", "-f", prefix+".launch", prefix+".build"); err != nil {
+	if _, err := runOutput(container("volume", "rm", "-f", prefix+".launch", prefix+".build")...); err != nil {
 		t.Logf("Failed to clean up cache volumes: %v", err)
 	}
 }
@@ -1314,11 +1377,12 @@ func PullImages() bool {
 }
 
 // FilterTests returns a new slice with only tests that should be run. Tests are filtered out if
-// their VersionInclusionConstraint does not match the `-runtime-version` flag.
+// their VersionInclusionConstraint does not match the `-runtime-version` flag or if they do not run
+// on the stack of the builder.
 func FilterTests(t *testing.T, imageCtx ImageContext, testCases []Test) []Test {
 	results := make([]Test, 0)
 	for _, tc := range testCases {
-		if ShouldTestVersion(t, tc.VersionInclusionConstraint) && ShouldTestStack(t, imageCtx.StackID, tc.SkipStacks) {
+		if ShouldTestVersion(t, tc.VersionInclusionConstraint) && ShouldTestStack(t, imageCtx.StackID, tc.SkipStacks) && runsOnStack(imageCtx.StackID, tc.Stacks) {
 			results = append(results, tc)
 		}
 	}
@@ -1349,6 +1413,19 @@ func ShouldTestStack(t *testing.T, stackID string, skipStacks []string) bool {
 	return true
 }
 
+// runsOnStack returns true if the stack is one of stacks, or if stacks is empty.
+func runsOnStack(stackID string, stacks []string) bool {
+	if len(stacks) == 0 {
+		return true
+	}
+	for _, s := range stacks {
+		if s == stackID {
+			return true
+		}
+	}
+	return false
+}
+
 // ShouldTestVersion returns true if the current test run's version is included
 // in the constraint parameter. An empty inclusion constraint is treated as
 // matching all versions.
@@ -0,0 +1,39 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acceptance
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFilterTestsByStack(t *testing.T) {
+	testCases := []Test{
+		{Name: "all stacks"},
+		{Name: "skipped", SkipStacks: []string{"google.24"}},
+		{Name: "only google.24", Stacks: []string{"google.22", "google.24"}},
+		{Name: "only google.22", Stacks: []string{"google.22"}},
+	}
+
+	var got []string
+	for _, tc := range FilterTests(t, ImageContext{StackID: "google.24"}, testCases) {
+		got = append(got, tc.Name)
+	}
+
+	if diff := cmp.Diff([]string{"all stacks", "only google.24"}, got); diff != "" {
+		t.Errorf("FilterTests() mismatch (-want +got):\n%s", diff)
+	}
+}
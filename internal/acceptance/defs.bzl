@@ -19,6 +19,9 @@ load("@rules_pkg//pkg:zip.bzl", "pkg_zip")
 # * gae_test_cloudbuild.zip: A zip which can be used with 'gae_test_cloudbuild.yaml' to
 #   submit a cloudbuild.
 #
+# Given a platforms value of ["linux/amd64", "linux/arm64"], the test targets are defined for each
+# platform, e.g. 1.13_gae_test_linux_arm64, and gae_test aliases to the tests of all platforms.
+#
 # To submit a cloudbuild with the go builder:
 #   blaze build //third_party/gcp_buildpacks/builders/go/acceptance:gae_test_cloudbuild.zip
 #   blaze build //third_party/gcp_buildpacks/builders/go/acceptance:gae_test_cloudbuild.yaml
@@ -36,6 +39,8 @@ def acceptance_test_suite(
         args = None,
         deps = None,
         argsmap = None,
+        detect_goldens = None,
+        platforms = None,
         **kwargs):
     """Macro to define an acceptance test.
 
@@ -49,14 +54,19 @@ def acceptance_test_suite(
       args: additional arguments to be passed to the test binary beyond ones corresponding to the arguments to this function
       deps: additional test dependencies beyond the acceptance package
       argsmap: version specific arguments map where the key is the version and the value is a list of flags that will be passed to the acceptance test framework
+      detect_goldens: a directory, relative to the package, of golden files with the expected detect output of each test case. Regenerate them with `bazel run <test> -- -update-detect-goldens`.
+      platforms: a list of platforms, e.g. ["linux/amd64", "linux/arm64"], to run the tests on. The builder, run and application images must be available for each platform; the images of other architectures than the host run with QEMU emulation. The cloudbuild targets are not generated per platform.
       **kwargs: this argument captures all additional arguments and forwards them to the generated go_test rule
     """
 
-    test_args = _build_args(args, name, testdata, builder, structure_test_config)
-    data = _build_data(structure_test_config, builder, testdata)
+    test_args = _build_args(args, name, testdata, builder, structure_test_config, detect_goldens)
+    data = _build_data(structure_test_config, builder, testdata, detect_goldens)
     deps = _build_deps(deps)
 
-    _build_tests(name, srcs, test_args, data, deps, versions, argsmap, **kwargs)
+    if platforms == None:
+        _build_tests(name, srcs, test_args, data, deps, versions, argsmap, **kwargs)
+    else:
+        _new_go_test_for_platforms(platforms, name, srcs, test_args, data, deps, versions, argsmap, **kwargs)
     _cloudbuild_targets(name, srcs, structure_test_config, builder, args, deps, versions, argsmap, testdata)
 
 def _build_tests(name, srcs, args, data, deps, versions, argsmap, **kwargs):
@@ -67,6 +77,22 @@ def _build_tests(name, srcs, args, data, deps, versions, argsmap, **kwargs):
     else:
         _new_go_test_for_versions(versions, name, srcs, args, data, deps, argsmap, **kwargs)
 
+def _new_go_test_for_platforms(platforms, name, srcs, args, data, deps, versions, argsmap, **kwargs):
+    suites = []
+    for p in platforms:
+        # For example, 'gcp_test_linux_arm64' for 'linux/arm64'.
+        platform_name = name + "_" + p.replace("/", "_")
+        suites.append(platform_name)
+        platform_args = list(args)
+        platform_args.append("-platform=" + p)
+        _build_tests(platform_name, srcs, platform_args, data, deps, versions, argsmap, **kwargs)
+
+    native.test_suite(
+        name = name,
+        tests = suites,
+    )
+    _new_bin_filegroup_alias(name, suites[0] + "_bin")
+
 def _new_go_test_for_versions(versions, name, srcs, args, data, deps, argsmap, **kwargs):
     tests = []
     for v in versions:
@@ -120,7 +146,7 @@ def _new_bin_filegroup_alias(name, test_name):
         testonly = 1,
     )
 
-def _build_args(args, name, testdata, builder, structure_test_config):
+def _build_args(args, name, testdata, builder, structure_test_config, detect_goldens = None):
     short_name = _remove_suffix(name, "_test")
     builder_name = _extract_builder_name(builder)
 
@@ -134,14 +160,19 @@ def _build_args(args, name, testdata, builder, structure_test_config):
     args.append("-builder-source=$(location " + builder + ")")
     args.append("-builder-prefix=" + builder_name + "-" + short_name + "-acceptance-test-")
     args.append("-runtime-name=" + builder_name)
+    if detect_goldens != None:
+        args.append("-detect-goldens=" + native.package_name() + "/" + detect_goldens)
     return args
 
-def _build_data(structure_test_config, builder, testdata):
-    return [
+def _build_data(structure_test_config, builder, testdata, detect_goldens = None):
+    data = [
         structure_test_config,
         builder,
         testdata,
     ]
+    if detect_goldens != None:
+        data += native.glob([detect_goldens + "/*.golden"])
+    return data
 
 def _extract_builder_name(builder):
     # A builder target is a full google3 path, the name of the builder, and then :builder.tar, the following
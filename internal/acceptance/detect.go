@@ -0,0 +1,118 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acceptance
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var (
+	// detectResultRegexp matches a buildpack result printed by the lifecycle detector for each order
+	// group it tries, e.g. "pass: google.nodejs.runtime@0.9.1".
+	detectResultRegexp = regexp.MustCompile(`^(pass|fail|skip|err): ([a-z0-9.-]+)@\S+$`)
+	// participatingRegexp matches a buildpack of the selected group, e.g. "google.nodejs.npm 0.9.0".
+	participatingRegexp = regexp.MustCompile(`^([a-z0-9-]+(?:\.[a-z0-9-]+)+)\s+v?\d+\.\d+\.\d+\S*$`)
+	// phaseHeaderRegexp matches the header pack prints at the start of each lifecycle phase.
+	phaseHeaderRegexp = regexp.MustCompile(`^===> ([A-Z]+)`)
+	// detectorPrefixRegexp matches the prefix of detector lines when the phases run separately.
+	detectorPrefixRegexp = regexp.MustCompile(`^\[detector\] ?`)
+)
+
+// normalizeDetectOutput extracts the detection section of a pack build log: the result of each
+// buildpack in every order group tried, in order, followed by the buildpacks of the selected group.
+// Buildpack versions are stripped so that releases of individual buildpacks do not cause diffs.
+func normalizeDetectOutput(log string) string {
+	var b strings.Builder
+	var participating []string
+	groups := 0
+	inDetect := false
+	for _, line := range strings.Split(log, "\n") {
+		line = strings.TrimSpace(line)
+		if m := phaseHeaderRegexp.FindStringSubmatch(line); m != nil {
+			inDetect = m[1] == "DETECTING"
+			continue
+		}
+		if !inDetect {
+			continue
+		}
+		line = detectorPrefixRegexp.ReplaceAllString(line, "")
+		switch {
+		case line == "======== Results ========":
+			groups++
+			participating = nil
+			fmt.Fprintf(&b, "group %d:\n", groups)
+		case detectResultRegexp.MatchString(line):
+			m := detectResultRegexp.FindStringSubmatch(line)
+			fmt.Fprintf(&b, "  %s: %s\n", m[1], m[2])
+		case participatingRegexp.MatchString(line):
+			participating = append(participating, participatingRegexp.FindStringSubmatch(line)[1])
+		}
+	}
+	b.WriteString("participating:\n")
+	for _, id := range participating {
+		fmt.Fprintf(&b, "  %s\n", id)
+	}
+	return b.String()
+}
+
+// detectGoldenPath returns the path of the golden file with the detect output of the named test.
+func detectGoldenPath(dir, name string) string {
+	return filepath.Join(dir, strings.ToLower(strings.Trim(specialChars.ReplaceAllString(name, "_"), "_"))+".golden")
+}
+
+// verifyDetectGolden compares the detect output of a build against the golden file of the test.
+// With -update-detect-goldens, the golden file is written instead.
+func verifyDetectGolden(t *testing.T, name, log string) {
+	t.Helper()
+
+	if detectGoldens == "" {
+		return
+	}
+	got := normalizeDetectOutput(log)
+	path := detectGoldenPath(detectGoldens, name)
+
+	if updateDetectGoldens {
+		// `bazel run` sets BUILD_WORKSPACE_DIRECTORY to the source tree; the runfiles are read-only.
+		if ws := os.Getenv("BUILD_WORKSPACE_DIRECTORY"); ws != "" {
+			path = filepath.Join(ws, path)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Error creating directory for %s: %v", path, err)
+		}
+		if err := ioutil.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatalf("Error writing %s: %v", path, err)
+		}
+		t.Logf("Updated detect golden %s", path)
+		return
+	}
+
+	want, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		t.Fatalf("Detect golden %s does not exist, run with -update-detect-goldens to create it. Detect output:\n%s", path, got)
+	}
+	if err != nil {
+		t.Fatalf("Error reading %s: %v", path, err)
+	}
+	if got != string(want) {
+		t.Errorf("Detect output does not match %s; the order groups or the buildpacks that pass detection changed. "+
+			"If this is intended, run with -update-detect-goldens and review the diff.\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
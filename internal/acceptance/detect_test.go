@@ -0,0 +1,112 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acceptance
+
+import (
+	"testing"
+)
+
+func TestNormalizeDetectOutput(t *testing.T) {
+	testCases := []struct {
+		name string
+		log  string
+		want string
+	}{
+		{
+			name: "creator",
+			log: `===> ANALYZING
+Timer: Analyzer started at 2023-01-01T00:00:00Z
+===> DETECTING
+Timer: Detector started at 2023-01-01T00:00:00Z
+======== Output: google.nodejs.runtime@0.9.1 ========
+pass: not part of the results
+======== Results ========
+fail: google.config.flex@0.9.0
+pass: google.nodejs.runtime@0.9.1
+pass: google.utils.label-image@0.0.2
+======== Results ========
+pass: google.nodejs.runtime@0.9.1
+skip: google.utils.archive-source@0.0.1
+pass: google.nodejs.npm@1.0.0
+Resolving plan... (try #1)
+3 of 4 buildpacks participating
+google.nodejs.runtime     0.9.1
+google.nodejs.npm         1.0.0
+google.utils.label-image  0.0.2
+Timer: Detector ran for 1.2s and ended at 2023-01-01T00:00:01Z
+===> RESTORING
+google.nodejs.npm 1.0.0
+`,
+			want: `group 1:
+  fail: google.config.flex
+  pass: google.nodejs.runtime
+  pass: google.utils.label-image
+group 2:
+  pass: google.nodejs.runtime
+  skip: google.utils.archive-source
+  pass: google.nodejs.npm
+participating:
+  google.nodejs.runtime
+  google.nodejs.npm
+  google.utils.label-image
+`,
+		},
+		{
+			name: "detector prefix",
+			log: `===> DETECTING
+[detector] ======== Results ========
+[detector] pass: google.nodejs.runtime@0.9.1
+[detector] google.nodejs.runtime 0.9.1
+===> ANALYZING
+[analyzer] google.nodejs.npm 1.0.0
+`,
+			want: `group 1:
+  pass: google.nodejs.runtime
+participating:
+  google.nodejs.runtime
+`,
+		},
+		{
+			name: "no detect output",
+			log:  "===> BUILDING\n",
+			want: "participating:\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := normalizeDetectOutput(tc.log); got != tc.want {
+				t.Errorf("normalizeDetectOutput() got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDetectGoldenPath(t *testing.T) {
+	testCases := []struct {
+		name string
+		want string
+	}{
+		{name: "function with framework", want: "goldens/function_with_framework.golden"},
+		{name: "Yarn 2 PnP function", want: "goldens/yarn_2_pnp_function.golden"},
+		{name: "ESM function!", want: "goldens/esm_function.golden"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := detectGoldenPath("goldens", tc.name); got != tc.want {
+				t.Errorf("detectGoldenPath(%q) = %q, want %q", tc.name, got, tc.want)
+			}
+		})
+	}
+}
@@ -38,6 +38,9 @@ func prepareEnvFailureTest(t *testing.T, fTest FailureTest) map[string]string {
 	if !fTest.SkipBuilderOutputMatch {
 		env["BUILDER_OUTPUT"] = "/tmp/builderoutput"
 		env["EXPECTED_BUILDER_OUTPUT"] = fTest.MustMatch
+		if fTest.MustMatchCode != "" {
+			env["EXPECTED_BUILDER_ERROR_CODE"] = string(fTest.MustMatchCode)
+		}
 	}
 	if shouldApplyRuntimeVersion(env) {
 		applyRuntimeVersion(t, env, runtimeVersion)
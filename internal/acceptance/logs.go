@@ -0,0 +1,112 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acceptance
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// LogMatch describes lines expected in the output of the run container.
+type LogMatch struct {
+	// Pattern is a regular expression matched against each line of the stdout and stderr of the
+	// container.
+	Pattern string
+	// Times specifies the exact number of lines that must match, if 0 at least one line must match.
+	Times int
+}
+
+// logsTimeout is how long the output of the container is polled for lines that are logged after
+// the responses to the test requests, e.g. by a background goroutine or a buffered logger.
+var logsTimeout = 10 * time.Second
+
+// verifyContainerLogs checks the output of the container against MustMatchLogs and MustNotMatchLogs.
+func verifyContainerLogs(t *testing.T, containerID string, mustMatch []LogMatch, mustNotMatch []string) {
+	t.Helper()
+	if len(mustMatch) == 0 && len(mustNotMatch) == 0 {
+		return
+	}
+	deadline := time.Now().Add(logsTimeout)
+	for {
+		out, err := runCombinedOutput(container("logs", containerID)...)
+		if err != nil {
+			t.Fatalf("Error fetching the logs of container %s: %v", containerID, err)
+		}
+		lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+		errs := checkLogs(lines, mustMatch, mustNotMatch)
+		if len(errs) == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			for _, e := range errs {
+				t.Errorf("Container logs: %s", e)
+			}
+			return
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// checkLogs returns a description of each log assertion that the lines fail. The patterns of
+// mustMatch must match in order: the first line that matches a pattern must follow the first line
+// that matches the previous pattern.
+func checkLogs(lines []string, mustMatch []LogMatch, mustNotMatch []string) []string {
+	var errs []string
+	prev, prevPattern := -1, ""
+	for _, m := range mustMatch {
+		re, err := regexp.Compile(m.Pattern)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("bad regexp %q: %v", m.Pattern, err))
+			continue
+		}
+		first, count := -1, 0
+		for i, l := range lines {
+			if re.MatchString(l) {
+				if first < 0 {
+					first = i
+				}
+				count++
+			}
+		}
+		switch {
+		case count == 0:
+			errs = append(errs, fmt.Sprintf("no line matches %q", m.Pattern))
+			continue
+		case m.Times > 0 && count != m.Times:
+			errs = append(errs, fmt.Sprintf("%d lines match %q, want %d", count, m.Pattern, m.Times))
+		}
+		if first < prev {
+			errs = append(errs, fmt.Sprintf("%q matches line %d, before %q on line %d", m.Pattern, first+1, prevPattern, prev+1))
+		}
+		prev, prevPattern = first, m.Pattern
+	}
+	for _, pattern := range mustNotMatch {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("bad regexp %q: %v", pattern, err))
+			continue
+		}
+		for i, l := range lines {
+			if re.MatchString(l) {
+				errs = append(errs, fmt.Sprintf("line %d matches %q: %q", i+1, pattern, l))
+				break
+			}
+		}
+	}
+	return errs
+}
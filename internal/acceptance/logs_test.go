@@ -0,0 +1,79 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acceptance
+
+import (
+	"testing"
+)
+
+func TestCheckLogs(t *testing.T) {
+	lines := []string{
+		"Serving function...",
+		"Function: helloWorld",
+		"WARNING: the --foo flag is deprecated",
+		"Listening on port 8080",
+		"GET / 200",
+		"GET / 200",
+	}
+	testCases := []struct {
+		name         string
+		mustMatch    []LogMatch
+		mustNotMatch []string
+		wantErrors   int
+	}{
+		{
+			name:         "in order",
+			mustMatch:    []LogMatch{{Pattern: "^Serving function"}, {Pattern: "Function: helloWorld"}, {Pattern: "port \\d+$"}},
+			mustNotMatch: []string{"(?i)error"},
+		},
+		{
+			name:       "out of order",
+			mustMatch:  []LogMatch{{Pattern: "Listening on port"}, {Pattern: "^Serving function"}},
+			wantErrors: 1,
+		},
+		{
+			name:      "exactly once",
+			mustMatch: []LogMatch{{Pattern: "deprecated", Times: 1}},
+		},
+		{
+			name:       "wrong count",
+			mustMatch:  []LogMatch{{Pattern: "GET / 200", Times: 1}},
+			wantErrors: 1,
+		},
+		{
+			name:       "missing",
+			mustMatch:  []LogMatch{{Pattern: "Started"}},
+			wantErrors: 1,
+		},
+		{
+			name:         "must not match",
+			mustNotMatch: []string{"WARNING"},
+			wantErrors:   1,
+		},
+		{
+			name:         "bad regexp",
+			mustMatch:    []LogMatch{{Pattern: "("}},
+			mustNotMatch: []string{"["},
+			wantErrors:   2,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if errs := checkLogs(lines, tc.mustMatch, tc.mustNotMatch); len(errs) != tc.wantErrors {
+				t.Errorf("checkLogs() = %q, want %d errors", errs, tc.wantErrors)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acceptance
+
+import (
+	"fmt"
+	"strings"
+)
+
+// targetPlatform is the platform of the images under test, e.g. "linux/arm64"; optional.
+var targetPlatform string
+
+// platformArgs returns the flags that select -platform in commands that pull, build or run
+// images, or nil to use the platform of the container runtime.
+func platformArgs() []string {
+	if targetPlatform == "" {
+		return nil
+	}
+	return []string{"--platform", targetPlatform}
+}
+
+// osArch returns the "<os>/<arch>" prefix of a platform, dropping the variant, e.g. "linux/arm64"
+// for "linux/arm64/v8". Image configs do not always record the variant, so it is not compared.
+func osArch(platform string) string {
+	parts := strings.SplitN(platform, "/", 3)
+	if len(parts) < 2 {
+		return platform
+	}
+	return parts[0] + "/" + parts[1]
+}
+
+// imagePlatform returns the "<os>/<arch>" platform of a local image.
+func imagePlatform(image string) (string, error) {
+	out, err := runOutput(container("image", "inspect", "--format={{.Os}}/{{.Architecture}}", image)...)
+	if err != nil {
+		return "", fmt.Errorf("inspecting platform of %q: %w", image, err)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// matchesTargetPlatform reports whether an image platform matches -platform. Any platform matches
+// if -platform is not set.
+func matchesTargetPlatform(platform string) bool {
+	return targetPlatform == "" || osArch(platform) == osArch(targetPlatform)
+}
+
+// verifyImagePlatform returns an error if a local image was not built for -platform, e.g. if a
+// single-architecture builder produced an image for the architecture of the host.
+func verifyImagePlatform(image string) error {
+	if targetPlatform == "" {
+		return nil
+	}
+	platform, err := imagePlatform(image)
+	if err != nil {
+		return err
+	}
+	if !matchesTargetPlatform(platform) {
+		return fmt.Errorf("image %q is for platform %s, want %s", image, platform, osArch(targetPlatform))
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acceptance
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPlatformArgs(t *testing.T) {
+	testCases := []struct {
+		platform string
+		want     []string
+	}{
+		{
+			platform: "",
+			want:     nil,
+		},
+		{
+			platform: "linux/arm64",
+			want:     []string{"--platform", "linux/arm64"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.platform, func(t *testing.T) {
+			setTargetPlatform(t, tc.platform)
+
+			if diff := cmp.Diff(tc.want, platformArgs()); diff != "" {
+				t.Errorf("platformArgs() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMatchesTargetPlatform(t *testing.T) {
+	testCases := []struct {
+		name     string
+		target   string
+		platform string
+		want     bool
+	}{
+		{
+			name:     "no target platform",
+			platform: "linux/amd64",
+			want:     true,
+		},
+		{
+			name:     "same platform",
+			target:   "linux/arm64",
+			platform: "linux/arm64",
+			want:     true,
+		},
+		{
+			name:     "variant is ignored",
+			target:   "linux/arm64/v8",
+			platform: "linux/arm64",
+			want:     true,
+		},
+		{
+			name:     "different architecture",
+			target:   "linux/arm64",
+			platform: "linux/amd64",
+			want:     false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			setTargetPlatform(t, tc.target)
+
+			if got := matchesTargetPlatform(tc.platform); got != tc.want {
+				t.Errorf("matchesTargetPlatform(%q) = %t, want %t", tc.platform, got, tc.want)
+			}
+		})
+	}
+}
+
+func setTargetPlatform(t *testing.T, platform string) {
+	t.Helper()
+	orig := targetPlatform
+	targetPlatform = platform
+	t.Cleanup(func() { targetPlatform = orig })
+}
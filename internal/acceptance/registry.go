@@ -0,0 +1,111 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acceptance
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+var (
+	dockerConfig         string // Path to a Docker config directory with the registry credentials; optional.
+	registryUsername     string // Username used to log in to the registries of the builder and run images; optional.
+	registryPasswordFile string // Path to a file containing the password or token of registryUsername.
+	// loggedInRegistries are the registries that were logged in to with registryUsername.
+	loggedInRegistries = map[string]bool{}
+)
+
+// defaultRegistry is the registry of image references that do not name one, e.g. "ubuntu:22.04".
+const defaultRegistry = "docker.io"
+
+// setUpRegistryAuth configures the credentials used by docker and pack to pull images from
+// remote registries.
+func setUpRegistryAuth() error {
+	if dockerConfig != "" {
+		// Both docker and pack read the credentials and credential helpers from $DOCKER_CONFIG.
+		if err := os.Setenv("DOCKER_CONFIG", dockerConfig); err != nil {
+			return fmt.Errorf("setting DOCKER_CONFIG: %v", err)
+		}
+	}
+	if registryUsername != "" && registryPasswordFile == "" {
+		return fmt.Errorf("-registry-password-file must be set with -registry-username")
+	}
+	return nil
+}
+
+// pullImage pulls the image if -pull-images is set or if the image is not present in the local
+// daemon, e.g. a builder image published to a remote registry. It logs in to the registry of the
+// image first if -registry-username is set.
+func pullImage(image string) error {
+	if !pullImages {
+		// A local image for another platform, e.g. the host platform, is replaced with the image for
+		// -platform.
+		if platform, err := imagePlatform(image); err == nil && matchesTargetPlatform(platform) {
+			return nil
+		}
+		if targetPlatform != "" {
+			log.Printf("Image %s for %s not found locally, pulling it", image, targetPlatform)
+		} else {
+			log.Printf("Image %s not found locally, pulling it", image)
+		}
+	}
+	if err := registryLogin(registryHost(image)); err != nil {
+		return err
+	}
+	args := append([]string{"pull"}, platformArgs()...)
+	if _, err := runOutput(container(append(args, image)...)...); err != nil {
+		return fmt.Errorf("pulling %q: %w", image, err)
+	}
+	return nil
+}
+
+// registryLogin logs in to the registry with -registry-username, once per registry.
+func registryLogin(registry string) error {
+	if registryUsername == "" || loggedInRegistries[registry] {
+		return nil
+	}
+	password, err := ioutil.ReadFile(registryPasswordFile)
+	if err != nil {
+		return fmt.Errorf("reading registry password: %v", err)
+	}
+	log.Printf("Logging in to %s as %s", registry, registryUsername)
+	args := container("login", "--username", registryUsername, "--password-stdin", registry)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(bytes.TrimSpace(password))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("logging in to %s: %v\n%s", registry, err, out)
+	}
+	loggedInRegistries[registry] = true
+	return nil
+}
+
+// registryHost returns the registry of an image reference, following the rules of docker: the
+// first component of the reference is a registry if it contains a "." or a ":", or is "localhost".
+func registryHost(image string) string {
+	i := strings.Index(image, "/")
+	if i < 0 {
+		return defaultRegistry
+	}
+	host := image[:i]
+	if host == "localhost" || strings.ContainsAny(host, ".:") {
+		return host
+	}
+	return defaultRegistry
+}
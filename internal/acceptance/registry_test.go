@@ -0,0 +1,71 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acceptance
+
+import "testing"
+
+func TestRegistryHost(t *testing.T) {
+	testCases := []struct {
+		image string
+		want  string
+	}{
+		{image: "ubuntu:22.04", want: "docker.io"},
+		{image: "paketobuildpacks/builder:base", want: "docker.io"},
+		{image: "gcr.io/buildpacks/builder:v1", want: "gcr.io"},
+		{image: "us-docker.pkg.dev/my-project/builders/google-22@sha256:0123", want: "us-docker.pkg.dev"},
+		{image: "localhost:5000/builder", want: "localhost:5000"},
+		{image: "localhost/builder", want: "localhost"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.image, func(t *testing.T) {
+			if got := registryHost(tc.image); got != tc.want {
+				t.Errorf("registryHost(%q) = %q, want %q", tc.image, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSetUpRegistryAuth(t *testing.T) {
+	testCases := []struct {
+		name         string
+		username     string
+		passwordFile string
+		wantErr      bool
+	}{
+		{
+			name: "no credentials",
+		},
+		{
+			name:         "username and password",
+			username:     "oauth2accesstoken",
+			passwordFile: "/tmp/token",
+		},
+		{
+			name:     "missing password",
+			username: "oauth2accesstoken",
+			wantErr:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			registryUsername, registryPasswordFile = tc.username, tc.passwordFile
+			t.Cleanup(func() { registryUsername, registryPasswordFile = "", "" })
+
+			if err := setUpRegistryAuth(); (err != nil) != tc.wantErr {
+				t.Errorf("setUpRegistryAuth() got error: %v, want error? %t", err, tc.wantErr)
+			}
+		})
+	}
+}
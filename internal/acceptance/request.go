@@ -0,0 +1,218 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acceptance
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Request describes an HTTP request sent to the application and the assertions on its response.
+// The requests of a test are sent in order, so earlier requests can warm up the application.
+type Request struct {
+	// Path specifies the URL path of the request, Test.Path is used if empty.
+	Path string
+	// Method specifies the HTTP method, GET is used if empty.
+	Method string
+	// Headers specifies the request headers.
+	Headers map[string]string
+	// Body specifies the request body.
+	Body string
+	// Concurrent specifies the number of copies of the request that are sent at the same time, the
+	// assertions apply to each response.
+	Concurrent int
+	// WarmUp is true if the response is not checked, e.g. for a request that initializes the app.
+	WarmUp bool
+	// MustMatchStatusCode specifies the expected status code, if not provided 200 will be used.
+	MustMatchStatusCode int
+	// MustMatch specifies a suffix of the response body.
+	MustMatch string
+	// MustContain specifies strings to be found in the response body.
+	MustContain []string
+	// MustMatchHeaders specifies regular expressions that the response headers must match.
+	MustMatchHeaders map[string]string
+	// MustMatchJSON specifies the expected values of paths in the JSON response body, e.g.
+	// {"env.FOO": "bar", "items.0.id": "1"}. Values that are not strings are compared to their JSON
+	// encoding, e.g. "42", "true" or "null".
+	MustMatchJSON map[string]string
+}
+
+// response is an HTTP response read in full.
+type response struct {
+	statusCode int
+	header     http.Header
+	body       string
+}
+
+// sendRequests sends the requests of a test in order and checks their responses.
+func sendRequests(t *testing.T, host string, port int, defaultPath string, requests []Request) {
+	t.Helper()
+	for i, r := range requests {
+		if r.Path == "" {
+			r.Path = defaultPath
+		}
+		n := r.Concurrent
+		if n < 1 {
+			n = 1
+		}
+		start := time.Now()
+		responses := make([]*response, n)
+		errs := make([]error, n)
+		var wg sync.WaitGroup
+		for c := 0; c < n; c++ {
+			wg.Add(1)
+			go func(c int) {
+				defer wg.Done()
+				responses[c], errs[c] = sendHTTPRequest(host, port, r, 120*time.Second)
+			}(c)
+		}
+		wg.Wait()
+		for c, res := range responses {
+			if errs[c] != nil {
+				t.Fatalf("Unable to send request %d (%s %s): %v", i, r.method(), r.Path, errs[c])
+			}
+			t.Logf("Request %d (%s %s): status %d, body %q (in %s)", i, r.method(), r.Path, res.statusCode, res.body, time.Since(start))
+			if r.WarmUp {
+				continue
+			}
+			for _, e := range checkResponse(r, res) {
+				t.Errorf("Request %d (%s %s): %s", i, r.method(), r.Path, e)
+			}
+		}
+	}
+}
+
+func (r Request) method() string {
+	if r.Method == "" {
+		return http.MethodGet
+	}
+	return r.Method
+}
+
+// sendHTTPRequest sends a request to host:port, retrying until the application accepts connections
+// or the timeout expires.
+func sendHTTPRequest(host string, port int, r Request, timeout time.Duration) (*response, error) {
+	url := fmt.Sprintf("http://%s:%d%s", host, port, r.Path)
+	sleep := 100 * time.Millisecond
+	deadline := time.Now().Add(timeout)
+	for {
+		req, err := http.NewRequest(r.method(), url, strings.NewReader(r.Body))
+		if err != nil {
+			return nil, fmt.Errorf("creating request: %w", err)
+		}
+		for k, v := range r.Headers {
+			req.Header.Set(k, v)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			if time.Now().After(deadline) {
+				return nil, fmt.Errorf("error making request: %w", err)
+			}
+			time.Sleep(sleep)
+			continue
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading body: %w", err)
+		}
+		return &response{statusCode: res.StatusCode, header: res.Header, body: strings.TrimSpace(string(body))}, nil
+	}
+}
+
+// checkResponse returns a description of each assertion of the request that the response fails.
+func checkResponse(r Request, res *response) []string {
+	var errs []string
+	wantCode := http.StatusOK
+	if r.MustMatchStatusCode != 0 {
+		wantCode = r.MustMatchStatusCode
+	}
+	if res.statusCode != wantCode {
+		errs = append(errs, fmt.Sprintf("unexpected status code: got %d, want %d", res.statusCode, wantCode))
+	}
+	if !strings.HasSuffix(res.body, r.MustMatch) {
+		errs = append(errs, fmt.Sprintf("response body does not contain suffix: got %q, want %q", res.body, r.MustMatch))
+	}
+	for _, s := range r.MustContain {
+		if !strings.Contains(res.body, s) {
+			errs = append(errs, fmt.Sprintf("response body does not contain %q: got %q", s, res.body))
+		}
+	}
+	for name, pattern := range r.MustMatchHeaders {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("bad regexp %q for header %s: %v", pattern, name, err))
+			continue
+		}
+		if got := res.header.Get(name); !re.MatchString(got) {
+			errs = append(errs, fmt.Sprintf("header %s does not match: got %q, want %q", name, got, pattern))
+		}
+	}
+	if len(r.MustMatchJSON) > 0 {
+		var doc interface{}
+		if err := json.Unmarshal([]byte(res.body), &doc); err != nil {
+			return append(errs, fmt.Sprintf("response body is not JSON: %v, got %q", err, res.body))
+		}
+		for path, want := range r.MustMatchJSON {
+			got, err := jsonPathValue(doc, path)
+			if err != nil {
+				errs = append(errs, err.Error())
+			} else if got != want {
+				errs = append(errs, fmt.Sprintf("JSON path %q: got %s, want %s", path, got, want))
+			}
+		}
+	}
+	return errs
+}
+
+// jsonPathValue returns the value at a dot-separated path in a decoded JSON document, array elements
+// are selected with their index. Strings are returned as is and other values JSON-encoded.
+func jsonPathValue(doc interface{}, path string) (string, error) {
+	v := doc
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			child, ok := node[key]
+			if !ok {
+				return "", fmt.Errorf("JSON path %q: key %q not found", path, key)
+			}
+			v = child
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return "", fmt.Errorf("JSON path %q: index %q out of range for array of length %d", path, key, len(node))
+			}
+			v = node[i]
+		default:
+			return "", fmt.Errorf("JSON path %q: cannot select %q in a %T", path, key, v)
+		}
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("JSON path %q: %v", path, err)
+	}
+	return string(b), nil
+}
@@ -0,0 +1,137 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acceptance
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONPathValue(t *testing.T) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(`{"env": {"FOO": "bar"}, "items": [{"id": 1}, {"id": 2}], "ok": true, "none": null}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{path: "env.FOO", want: "bar"},
+		{path: "items.1.id", want: "2"},
+		{path: "ok", want: "true"},
+		{path: "none", want: "null"},
+		{path: "env", want: `{"FOO":"bar"}`},
+		{path: "env.BAR", wantErr: true},
+		{path: "items.2.id", wantErr: true},
+		{path: "ok.value", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			got, err := jsonPathValue(doc, tc.path)
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("jsonPathValue(%q) got error: %v, want error? %v", tc.path, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("jsonPathValue(%q) = %q, want %q", tc.path, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckResponse(t *testing.T) {
+	res := &response{
+		statusCode: http.StatusOK,
+		header:     http.Header{"Content-Type": []string{"application/json; charset=utf-8"}},
+		body:       `{"env": {"FOO": "bar"}, "count": 3}`,
+	}
+	testCases := []struct {
+		name       string
+		request    Request
+		wantErrors int
+	}{
+		{
+			name: "all assertions pass",
+			request: Request{
+				MustContain:      []string{`"FOO"`},
+				MustMatchHeaders: map[string]string{"Content-Type": "^application/json"},
+				MustMatchJSON:    map[string]string{"env.FOO": "bar", "count": "3"},
+			},
+		},
+		{
+			name:       "status code",
+			request:    Request{MustMatchStatusCode: http.StatusNotFound},
+			wantErrors: 1,
+		},
+		{
+			name:       "suffix",
+			request:    Request{MustMatch: "PASS"},
+			wantErrors: 1,
+		},
+		{
+			name:       "header",
+			request:    Request{MustMatchHeaders: map[string]string{"Content-Type": "^text/html", "X-Missing": ".+"}},
+			wantErrors: 2,
+		},
+		{
+			name:       "json",
+			request:    Request{MustMatchJSON: map[string]string{"env.FOO": "baz", "env.BAR": "bar"}},
+			wantErrors: 2,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if errs := checkResponse(tc.request, res); len(errs) != tc.wantErrors {
+				t.Errorf("checkResponse() = %q, want %d errors", errs, tc.wantErrors)
+			}
+		})
+	}
+}
+
+func TestSendHTTPRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		w.Write([]byte(r.URL.Path + " " + r.Header.Get("X-Test") + " " + string(b) + "\n"))
+	}))
+	defer srv.Close()
+	host, portStr, err := net.SplitHostPort(strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := Request{Path: "/echo", Method: http.MethodPost, Headers: map[string]string{"X-Test": "yes"}, Body: "hello"}
+	res, err := sendHTTPRequest(host, port, r, time.Second)
+	if err != nil {
+		t.Fatalf("sendHTTPRequest() got error: %v", err)
+	}
+	if want := "/echo yes hello"; res.body != want {
+		t.Errorf("sendHTTPRequest() body = %q, want %q", res.body, want)
+	}
+	if got := res.header.Get("X-Method"); got != http.MethodPost {
+		t.Errorf("sendHTTPRequest() method = %q, want %q", got, http.MethodPost)
+	}
+}
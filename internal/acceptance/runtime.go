@@ -0,0 +1,146 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acceptance
+
+import (
+	"fmt"
+	"os"
+)
+
+var (
+	containerRuntimeName string // Container CLI used to manage images and containers: docker, podman or nerdctl.
+	dockerHost           string // Docker API endpoint used by pack; optional.
+	// packDockerHost is the Docker API endpoint used by pack, it is empty for the default daemon.
+	packDockerHost string
+	// containerCLI is the container runtime selected by -container-runtime.
+	containerCLI containerRuntime = dockerRuntime{}
+)
+
+// containerRuntime is a container CLI that manages the images and containers of the tests. The
+// CLIs implement the subset of the docker commands used by the tests.
+type containerRuntime interface {
+	// name returns the name of the CLI binary.
+	name() string
+	// cmd returns the command line that runs the CLI with the given arguments.
+	cmd(args ...string) []string
+	// dockerHost returns the Docker API endpoint that pack uses to build images in the image store
+	// of the runtime, or "" for the default Docker daemon.
+	dockerHost() (string, error)
+}
+
+type dockerRuntime struct{}
+
+func (dockerRuntime) name() string {
+	return "docker"
+}
+
+func (r dockerRuntime) cmd(args ...string) []string {
+	return append([]string{r.name()}, args...)
+}
+
+func (dockerRuntime) dockerHost() (string, error) {
+	return dockerHost, nil
+}
+
+// podmanRuntime uses podman, pack builds images through the Docker compatible API of the podman
+// service, which must be running, e.g. with `systemctl --user start podman.socket`.
+type podmanRuntime struct{}
+
+func (podmanRuntime) name() string {
+	return "podman"
+}
+
+func (r podmanRuntime) cmd(args ...string) []string {
+	return append([]string{r.name()}, args...)
+}
+
+func (r podmanRuntime) dockerHost() (string, error) {
+	if dockerHost != "" {
+		return dockerHost, nil
+	}
+	socket, err := runOutput(r.cmd("info", "--format={{.Host.RemoteSocket.Path}}")...)
+	if err != nil {
+		return "", fmt.Errorf("getting the podman API socket: %w", err)
+	}
+	if socket == "" {
+		return "", fmt.Errorf("podman API socket not found, start the podman service or set -docker-host")
+	}
+	return "unix://" + socket, nil
+}
+
+// nerdctlRuntime uses nerdctl, the namespace of the containerd image store is set with
+// $CONTAINERD_NAMESPACE. containerd has no Docker compatible API, so pack builds images through
+// the Docker daemon at -docker-host, which must use the same containerd image store.
+type nerdctlRuntime struct{}
+
+func (nerdctlRuntime) name() string {
+	return "nerdctl"
+}
+
+func (r nerdctlRuntime) cmd(args ...string) []string {
+	return append([]string{r.name()}, args...)
+}
+
+func (nerdctlRuntime) dockerHost() (string, error) {
+	if dockerHost == "" {
+		return "", fmt.Errorf("-docker-host must be set with nerdctl, pack requires a Docker API to build images")
+	}
+	return dockerHost, nil
+}
+
+// newContainerRuntime returns the container runtime with the given name.
+func newContainerRuntime(name string) (containerRuntime, error) {
+	switch name {
+	case "", "docker":
+		return dockerRuntime{}, nil
+	case "podman":
+		return podmanRuntime{}, nil
+	case "nerdctl":
+		return nerdctlRuntime{}, nil
+	}
+	return nil, fmt.Errorf("unsupported container runtime %q, want one of docker, podman or nerdctl", name)
+}
+
+// setUpContainerRuntime selects the container runtime of -container-runtime.
+func setUpContainerRuntime() error {
+	r, err := newContainerRuntime(containerRuntimeName)
+	if err != nil {
+		return err
+	}
+	containerCLI = r
+	return nil
+}
+
+// setUpPackDockerHost points pack to the Docker API of the container runtime.
+func setUpPackDockerHost() error {
+	host, err := containerCLI.dockerHost()
+	if err != nil {
+		return err
+	}
+	packDockerHost = host
+	if host == "" {
+		return nil
+	}
+	// pack reads the Docker API endpoint from $DOCKER_HOST.
+	if err := os.Setenv("DOCKER_HOST", host); err != nil {
+		return fmt.Errorf("setting DOCKER_HOST: %v", err)
+	}
+	return nil
+}
+
+// container returns the command line that runs the container CLI with the given arguments.
+func container(args ...string) []string {
+	return containerCLI.cmd(args...)
+}
@@ -0,0 +1,116 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acceptance
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewContainerRuntime(t *testing.T) {
+	testCases := []struct {
+		name    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "",
+			want: []string{"docker", "ps"},
+		},
+		{
+			name: "docker",
+			want: []string{"docker", "ps"},
+		},
+		{
+			name: "podman",
+			want: []string{"podman", "ps"},
+		},
+		{
+			name: "nerdctl",
+			want: []string{"nerdctl", "ps"},
+		},
+		{
+			name:    "rkt",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := newContainerRuntime(tc.name)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("newContainerRuntime(%q) got error: %v, want error? %t", tc.name, err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.want, r.cmd("ps")); diff != "" {
+				t.Errorf("cmd() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDockerHost(t *testing.T) {
+	testCases := []struct {
+		name       string
+		runtime    containerRuntime
+		dockerHost string
+		want       string
+		wantErr    bool
+	}{
+		{
+			name:    "docker default daemon",
+			runtime: dockerRuntime{},
+		},
+		{
+			name:       "docker",
+			runtime:    dockerRuntime{},
+			dockerHost: "tcp://localhost:2375",
+			want:       "tcp://localhost:2375",
+		},
+		{
+			name:       "podman",
+			runtime:    podmanRuntime{},
+			dockerHost: "unix:///run/user/1000/podman/podman.sock",
+			want:       "unix:///run/user/1000/podman/podman.sock",
+		},
+		{
+			name:       "nerdctl",
+			runtime:    nerdctlRuntime{},
+			dockerHost: "unix:///run/docker.sock",
+			want:       "unix:///run/docker.sock",
+		},
+		{
+			name:    "nerdctl without docker host",
+			runtime: nerdctlRuntime{},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dockerHost = tc.dockerHost
+			t.Cleanup(func() { dockerHost = "" })
+
+			got, err := tc.runtime.dockerHost()
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("dockerHost() got error: %v, want error? %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("dockerHost() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,353 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package builderconfig validates the builder.toml descriptors in this repository against the
+// structural invariants the builders rely on. The checks are fast enough to run as a unit test,
+// catching order group mistakes that would otherwise only surface in acceptance tests.
+package builderconfig
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+const (
+	// tagGCF marks builders whose function order groups run on Cloud Functions and must archive the
+	// function source.
+	tagGCF = "gcf"
+	// tagLegacyWorker marks builders that may contain the legacy worker order groups for the oldest
+	// Cloud Functions runtimes (nodejs8, nodejs10 and go111).
+	tagLegacyWorker = "legacy-worker"
+
+	// defaultAPI is the buildpack API version used by the buildpack() Bazel macro.
+	defaultAPI = "0.8"
+)
+
+// Spec describes a builder descriptor and the tags that select the rules that apply to it.
+type Spec struct {
+	// Path is the path of the builder.toml relative to the repository root.
+	Path string
+	// Tags select the rules that apply to the builder in addition to the untagged rules.
+	Tags []string
+}
+
+// Builders lists every builder descriptor in the repository.
+var Builders = []Spec{
+	{Path: "builders/gcp/base/builder.toml"},
+	{Path: "builders/dotnet/builder.toml"},
+	{Path: "builders/go/builder.toml", Tags: []string{tagGCF, tagLegacyWorker}},
+	{Path: "builders/java/builder.toml"},
+	{Path: "builders/nodejs/builder.toml", Tags: []string{tagGCF, tagLegacyWorker}},
+	{Path: "builders/php/builder.toml", Tags: []string{tagGCF}},
+	{Path: "builders/python/builder.toml", Tags: []string{tagGCF}},
+	{Path: "builders/ruby/builder.toml", Tags: []string{tagGCF}},
+}
+
+// Rule is a structural invariant on the order groups of a builder. Buildpack IDs are matched as
+// path.Match patterns, e.g. "google.*.functions-framework".
+type Rule struct {
+	// Name identifies the rule in error messages.
+	Name string
+	// Tags restricts the rule to builders with at least one of the tags. Empty means all builders.
+	Tags []string
+	// ExceptTags excludes builders with any of the tags.
+	ExceptTags []string
+	// When restricts the rule to groups that contain a buildpack matching one of the patterns.
+	// Empty means all groups.
+	When []string
+	// Unless excludes groups that contain a buildpack matching one of the patterns.
+	Unless []string
+	// Require lists patterns of which at least one must match a buildpack in the group.
+	Require []string
+	// NotOptional lists patterns of buildpacks that must not be optional when present.
+	NotOptional []string
+	// Forbid lists patterns of buildpacks that must not appear in the group.
+	Forbid []string
+}
+
+// Rules lists the invariants checked for every builder.
+var Rules = []Rule{
+	{
+		Name: "label-image",
+		// Missing entrypoint groups only exist to fail the build with a helpful message. Dart images
+		// are not labeled yet.
+		Unless:      []string{"google.*.missing-entrypoint", "google.dart.*"},
+		Require:     []string{"google.utils.label-image"},
+		NotOptional: []string{"google.utils.label-image"},
+	},
+	{
+		Name: "entrypoint",
+		Require: []string{
+			"google.config.entrypoint",
+			"google.config.flex",
+			"google.*.appengine",
+			"google.*.functions-framework",
+			"google.*.legacy-worker",
+			"google.*.missing-entrypoint",
+			"google.dart.compile",
+			"google.go.build",
+			"google.java.entrypoint",
+			"google.java.exploded-jar",
+			"google.php.webconfig",
+			"google.python.asgi",
+			"google.ruby.server",
+			"google.web.static",
+		},
+	},
+	{
+		Name:    "functions-archive-source",
+		Tags:    []string{tagGCF},
+		When:    []string{"google.*.functions-framework", "google.*.legacy-worker"},
+		Require: []string{"google.utils.archive-source"},
+	},
+	{
+		Name:       "legacy-worker-builders",
+		ExceptTags: []string{tagLegacyWorker},
+		Forbid:     []string{"google.*.legacy-worker"},
+	},
+	{
+		Name:        "legacy-worker-optional",
+		Tags:        []string{tagLegacyWorker},
+		NotOptional: []string{"google.*.legacy-worker"},
+	},
+}
+
+// Builder is the subset of a builder.toml that is validated.
+type Builder struct {
+	Buildpacks []BuildpackRef `toml:"buildpacks"`
+	Order      []Order        `toml:"order"`
+}
+
+// BuildpackRef is a [[buildpacks]] entry of a builder.toml.
+type BuildpackRef struct {
+	ID  string `toml:"id"`
+	URI string `toml:"uri"`
+}
+
+// Order is an [[order]] entry of a builder.toml.
+type Order struct {
+	Group []GroupEntry `toml:"group"`
+}
+
+// GroupEntry is a buildpack in an order group.
+type GroupEntry struct {
+	ID       string `toml:"id"`
+	Optional bool   `toml:"optional"`
+}
+
+// Buildpack is a buildpack defined by a buildpack() rule in a BUILD.bazel file under cmd/.
+type Buildpack struct {
+	// ID is the buildpack ID, e.g. google.nodejs.npm.
+	ID string
+	// Name is the name of the buildpack() rule, which is also the base name of its archive.
+	Name string
+	// Dir is the directory of the BUILD.bazel file relative to the cmd directory.
+	Dir string
+	// API is the buildpack API version.
+	API string
+}
+
+// ReadBuilder parses the builder.toml at path.
+func ReadBuilder(path string) (*Builder, error) {
+	var b Builder
+	if _, err := toml.DecodeFile(path, &b); err != nil {
+		return nil, fmt.Errorf("decoding %s: %v", path, err)
+	}
+	return &b, nil
+}
+
+var (
+	buildpackRuleRegexp = regexp.MustCompile(`(?s)\bbuildpack\((.*?)\n\)`)
+	nameAttrRegexp      = regexp.MustCompile(`(?m)^\s*name = "([^"]+)"`)
+	prefixAttrRegexp    = regexp.MustCompile(`(?m)^\s*prefix = "([^"]+)"`)
+	apiAttrRegexp       = regexp.MustCompile(`(?m)^\s*api = "([^"]+)"`)
+)
+
+// FindBuildpacks returns the buildpacks defined in the BUILD.bazel files under cmdDir, keyed by ID.
+func FindBuildpacks(cmdDir string) (map[string]Buildpack, error) {
+	result := map[string]Buildpack{}
+	err := filepath.Walk(cmdDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || info.Name() != "BUILD.bazel" {
+			return nil
+		}
+		content, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		bp, ok, err := parseBuildFile(string(content))
+		if err != nil {
+			return fmt.Errorf("parsing %s: %v", p, err)
+		}
+		if !ok {
+			return nil
+		}
+		dir, err := filepath.Rel(cmdDir, filepath.Dir(p))
+		if err != nil {
+			return err
+		}
+		bp.Dir = filepath.ToSlash(dir)
+		if other, ok := result[bp.ID]; ok {
+			return fmt.Errorf("buildpack %q is defined in both cmd/%s and cmd/%s", bp.ID, other.Dir, bp.Dir)
+		}
+		result[bp.ID] = bp
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// parseBuildFile returns the buildpack defined by the buildpack() rule in the BUILD.bazel content,
+// if any. The ID is derived the same way as in the buildpack() macro.
+func parseBuildFile(content string) (Buildpack, bool, error) {
+	m := buildpackRuleRegexp.FindStringSubmatch(content)
+	if m == nil {
+		return Buildpack{}, false, nil
+	}
+	rule := m[1]
+	name := nameAttrRegexp.FindStringSubmatch(rule)
+	prefix := prefixAttrRegexp.FindStringSubmatch(rule)
+	if name == nil || prefix == nil {
+		return Buildpack{}, false, fmt.Errorf("buildpack() rule is missing name or prefix")
+	}
+	api := defaultAPI
+	if a := apiAttrRegexp.FindStringSubmatch(rule); a != nil {
+		api = a[1]
+	}
+	return Buildpack{
+		ID:   fmt.Sprintf("google.%s.%s", prefix[1], strings.ReplaceAll(name[1], "_", "-")),
+		Name: name[1],
+		API:  api,
+	}, true, nil
+}
+
+// Validate returns every invariant violated by the builder described by spec.
+func Validate(spec Spec, b *Builder, buildpacks map[string]Buildpack, rules []Rule) []error {
+	var errs []error
+	errorf := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", spec.Path, fmt.Sprintf(format, args...)))
+	}
+
+	declared := map[string]bool{}
+	apis := map[string][]string{}
+	for _, ref := range b.Buildpacks {
+		declared[ref.ID] = true
+		bp, ok := buildpacks[ref.ID]
+		if !ok {
+			errorf("buildpack %q has no matching buildpack() rule in cmd/", ref.ID)
+			continue
+		}
+		if got, want := path.Base(ref.URI), bp.Name+".tgz"; got != want {
+			errorf("buildpack %q has uri %q, want an archive named %q (built from cmd/%s)", ref.ID, ref.URI, want, bp.Dir)
+		}
+		apis[bp.API] = append(apis[bp.API], ref.ID)
+	}
+	if len(apis) > 1 {
+		var versions []string
+		for api, ids := range apis {
+			versions = append(versions, fmt.Sprintf("%s: %v", api, ids))
+		}
+		sort.Strings(versions)
+		errorf("buildpacks use inconsistent buildpack API versions: %s", strings.Join(versions, "; "))
+	}
+
+	for i, o := range b.Order {
+		group := fmt.Sprintf("order group %d %v", i+1, groupIDs(o))
+		for _, e := range o.Group {
+			if !declared[e.ID] {
+				errorf("%s: buildpack %q is not listed in [[buildpacks]]", group, e.ID)
+			}
+		}
+		for _, r := range rules {
+			if !r.appliesTo(spec, o) {
+				continue
+			}
+			if len(r.Require) > 0 && !o.contains(r.Require) {
+				errorf("%s: rule %q: group must contain one of %v", group, r.Name, r.Require)
+			}
+			for _, e := range o.Group {
+				if e.Optional && matchesAny(e.ID, r.NotOptional) {
+					errorf("%s: rule %q: buildpack %q must not be optional", group, r.Name, e.ID)
+				}
+				if matchesAny(e.ID, r.Forbid) {
+					errorf("%s: rule %q: buildpack %q is not allowed in this builder", group, r.Name, e.ID)
+				}
+			}
+		}
+	}
+	return errs
+}
+
+func (r Rule) appliesTo(spec Spec, o Order) bool {
+	if len(r.Tags) > 0 && !hasAnyTag(spec.Tags, r.Tags) {
+		return false
+	}
+	if hasAnyTag(spec.Tags, r.ExceptTags) {
+		return false
+	}
+	if len(r.When) > 0 && !o.contains(r.When) {
+		return false
+	}
+	return !o.contains(r.Unless)
+}
+
+// contains returns true if any buildpack in the group matches one of the patterns.
+func (o Order) contains(patterns []string) bool {
+	for _, e := range o.Group {
+		if matchesAny(e.ID, patterns) {
+			return true
+		}
+	}
+	return false
+}
+
+func groupIDs(o Order) []string {
+	var ids []string
+	for _, e := range o.Group {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
+
+func matchesAny(id string, patterns []string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, id); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAnyTag(tags, want []string) bool {
+	for _, t := range tags {
+		for _, w := range want {
+			if t == w {
+				return true
+			}
+		}
+	}
+	return false
+}
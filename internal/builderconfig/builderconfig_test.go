@@ -0,0 +1,234 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builderconfig
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBuilders(t *testing.T) {
+	root := repoRoot(t)
+	buildpacks, err := FindBuildpacks(filepath.Join(root, "cmd"))
+	if err != nil {
+		t.Fatalf("FindBuildpacks() got err=%v", err)
+	}
+	for _, spec := range Builders {
+		t.Run(spec.Path, func(t *testing.T) {
+			b, err := ReadBuilder(filepath.Join(root, spec.Path))
+			if err != nil {
+				t.Fatalf("ReadBuilder() got err=%v", err)
+			}
+			for _, err := range Validate(spec, b, buildpacks, Rules) {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestBuildersListsEveryBuilder(t *testing.T) {
+	root := repoRoot(t)
+	var got []string
+	err := filepath.Walk(filepath.Join(root, "builders"), func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == "testdata" {
+			return filepath.SkipDir
+		}
+		if info.Name() == "builder.toml" {
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				return err
+			}
+			got = append(got, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walking builders: %v", err)
+	}
+	var want []string
+	for _, spec := range Builders {
+		want = append(want, spec.Path)
+	}
+	if diff := cmp.Diff(want, got, cmpSortStrings); diff != "" {
+		t.Errorf("Builders does not match the builder.toml files in the repository (-want, +got):\n%s", diff)
+	}
+}
+
+func TestValidateBrokenBuilder(t *testing.T) {
+	spec := Spec{Path: "broken_builder.toml", Tags: []string{tagGCF}}
+	b, err := ReadBuilder(filepath.Join(testdataDir(t), spec.Path))
+	if err != nil {
+		t.Fatalf("ReadBuilder() got err=%v", err)
+	}
+	buildpacks := map[string]Buildpack{
+		"google.nodejs.runtime":             {ID: "google.nodejs.runtime", Name: "runtime", Dir: "nodejs/runtime", API: "0.8"},
+		"google.nodejs.functions-framework": {ID: "google.nodejs.functions-framework", Name: "functions_framework", Dir: "nodejs/functions_framework", API: "0.8"},
+		"google.nodejs.legacy-worker":       {ID: "google.nodejs.legacy-worker", Name: "legacy_worker", Dir: "nodejs/legacy_worker", API: "0.8"},
+		"google.nodejs.npm":                 {ID: "google.nodejs.npm", Name: "npm", Dir: "nodejs/npm", API: "0.8"},
+		"google.utils.label-image":          {ID: "google.utils.label-image", Name: "label_image", Dir: "utils/label", API: "0.9"},
+		"google.config.entrypoint":          {ID: "google.config.entrypoint", Name: "entrypoint", Dir: "config/entrypoint", API: "0.8"},
+	}
+
+	want := []string{
+		`broken_builder.toml: buildpack "google.nodejs.npm" has uri "npm_install.tgz", want an archive named "npm.tgz" (built from cmd/nodejs/npm)`,
+		`broken_builder.toml: buildpack "google.nodejs.bun" has no matching buildpack() rule in cmd/`,
+		`broken_builder.toml: buildpacks use inconsistent buildpack API versions: 0.8: [google.nodejs.runtime google.nodejs.functions-framework google.nodejs.legacy-worker google.nodejs.npm]; 0.9: [google.utils.label-image]`,
+		`broken_builder.toml: order group 1 [google.nodejs.runtime google.nodejs.npm]: rule "label-image": group must contain one of [google.utils.label-image]`,
+		`broken_builder.toml: order group 1 [google.nodejs.runtime google.nodejs.npm]: rule "entrypoint": group must contain one of [google.config.entrypoint google.config.flex google.*.appengine google.*.functions-framework google.*.legacy-worker google.*.missing-entrypoint google.dart.compile google.go.build google.java.entrypoint google.java.exploded-jar google.php.webconfig google.python.asgi google.ruby.server google.web.static]`,
+		`broken_builder.toml: order group 2 [google.nodejs.runtime google.nodejs.functions-framework google.utils.label-image]: rule "label-image": buildpack "google.utils.label-image" must not be optional`,
+		`broken_builder.toml: order group 2 [google.nodejs.runtime google.nodejs.functions-framework google.utils.label-image]: rule "functions-archive-source": group must contain one of [google.utils.archive-source]`,
+		`broken_builder.toml: order group 3 [google.nodejs.runtime google.nodejs.legacy-worker google.utils.label-image]: rule "functions-archive-source": group must contain one of [google.utils.archive-source]`,
+		`broken_builder.toml: order group 3 [google.nodejs.runtime google.nodejs.legacy-worker google.utils.label-image]: rule "legacy-worker-builders": buildpack "google.nodejs.legacy-worker" is not allowed in this builder`,
+		`broken_builder.toml: order group 4 [google.nodejs.runtime google.config.entrypoint google.utils.label-image]: buildpack "google.config.entrypoint" is not listed in [[buildpacks]]`,
+	}
+	var got []string
+	for _, err := range Validate(spec, b, buildpacks, Rules) {
+		got = append(got, err.Error())
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Validate() returned unexpected errors (-want, +got):\n%s", diff)
+	}
+}
+
+func TestValidateLegacyWorkerOptional(t *testing.T) {
+	spec := Spec{Path: "builder.toml", Tags: []string{tagLegacyWorker}}
+	b := &Builder{
+		Buildpacks: []BuildpackRef{{ID: "google.go.legacy-worker", URI: "legacy_worker.tgz"}},
+		Order:      []Order{{Group: []GroupEntry{{ID: "google.go.legacy-worker", Optional: true}}}},
+	}
+	buildpacks := map[string]Buildpack{
+		"google.go.legacy-worker": {ID: "google.go.legacy-worker", Name: "legacy_worker", API: "0.8"},
+	}
+	rules := []Rule{{Name: "legacy-worker-optional", Tags: []string{tagLegacyWorker}, NotOptional: []string{"google.*.legacy-worker"}}}
+
+	errs := Validate(spec, b, buildpacks, rules)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), `buildpack "google.go.legacy-worker" must not be optional`) {
+		t.Errorf("Validate() = %v, want a single optional legacy worker error", errs)
+	}
+}
+
+func TestParseBuildFile(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		want    Buildpack
+		wantOK  bool
+		wantErr bool
+	}{
+		{
+			name: "default api",
+			content: `buildpack(
+    name = "label_image",
+    executables = [
+        ":main",
+    ],
+    prefix = "utils",
+    version = "0.0.2",
+)
+
+go_binary(
+    name = "main",
+)`,
+			want:   Buildpack{ID: "google.utils.label-image", Name: "label_image", API: "0.8"},
+			wantOK: true,
+		},
+		{
+			name: "explicit api",
+			content: `buildpack(
+    name = "npm",
+    api = "0.9",
+    prefix = "nodejs",
+)`,
+			want:   Buildpack{ID: "google.nodejs.npm", Name: "npm", API: "0.9"},
+			wantOK: true,
+		},
+		{
+			name: "no buildpack",
+			content: `go_library(
+    name = "nodejs",
+)`,
+		},
+		{
+			name: "missing prefix",
+			content: `buildpack(
+    name = "npm",
+)`,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok, err := parseBuildFile(tc.content)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("parseBuildFile() got err=%v, want err=%t", err, tc.wantErr)
+			}
+			if ok != tc.wantOK {
+				t.Errorf("parseBuildFile() got ok=%t, want ok=%t", ok, tc.wantOK)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("parseBuildFile() returned unexpected buildpack (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+var cmpSortStrings = cmp.Transformer("sort", func(in []string) []string {
+	out := append([]string(nil), in...)
+	sort.Strings(out)
+	return out
+})
+
+// testdataDir returns the testdata directory next to this file.
+func testdataDir(t *testing.T) string {
+	t.Helper()
+	return filepath.Join(sourceDir(t), "testdata")
+}
+
+// repoRoot returns the root of the source tree. Under Bazel, the builder descriptors in the test
+// data are symlinks into the source tree, which lets the test read the BUILD.bazel files in cmd/.
+func repoRoot(t *testing.T) string {
+	t.Helper()
+	p, err := filepath.EvalSymlinks(filepath.Join(sourceDir(t), "..", "..", Builders[0].Path))
+	if err != nil {
+		t.Fatalf("resolving %s: %v", Builders[0].Path, err)
+	}
+	return strings.TrimSuffix(p, filepath.FromSlash("/"+Builders[0].Path))
+}
+
+func sourceDir(t *testing.T) string {
+	t.Helper()
+	_, file, _, ok := runtime.Caller(0)
+	if !ok {
+		t.Fatal("unable to get caller information")
+	}
+	dir := filepath.Dir(file)
+	if filepath.IsAbs(dir) {
+		return dir
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getting current working directory: %v", err)
+	}
+	return filepath.Join(wd, dir)
+}
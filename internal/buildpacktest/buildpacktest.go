@@ -31,6 +31,7 @@ import (
 
 	"github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktestenv"
 	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/fileutil"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
@@ -72,11 +73,13 @@ type config struct {
 	detectFn       gcp.DetectFn
 	testName       string
 	files          map[string]string
+	buildpackFiles map[string]string
 	envs           []string
 	stack          string
 	want           int
 	appPath        string
 	mockProcesses  []*mockprocess.Mock
+	inProcess      bool
 }
 
 // Result encapsulates the result of a buildpack phase ran as a child process.
@@ -93,6 +96,8 @@ type Result struct {
 	// ExitCode is the exit code of the child process that ran the buildpack
 	// function.
 	ExitCode int
+	// state holds the layers and processes created by a successful build.
+	state *buildState
 }
 
 // CommandExecuted returns true if the command was executed using ctx.Exec, otherwise returns false.
@@ -120,6 +125,22 @@ func WithApp(appName string) Option {
 	}
 }
 
+// WithFiles specifies files, keyed by path relative to the application root, to create before
+// the buildpack phase runs.
+func WithFiles(files map[string]string) Option {
+	return func(cfg *config) {
+		cfg.files = files
+	}
+}
+
+// WithBuildpackFiles specifies files, keyed by path relative to the buildpack root, to create
+// before the buildpack phase runs, e.g. the files that a buildpack copies into a layer.
+func WithBuildpackFiles(files map[string]string) Option {
+	return func(cfg *config) {
+		cfg.buildpackFiles = files
+	}
+}
+
 // WithEnvs specifies env vars to set for the buildpack test.
 func WithEnvs(envs ...string) Option {
 	return func(cfg *config) {
@@ -127,6 +148,16 @@ func WithEnvs(envs ...string) Option {
 	}
 }
 
+// WithInProcess runs the buildpack phase in the test process instead of a child process, so that
+// it can be debugged and does not depend on the test binary being re-executed. Calls to ctx.Exit
+// are intercepted and set the exit code of the result, but the test must not call os.Exit itself
+// or run in parallel, as the phase changes the working directory and environment of the process.
+func WithInProcess() Option {
+	return func(cfg *config) {
+		cfg.inProcess = true
+	}
+}
+
 // WithExecMocks mocks the behavior of shell commands.
 func WithExecMocks(mocks ...*mockprocess.Mock) Option {
 	return func(cfg *config) {
@@ -190,10 +221,30 @@ func RunBuild(t *testing.T, buildFn gcp.BuildFn, opts ...Option) (*Result, error
 	return runBuildpackPhaseForTest(t, cfg)
 }
 
+// RunDetect is a helper for testing a buildpack's implementation of /bin/detect. The exit code
+// of the result is 100 if the buildpack does not detect the application. Unless WithInProcess is
+// used, this MUST be called from a test function with the stub `func TestDetect(t *testing.T)`.
+func RunDetect(t *testing.T, detectFn gcp.DetectFn, opts ...Option) (*Result, error) {
+	t.Helper()
+	cfg := &config{
+		buildpackPhase: detectPhase,
+		detectFn:       detectFn,
+	}
+
+	for _, o := range opts {
+		o(cfg)
+	}
+
+	return runBuildpackPhaseForTest(t, cfg)
+}
+
 // runBuildpackPhaseForTest runs a buildpack phase as a separate child process.
 // A child process is used to avoid the test suite itself being terminated by
 // errant calls to os.Exit() in the buildpack.
 func runBuildpackPhaseForTest(t *testing.T, cfg *config) (*Result, error) {
+	if cfg.inProcess {
+		return runBuildpackPhaseInProcess(t, cfg)
+	}
 	testDir, err := os.Getwd()
 	if err != nil {
 		t.Fatalf("getting working directory: %v", err)
@@ -212,6 +263,8 @@ func runBuildpackPhaseForTest(t *testing.T, cfg *config) (*Result, error) {
 		args = append(args, os.Args[1:]...)
 		cmd := exec.Command(testBinary, args...)
 		cmd.Env = append(os.Environ(), fmt.Sprintf("%s=%s", runTestAsHelperProcessEnv, cfg.buildpackPhase))
+		stateFile := filepath.Join(t.TempDir(), "build-state.json")
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", buildStateFileEnv, stateFile))
 
 		for _, e := range cfg.envs {
 			cmd.Env = append(cmd.Env, e)
@@ -229,6 +282,11 @@ func runBuildpackPhaseForTest(t *testing.T, cfg *config) (*Result, error) {
 			Output:   string(output),
 			ExitCode: exitCode,
 		}
+		state, stateErr := readBuildState(stateFile)
+		if stateErr != nil {
+			t.Fatalf("reading build state: %v", stateErr)
+		}
+		result.state = state
 
 		return result, err
 	}
@@ -243,6 +301,9 @@ func runBuildpackPhaseForTest(t *testing.T, cfg *config) (*Result, error) {
 func runBuildpackPhaseMain(t *testing.T, cfg *config) {
 	phasePassed, err := runBuildpackPhase(t, cfg)
 	if err != nil {
+		if hint := buildererror.HintOf(err); hint != nil {
+			log.Print(hint)
+		}
 		log.Fatalf("buildpack error: %v", err)
 	}
 
@@ -257,51 +318,60 @@ func runBuildpackPhaseMain(t *testing.T, cfg *config) {
 	os.Exit(0)
 }
 
-func runBuildpackPhase(t *testing.T, cfg *config) (bool, error) {
+func runBuildpackPhase(t *testing.T, cfg *config, ctxOpts ...gcp.ContextOption) (bool, error) {
 	temps := buildpacktestenv.SetUpTempDirs(t)
-	opts := []gcp.ContextOption{gcp.WithApplicationRoot(temps.CodeDir), gcp.WithBuildpackRoot(temps.BuildpackDir)}
-
-	// Mock out calls to ctx.Exec, if specified
-	if len(cfg.mockProcesses) > 0 {
-		eCmd, err := mockprocess.NewExecCmd(cfg.mockProcesses...)
-		if err != nil {
-			t.Fatalf("error creating mock exec command: %v", err)
-		}
-		opts = append(opts, gcp.WithExecCmd(eCmd))
-	}
+	opts := append([]gcp.ContextOption{gcp.WithApplicationRoot(temps.CodeDir), gcp.WithBuildpackRoot(temps.BuildpackDir)}, ctxOpts...)
+	opts = append(opts, execMockOptions(t, cfg)...)
 
 	// Logs all ctx.Exec commands to stderr
 	os.Setenv(env.DebugMode, "true")
 	ctx := gcp.NewContext(opts...)
 
-	if cfg.appPath != "" {
-		// Copy apps from test data into temp code dir
-		if err := fileutil.MaybeCopyPathContents(temps.CodeDir, filepath.Join(flagTestData, cfg.appPath), fileutil.AllPaths); err != nil {
-			return false, fmt.Errorf("unable to copy app directory %q to %q: %v", cfg.appPath, temps.CodeDir, err)
-		}
+	if err := writeApp(temps.CodeDir, cfg); err != nil {
+		return false, err
+	}
+	if err := writeFiles(temps.BuildpackDir, cfg.buildpackFiles); err != nil {
+		return false, err
 	}
 
-	for f, c := range cfg.files {
-		fn := filepath.Join(temps.CodeDir, f)
+	return runPhase(ctx, cfg)
+}
 
-		if dir := path.Dir(fn); dir != "" {
-			if err := os.MkdirAll(dir, 0744); err != nil {
-				return false, fmt.Errorf("creating directory tree %s: %v", dir, err)
-			}
-		}
+// execMockOptions returns the context options that mock out calls to ctx.Exec, if specified.
+func execMockOptions(t *testing.T, cfg *config) []gcp.ContextOption {
+	if len(cfg.mockProcesses) == 0 {
+		return nil
+	}
+	eCmd, err := mockprocess.NewExecCmd(cfg.mockProcesses...)
+	if err != nil {
+		t.Fatalf("error creating mock exec command: %v", err)
+	}
+	return []gcp.ContextOption{gcp.WithExecCmd(eCmd)}
+}
 
-		if err := ioutil.WriteFile(fn, []byte(c), 0644); err != nil {
-			return false, fmt.Errorf("writing file %s: %v", fn, err)
+// writeApp creates the application of the test in codeDir.
+func writeApp(codeDir string, cfg *config) error {
+	if cfg.appPath != "" {
+		// Copy apps from test data into temp code dir
+		if err := fileutil.MaybeCopyPathContents(codeDir, filepath.Join(flagTestData, cfg.appPath), fileutil.AllPaths); err != nil {
+			return fmt.Errorf("unable to copy app directory %q to %q: %v", cfg.appPath, codeDir, err)
 		}
 	}
+	return writeFiles(codeDir, cfg.files)
+}
 
-	if err := os.Chdir(temps.CodeDir); err != nil {
-		return false, fmt.Errorf("changing to code dir %q: %v", temps.CodeDir, err)
+// runPhase runs the detect or build function of cfg from the application root of ctx.
+func runPhase(ctx *gcp.Context, cfg *config) (bool, error) {
+	if err := os.Chdir(ctx.ApplicationRoot()); err != nil {
+		return false, fmt.Errorf("changing to code dir %q: %v", ctx.ApplicationRoot(), err)
 	}
 
 	if cfg.buildpackPhase == buildPhase {
 		if err := cfg.buildFn(ctx); err != nil {
-			return false, fmt.Errorf("build error: %v", err)
+			return false, fmt.Errorf("build error: %w", err)
+		}
+		if err := writeBuildState(ctx); err != nil {
+			return false, err
 		}
 	} else {
 		detect, err := cfg.detectFn(ctx)
@@ -318,3 +388,21 @@ func runBuildpackPhase(t *testing.T, cfg *config) (bool, error) {
 
 	return true, nil
 }
+
+// writeFiles creates the files, keyed by path relative to root.
+func writeFiles(root string, files map[string]string) error {
+	for f, c := range files {
+		fn := filepath.Join(root, f)
+
+		if dir := path.Dir(fn); dir != "" {
+			if err := os.MkdirAll(dir, 0744); err != nil {
+				return fmt.Errorf("creating directory tree %s: %v", dir, err)
+			}
+		}
+
+		if err := ioutil.WriteFile(fn, []byte(c), 0644); err != nil {
+			return fmt.Errorf("writing file %s: %v", fn, err)
+		}
+	}
+	return nil
+}
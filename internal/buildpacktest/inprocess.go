@@ -0,0 +1,141 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildpacktest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// errDetectFailed is returned when the buildpack does not detect the application in-process, the
+// child process exits with code 100 instead.
+var errDetectFailed = errors.New("detect did not pass")
+
+// exitPanic is the value panicked by the exit function of in-process phases so that ctx.Exit
+// unwinds the buildpack function instead of exiting the test.
+type exitPanic struct {
+	code int
+}
+
+// syncBuffer is a buffer that can be written to by concurrent commands, e.g. of ctx.Parallel.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// runBuildpackPhaseInProcess runs a buildpack phase in the test process. The env vars of the
+// test and its working directory are restored once the phase completes.
+func runBuildpackPhaseInProcess(t *testing.T, cfg *config) (*Result, error) {
+	t.Helper()
+	setInProcessEnv(t, cfg)
+	return runInProcess(t, cfg.buildpackPhase, func(ctxOpts ...gcp.ContextOption) (bool, error) {
+		return runBuildpackPhase(t, cfg, ctxOpts...)
+	})
+}
+
+// setInProcessEnv sets the env vars of the test for in-process phases, they are restored when the
+// test completes.
+func setInProcessEnv(t *testing.T, cfg *config) {
+	t.Helper()
+	// Logs all ctx.Exec commands to the output.
+	t.Setenv(env.DebugMode, "true")
+	for _, e := range cfg.envs {
+		kv := strings.SplitN(e, "=", 2)
+		if len(kv) != 2 {
+			t.Fatalf("invalid env var %q, want KEY=VALUE", e)
+		}
+		t.Setenv(kv[0], kv[1])
+	}
+}
+
+// runInProcess calls run with the context options that capture the output and the exit of the
+// buildpack, and returns the result of the phase.
+func runInProcess(t *testing.T, phase buildpackPhase, run func(ctxOpts ...gcp.ContextOption) (bool, error)) (*Result, error) {
+	t.Helper()
+	stateFile := filepath.Join(t.TempDir(), "build-state.json")
+	t.Setenv(buildStateFileEnv, stateFile)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getting working directory: %v", err)
+	}
+	defer func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Fatalf("restoring working directory %q: %v", wd, err)
+		}
+	}()
+
+	var out syncBuffer
+	result := &Result{}
+	err = func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				ep, ok := r.(exitPanic)
+				if !ok {
+					panic(r)
+				}
+				result.ExitCode = ep.code
+				if ep.code != 0 {
+					err = fmt.Errorf("buildpack exited with code %d", ep.code)
+				}
+			}
+		}()
+		exit := func(code int) { panic(exitPanic{code: code}) }
+		passed, err := run(gcp.WithOutput(&out), gcp.WithExitFunc(exit))
+		if err != nil {
+			if hint := buildererror.HintOf(err); hint != nil {
+				fmt.Fprintln(&out, hint)
+			}
+			fmt.Fprintf(&out, "buildpack error: %v\n", err)
+			result.ExitCode = 1
+			return err
+		}
+		if phase == detectPhase && !passed {
+			result.ExitCode = 100
+			return errDetectFailed
+		}
+		return nil
+	}()
+	result.Output = out.String()
+
+	state, stateErr := readBuildState(stateFile)
+	if stateErr != nil {
+		t.Fatalf("reading build state: %v", stateErr)
+	}
+	result.state = state
+	return result, err
+}
@@ -0,0 +1,113 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildpacktest
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestRunBuildInProcess(t *testing.T) {
+	testCases := []struct {
+		name         string
+		buildFn      gcp.BuildFn
+		wantExitCode int
+		wantOutput   string
+	}{
+		{
+			name: "success",
+			buildFn: func(ctx *gcp.Context) error {
+				if os.Getenv("GREETING") != "hello" {
+					return gcp.InternalErrorf("GREETING = %q, want %q", os.Getenv("GREETING"), "hello")
+				}
+				if _, err := ctx.Layer("tools", gcp.LaunchLayer); err != nil {
+					return err
+				}
+				ctx.Logf("built")
+				return nil
+			},
+			wantOutput: "built",
+		},
+		{
+			name: "error",
+			buildFn: func(ctx *gcp.Context) error {
+				return gcp.UserErrorf("missing main.py")
+			},
+			wantExitCode: 1,
+			wantOutput:   "missing main.py",
+		},
+		{
+			name: "exit",
+			buildFn: func(ctx *gcp.Context) error {
+				ctx.Exit(2, buildererror.Errorf(buildererror.StatusInternal, "giving up"))
+				return nil
+			},
+			wantExitCode: 2,
+			wantOutput:   "giving up",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			wd, err := os.Getwd()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := RunBuild(t, tc.buildFn, WithInProcess(), WithEnvs("GREETING=hello"))
+			if (err != nil) != (tc.wantExitCode != 0) {
+				t.Errorf("RunBuild() got error: %v, want error? %t", err, tc.wantExitCode != 0)
+			}
+			if result.ExitCode != tc.wantExitCode {
+				t.Errorf("RunBuild() exit code = %d, want %d", result.ExitCode, tc.wantExitCode)
+			}
+			if !strings.Contains(result.Output, tc.wantOutput) {
+				t.Errorf("RunBuild() output = %q, want it to contain %q", result.Output, tc.wantOutput)
+			}
+			if tc.wantExitCode == 0 && !result.LayerExists("tools") {
+				t.Errorf("RunBuild() did not record layer %q", "tools")
+			}
+			if got, _ := os.Getwd(); got != wd {
+				t.Errorf("working directory = %q after RunBuild(), want %q", got, wd)
+			}
+		})
+	}
+	if _, ok := os.LookupEnv("GREETING"); ok {
+		t.Errorf("GREETING is set after RunBuild(), want it restored")
+	}
+}
+
+func TestRunDetectInProcess(t *testing.T) {
+	detectFn := func(ctx *gcp.Context) (gcp.DetectResult, error) {
+		exists, err := ctx.FileExists("main.py")
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return gcp.OptOut("main.py not found"), nil
+		}
+		return gcp.OptIn("found main.py"), nil
+	}
+
+	if result, err := RunDetect(t, detectFn, WithInProcess(), WithFiles(map[string]string{"main.py": ""})); err != nil || result.ExitCode != 0 {
+		t.Errorf("RunDetect() = %d, %v, want 0, nil", result.ExitCode, err)
+	}
+	if result, err := RunDetect(t, detectFn, WithInProcess()); err == nil || result.ExitCode != 100 {
+		t.Errorf("RunDetect() = %d, %v, want 100 and an error", result.ExitCode, err)
+	}
+}
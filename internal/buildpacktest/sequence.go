@@ -0,0 +1,167 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildpacktest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/buildpacktestenv"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+// BuildpackPhase is a buildpack of the sequence run by RunBuildSequence.
+type BuildpackPhase struct {
+	// ID is the id of the buildpack, e.g. "google.nodejs.runtime". The layers of the buildpack are
+	// created in a directory named after it, as they are by the lifecycle.
+	ID string
+	// DetectFn is the detect function of the buildpack. The buildpack is always built if it is nil.
+	DetectFn gcp.DetectFn
+	// BuildFn is the build function of the buildpack.
+	BuildFn gcp.BuildFn
+	// Optional buildpacks are skipped when they do not detect the application instead of failing
+	// the sequence.
+	Optional bool
+	// BuildpackFiles are the files, keyed by path relative to the buildpack root, to create before
+	// the buildpack runs.
+	BuildpackFiles map[string]string
+}
+
+// RunBuildSequence is a helper for testing the integration of several buildpacks, e.g. a runtime,
+// a package manager and a functions framework buildpack. It runs the detect and build functions
+// of the buildpacks in order against the same application directory, and the build environment
+// of the layers created by a buildpack is set for the buildpacks that follow, as it is by the
+// lifecycle. The WithApp, WithFiles, WithEnvs and WithExecMocks options apply to the whole
+// sequence.
+//
+// The buildpacks run in-process with the same restrictions as WithInProcess. The results are in
+// the order of the phases, an optional buildpack that did not detect the application has a result
+// with exit code 100. The sequence stops at the first buildpack that fails, so the results of the
+// buildpacks that follow are missing.
+func RunBuildSequence(t *testing.T, phases []BuildpackPhase, opts ...Option) ([]*Result, error) {
+	t.Helper()
+	cfg := &config{}
+	for _, o := range opts {
+		o(cfg)
+	}
+	setInProcessEnv(t, cfg)
+
+	temps := buildpacktestenv.SetUpTempDirs(t)
+	if err := writeApp(temps.CodeDir, cfg); err != nil {
+		return nil, err
+	}
+	mockOpts := execMockOptions(t, cfg)
+
+	var results []*Result
+	for _, p := range phases {
+		buildpackRoot := filepath.Join(temps.BuildpackDir, p.ID)
+		if err := os.MkdirAll(buildpackRoot, 0755); err != nil {
+			return results, fmt.Errorf("creating buildpack dir %s: %v", buildpackRoot, err)
+		}
+		if err := writeFiles(buildpackRoot, p.BuildpackFiles); err != nil {
+			return results, err
+		}
+		ctxOpts := append([]gcp.ContextOption{
+			gcp.WithApplicationRoot(temps.CodeDir),
+			gcp.WithBuildpackRoot(buildpackRoot),
+			gcp.WithBuildpackInfo(libcnb.BuildpackInfo{ID: p.ID, Version: "my-version"}),
+			gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: filepath.Join(temps.LayersDir, p.ID)}}),
+		}, mockOpts...)
+
+		if p.DetectFn != nil {
+			detectCfg := &config{buildpackPhase: detectPhase, detectFn: p.DetectFn}
+			result, err := runInProcess(t, detectPhase, func(outOpts ...gcp.ContextOption) (bool, error) {
+				return runPhase(gcp.NewContext(append(ctxOpts, outOpts...)...), detectCfg)
+			})
+			if err == errDetectFailed && p.Optional {
+				results = append(results, result)
+				continue
+			}
+			if err != nil {
+				return append(results, result), fmt.Errorf("detecting buildpack %s: %w", p.ID, err)
+			}
+		}
+
+		buildCfg := &config{buildpackPhase: buildPhase, buildFn: p.BuildFn}
+		result, err := runInProcess(t, buildPhase, func(outOpts ...gcp.ContextOption) (bool, error) {
+			ctx := gcp.NewContext(append(ctxOpts, outOpts...)...)
+			passed, err := runPhase(ctx, buildCfg)
+			if err == nil {
+				setBuildEnv(t, ctx.Layers())
+			}
+			return passed, err
+		})
+		results = append(results, result)
+		if err != nil {
+			return results, fmt.Errorf("building buildpack %s: %w", p.ID, err)
+		}
+	}
+	return results, nil
+}
+
+// setBuildEnv sets the environment of the build layers for the rest of the test, the bin and lib
+// directories of the layers are added to PATH and LD_LIBRARY_PATH.
+func setBuildEnv(t *testing.T, layers []*libcnb.Layer) {
+	t.Helper()
+	for _, l := range layers {
+		if !l.Build {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(l.Path, "bin")); err == nil {
+			modifyEnv(t, "PATH", "prepend", filepath.Join(l.Path, "bin"), string(os.PathListSeparator))
+		}
+		if _, err := os.Stat(filepath.Join(l.Path, "lib")); err == nil {
+			modifyEnv(t, "LD_LIBRARY_PATH", "prepend", filepath.Join(l.Path, "lib"), string(os.PathListSeparator))
+		}
+		for _, e := range []libcnb.Environment{l.SharedEnvironment, l.BuildEnvironment} {
+			for k, v := range e {
+				i := strings.LastIndex(k, ".")
+				if i < 0 {
+					modifyEnv(t, k, "override", v, "")
+					continue
+				}
+				if name, action := k[:i], k[i+1:]; action != "delim" {
+					modifyEnv(t, name, action, v, e[name+".delim"])
+				}
+			}
+		}
+	}
+}
+
+// modifyEnv modifies the env var as described by the action of a layer env file, see
+// https://github.com/buildpacks/spec/blob/main/buildpack.md#environment-variable-modification-rules.
+func modifyEnv(t *testing.T, name, action, value, delim string) {
+	t.Helper()
+	cur, ok := os.LookupEnv(name)
+	switch action {
+	case "default":
+		if ok {
+			return
+		}
+	case "prepend":
+		if cur != "" {
+			value = value + delim + cur
+		}
+	case "append":
+		if cur != "" {
+			value = cur + delim + value
+		}
+	}
+	t.Setenv(name, value)
+}
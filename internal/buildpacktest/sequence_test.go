@@ -0,0 +1,151 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildpacktest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestRunBuildSequence(t *testing.T) {
+	runtime := BuildpackPhase{
+		ID: "google.nodejs.runtime",
+		BuildFn: func(ctx *gcp.Context) error {
+			l, err := ctx.Layer("node", gcp.BuildLayer, gcp.LaunchLayer)
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Join(l.Path, "bin"), 0755); err != nil {
+				return err
+			}
+			l.SharedEnvironment.Default("NODE_HOME", l.Path)
+			l.BuildEnvironment.Prepend("NODE_OPTIONS", " ", "--max-old-space-size=512")
+			return ioutil.WriteFile("runtime.txt", []byte("node"), 0644)
+		},
+	}
+	yarn := BuildpackPhase{
+		ID: "google.nodejs.yarn",
+		DetectFn: func(ctx *gcp.Context) (gcp.DetectResult, error) {
+			return gcp.OptOut("yarn.lock not found"), nil
+		},
+		BuildFn: func(ctx *gcp.Context) error {
+			return gcp.InternalErrorf("yarn must not be built")
+		},
+		Optional: true,
+	}
+	npm := BuildpackPhase{
+		ID: "google.nodejs.npm",
+		DetectFn: func(ctx *gcp.Context) (gcp.DetectResult, error) {
+			return gcp.OptIn("found package.json"), nil
+		},
+		BuildFn: func(ctx *gcp.Context) error {
+			if _, err := ctx.Layer("npm_modules", gcp.LaunchLayer); err != nil {
+				return err
+			}
+			if got := os.Getenv("NODE_HOME"); !strings.HasSuffix(got, filepath.Join("google.nodejs.runtime", "node")) {
+				return gcp.InternalErrorf("NODE_HOME = %q, want the node layer", got)
+			}
+			if got, want := os.Getenv("NODE_OPTIONS"), "--max-old-space-size=512 --trace-warnings"; got != want {
+				return gcp.InternalErrorf("NODE_OPTIONS = %q, want %q", got, want)
+			}
+			if got := os.Getenv("PATH"); !strings.Contains(got, filepath.Join("google.nodejs.runtime", "node", "bin")) {
+				return gcp.InternalErrorf("PATH = %q, want it to contain the node layer", got)
+			}
+			if _, err := os.Stat(filepath.Join(ctx.ApplicationRoot(), "runtime.txt")); err != nil {
+				return gcp.InternalErrorf("file of the runtime buildpack not found: %v", err)
+			}
+			return nil
+		},
+	}
+
+	t.Run("nodejs", func(t *testing.T) {
+		results, err := RunBuildSequence(t, []BuildpackPhase{runtime, yarn, npm}, WithEnvs("NODE_OPTIONS=--trace-warnings"))
+		if err != nil {
+			for _, r := range results {
+				t.Log(r.Output)
+			}
+			t.Fatalf("RunBuildSequence() got error: %v", err)
+		}
+		if len(results) != 3 {
+			t.Fatalf("RunBuildSequence() returned %d results, want 3", len(results))
+		}
+		if !results[0].LayerExists("node") {
+			t.Errorf("runtime result has no layer %q", "node")
+		}
+		if results[1].ExitCode != 100 {
+			t.Errorf("yarn exit code = %d, want 100", results[1].ExitCode)
+		}
+		if !results[2].LayerExists("npm_modules") {
+			t.Errorf("npm result has no layer %q", "npm_modules")
+		}
+	})
+	if _, ok := os.LookupEnv("NODE_HOME"); ok {
+		t.Errorf("NODE_HOME is set after the test, want it restored")
+	}
+}
+
+func TestRunBuildSequenceFailure(t *testing.T) {
+	built := false
+	testCases := []struct {
+		name  string
+		phase BuildpackPhase
+	}{
+		{
+			name: "detect fails",
+			phase: BuildpackPhase{
+				ID: "google.python.pip",
+				DetectFn: func(ctx *gcp.Context) (gcp.DetectResult, error) {
+					return gcp.OptOut("requirements.txt not found"), nil
+				},
+			},
+		},
+		{
+			name: "build fails",
+			phase: BuildpackPhase{
+				ID: "google.python.pip",
+				BuildFn: func(ctx *gcp.Context) error {
+					return gcp.UserErrorf("invalid requirements.txt")
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			next := BuildpackPhase{
+				ID: "google.python.functions-framework",
+				BuildFn: func(ctx *gcp.Context) error {
+					built = true
+					return nil
+				},
+			}
+
+			results, err := RunBuildSequence(t, []BuildpackPhase{tc.phase, next})
+			if err == nil {
+				t.Fatal("RunBuildSequence() got no error, want error")
+			}
+			if len(results) != 1 || results[0].ExitCode == 0 {
+				t.Errorf("RunBuildSequence() results = %+v, want a single failed result", results)
+			}
+			if built {
+				t.Error("RunBuildSequence() built the buildpack after the failure")
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildpacktest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+// buildStateFileEnv is the env var with the path of the file where the child process writes the
+// state of the build, so that the test can assert on the layers and processes it created.
+const buildStateFileEnv = "BUILDPACKTEST_BUILD_STATE_FILE"
+
+// buildState is the state of a build after the build function returns.
+type buildState struct {
+	Layers    []layerState
+	Processes []libcnb.Process
+}
+
+// layerState describes a layer created by the build function.
+type layerState struct {
+	Name   string
+	Build  bool
+	Launch bool
+	Cache  bool
+	// LaunchEnv holds the shared and launch environment of the layer, keyed by the name of the
+	// environment file, e.g. "PATH.prepend".
+	LaunchEnv map[string]string
+	// Files are the paths of the files and directories in the layer, relative to the layer.
+	Files []string
+}
+
+// writeBuildState records the layers and processes of the build in the file named by
+// buildStateFileEnv, if it is set.
+func writeBuildState(ctx *gcp.Context) error {
+	path := os.Getenv(buildStateFileEnv)
+	if path == "" {
+		return nil
+	}
+	state := buildState{Processes: ctx.Processes()}
+	for _, l := range ctx.Layers() {
+		ls := layerState{Name: l.Name, Build: l.Build, Launch: l.Launch, Cache: l.Cache, LaunchEnv: map[string]string{}}
+		for k, v := range l.SharedEnvironment {
+			ls.LaunchEnv[k] = v
+		}
+		for k, v := range l.LaunchEnvironment {
+			ls.LaunchEnv[k] = v
+		}
+		err := filepath.Walk(l.Path, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(l.Path, p)
+			if err != nil {
+				return err
+			}
+			if rel != "." {
+				ls.Files = append(ls.Files, filepath.ToSlash(rel))
+			}
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("listing the files of layer %s: %v", l.Name, err)
+		}
+		sort.Strings(ls.Files)
+		state.Layers = append(state.Layers, ls)
+	}
+	b, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("marshalling build state: %v", err)
+	}
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		return fmt.Errorf("writing build state: %v", err)
+	}
+	return nil
+}
+
+// readBuildState reads the state written by writeBuildState, it returns nil if the build did not
+// complete.
+func readBuildState(path string) (*buildState, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state buildState
+	if err := json.Unmarshal(b, &state); err != nil {
+		return nil, fmt.Errorf("unmarshalling build state: %v", err)
+	}
+	return &state, nil
+}
+
+func (r *Result) layer(name string) (layerState, bool) {
+	if r.state == nil {
+		return layerState{}, false
+	}
+	for _, l := range r.state.Layers {
+		if l.Name == name {
+			return l, true
+		}
+	}
+	return layerState{}, false
+}
+
+// LayerExists returns true if the build created the layer.
+func (r *Result) LayerExists(name string) bool {
+	_, ok := r.layer(name)
+	return ok
+}
+
+// FileExistsInLayer returns true if the layer contains the file or directory at path, relative to
+// the layer.
+func (r *Result) FileExistsInLayer(layer, path string) bool {
+	l, ok := r.layer(layer)
+	if !ok {
+		return false
+	}
+	path = strings.Trim(filepath.ToSlash(path), "/")
+	i := sort.SearchStrings(l.Files, path)
+	return i < len(l.Files) && l.Files[i] == path
+}
+
+// LaunchEnvContains returns true if a launch layer sets the environment variable to a value that
+// contains the given value, whatever the modifier of the variable, e.g. PATH.prepend or
+// NODE_ENV.default.
+func (r *Result) LaunchEnvContains(name, value string) bool {
+	if r.state == nil {
+		return false
+	}
+	for _, l := range r.state.Layers {
+		if !l.Launch {
+			continue
+		}
+		for k, v := range l.LaunchEnv {
+			if (k == name || strings.HasPrefix(k, name+".")) && !strings.HasSuffix(k, ".delim") && strings.Contains(v, value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ProcessRegistered returns true if the build added a process of the given type whose command line
+// matches the command regular expression.
+func (r *Result) ProcessRegistered(processType, command string) bool {
+	if r.state == nil {
+		return false
+	}
+	re := regexp.MustCompile(command)
+	for _, p := range r.state.Processes {
+		if p.Type == processType && re.MatchString(strings.Join(append([]string{p.Command}, p.Arguments...), " ")) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cacheformat contains a test helper that catches changes to the code populating a cached
+// layer that were made without bumping the layer's cache format version.
+//
+// The helper records a hash of the relevant source files together with the cache format version
+// in a golden file. If the sources change while the version stays the same, the test fails until
+// the golden is regenerated with -update_cache_format. This is best effort: it cannot tell whether
+// a change affects the cached layer, it only forces the author to make that decision.
+package cacheformat
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+)
+
+var flagUpdate bool
+
+func init() {
+	flag.BoolVar(&flagUpdate, "update_cache_format", false, "Regenerate cache format golden files.")
+}
+
+// Check fails the test if the given source files changed since the golden file was recorded
+// without the cache format version changing as well. Paths are relative to the directory of the
+// calling test file.
+func Check(t *testing.T, golden string, version cache.FormatVersion, files ...string) {
+	t.Helper()
+	_, caller, _, ok := runtime.Caller(1)
+	if !ok {
+		t.Fatalf("unable to get caller information")
+	}
+	dir := filepath.Dir(caller)
+	if !filepath.IsAbs(dir) {
+		wd, err := os.Getwd()
+		if err != nil {
+			t.Fatalf("getting current working directory: %v", err)
+		}
+		dir = filepath.Join(wd, dir)
+	}
+	if err := check(dir, golden, version, files, flagUpdate); err != nil {
+		t.Error(err)
+	}
+}
+
+// check implements Check for source files and golden relative to dir. If update is set, the
+// golden file is rewritten instead of compared.
+func check(dir, golden string, version cache.FormatVersion, files []string, update bool) error {
+	sum, err := hashFiles(dir, files)
+	if err != nil {
+		return err
+	}
+	goldenPath := filepath.Join(dir, golden)
+	if update {
+		content := fmt.Sprintf("# Generated by -update_cache_format. Do not edit.\nversion: %s\nsources: %s\n", version, sum)
+		if err := ioutil.WriteFile(goldenPath, []byte(content), 0644); err != nil {
+			return fmt.Errorf("writing golden %s: %v", goldenPath, err)
+		}
+		return nil
+	}
+
+	goldenVersion, goldenSum, err := readGolden(goldenPath)
+	if err != nil {
+		return err
+	}
+	if string(version) != goldenVersion {
+		return fmt.Errorf("cache format version is %q but %s records %q; re-run the test with -update_cache_format to record it", version, golden, goldenVersion)
+	}
+	if sum != goldenSum {
+		return fmt.Errorf("%v changed since cache format version %q was recorded in %s. If the change affects how the cached layer is populated, bump the cache format version. In either case, re-run the test with -update_cache_format", files, version, golden)
+	}
+	return nil
+}
+
+func hashFiles(dir string, files []string) (string, error) {
+	h := sha256.New()
+	for _, f := range files {
+		b, err := ioutil.ReadFile(filepath.Join(dir, f))
+		if err != nil {
+			return "", fmt.Errorf("reading source file: %v", err)
+		}
+		h.Write([]byte(f))
+		h.Write(b)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func readGolden(path string) (string, string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", "", fmt.Errorf("reading golden: %v; run the test with -update_cache_format to create it", err)
+	}
+	var version, sum string
+	for _, line := range strings.Split(string(b), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if v := strings.TrimPrefix(line, "version: "); v != line {
+			version = v
+		}
+		if s := strings.TrimPrefix(line, "sources: "); s != line {
+			sum = s
+		}
+	}
+	if version == "" || sum == "" {
+		return "", "", fmt.Errorf("golden %s is malformed; run the test with -update_cache_format to regenerate it", path)
+	}
+	return version, sum, nil
+}
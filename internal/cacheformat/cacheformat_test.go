@@ -0,0 +1,82 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cacheformat
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		name       string
+		newSource  string
+		newVersion string
+		wantErr    bool
+	}{
+		{
+			name:       "unchanged",
+			newSource:  "populate layer",
+			newVersion: "v1",
+		},
+		{
+			name:       "source changed without version bump",
+			newSource:  "populate layer differently",
+			newVersion: "v1",
+			wantErr:    true,
+		},
+		{
+			name:       "version bumped without updating golden",
+			newSource:  "populate layer differently",
+			newVersion: "v2",
+			wantErr:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeSource(t, dir, "populate layer")
+			if err := check(dir, "golden", "v1", []string{"main.go"}, true); err != nil {
+				t.Fatalf("check(update=true) got err=%v, want err=nil", err)
+			}
+
+			writeSource(t, dir, tc.newSource)
+			err := check(dir, "golden", "v1", []string{"main.go"}, false)
+			if tc.newVersion != "v1" {
+				err = check(dir, "golden", "v2", []string{"main.go"}, false)
+			}
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("check() got err=%v, want err=%t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckMissingGolden(t *testing.T) {
+	dir := t.TempDir()
+	writeSource(t, dir, "populate layer")
+
+	if err := check(dir, "golden", "v1", []string{"main.go"}, false); err == nil {
+		t.Errorf("check() with missing golden got err=nil, want err")
+	}
+}
+
+func writeSource(t *testing.T, dir, content string) {
+	t.Helper()
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte(content), 0644); err != nil {
+		t.Fatalf("writing source: %v", err)
+	}
+}
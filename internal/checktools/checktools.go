@@ -33,14 +33,22 @@ var (
 	// LINT.ThenChange(//depot/google3/apphosting/g3doc/runtimes/tutorials/buildpack-tests-debug.md)
 )
 
-// Installed checks that all required tools are on PATH.
-func Installed() error {
+// containerCLIInstallURLs are the installation instructions of the supported container CLIs.
+var containerCLIInstallURLs = map[string]string{
+	"docker":  "https://docs.docker.com/install/",
+	"podman":  "https://podman.io/docs/installation",
+	"nerdctl": "https://github.com/containerd/nerdctl#install",
+}
+
+// Installed checks that all required tools are on PATH, containerCLI is the container runtime
+// used to manage images and containers, e.g. "docker" or "podman".
+func Installed(containerCLI string) error {
 	tools := []struct {
 		name string
 		url  string
 	}{
 		{"pack", "https://buildpacks.io/docs/install-pack/"},
-		{"docker", "https://docs.docker.com/install/"},
+		{containerCLI, containerCLIInstallURLs[containerCLI]},
 		{"container-structure-test", "https://github.com/GoogleContainerTools/container-structure-test#installation"},
 	}
 
@@ -0,0 +1,77 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package agentutil contains helpers shared by the buildpacks that add agents to the application, e.g.
+// utils/otel, utils/apm-agent and utils/observability-agents.
+package agentutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// NodeJS is the language of Node.js applications.
+	NodeJS = "nodejs"
+	// Python is the language of Python applications.
+	Python = "python"
+	// Java is the language of Java applications.
+	Java = "java"
+)
+
+// markers are the files that identify the language of an application, in order of precedence.
+var markers = []struct {
+	language string
+	files    []string
+}{
+	{language: NodeJS, files: []string{"package.json"}},
+	{language: Python, files: []string{"requirements.txt", "setup.py", "Pipfile", "Pipfile.lock", "pyproject.toml", "poetry.lock"}},
+	{language: Java, files: []string{"pom.xml", "build.gradle", "build.gradle.kts"}},
+}
+
+// AppLanguage returns the language of the application, from GOOGLE_RUNTIME if it is set or from
+// the files of the application otherwise. GOOGLE_RUNTIME is returned as is if it is not one of the
+// languages above, and an empty string if the language cannot be told from the files.
+func AppLanguage(ctx *gcp.Context) (string, error) {
+	if runtime := os.Getenv(env.Runtime); runtime != "" {
+		for _, m := range markers {
+			if strings.HasPrefix(runtime, m.language) {
+				return m.language, nil
+			}
+		}
+		return runtime, nil
+	}
+	for _, m := range markers {
+		for _, f := range m.files {
+			if exists, err := ctx.FileExists(ctx.ApplicationRoot(), f); err != nil {
+				return "", err
+			} else if exists {
+				return m.language, nil
+			}
+		}
+	}
+	jars, err := ctx.Glob(filepath.Join(ctx.ApplicationRoot(), "*.jar"))
+	if err != nil {
+		return "", fmt.Errorf("finding jar files: %w", err)
+	}
+	if len(jars) > 0 {
+		return Java, nil
+	}
+	return "", nil
+}
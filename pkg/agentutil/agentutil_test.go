@@ -0,0 +1,103 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agentutil
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestAppLanguage(t *testing.T) {
+	testCases := []struct {
+		name    string
+		files   []string
+		runtime string
+		want    string
+	}{
+		{
+			name:  "package.json",
+			files: []string{"package.json"},
+			want:  NodeJS,
+		},
+		{
+			name:  "requirements.txt",
+			files: []string{"requirements.txt"},
+			want:  Python,
+		},
+		{
+			name:  "Pipfile",
+			files: []string{"Pipfile"},
+			want:  Python,
+		},
+		{
+			name:  "poetry.lock",
+			files: []string{"pyproject.toml", "poetry.lock"},
+			want:  Python,
+		},
+		{
+			name:  "build.gradle.kts",
+			files: []string{"build.gradle.kts"},
+			want:  Java,
+		},
+		{
+			name:  "jar",
+			files: []string{"app.jar"},
+			want:  Java,
+		},
+		{
+			name:  "Node.js takes precedence",
+			files: []string{"package.json", "requirements.txt"},
+			want:  NodeJS,
+		},
+		{
+			name:    "GOOGLE_RUNTIME",
+			files:   []string{"package.json"},
+			runtime: "python312",
+			want:    Python,
+		},
+		{
+			name:    "other GOOGLE_RUNTIME",
+			runtime: "go122",
+			want:    "go122",
+		},
+		{
+			name:  "unknown",
+			files: []string{"main.go"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(env.Runtime, tc.runtime)
+			dir := t.TempDir()
+			for _, f := range tc.files {
+				if err := ioutil.WriteFile(filepath.Join(dir, f), nil, 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+
+			got, err := AppLanguage(gcp.NewContext(gcp.WithApplicationRoot(dir)))
+			if err != nil {
+				t.Fatalf("AppLanguage() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("AppLanguage() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
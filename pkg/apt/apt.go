@@ -0,0 +1,179 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package apt installs Debian packages into layers. Buildpacks do not run as root, so packages are
+// downloaded with apt-get and extracted into the layer rather than installed on the system.
+package apt
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	packagesKey = "packages"
+
+	cacheFormatVersion = "v1"
+)
+
+// ParsePackages returns the sorted unique package names of a list separated by spaces or commas,
+// e.g. "libsqlite3-dev, libvips-dev".
+func ParsePackages(list string) []string {
+	seen := map[string]bool{}
+	var packages []string
+	for _, p := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' || r == '\n' }) {
+		if !seen[p] {
+			seen[p] = true
+			packages = append(packages, p)
+		}
+	}
+	sort.Strings(packages)
+	return packages
+}
+
+// InstallCached installs the packages into the layer unless they were installed by a previous
+// build on the same stack. The layer must be a cache layer for the packages to be reused.
+func InstallCached(ctx *gcp.Context, l *libcnb.Layer, packages []string) error {
+	if len(packages) == 0 {
+		return nil
+	}
+	hit, sha, err := cache.CheckCache(ctx, l, cache.WithFormatVersion(cacheFormatVersion), packagesKey, cache.WithStrings(append([]string{ctx.StackID(), ctx.Arch()}, packages...)...))
+	if err != nil {
+		return fmt.Errorf("checking %v layer cache: %w", l.Name, err)
+	}
+	if hit {
+		ctx.CacheHit(l.Name)
+		ConfigureLayerEnv(ctx, l)
+		return nil
+	}
+	ctx.CacheMiss(l.Name)
+	if err := ctx.ClearLayer(l); err != nil {
+		return fmt.Errorf("clearing layer %q: %w", l.Name, err)
+	}
+	ctx.Logf("Installing %v", packages)
+	if err := Install(ctx, l, packages); err != nil {
+		return err
+	}
+	ctx.SetMetadata(l, packagesKey, sha)
+	return nil
+}
+
+// Install downloads the packages and the dependencies missing from the build image and extracts
+// them into the layer. The layer environment is configured so that compilers, linkers, pkg-config
+// and the dynamic loader find the extracted files.
+func Install(ctx *gcp.Context, l *libcnb.Layer, packages []string) error {
+	aptDir, err := ctx.TempDir("apt")
+	if err != nil {
+		return err
+	}
+	cacheDir := filepath.Join(aptDir, "cache")
+	stateDir := filepath.Join(aptDir, "state")
+	for _, dir := range []string{filepath.Join(cacheDir, "archives", "partial"), filepath.Join(stateDir, "lists", "partial")} {
+		if err := ctx.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	opts := []string{
+		"-o", "debug::nolocking=true",
+		"-o", "dir::cache=" + cacheDir,
+		"-o", "dir::state=" + stateDir,
+	}
+	if _, err := ctx.Exec(append(append([]string{"apt-get"}, opts...), "update"), gcp.WithUserAttribution); err != nil {
+		return fmt.Errorf("updating package lists: %w", err)
+	}
+	download := append([]string{"apt-get"}, opts...)
+	download = append(download, "--yes", "--download-only", "--reinstall", "--no-install-recommends", "install")
+	download = append(download, packages...)
+	if _, err := ctx.Exec(download, gcp.WithUserAttribution); err != nil {
+		return gcp.UserErrorf("downloading packages %v: %v", packages, err)
+	}
+
+	debs, err := filepath.Glob(filepath.Join(cacheDir, "archives", "*.deb"))
+	if err != nil {
+		return err
+	}
+	for _, deb := range debs {
+		ctx.Debugf("Extracting %s", filepath.Base(deb))
+		if _, err := ctx.Exec([]string{"dpkg", "--extract", deb, l.Path}, gcp.WithUserAttribution); err != nil {
+			return fmt.Errorf("extracting %s: %w", filepath.Base(deb), err)
+		}
+	}
+	ConfigureLayerEnv(ctx, l)
+	return nil
+}
+
+// searchPaths returns the directories of the packages extracted into the layer by environment
+// variable, in the order they are searched. multiarchTriplet is the Debian multiarch directory
+// of the architecture specific libraries, e.g. "x86_64-linux-gnu".
+func searchPaths(l *libcnb.Layer, multiarchTriplet string) map[string][]string {
+	usr := filepath.Join(l.Path, "usr")
+	libs := []string{
+		filepath.Join(usr, "lib", multiarchTriplet),
+		filepath.Join(usr, "lib"),
+		filepath.Join(l.Path, "lib", multiarchTriplet),
+	}
+	return map[string][]string{
+		"PATH":            {filepath.Join(usr, "bin")},
+		"LD_LIBRARY_PATH": libs,
+		"LIBRARY_PATH":    libs,
+		"CPATH":           {filepath.Join(usr, "include", multiarchTriplet), filepath.Join(usr, "include")},
+		"PKG_CONFIG_PATH": {
+			filepath.Join(usr, "lib", multiarchTriplet, "pkgconfig"),
+			filepath.Join(usr, "lib", "pkgconfig"),
+			filepath.Join(usr, "share", "pkgconfig"),
+		},
+	}
+}
+
+// ConfigureLayerEnv adds the directories of the packages extracted into the layer to the search
+// paths of the build and launch environments.
+func ConfigureLayerEnv(ctx *gcp.Context, l *libcnb.Layer) {
+	sep := string(os.PathListSeparator)
+	for name, paths := range searchPaths(l, ctx.MultiarchTriplet()) {
+		switch name {
+		case "PATH", "LD_LIBRARY_PATH":
+			l.SharedEnvironment.Prepend(name, sep, strings.Join(paths, sep))
+		default:
+			l.BuildEnvironment.Prepend(name, sep, strings.Join(paths, sep))
+		}
+	}
+}
+
+// ExecEnv returns the environment that commands run by the buildpack that installed the packages
+// need to find them, the layer environment only applies to the buildpacks that run after it.
+func ExecEnv(ctx *gcp.Context, l *libcnb.Layer) []string {
+	sep := string(os.PathListSeparator)
+	paths := searchPaths(l, ctx.MultiarchTriplet())
+	var names []string
+	for name := range paths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var e []string
+	for _, name := range names {
+		v := strings.Join(paths[name], sep)
+		if cur := os.Getenv(name); cur != "" {
+			v += sep + cur
+		}
+		e = append(e, name+"="+v)
+	}
+	return e
+}
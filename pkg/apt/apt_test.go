@@ -0,0 +1,107 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apt
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/cacheformat"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParsePackages(t *testing.T) {
+	testCases := []struct {
+		name string
+		list string
+		want []string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "spaces",
+			list: "libvips-dev libsqlite3-dev",
+			want: []string{"libsqlite3-dev", "libvips-dev"},
+		},
+		{
+			name: "commas and duplicates",
+			list: " libvips-dev,libsqlite3-dev, libvips-dev ",
+			want: []string{"libsqlite3-dev", "libvips-dev"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, ParsePackages(tc.list)); diff != "" {
+				t.Errorf("ParsePackages(%q) mismatch (-want +got):\n%s", tc.list, diff)
+			}
+		})
+	}
+}
+
+func TestConfigureLayerEnv(t *testing.T) {
+	l := &libcnb.Layer{
+		Path:              "/layers/cgo",
+		BuildEnvironment:  libcnb.Environment{},
+		SharedEnvironment: libcnb.Environment{},
+	}
+
+	t.Setenv("CNB_TARGET_ARCH", "amd64")
+
+	ConfigureLayerEnv(gcp.NewContext(), l)
+
+	want := map[string]string{
+		"LD_LIBRARY_PATH.prepend": "/layers/cgo/usr/lib/x86_64-linux-gnu:/layers/cgo/usr/lib:/layers/cgo/lib/x86_64-linux-gnu",
+		"LD_LIBRARY_PATH.delim":   ":",
+		"PATH.prepend":            "/layers/cgo/usr/bin",
+		"PATH.delim":              ":",
+	}
+	if diff := cmp.Diff(libcnb.Environment(want), l.SharedEnvironment); diff != "" {
+		t.Errorf("ConfigureLayerEnv() shared environment mismatch (-want +got):\n%s", diff)
+	}
+	if got, want := l.BuildEnvironment["CPATH.prepend"], "/layers/cgo/usr/include/x86_64-linux-gnu:/layers/cgo/usr/include"; got != want {
+		t.Errorf("ConfigureLayerEnv() CPATH = %q, want %q", got, want)
+	}
+	if got, want := l.BuildEnvironment["PKG_CONFIG_PATH.prepend"], "/layers/cgo/usr/lib/x86_64-linux-gnu/pkgconfig:/layers/cgo/usr/lib/pkgconfig:/layers/cgo/usr/share/pkgconfig"; got != want {
+		t.Errorf("ConfigureLayerEnv() PKG_CONFIG_PATH = %q, want %q", got, want)
+	}
+}
+
+func TestCacheFormatVersion(t *testing.T) {
+	cacheformat.Check(t, "testdata/cache_format.golden", cache.WithFormatVersion(cacheFormatVersion), "apt.go")
+}
+
+func TestExecEnv(t *testing.T) {
+	t.Setenv("PATH", "/usr/bin")
+	t.Setenv("LD_LIBRARY_PATH", "")
+	t.Setenv("LIBRARY_PATH", "")
+	t.Setenv("CPATH", "")
+	t.Setenv("PKG_CONFIG_PATH", "")
+	t.Setenv("CNB_TARGET_ARCH", "amd64")
+	l := &libcnb.Layer{Path: "/layers/toolchain"}
+
+	want := []string{
+		"CPATH=/layers/toolchain/usr/include/x86_64-linux-gnu:/layers/toolchain/usr/include",
+		"LD_LIBRARY_PATH=/layers/toolchain/usr/lib/x86_64-linux-gnu:/layers/toolchain/usr/lib:/layers/toolchain/lib/x86_64-linux-gnu",
+		"LIBRARY_PATH=/layers/toolchain/usr/lib/x86_64-linux-gnu:/layers/toolchain/usr/lib:/layers/toolchain/lib/x86_64-linux-gnu",
+		"PATH=/layers/toolchain/usr/bin:/usr/bin",
+		"PKG_CONFIG_PATH=/layers/toolchain/usr/lib/x86_64-linux-gnu/pkgconfig:/layers/toolchain/usr/lib/pkgconfig:/layers/toolchain/usr/share/pkgconfig",
+	}
+	if diff := cmp.Diff(want, ExecEnv(gcp.NewContext(), l)); diff != "" {
+		t.Errorf("ExecEnv() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,267 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audit reports the findings of dependency vulnerability scanners in the builder output
+// and fails builds with findings at or above a configured severity.
+package audit
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/builderoutput"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// maxReportedFindings is the number of findings included in the builder output and the build log.
+// The counts always include all the findings.
+const maxReportedFindings = 20
+
+// Severity is the severity of a vulnerability.
+type Severity int
+
+// Severities in increasing order.
+const (
+	// SeverityUnknown is used for findings of scanners that do not report severities.
+	SeverityUnknown Severity = iota
+	SeverityLow
+	SeverityModerate
+	SeverityHigh
+	SeverityCritical
+)
+
+var severityNames = map[Severity]string{
+	SeverityUnknown:  "unknown",
+	SeverityLow:      "low",
+	SeverityModerate: "moderate",
+	SeverityHigh:     "high",
+	SeverityCritical: "critical",
+}
+
+func (s Severity) String() string {
+	return severityNames[s]
+}
+
+// ParseSeverity returns the severity with the given name, or SeverityUnknown if the name is not
+// recognized. The names used by npm, GitHub advisories and CVSS ratings are supported.
+func ParseSeverity(name string) Severity {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "info", "low":
+		return SeverityLow
+	case "moderate", "medium":
+		return SeverityModerate
+	case "high":
+		return SeverityHigh
+	case "critical":
+		return SeverityCritical
+	default:
+		return SeverityUnknown
+	}
+}
+
+// Config is the audit configuration of a buildpack, read from an env var such as
+// GOOGLE_NODEJS_AUDIT.
+type Config struct {
+	// Enabled is true if the scan runs.
+	Enabled bool
+	// Fail is true if findings at or above Threshold fail the build.
+	Fail bool
+	// Threshold is the lowest severity that fails the build. Findings of unknown severity fail the
+	// build whenever Fail is true.
+	Threshold Severity
+	envVar    string
+}
+
+// ParseConfig reads the audit configuration from the env var. The scan is disabled if the env var
+// is empty or false. It reports the findings without failing the build if the value is true or
+// `warn`, fails on any finding if the value is `fail`, and fails on findings at or above the
+// severity if the value is one of `low`, `moderate`, `high` or `critical`.
+func ParseConfig(envVar string) (Config, error) {
+	v := strings.ToLower(strings.TrimSpace(os.Getenv(envVar)))
+	if v == "" {
+		return Config{}, nil
+	}
+	if b, err := strconv.ParseBool(v); err == nil {
+		return Config{Enabled: b, envVar: envVar}, nil
+	}
+	switch v {
+	case "warn":
+		return Config{Enabled: true, envVar: envVar}, nil
+	case "fail":
+		return Config{Enabled: true, Fail: true, envVar: envVar}, nil
+	}
+	if s := ParseSeverity(v); s != SeverityUnknown {
+		return Config{Enabled: true, Fail: true, Threshold: s, envVar: envVar}, nil
+	}
+	return Config{}, gcp.UserErrorf("invalid %s %q, must be true, false, warn, fail or a severity: low, moderate, high or critical", envVar, v)
+}
+
+// Finding is a vulnerability reported by a scanner.
+type Finding struct {
+	// ID is the identifier of the advisory, e.g. "GHSA-xvch-5gv4-984h" or "GO-2023-1571".
+	ID string
+	// Package is the name of the affected dependency.
+	Package string
+	// Version is the installed version of the affected dependency, if known.
+	Version  string
+	Severity Severity
+	Title    string
+	URL      string
+	// Symbols are the affected functions that the application calls, if the scanner reports them.
+	Symbols []string
+}
+
+// Report is the result of a dependency vulnerability scan.
+type Report struct {
+	// Scanner is the tool that produced the report, e.g. "npm audit".
+	Scanner string
+	// FixCommand is the command that upgrades the affected dependencies, if the scanner has one.
+	FixCommand string
+	// StrictCategory is the warning category of critical findings that do not fail the build, which
+	// GOOGLE_STRICT_BUILD can promote to a failure.
+	StrictCategory gcp.WarningCategory
+	Findings       []Finding
+}
+
+// Count returns the number of findings of the severity.
+func (r Report) Count(s Severity) int {
+	n := 0
+	for _, f := range r.Findings {
+		if f.Severity == s {
+			n++
+		}
+	}
+	return n
+}
+
+// Check adds the report to the builder output and logs the findings. It returns a user error if the
+// configuration fails the build on any of them.
+func (c Config) Check(ctx *gcp.Context, r Report) error {
+	findings := sortedFindings(r.Findings)
+	ctx.AddVulnerabilityReport(c.builderOutputReport(r.Scanner, findings))
+	if len(findings) == 0 {
+		ctx.Logf("%s found no vulnerabilities.", r.Scanner)
+		return nil
+	}
+
+	summary := fmt.Sprintf("%s found %d vulnerabilities (%s)", r.Scanner, len(findings), countsSummary(r))
+	ctx.Logf("%s:", summary)
+	for i, f := range findings {
+		if i == maxReportedFindings {
+			ctx.Logf("  ... and %d more", len(findings)-maxReportedFindings)
+			break
+		}
+		ctx.Logf("  %s", f)
+	}
+
+	if failing := c.failing(findings); failing > 0 {
+		be := gcp.UserErrorf("%s: %d at or above the %s threshold set by %s", summary, failing, c.thresholdName(), c.envVar).
+			WithCode(buildererror.CodeVulnerabilitiesFound)
+		return be.WithHint(fmt.Sprintf("Upgrade the affected dependencies, or change %s to allow them.", c.envVar), r.FixCommand)
+	}
+	if r.StrictCategory != "" && r.Count(SeverityCritical) > 0 {
+		return ctx.StrictWarnf(r.StrictCategory, "%s.", summary)
+	}
+	ctx.Warnf("%s.", summary)
+	return nil
+}
+
+// String returns a one line description of the finding for the build log.
+func (f Finding) String() string {
+	pkg := f.Package
+	if f.Version != "" {
+		pkg += "@" + f.Version
+	}
+	s := fmt.Sprintf("[%s] %s: %s", f.Severity, pkg, f.ID)
+	if f.Title != "" {
+		s += " " + f.Title
+	}
+	if len(f.Symbols) > 0 {
+		s += fmt.Sprintf(" (called: %s)", strings.Join(f.Symbols, ", "))
+	}
+	return s
+}
+
+// failing returns the number of findings that fail the build.
+func (c Config) failing(findings []Finding) int {
+	if !c.Fail {
+		return 0
+	}
+	n := 0
+	for _, f := range findings {
+		if f.Severity == SeverityUnknown || f.Severity >= c.Threshold {
+			n++
+		}
+	}
+	return n
+}
+
+func (c Config) thresholdName() string {
+	if c.Threshold == SeverityUnknown {
+		return "any severity"
+	}
+	return c.Threshold.String() + " severity"
+}
+
+func (c Config) builderOutputReport(scanner string, findings []Finding) builderoutput.VulnerabilityReport {
+	report := builderoutput.VulnerabilityReport{Scanner: scanner, Counts: map[string]int{}}
+	for i, f := range findings {
+		report.Counts[f.Severity.String()]++
+		if i < maxReportedFindings {
+			report.Findings = append(report.Findings, builderoutput.Vulnerability{
+				ID:       f.ID,
+				Package:  f.Package,
+				Version:  f.Version,
+				Severity: f.Severity.String(),
+				Title:    f.Title,
+				URL:      f.URL,
+				Symbols:  f.Symbols,
+			})
+		}
+	}
+	return report
+}
+
+// countsSummary returns the number of findings of each severity, most severe first, e.g.
+// "1 critical, 2 high".
+func countsSummary(r Report) string {
+	var parts []string
+	for s := SeverityCritical; s >= SeverityUnknown; s-- {
+		if n := r.Count(s); n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, s))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// sortedFindings returns the findings sorted by decreasing severity, then by package and ID.
+func sortedFindings(findings []Finding) []Finding {
+	sorted := make([]Finding, len(findings))
+	copy(sorted, findings)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Severity != b.Severity {
+			return a.Severity > b.Severity
+		}
+		if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		return a.ID < b.ID
+	})
+	return sorted
+}
@@ -0,0 +1,156 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseConfig(t *testing.T) {
+	testCases := []struct {
+		value   string
+		want    Config
+		wantErr bool
+	}{
+		{value: "", want: Config{}},
+		{value: "false", want: Config{envVar: "AUDIT"}},
+		{value: "true", want: Config{Enabled: true, envVar: "AUDIT"}},
+		{value: "warn", want: Config{Enabled: true, envVar: "AUDIT"}},
+		{value: "fail", want: Config{Enabled: true, Fail: true, envVar: "AUDIT"}},
+		{value: "High", want: Config{Enabled: true, Fail: true, Threshold: SeverityHigh, envVar: "AUDIT"}},
+		{value: "medium", want: Config{Enabled: true, Fail: true, Threshold: SeverityModerate, envVar: "AUDIT"}},
+		{value: "severe", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			t.Setenv("AUDIT", tc.value)
+
+			got, err := ParseConfig("AUDIT")
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("ParseConfig() got error: %v, want error? %t", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(Config{})); diff != "" {
+				t.Errorf("ParseConfig() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	findings := []Finding{
+		{ID: "GHSA-1", Package: "lodash", Version: "4.17.15", Severity: SeverityHigh, Title: "Prototype Pollution"},
+		{ID: "GHSA-2", Package: "minimist", Version: "0.0.8", Severity: SeverityCritical},
+		{ID: "GHSA-3", Package: "debug", Version: "2.6.8", Severity: SeverityLow},
+	}
+	testCases := []struct {
+		name     string
+		value    string
+		strict   string
+		findings []Finding
+		wantErr  bool
+		wantCode buildererror.Code
+	}{
+		{
+			name:     "no findings",
+			value:    "critical",
+			findings: nil,
+		},
+		{
+			name:     "report only",
+			value:    "true",
+			findings: findings,
+		},
+		{
+			name:     "below threshold",
+			value:    "critical",
+			findings: findings[:1],
+		},
+		{
+			name:     "at threshold",
+			value:    "high",
+			findings: findings,
+			wantErr:  true,
+			wantCode: buildererror.CodeVulnerabilitiesFound,
+		},
+		{
+			name:     "fail on unknown severity",
+			value:    "critical",
+			findings: []Finding{{ID: "PYSEC-1", Package: "flask", Version: "0.5"}},
+			wantErr:  true,
+			wantCode: buildererror.CodeVulnerabilitiesFound,
+		},
+		{
+			name:     "critical in strict build",
+			value:    "true",
+			strict:   "npm-audit-critical",
+			findings: findings,
+			wantErr:  true,
+			wantCode: buildererror.CodeStrictBuildWarning,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("AUDIT", tc.value)
+			t.Setenv("GOOGLE_STRICT_BUILD", tc.strict)
+			cfg, err := ParseConfig("AUDIT")
+			if err != nil {
+				t.Fatalf("ParseConfig() got error: %v", err)
+			}
+			ctx := gcp.NewContext()
+
+			err = cfg.Check(ctx, Report{Scanner: "npm audit", FixCommand: "npm audit fix", StrictCategory: gcp.WarningNPMAuditCritical, Findings: tc.findings})
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("Check() got error: %v, want error? %t", err, tc.wantErr)
+			}
+			if got := buildererror.CodeOf(err); got != tc.wantCode {
+				t.Errorf("Check() error code = %q, want %q", got, tc.wantCode)
+			}
+		})
+	}
+}
+
+func TestBuilderOutputReport(t *testing.T) {
+	findings := sortedFindings([]Finding{
+		{ID: "GHSA-3", Package: "debug", Severity: SeverityLow},
+		{ID: "GHSA-1", Package: "lodash", Severity: SeverityHigh},
+		{ID: "GHSA-2", Package: "minimist", Severity: SeverityCritical, Symbols: []string{"minimist.parse"}},
+	})
+	for i := 0; i < maxReportedFindings; i++ {
+		findings = append(findings, Finding{ID: "GHSA-4", Package: "moment", Severity: SeverityModerate})
+	}
+
+	got := Config{}.builderOutputReport("npm audit", findings)
+	if want := map[string]int{"critical": 1, "high": 1, "low": 1, "moderate": maxReportedFindings}; !cmp.Equal(got.Counts, want) {
+		t.Errorf("builderOutputReport() counts = %v, want %v", got.Counts, want)
+	}
+	if len(got.Findings) != maxReportedFindings {
+		t.Errorf("builderOutputReport() got %d findings, want %d", len(got.Findings), maxReportedFindings)
+	}
+	if first := got.Findings[0]; first.ID != "GHSA-2" || first.Severity != "critical" || len(first.Symbols) != 1 {
+		t.Errorf("builderOutputReport() first finding = %+v, want the critical finding", first)
+	}
+}
+
+func TestFindingString(t *testing.T) {
+	f := Finding{ID: "GO-2023-1571", Package: "golang.org/x/net", Version: "v0.5.0", Severity: SeverityUnknown, Title: "Denial of service", Symbols: []string{"http2.Server.ServeConn"}}
+	want := "[unknown] golang.org/x/net@v0.5.0: GO-2023-1571 Denial of service (called: http2.Server.ServeConn)"
+	if got := f.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package buildcommand runs a user-provided build command in place of a language buildpack's
+// default build step.
+package buildcommand
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// layerName is the name of the cache layer that holds HOME and tool caches for the command.
+	layerName = "build_command"
+	homeDir   = "home"
+	cacheDir  = "cache"
+)
+
+// Config configures how a language buildpack runs the user-provided build command.
+type Config struct {
+	// CacheEnv maps tool cache env vars to directories relative to the cache layer,
+	// e.g. {"GOCACHE": "go-build"}.
+	CacheEnv map[string]string
+	// Env contains additional env vars (of the form "KEY=value") for the command.
+	Env []string
+	// Home overrides the HOME directory of the command. By default HOME is placed in the cache layer.
+	Home string
+	// DefaultOutput is the path or glob, relative to the application root, that the command is
+	// expected to produce when GOOGLE_BUILD_OUTPUT is not set. If empty, the output is not validated.
+	DefaultOutput string
+}
+
+// Command returns the user-provided build command and whether it is set.
+func Command() (string, bool) {
+	cmd := strings.TrimSpace(os.Getenv(env.BuildCommand))
+	return cmd, cmd != ""
+}
+
+// Run runs the user-provided build command with HOME and tool caches pointed into a cache layer,
+// then validates that the expected output exists. It returns the paths matching the expected output.
+func Run(ctx *gcp.Context, cfg Config) ([]string, error) {
+	command, ok := Command()
+	if !ok {
+		return nil, gcp.InternalErrorf("%s is not set", env.BuildCommand)
+	}
+	l, err := ctx.Layer(layerName, gcp.BuildLayer, gcp.CacheLayer)
+	if err != nil {
+		return nil, fmt.Errorf("creating %v layer: %w", layerName, err)
+	}
+	cmdEnv, err := commandEnv(ctx, l.Path, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx.Logf("Running build command from %s: %q", env.BuildCommand, command)
+	if _, err := ctx.Exec([]string{"bash", "-c", command}, gcp.WithEnv(cmdEnv...), gcp.WithWorkDir(ctx.ApplicationRoot()), gcp.WithUserAttribution); err != nil {
+		return nil, err
+	}
+
+	pattern := os.Getenv(env.BuildOutput)
+	if pattern == "" {
+		pattern = cfg.DefaultOutput
+	}
+	if pattern == "" {
+		return nil, nil
+	}
+	return validateOutput(ctx, pattern)
+}
+
+// commandEnv creates the directories the command uses for HOME and tool caches and returns the
+// corresponding env vars.
+func commandEnv(ctx *gcp.Context, layerPath string, cfg Config) ([]string, error) {
+	home := cfg.Home
+	if home == "" {
+		home = filepath.Join(layerPath, homeDir)
+	}
+	dirs := map[string]string{
+		"HOME":           home,
+		"XDG_CACHE_HOME": filepath.Join(layerPath, cacheDir),
+	}
+	for k, v := range cfg.CacheEnv {
+		dirs[k] = filepath.Join(layerPath, v)
+	}
+
+	var keys []string
+	for k := range dirs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var result []string
+	for _, k := range keys {
+		if err := ctx.MkdirAll(dirs[k], 0755); err != nil {
+			return nil, err
+		}
+		result = append(result, fmt.Sprintf("%s=%s", k, dirs[k]))
+	}
+	return append(result, cfg.Env...), nil
+}
+
+// validateOutput returns the paths matching pattern relative to the application root, or a user
+// error if there are none.
+func validateOutput(ctx *gcp.Context, pattern string) ([]string, error) {
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(ctx.ApplicationRoot(), pattern)
+	}
+	matches, err := ctx.Glob(pattern)
+	if err != nil {
+		return nil, gcp.UserErrorf("invalid %s %q: %v", env.BuildOutput, pattern, err)
+	}
+	if len(matches) == 0 {
+		return nil, gcp.UserErrorf("%s completed but did not produce the expected output %q; update the command or set %s to the path it produces", env.BuildCommand, pattern, env.BuildOutput)
+	}
+	return matches, nil
+}
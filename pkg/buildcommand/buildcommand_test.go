@@ -0,0 +1,177 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildcommand
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCommand(t *testing.T) {
+	testCases := []struct {
+		name    string
+		value   string
+		want    string
+		wantSet bool
+	}{
+		{
+			name: "unset",
+		},
+		{
+			name:  "whitespace only",
+			value: "  ",
+		},
+		{
+			name:    "command",
+			value:   " make build ",
+			want:    "make build",
+			wantSet: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("GOOGLE_BUILD_COMMAND", tc.value)
+
+			got, gotSet := Command()
+			if got != tc.want || gotSet != tc.wantSet {
+				t.Errorf("Command() = %q, %t, want %q, %t", got, gotSet, tc.want, tc.wantSet)
+			}
+		})
+	}
+}
+
+func TestCommandEnv(t *testing.T) {
+	testCases := []struct {
+		name string
+		cfg  Config
+		want []string
+	}{
+		{
+			name: "defaults",
+			want: []string{"HOME=<layer>/home", "XDG_CACHE_HOME=<layer>/cache"},
+		},
+		{
+			name: "tool caches",
+			cfg: Config{
+				CacheEnv: map[string]string{"GOCACHE": "go-build", "GOMODCACHE": "gomod"},
+				Env:      []string{"CGO_ENABLED=0"},
+			},
+			want: []string{"GOCACHE=<layer>/go-build", "GOMODCACHE=<layer>/gomod", "HOME=<layer>/home", "XDG_CACHE_HOME=<layer>/cache", "CGO_ENABLED=0"},
+		},
+		{
+			name: "home override",
+			cfg:  Config{Home: "<layer>/custom"},
+			want: []string{"HOME=<layer>/custom", "XDG_CACHE_HOME=<layer>/cache"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			layer := t.TempDir()
+			tc.cfg.Home = strings.ReplaceAll(tc.cfg.Home, "<layer>", layer)
+			ctx := gcp.NewContext()
+
+			got, err := commandEnv(ctx, layer, tc.cfg)
+			if err != nil {
+				t.Fatalf("commandEnv() got error: %v", err)
+			}
+
+			var want []string
+			for _, e := range tc.want {
+				want = append(want, strings.ReplaceAll(e, "<layer>", layer))
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("commandEnv() mismatch (-want +got):\n%s", diff)
+			}
+			for _, e := range got {
+				dir := strings.SplitN(e, "=", 2)[1]
+				if !strings.HasPrefix(dir, layer) {
+					continue
+				}
+				if _, err := os.Stat(dir); err != nil {
+					t.Errorf("directory for %q was not created: %v", e, err)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateOutput(t *testing.T) {
+	testCases := []struct {
+		name    string
+		files   []string
+		pattern string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "binary",
+			files:   []string{"main"},
+			pattern: "main",
+			want:    []string{"main"},
+		},
+		{
+			name:    "glob",
+			files:   []string{"target/app.jar", "target/classes/Main.class"},
+			pattern: "target/*.jar",
+			want:    []string{"target/app.jar"},
+		},
+		{
+			name:    "directory",
+			files:   []string{"dist/index.js"},
+			pattern: "dist",
+			want:    []string{"dist"},
+		},
+		{
+			name:    "missing output",
+			files:   []string{"main.go"},
+			pattern: "main",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			for _, f := range tc.files {
+				path := filepath.Join(root, f)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("creating directory for %s: %v", path, err)
+				}
+				if err := ioutil.WriteFile(path, []byte{}, 0644); err != nil {
+					t.Fatalf("writing file %s: %v", path, err)
+				}
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(root))
+
+			got, err := validateOutput(ctx, tc.pattern)
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("validateOutput(%q) got error: %v, want error: %t", tc.pattern, err, tc.wantErr)
+			}
+
+			var want []string
+			for _, w := range tc.want {
+				want = append(want, filepath.Join(root, w))
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("validateOutput(%q) mismatch (-want +got):\n%s", tc.pattern, diff)
+			}
+		})
+	}
+}
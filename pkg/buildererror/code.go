@@ -0,0 +1,76 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildererror
+
+import (
+	"errors"
+)
+
+// Code is a machine-readable identifier of a class of build failures. It is included in the
+// builder output so that failures can be mapped to documentation and remediation steps without
+// matching on error messages, which may change.
+type Code string
+
+// Codes of known build failures. Codes are part of the builder output contract and must not be
+// renamed once released.
+const (
+	// CodeDownloadFailed is set when a runtime, tool or dependency could not be downloaded.
+	CodeDownloadFailed Code = "DOWNLOAD_FAILED"
+	// CodeInvalidRuntimeVersion is set when the requested runtime version is invalid or unavailable.
+	CodeInvalidRuntimeVersion Code = "INVALID_RUNTIME_VERSION"
+	// CodeFunctionTargetNotFound is set when the function target is not defined by the build output.
+	CodeFunctionTargetNotFound Code = "FUNCTION_TARGET_NOT_FOUND"
+	// CodeGoWorkParseError is set when the go.work file cannot be parsed.
+	CodeGoWorkParseError Code = "GO_WORK_PARSE_ERROR"
+	// CodeJavaPomParseError is set when the pom.xml file cannot be parsed.
+	CodeJavaPomParseError Code = "JAVA_POM_PARSE_ERROR"
+	// CodeNodejsGCPBuildFailed is set when the gcp-build script in package.json fails.
+	CodeNodejsGCPBuildFailed Code = "NODEJS_GCP_BUILD_FAILED"
+	// CodeNodejsLockfileOutOfSync is set when the lockfile does not match the dependencies in
+	// package.json and the package manager refuses to update it.
+	CodeNodejsLockfileOutOfSync Code = "NODEJS_LOCKFILE_OUT_OF_SYNC"
+	// CodeNodejsMissingMain is set when the file of the "main" field in package.json, or the default
+	// index.js or function.js, does not exist.
+	CodeNodejsMissingMain Code = "NODEJS_MISSING_MAIN"
+	// CodeNodejsPackageJSONParseError is set when the package.json file cannot be parsed.
+	CodeNodejsPackageJSONParseError Code = "NODEJS_PACKAGE_JSON_PARSE_ERROR"
+	// CodePythonDependencyConflict is set when `pip check` finds incompatible dependencies.
+	CodePythonDependencyConflict Code = "PYTHON_DEPENDENCY_CONFLICT"
+	// CodePythonMissingMain is set when the source file of a Python function does not exist.
+	CodePythonMissingMain Code = "PYTHON_MISSING_MAIN"
+	// CodePythonRequirementsParseError is set when pip cannot parse a requirements file.
+	CodePythonRequirementsParseError Code = "PYTHON_REQUIREMENTS_PARSE_ERROR"
+	// CodeVulnerabilitiesFound is set when a dependency scan finds vulnerabilities at or above the
+	// configured severity threshold.
+	CodeVulnerabilitiesFound Code = "VULNERABILITIES_FOUND"
+	// CodeStrictBuildWarning is set when GOOGLE_STRICT_BUILD promotes a warning to a build failure.
+	CodeStrictBuildWarning Code = "STRICT_BUILD_WARNING"
+)
+
+// WithCode sets the code of the error and returns it, e.g.
+// UserErrorf("%s does not exist", file).WithCode(CodeNodejsMissingMain).
+func (e *Error) WithCode(code Code) *Error {
+	e.Code = code
+	return e
+}
+
+// CodeOf returns the code of the first Error in the chain of err, or an empty code if there is none.
+func CodeOf(err error) Code {
+	var be *Error
+	if errors.As(err, &be) {
+		return be.Code
+	}
+	return ""
+}
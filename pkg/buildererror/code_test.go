@@ -0,0 +1,77 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildererror
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCodeOf(t *testing.T) {
+	testCases := []struct {
+		name string
+		err  error
+		want Code
+	}{
+		{
+			name: "error with code",
+			err:  UserErrorf("index.js does not exist").WithCode(CodeNodejsMissingMain),
+			want: CodeNodejsMissingMain,
+		},
+		{
+			name: "wrapped error with code",
+			err:  fmt.Errorf("installing dependencies: %w", UserErrorf("invalid requirement").WithCode(CodePythonRequirementsParseError)),
+			want: CodePythonRequirementsParseError,
+		},
+		{
+			name: "error without code",
+			err:  InternalErrorf("failed"),
+		},
+		{
+			name: "other error",
+			err:  fmt.Errorf("failed"),
+		},
+		{
+			name: "nil error",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := CodeOf(tc.err); got != tc.want {
+				t.Errorf("CodeOf(%v) = %q, want %q", tc.err, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestErrorCodeJSON(t *testing.T) {
+	b, err := json.Marshal(UserErrorf("index.js does not exist").WithCode(CodeNodejsMissingMain))
+	if err != nil {
+		t.Fatalf("json.Marshal() got error: %v", err)
+	}
+	if want := `"errorCode":"NODEJS_MISSING_MAIN"`; !strings.Contains(string(b), want) {
+		t.Errorf("json.Marshal() = %s, want it to contain %s", b, want)
+	}
+
+	b, err = json.Marshal(UserErrorf("failed"))
+	if err != nil {
+		t.Fatalf("json.Marshal() got error: %v", err)
+	}
+	if strings.Contains(string(b), "errorCode") {
+		t.Errorf("json.Marshal() = %s, want no errorCode for errors without a code", b)
+	}
+}
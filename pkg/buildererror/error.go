@@ -36,7 +36,9 @@ type Error struct {
 	Type             Status `json:"errorType"`
 	Status           Status `json:"canonicalCode"`
 	ID               ID     `json:"errorId"`
+	Code             Code   `json:"errorCode,omitempty"`
 	Message          string `json:"errorMessage"`
+	Hint             *Hint  `json:"errorHint,omitempty"`
 }
 
 func (e *Error) Error() string {
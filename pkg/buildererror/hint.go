@@ -0,0 +1,56 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildererror
+
+import (
+	"errors"
+	"strings"
+)
+
+// Hint tells the user how to fix a build failure.
+type Hint struct {
+	// Message describes what needs to change, e.g. "Add a start script to package.json.".
+	Message string `json:"message"`
+	// Command is the exact command that fixes the failure, if there is one.
+	Command string `json:"command,omitempty"`
+}
+
+// String returns the hint as the "How to fix" block printed after a build failure.
+func (h *Hint) String() string {
+	var sb strings.Builder
+	sb.WriteString("How to fix:\n  ")
+	sb.WriteString(h.Message)
+	if h.Command != "" {
+		sb.WriteString("\n  Run: ")
+		sb.WriteString(h.Command)
+	}
+	return sb.String()
+}
+
+// WithHint attaches a remediation hint to the error and returns it, e.g.
+// UserErrorf("package-lock.json is out of sync").WithHint("Update the lockfile and commit it.", "npm install").
+func (e *Error) WithHint(message, command string) *Error {
+	e.Hint = &Hint{Message: message, Command: command}
+	return e
+}
+
+// HintOf returns the hint of the first Error in the chain of err, or nil if there is none.
+func HintOf(err error) *Hint {
+	var be *Error
+	if errors.As(err, &be) {
+		return be.Hint
+	}
+	return nil
+}
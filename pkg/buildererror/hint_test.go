@@ -0,0 +1,80 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildererror
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestHintString(t *testing.T) {
+	testCases := []struct {
+		name string
+		hint Hint
+		want string
+	}{
+		{
+			name: "with command",
+			hint: Hint{Message: "Update package-lock.json and commit it.", Command: "npm install"},
+			want: "How to fix:\n  Update package-lock.json and commit it.\n  Run: npm install",
+		},
+		{
+			name: "without command",
+			hint: Hint{Message: "Fix the errors reported by the gcp-build script."},
+			want: "How to fix:\n  Fix the errors reported by the gcp-build script.",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.hint.String(); got != tc.want {
+				t.Errorf("String() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHintOf(t *testing.T) {
+	err := fmt.Errorf("installing dependencies: %w", UserErrorf("out of sync").WithHint("Update the lockfile.", "npm install"))
+	got := HintOf(err)
+	if got == nil || got.Message != "Update the lockfile." || got.Command != "npm install" {
+		t.Errorf("HintOf(%v) = %+v, want the attached hint", err, got)
+	}
+	if got := HintOf(UserErrorf("failed")); got != nil {
+		t.Errorf("HintOf() = %+v, want nil for errors without a hint", got)
+	}
+	if got := HintOf(fmt.Errorf("failed")); got != nil {
+		t.Errorf("HintOf() = %+v, want nil for other errors", got)
+	}
+}
+
+func TestErrorHintJSON(t *testing.T) {
+	b, err := json.Marshal(UserErrorf("out of sync").WithHint("Update the lockfile.", "npm install"))
+	if err != nil {
+		t.Fatalf("json.Marshal() got error: %v", err)
+	}
+	if want := `"errorHint":{"message":"Update the lockfile.","command":"npm install"}`; !strings.Contains(string(b), want) {
+		t.Errorf("json.Marshal() = %s, want it to contain %s", b, want)
+	}
+
+	b, err = json.Marshal(UserErrorf("failed"))
+	if err != nil {
+		t.Fatalf("json.Marshal() got error: %v", err)
+	}
+	if strings.Contains(string(b), "errorHint") {
+		t.Errorf("json.Marshal() = %s, want no errorHint for errors without a hint", b)
+	}
+}
@@ -33,8 +33,9 @@ type CounterID string
 // Intended usage:
 //   buildermetrics.GlobalBuilderMetrics().GetCounter(buildermetrics.MyNewMetric).Increment(1)
 const (
-	ArNpmCredsGenCounterID    CounterID = "1"
-	NpmGcpBuildUsageCounterID CounterID = "2"
+	ArNpmCredsGenCounterID       CounterID = "1"
+	NpmGcpBuildUsageCounterID    CounterID = "2"
+	ExecOutputTruncatedCounterID CounterID = "3"
 )
 
 var (
@@ -47,6 +48,10 @@ var (
 			"npm_gcp_build_script_uses",
 			"The number of times the gcp-build script is used by npm developers",
 		},
+		ExecOutputTruncatedCounterID: Descriptor{
+			"exec_output_truncated",
+			"The number of commands whose output exceeded the output limit and was truncated",
+		},
 	}
 )
 
@@ -49,6 +49,7 @@ type BuilderOutput struct {
 	Stats                    []BuilderStat                 `json:"stats"`
 	Warnings                 []string                      `json:"warnings"`
 	CustomImage              bool                          `json:"customImage"`
+	Vulnerabilities          []VulnerabilityReport         `json:"vulnerabilities,omitempty"`
 }
 
 // IsSystemError determines if the error type is a SYSTEM-attributed error
@@ -63,3 +64,25 @@ type BuilderStat struct {
 	DurationMs       int64  `json:"totalDurationMs"`
 	UserDurationMs   int64  `json:"userDurationMs"`
 }
+
+// VulnerabilityReport summarizes the findings of a dependency vulnerability scan.
+type VulnerabilityReport struct {
+	BuildpackID string `json:"buildpackId"`
+	// Scanner is the tool that produced the report, e.g. "npm audit".
+	Scanner string `json:"scanner"`
+	// Counts is the number of findings of each severity.
+	Counts map[string]int `json:"counts"`
+	// Findings are the most severe findings, the list is truncated to keep the output small.
+	Findings []Vulnerability `json:"findings,omitempty"`
+}
+
+// Vulnerability describes a vulnerability that affects a dependency of the application.
+type Vulnerability struct {
+	ID       string   `json:"id"`
+	Package  string   `json:"package"`
+	Version  string   `json:"version,omitempty"`
+	Severity string   `json:"severity"`
+	Title    string   `json:"title,omitempty"`
+	URL      string   `json:"url,omitempty"`
+	Symbols  []string `json:"symbols,omitempty"`
+}
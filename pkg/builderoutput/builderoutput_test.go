@@ -111,6 +111,11 @@ func TestJSON(t *testing.T) {
 		InstalledRuntimeVersions: []string{"6.0.6"},
 		Metrics:                  bm,
 		Error:                    buildererror.Error{Status: buildererror.StatusInternal},
+		Vulnerabilities: []VulnerabilityReport{{
+			Scanner:  "npm audit",
+			Counts:   map[string]int{"critical": 1},
+			Findings: []Vulnerability{{ID: "GHSA-xvch-5gv4-984h", Package: "minimist", Severity: "critical"}},
+		}},
 	}
 
 	s, err := b.JSON()
@@ -127,6 +132,9 @@ func TestJSON(t *testing.T) {
 	if want := `{"c":{"1":3}}`; !strings.Contains(string(s), want) {
 		t.Errorf(`Expected string %q not found in %s`, want, s)
 	}
+	if want := `"vulnerabilities":[{"buildpackId":"","scanner":"npm audit","counts":{"critical":1},"findings":[{"id":"GHSA-xvch-5gv4-984h","package":"minimist","severity":"critical"}]}]`; !strings.Contains(string(s), want) {
+		t.Errorf(`Expected string %q not found in %s`, want, s)
+	}
 }
 
 func TestIsSystemError(t *testing.T) {
@@ -0,0 +1,187 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builderoutput
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// ProvenanceFilename is the name of the provenance statement in the builder output directory.
+	ProvenanceFilename = "provenance.intoto.json"
+	// StatementType is the type of in-toto v1 statements.
+	StatementType = "https://in-toto.io/Statement/v1"
+	// ProvenancePredicateType is the predicate type of SLSA v1 provenance.
+	ProvenancePredicateType = "https://slsa.dev/provenance/v1"
+	// BuildType describes how the external parameters of the provenance are interpreted.
+	BuildType = "https://github.com/GoogleCloudPlatform/buildpacks/slsa/build/v1"
+	// BuilderID identifies the builder in the provenance.
+	BuilderID = "https://github.com/GoogleCloudPlatform/buildpacks"
+)
+
+// Statement is an in-toto statement that holds SLSA provenance, see
+// https://slsa.dev/spec/v1.0/provenance. Its subject is left empty: the image digest is only known
+// once the image is exported, the signing tool adds it when it attaches the statement to the image.
+type Statement struct {
+	Type          string               `json:"_type"`
+	Subject       []ResourceDescriptor `json:"subject"`
+	PredicateType string               `json:"predicateType"`
+	Predicate     Provenance           `json:"predicate"`
+}
+
+// Provenance is a SLSA v1 provenance predicate.
+type Provenance struct {
+	BuildDefinition BuildDefinition `json:"buildDefinition"`
+	RunDetails      RunDetails      `json:"runDetails"`
+}
+
+// BuildDefinition describes the inputs of the build.
+type BuildDefinition struct {
+	BuildType            string               `json:"buildType"`
+	ExternalParameters   ExternalParameters   `json:"externalParameters"`
+	InternalParameters   InternalParameters   `json:"internalParameters"`
+	ResolvedDependencies []ResourceDescriptor `json:"resolvedDependencies"`
+}
+
+// ExternalParameters are the inputs of the build under the control of the user.
+type ExternalParameters struct {
+	// Source is the application source, identified by its directory hash.
+	Source ResourceDescriptor `json:"source"`
+	// Env are the GOOGLE_* environment variables that configure the buildpacks.
+	Env map[string]string `json:"env,omitempty"`
+}
+
+// InternalParameters are the inputs of the build under the control of the builder.
+type InternalParameters struct {
+	StackID string `json:"stackId,omitempty"`
+}
+
+// RunDetails describes the execution of the build.
+type RunDetails struct {
+	Builder  Builder       `json:"builder"`
+	Metadata BuildMetadata `json:"metadata"`
+}
+
+// Builder identifies the builder and the versions of the buildpacks that ran.
+type Builder struct {
+	ID      string            `json:"id"`
+	Version map[string]string `json:"version,omitempty"`
+}
+
+// BuildMetadata holds the times of the build.
+type BuildMetadata struct {
+	StartedOn  *time.Time `json:"startedOn,omitempty"`
+	FinishedOn *time.Time `json:"finishedOn,omitempty"`
+}
+
+// ResourceDescriptor describes an artifact used by the build, e.g. a buildpack or a runtime.
+type ResourceDescriptor struct {
+	Name        string            `json:"name,omitempty"`
+	URI         string            `json:"uri,omitempty"`
+	Digest      map[string]string `json:"digest,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// NewStatement returns an empty provenance statement.
+func NewStatement() Statement {
+	return Statement{
+		Type:          StatementType,
+		Subject:       []ResourceDescriptor{},
+		PredicateType: ProvenancePredicateType,
+		Predicate: Provenance{
+			BuildDefinition: BuildDefinition{BuildType: BuildType, ResolvedDependencies: []ResourceDescriptor{}},
+			RunDetails:      RunDetails{Builder: Builder{ID: BuilderID}},
+		},
+	}
+}
+
+// StatementFromJSON parses json bytes to a Statement.
+func StatementFromJSON(bytes []byte) (Statement, error) {
+	var s Statement
+	if err := json.Unmarshal(bytes, &s); err != nil {
+		return Statement{}, fmt.Errorf("unmarshalling json: %w", err)
+	}
+	return s, nil
+}
+
+// JSON encodes a Statement as indented json.
+func (s Statement) JSON() ([]byte, error) {
+	bytes, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshalling json: %w", err)
+	}
+	return bytes, nil
+}
+
+// BuildpackRun describes the contribution of a buildpack to the provenance.
+type BuildpackRun struct {
+	ID      string
+	Version string
+	// Env are the configuration environment variables seen by the buildpack.
+	Env map[string]string
+	// Dependencies are the runtimes and tools the buildpack installed.
+	Dependencies []ResourceDescriptor
+	StartedOn    time.Time
+	FinishedOn   time.Time
+}
+
+// AddBuildpackRun merges the inputs of a buildpack into the provenance. Buildpacks run one after
+// the other, so the build starts with the first run and finishes with the last one.
+func (s *Statement) AddBuildpackRun(r BuildpackRun) {
+	p := &s.Predicate
+	if p.RunDetails.Builder.Version == nil {
+		p.RunDetails.Builder.Version = map[string]string{}
+	}
+	p.RunDetails.Builder.Version[r.ID] = r.Version
+	if p.RunDetails.Metadata.StartedOn == nil {
+		started := r.StartedOn.UTC()
+		p.RunDetails.Metadata.StartedOn = &started
+	}
+	finished := r.FinishedOn.UTC()
+	p.RunDetails.Metadata.FinishedOn = &finished
+
+	for k, v := range r.Env {
+		if p.BuildDefinition.ExternalParameters.Env == nil {
+			p.BuildDefinition.ExternalParameters.Env = map[string]string{}
+		}
+		p.BuildDefinition.ExternalParameters.Env[k] = v
+	}
+
+	deps := append([]ResourceDescriptor{{
+		Name:        r.ID,
+		URI:         "urn:cnb:buildpack:" + r.ID + "@" + r.Version,
+		Annotations: map[string]string{"type": "buildpack", "version": r.Version},
+	}}, r.Dependencies...)
+	for _, d := range deps {
+		if !containsResource(p.BuildDefinition.ResolvedDependencies, d) {
+			p.BuildDefinition.ResolvedDependencies = append(p.BuildDefinition.ResolvedDependencies, d)
+		}
+	}
+	sort.SliceStable(p.BuildDefinition.ResolvedDependencies, func(i, j int) bool {
+		return p.BuildDefinition.ResolvedDependencies[i].URI < p.BuildDefinition.ResolvedDependencies[j].URI
+	})
+}
+
+func containsResource(resources []ResourceDescriptor, r ResourceDescriptor) bool {
+	for _, o := range resources {
+		if o.URI == r.URI && o.Name == r.Name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builderoutput
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAddBuildpackRun(t *testing.T) {
+	start := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := NewStatement()
+	s.AddBuildpackRun(BuildpackRun{
+		ID:           "google.nodejs.runtime",
+		Version:      "1.0.0",
+		Env:          map[string]string{"GOOGLE_RUNTIME_VERSION": "18"},
+		Dependencies: []ResourceDescriptor{{Name: "nodejs", URI: "pkg:generic/nodejs@18.16.0"}},
+		StartedOn:    start,
+		FinishedOn:   start.Add(10 * time.Second),
+	})
+	s.AddBuildpackRun(BuildpackRun{
+		ID:           "google.nodejs.npm",
+		Version:      "1.1.0",
+		Env:          map[string]string{"GOOGLE_NODE_RUN_SCRIPTS": "build"},
+		Dependencies: []ResourceDescriptor{{Name: "nodejs", URI: "pkg:generic/nodejs@18.16.0"}},
+		StartedOn:    start.Add(10 * time.Second),
+		FinishedOn:   start.Add(time.Minute),
+	})
+
+	// Round-trip through JSON as each buildpack reads the statement written by the previous one.
+	b, err := s.JSON()
+	if err != nil {
+		t.Fatalf("JSON() got error: %v", err)
+	}
+	got, err := StatementFromJSON(b)
+	if err != nil {
+		t.Fatalf("StatementFromJSON() got error: %v", err)
+	}
+
+	finished := start.Add(time.Minute)
+	want := Statement{
+		Type:          StatementType,
+		Subject:       []ResourceDescriptor{},
+		PredicateType: ProvenancePredicateType,
+		Predicate: Provenance{
+			BuildDefinition: BuildDefinition{
+				BuildType: BuildType,
+				ExternalParameters: ExternalParameters{
+					Env: map[string]string{"GOOGLE_RUNTIME_VERSION": "18", "GOOGLE_NODE_RUN_SCRIPTS": "build"},
+				},
+				ResolvedDependencies: []ResourceDescriptor{
+					{Name: "nodejs", URI: "pkg:generic/nodejs@18.16.0"},
+					{Name: "google.nodejs.npm", URI: "urn:cnb:buildpack:google.nodejs.npm@1.1.0", Annotations: map[string]string{"type": "buildpack", "version": "1.1.0"}},
+					{Name: "google.nodejs.runtime", URI: "urn:cnb:buildpack:google.nodejs.runtime@1.0.0", Annotations: map[string]string{"type": "buildpack", "version": "1.0.0"}},
+				},
+			},
+			RunDetails: RunDetails{
+				Builder:  Builder{ID: BuilderID, Version: map[string]string{"google.nodejs.runtime": "1.0.0", "google.nodejs.npm": "1.1.0"}},
+				Metadata: BuildMetadata{StartedOn: &start, FinishedOn: &finished},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("AddBuildpackRun() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -18,11 +18,21 @@ package cache
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
 )
 
+// FormatVersion identifies the layout of a cached layer's contents.
+type FormatVersion string
+
 // Option is a function that returns strings to be hashed when computing a cache key.
 type Option func() ([]string, error)
 
@@ -50,6 +60,183 @@ func WithFiles(files ...string) Option {
 	}
 }
 
+// WithGlob returns a cache option that hashes the paths, relative to root, and the contents of the
+// files that match any of the given patterns, so that adding, renaming or changing a matching file
+// changes the key. A pattern without a "/" matches file names at any depth, e.g. "pom.xml" or
+// "*.gradle". A pattern with a "/" matches paths relative to root, e.g. ".mvn/extensions.xml" or
+// "/global.json". A pattern starting with "!" excludes the directories with a matching name, e.g.
+// "!target". Hidden directories and node_modules are never searched.
+func WithGlob(root string, patterns ...string) Option {
+	return func() ([]string, error) {
+		var names, paths, excluded []string
+		for _, p := range patterns {
+			switch {
+			case strings.HasPrefix(p, "!"):
+				excluded = append(excluded, strings.TrimPrefix(p, "!"))
+			case strings.Contains(p, "/"):
+				paths = append(paths, strings.TrimPrefix(p, "/"))
+			default:
+				names = append(names, p)
+			}
+		}
+		for _, p := range append(append(append([]string{}, names...), paths...), excluded...) {
+			if _, err := filepath.Match(p, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+			}
+		}
+
+		matches := map[string]bool{}
+		for _, p := range paths {
+			files, err := filepath.Glob(filepath.Join(root, filepath.FromSlash(p)))
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range files {
+				if info, err := os.Stat(f); err == nil && info.Mode().IsRegular() {
+					matches[f] = true
+				}
+			}
+		}
+		if len(names) > 0 {
+			err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if info.IsDir() {
+					if path != root && (strings.HasPrefix(info.Name(), ".") || info.Name() == "node_modules" || matchesAny(excluded, info.Name())) {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if info.Mode().IsRegular() && matchesAny(names, info.Name()) {
+					matches[path] = true
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		files := make([]string, 0, len(matches))
+		for f := range matches {
+			files = append(files, f)
+		}
+		sort.Strings(files)
+		var hashed []string
+		for _, f := range files {
+			digest, err := fileDigest(f)
+			if err != nil {
+				return nil, err
+			}
+			rel, err := filepath.Rel(root, f)
+			if err != nil {
+				return nil, err
+			}
+			hashed = append(hashed, fmt.Sprintf("%s:%s\n", filepath.ToSlash(rel), digest))
+		}
+		return hashed, nil
+	}
+}
+
+// WithDirTree returns a cache option that hashes the structure and the contents of a directory:
+// the paths of its files, directories and symbolic links, the targets of the links and the contents
+// of the files. Callers can detect if the directory did not exist by checking returned error values
+// against os.IsNotFound(...).
+func WithDirTree(dir string) Option {
+	return func() ([]string, error) {
+		var hashed []string
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || path == dir {
+				return err
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			switch {
+			case info.IsDir():
+				hashed = append(hashed, fmt.Sprintf("d %s\n", rel))
+			case info.Mode()&os.ModeSymlink != 0:
+				target, err := os.Readlink(path)
+				if err != nil {
+					return err
+				}
+				hashed = append(hashed, fmt.Sprintf("l %s -> %s\n", rel, target))
+			case info.Mode().IsRegular():
+				digest, err := fileDigest(path)
+				if err != nil {
+					return err
+				}
+				hashed = append(hashed, fmt.Sprintf("f %s:%s\n", rel, digest))
+			}
+			return nil
+		})
+		return hashed, err
+	}
+}
+
+// WithEnvVars returns a cache option that hashes the names and values of environment variables.
+// Unset and empty variables are hashed alike.
+func WithEnvVars(names ...string) Option {
+	return func() ([]string, error) {
+		var hashed []string
+		for _, n := range names {
+			hashed = append(hashed, fmt.Sprintf("%s=%s\n", n, os.Getenv(n)))
+		}
+		return hashed, nil
+	}
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := filepath.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// fileDigest returns the hex-encoded SHA-256 checksum of the contents of a file.
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// WithFormatVersion returns the format version of a cached layer. Buildpacks must bump the version
+// whenever they change how the layer is populated, so that layers cached in an incompatible layout
+// produce a cache miss even if the remaining inputs are unchanged.
+func WithFormatVersion(version string) FormatVersion {
+	return FormatVersion(version)
+}
+
+// CheckCache computes the cache key of a layer from its format version and the given cache options,
+// and reports whether it matches the key stored in the layer metadata under metadataKey. The
+// current key is returned so that callers can store it after repopulating the layer.
+func CheckCache(ctx *gcp.Context, l *libcnb.Layer, version FormatVersion, metadataKey string, opts ...Option) (bool, string, error) {
+	if version == "" {
+		return false, "", gcp.InternalErrorf("cache format version for layer %q must not be empty", l.Name)
+	}
+	opts = append([]Option{WithStrings("format-version:" + string(version))}, opts...)
+	key, err := Hash(ctx, opts...)
+	if err != nil {
+		return false, "", err
+	}
+	metaKey := ctx.GetMetadata(l, metadataKey)
+	ctx.Debugf("Current cache key for %s (format %s): %q", l.Name, version, key)
+	ctx.Debugf("  Stored cache key for %s: %q", l.Name, metaKey)
+	return key == metaKey, key, nil
+}
+
 // Hash creates a sha256 hash from the given cache options.
 func Hash(ctx *gcp.Context, opts ...Option) (result string, err error) {
 	h := sha256.New()
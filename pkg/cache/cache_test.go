@@ -15,9 +15,12 @@
 package cache
 
 import (
+	"crypto/sha256"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"sort"
 	"testing"
 
@@ -139,6 +142,192 @@ func TestWithFilesError(t *testing.T) {
 	}
 }
 
+func TestWithGlob(t *testing.T) {
+	root := t.TempDir()
+	for f, content := range map[string]string{
+		"pom.xml":                    "root",
+		"api/pom.xml":                "api",
+		"api/src/main/java/App.java": "class App {}",
+		"core/pom.xml":               "core",
+		"core/target/classes/META-INF/maven/pom.xml": "copy",
+		"web/node_modules/pom.xml":                   "dependency",
+		".git/pom.xml":                               "hidden",
+		".mvn/extensions.xml":                        "extensions",
+		"global.json":                                "{}",
+		"app/global.json":                            "{}",
+	} {
+		writeLayerFile(t, filepath.Join(root, f), content)
+	}
+	digest := func(content string) string {
+		return fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+	}
+	testCases := []struct {
+		name     string
+		patterns []string
+		want     []string
+	}{
+		{
+			name:     "file names at any depth",
+			patterns: []string{"pom.xml", "!target"},
+			want: []string{
+				"api/pom.xml:" + digest("api") + "\n",
+				"core/pom.xml:" + digest("core") + "\n",
+				"pom.xml:" + digest("root") + "\n",
+			},
+		},
+		{
+			name:     "paths relative to root",
+			patterns: []string{".mvn/extensions.xml", "/global.json"},
+			want: []string{
+				".mvn/extensions.xml:" + digest("extensions") + "\n",
+				"global.json:" + digest("{}") + "\n",
+			},
+		},
+		{
+			name:     "wildcards",
+			patterns: []string{"*.java", "*.csproj"},
+			want:     []string{"api/src/main/java/App.java:" + digest("class App {}") + "\n"},
+		},
+		{
+			name:     "no match",
+			patterns: []string{"build.gradle"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := WithGlob(root, tc.patterns...)()
+			if err != nil {
+				t.Fatalf("WithGlob(%v) got error: %v", tc.patterns, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("WithGlob(%v) = %q, want %q", tc.patterns, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWithGlobInvalidPattern(t *testing.T) {
+	if _, err := WithGlob(t.TempDir(), "[")(); err == nil {
+		t.Errorf("WithGlob(%q) got err=nil, want err", "[")
+	}
+}
+
+func TestWithDirTree(t *testing.T) {
+	dir := t.TempDir()
+	writeLayerFile(t, filepath.Join(dir, "src", "main.go"), "package main")
+	ctx := gcp.NewContext()
+	before := computeHash(t, ctx, WithDirTree(dir))
+
+	if got := computeHash(t, ctx, WithDirTree(dir)); got != before {
+		t.Errorf("Hash(WithDirTree()) of an unchanged directory = %q, want %q", got, before)
+	}
+	changes := []struct {
+		name   string
+		change func() error
+	}{
+		{
+			name: "changed content",
+			change: func() error {
+				return ioutil.WriteFile(filepath.Join(dir, "src", "main.go"), []byte("package lib"), 0644)
+			},
+		},
+		{
+			name: "renamed file",
+			change: func() error {
+				return os.Rename(filepath.Join(dir, "src", "main.go"), filepath.Join(dir, "src", "lib.go"))
+			},
+		},
+		{
+			name:   "new empty directory",
+			change: func() error { return os.Mkdir(filepath.Join(dir, "empty"), 0755) },
+		},
+		{
+			name:   "new symlink",
+			change: func() error { return os.Symlink("src/lib.go", filepath.Join(dir, "link.go")) },
+		},
+	}
+	previous := before
+	for _, c := range changes {
+		if err := c.change(); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		got := computeHash(t, ctx, WithDirTree(dir))
+		if got == previous {
+			t.Errorf("Hash(WithDirTree()) after %s = %q, want a different key", c.name, got)
+		}
+		previous = got
+	}
+}
+
+func TestWithDirTreeError(t *testing.T) {
+	_, err := Hash(gcp.NewContext(), WithDirTree("/does/not/exist"))
+	if !os.IsNotExist(err) {
+		t.Errorf("Hash(WithDirTree()) got err=%v, want %v", err, os.ErrNotExist)
+	}
+}
+
+func TestWithEnvVars(t *testing.T) {
+	t.Setenv("GOOGLE_BUILDABLE", "./cmd/app")
+	t.Setenv("GOOGLE_BUILD_ARGS", "")
+
+	got, err := WithEnvVars("GOOGLE_BUILDABLE", "GOOGLE_BUILD_ARGS")()
+	if err != nil {
+		t.Fatalf("WithEnvVars() got error: %v", err)
+	}
+	want := []string{"GOOGLE_BUILDABLE=./cmd/app\n", "GOOGLE_BUILD_ARGS=\n"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("WithEnvVars() = %q, want %q", got, want)
+	}
+
+	// The names are part of the key, the same value in different variables is not a hit.
+	ctx := gcp.NewContext()
+	t.Setenv("A", "value")
+	t.Setenv("B", "value")
+	if a, b := computeHash(t, ctx, WithEnvVars("A")), computeHash(t, ctx, WithEnvVars("B")); a == b {
+		t.Errorf("Hash(WithEnvVars(A)) = Hash(WithEnvVars(B)) = %q, want different keys", a)
+	}
+}
+
+func TestCheckCache(t *testing.T) {
+	ctx := gcp.NewContext(gcp.WithBuildpackInfo(libcnb.BuildpackInfo{ID: "id", Version: "version"}))
+	l := &libcnb.Layer{Name: "layer", Metadata: map[string]interface{}{}}
+
+	hit, v1Key, err := CheckCache(ctx, l, WithFormatVersion("v1"), "cache-key", WithStrings("my-string"))
+	if err != nil {
+		t.Fatalf("CheckCache(v1) got err=%v, want err=nil", err)
+	}
+	if hit {
+		t.Errorf("CheckCache(v1) on empty metadata got hit=true, want false")
+	}
+	ctx.SetMetadata(l, "cache-key", v1Key)
+
+	hit, got, err := CheckCache(ctx, l, WithFormatVersion("v1"), "cache-key", WithStrings("my-string"))
+	if err != nil {
+		t.Fatalf("CheckCache(v1) got err=%v, want err=nil", err)
+	}
+	if !hit || got != v1Key {
+		t.Errorf("CheckCache(v1) = %t, %q, want true, %q", hit, got, v1Key)
+	}
+
+	// Bumping the format version must miss even though the other cache inputs are unchanged.
+	hit, v2Key, err := CheckCache(ctx, l, WithFormatVersion("v2"), "cache-key", WithStrings("my-string"))
+	if err != nil {
+		t.Fatalf("CheckCache(v2) got err=%v, want err=nil", err)
+	}
+	if hit || v2Key == v1Key {
+		t.Errorf("CheckCache(v2) = %t, %q, want false and a key different from %q", hit, v2Key, v1Key)
+	}
+}
+
+func TestCheckCacheRequiresFormatVersion(t *testing.T) {
+	ctx := gcp.NewContext()
+	l := &libcnb.Layer{Name: "layer", Metadata: map[string]interface{}{}}
+
+	if _, _, err := CheckCache(ctx, l, WithFormatVersion(""), "cache-key"); err == nil {
+		t.Errorf("CheckCache() with empty format version got err=nil, want err")
+	}
+}
+
 func TestHash_SameFileContentsYieldsSameHash(t *testing.T) {
 	temp, err := ioutil.TempDir("", "test-sha-same-contents-")
 	if err != nil {
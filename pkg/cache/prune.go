@@ -0,0 +1,212 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+	"golang.org/x/sys/unix"
+)
+
+// Unit returns the key of the group of files that the file at the given path, relative to the
+// layer, belongs to. The files of a group are pruned together so that the cache never holds
+// partial entries, e.g. half of an unpacked package.
+type Unit func(rel string) string
+
+// ByFile prunes each file on its own, for caches of independent files such as archives.
+func ByFile(rel string) string {
+	return rel
+}
+
+// ByDirectory prunes the files of a directory together, e.g. the files of a version of an
+// artifact in a Maven repository.
+func ByDirectory(rel string) string {
+	return filepath.Dir(rel)
+}
+
+// ByPrefix prunes the files under the same directory at the given depth together.
+func ByPrefix(depth int) Unit {
+	return func(rel string) string {
+		parts := strings.Split(rel, string(filepath.Separator))
+		if len(parts) <= depth {
+			return filepath.Dir(rel)
+		}
+		return filepath.Join(parts[:depth]...)
+	}
+}
+
+var sizeSuffixes = []struct {
+	suffix string
+	factor int64
+}{
+	{"tib", 1 << 40}, {"tb", 1 << 40}, {"t", 1 << 40},
+	{"gib", 1 << 30}, {"gb", 1 << 30}, {"g", 1 << 30},
+	{"mib", 1 << 20}, {"mb", 1 << 20}, {"m", 1 << 20},
+	{"kib", 1 << 10}, {"kb", 1 << 10}, {"k", 1 << 10},
+	{"b", 1},
+}
+
+// MaxSize returns the size limit of cache layers set by GOOGLE_CACHE_MAX_SIZE, or 0 if there is
+// no limit.
+func MaxSize() (int64, error) {
+	value := strings.ToLower(strings.TrimSpace(os.Getenv(env.CacheMaxSize)))
+	if value == "" {
+		return 0, nil
+	}
+	factor := int64(1)
+	for _, s := range sizeSuffixes {
+		if strings.HasSuffix(value, s.suffix) {
+			value, factor = strings.TrimSpace(strings.TrimSuffix(value, s.suffix)), s.factor
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n <= 0 {
+		return 0, gcp.UserErrorf("invalid %s %q, must be a positive size such as 500MB or 2G", env.CacheMaxSize, os.Getenv(env.CacheMaxSize))
+	}
+	return int64(n * float64(factor)), nil
+}
+
+type pruneUnit struct {
+	files    []string
+	size     int64
+	lastUsed time.Time
+}
+
+// Prune deletes the least recently used entries of a cache layer, grouped by unit, until the
+// layer is within the size limit set by GOOGLE_CACHE_MAX_SIZE. Entries are ordered by the latest
+// access or modification time of their files. It returns the number of bytes deleted, a nil layer
+// is ignored.
+func Prune(ctx *gcp.Context, l *libcnb.Layer, unit Unit) (int64, error) {
+	maxSize, err := MaxSize()
+	if err != nil || maxSize == 0 || l == nil {
+		return 0, err
+	}
+
+	units := map[string]*pruneUnit{}
+	var total int64
+	err = filepath.Walk(l.Path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(l.Path, path)
+		if err != nil {
+			return err
+		}
+		key := unit(rel)
+		u, ok := units[key]
+		if !ok {
+			u = &pruneUnit{}
+			units[key] = u
+		}
+		u.files = append(u.files, path)
+		u.size += info.Size()
+		if t := lastUsed(path, info); t.After(u.lastUsed) {
+			u.lastUsed = t
+		}
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, gcp.InternalErrorf("walking layer %q: %v", l.Name, err)
+	}
+	if total <= maxSize {
+		ctx.Debugf("Cache layer %s uses %d of %d bytes.", l.Name, total, maxSize)
+		return 0, nil
+	}
+
+	keys := make([]string, 0, len(units))
+	for k := range units {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ui, uj := units[keys[i]], units[keys[j]]
+		if !ui.lastUsed.Equal(uj.lastUsed) {
+			return ui.lastUsed.Before(uj.lastUsed)
+		}
+		return keys[i] < keys[j]
+	})
+
+	var pruned int64
+	var count int
+	for _, k := range keys {
+		if total-pruned <= maxSize {
+			break
+		}
+		for _, f := range units[k].files {
+			if err := removeFile(f); err != nil {
+				return pruned, gcp.InternalErrorf("pruning cache layer %q: %v", l.Name, err)
+			}
+		}
+		pruned += units[k].size
+		count++
+	}
+	removeEmptyDirs(l.Path)
+	ctx.Logf("Pruned %d least recently used entries (%d bytes) from cache layer %s to fit in %s=%s.", count, pruned, l.Name, env.CacheMaxSize, os.Getenv(env.CacheMaxSize))
+	return pruned, nil
+}
+
+// lastUsed returns the latest of the access and modification times of a file. Access times are
+// not updated on file systems mounted with noatime, the modification time is used then.
+func lastUsed(path string, info os.FileInfo) time.Time {
+	t := info.ModTime()
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err == nil {
+		if at := time.Unix(st.Atim.Unix()); at.After(t) {
+			t = at
+		}
+	}
+	return t
+}
+
+// removeFile deletes a file, making its directory writable if needed because some tools create
+// read-only cache directories.
+func removeFile(path string) error {
+	err := os.Remove(path)
+	if !os.IsPermission(err) {
+		return err
+	}
+	if err := os.Chmod(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("making %s writable: %w", filepath.Dir(path), err)
+	}
+	return os.Remove(path)
+}
+
+// removeEmptyDirs deletes the empty directories under root, but not root itself.
+func removeEmptyDirs(root string) {
+	var dirs []string
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() && path != root {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	// Deepest first so that parents become empty before they are visited.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		os.Remove(dirs[i])
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestMaxSize(t *testing.T) {
+	testCases := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{value: "", want: 0},
+		{value: "1024", want: 1024},
+		{value: "10b", want: 10},
+		{value: "2k", want: 2 << 10},
+		{value: "500MB", want: 500 << 20},
+		{value: " 1.5 GiB ", want: 3 << 29},
+		{value: "1T", want: 1 << 40},
+		{value: "0", wantErr: true},
+		{value: "-5M", wantErr: true},
+		{value: "lots", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			t.Setenv(env.CacheMaxSize, tc.value)
+
+			got, err := MaxSize()
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("MaxSize() got error: %v, want error? %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("MaxSize() = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestByPrefix(t *testing.T) {
+	testCases := []struct {
+		rel  string
+		want string
+	}{
+		{rel: "a/b/c/d", want: "a/b"},
+		{rel: "a/b/c", want: "a/b"},
+		{rel: "a/b", want: "a"},
+		{rel: "a", want: "."},
+	}
+	for _, tc := range testCases {
+		if got := ByPrefix(2)(tc.rel); got != tc.want {
+			t.Errorf("ByPrefix(2)(%q) = %q, want %q", tc.rel, got, tc.want)
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	testCases := []struct {
+		name    string
+		maxSize string
+		unit    Unit
+		want    []string
+	}{
+		{
+			name: "no limit",
+			unit: ByFile,
+			want: []string{"a/old.bin", "a/older.bin", "b/new.bin", "b/newer.bin"},
+		},
+		{
+			name:    "within limit",
+			maxSize: "400b",
+			unit:    ByFile,
+			want:    []string{"a/old.bin", "a/older.bin", "b/new.bin", "b/newer.bin"},
+		},
+		{
+			name:    "by file",
+			maxSize: "250b",
+			unit:    ByFile,
+			want:    []string{"b/new.bin", "b/newer.bin"},
+		},
+		{
+			name:    "by directory",
+			maxSize: "350b",
+			unit:    ByDirectory,
+			want:    []string{"b/new.bin", "b/newer.bin"},
+		},
+		{
+			name:    "everything",
+			maxSize: "50b",
+			unit:    ByDirectory,
+			want:    nil,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(env.CacheMaxSize, tc.maxSize)
+			l := &libcnb.Layer{Name: "cache", Path: t.TempDir()}
+			now := time.Now()
+			writeUsedFile(t, filepath.Join(l.Path, "a", "older.bin"), now.Add(-4*time.Hour))
+			writeUsedFile(t, filepath.Join(l.Path, "a", "old.bin"), now.Add(-3*time.Hour))
+			writeUsedFile(t, filepath.Join(l.Path, "b", "new.bin"), now.Add(-2*time.Hour))
+			writeUsedFile(t, filepath.Join(l.Path, "b", "newer.bin"), now.Add(-1*time.Hour))
+
+			if _, err := Prune(gcp.NewContext(), l, tc.unit); err != nil {
+				t.Fatalf("Prune() got error: %v", err)
+			}
+
+			var got []string
+			filepath.Walk(l.Path, func(path string, info os.FileInfo, err error) error {
+				if err == nil && !info.IsDir() {
+					rel, _ := filepath.Rel(l.Path, path)
+					got = append(got, filepath.ToSlash(rel))
+				}
+				return nil
+			})
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("Prune() left files %v, want %v", got, tc.want)
+			}
+			if _, err := os.Stat(filepath.Join(l.Path, "a")); len(tc.want) < 4 && !os.IsNotExist(err) {
+				t.Errorf("Prune() left empty directory a, got stat error: %v", err)
+			}
+		})
+	}
+}
+
+func TestPruneNilLayer(t *testing.T) {
+	t.Setenv(env.CacheMaxSize, "1b")
+
+	if got, err := Prune(gcp.NewContext(), nil, ByFile); got != 0 || err != nil {
+		t.Errorf("Prune(nil) = %d, %v, want 0, nil", got, err)
+	}
+}
+
+// writeUsedFile writes a 100 byte file that was last accessed and modified at the given time.
+func writeUsedFile(t *testing.T, path string, used time.Time) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("creating %s: %v", filepath.Dir(path), err)
+	}
+	if err := ioutil.WriteFile(path, make([]byte, 100), 0644); err != nil {
+		t.Fatalf("writing %s: %v", path, err)
+	}
+	if err := os.Chtimes(path, used, used); err != nil {
+		t.Fatalf("setting times of %s: %v", path, err)
+	}
+}
@@ -0,0 +1,258 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/fetch"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+	"golang.org/x/oauth2/google"
+)
+
+const storageScope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+var (
+	// storageEndpoint is the Cloud Storage XML API endpoint, it is a var for testing.
+	storageEndpoint = "https://storage.googleapis.com"
+	// storageClient returns an HTTP client authorized with Application Default Credentials, it is a
+	// var for testing.
+	storageClient = func() (*http.Client, error) {
+		return google.DefaultClient(context.Background(), storageScope)
+	}
+)
+
+// remoteCache is a Cloud Storage location that holds layer archives.
+type remoteCache struct {
+	client *http.Client
+	bucket string
+	prefix string
+}
+
+// newRemoteCache returns the remote cache configured by GOOGLE_REMOTE_CACHE, or nil if there is
+// none or if credentials to access it are not available.
+func newRemoteCache(ctx *gcp.Context) (*remoteCache, error) {
+	value := strings.TrimSpace(os.Getenv(env.RemoteCache))
+	if value == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(value, "gs://") {
+		return nil, gcp.UserErrorf("invalid %s %q, must be a Cloud Storage URL such as gs://my-bucket/cache", env.RemoteCache, value)
+	}
+	parts := strings.SplitN(strings.TrimPrefix(value, "gs://"), "/", 2)
+	if parts[0] == "" {
+		return nil, gcp.UserErrorf("invalid %s %q, bucket name is missing", env.RemoteCache, value)
+	}
+	rc := &remoteCache{bucket: parts[0]}
+	if len(parts) == 2 {
+		rc.prefix = strings.Trim(parts[1], "/")
+	}
+	client, err := storageClient()
+	if err != nil {
+		// The build can proceed without the remote cache, e.g. when run locally.
+		ctx.Warnf("Remote cache %s is disabled, unable to find Application Default Credentials: %v", value, err)
+		return nil, nil
+	}
+	rc.client = client
+	return rc, nil
+}
+
+// object returns the name of the object that holds the archive of a layer with the given key.
+// Cache keys are computed from the buildpack ID and version, the ID is also part of the name so that
+// the objects of a buildpack are easy to find and delete.
+func (rc *remoteCache) object(ctx *gcp.Context, l *libcnb.Layer, key string) string {
+	return path.Join(rc.prefix, ctx.BuildpackID(), l.Name, key+".tar.gz")
+}
+
+func (rc *remoteCache) url(object string) string {
+	return fmt.Sprintf("%s/%s/%s", storageEndpoint, rc.bucket, (&url.URL{Path: object}).EscapedPath())
+}
+
+func (rc *remoteCache) do(method, object string, body io.Reader, size int64) (*http.Response, error) {
+	req, err := http.NewRequest(method, rc.url(object), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+		req.Header.Set("Content-Type", "application/gzip")
+	}
+	return rc.client.Do(req)
+}
+
+// RestoreRemote restores the content of a layer from the remote cache configured by
+// GOOGLE_REMOTE_CACHE, if any, and reports whether the archive for the given key was found. It is
+// meant to be called on a local cache miss, after the layer is cleared. Failures to reach the
+// remote cache are logged as warnings and reported as a miss.
+func RestoreRemote(ctx *gcp.Context, l *libcnb.Layer, key string) (bool, error) {
+	rc, err := newRemoteCache(ctx)
+	if err != nil || rc == nil || key == "" {
+		return false, err
+	}
+	object := rc.object(ctx, l, key)
+	resp, err := rc.do(http.MethodGet, object, nil, 0)
+	if err != nil {
+		ctx.Warnf("Failed to restore layer %s from remote cache: %v", l.Name, err)
+		return false, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		ctx.Debugf("Remote cache miss for layer %s: gs://%s/%s not found.", l.Name, rc.bucket, object)
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		ctx.Warnf("Failed to restore layer %s from remote cache: fetching gs://%s/%s returned HTTP status: %d", l.Name, rc.bucket, object, resp.StatusCode)
+		return false, nil
+	}
+
+	f, err := ioutil.TempFile("", "remote-cache-*.tar.gz")
+	if err != nil {
+		return false, gcp.InternalErrorf("creating temporary file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err = io.Copy(f, resp.Body); err == nil {
+		err = fetch.ExtractTarball(f.Name(), l.Path, 0)
+	}
+	if err != nil {
+		ctx.Warnf("Failed to restore layer %s from remote cache: %v", l.Name, err)
+		// Do not leave a partially restored layer behind.
+		if err := ctx.ClearLayer(l); err != nil {
+			return false, fmt.Errorf("clearing layer %q: %w", l.Name, err)
+		}
+		return false, nil
+	}
+	ctx.Logf("Restored layer %s from remote cache gs://%s/%s.", l.Name, rc.bucket, object)
+	return true, nil
+}
+
+// SaveRemote uploads the content of a layer to the remote cache configured by
+// GOOGLE_REMOTE_CACHE, if any, under the given key. Keys identify the content of the layer, so
+// the upload is skipped if an archive already exists for the key. Failures to reach the remote
+// cache are logged as warnings.
+func SaveRemote(ctx *gcp.Context, l *libcnb.Layer, key string) error {
+	rc, err := newRemoteCache(ctx)
+	if err != nil || rc == nil || key == "" {
+		return err
+	}
+	object := rc.object(ctx, l, key)
+	resp, err := rc.do(http.MethodHead, object, nil, 0)
+	if err != nil {
+		ctx.Warnf("Failed to save layer %s to remote cache: %v", l.Name, err)
+		return nil
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		ctx.Debugf("Layer %s is already in remote cache gs://%s/%s.", l.Name, rc.bucket, object)
+		return nil
+	}
+
+	f, err := ioutil.TempFile("", "remote-cache-*.tar.gz")
+	if err != nil {
+		return gcp.InternalErrorf("creating temporary file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if err := writeTarball(l.Path, f); err != nil {
+		return gcp.InternalErrorf("archiving layer %q: %v", l.Name, err)
+	}
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return gcp.InternalErrorf("seeking %s: %v", f.Name(), err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return gcp.InternalErrorf("seeking %s: %v", f.Name(), err)
+	}
+	resp, err = rc.do(http.MethodPut, object, f, size)
+	if err != nil {
+		ctx.Warnf("Failed to save layer %s to remote cache: %v", l.Name, err)
+		return nil
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		ctx.Warnf("Failed to save layer %s to remote cache: uploading gs://%s/%s returned HTTP status: %d", l.Name, rc.bucket, object, resp.StatusCode)
+		return nil
+	}
+	ctx.Logf("Saved layer %s (%d bytes) to remote cache gs://%s/%s.", l.Name, size, rc.bucket, object)
+	return nil
+}
+
+// writeTarball writes the content of a directory as a gzipped tarball. Directories are made
+// writable by their owner in the archive, otherwise their content could not be extracted from it,
+// e.g. the Go module cache is read-only.
+func writeTarball(dir string, w io.Writer) error {
+	gzw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gzw)
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || p == dir {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		} else if !info.Mode().IsRegular() && !info.IsDir() {
+			// Sockets, pipes and devices cannot be restored.
+			return nil
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			header.Name += "/"
+			header.Mode |= 0700
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gzw.Close()
+}
@@ -0,0 +1,203 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+// fakeStorage is an in-memory implementation of the Cloud Storage XML API object requests.
+type fakeStorage struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	puts    int
+}
+
+func (s *fakeStorage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		b, ok := s.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(b)
+	case http.MethodPut:
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		s.objects[r.URL.Path] = b
+		s.puts++
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func setUpFakeStorage(t *testing.T) *fakeStorage {
+	t.Helper()
+	s := &fakeStorage{objects: map[string][]byte{}}
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	origEndpoint, origClient := storageEndpoint, storageClient
+	storageEndpoint = server.URL
+	storageClient = func() (*http.Client, error) { return server.Client(), nil }
+	t.Cleanup(func() {
+		storageEndpoint, storageClient = origEndpoint, origClient
+	})
+	return s
+}
+
+func TestRemoteRoundTrip(t *testing.T) {
+	s := setUpFakeStorage(t)
+	t.Setenv(env.RemoteCache, "gs://my-bucket/some/prefix/")
+	ctx := gcp.NewContext(gcp.WithBuildpackInfo(libcnb.BuildpackInfo{ID: "google.nodejs.npm", Version: "1.0.0"}))
+
+	src := &libcnb.Layer{Name: "npm_modules", Path: t.TempDir()}
+	writeLayerFile(t, filepath.Join(src.Path, "node_modules", "express", "index.js"), "module.exports = {};")
+	if err := os.Symlink("../express/index.js", filepath.Join(src.Path, "node_modules", "express", "link.js")); err != nil {
+		t.Fatalf("creating symlink: %v", err)
+	}
+	readOnly := filepath.Join(src.Path, "pkg", "mod")
+	writeLayerFile(t, filepath.Join(readOnly, "go.mod"), "module example.com/m")
+	if err := os.Chmod(readOnly, 0555); err != nil {
+		t.Fatalf("making %s read-only: %v", readOnly, err)
+	}
+	t.Cleanup(func() { os.Chmod(readOnly, 0755) })
+
+	if err := SaveRemote(ctx, src, "abc123"); err != nil {
+		t.Fatalf("SaveRemote() got error: %v", err)
+	}
+	wantObject := "/my-bucket/some/prefix/google.nodejs.npm/npm_modules/abc123.tar.gz"
+	if _, ok := s.objects[wantObject]; !ok {
+		t.Fatalf("SaveRemote() did not upload %s, got objects %v", wantObject, s.objects)
+	}
+	// Archives are keyed by content, an existing archive is not uploaded again.
+	if err := SaveRemote(ctx, src, "abc123"); err != nil {
+		t.Fatalf("SaveRemote() got error: %v", err)
+	}
+	if s.puts != 1 {
+		t.Errorf("SaveRemote() uploaded %d times, want 1", s.puts)
+	}
+
+	dst := &libcnb.Layer{Name: "npm_modules", Path: t.TempDir()}
+	restored, err := RestoreRemote(ctx, dst, "abc123")
+	if err != nil {
+		t.Fatalf("RestoreRemote() got error: %v", err)
+	}
+	if !restored {
+		t.Fatalf("RestoreRemote() = false, want true")
+	}
+	for _, f := range []string{"node_modules/express/index.js", "node_modules/express/link.js", "pkg/mod/go.mod"} {
+		b, err := ioutil.ReadFile(filepath.Join(dst.Path, f))
+		if err != nil {
+			t.Errorf("reading restored file %s: %v", f, err)
+			continue
+		}
+		if strings.TrimSpace(string(b)) == "" {
+			t.Errorf("restored file %s is empty", f)
+		}
+	}
+	if target, err := os.Readlink(filepath.Join(dst.Path, "node_modules", "express", "link.js")); err != nil || target != "../express/index.js" {
+		t.Errorf("restored link.js -> %q, %v, want symlink to ../express/index.js", target, err)
+	}
+}
+
+func TestRestoreRemoteMiss(t *testing.T) {
+	setUpFakeStorage(t)
+	t.Setenv(env.RemoteCache, "gs://my-bucket")
+	l := &libcnb.Layer{Name: "gopath", Path: t.TempDir()}
+
+	restored, err := RestoreRemote(gcp.NewContext(), l, "missing")
+	if err != nil {
+		t.Fatalf("RestoreRemote() got error: %v", err)
+	}
+	if restored {
+		t.Errorf("RestoreRemote() = true, want false")
+	}
+}
+
+func TestRestoreRemoteCorruptArchive(t *testing.T) {
+	s := setUpFakeStorage(t)
+	t.Setenv(env.RemoteCache, "gs://my-bucket")
+	ctx := gcp.NewContext(gcp.WithBuildpackInfo(libcnb.BuildpackInfo{ID: "google.go.gomod"}))
+	s.objects["/my-bucket/google.go.gomod/gopath/key.tar.gz"] = []byte("not a tarball")
+	l, err := ctx.Layer("gopath")
+	if err != nil {
+		t.Fatalf("Layer() got error: %v", err)
+	}
+
+	restored, err := RestoreRemote(ctx, l, "key")
+	if err != nil {
+		t.Fatalf("RestoreRemote() got error: %v", err)
+	}
+	if restored {
+		t.Errorf("RestoreRemote() = true, want false")
+	}
+}
+
+func TestRemoteCacheNotConfigured(t *testing.T) {
+	s := setUpFakeStorage(t)
+	t.Setenv(env.RemoteCache, "")
+	l := &libcnb.Layer{Name: "gopath", Path: t.TempDir()}
+
+	if err := SaveRemote(gcp.NewContext(), l, "key"); err != nil {
+		t.Fatalf("SaveRemote() got error: %v", err)
+	}
+	if restored, err := RestoreRemote(gcp.NewContext(), l, "key"); restored || err != nil {
+		t.Errorf("RestoreRemote() = %v, %v, want false, nil", restored, err)
+	}
+	if len(s.objects) != 0 {
+		t.Errorf("SaveRemote() uploaded %v, want no objects", s.objects)
+	}
+}
+
+func TestRemoteCacheInvalidURL(t *testing.T) {
+	setUpFakeStorage(t)
+	l := &libcnb.Layer{Name: "gopath", Path: t.TempDir()}
+	for _, value := range []string{"my-bucket", "gs://", "https://storage.googleapis.com/my-bucket"} {
+		t.Run(value, func(t *testing.T) {
+			t.Setenv(env.RemoteCache, value)
+
+			if _, err := RestoreRemote(gcp.NewContext(), l, "key"); err == nil {
+				t.Errorf("RestoreRemote() got nil error, want error")
+			}
+		})
+	}
+}
+
+func writeLayerFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("creating %s: %v", filepath.Dir(path), err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("writing %s: %v", path, err)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudfunctions
+
+import (
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/Masterminds/semver"
+)
+
+const (
+	// FrameworkLatest selects the most recent release of the Functions Framework.
+	FrameworkLatest = "latest"
+	// FrameworkStable selects the version of the Functions Framework bundled with the buildpack.
+	FrameworkStable = "stable"
+)
+
+// exactVersionRegexp matches exact versions, e.g. 3.3.0 or 1.0.0-beta.1, but not ranges or
+// partial versions such as 3 or ^3.0.0.
+var exactVersionRegexp = regexp.MustCompile(`^\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?$`)
+
+// minFrameworkVersions are the oldest versions of the Functions Framework supported by the
+// functions-framework buildpack of each language, older versions lack the features that the
+// buildpacks configure, e.g. the signature type or the source file.
+var minFrameworkVersions = map[string]string{
+	"go":     "1.0.0",
+	"java":   "1.0.0",
+	"nodejs": "2.0.0",
+	"python": "2.0.0",
+}
+
+// FrameworkVersion returns the version of the Functions Framework requested with
+// GOOGLE_FUNCTIONS_FRAMEWORK_VERSION for the given language: an exact version without a "v" prefix,
+// FrameworkLatest or FrameworkStable, which is also returned if the variable is not set. Exact
+// versions older than the minimum supported version of the language are rejected.
+func FrameworkVersion(ctx *gcp.Context, language string) (string, error) {
+	value := strings.TrimSpace(os.Getenv(env.FunctionsFrameworkVersion))
+	switch strings.ToLower(value) {
+	case "", FrameworkStable:
+		return FrameworkStable, nil
+	case FrameworkLatest:
+		ctx.Logf("Using the latest version of the Functions Framework (%s=%s).", env.FunctionsFrameworkVersion, value)
+		return FrameworkLatest, nil
+	}
+	minVersion, ok := minFrameworkVersions[language]
+	if !ok {
+		return "", gcp.InternalErrorf("no minimum Functions Framework version for language %q", language)
+	}
+	version := strings.TrimPrefix(value, "v")
+	v, err := semver.NewVersion(version)
+	if err != nil || !exactVersionRegexp.MatchString(version) {
+		return "", gcp.UserErrorf("invalid %s %q, must be an exact version such as %s, %q or %q", env.FunctionsFrameworkVersion, value, minVersion, FrameworkLatest, FrameworkStable)
+	}
+	if v.LessThan(semver.MustParse(minVersion)) {
+		return "", gcp.UserErrorf("%s %q is not supported, the %s Functions Framework must be version %s or later", env.FunctionsFrameworkVersion, value, language, minVersion)
+	}
+	ctx.Logf("Using version %s of the Functions Framework (%s=%s).", version, env.FunctionsFrameworkVersion, value)
+	return version, nil
+}
+
+// WarnIfFrameworkVersionIgnored warns that GOOGLE_FUNCTIONS_FRAMEWORK_VERSION has no effect because
+// the function declares its own dependency on the Functions Framework, which is always used.
+func WarnIfFrameworkVersionIgnored(ctx *gcp.Context, dependencyFile string) {
+	if v := os.Getenv(env.FunctionsFrameworkVersion); v != "" {
+		ctx.Warnf("Ignoring %s=%s: the function depends on the Functions Framework in %s, update the version there instead.", env.FunctionsFrameworkVersion, v, dependencyFile)
+	}
+}
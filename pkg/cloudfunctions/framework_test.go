@@ -0,0 +1,117 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudfunctions
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestFrameworkVersion(t *testing.T) {
+	testCases := []struct {
+		name     string
+		language string
+		value    string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "default to stable",
+			language: "nodejs",
+			want:     FrameworkStable,
+		},
+		{
+			name:     "stable",
+			language: "python",
+			value:    "stable",
+			want:     FrameworkStable,
+		},
+		{
+			name:     "latest",
+			language: "go",
+			value:    " Latest ",
+			want:     FrameworkLatest,
+		},
+		{
+			name:     "exact version",
+			language: "nodejs",
+			value:    "3.3.0",
+			want:     "3.3.0",
+		},
+		{
+			name:     "v prefix",
+			language: "go",
+			value:    "v1.5.3",
+			want:     "1.5.3",
+		},
+		{
+			name:     "pre-release",
+			language: "java",
+			value:    "1.3.0-beta.1",
+			want:     "1.3.0-beta.1",
+		},
+		{
+			name:     "minimum version",
+			language: "python",
+			value:    "2.0.0",
+			want:     "2.0.0",
+		},
+		{
+			name:     "older than minimum version",
+			language: "python",
+			value:    "1.6.0",
+			wantErr:  true,
+		},
+		{
+			name:     "partial version",
+			language: "nodejs",
+			value:    "3",
+			wantErr:  true,
+		},
+		{
+			name:     "range",
+			language: "nodejs",
+			value:    "^3.0.0",
+			wantErr:  true,
+		},
+		{
+			name:     "unknown channel",
+			language: "java",
+			value:    "nightly",
+			wantErr:  true,
+		},
+		{
+			name:     "unknown language",
+			language: "cobol",
+			value:    "1.0.0",
+			wantErr:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(env.FunctionsFrameworkVersion, tc.value)
+
+			got, err := FrameworkVersion(gcp.NewContext(), tc.language)
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("FrameworkVersion(%q) got error: %v, want error? %v", tc.language, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("FrameworkVersion(%q) = %q, want %q", tc.language, got, tc.want)
+			}
+		})
+	}
+}
@@ -153,8 +153,16 @@ func installFileWatcher(ctx *gcp.Context) error {
 		// Download and install watchexec in layer.
 		ctx.Logf("Installing watchexec v%s", watchexecVersion)
 		archiveURL := fmt.Sprintf(watchexecURL, watchexecVersion)
-		command := fmt.Sprintf("curl --fail --show-error --silent --location --retry 3 %s | tar xJ --directory %s --strip-components=1 --wildcards \"*watchexec\"", archiveURL, binDir)
-		if _, err := ctx.Exec([]string{"bash", "-c", command}, gcp.WithUserAttribution); err != nil {
+		tmpDir, err := ctx.TempDir("watchexec")
+		if err != nil {
+			return err
+		}
+		defer ctx.RemoveAll(tmpDir)
+		archive := filepath.Join(tmpDir, "watchexec.tar.xz")
+		if err := ctx.Download(archiveURL, archive); err != nil {
+			return err
+		}
+		if _, err := ctx.Exec([]string{"tar", "xJf", archive, "--directory", binDir, "--strip-components=1", "--wildcards", "*watchexec"}, gcp.WithUserAttribution); err != nil {
 			return err
 		}
 		ctx.SetMetadata(wxl, versionKey, watchexecVersion)
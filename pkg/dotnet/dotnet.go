@@ -38,8 +38,29 @@ const (
 	PublishLayerName = "publish"
 	// PublishOutputDirName is passed as the output directory for `dotnet publish`.
 	PublishOutputDirName = "bin"
+	// EnvPublishAOT is the environment variable that publishes the application as a native executable
+	// with Native AOT, which starts faster and does not need the .NET runtime.
+	EnvPublishAOT = "GOOGLE_DOTNET_PUBLISH_AOT"
 )
 
+// AOTRuntimeIdentifier returns the runtime identifier of the native executables built for the
+// architecture of the image, e.g. "linux-x64" or "linux-arm64".
+func AOTRuntimeIdentifier(ctx *gcp.Context) string {
+	if arch := ctx.Arch(); arch != gcp.ArchAMD64 {
+		return "linux-" + arch
+	}
+	return "linux-x64"
+}
+
+// AOTToolchainPackages are the packages needed by the Native AOT compiler to link executables, see
+// https://learn.microsoft.com/en-us/dotnet/core/deploying/native-aot/#prerequisites.
+var AOTToolchainPackages = []string{"clang", "zlib1g-dev"}
+
+// PublishAOT returns true if the application must be published with Native AOT.
+func PublishAOT() (bool, error) {
+	return env.IsPresentAndTrue(EnvPublishAOT)
+}
+
 // ProjectFiles finds all project files supported by dotnet.
 func ProjectFiles(ctx *gcp.Context, dir string) ([]string, error) {
 	result, err := ctx.Exec([]string{"find", dir, "-regex", `.*\.\(cs\|fs\|vb\)proj`}, gcp.WithUserTimingAttribution)
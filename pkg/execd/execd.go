@@ -0,0 +1,105 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package execd provides helpers to implement exec.d executables, which the launcher runs before
+// the process starts to set environment variables that can only be computed at launch time, see
+// https://github.com/buildpacks/spec/blob/main/buildpack.md#execd.
+package execd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+const (
+	// outputFD is the file descriptor to which exec.d executables write environment variables.
+	outputFD = 3
+
+	// cgroupRootEnv overrides the root of the cgroup filesystem, it is used for testing.
+	cgroupRootEnv = "X_GOOGLE_CGROUP_ROOT"
+	// unlimitedMemory is the memory limit from which the container is considered unlimited: cgroup
+	// v1 reports a value close to 2^63 when there is no limit.
+	unlimitedMemory = 1 << 50
+)
+
+// EnvFn returns the environment variables to set at launch, a nil map leaves the environment
+// unchanged.
+type EnvFn func() (map[string]string, error)
+
+// Main runs fn and writes the environment variables that it returns to the launcher. An error fails
+// the launch of the process, so fn should only return errors that make the process unusable.
+func Main(fn EnvFn) {
+	out := os.NewFile(outputFD, "exec.d output")
+	if err := run(fn, out); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", filepath.Base(os.Args[0]), err)
+		os.Exit(1)
+	}
+}
+
+func run(fn EnvFn, w io.Writer) error {
+	vars, err := fn()
+	if err != nil {
+		return err
+	}
+	return Write(w, vars)
+}
+
+// Write writes the environment variables to w in the TOML format expected by the launcher.
+func Write(w io.Writer, vars map[string]string) error {
+	if len(vars) == 0 {
+		return nil
+	}
+	if err := toml.NewEncoder(w).Encode(vars); err != nil {
+		return fmt.Errorf("encoding environment variables: %v", err)
+	}
+	return nil
+}
+
+// Append returns the current value of the environment variable with value appended, separated by
+// delim. exec.d executables override variables, so values set by the user must be preserved.
+func Append(name, delim, value string) string {
+	if current := os.Getenv(name); current != "" {
+		return current + delim + value
+	}
+	return value
+}
+
+// MemoryLimit returns the memory limit of the container in bytes read from its cgroup, v2 or v1,
+// and false if the container has no memory limit or it cannot be read.
+func MemoryLimit() (int64, bool) {
+	root := os.Getenv(cgroupRootEnv)
+	if root == "" {
+		root = "/sys/fs/cgroup"
+	}
+	for _, file := range []string{"memory.max", filepath.Join("memory", "memory.limit_in_bytes")} {
+		content, err := ioutil.ReadFile(filepath.Join(root, file))
+		if err != nil {
+			continue
+		}
+		// cgroup v2 reports "max" when there is no limit.
+		limit, err := strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+		if err != nil || limit <= 0 || limit >= unlimitedMemory {
+			return 0, false
+		}
+		return limit, true
+	}
+	return 0, false
+}
@@ -0,0 +1,151 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package execd
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	testCases := []struct {
+		name    string
+		vars    map[string]string
+		err     error
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "sorted variables",
+			vars: map[string]string{"NODE_OPTIONS": "--max-old-space-size=448", "APP_MEMORY_MB": "512"},
+			want: "APP_MEMORY_MB = \"512\"\nNODE_OPTIONS = \"--max-old-space-size=448\"\n",
+		},
+		{
+			name: "escaped values",
+			vars: map[string]string{"JAVA_TOOL_OPTIONS": `-Dname="a\b"`},
+			want: "JAVA_TOOL_OPTIONS = \"-Dname=\\\"a\\\\b\\\"\"\n",
+		},
+		{
+			name: "no variables",
+		},
+		{
+			name:    "error",
+			err:     errors.New("reading memory limit"),
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := run(func() (map[string]string, error) { return tc.vars, tc.err }, &out)
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("run() got error: %v, want error? %v", err, tc.wantErr)
+			}
+			if got := out.String(); got != tc.want {
+				t.Errorf("run() wrote %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAppend(t *testing.T) {
+	testCases := []struct {
+		name    string
+		current string
+		want    string
+	}{
+		{
+			name: "unset",
+			want: "-Xss1m",
+		},
+		{
+			name:    "set",
+			current: "-Dfoo=bar",
+			want:    "-Dfoo=bar -Xss1m",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("JAVA_TOOL_OPTIONS", tc.current)
+
+			if got := Append("JAVA_TOOL_OPTIONS", " ", "-Xss1m"); got != tc.want {
+				t.Errorf("Append() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	testCases := []struct {
+		name      string
+		files     map[string]string
+		want      int64
+		wantLimit bool
+	}{
+		{
+			name:      "cgroup v2 limit",
+			files:     map[string]string{"memory.max": "536870912\n"},
+			want:      536870912,
+			wantLimit: true,
+		},
+		{
+			name:      "cgroup v1 limit",
+			files:     map[string]string{"memory/memory.limit_in_bytes": "1073741824\n"},
+			want:      1073741824,
+			wantLimit: true,
+		},
+		{
+			name:  "cgroup v2 without limit",
+			files: map[string]string{"memory.max": "max\n"},
+		},
+		{
+			name:  "cgroup v1 without limit",
+			files: map[string]string{"memory/memory.limit_in_bytes": "9223372036854771712\n"},
+		},
+		{
+			name: "no cgroup",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(cgroupRootEnv, writeCgroup(t, tc.files))
+
+			got, ok := MemoryLimit()
+			if got != tc.want || ok != tc.wantLimit {
+				t.Errorf("MemoryLimit() = %d, %v, want %d, %v", got, ok, tc.want, tc.wantLimit)
+			}
+		})
+	}
+}
+
+// writeCgroup writes the given files to a temporary cgroup filesystem and returns its root.
+func writeCgroup(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("creating %s: %v", filepath.Dir(path), err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("writing %s: %v", path, err)
+		}
+	}
+	return root
+}
@@ -18,7 +18,10 @@ package fetch
 import (
 	"archive/tar"
 	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -30,8 +33,13 @@ import (
 	"github.com/hashicorp/go-retryablehttp"
 )
 
-// gcpUserAgent is required for the Ruby runtime, but used for others for simplicity.
-const gcpUserAgent = "GCPBuildpacks"
+const (
+	// gcpUserAgent is required for the Ruby runtime, but used for others for simplicity.
+	gcpUserAgent = "GCPBuildpacks"
+	// downloadAttempts is the number of times a download is attempted if the connection fails
+	// while the response body is read. Failed requests are retried by the HTTP client.
+	downloadAttempts = 3
+)
 
 // Tarball downloads a tarball from a URL and extracts it into the provided directory.
 func Tarball(url, dir string, stripComponents int) error {
@@ -43,6 +51,56 @@ func Tarball(url, dir string, stripComponents int) error {
 	return untar(dir, response.Body, stripComponents)
 }
 
+// TarballWithChecksum downloads a tarball from a URL, verifies that its hex-encoded SHA-256
+// checksum matches the given one and extracts it into the provided directory.
+func TarballWithChecksum(url, dir string, stripComponents int, checksum string) error {
+	f, err := ioutil.TempFile("", "download-*.tar.gz")
+	if err != nil {
+		return gcp.InternalErrorf("creating temporary file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if err := download(url, f, checksum); err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return gcp.InternalErrorf("seeking %s: %v", f.Name(), err)
+	}
+	return untar(dir, f, stripComponents)
+}
+
+// ExtractTarball extracts a gzipped tarball from a file into the provided directory.
+func ExtractTarball(path, dir string, stripComponents int) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return gcp.InternalErrorf("opening %s: %v", path, err)
+	}
+	defer f.Close()
+	return untar(dir, f, stripComponents)
+}
+
+// File downloads the content of a URL to the given path. If checksum is not empty, the download
+// fails and the path is not created unless the hex-encoded SHA-256 checksum of the content
+// matches it.
+func File(url, path, checksum string) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return gcp.InternalErrorf("creating temporary file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if err := download(url, f, checksum); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return gcp.InternalErrorf("closing %s: %v", f.Name(), err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return gcp.InternalErrorf("renaming %s to %s: %v", f.Name(), path, err)
+	}
+	return nil
+}
+
 // JSON fetches a JSON payload from a URL and unmarshalls it into the value pointed to by v.
 func JSON(url string, v interface{}) error {
 	response, err := doGet(url)
@@ -75,6 +133,76 @@ func GetURL(url string, f io.Writer) error {
 	return nil
 }
 
+// download writes the content of a URL to f and verifies its SHA-256 checksum if it is not empty.
+// If the connection fails while the content is read, the download is resumed from where it
+// stopped, or restarted if the server does not support range requests.
+func download(url string, f *os.File, checksum string) error {
+	if err := f.Truncate(0); err != nil {
+		return gcp.InternalErrorf("truncating %s: %v", f.Name(), err)
+	}
+	var err error
+	for attempt := 1; attempt <= downloadAttempts; attempt++ {
+		if err = downloadAttempt(url, f); err == nil {
+			break
+		}
+		if _, ok := err.(*readError); !ok {
+			return err
+		}
+	}
+	if err != nil {
+		return gcp.UserErrorf("downloading %s: %v", url, err)
+	}
+	if checksum == "" {
+		return nil
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return gcp.InternalErrorf("seeking %s: %v", f.Name(), err)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return gcp.InternalErrorf("reading %s: %v", f.Name(), err)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(sum, checksum) {
+		return gcp.UserErrorf("verifying %s: got SHA-256 checksum %s, want %s", url, sum, checksum)
+	}
+	return nil
+}
+
+// readError is returned by downloadAttempt if the response body could not be copied to the file.
+type readError struct {
+	err error
+}
+
+func (e *readError) Error() string {
+	return e.err.Error()
+}
+
+// downloadAttempt appends the content of a URL to f, starting at the current size of f.
+func downloadAttempt(url string, f *os.File) error {
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return gcp.InternalErrorf("seeking %s: %v", f.Name(), err)
+	}
+	response, err := doGetFrom(url, offset)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if offset > 0 && response.StatusCode != http.StatusPartialContent {
+		// The server sent the whole content.
+		if err := f.Truncate(0); err != nil {
+			return gcp.InternalErrorf("truncating %s: %v", f.Name(), err)
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return gcp.InternalErrorf("seeking %s: %v", f.Name(), err)
+		}
+	}
+	if _, err := io.Copy(f, response.Body); err != nil {
+		return &readError{err: err}
+	}
+	return nil
+}
+
 // untar extracts a tarball from a reader and writes it to the given directory.
 func untar(dir string, r io.Reader, stripComponents int) error {
 	gzr, err := gzip.NewReader(r)
@@ -181,6 +309,12 @@ func isValidTarDestination(dest, rootDir string, tarType byte) bool {
 
 // doGet performs an HTTP GET request for a URL.
 func doGet(url string) (*http.Response, error) {
+	return doGetFrom(url, 0)
+}
+
+// doGetFrom performs an HTTP GET request for the content of a URL starting at the given byte
+// offset. Servers that do not support range requests respond with the whole content.
+func doGetFrom(url string, offset int64) (*http.Response, error) {
 	retryClient := retryablehttp.NewClient()
 	retryClient.RetryMax = 3
 	req, err := http.NewRequest("GET", url, nil)
@@ -189,6 +323,9 @@ func doGet(url string) (*http.Response, error) {
 	}
 
 	req.Header.Set("User-Agent", gcpUserAgent)
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
 
 	response, err := retryClient.StandardClient().Do(req)
 	if err != nil {
@@ -17,10 +17,15 @@ package fetch
 import (
 	"bytes"
 	"io"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/GoogleCloudPlatform/buildpacks/internal/testserver"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/testdata"
@@ -172,3 +177,117 @@ func TestGetURL(t *testing.T) {
 		})
 	}
 }
+
+func TestFile(t *testing.T) {
+	// echo -n "foo, bar" | sha256sum
+	const checksum = "c6250c6a27394fbb4618655b01a2b46e7f0e5785a814404ec9490a4ea2ea5ca8"
+	testCases := []struct {
+		name       string
+		httpStatus int
+		checksum   string
+		wantError  bool
+	}{
+		{
+			name: "no checksum",
+		},
+		{
+			name:     "matching checksum",
+			checksum: checksum,
+		},
+		{
+			name:     "checksum is case insensitive",
+			checksum: strings.ToUpper(checksum),
+		},
+		{
+			name:      "checksum mismatch",
+			checksum:  strings.Repeat("0", 64),
+			wantError: true,
+		},
+		{
+			name:       "not found",
+			httpStatus: http.StatusNotFound,
+			wantError:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := testserver.New(
+				t,
+				testserver.WithStatus(tc.httpStatus),
+				testserver.WithJSON("foo, bar"))
+			path := filepath.Join(t.TempDir(), "file")
+
+			err := File(server.URL, path, tc.checksum)
+			if tc.wantError == (err == nil) {
+				t.Fatalf("File(%q, %q, %q) got error: %v, want error? %v", server.URL, path, tc.checksum, err, tc.wantError)
+			}
+			got, readErr := ioutil.ReadFile(path)
+			if tc.wantError {
+				if readErr == nil {
+					t.Errorf("File(%q, %q, %q) created %s, want it not to exist", server.URL, path, tc.checksum, path)
+				}
+				return
+			}
+			if readErr != nil {
+				t.Fatalf("reading %s: %v", path, readErr)
+			}
+			if string(got) != "foo, bar" {
+				t.Errorf("File(%q, %q, %q) wrote %q, want %q", server.URL, path, tc.checksum, got, "foo, bar")
+			}
+		})
+	}
+}
+
+func TestFileResumesInterruptedDownload(t *testing.T) {
+	const content = "foo, bar"
+	testCases := []struct {
+		name         string
+		acceptRanges bool
+		wantRanges   []string
+	}{
+		{
+			name:         "range requests",
+			acceptRanges: true,
+			wantRanges:   []string{"", "bytes=3-"},
+		},
+		{
+			name:       "no range requests",
+			wantRanges: []string{"", "bytes=3-"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var ranges []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ranges = append(ranges, r.Header.Get("Range"))
+				if len(ranges) == 1 {
+					// The connection is closed before the whole body is written.
+					w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+					w.Write([]byte(content[:3]))
+					return
+				}
+				if tc.acceptRanges {
+					http.ServeContent(w, r, "", time.Time{}, strings.NewReader(content))
+					return
+				}
+				w.Write([]byte(content))
+			}))
+			t.Cleanup(server.Close)
+			path := filepath.Join(t.TempDir(), "file")
+			// echo -n "foo, bar" | sha256sum
+			checksum := "c6250c6a27394fbb4618655b01a2b46e7f0e5785a814404ec9490a4ea2ea5ca8"
+
+			if err := File(server.URL, path, checksum); err != nil {
+				t.Fatalf("File(%q, %q, %q) got error: %v", server.URL, path, checksum, err)
+			}
+			if got, err := ioutil.ReadFile(path); err != nil || string(got) != content {
+				t.Errorf("File(%q, %q, %q) wrote %q (%v), want %q", server.URL, path, checksum, got, err, content)
+			}
+			if diff := cmp.Diff(tc.wantRanges, ranges); diff != "" {
+				t.Errorf("File(%q, %q, %q) requested ranges mismatch (-want +got):\n%s", server.URL, path, checksum, diff)
+			}
+		})
+	}
+}
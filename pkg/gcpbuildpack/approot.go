@@ -0,0 +1,138 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+)
+
+// SourceRootMarker is the path, relative to the workspace, of a file containing the subdirectory
+// of the workspace to build. Platforms write it when the deployable root of a source context is
+// not the root of the repository.
+const SourceRootMarker = ".googlebuild/source-root"
+
+// resolveApplicationRoot returns the directory to build: the subdirectory from
+// GOOGLE_APPLICATION_DIR or GOOGLE_SUBDIRECTORY, else the subdirectory named in the source root
+// marker file, else the workspace itself.
+func resolveApplicationRoot(workspace string) (string, error) {
+	appDir, appDirOK := os.LookupEnv(env.ApplicationDir)
+	subdir, subdirOK := os.LookupEnv(env.Subdirectory)
+	switch {
+	case appDirOK && subdirOK:
+		root, err := normalizeSubdirectory(workspace, appDir, env.ApplicationDir)
+		if err != nil {
+			return "", err
+		}
+		if other, err := normalizeSubdirectory(workspace, subdir, env.Subdirectory); err != nil || other != root {
+			return "", UserErrorf("%s=%q and %s=%q name different directories, set only %s", env.ApplicationDir, appDir, env.Subdirectory, subdir, env.ApplicationDir)
+		}
+		return root, nil
+	case appDirOK:
+		return normalizeSubdirectory(workspace, appDir, env.ApplicationDir)
+	case subdirOK:
+		return normalizeSubdirectory(workspace, subdir, env.Subdirectory)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(workspace, SourceRootMarker))
+	if os.IsNotExist(err) {
+		return workspace, nil
+	}
+	if err != nil {
+		return "", InternalErrorf("reading %s: %v", SourceRootMarker, err)
+	}
+	return normalizeSubdirectory(workspace, string(content), SourceRootMarker)
+}
+
+// normalizeSubdirectory validates that dir, as provided by source, is an existing directory inside
+// the workspace and returns its absolute path.
+func normalizeSubdirectory(workspace, dir, source string) (string, error) {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		return "", UserErrorf("%s is empty; it must name a directory relative to the source root", source)
+	}
+	if filepath.IsAbs(dir) {
+		return "", UserErrorf("%s=%q must be relative to the source root", source, dir)
+	}
+	clean := filepath.Clean(dir)
+	if clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", UserErrorf("%s=%q must not point outside of the source root", source, dir)
+	}
+	root := filepath.Join(workspace, clean)
+	fi, err := os.Stat(root)
+	if os.IsNotExist(err) {
+		return "", UserErrorf("%s=%q does not exist in the source", source, dir)
+	}
+	if err != nil {
+		return "", InternalErrorf("checking %s=%q: %v", source, dir, err)
+	}
+	if !fi.IsDir() {
+		return "", UserErrorf("%s=%q is not a directory", source, dir)
+	}
+
+	// Symlinks must not lead outside of the workspace either.
+	realWorkspace, err := filepath.EvalSymlinks(workspace)
+	if err != nil {
+		return "", InternalErrorf("resolving %s: %v", workspace, err)
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", InternalErrorf("resolving %s=%q: %v", source, dir, err)
+	}
+	if rel, err := filepath.Rel(realWorkspace, realRoot); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", UserErrorf("%s=%q must not point outside of the source root", source, dir)
+	}
+	return root, nil
+}
+
+// useEffectiveApplicationRoot resolves the directory to build and makes it the application root
+// and the working directory, so that every buildpack sees the same root.
+func (ctx *Context) useEffectiveApplicationRoot() error {
+	if ctx.workspaceRoot == "" {
+		return nil
+	}
+	root, err := resolveApplicationRoot(ctx.workspaceRoot)
+	if err != nil {
+		return err
+	}
+	if root == ctx.workspaceRoot {
+		return nil
+	}
+	ctx.Debugf("Using application root %s", root)
+	if err := os.Chdir(root); err != nil {
+		return InternalErrorf("changing directory to %s: %v", root, err)
+	}
+	ctx.applicationRoot = root
+	return nil
+}
+
+// wrapInApplicationRoot makes the process run in the application root when it differs from the
+// workspace, which is the working directory of processes at launch.
+func (ctx *Context) wrapInApplicationRoot(p *libcnb.Process) {
+	if ctx.workspaceRoot == "" || ctx.applicationRoot == ctx.workspaceRoot {
+		return
+	}
+	if p.Direct {
+		p.Arguments = append([]string{"-c", `cd "$0" && exec "$@"`, ctx.applicationRoot, p.Command}, p.Arguments...)
+		p.Command = "/bin/bash"
+		return
+	}
+	p.Command = fmt.Sprintf("cd %q && %s", ctx.applicationRoot, p.Command)
+}
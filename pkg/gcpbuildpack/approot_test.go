@@ -0,0 +1,248 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestResolveApplicationRoot(t *testing.T) {
+	testCases := []struct {
+		name   string
+		env    map[string]string
+		marker string
+		want   string
+	}{
+		{
+			name: "workspace root by default",
+			want: ".",
+		},
+		{
+			name: "env var",
+			env:  map[string]string{env.Subdirectory: "services/api"},
+			want: "services/api",
+		},
+		{
+			name:   "marker file",
+			marker: "services/web\n",
+			want:   "services/web",
+		},
+		{
+			name:   "env var takes precedence over marker file",
+			env:    map[string]string{env.Subdirectory: "services/api"},
+			marker: "services/web",
+			want:   "services/api",
+		},
+		{
+			name: "application dir",
+			env:  map[string]string{env.ApplicationDir: "services/api"},
+			want: "services/api",
+		},
+		{
+			name:   "application dir takes precedence over marker file",
+			env:    map[string]string{env.ApplicationDir: "services/api"},
+			marker: "services/web",
+			want:   "services/api",
+		},
+		{
+			name: "application dir and subdirectory agree",
+			env:  map[string]string{env.ApplicationDir: "services/api", env.Subdirectory: "./services/api/"},
+			want: "services/api",
+		},
+		{
+			name: "normalized",
+			env:  map[string]string{env.Subdirectory: "./services//api/"},
+			want: "services/api",
+		},
+		{
+			name: "dot is the workspace",
+			env:  map[string]string{env.Subdirectory: "."},
+			want: ".",
+		},
+		{
+			name: "dot dot inside the workspace",
+			env:  map[string]string{env.Subdirectory: "services/web/../api"},
+			want: "services/api",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			workspace := setUpMonorepo(t, tc.marker)
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+
+			got, err := resolveApplicationRoot(workspace)
+			if err != nil {
+				t.Fatalf("resolveApplicationRoot() got error: %v", err)
+			}
+			if want := filepath.Join(workspace, tc.want); got != want {
+				t.Errorf("resolveApplicationRoot() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestResolveApplicationRootInvalid(t *testing.T) {
+	testCases := []struct {
+		name    string
+		env     map[string]string
+		marker  string
+		wantErr string
+	}{
+		{
+			name:    "empty env var",
+			env:     map[string]string{env.Subdirectory: " "},
+			wantErr: "GOOGLE_SUBDIRECTORY is empty",
+		},
+		{
+			name:    "empty application dir",
+			env:     map[string]string{env.ApplicationDir: ""},
+			wantErr: "GOOGLE_APPLICATION_DIR is empty",
+		},
+		{
+			name:    "application dir and subdirectory conflict",
+			env:     map[string]string{env.ApplicationDir: "services/api", env.Subdirectory: "services/web"},
+			wantErr: "name different directories",
+		},
+		{
+			name:    "empty marker file",
+			marker:  "\n",
+			wantErr: ".googlebuild/source-root is empty",
+		},
+		{
+			name:    "absolute",
+			env:     map[string]string{env.Subdirectory: "/services/api"},
+			wantErr: "must be relative",
+		},
+		{
+			name:    "outside of the workspace",
+			env:     map[string]string{env.Subdirectory: "../other"},
+			wantErr: "must not point outside",
+		},
+		{
+			name:    "outside of the workspace in marker file",
+			marker:  "services/../../other",
+			wantErr: "must not point outside",
+		},
+		{
+			name:    "symlink outside of the workspace",
+			env:     map[string]string{env.Subdirectory: "escape"},
+			wantErr: "must not point outside",
+		},
+		{
+			name:    "missing",
+			env:     map[string]string{env.Subdirectory: "services/missing"},
+			wantErr: "does not exist",
+		},
+		{
+			name:    "file",
+			env:     map[string]string{env.Subdirectory: "services/api/package.json"},
+			wantErr: "is not a directory",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			workspace := setUpMonorepo(t, tc.marker)
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+
+			_, err := resolveApplicationRoot(workspace)
+			if err == nil {
+				t.Fatalf("resolveApplicationRoot() got no error, want error containing %q", tc.wantErr)
+			}
+			if !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("resolveApplicationRoot() got error %q, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestAddProcessInApplicationRoot(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts []processOption
+		want libcnb.Process
+	}{
+		{
+			name: "direct",
+			opts: []processOption{AsDirectProcess()},
+			want: libcnb.Process{
+				Type:      "web",
+				Command:   "/bin/bash",
+				Arguments: []string{"-c", `cd "$0" && exec "$@"`, "/workspace/services/api", "node", "server.js"},
+				Direct:    true,
+			},
+		},
+		{
+			name: "shell",
+			want: libcnb.Process{
+				Type:      "web",
+				Command:   `cd "/workspace/services/api" && node`,
+				Arguments: []string{"server.js"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := NewContext()
+			ctx.workspaceRoot = "/workspace"
+			ctx.applicationRoot = "/workspace/services/api"
+
+			ctx.AddProcess("web", []string{"node", "server.js"}, tc.opts...)
+
+			if diff := cmp.Diff([]libcnb.Process{tc.want}, ctx.buildResult.Processes); diff != "" {
+				t.Errorf("AddProcess() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// setUpMonorepo creates a workspace with two applications under services/, a symlink that points
+// outside of the workspace and, if marker is not empty, a source root marker file.
+func setUpMonorepo(t *testing.T, marker string) string {
+	t.Helper()
+	tmp := t.TempDir()
+	workspace := filepath.Join(tmp, "workspace")
+	for _, dir := range []string{"services/api", "services/web", "../other"} {
+		if err := os.MkdirAll(filepath.Join(workspace, dir), 0755); err != nil {
+			t.Fatalf("creating %s: %v", dir, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(workspace, "services/api/package.json"), []byte("{}"), 0644); err != nil {
+		t.Fatalf("writing package.json: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(tmp, "other"), filepath.Join(workspace, "escape")); err != nil {
+		t.Fatalf("creating symlink: %v", err)
+	}
+	if marker != "" {
+		path := filepath.Join(workspace, SourceRootMarker)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("creating %s: %v", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, []byte(marker), 0644); err != nil {
+			t.Fatalf("writing %s: %v", path, err)
+		}
+	}
+	return workspace
+}
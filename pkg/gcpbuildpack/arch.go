@@ -0,0 +1,63 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"os"
+	"runtime"
+)
+
+const (
+	// ArchAMD64 is the x86-64 architecture.
+	ArchAMD64 = "amd64"
+	// ArchARM64 is the 64-bit ARM architecture, e.g. of T2A VMs, GKE Arm nodes and Apple Silicon.
+	ArchARM64 = "arm64"
+
+	// targetArchEnv is set by the lifecycle to the architecture of the run image since Platform
+	// API 0.12.
+	targetArchEnv = "CNB_TARGET_ARCH"
+)
+
+// goarch is the architecture of the buildpack binary, which runs on the build image. It is a var
+// for testing.
+var goarch = runtime.GOARCH
+
+// Arch returns the architecture of the image being built in the format of GOARCH, e.g. "amd64"
+// or "arm64". Buildpacks use it to download the runtime archives built for the architecture.
+func (ctx *Context) Arch() string {
+	if arch := os.Getenv(targetArchEnv); arch != "" {
+		return arch
+	}
+	return goarch
+}
+
+// UnameArch returns the architecture of the image being built as printed by `uname -m`, e.g.
+// "x86_64" or "aarch64", which is used in the names of many release archives.
+func (ctx *Context) UnameArch() string {
+	switch arch := ctx.Arch(); arch {
+	case ArchAMD64:
+		return "x86_64"
+	case ArchARM64:
+		return "aarch64"
+	default:
+		return arch
+	}
+}
+
+// MultiarchTriplet returns the Debian multiarch triplet of the image being built, e.g.
+// "x86_64-linux-gnu", which names the directories of architecture specific libraries.
+func (ctx *Context) MultiarchTriplet() string {
+	return ctx.UnameArch() + "-linux-gnu"
+}
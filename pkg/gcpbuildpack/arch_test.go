@@ -0,0 +1,77 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import "testing"
+
+func TestArch(t *testing.T) {
+	testCases := []struct {
+		name          string
+		goarch        string
+		targetArch    string
+		want          string
+		wantUnameArch string
+		wantTriplet   string
+	}{
+		{
+			name:          "amd64",
+			goarch:        "amd64",
+			want:          "amd64",
+			wantUnameArch: "x86_64",
+			wantTriplet:   "x86_64-linux-gnu",
+		},
+		{
+			name:          "arm64",
+			goarch:        "arm64",
+			want:          "arm64",
+			wantUnameArch: "aarch64",
+			wantTriplet:   "aarch64-linux-gnu",
+		},
+		{
+			name:          "target arch set by the lifecycle",
+			goarch:        "amd64",
+			targetArch:    "arm64",
+			want:          "arm64",
+			wantUnameArch: "aarch64",
+			wantTriplet:   "aarch64-linux-gnu",
+		},
+		{
+			name:          "other arch",
+			goarch:        "ppc64le",
+			want:          "ppc64le",
+			wantUnameArch: "ppc64le",
+			wantTriplet:   "ppc64le-linux-gnu",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			orig := goarch
+			goarch = tc.goarch
+			t.Cleanup(func() { goarch = orig })
+			t.Setenv(targetArchEnv, tc.targetArch)
+			ctx := NewContext()
+
+			if got := ctx.Arch(); got != tc.want {
+				t.Errorf("Arch() = %q, want %q", got, tc.want)
+			}
+			if got := ctx.UnameArch(); got != tc.wantUnameArch {
+				t.Errorf("UnameArch() = %q, want %q", got, tc.wantUnameArch)
+			}
+			if got := ctx.MultiarchTriplet(); got != tc.wantTriplet {
+				t.Errorf("MultiarchTriplet() = %q, want %q", got, tc.wantTriplet)
+			}
+		})
+	}
+}
@@ -33,6 +33,8 @@ const (
 	builderOutputEnv         = "BUILDER_OUTPUT"
 	builderOutputFilename    = "output"
 	expectedBuilderOutputEnv = "EXPECTED_BUILDER_OUTPUT"
+	// expectedErrorCodeEnv is the error code that acceptance tests expect in the builder output.
+	expectedErrorCodeEnv = "EXPECTED_BUILDER_ERROR_CODE"
 )
 
 var (
@@ -76,7 +78,7 @@ func (ctx *Context) saveErrorOutput(be *buildererror.Error) {
 	}
 
 	be.BuildpackID, be.BuildpackVersion = ctx.BuildpackID(), ctx.BuildpackVersion()
-	bo := builderoutput.BuilderOutput{Error: *be}
+	bo := builderoutput.BuilderOutput{Error: *be, Vulnerabilities: ctx.vulnerabilities}
 	bm := buildermetrics.GlobalBuilderMetrics()
 	bo.Metrics = *bm
 	data, err := bo.JSON()
@@ -113,6 +115,9 @@ func (ctx *Context) saveErrorOutput(be *buildererror.Error) {
 			ctx.Warnf("Bad regexp %q: %v", expectedBuilderOutputEnv, err)
 		}
 	}
+	if expected := os.Getenv(expectedErrorCodeEnv); expected != "" {
+		ctx.Logf("Expected error code included in error output: %t (got %q)", string(be.Code) == expected, be.Code)
+	}
 	return
 }
 
@@ -176,6 +181,7 @@ func (ctx *Context) saveSuccessOutput(duration time.Duration) {
 		UserDurationMs:   ctx.stats.user.Milliseconds(),
 	})
 	bo.Warnings = append(bo.Warnings, ctx.warnings...)
+	bo.Vulnerabilities = append(bo.Vulnerabilities, ctx.vulnerabilities...)
 
 	bm := buildermetrics.GlobalBuilderMetrics()
 	bo.Metrics = *bm
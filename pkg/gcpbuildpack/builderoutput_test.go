@@ -15,8 +15,10 @@
 package gcpbuildpack
 
 import (
+	"bytes"
 	"encoding/json"
 	"io/ioutil"
+	"log"
 	"os"
 	"path/filepath"
 	"strings"
@@ -83,6 +85,31 @@ func TestSaveErrorOutput(t *testing.T) {
 	}
 }
 
+func TestSaveErrorOutputWithCode(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("BUILDER_OUTPUT", tempDir)
+	t.Setenv("EXPECTED_BUILDER_ERROR_CODE", string(buildererror.CodeNodejsMissingMain))
+	var buf bytes.Buffer
+	ctx := NewContext(WithBuildpackInfo(libcnb.BuildpackInfo{ID: "id", Version: "version"}), WithLogger(log.New(&buf, "", 0)))
+
+	ctx.saveErrorOutput(UserErrorf("index.js does not exist").WithCode(buildererror.CodeNodejsMissingMain))
+
+	data, err := ioutil.ReadFile(filepath.Join(tempDir, "output"))
+	if err != nil {
+		t.Fatalf("reading $BUILDER_OUTPUT/output: %v", err)
+	}
+	got, err := builderoutput.FromJSON(data)
+	if err != nil {
+		t.Fatalf("builderoutput.FromJSON() got error: %v", err)
+	}
+	if got.Error.Code != buildererror.CodeNodejsMissingMain {
+		t.Errorf("builder output error code = %q, want %q", got.Error.Code, buildererror.CodeNodejsMissingMain)
+	}
+	if want := "Expected error code included in error output: true"; !strings.Contains(buf.String(), want) {
+		t.Errorf("saveErrorOutput() logged %q, want it to contain %q", buf.String(), want)
+	}
+}
+
 func TestMessageProducers(t *testing.T) {
 	testCases := []struct {
 		name     string
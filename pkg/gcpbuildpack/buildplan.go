@@ -0,0 +1,90 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"github.com/buildpacks/libcnb"
+)
+
+// BuildPlanMetadata is the metadata of a build plan requirement, it is passed to the buildpacks
+// that provide the dependency, see
+// https://github.com/buildpacks/spec/blob/main/buildpack.md#build-plan-toml.
+type BuildPlanMetadata map[string]interface{}
+
+// Provide returns a build plan that provides the given dependencies. Every provided dependency
+// must be required by a buildpack of the group for the plan to be selected.
+func Provide(names ...string) libcnb.BuildPlan {
+	var plan libcnb.BuildPlan
+	for _, n := range names {
+		plan.Provides = append(plan.Provides, libcnb.BuildPlanProvide{Name: n})
+	}
+	return plan
+}
+
+// Require returns a build plan that requires the given dependency. The dependency must be provided
+// by this or an earlier buildpack of the group for the plan to be selected.
+func Require(name string, metadata BuildPlanMetadata) libcnb.BuildPlan {
+	return libcnb.BuildPlan{Requires: []libcnb.BuildPlanRequire{{Name: name, Metadata: metadata}}}
+}
+
+// ProvideRequire returns a build plan that both provides and requires the given dependency, so
+// that the buildpack receives the metadata of all the buildpacks that require it.
+func ProvideRequire(name string, metadata BuildPlanMetadata) libcnb.BuildPlan {
+	plan := Require(name, metadata)
+	plan.Provides = []libcnb.BuildPlanProvide{{Name: name}}
+	return plan
+}
+
+// WithBuildPlanEntry merges the provides and requires of plan into the primary build plan of the
+// detect result. Unlike WithBuildPlans, it can be used several times.
+func WithBuildPlanEntry(plan libcnb.BuildPlan) DetectResultOption {
+	return func(r *detectResult) {
+		if len(r.result.Plans) == 0 {
+			r.result.Plans = []libcnb.BuildPlan{{}}
+		}
+		primary := &r.result.Plans[0]
+		primary.Provides = append(primary.Provides, plan.Provides...)
+		primary.Requires = append(primary.Requires, plan.Requires...)
+	}
+}
+
+// BuildPlanEntries returns the entries of the buildpack plan with the given name. There is one
+// entry for each buildpack that required the dependency.
+func (ctx *Context) BuildPlanEntries(name string) []libcnb.BuildpackPlanEntry {
+	var entries []libcnb.BuildpackPlanEntry
+	for _, e := range ctx.buildContext.Plan.Entries {
+		if e.Name == name {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// BuildPlanEntryMetadata returns the metadata of all the buildpack plan entries with the given name,
+// merged in the order of the buildpacks that required them, and whether the dependency was
+// required at all.
+func (ctx *Context) BuildPlanEntryMetadata(name string) (BuildPlanMetadata, bool) {
+	entries := ctx.BuildPlanEntries(name)
+	if len(entries) == 0 {
+		return nil, false
+	}
+	metadata := BuildPlanMetadata{}
+	for _, e := range entries {
+		for k, v := range e.Metadata {
+			metadata[k] = v
+		}
+	}
+	return metadata, true
+}
@@ -0,0 +1,97 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"testing"
+
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWithBuildPlanEntry(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts []DetectResultOption
+		want []libcnb.BuildPlan
+	}{
+		{
+			name: "provide",
+			opts: []DetectResultOption{WithBuildPlanEntry(Provide("node_modules", "npm"))},
+			want: []libcnb.BuildPlan{{Provides: []libcnb.BuildPlanProvide{{Name: "node_modules"}, {Name: "npm"}}}},
+		},
+		{
+			name: "require with metadata",
+			opts: []DetectResultOption{WithBuildPlanEntry(Require("node_modules", BuildPlanMetadata{"dev": true}))},
+			want: []libcnb.BuildPlan{{Requires: []libcnb.BuildPlanRequire{{Name: "node_modules", Metadata: map[string]interface{}{"dev": true}}}}},
+		},
+		{
+			name: "provide require",
+			opts: []DetectResultOption{WithBuildPlanEntry(ProvideRequire("graalvm", nil))},
+			want: []libcnb.BuildPlan{{
+				Provides: []libcnb.BuildPlanProvide{{Name: "graalvm"}},
+				Requires: []libcnb.BuildPlanRequire{{Name: "graalvm"}},
+			}},
+		},
+		{
+			name: "entries are merged into the primary plan",
+			opts: []DetectResultOption{
+				WithBuildPlans(Provide("a"), Provide("b")),
+				WithBuildPlanEntry(Require("c", nil)),
+				WithBuildPlanEntry(Provide("d")),
+			},
+			want: []libcnb.BuildPlan{
+				{
+					Provides: []libcnb.BuildPlanProvide{{Name: "a"}, {Name: "d"}},
+					Requires: []libcnb.BuildPlanRequire{{Name: "c"}},
+				},
+				{Provides: []libcnb.BuildPlanProvide{{Name: "b"}}},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := OptInAlways(tc.opts...).Result().Plans
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("build plans mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBuildPlanEntryMetadata(t *testing.T) {
+	ctx := NewContext(WithBuildContext(libcnb.BuildContext{Plan: libcnb.BuildpackPlan{Entries: []libcnb.BuildpackPlanEntry{
+		{Name: "node_modules", Metadata: map[string]interface{}{"dev": false, "from": "first"}},
+		{Name: "graalvm"},
+		{Name: "node_modules", Metadata: map[string]interface{}{"dev": true}},
+	}}}))
+
+	if got := len(ctx.BuildPlanEntries("node_modules")); got != 2 {
+		t.Errorf("BuildPlanEntries(node_modules) got %d entries, want 2", got)
+	}
+	got, ok := ctx.BuildPlanEntryMetadata("node_modules")
+	if !ok {
+		t.Fatal("BuildPlanEntryMetadata(node_modules) not found")
+	}
+	if diff := cmp.Diff(BuildPlanMetadata{"dev": true, "from": "first"}, got); diff != "" {
+		t.Errorf("BuildPlanEntryMetadata(node_modules) mismatch (-want +got):\n%s", diff)
+	}
+	if got, ok := ctx.BuildPlanEntryMetadata("graalvm"); !ok || len(got) != 0 {
+		t.Errorf("BuildPlanEntryMetadata(graalvm) = %v, %t, want empty, true", got, ok)
+	}
+	if _, ok := ctx.BuildPlanEntryMetadata("missing"); ok {
+		t.Error("BuildPlanEntryMetadata(missing) found, want not found")
+	}
+}
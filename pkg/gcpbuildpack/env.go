@@ -21,19 +21,18 @@ import (
 	"github.com/buildpacks/libcnb"
 )
 
-// SetFunctionsEnvVars sets launch-time functions environment variables.
+// SetFunctionsEnvVars sets launch-time functions environment variables. When several function
+// targets are declared, the target of each process added by AddFunctionWebProcesses is set as a
+// process-specific variable.
 func (ctx *Context) SetFunctionsEnvVars(l *libcnb.Layer) error {
-	target, ok := os.LookupEnv(env.FunctionTarget)
-	if !ok {
-		return UserErrorf("required env var %s not found", env.FunctionTarget)
+	targets, err := ctx.FunctionTargets()
+	if err != nil {
+		return err
 	}
-	if target == "" {
-		return UserErrorf("required env var %s has an empty value", env.FunctionTarget)
-	}
-	l.LaunchEnvironment.Default(env.FunctionTargetLaunch, target)
 	if signature, ok := os.LookupEnv(env.FunctionSignatureType); ok {
 		l.LaunchEnvironment.Default(env.FunctionSignatureTypeLaunch, signature)
 	}
+	setFunctionTargetsEnv(l, targets)
 	if source, ok := os.LookupEnv(env.FunctionSource); ok {
 		l.LaunchEnvironment.Default(env.FunctionSourceLaunch, source)
 	}
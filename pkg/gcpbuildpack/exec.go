@@ -15,21 +15,29 @@
 package gcpbuildpack
 
 import (
-	"bytes"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildermetrics"
 	"golang.org/x/sys/unix"
 )
 
 var (
 	divider = strings.Repeat("-", 80)
+
+	// networkErrorRegexp matches the output of commands that failed on transient network errors:
+	// connection resets, timeouts, DNS lookup failures and HTTP 5xx responses of a registry.
+	networkErrorRegexp = regexp.MustCompile(`ETIMEDOUT|ECONNRESET|ECONNREFUSED|EAI_AGAIN|ESOCKETTIMEDOUT|\bE5\d\d\b|` +
+		`(?i:socket hang up|connection reset|connection refused|connection timed out|read timed out|i/o timeout|` +
+		`TLS handshake timeout|temporary failure in name resolution|max retries exceeded|` +
+		`(HTTP|status|status code|error)[: /]+5\d\d\b|\b5\d\d (internal server error|bad gateway|service unavailable|gateway time-?out))`)
 )
 
 // ExecResult bundles exec results.
@@ -48,6 +56,19 @@ type execParams struct {
 	userFailure     bool
 	userTiming      bool
 	messageProducer MessageProducer
+	outputLimit     int
+
+	// logPrefix is prepended to every logged line of the command, it is set by Parallel so that
+	// the interleaved output of concurrent commands can be told apart.
+	logPrefix string
+	// parallel is set by Parallel, which attributes user timing for the whole group of commands.
+	parallel bool
+	// streaming logs all of the output while the command runs, see WithStreamingOutput.
+	streaming bool
+	// retryAttempts, retryBackoff and retryable are set by WithRetry.
+	retryAttempts int
+	retryBackoff  time.Duration
+	retryable     func(*ExecResult) bool
 }
 
 // ExecOption configures Exec functions.
@@ -67,6 +88,50 @@ func WithWorkDir(dir string) ExecOption {
 	}
 }
 
+// WithOutputLimit sets the maximum number of bytes kept from each of stdout, stderr and the
+// combined output of the command. Output beyond the limit is dropped from the middle of the
+// stream, keeping its head and tail.
+func WithOutputLimit(bytes int) ExecOption {
+	return func(o *execParams) {
+		o.outputLimit = bytes
+	}
+}
+
+// WithStreamingOutput logs all of the output of the command as it is produced, including output
+// beyond the output limit, which is otherwise only logged once the command completes. The output
+// of commands that are not attributed to the user is also logged. The captured output is returned
+// as usual.
+var WithStreamingOutput = func(o *execParams) {
+	o.streaming = true
+}
+
+// WithRetry runs the command up to the given number of attempts until it succeeds, as long as
+// retryable returns true for the result of the failed attempt, which is useful for commands that
+// fail on transient network errors, e.g. installing dependencies from a registry. The wait between
+// attempts starts at backoff and doubles after each attempt. Commands that cannot be started are
+// not retried.
+func WithRetry(attempts int, backoff time.Duration, retryable func(*ExecResult) bool) ExecOption {
+	return func(o *execParams) {
+		o.retryAttempts = attempts
+		o.retryBackoff = backoff
+		o.retryable = retryable
+	}
+}
+
+// WithNetworkRetry retries commands that download dependencies from a registry when they fail on
+// transient network errors. Other failures, e.g. a dependency that cannot be resolved, are not
+// retried.
+var WithNetworkRetry = WithRetry(3, 5*time.Second, IsNetworkError)
+
+// IsNetworkError returns whether the output of the command shows that it failed on a transient
+// network error.
+func IsNetworkError(result *ExecResult) bool {
+	return networkErrorRegexp.MatchString(result.Combined)
+}
+
+// retrySleep waits between attempts of commands run WithRetry, it is a var for testing.
+var retrySleep = time.Sleep
+
 // WithUserAttribution indicates that failure and timing both are attributed to the user.
 var WithUserAttribution = func(o *execParams) {
 	o.userFailure = true
@@ -110,7 +175,7 @@ var WithStdoutHead = WithMessageProducer(KeepStdoutHead)
 
 // Exec runs the given command (with args) under the default configuration, allowing the caller to handle the error.
 func (ctx *Context) Exec(cmd []string, opts ...ExecOption) (*ExecResult, error) {
-	params := execParams{cmd: cmd, messageProducer: KeepCombinedTail}
+	params := execParams{cmd: cmd, messageProducer: KeepCombinedTail, outputLimit: defaultOutputLimit}
 	for _, o := range opts {
 		o(&params)
 	}
@@ -118,9 +183,21 @@ func (ctx *Context) Exec(cmd []string, opts ...ExecOption) (*ExecResult, error)
 	start := time.Now()
 
 	result, err := ctx.configuredExec(params)
+	delay := params.retryBackoff
+	for attempt := 1; err != nil && result != nil && attempt < params.retryAttempts && params.retryable(result); attempt++ {
+		ctx.Warnf("%q failed with exit code %d, retrying in %v (attempt %d of %d).", params.cmd[0], result.ExitCode, delay, attempt+1, params.retryAttempts)
+		retrySleep(delay)
+		delay *= 2
+		result, err = ctx.configuredExec(params)
+	}
+	if ctx.jsonLogs {
+		ctx.logExec(params, result, err, time.Since(start))
+	}
 
-	if params.userTiming {
+	if params.userTiming && !params.parallel {
+		ctx.mu.Lock()
 		ctx.stats.user += time.Since(start)
+		ctx.mu.Unlock()
 	}
 
 	if err == nil {
@@ -131,6 +208,7 @@ func (ctx *Context) Exec(cmd []string, opts ...ExecOption) (*ExecResult, error)
 	if result != nil {
 		message = params.messageProducer(result)
 	}
+	message = ctx.redact(message)
 
 	var be *buildererror.Error
 	if params.userFailure {
@@ -152,7 +230,7 @@ func (ctx *Context) configuredExec(params execParams) (*ExecResult, error) {
 	}
 
 	shouldLog := true
-	if !params.userFailure && !ctx.debug {
+	if !params.userFailure && !ctx.debug && !params.streaming {
 		// For "system" commands, we will only log if the debug flag is present.
 		shouldLog = false
 	}
@@ -161,7 +239,7 @@ func (ctx *Context) configuredExec(params execParams) (*ExecResult, error) {
 		if !shouldLog {
 			return
 		}
-		ctx.Logf(format, args...)
+		ctx.Logf("%s"+format, append([]interface{}{params.logPrefix}, args...)...)
 	}
 
 	readableCmd := strings.Join(params.cmd, " ")
@@ -169,7 +247,9 @@ func (ctx *Context) configuredExec(params execParams) (*ExecResult, error) {
 		env := strings.Join(params.env, " ")
 		readableCmd = fmt.Sprintf("%s (%s)", readableCmd, env)
 	}
-	optionalLogf(divider)
+	if !ctx.jsonLogs {
+		optionalLogf(divider)
+	}
 	optionalLogf("Running %q", readableCmd)
 
 	status := buildererror.StatusInternal
@@ -189,16 +269,42 @@ func (ctx *Context) configuredExec(params execParams) (*ExecResult, error) {
 		ecmd.Dir = params.dir
 	}
 
-	if len(params.env) > 0 {
-		ecmd.Env = append(append(ecmd.Env, os.Environ()...), params.env...)
+	// Build-time secrets are not part of readableCmd so that they are not logged.
+	if len(params.env) > 0 || len(ctx.secrets) > 0 {
+		ecmd.Env = append(append(append(ecmd.Env, os.Environ()...), ctx.secrets...), params.env...)
 	}
 
-	var outb, errb bytes.Buffer
-	combinedb := lockingBuffer{log: shouldLog}
-	ecmd.Stdout = io.MultiWriter(&outb, &combinedb)
-	ecmd.Stderr = io.MultiWriter(&errb, &combinedb)
+	out := ctx.output
+	if ctx.jsonLogs || params.logPrefix != "" {
+		lw := newPrefixWriter(ctx.output, params.logPrefix)
+		if ctx.jsonLogs {
+			lw = ctx.newJSONLogWriter(params)
+		}
+		defer lw.flush()
+		out = lw
+	}
+	outb, errb := newCappedBuffer(params.outputLimit), newCappedBuffer(params.outputLimit)
+	combinedb := lockingBuffer{buf: newCappedBuffer(params.outputLimit), log: shouldLog, stream: params.streaming, out: out}
+	ecmd.Stdout = io.MultiWriter(outb, &combinedb)
+	ecmd.Stderr = io.MultiWriter(errb, &combinedb)
+	var redacting []*lineWriter
+	if ctx.redactor != nil {
+		// Output is redacted line by line before it is captured, so that neither the logs, the
+		// truncated output nor the error messages built from it contain secrets.
+		redacting = []*lineWriter{ctx.newRedactingWriter(ecmd.Stdout), ctx.newRedactingWriter(ecmd.Stderr)}
+		ecmd.Stdout, ecmd.Stderr = redacting[0], redacting[1]
+	}
 
-	if err := ecmd.Run(); err != nil {
+	err := ecmd.Run()
+	for _, lw := range redacting {
+		lw.flush()
+	}
+	combinedb.flushLog()
+	if truncated := combinedb.buf.truncated(); truncated > 0 {
+		buildermetrics.GlobalBuilderMetrics().GetCounter(buildermetrics.ExecOutputTruncatedCounterID).Increment(1)
+		ctx.Warnf("Output of %q exceeded %s and was truncated: %s", params.cmd[0], byteSize(int64(params.outputLimit)), truncationMarker(truncated))
+	}
+	if err != nil {
 		if ee, ok := err.(*exec.ExitError); ok {
 			// The command returned a non-zero result.
 			exitCode = ee.ExitCode()
@@ -219,9 +325,9 @@ func (ctx *Context) configuredExec(params execParams) (*ExecResult, error) {
 
 	result := &ExecResult{
 		ExitCode: exitCode,
-		Stdout:   strings.TrimSpace(string(outb.Bytes())),
-		Stderr:   strings.TrimSpace(string(errb.Bytes())),
-		Combined: strings.TrimSpace(string(combinedb.Bytes())),
+		Stdout:   strings.TrimSpace(outb.String()),
+		Stderr:   strings.TrimSpace(errb.String()),
+		Combined: strings.TrimSpace(combinedb.String()),
 	}
 
 	if exitCode != 0 {
@@ -233,22 +339,50 @@ func (ctx *Context) configuredExec(params execParams) (*ExecResult, error) {
 }
 
 type lockingBuffer struct {
-	buf bytes.Buffer
+	buf *cappedBuffer
 	sync.Mutex
 
-	// log tells the buffer to also log the output to stderr.
+	// log tells the buffer to also log the output to out. Only the head of the output is logged
+	// while the command runs; the tail is logged by flushLog.
 	log bool
+	// stream tells the buffer to log all of the output while the command runs instead.
+	stream bool
+	out    io.Writer
 }
 
 func (lb *lockingBuffer) Write(p []byte) (int, error) {
 	lb.Lock()
 	defer lb.Unlock()
-	if lb.log {
-		os.Stderr.Write(p)
+	if lb.stream {
+		lb.out.Write(p)
+		return lb.buf.Write(p)
+	}
+	if room := lb.buf.headRoom(); lb.log && room > 0 {
+		if room > len(p) {
+			room = len(p)
+		}
+		lb.out.Write(p[:room])
 	}
 	return lb.buf.Write(p)
 }
 
-func (lb *lockingBuffer) Bytes() []byte {
-	return lb.buf.Bytes()
+// flushLog logs the part of the output that was held back while the command ran: the tail,
+// preceded by the truncation marker if the output exceeded the limit.
+func (lb *lockingBuffer) flushLog() {
+	lb.Lock()
+	defer lb.Unlock()
+	if !lb.log || lb.stream || lb.buf.ringLen == 0 {
+		return
+	}
+	if truncated := lb.buf.truncated(); truncated > 0 {
+		fmt.Fprintf(lb.out, "\n%s\n%s", truncationMarker(truncated), trimIncompleteRunePrefix(lb.buf.tail()))
+		return
+	}
+	lb.out.Write(lb.buf.tail())
+}
+
+func (lb *lockingBuffer) String() string {
+	lb.Lock()
+	defer lb.Unlock()
+	return lb.buf.String()
 }
@@ -15,6 +15,8 @@
 package gcpbuildpack
 
 import (
+	"bytes"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -26,6 +28,7 @@ import (
 	"time"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestExecEmitsSpan(t *testing.T) {
@@ -632,3 +635,129 @@ func (e *fakeExiter) Exit(exitCode int, be *buildererror.Error) {
 	e.code = exitCode
 	e.err = be
 }
+
+func TestExecWithStreamingOutput(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := jsonLogContext(t, &buf)
+	ctx.debug = false
+
+	cmd := []string{"/bin/bash", "-c", "for i in $(seq 1 100); do echo line $i; done"}
+	result, err := ctx.Exec(cmd, WithOutputLimit(200), WithStreamingOutput)
+	if err != nil {
+		t.Fatalf("Exec(%v) got unexpected error: %v", cmd, err)
+	}
+
+	var lines []string
+	for _, e := range decodeLogEntries(t, &buf) {
+		if e.Command != "" && e.DurationMs == nil {
+			lines = append(lines, e.Message)
+		}
+	}
+	if len(lines) != 100 || lines[0] != "line 1" || lines[99] != "line 100" {
+		t.Errorf("streamed %d lines %v, want lines 1 to 100", len(lines), lines)
+	}
+	if !strings.Contains(result.Stdout, "truncated ...]") {
+		t.Errorf("stdout = %q, want the captured output to be truncated", result.Stdout)
+	}
+}
+
+func TestExecWithRetry(t *testing.T) {
+	var slept []time.Duration
+	origSleep := retrySleep
+	retrySleep = func(d time.Duration) { slept = append(slept, d) }
+	t.Cleanup(func() { retrySleep = origSleep })
+
+	testCases := []struct {
+		name      string
+		failures  int
+		output    string
+		attempts  int
+		wantErr   bool
+		wantSlept []time.Duration
+	}{
+		{
+			name:     "succeeds first time",
+			attempts: 3,
+		},
+		{
+			name:      "succeeds after retries",
+			failures:  2,
+			output:    "npm ERR! code ECONNRESET",
+			attempts:  3,
+			wantSlept: []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			name:      "fails after all attempts",
+			failures:  3,
+			output:    "npm ERR! code ETIMEDOUT",
+			attempts:  3,
+			wantErr:   true,
+			wantSlept: []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			name:     "non-network failure is not retried",
+			failures: 1,
+			output:   "npm ERR! `npm ci` can only install packages when your package.json and package-lock.json are in sync.",
+			attempts: 3,
+			wantErr:  true,
+		},
+		{
+			name:     "no retry",
+			failures: 1,
+			output:   "ECONNRESET",
+			wantErr:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			slept = nil
+			counter := filepath.Join(t.TempDir(), "counter")
+			// The script fails with tc.output until it has run tc.failures times.
+			script := fmt.Sprintf(`echo x >> %s; [ $(wc -l < %s) -gt %d ] && exit 0; echo '%s'; exit 1`, counter, counter, tc.failures, tc.output)
+			ctx := NewContext()
+
+			_, err := ctx.Exec([]string{"/bin/sh", "-c", script}, WithRetry(tc.attempts, time.Second, IsNetworkError))
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("Exec() got error: %v, want error? %v", err, tc.wantErr)
+			}
+			if got := lineCount(t, counter); got != len(tc.wantSlept)+1 {
+				t.Errorf("Exec() ran the command %d times, want %d", got, len(tc.wantSlept)+1)
+			}
+			if diff := cmp.Diff(tc.wantSlept, slept); diff != "" {
+				t.Errorf("Exec() waits mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func lineCount(t *testing.T, path string) int {
+	t.Helper()
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+	return strings.Count(string(content), "\n")
+}
+
+func TestIsNetworkError(t *testing.T) {
+	testCases := []struct {
+		output string
+		want   bool
+	}{
+		{output: "npm ERR! code ECONNRESET", want: true},
+		{output: "npm ERR! code EAI_AGAIN\nnpm ERR! request to https://registry.npmjs.org/ failed", want: true},
+		{output: "npm ERR! code E503\nnpm ERR! 503 Service Unavailable - GET https://registry.npmjs.org/express", want: true},
+		{output: "ReadTimeoutError: HTTPSConnectionPool(host='pypi.org', port=443): Read timed out.", want: true},
+		{output: "error: Failed to fetch: `https://pypi.org/simple/flask/`\n  Caused by: HTTP status server error (502 Bad Gateway)", want: true},
+		{output: "dial tcp: lookup proxy.golang.org: i/o timeout", want: true},
+		{output: "npm ERR! code E404\nnpm ERR! 404 Not Found - GET https://registry.npmjs.org/not-a-package", want: false},
+		{output: "ERROR: Could not find a version that satisfies the requirement flask==99.0", want: false},
+		{output: "Usage Error: This project is configured to use yarn", want: false},
+		{output: "", want: false},
+	}
+	for _, tc := range testCases {
+		if got := IsNetworkError(&ExecResult{Combined: tc.output}); got != tc.want {
+			t.Errorf("IsNetworkError(%q) = %v, want %v", tc.output, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"path/filepath"
+
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// ExecDDir is the directory of the buildpack that holds the exec.d executables declared in the
+	// execd attribute of the buildpack build rule.
+	ExecDDir = "exec.d"
+
+	// execDLayerName is the name of the launch layer that holds the exec.d executables.
+	execDLayerName = "execd"
+)
+
+// AddExecD adds an exec.d executable to the image, which the launcher runs before the process
+// starts to set environment variables that can only be computed at launch time, e.g. from the
+// container memory limit. The executable should be implemented with the execd package.
+// binary is the path of the executable, relative to the buildpack root if it is not absolute, e.g.
+// filepath.Join(ExecDDir, "heap-size").
+func (ctx *Context) AddExecD(name, binary string) error {
+	l, err := ctx.execDLayer()
+	if err != nil {
+		return err
+	}
+	if !filepath.IsAbs(binary) {
+		binary = filepath.Join(ctx.BuildpackRoot(), binary)
+	}
+	content, err := ctx.ReadFile(binary)
+	if err != nil {
+		return err
+	}
+	ctx.Debugf("Adding exec.d executable %s from %s.", name, binary)
+	return ctx.WriteFile(l.Exec.FilePath(name), content, 0755)
+}
+
+// execDLayer returns the launch layer that holds the exec.d executables, creating it on first use.
+func (ctx *Context) execDLayer() (*libcnb.Layer, error) {
+	if ctx.execD != nil {
+		return ctx.execD, nil
+	}
+	l, err := ctx.Layer(execDLayerName, LaunchLayer)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.MkdirAll(l.Exec.Path, layerMode); err != nil {
+		return nil, err
+	}
+	ctx.execD = l
+	return l, nil
+}
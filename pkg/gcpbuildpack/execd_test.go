@@ -0,0 +1,77 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/buildpacks/libcnb"
+)
+
+func TestAddExecD(t *testing.T) {
+	bpRoot := t.TempDir()
+	layers := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(bpRoot, ExecDDir), 0755); err != nil {
+		t.Fatalf("creating %s: %v", ExecDDir, err)
+	}
+	for _, name := range []string{"heap-size", "secrets"} {
+		if err := ioutil.WriteFile(filepath.Join(bpRoot, ExecDDir, name), []byte("#!/bin/sh\n# "+name), 0644); err != nil {
+			t.Fatalf("writing %s: %v", name, err)
+		}
+	}
+	ctx := NewContext(WithBuildpackRoot(bpRoot), WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layers}}))
+
+	for _, name := range []string{"heap-size", "secrets"} {
+		if err := ctx.AddExecD(name, filepath.Join(ExecDDir, name)); err != nil {
+			t.Fatalf("AddExecD(%q) got error: %v", name, err)
+		}
+	}
+
+	if got := len(ctx.buildResult.Layers); got != 1 {
+		t.Fatalf("AddExecD() created %d layers, want 1", got)
+	}
+	l := ctx.execD
+	if !l.Launch || l.Build || l.Cache {
+		t.Errorf("AddExecD() layer types = %+v, want launch only", l.LayerTypes)
+	}
+	for _, name := range []string{"heap-size", "secrets"} {
+		path := filepath.Join(layers, execDLayerName, "exec.d", name)
+		fi, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("stat %s: %v", path, err)
+		}
+		if fi.Mode().Perm() != 0755 {
+			t.Errorf("%s mode = %v, want 0755", path, fi.Mode().Perm())
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("reading %s: %v", path, err)
+		}
+		if want := "#!/bin/sh\n# " + name; string(content) != want {
+			t.Errorf("%s content = %q, want %q", path, content, want)
+		}
+	}
+}
+
+func TestAddExecDMissingBinary(t *testing.T) {
+	ctx := NewContext(WithBuildpackRoot(t.TempDir()), WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}))
+
+	if err := ctx.AddExecD("heap-size", filepath.Join(ExecDDir, "heap-size")); err == nil {
+		t.Error("AddExecD() got no error, want error for a missing executable")
+	}
+}
@@ -16,7 +16,6 @@ package gcpbuildpack
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
 )
@@ -36,8 +35,14 @@ func (e defaultExiter) Exit(exitCode int, be *buildererror.Error) {
 		if be.ID != "" {
 			msg += fmt.Sprintf("(ID: %s) ", be.ID)
 		}
+		if be.Code != "" {
+			msg += fmt.Sprintf("(Code: %s) ", be.Code)
+		}
 		msg += be.Message
-		e.ctx.Logf(msg)
+		e.ctx.logf(severityError, "", "%s", msg)
+		if be.Hint != nil {
+			e.ctx.logf(severityError, "", "%s", be.Hint)
+		}
 		e.ctx.saveErrorOutput(be)
 	}
 
@@ -51,5 +56,14 @@ func (e defaultExiter) Exit(exitCode int, be *buildererror.Error) {
 		e.ctx.Tipf(divider)
 	}
 
-	os.Exit(exitCode)
+	// Deferred functions do not run on exit, the span of the phase is ended here instead.
+	status := buildererror.StatusOk
+	if be != nil {
+		status = be.Status
+	} else if exitCode != 0 {
+		status = buildererror.StatusInternal
+	}
+	e.ctx.endPhaseSpan(status)
+
+	e.ctx.exit(exitCode)
 }
@@ -0,0 +1,186 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+	"gopkg.in/yaml.v2"
+)
+
+// FunctionsConfigFile is the file in the application root that declares the functions served by
+// the image, as an alternative to a comma-separated GOOGLE_FUNCTION_TARGET.
+const FunctionsConfigFile = "functions.yaml"
+
+// invalidProcessTypeChars matches the characters that are not allowed in a process type.
+var invalidProcessTypeChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// FunctionTarget describes a function served by a functions framework process.
+type FunctionTarget struct {
+	// Target is the name of the function, e.g. "HelloWorld".
+	Target string `yaml:"target"`
+	// SignatureType is the optional signature type of the function, e.g. "http" or "cloudevent".
+	SignatureType string `yaml:"signatureType"`
+}
+
+// ProcessType returns the name of the launch process that serves the function. It is the target
+// with the characters that are not allowed in a process type replaced by "-".
+func (t FunctionTarget) ProcessType() string {
+	return invalidProcessTypeChars.ReplaceAllString(t.Target, "-")
+}
+
+type functionsConfig struct {
+	Functions []FunctionTarget `yaml:"functions"`
+}
+
+// DetectFunction opts in if the function targets are declared in GOOGLE_FUNCTION_TARGET or in
+// functions.yaml.
+func DetectFunction(ctx *Context, opts ...DetectResultOption) (DetectResult, error) {
+	if _, ok := os.LookupEnv(env.FunctionTarget); ok {
+		return OptInEnvSet(env.FunctionTarget, opts...), nil
+	}
+	configExists, err := ctx.FileExists(ctx.ApplicationRoot(), FunctionsConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	if configExists {
+		return OptInFileFound(FunctionsConfigFile, opts...), nil
+	}
+	return OptOutEnvNotSet(env.FunctionTarget), nil
+}
+
+// FunctionTargets returns the functions to serve. They are read from GOOGLE_FUNCTION_TARGET,
+// which may hold several comma-separated targets, or from functions.yaml if the env var is not set.
+// The first target is served by the web process.
+func (ctx *Context) FunctionTargets() ([]FunctionTarget, error) {
+	configPath := filepath.Join(ctx.ApplicationRoot(), FunctionsConfigFile)
+	configExists, err := ctx.FileExists(configPath)
+	if err != nil {
+		return nil, err
+	}
+	var targets []FunctionTarget
+	if value, ok := os.LookupEnv(env.FunctionTarget); ok {
+		if strings.TrimSpace(value) == "" {
+			return nil, UserErrorf("required env var %s has an empty value", env.FunctionTarget)
+		}
+		if configExists {
+			ctx.Debugf("Ignoring %s because %s is set.", FunctionsConfigFile, env.FunctionTarget)
+		}
+		for _, t := range strings.Split(value, ",") {
+			targets = append(targets, FunctionTarget{Target: strings.TrimSpace(t)})
+		}
+	} else if configExists {
+		if targets, err = ctx.readFunctionsConfig(configPath); err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, UserErrorf("required env var %s not found", env.FunctionTarget)
+	}
+
+	processTypes := map[string]string{}
+	for _, t := range targets {
+		if t.Target == "" {
+			return nil, UserErrorf("invalid function targets %q: targets must not be empty", joinTargets(targets))
+		}
+		pt := t.ProcessType()
+		if other, ok := processTypes[pt]; ok {
+			return nil, UserErrorf("function targets %q and %q both use the process type %q", other, t.Target, pt)
+		}
+		if len(targets) > 1 && pt == WebProcess {
+			return nil, UserErrorf("function target %q conflicts with the %q process, rename the function", t.Target, WebProcess)
+		}
+		processTypes[pt] = t.Target
+	}
+	return targets, nil
+}
+
+func (ctx *Context) readFunctionsConfig(path string) ([]FunctionTarget, error) {
+	content, err := ctx.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config functionsConfig
+	if err := yaml.UnmarshalStrict(content, &config); err != nil {
+		return nil, UserErrorf("parsing %s: %v", FunctionsConfigFile, err)
+	}
+	if len(config.Functions) == 0 {
+		return nil, UserErrorf("%s does not declare any functions", FunctionsConfigFile)
+	}
+	return config.Functions, nil
+}
+
+// SingleFunctionTarget returns the only function target, or an error if several targets are
+// declared. It is used by the functions frameworks that compile the target into the image.
+func (ctx *Context) SingleFunctionTarget(runtime string) (FunctionTarget, error) {
+	targets, err := ctx.FunctionTargets()
+	if err != nil {
+		return FunctionTarget{}, err
+	}
+	if len(targets) > 1 {
+		return FunctionTarget{}, UserErrorf("multiple function targets (%s) are not supported for %s functions", joinTargets(targets), runtime)
+	}
+	return targets[0], nil
+}
+
+// AddFunctionWebProcesses adds the web process that serves the first function target. When several
+// targets are declared, it also adds one process per target so that the target can be selected by
+// overriding the image command, e.g. `docker run <image> <target>`. SetFunctionsEnvVars must be
+// called to set the target of each process.
+func (ctx *Context) AddFunctionWebProcesses(cmd []string) error {
+	targets, err := ctx.FunctionTargets()
+	if err != nil {
+		return err
+	}
+	ctx.AddWebProcess(cmd)
+	if len(targets) == 1 {
+		return nil
+	}
+	for _, t := range targets {
+		ctx.AddProcess(t.ProcessType(), cmd, AsDirectProcess())
+	}
+	ctx.Logf("Added launch processes for function targets: %s", joinTargets(targets))
+	return nil
+}
+
+// setFunctionTargetsEnv sets the launch-time target and signature type of each function process.
+func setFunctionTargetsEnv(l *libcnb.Layer, targets []FunctionTarget) {
+	first := targets[0]
+	l.LaunchEnvironment.Default(env.FunctionTargetLaunch, first.Target)
+	if first.SignatureType != "" {
+		l.LaunchEnvironment.Default(env.FunctionSignatureTypeLaunch, first.SignatureType)
+	}
+	if len(targets) == 1 {
+		return
+	}
+	for _, t := range targets {
+		l.LaunchEnvironment.ProcessOverride(t.ProcessType(), env.FunctionTargetLaunch, t.Target)
+		if t.SignatureType != "" {
+			l.LaunchEnvironment.ProcessOverride(t.ProcessType(), env.FunctionSignatureTypeLaunch, t.SignatureType)
+		}
+	}
+}
+
+func joinTargets(targets []FunctionTarget) string {
+	var names []string
+	for _, t := range targets {
+		names = append(names, t.Target)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,215 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFunctionTargets(t *testing.T) {
+	testCases := []struct {
+		name    string
+		target  *string
+		config  string
+		want    []FunctionTarget
+		wantErr bool
+	}{
+		{
+			name:   "single target",
+			target: strPtr("HelloWorld"),
+			want:   []FunctionTarget{{Target: "HelloWorld"}},
+		},
+		{
+			name:   "comma-separated targets",
+			target: strPtr("HelloWorld, HelloEvent,com.example.Hello"),
+			want:   []FunctionTarget{{Target: "HelloWorld"}, {Target: "HelloEvent"}, {Target: "com.example.Hello"}},
+		},
+		{
+			name: "functions.yaml",
+			config: `functions:
+- target: HelloWorld
+- target: HelloEvent
+  signatureType: cloudevent
+`,
+			want: []FunctionTarget{{Target: "HelloWorld"}, {Target: "HelloEvent", SignatureType: "cloudevent"}},
+		},
+		{
+			name:   "env var takes precedence over functions.yaml",
+			target: strPtr("HelloWorld"),
+			config: "functions:\n- target: HelloEvent\n",
+			want:   []FunctionTarget{{Target: "HelloWorld"}},
+		},
+		{
+			name:    "neither env var nor functions.yaml",
+			wantErr: true,
+		},
+		{
+			name:    "empty env var",
+			target:  strPtr(""),
+			wantErr: true,
+		},
+		{
+			name:    "empty target in list",
+			target:  strPtr("HelloWorld,,HelloEvent"),
+			wantErr: true,
+		},
+		{
+			name:    "duplicate process types",
+			target:  strPtr("hello$world,hello_world,hello-world"),
+			wantErr: true,
+		},
+		{
+			name:    "target conflicts with web process",
+			target:  strPtr("HelloWorld,web"),
+			wantErr: true,
+		},
+		{
+			name:    "empty functions.yaml",
+			config:  "functions: []\n",
+			wantErr: true,
+		},
+		{
+			name:    "unknown functions.yaml field",
+			config:  "functions:\n- name: HelloWorld\n",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.target != nil {
+				t.Setenv(env.FunctionTarget, *tc.target)
+			} else {
+				unsetEnv(t, env.FunctionTarget)
+			}
+			root := t.TempDir()
+			if tc.config != "" {
+				if err := ioutil.WriteFile(filepath.Join(root, FunctionsConfigFile), []byte(tc.config), 0644); err != nil {
+					t.Fatalf("writing %s: %v", FunctionsConfigFile, err)
+				}
+			}
+			ctx := NewContext(WithApplicationRoot(root))
+
+			got, err := ctx.FunctionTargets()
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("FunctionTargets() got error: %v, want error? %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("FunctionTargets() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFunctionTargetProcessType(t *testing.T) {
+	testCases := []struct {
+		target string
+		want   string
+	}{
+		{target: "HelloWorld", want: "HelloWorld"},
+		{target: "com.example.Hello", want: "com.example.Hello"},
+		{target: "hello_world-2", want: "hello_world-2"},
+		{target: "$hello world", want: "-hello-world"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.target, func(t *testing.T) {
+			if got := (FunctionTarget{Target: tc.target}).ProcessType(); got != tc.want {
+				t.Errorf("ProcessType() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAddFunctionWebProcesses(t *testing.T) {
+	t.Setenv(env.FunctionTarget, "HelloWorld,HelloEvent")
+	ctx := NewContext(WithApplicationRoot(t.TempDir()))
+
+	if err := ctx.AddFunctionWebProcesses([]string{"/start"}); err != nil {
+		t.Fatalf("AddFunctionWebProcesses() got error: %v", err)
+	}
+	want := []libcnb.Process{
+		proc("/start", "web"),
+		{Command: "/start", Type: "HelloWorld", Direct: true},
+		{Command: "/start", Type: "HelloEvent", Direct: true},
+	}
+	if diff := cmp.Diff(want, ctx.buildResult.Processes); diff != "" {
+		t.Errorf("AddFunctionWebProcesses() processes mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSetFunctionsEnvVars(t *testing.T) {
+	testCases := []struct {
+		name   string
+		target string
+		env    map[string]string
+		want   libcnb.Environment
+	}{
+		{
+			name:   "single target",
+			target: "HelloWorld",
+			env:    map[string]string{env.FunctionSignatureType: "http", env.FunctionSource: "app.py"},
+			want: libcnb.Environment{
+				"FUNCTION_TARGET.default":         "HelloWorld",
+				"FUNCTION_SIGNATURE_TYPE.default": "http",
+				"FUNCTION_SOURCE.default":         "app.py",
+			},
+		},
+		{
+			name:   "multiple targets",
+			target: "HelloWorld,HelloEvent",
+			want: libcnb.Environment{
+				"FUNCTION_TARGET.default":             "HelloWorld",
+				"HelloWorld/FUNCTION_TARGET.override": "HelloWorld",
+				"HelloEvent/FUNCTION_TARGET.override": "HelloEvent",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(env.FunctionTarget, tc.target)
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			ctx := NewContext(WithApplicationRoot(t.TempDir()))
+			l := &libcnb.Layer{LaunchEnvironment: libcnb.Environment{}}
+
+			if err := ctx.SetFunctionsEnvVars(l); err != nil {
+				t.Fatalf("SetFunctionsEnvVars() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, l.LaunchEnvironment); diff != "" {
+				t.Errorf("SetFunctionsEnvVars() launch environment mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+// unsetEnv unsets an env var for the duration of the test.
+func unsetEnv(t *testing.T, name string) {
+	t.Helper()
+	if v, ok := os.LookupEnv(name); ok {
+		t.Cleanup(func() { os.Setenv(name, v) })
+	}
+	os.Unsetenv(name)
+}
@@ -18,6 +18,7 @@ package gcpbuildpack
 import (
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
@@ -25,9 +26,11 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/builderoutput"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	"github.com/buildpacks/libcnb"
 )
@@ -69,19 +72,29 @@ type BuildFn func(*Context) error
 type stats struct {
 	spans []*spanInfo
 	user  time.Duration
+	// cacheHits and cacheMisses are the tags passed to CacheHit and CacheMiss.
+	cacheHits   []string
+	cacheMisses []string
 }
 
 // Context provides contextually aware functions for buildpack authors.
 type Context struct {
 	info                     libcnb.BuildpackInfo
 	applicationRoot          string
+	workspaceRoot            string
 	buildpackRoot            string
 	debug                    bool
+	jsonLogs                 bool
+	phase                    string
 	logger                   *log.Logger
 	installedRuntimeVersions []string
 	stats                    stats
 	exiter                   Exiter
 	warnings                 []string
+	vulnerabilities          []builderoutput.VulnerabilityReport
+	// phaseSpan is the span of the phase in progress, traceExported is set once it is exported.
+	phaseSpan     *phaseSpan
+	traceExported bool
 
 	// detect items
 	detectContext libcnb.DetectContext
@@ -89,8 +102,24 @@ type Context struct {
 	// build items
 	buildContext libcnb.BuildContext
 	buildResult  libcnb.BuildResult
+	sboms        []*layerSBOM
+	// restoredLayers are the names of the layers restored from cache that have not been cleared.
+	restoredLayers map[string]bool
+	// execD is the layer of the exec.d executables added by AddExecD.
+	execD *libcnb.Layer
+	// secrets are the build-time secrets of the form "KEY=value", redactor replaces their values.
+	secrets  []string
+	redactor *strings.Replacer
+	// sourceDigest is the hash of the application source recorded in the provenance.
+	sourceDigest string
 
 	execCmd func(name string, arg ...string) *exec.Cmd
+	// output receives the output of the executed commands, exit is called by the default Exiter.
+	output io.Writer
+	exit   func(code int)
+
+	// mu guards stats and warnings, which are updated by the concurrent commands of Parallel.
+	mu sync.Mutex
 }
 
 // ContextOption configures NewContext functions.
@@ -140,6 +169,23 @@ func WithLogger(logger *log.Logger) ContextOption {
 	}
 }
 
+// WithOutput sends the logs and the output of executed commands to w in place of stderr, this is
+// useful for tests that run a buildpack in-process.
+func WithOutput(w io.Writer) ContextOption {
+	return func(ctx *Context) {
+		ctx.logger = log.New(w, "", 0)
+		ctx.output = w
+	}
+}
+
+// WithExitFunc overrides the function that exits the process once the buildpack failed, os.Exit
+// by default, this is useful for tests that run a buildpack in-process.
+func WithExitFunc(exit func(code int)) ContextOption {
+	return func(ctx *Context) {
+		ctx.exit = exit
+	}
+}
+
 // WithStackID sets the StackID in Context.
 func WithStackID(stackID string) ContextOption {
 	return func(ctx *Context) {
@@ -154,10 +200,18 @@ func NewContext(opts ...ContextOption) *Context {
 		defaultLogger.Printf("Failed to parse debug mode: %v", err)
 		os.Exit(1)
 	}
+	jsonLogs, err := jsonLogFormat()
+	if err != nil {
+		defaultLogger.Printf("Failed to parse log format: %v", err)
+		os.Exit(1)
+	}
 	ctx := &Context{
-		debug:   debug,
-		execCmd: exec.Command,
-		logger:  defaultLogger,
+		debug:    debug,
+		jsonLogs: jsonLogs,
+		execCmd:  exec.Command,
+		logger:   defaultLogger,
+		output:   os.Stderr,
+		exit:     os.Exit,
 	}
 	ctx.exiter = defaultExiter{ctx: ctx}
 	for _, o := range opts {
@@ -170,7 +224,9 @@ func NewContext(opts ...ContextOption) *Context {
 func newDetectContext(detectContext libcnb.DetectContext) *Context {
 	ctx := NewContext(WithBuildpackInfo(detectContext.Buildpack.Info))
 	ctx.detectContext = detectContext
+	ctx.phase = "detect"
 	ctx.applicationRoot = ctx.detectContext.Application.Path
+	ctx.workspaceRoot = ctx.applicationRoot
 	ctx.buildpackRoot = ctx.detectContext.Buildpack.Path
 	return ctx
 }
@@ -178,7 +234,9 @@ func newDetectContext(detectContext libcnb.DetectContext) *Context {
 func newBuildContext(buildContext libcnb.BuildContext) *Context {
 	ctx := NewContext(WithBuildpackInfo(buildContext.Buildpack.Info))
 	ctx.buildContext = buildContext
+	ctx.phase = "build"
 	ctx.applicationRoot = ctx.buildContext.Application.Path
+	ctx.workspaceRoot = ctx.applicationRoot
 	ctx.buildpackRoot = ctx.buildContext.Buildpack.Path
 	ctx.buildResult = libcnb.NewBuildResult()
 	return ctx
@@ -199,7 +257,9 @@ func (ctx *Context) BuildpackName() string {
 	return ctx.info.Name
 }
 
-// ApplicationRoot returns the root folder of the application code.
+// ApplicationRoot returns the root folder of the application code. It is a subdirectory of the
+// source if one was selected with GOOGLE_APPLICATION_DIR, GOOGLE_SUBDIRECTORY or the source root
+// marker file.
 func (ctx *Context) ApplicationRoot() string {
 	return ctx.applicationRoot
 }
@@ -224,6 +284,17 @@ func (ctx *Context) Processes() []libcnb.Process {
 	return ctx.buildResult.Processes
 }
 
+// Layers returns the list of layers created by the buildpack.
+func (ctx *Context) Layers() []*libcnb.Layer {
+	var layers []*libcnb.Layer
+	for _, c := range ctx.buildResult.Layers {
+		if lc, ok := c.(layerContributor); ok {
+			layers = append(layers, lc.l)
+		}
+	}
+	return layers
+}
+
 // Main is the main entrypoint to a buildpack's detect and build functions.
 func Main(d DetectFn, b BuildFn) {
 	switch filepath.Base(os.Args[0]) {
@@ -244,9 +315,18 @@ type gcpdetector struct {
 func (gcpd gcpdetector) Detect(ldctx libcnb.DetectContext) (libcnb.DetectResult, error) {
 	ctx := newDetectContext(ldctx)
 	status := buildererror.StatusInternal
-	defer func(now time.Time) {
-		ctx.Span(fmt.Sprintf("Buildpack Detect %s", ctx.info.ID), now, status)
-	}(time.Now())
+	ctx.startPhaseSpan(fmt.Sprintf("Buildpack Detect %s", ctx.info.ID))
+	defer func() {
+		ctx.endPhaseSpan(status)
+	}()
+
+	if err := ctx.useEffectiveApplicationRoot(); err != nil {
+		var be *buildererror.Error
+		if errors.As(err, &be) {
+			status = be.Status
+		}
+		return libcnb.DetectResult{}, err
+	}
 
 	result, err := gcpd.detectFn(ctx)
 	if err != nil {
@@ -284,9 +364,39 @@ func (gcpb gcpbuilder) Build(lbctx libcnb.BuildContext) (libcnb.BuildResult, err
 	ctx.Logf("=== %s (%s@%s) ===", ctx.BuildpackName(), ctx.BuildpackID(), ctx.BuildpackVersion())
 
 	status := buildererror.StatusInternal
-	defer func(now time.Time) {
-		ctx.Span(fmt.Sprintf("Buildpack Build %s", ctx.BuildpackID()), now, status)
-	}(time.Now())
+	ctx.startPhaseSpan(fmt.Sprintf("Buildpack Build %s", ctx.BuildpackID()))
+	defer func() {
+		ctx.endPhaseSpan(status)
+	}()
+
+	if err := ctx.useEffectiveApplicationRoot(); err != nil {
+		var be *buildererror.Error
+		if errors.As(err, &be) {
+			status = be.Status
+			ctx.Exit(1, be)
+		}
+		ctx.Exit(1, buildererror.Errorf(status, err.Error()))
+	}
+
+	if err := ctx.loadSecrets(); err != nil {
+		var be *buildererror.Error
+		if errors.As(err, &be) {
+			status = be.Status
+			ctx.Exit(1, be)
+		}
+		ctx.Exit(1, buildererror.Errorf(status, err.Error()))
+	}
+
+	ctx.startProvenance()
+
+	if err := ctx.setupReproducibleBuild(); err != nil {
+		var be *buildererror.Error
+		if errors.As(err, &be) {
+			status = be.Status
+			ctx.Exit(1, be)
+		}
+		ctx.Exit(1, buildererror.Errorf(status, err.Error()))
+	}
 
 	if err := gcpb.buildFn(ctx); err != nil {
 		msg := fmt.Sprintf("Failed to run /bin/build: %v", err)
@@ -298,8 +408,18 @@ func (gcpb gcpbuilder) Build(lbctx libcnb.BuildContext) (libcnb.BuildResult, err
 		ctx.Exit(1, buildererror.Errorf(status, msg))
 	}
 
+	if err := ctx.normalizeOutputs(start); err != nil {
+		ctx.Exit(1, buildererror.Errorf(status, err.Error()))
+	}
+
+	if err := ctx.writeSBOMs(); err != nil {
+		ctx.Exit(1, buildererror.Errorf(status, err.Error()))
+	}
+	ctx.reportLayers()
+
 	status = buildererror.StatusOk
 	ctx.saveSuccessOutput(time.Since(start))
+	ctx.saveProvenance(start)
 	return ctx.buildResult, nil
 }
 
@@ -320,7 +440,7 @@ func (ctx *Context) Exit(exitCode int, be *buildererror.Error) {
 
 // Logf emits a structured logging line.
 func (ctx *Context) Logf(format string, args ...interface{}) {
-	ctx.logger.Printf(format, args...)
+	ctx.logf(severityInfo, "", format, args...)
 }
 
 // Debugf emits a structured logging line if the debug flag is set.
@@ -328,13 +448,15 @@ func (ctx *Context) Debugf(format string, args ...interface{}) {
 	if !ctx.debug {
 		return
 	}
-	ctx.Logf("DEBUG: "+format, args...)
+	ctx.logf(severityDebug, "DEBUG: ", format, args...)
 }
 
 // Warnf emits a structured logging line for warnings.
 func (ctx *Context) Warnf(format string, args ...interface{}) {
-	ctx.warnings = append(ctx.warnings, fmt.Sprintf(format, args...))
-	ctx.Logf("WARNING: "+format, args...)
+	ctx.mu.Lock()
+	ctx.warnings = append(ctx.warnings, ctx.redact(fmt.Sprintf(format, args...)))
+	ctx.mu.Unlock()
+	ctx.logf(severityWarning, "WARNING: ", format, args...)
 }
 
 // Tipf emits a structured logging line for usage tips.
@@ -347,27 +469,44 @@ func (ctx *Context) Tipf(format string, args ...interface{}) {
 
 // CacheHit records a cache hit debug message. This is used in acceptance test validation.
 func (ctx *Context) CacheHit(tag string) {
+	ctx.mu.Lock()
+	ctx.stats.cacheHits = append(ctx.stats.cacheHits, tag)
+	ctx.mu.Unlock()
 	ctx.Debugf("%s %q", cacheHitMessage, tag)
 }
 
 // CacheMiss records a cache miss debug message. This is used in acceptance test validation.
 func (ctx *Context) CacheMiss(tag string) {
+	ctx.mu.Lock()
+	ctx.stats.cacheMisses = append(ctx.stats.cacheMisses, tag)
+	ctx.mu.Unlock()
 	ctx.Debugf("%s %q", cacheMissMessage, tag)
 }
 
 // Span emits a structured Stackdriver span.
 func (ctx *Context) Span(label string, start time.Time, status buildererror.Status) {
-	now := time.Now()
-	attributes := map[string]interface{}{
+	ctx.recordSpan(label, start, ctx.spanAttributes(), status)
+}
+
+func (ctx *Context) spanAttributes() map[string]interface{} {
+	return map[string]interface{}{
 		"/buildpack_id":      ctx.BuildpackID(),
 		"/buildpack_name":    ctx.BuildpackName(),
 		"/buildpack_version": ctx.BuildpackVersion(),
 	}
-	si, err := newSpanInfo(label, start, now, attributes, status)
+}
+
+// recordSpan records a span that ends now, it returns nil if the span is invalid.
+func (ctx *Context) recordSpan(label string, start time.Time, attributes map[string]interface{}, status buildererror.Status) *spanInfo {
+	si, err := newSpanInfo(label, start, time.Now(), attributes, status)
 	if err != nil {
 		ctx.Warnf("Invalid span dropped: %v", err)
+		return nil
 	}
+	ctx.mu.Lock()
 	ctx.stats.spans = append(ctx.stats.spans, si)
+	ctx.mu.Unlock()
+	return si
 }
 
 // InstalledRuntimeVersions returns the list of runtime versions installed during build time.
@@ -381,6 +520,12 @@ func (ctx *Context) AddInstalledRuntimeVersion(version string) {
 	ctx.installedRuntimeVersions = append(ctx.installedRuntimeVersions, version)
 }
 
+// AddVulnerabilityReport adds the report of a dependency vulnerability scan to the builder output.
+func (ctx *Context) AddVulnerabilityReport(r builderoutput.VulnerabilityReport) {
+	r.BuildpackID = ctx.BuildpackID()
+	ctx.vulnerabilities = append(ctx.vulnerabilities, r)
+}
+
 // AddBOMEntry adds an entry to the bill of materials.
 func (ctx *Context) AddBOMEntry(entry libcnb.BOMEntry) {
 	if ctx.buildResult.BOM == nil {
@@ -407,6 +552,12 @@ func AsDefaultProcess() processOption {
 	return func(o *libcnb.Process) { o.Default = true }
 }
 
+// WithWorkingDirectory causes the process to be executed in the given directory, which removes the
+// need for a shell to change directories.
+func WithWorkingDirectory(dir string) processOption {
+	return func(o *libcnb.Process) { o.WorkingDirectory = dir }
+}
+
 // AddProcess adds the given command as named process, overwriting any previous process with the same name.
 func (ctx *Context) AddProcess(name string, cmd []string, opts ...processOption) {
 	current := ctx.buildResult.Processes
@@ -428,6 +579,7 @@ func (ctx *Context) AddProcess(name string, cmd []string, opts ...processOption)
 	for _, opt := range opts {
 		opt(&p)
 	}
+	ctx.wrapInApplicationRoot(&p)
 	ctx.buildResult.Processes = append(ctx.buildResult.Processes, p)
 }
 
@@ -356,6 +356,15 @@ func TestAddProcess(t *testing.T) {
 				libcnb.Process{Command: "/start", Arguments: []string{"arg1", "arg2"}, Type: "foo", Direct: true, Default: true},
 			},
 		},
+		{
+			desc: "with opts, working directory",
+			name: "foo",
+			cmd:  []string{"/layers/bin/start"},
+			opts: []processOption{AsDirectProcess(), WithWorkingDirectory("/layers/bin")},
+			want: []libcnb.Process{
+				libcnb.Process{Command: "/layers/bin/start", Type: "foo", Direct: true, WorkingDirectory: "/layers/bin"},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -0,0 +1,203 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	"github.com/buildpacks/libcnb"
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+const (
+	// httpUserAgent is the User-Agent of requests sent by buildpacks.
+	httpUserAgent = "GCPBuildpacks"
+	// httpRetries is the number of times failed requests are retried.
+	httpRetries = 3
+	// etagMetadataPrefix prefixes the URL in the layer metadata key of the ETag of a download.
+	etagMetadataPrefix = "etag:"
+)
+
+// proxyFromEnvironment selects the proxy of each request from HTTP_PROXY, HTTPS_PROXY and
+// NO_PROXY, it is a var for testing.
+var proxyFromEnvironment = http.ProxyFromEnvironment
+
+type downloadOptions struct {
+	header    http.Header
+	etagLayer *libcnb.Layer
+}
+
+// DownloadOption configures Download and DownloadBytes.
+type DownloadOption func(o *downloadOptions)
+
+// WithRequestHeader sets a header of the download request, e.g. for authorization.
+func WithRequestHeader(key, value string) DownloadOption {
+	return func(o *downloadOptions) {
+		o.header.Set(key, value)
+	}
+}
+
+// WithETagCache stores the ETag of the downloaded file in the metadata of the given layer. When the
+// file already exists it is only downloaded again if the server reports that it was modified since,
+// so the layer is expected to contain the file and to be cached.
+func WithETagCache(l *libcnb.Layer) DownloadOption {
+	return func(o *downloadOptions) {
+		o.etagLayer = l
+	}
+}
+
+// HTTPClient returns an HTTP client that retries failed requests and sends them through the proxies
+// configured in HTTP_PROXY, HTTPS_PROXY and NO_PROXY.
+func (ctx *Context) HTTPClient() *http.Client {
+	rc := retryablehttp.NewClient()
+	rc.RetryMax = httpRetries
+	// Requests are logged with the context instead.
+	rc.Logger = nil
+	if t, ok := rc.HTTPClient.Transport.(*http.Transport); ok {
+		t.Proxy = proxyFromEnvironment
+	}
+	return rc.StandardClient()
+}
+
+// Download downloads the content of a URL into the dest file, replacing it once the download
+// completes. Failures are attributed to the user, since they are typically caused by an
+// unavailable version, a network restriction or a misconfigured proxy.
+func (ctx *Context) Download(url, dest string, opts ...DownloadOption) error {
+	o := newDownloadOptions(opts)
+	etagKey := etagMetadataPrefix + url
+	if o.etagLayer != nil {
+		if etag := ctx.GetMetadata(o.etagLayer, etagKey); etag != "" {
+			exists, err := ctx.FileExists(dest)
+			if err != nil {
+				return err
+			}
+			if exists {
+				o.header.Set("If-None-Match", etag)
+			}
+		}
+	}
+
+	ctx.Debugf("Downloading %s to %s", url, dest)
+	resp, err := ctx.httpGet(url, o)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && o.header.Get("If-None-Match") != "" {
+		ctx.Debugf("%s was not modified, keeping %s", url, dest)
+		return nil
+	}
+	if err := httpStatusError(url, resp); err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(dest)
+	if err := ctx.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	// Download into a temporary file so that a failed download does not leave a partial file.
+	f, err := ioutil.TempFile(dir, filepath.Base(dest)+".download-*")
+	if err != nil {
+		return InternalErrorf("creating temporary file in %s: %v", dir, err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return UserErrorf("downloading %s: %v", url, err).WithCode(buildererror.CodeDownloadFailed)
+	}
+	if err := f.Close(); err != nil {
+		return InternalErrorf("writing %s: %v", f.Name(), err)
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return InternalErrorf("setting permissions of %s: %v", f.Name(), err)
+	}
+	if err := ctx.Rename(f.Name(), dest); err != nil {
+		return err
+	}
+
+	if o.etagLayer != nil {
+		if etag := resp.Header.Get("ETag"); etag != "" {
+			ctx.SetMetadata(o.etagLayer, etagKey, etag)
+		} else {
+			delete(o.etagLayer.Metadata, etagKey)
+		}
+	}
+	return nil
+}
+
+// DownloadBytes returns the content of a URL, e.g. a small JSON or XML document. WithETagCache
+// does not apply since the content is not stored.
+func (ctx *Context) DownloadBytes(url string, opts ...DownloadOption) ([]byte, error) {
+	ctx.Debugf("Downloading %s", url)
+	resp, err := ctx.httpGet(url, newDownloadOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := httpStatusError(url, resp); err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, UserErrorf("downloading %s: %v", url, err).WithCode(buildererror.CodeDownloadFailed)
+	}
+	return b, nil
+}
+
+func newDownloadOptions(opts []DownloadOption) downloadOptions {
+	o := downloadOptions{header: http.Header{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// httpGet sends a GET request for the URL, the caller must close the body of the response.
+func (ctx *Context) httpGet(url string, o downloadOptions) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, UserErrorf("downloading %s: %v", url, err)
+	}
+	for k, v := range o.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("User-Agent", httpUserAgent)
+	resp, err := ctx.HTTPClient().Do(req)
+	if err != nil {
+		return nil, UserErrorf("downloading %s: %v, check the network and the HTTP_PROXY, HTTPS_PROXY and NO_PROXY settings", url, err).WithCode(buildererror.CodeDownloadFailed)
+	}
+	return resp, nil
+}
+
+// httpStatusError returns an error describing an unsuccessful response.
+func httpStatusError(url string, resp *http.Response) error {
+	var be *buildererror.Error
+	switch {
+	case resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices:
+		return nil
+	case resp.StatusCode == http.StatusNotFound:
+		be = UserErrorf("downloading %s: HTTP status %q, check that the requested version exists", url, resp.Status)
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		be = UserErrorf("downloading %s: HTTP status %q, check that the build has access to the URL", url, resp.Status)
+	default:
+		be = UserErrorf("downloading %s: HTTP status %q", url, resp.Status)
+	}
+	return be.WithCode(buildererror.CodeDownloadFailed)
+}
@@ -0,0 +1,179 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestDownload(t *testing.T) {
+	var gotHeader http.Header
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header
+		fmt.Fprint(w, "content")
+	}))
+	defer svr.Close()
+	dest := filepath.Join(t.TempDir(), "dir", "file.txt")
+	ctx := NewContext()
+
+	if err := ctx.Download(svr.URL, dest, WithRequestHeader("Authorization", "Bearer token")); err != nil {
+		t.Fatalf("Download() got error: %v", err)
+	}
+
+	if got := readFile(t, dest); got != "content" {
+		t.Errorf("Download() wrote %q, want %q", got, "content")
+	}
+	if got := gotHeader.Get("Authorization"); got != "Bearer token" {
+		t.Errorf("Download() sent Authorization header %q, want %q", got, "Bearer token")
+	}
+	if got := gotHeader.Get("User-Agent"); got != httpUserAgent {
+		t.Errorf("Download() sent User-Agent header %q, want %q", got, httpUserAgent)
+	}
+}
+
+func TestDownloadETagCache(t *testing.T) {
+	requests := 0
+	var gotIfNoneMatch string
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		gotIfNoneMatch = r.Header.Get("If-None-Match")
+		if gotIfNoneMatch == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, "content")
+	}))
+	defer svr.Close()
+	ctx := NewContext(WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}))
+	l, err := ctx.Layer("download", CacheLayer)
+	if err != nil {
+		t.Fatalf("Layer() got error: %v", err)
+	}
+	dest := filepath.Join(l.Path, "file.txt")
+
+	if err := ctx.Download(svr.URL, dest, WithETagCache(l)); err != nil {
+		t.Fatalf("Download() got error: %v", err)
+	}
+	if gotIfNoneMatch != "" {
+		t.Errorf("first Download() sent If-None-Match %q, want none", gotIfNoneMatch)
+	}
+	if got := ctx.GetMetadata(l, etagMetadataPrefix+svr.URL); got != `"v1"` {
+		t.Errorf("Download() stored ETag %q, want %q", got, `"v1"`)
+	}
+
+	if err := ctx.Download(svr.URL, dest, WithETagCache(l)); err != nil {
+		t.Fatalf("Download() got error: %v", err)
+	}
+	if gotIfNoneMatch != `"v1"` {
+		t.Errorf("second Download() sent If-None-Match %q, want %q", gotIfNoneMatch, `"v1"`)
+	}
+	if got := readFile(t, dest); got != "content" {
+		t.Errorf("Download() left %q, want %q", got, "content")
+	}
+	if requests != 2 {
+		t.Errorf("Download() sent %d requests, want 2", requests)
+	}
+}
+
+func TestDownloadErrors(t *testing.T) {
+	testCases := []struct {
+		name   string
+		status int
+	}{
+		{name: "not found", status: http.StatusNotFound},
+		{name: "forbidden", status: http.StatusForbidden},
+		{name: "bad request", status: http.StatusBadRequest},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+			}))
+			defer svr.Close()
+			dest := filepath.Join(t.TempDir(), "file.txt")
+
+			err := NewContext().Download(svr.URL, dest)
+			be, ok := err.(*buildererror.Error)
+			if !ok || be.Status != buildererror.StatusUnknown {
+				t.Fatalf("Download() got error: %v, want a user error", err)
+			}
+			if got := buildererror.CodeOf(err); got != buildererror.CodeDownloadFailed {
+				t.Errorf("Download() error code = %q, want %q", got, buildererror.CodeDownloadFailed)
+			}
+			if exists, _ := NewContext().FileExists(dest); exists {
+				t.Errorf("Download() created %s after a failed download", dest)
+			}
+		})
+	}
+}
+
+func TestDownloadBytes(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"version": "1.2.3"}`)
+	}))
+	defer svr.Close()
+
+	got, err := NewContext().DownloadBytes(svr.URL)
+	if err != nil {
+		t.Fatalf("DownloadBytes() got error: %v", err)
+	}
+	if want := `{"version": "1.2.3"}`; string(got) != want {
+		t.Errorf("DownloadBytes() = %q, want %q", got, want)
+	}
+}
+
+func TestHTTPClientUsesProxy(t *testing.T) {
+	var gotURL string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotURL = r.URL.String()
+		fmt.Fprint(w, "proxied")
+	}))
+	defer proxy.Close()
+	origProxy := proxyFromEnvironment
+	proxyFromEnvironment = func(*http.Request) (*url.URL, error) {
+		return url.Parse(proxy.URL)
+	}
+	t.Cleanup(func() { proxyFromEnvironment = origProxy })
+
+	got, err := NewContext().DownloadBytes("http://example.invalid/file")
+	if err != nil {
+		t.Fatalf("DownloadBytes() got error: %v", err)
+	}
+	if string(got) != "proxied" {
+		t.Errorf("DownloadBytes() = %q, want %q", got, "proxied")
+	}
+	if gotURL != "http://example.invalid/file" {
+		t.Errorf("proxy received request for %q, want %q", gotURL, "http://example.invalid/file")
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+	return string(b)
+}
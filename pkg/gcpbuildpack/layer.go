@@ -89,6 +89,12 @@ func (ctx *Context) Layer(name string, opts ...layerOption) (*libcnb.Layer, erro
 			return nil, err
 		}
 	}
+	if l.Cache && len(l.Metadata) > 0 {
+		if ctx.restoredLayers == nil {
+			ctx.restoredLayers = map[string]bool{}
+		}
+		ctx.restoredLayers[name] = true
+	}
 	if l.Metadata == nil {
 		l.Metadata = make(map[string]interface{})
 	}
@@ -119,11 +125,17 @@ func (ctx *Context) ClearLayer(l *libcnb.Layer) error {
 		return err
 	}
 	l.Metadata = make(map[string]interface{})
+	delete(ctx.restoredLayers, l.Name)
 	return nil
 }
 
 // SetMetadata sets metadata on the layer.
+// The values of build-time secrets are redacted because layer metadata is stored in the image.
 func (ctx *Context) SetMetadata(l *libcnb.Layer, key, value string) {
+	if redacted := ctx.redact(value); redacted != value {
+		ctx.Warnf("Redacted build-time secret from metadata %q of layer %q", key, l.Name)
+		value = redacted
+	}
 	l.Metadata[key] = value
 }
 
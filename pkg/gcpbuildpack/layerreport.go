@@ -0,0 +1,155 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+const (
+	// layerReportTopDirs is the number of largest directories listed for each layer.
+	layerReportTopDirs = 5
+	// layerReportMaxDepth is the maximum depth, relative to the layer, of the listed directories.
+	layerReportMaxDepth = 3
+)
+
+// dirSize is the total size of the files in a directory and its subdirectories.
+type dirSize struct {
+	path string
+	size int64
+}
+
+// reportLayers logs the size, type and cache status of the layers created by the buildpack and
+// their largest directories if GOOGLE_LAYER_REPORT is true.
+func (ctx *Context) reportLayers() {
+	enabled, err := env.IsPresentAndTrue(env.LayerReport)
+	if err != nil {
+		ctx.Warnf("Skipping layer report: %v", err)
+		return
+	}
+	if !enabled || len(ctx.buildResult.Layers) == 0 {
+		return
+	}
+
+	ctx.Logf("Layers created by %s:", ctx.BuildpackID())
+	seen := map[string]bool{}
+	for _, c := range ctx.buildResult.Layers {
+		lc, ok := c.(layerContributor)
+		if !ok || seen[lc.l.Name] {
+			continue
+		}
+		seen[lc.l.Name] = true
+
+		var types []string
+		if lc.l.Build {
+			types = append(types, "build")
+		}
+		if lc.l.Launch {
+			types = append(types, "launch")
+		}
+		switch {
+		case !lc.l.Cache:
+		case ctx.restoredLayers[lc.l.Name]:
+			types = append(types, "cache (reused)")
+		default:
+			types = append(types, "cache (rebuilt)")
+		}
+		if len(types) == 0 {
+			types = append(types, "discarded")
+		}
+
+		size, dirs := diskUsage(lc.l.Path, layerReportMaxDepth)
+		ctx.Logf("  %s: %s, %s", lc.l.Name, byteSize(size), strings.Join(types, ", "))
+		for _, d := range largestDirs(dirs, layerReportTopDirs) {
+			ctx.Logf("    %s: %s", d.path, byteSize(d.size))
+		}
+	}
+}
+
+// layerSizes returns the size in bytes of each layer of the buildpack.
+func (ctx *Context) layerSizes() map[string]int64 {
+	sizes := map[string]int64{}
+	root := ctx.buildContext.Layers.Path
+	if root == "" {
+		return sizes
+	}
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		ctx.Debugf("Failed to list layers in %s: %v", root, err)
+		return sizes
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			sizes[e.Name()], _ = diskUsage(filepath.Join(root, e.Name()), 0)
+		}
+	}
+	return sizes
+}
+
+// diskUsage returns the total size of the regular files in root, and the total size of each of its
+// directories up to maxDepth, keyed by path relative to root. Unreadable files are ignored.
+func diskUsage(root string, maxDepth int) (int64, map[string]int64) {
+	var total int64
+	dirs := map[string]int64{}
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+		total += info.Size()
+		rel, err := filepath.Rel(root, filepath.Dir(path))
+		if err != nil || rel == "." {
+			return nil
+		}
+		parts := strings.Split(rel, string(filepath.Separator))
+		for i := 1; i <= len(parts) && i <= maxDepth; i++ {
+			dirs[filepath.Join(parts[:i]...)] += info.Size()
+		}
+		return nil
+	})
+	return total, dirs
+}
+
+// largestDirs returns the n largest directories, largest first. Directories whose size is all in
+// one of their subdirectories are skipped, the subdirectory is more informative.
+func largestDirs(dirs map[string]int64, n int) []dirSize {
+	wrappers := map[string]bool{}
+	for path, size := range dirs {
+		if parent := filepath.Dir(path); parent != "." && dirs[parent] == size {
+			wrappers[parent] = true
+		}
+	}
+	var result []dirSize
+	for path, size := range dirs {
+		if !wrappers[path] && size > 0 {
+			result = append(result, dirSize{path: path, size: size})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].size != result[j].size {
+			return result[i].size > result[j].size
+		}
+		return result[i].path < result[j].path
+	})
+	if len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
@@ -0,0 +1,131 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLargestDirs(t *testing.T) {
+	dirs := map[string]int64{
+		"lib":                         300,
+		"lib/node_modules":            300,
+		"lib/node_modules/react":      100,
+		"lib/node_modules/typescript": 200,
+		"bin":                         10,
+		"empty":                       0,
+	}
+
+	got := largestDirs(dirs, 3)
+
+	// "lib" only contains "lib/node_modules" and is skipped.
+	want := []dirSize{{"lib/node_modules", 300}, {"lib/node_modules/typescript", 200}, {"lib/node_modules/react", 100}}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(dirSize{})); diff != "" {
+		t.Errorf("largestDirs() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDiskUsage(t *testing.T) {
+	root := t.TempDir()
+	writeSizedFile(t, filepath.Join(root, "top"), 1)
+	writeSizedFile(t, filepath.Join(root, "a", "file"), 10)
+	writeSizedFile(t, filepath.Join(root, "a", "b", "file"), 100)
+	writeSizedFile(t, filepath.Join(root, "a", "b", "c", "file"), 1000)
+
+	total, dirs := diskUsage(root, 2)
+
+	if total != 1111 {
+		t.Errorf("diskUsage() total = %d, want 1111", total)
+	}
+	if diff := cmp.Diff(map[string]int64{"a": 1110, "a/b": 1100}, dirs); diff != "" {
+		t.Errorf("diskUsage() dirs mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestReportLayers(t *testing.T) {
+	layers := t.TempDir()
+	// A layer restored from cache has metadata.
+	if err := ioutil.WriteFile(filepath.Join(layers, "restored.toml"), []byte("[metadata]\nversion = \"1.0.0\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	ctx := NewContext(
+		WithBuildpackInfo(libcnb.BuildpackInfo{ID: "google.nodejs.npm"}),
+		WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layers}}),
+		WithLogger(log.New(&buf, "", 0)))
+	restored, err := ctx.Layer("restored", BuildLayer, CacheLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeSizedFile(t, filepath.Join(restored.Path, "bin", "node"), 2048)
+	modules, err := ctx.Layer("modules", LaunchLayer, CacheLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.ClearLayer(modules); err != nil {
+		t.Fatal(err)
+	}
+	writeSizedFile(t, filepath.Join(modules.Path, "node_modules", "a", "index.js"), 3*1024*1024)
+
+	t.Setenv(env.LayerReport, "true")
+	ctx.reportLayers()
+
+	want := []string{
+		"Layers created by google.nodejs.npm:",
+		"  restored: 2 KB, build, cache (reused)",
+		"    bin: 2 KB",
+		"  modules: 3 MB, launch, cache (rebuilt)",
+		"    node_modules/a: 3 MB",
+	}
+	if diff := cmp.Diff(want, strings.Split(strings.TrimSpace(buf.String()), "\n")); diff != "" {
+		t.Errorf("reportLayers() output mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestReportLayersDisabled(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := NewContext(
+		WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}),
+		WithLogger(log.New(&buf, "", 0)))
+	if _, err := ctx.Layer("layer", LaunchLayer); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx.reportLayers()
+
+	if buf.Len() != 0 {
+		t.Errorf("reportLayers() logged %q, want no output", buf.String())
+	}
+}
+
+func writeSizedFile(t *testing.T, path string, size int) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, make([]byte, size), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
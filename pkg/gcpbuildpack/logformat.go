@@ -0,0 +1,122 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+
+	// Severities of JSON log entries, as understood by Cloud Logging.
+	severityDebug   = "DEBUG"
+	severityInfo    = "INFO"
+	severityWarning = "WARNING"
+	severityError   = "ERROR"
+)
+
+// logTime returns the timestamp of JSON log entries, it is a var for testing.
+var logTime = time.Now
+
+// logEntry is a line of the build logs in JSON format.
+type logEntry struct {
+	Timestamp   string `json:"timestamp"`
+	Severity    string `json:"severity"`
+	BuildpackID string `json:"buildpackId,omitempty"`
+	Phase       string `json:"phase,omitempty"`
+	Message     string `json:"message"`
+	// Command, DurationMs and ExitCode are set for command executions and their output.
+	Command    string `json:"command,omitempty"`
+	DurationMs *int64 `json:"durationMs,omitempty"`
+	ExitCode   *int   `json:"exitCode,omitempty"`
+}
+
+// jsonLogFormat returns true if the build logs must be emitted as JSON.
+func jsonLogFormat() (bool, error) {
+	switch f := strings.ToLower(strings.TrimSpace(os.Getenv(env.BuildLogFormat))); f {
+	case "", logFormatText:
+		return false, nil
+	case logFormatJSON:
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid %s %q, must be one of %q or %q", env.BuildLogFormat, f, logFormatText, logFormatJSON)
+	}
+}
+
+// logf emits a logging line. In text format, the line is prefixed with textPrefix, in JSON format
+// the severity is recorded instead.
+func (ctx *Context) logf(severity, textPrefix, format string, args ...interface{}) {
+	if !ctx.jsonLogs {
+		ctx.logger.Print(ctx.redact(fmt.Sprintf(textPrefix+format, args...)))
+		return
+	}
+	ctx.logJSON(logEntry{Severity: severity, Message: fmt.Sprintf(format, args...)})
+}
+
+// logJSON emits the entry as a single line of JSON, filling in the common fields.
+func (ctx *Context) logJSON(e logEntry) {
+	e.Timestamp = logTime().UTC().Format(time.RFC3339Nano)
+	e.BuildpackID = ctx.BuildpackID()
+	e.Phase = ctx.phase
+	e.Message = ctx.redact(e.Message)
+	e.Command = ctx.redact(e.Command)
+	b, err := json.Marshal(e)
+	if err != nil {
+		// Marshalling strings and numbers does not fail, fall back to text just in case.
+		ctx.logger.Printf("%s: %s", e.Severity, e.Message)
+		return
+	}
+	ctx.logger.Print(string(b))
+}
+
+// logExec emits the completion of a command in JSON format. Commands that are not logged in text
+// format are emitted with debug severity.
+func (ctx *Context) logExec(params execParams, result *ExecResult, err error, duration time.Duration) {
+	severity := severityInfo
+	if !params.userFailure && !ctx.debug {
+		severity = severityDebug
+	}
+	if err != nil {
+		severity = severityError
+	}
+	ms := duration.Milliseconds()
+	e := logEntry{
+		Severity:   severity,
+		Message:    fmt.Sprintf("%sDone %q", params.logPrefix, strings.Join(params.cmd, " ")),
+		Command:    strings.Join(params.cmd, " "),
+		DurationMs: &ms,
+	}
+	if result != nil {
+		e.ExitCode = &result.ExitCode
+	}
+	ctx.logJSON(e)
+}
+
+// newJSONLogWriter returns a lineWriter that emits each line of the output of the command as a
+// JSON log entry.
+func (ctx *Context) newJSONLogWriter(params execParams) *lineWriter {
+	cmd := strings.Join(params.cmd, " ")
+	return &lineWriter{emit: func(line []byte) {
+		ctx.logJSON(logEntry{Severity: severityInfo, Message: params.logPrefix + string(line), Command: cmd})
+	}}
+}
@@ -0,0 +1,144 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestJSONLogFormat(t *testing.T) {
+	testCases := []struct {
+		value   string
+		want    bool
+		wantErr bool
+	}{
+		{value: "", want: false},
+		{value: "text", want: false},
+		{value: "json", want: true},
+		{value: " JSON ", want: true},
+		{value: "yaml", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			t.Setenv(env.BuildLogFormat, tc.value)
+
+			got, err := jsonLogFormat()
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("jsonLogFormat() got error: %v, want error? %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("jsonLogFormat() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLogfText(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := NewContext(WithLogger(log.New(&buf, "", 0)))
+	ctx.debug = true
+
+	ctx.Logf("Installing %s", "express")
+	ctx.Warnf("Retaining %s", "devDependencies")
+	ctx.Debugf("cache %s", "hit")
+
+	want := "Installing express\nWARNING: Retaining devDependencies\nDEBUG: cache hit\n"
+	if got := buf.String(); got != want {
+		t.Errorf("logs = %q, want %q", got, want)
+	}
+}
+
+func TestLogfJSON(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := jsonLogContext(t, &buf)
+
+	ctx.Logf("Installing %s", "express")
+	ctx.Warnf("Retaining %s", "devDependencies")
+	ctx.Debugf("cache %s", "hit")
+
+	want := []logEntry{
+		{Timestamp: "2023-06-01T12:00:00Z", Severity: "INFO", BuildpackID: "google.nodejs.npm", Phase: "build", Message: "Installing express"},
+		{Timestamp: "2023-06-01T12:00:00Z", Severity: "WARNING", BuildpackID: "google.nodejs.npm", Phase: "build", Message: "Retaining devDependencies"},
+		{Timestamp: "2023-06-01T12:00:00Z", Severity: "DEBUG", BuildpackID: "google.nodejs.npm", Phase: "build", Message: "cache hit"},
+	}
+	if diff := cmp.Diff(want, decodeLogEntries(t, &buf)); diff != "" {
+		t.Errorf("log entries mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestExecJSON(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := jsonLogContext(t, &buf)
+
+	ctx.Exec([]string{"/bin/bash", "-c", "echo one; echo two; exit 3"}, WithUserAttribution)
+
+	entries := decodeLogEntries(t, &buf)
+	var output []string
+	var done *logEntry
+	for i, e := range entries {
+		if e.Command == "" {
+			continue
+		}
+		if e.DurationMs != nil {
+			done = &entries[i]
+			continue
+		}
+		output = append(output, e.Message)
+	}
+	if diff := cmp.Diff([]string{"one", "two"}, output); diff != "" {
+		t.Errorf("command output mismatch (-want +got):\n%s", diff)
+	}
+	if done == nil {
+		t.Fatalf("no command execution entry in %v", entries)
+	}
+	if done.Severity != "ERROR" || done.ExitCode == nil || *done.ExitCode != 3 {
+		t.Errorf("command execution entry = %+v, want severity ERROR and exit code 3", done)
+	}
+}
+
+func jsonLogContext(t *testing.T, buf *bytes.Buffer) *Context {
+	t.Helper()
+	origTime := logTime
+	logTime = func() time.Time { return time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC) }
+	t.Cleanup(func() { logTime = origTime })
+
+	ctx := NewContext(WithLogger(log.New(buf, "", 0)), WithBuildpackInfo(libcnb.BuildpackInfo{ID: "google.nodejs.npm"}))
+	ctx.debug = true
+	ctx.jsonLogs = true
+	ctx.phase = "build"
+	return ctx
+}
+
+func decodeLogEntries(t *testing.T, buf *bytes.Buffer) []logEntry {
+	t.Helper()
+	var entries []logEntry
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var e logEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("log line %q is not JSON: %v", line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
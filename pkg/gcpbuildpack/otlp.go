@@ -0,0 +1,261 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+const (
+	// traceparentEnv holds the W3C trace context (https://www.w3.org/TR/trace-context/) of the
+	// build, the exported spans are part of its trace.
+	traceparentEnv = "TRACEPARENT"
+	// otlpTracesPath is appended to GOOGLE_BUILD_OTLP_ENDPOINT, as with OTEL_EXPORTER_OTLP_ENDPOINT.
+	otlpTracesPath = "/v1/traces"
+	// otlpScope is the instrumentation scope of the exported spans.
+	otlpScope = "github.com/GoogleCloudPlatform/buildpacks"
+
+	otlpSpanKindInternal = 1
+	otlpStatusOk         = 1
+	otlpStatusError      = 2
+)
+
+var (
+	traceparentRegexp = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-([0-9a-f]{16})-[0-9a-f]{2}$`)
+	// otlpClient sends traces, a slow collector must not hold up the build.
+	otlpClient = &http.Client{Timeout: 10 * time.Second}
+)
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScopeInfo `json:"scope"`
+	Spans []otlpSpan    `json:"spans"`
+}
+
+type otlpScopeInfo struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+// otlpValue is an OTLP AnyValue, 64-bit integers are encoded as strings in OTLP/JSON.
+type otlpValue struct {
+	StringValue *string         `json:"stringValue,omitempty"`
+	IntValue    *string         `json:"intValue,omitempty"`
+	ArrayValue  *otlpArrayValue `json:"arrayValue,omitempty"`
+}
+
+type otlpArrayValue struct {
+	Values []otlpValue `json:"values"`
+}
+
+// tracesEnabled returns true if traces are exported to an OTLP endpoint.
+func tracesEnabled() bool {
+	return os.Getenv(env.BuildOTLPEndpoint) != ""
+}
+
+// exportTrace sends the recorded spans to GOOGLE_BUILD_OTLP_ENDPOINT, if set. The trace is
+// exported at most once, failures are logged as warnings and do not fail the build.
+func (ctx *Context) exportTrace() {
+	endpoint := os.Getenv(env.BuildOTLPEndpoint)
+	if endpoint == "" || ctx.traceExported {
+		return
+	}
+	ctx.traceExported = true
+
+	ctx.mu.Lock()
+	traces := ctx.otlpTraces(os.Getenv(traceparentEnv))
+	ctx.mu.Unlock()
+	body, err := json.Marshal(traces)
+	if err != nil {
+		ctx.Warnf("Failed to encode build trace: %v", err)
+		return
+	}
+	url := strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(url, otlpTracesPath) {
+		url += otlpTracesPath
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		ctx.Warnf("Failed to export build trace to %s: %v", url, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range ctx.otlpHeaders() {
+		req.Header.Set(k, v)
+	}
+	resp, err := otlpClient.Do(req)
+	if err != nil {
+		ctx.Warnf("Failed to export build trace to %s: %v", url, err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		ctx.Warnf("Failed to export build trace to %s: HTTP status %d", url, resp.StatusCode)
+		return
+	}
+	ctx.Debugf("Exported %d spans to %s.", len(traces.ResourceSpans[0].ScopeSpans[0].Spans), url)
+}
+
+// otlpHeaders returns the headers set in GOOGLE_BUILD_OTLP_HEADERS, which may be a build-time
+// secret.
+func (ctx *Context) otlpHeaders() map[string]string {
+	value, ok := ctx.Secret(env.BuildOTLPHeaders)
+	if !ok {
+		value = os.Getenv(env.BuildOTLPHeaders)
+	}
+	headers := map[string]string{}
+	for _, h := range strings.Split(value, ",") {
+		parts := strings.SplitN(h, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			continue
+		}
+		headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return headers
+}
+
+// otlpTraces converts the recorded spans to an OTLP trace. The spans are children of the phase
+// span, which is a child of the span in traceparent if it is a valid W3C trace context.
+func (ctx *Context) otlpTraces(traceparent string) otlpTraces {
+	traceID, parentID := randomHex(16), ""
+	if m := traceparentRegexp.FindStringSubmatch(traceparent); m != nil {
+		traceID, parentID = m[1], m[2]
+	}
+	phaseID := ""
+	for _, si := range ctx.stats.spans {
+		if si.phase {
+			phaseID = randomHex(8)
+		}
+	}
+
+	var spans []otlpSpan
+	for _, si := range ctx.stats.spans {
+		span := otlpSpan{
+			TraceID:           traceID,
+			SpanID:            randomHex(8),
+			ParentSpanID:      parentID,
+			Name:              si.name,
+			Kind:              otlpSpanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(si.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(si.end.UnixNano(), 10),
+			Attributes:        otlpAttributes(si.attributes),
+			Status:            otlpStatus{Code: otlpStatusOk},
+		}
+		if si.phase {
+			span.SpanID = phaseID
+		} else if phaseID != "" {
+			span.ParentSpanID = phaseID
+		}
+		if si.status != buildererror.StatusOk {
+			span.Status = otlpStatus{Code: otlpStatusError, Message: si.status.String()}
+		}
+		spans = append(spans, span)
+	}
+
+	return otlpTraces{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: otlpAttributes(map[string]interface{}{
+			"service.name":      "buildpacks",
+			"buildpack.id":      ctx.BuildpackID(),
+			"buildpack.version": ctx.BuildpackVersion(),
+			"buildpack.phase":   ctx.phase,
+		})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScopeInfo{Name: otlpScope}, Spans: spans}},
+	}}}
+}
+
+// otlpAttributes converts attributes to OTLP key-values sorted by key.
+func otlpAttributes(attributes map[string]interface{}) []otlpKeyValue {
+	var kvs []otlpKeyValue
+	for k, v := range attributes {
+		kvs = append(kvs, otlpKeyValue{Key: k, Value: otlpAnyValue(v)})
+	}
+	sort.Slice(kvs, func(i, j int) bool { return kvs[i].Key < kvs[j].Key })
+	return kvs
+}
+
+func otlpAnyValue(v interface{}) otlpValue {
+	switch t := v.(type) {
+	case int64:
+		s := strconv.FormatInt(t, 10)
+		return otlpValue{IntValue: &s}
+	case int:
+		s := strconv.Itoa(t)
+		return otlpValue{IntValue: &s}
+	case []string:
+		arr := &otlpArrayValue{Values: []otlpValue{}}
+		for _, e := range t {
+			arr.Values = append(arr.Values, otlpAnyValue(e))
+		}
+		return otlpValue{ArrayValue: arr}
+	default:
+		s := fmt.Sprint(v)
+		return otlpValue{StringValue: &s}
+	}
+}
+
+// randomHex returns n random bytes encoded as hex, as used for OTLP trace and span IDs.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	// crypto/rand only fails if the system has no source of randomness.
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
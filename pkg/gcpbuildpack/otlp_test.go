@@ -0,0 +1,160 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOTLPTraces(t *testing.T) {
+	start := time.Unix(100, 0)
+	ctx := NewContext(WithBuildpackInfo(libcnb.BuildpackInfo{ID: "google.nodejs.npm", Version: "1.0.0"}))
+	ctx.phase = "build"
+	ctx.stats.spans = []*spanInfo{
+		{name: `Exec "npm ci"`, start: start, end: start.Add(time.Second), status: buildererror.StatusOk},
+		{name: `Exec "npm run build"`, start: start.Add(time.Second), end: start.Add(2 * time.Second), status: buildererror.StatusUnknown},
+		{
+			name:       "Buildpack Build google.nodejs.npm",
+			start:      start,
+			end:        start.Add(3 * time.Second),
+			attributes: map[string]interface{}{"/cache_hits": []string{"npm"}, "/layer_size_bytes/npm_modules": int64(42)},
+			status:     buildererror.StatusUnknown,
+			phase:      true,
+		},
+	}
+
+	traces := ctx.otlpTraces("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+
+	if len(traces.ResourceSpans) != 1 || len(traces.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("otlpTraces() = %+v, want a single resource and scope", traces)
+	}
+	spans := traces.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 3 {
+		t.Fatalf("otlpTraces() got %d spans, want 3", len(spans))
+	}
+	phase := spans[2]
+	for i, span := range spans {
+		if span.TraceID != "0af7651916cd43dd8448eb211c80319c" {
+			t.Errorf("span %d traceId = %q, want the trace of TRACEPARENT", i, span.TraceID)
+		}
+		if len(span.SpanID) != 16 {
+			t.Errorf("span %d spanId = %q, want 16 hex digits", i, span.SpanID)
+		}
+	}
+	if phase.ParentSpanID != "b7ad6b7169203331" {
+		t.Errorf("phase span parentSpanId = %q, want the span of TRACEPARENT", phase.ParentSpanID)
+	}
+	for _, span := range spans[:2] {
+		if span.ParentSpanID != phase.SpanID {
+			t.Errorf("span %q parentSpanId = %q, want the phase span %q", span.Name, span.ParentSpanID, phase.SpanID)
+		}
+	}
+	if got, want := spans[0].StartTimeUnixNano, "100000000000"; got != want {
+		t.Errorf("span startTimeUnixNano = %q, want %q", got, want)
+	}
+	if diff := cmp.Diff(otlpStatus{Code: otlpStatusOk}, spans[0].Status); diff != "" {
+		t.Errorf("successful span status mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(otlpStatus{Code: otlpStatusError, Message: "UNKNOWN"}, spans[1].Status); diff != "" {
+		t.Errorf("failed span status mismatch (-want +got):\n%s", diff)
+	}
+
+	b, err := json.Marshal(phase.Attributes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"key":"/cache_hits","value":{"arrayValue":{"values":[{"stringValue":"npm"}]}}},{"key":"/layer_size_bytes/npm_modules","value":{"intValue":"42"}}]`
+	if string(b) != want {
+		t.Errorf("phase span attributes = %s, want %s", b, want)
+	}
+}
+
+func TestOTLPTracesWithoutTraceparent(t *testing.T) {
+	start := time.Unix(100, 0)
+	ctx := NewContext()
+	ctx.stats.spans = []*spanInfo{{name: "Exec", start: start, end: start}}
+
+	spans := ctx.otlpTraces("invalid").ResourceSpans[0].ScopeSpans[0].Spans
+
+	if len(spans[0].TraceID) != 32 || spans[0].ParentSpanID != "" {
+		t.Errorf("otlpTraces() span = %+v, want a new trace without parent", spans[0])
+	}
+}
+
+func TestExportTrace(t *testing.T) {
+	var gotPath, gotAuth string
+	var got otlpTraces
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		gotPath, gotAuth = r.URL.Path, r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv(env.BuildOTLPEndpoint, server.URL+"/")
+	t.Setenv(env.BuildOTLPHeaders, "authorization=Bearer token, x-empty")
+	layers := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(layers, "deps", "lib"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(layers, "deps", "lib", "file"), make([]byte, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx := NewContext(WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layers}}))
+
+	ctx.startPhaseSpan("Buildpack Build")
+	ctx.CacheMiss("deps")
+	ctx.endPhaseSpan(buildererror.StatusOk)
+	// The trace is only exported once, e.g. when Exit is called before the deferred functions run.
+	ctx.endPhaseSpan(buildererror.StatusOk)
+
+	if requests != 1 {
+		t.Fatalf("exported trace %d times, want 1", requests)
+	}
+	if gotPath != "/v1/traces" {
+		t.Errorf("exported trace to %q, want %q", gotPath, "/v1/traces")
+	}
+	if gotAuth != "Bearer token" {
+		t.Errorf("exported trace with authorization %q, want %q", gotAuth, "Bearer token")
+	}
+	spans := got.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 1 {
+		t.Fatalf("exported %d spans, want 1", len(spans))
+	}
+	attributes := map[string]otlpValue{}
+	for _, kv := range spans[0].Attributes {
+		attributes[kv.Key] = kv.Value
+	}
+	if v := attributes["/layer_size_bytes/deps"].IntValue; v == nil || *v != "100" {
+		t.Errorf("exported layer size = %v, want 100", v)
+	}
+	if v := attributes["/cache_misses"].ArrayValue; v == nil || len(v.Values) != 1 || *v.Values[0].StringValue != "deps" {
+		t.Errorf("exported cache misses = %+v, want [deps]", v)
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// defaultOutputLimit is the maximum number of bytes of each output stream of a command that is kept
+// in memory and logged. Commands that print more than this are most likely stuck in a loop.
+const defaultOutputLimit = 20 * 1024 * 1024
+
+// cappedBuffer is an io.Writer that keeps at most limit bytes of a stream: the first half and the
+// last half. Anything in between is dropped and replaced by a truncation marker in String().
+// cappedBuffer is not safe for concurrent use.
+type cappedBuffer struct {
+	limit int
+	head  []byte
+
+	// ring holds the tail of the stream once the head is full. It is allocated lazily so that small
+	// outputs do not pay for it.
+	ring    []byte
+	ringPos int
+	ringLen int
+
+	total int64
+}
+
+func newCappedBuffer(limit int) *cappedBuffer {
+	if limit < 2 {
+		limit = 2
+	}
+	return &cappedBuffer{limit: limit}
+}
+
+func (b *cappedBuffer) headLimit() int {
+	return b.limit - b.limit/2
+}
+
+// headRoom returns the number of bytes that can still be written before the head is full.
+func (b *cappedBuffer) headRoom() int {
+	return b.headLimit() - len(b.head)
+}
+
+// Write implements io.Writer. It never fails.
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	b.total += int64(n)
+
+	if room := b.headRoom(); room > 0 {
+		if room > len(p) {
+			room = len(p)
+		}
+		b.head = append(b.head, p[:room]...)
+		p = p[room:]
+	}
+	if len(p) == 0 {
+		return n, nil
+	}
+
+	if b.ring == nil {
+		b.ring = make([]byte, b.limit/2)
+	}
+	size := len(b.ring)
+	if len(p) >= size {
+		copy(b.ring, p[len(p)-size:])
+		b.ringPos, b.ringLen = 0, size
+		return n, nil
+	}
+	if c := copy(b.ring[b.ringPos:], p); c < len(p) {
+		copy(b.ring, p[c:])
+	}
+	b.ringPos = (b.ringPos + len(p)) % size
+	if b.ringLen += len(p); b.ringLen > size {
+		b.ringLen = size
+	}
+	return n, nil
+}
+
+// tail returns the kept tail of the stream in order.
+func (b *cappedBuffer) tail() []byte {
+	if b.ringLen < len(b.ring) {
+		return b.ring[:b.ringLen]
+	}
+	return append(append([]byte{}, b.ring[b.ringPos:]...), b.ring[:b.ringPos]...)
+}
+
+// truncated returns the number of bytes of the stream that were dropped.
+func (b *cappedBuffer) truncated() int64 {
+	return b.total - int64(len(b.head)) - int64(b.ringLen)
+}
+
+// String returns the kept output. If the stream exceeded the limit, the head and the tail are
+// separated by a marker with the size of the dropped output, and both are cut at UTF-8 character
+// boundaries.
+func (b *cappedBuffer) String() string {
+	if b.truncated() == 0 {
+		return string(b.head) + string(b.tail())
+	}
+	head, tail := trimIncompleteRuneSuffix(b.head), trimIncompleteRunePrefix(b.tail())
+	dropped := b.total - int64(len(head)) - int64(len(tail))
+	return fmt.Sprintf("%s\n%s\n%s", head, truncationMarker(dropped), tail)
+}
+
+// truncationMarker returns the marker that replaces the given number of dropped bytes.
+func truncationMarker(dropped int64) string {
+	return fmt.Sprintf("[... %s truncated ...]", byteSize(dropped))
+}
+
+// byteSize formats a number of bytes for humans, rounding down.
+func byteSize(n int64) string {
+	const kb, mb = 1024, 1024 * 1024
+	switch {
+	case n >= mb:
+		return fmt.Sprintf("%d MB", n/mb)
+	case n >= kb:
+		return fmt.Sprintf("%d KB", n/kb)
+	default:
+		return fmt.Sprintf("%d bytes", n)
+	}
+}
+
+// trimIncompleteRuneSuffix drops a multi-byte UTF-8 sequence that was cut off at the end of p.
+func trimIncompleteRuneSuffix(p []byte) []byte {
+	for i := 1; i < utf8.UTFMax && i <= len(p); i++ {
+		c := p[len(p)-i]
+		if utf8.RuneStart(c) {
+			if !utf8.FullRune(p[len(p)-i:]) {
+				return p[:len(p)-i]
+			}
+			return p
+		}
+	}
+	return p
+}
+
+// trimIncompleteRunePrefix drops the continuation bytes of a UTF-8 sequence that was cut off at the
+// start of p.
+func trimIncompleteRunePrefix(p []byte) []byte {
+	for i := 0; i < utf8.UTFMax && i < len(p); i++ {
+		if utf8.RuneStart(p[i]) {
+			return p[i:]
+		}
+	}
+	return p
+}
+
+// lineWriter is an io.Writer that calls emit with each complete line written to it, without the
+// line terminator. Partial lines are held until they are completed or flush is called, so that the
+// lines of concurrent commands do not mix.
+type lineWriter struct {
+	emit func(line []byte)
+	buf  []byte
+}
+
+// newPrefixWriter returns a lineWriter that writes each line to w prefixed with prefix.
+func newPrefixWriter(w io.Writer, prefix string) *lineWriter {
+	return &lineWriter{emit: func(line []byte) {
+		w.Write(append(append([]byte(prefix), line...), '\n'))
+	}}
+}
+
+// Write implements io.Writer. It never fails.
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	lw.buf = append(lw.buf, p...)
+	for {
+		i := bytes.IndexByte(lw.buf, '\n')
+		if i < 0 {
+			break
+		}
+		lw.emit(lw.buf[:i])
+		lw.buf = lw.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush emits the pending partial line, if any.
+func (lw *lineWriter) flush() {
+	if len(lw.buf) == 0 {
+		return
+	}
+	lw.emit(lw.buf)
+	lw.buf = nil
+}
@@ -0,0 +1,270 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildermetrics"
+)
+
+func TestCappedBuffer(t *testing.T) {
+	testCases := []struct {
+		name   string
+		limit  int
+		writes []string
+		want   string
+	}{
+		{
+			name:   "empty",
+			limit:  10,
+			writes: nil,
+			want:   "",
+		},
+		{
+			name:   "below limit",
+			limit:  10,
+			writes: []string{"abc", "def"},
+			want:   "abcdef",
+		},
+		{
+			name:   "exactly at limit",
+			limit:  10,
+			writes: []string{"0123456789"},
+			want:   "0123456789",
+		},
+		{
+			name:   "exactly at limit in small writes",
+			limit:  10,
+			writes: []string{"012", "345", "678", "9"},
+			want:   "0123456789",
+		},
+		{
+			name:   "one byte over limit",
+			limit:  10,
+			writes: []string{"0123456789x"},
+			want:   "01234\n[... 1 bytes truncated ...]\n6789x",
+		},
+		{
+			name:   "tail wraps around in small writes",
+			limit:  10,
+			writes: []string{"01234", "abc", "def", "ghi", "jkl"},
+			want:   "01234\n[... 7 bytes truncated ...]\nhijkl",
+		},
+		{
+			name:   "single write larger than tail",
+			limit:  10,
+			writes: []string{"01", "23456789abcdefghijklmnop"},
+			want:   "01234\n[... 16 bytes truncated ...]\nlmnop",
+		},
+		{
+			name:   "odd limit keeps larger head",
+			limit:  5,
+			writes: []string{"abcdefgh"},
+			want:   "abc\n[... 3 bytes truncated ...]\ngh",
+		},
+		{
+			name:   "multi-byte rune cut at end of head",
+			limit:  8,
+			writes: []string{"abc€" + strings.Repeat("x", 10)},
+			want:   "abc\n[... 9 bytes truncated ...]\nxxxx",
+		},
+		{
+			name:   "multi-byte rune cut at start of tail",
+			limit:  8,
+			writes: []string{strings.Repeat("x", 10) + "€yy"},
+			want:   "xxxx\n[... 9 bytes truncated ...]\nyy",
+		},
+		{
+			name:   "complete multi-byte rune at both ends",
+			limit:  8,
+			writes: []string{"a€" + strings.Repeat("x", 10) + "€b"},
+			want:   "a€\n[... 10 bytes truncated ...]\n€b",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := newCappedBuffer(tc.limit)
+			for _, w := range tc.writes {
+				if n, err := b.Write([]byte(w)); n != len(w) || err != nil {
+					t.Fatalf("Write(%q) = %d, %v, want %d, nil", w, n, err, len(w))
+				}
+			}
+			got := b.String()
+			if got != tc.want {
+				t.Errorf("String() = %q, want %q", got, tc.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("String() = %q is not valid UTF-8", got)
+			}
+		})
+	}
+}
+
+func TestTruncationMarker(t *testing.T) {
+	testCases := []struct {
+		dropped int64
+		want    string
+	}{
+		{dropped: 1, want: "[... 1 bytes truncated ...]"},
+		{dropped: 1023, want: "[... 1023 bytes truncated ...]"},
+		{dropped: 1024, want: "[... 1 KB truncated ...]"},
+		{dropped: 5*1024*1024 + 1, want: "[... 5 MB truncated ...]"},
+	}
+	for _, tc := range testCases {
+		if got := truncationMarker(tc.dropped); got != tc.want {
+			t.Errorf("truncationMarker(%d) = %q, want %q", tc.dropped, got, tc.want)
+		}
+	}
+}
+
+func TestExecTruncatesOutput(t *testing.T) {
+	ctx, cleanUp := simpleContext(t)
+	defer cleanUp()
+	counter := buildermetrics.GlobalBuilderMetrics().GetCounter(buildermetrics.ExecOutputTruncatedCounterID)
+	before := counter.Value()
+
+	cmd := []string{"/bin/bash", "-c", "echo start; for i in $(seq 1 1000); do echo line $i; done; echo failed >&2; exit 1"}
+	result, err := ctx.Exec(cmd, WithOutputLimit(200), WithUserAttribution)
+	if err == nil {
+		t.Fatalf("Exec(%v) got err=nil, want error", cmd)
+	}
+
+	// Stdout and stderr are copied concurrently, so the stderr line may be anywhere in the combined
+	// output.
+	combined := strings.Replace(result.Combined, "failed\n", "", 1)
+	if combined == result.Combined {
+		combined = strings.TrimSuffix(result.Combined, "\nfailed")
+	}
+	for name, out := range map[string]string{"stdout": result.Stdout, "combined": combined} {
+		if !strings.HasPrefix(out, "start\nline 1\n") {
+			t.Errorf("%s = %q, want the head of the output", name, out)
+		}
+		if !strings.Contains(out, "KB truncated ...]") {
+			t.Errorf("%s = %q, want a truncation marker", name, out)
+		}
+		if !strings.HasSuffix(out, "line 1000") {
+			t.Errorf("%s = %q, want the tail of the output", name, out)
+		}
+		if len(out) > 250 {
+			t.Errorf("len(%s) = %d, want at most the limit plus the marker", name, len(out))
+		}
+	}
+	if result.Stderr != "failed" {
+		t.Errorf("stderr = %q, want %q", result.Stderr, "failed")
+	}
+	if !strings.Contains(err.Error(), "truncated ...]") {
+		t.Errorf("error = %q, want the truncated output", err.Error())
+	}
+	if got := counter.Value() - before; got != 1 {
+		t.Errorf("%s counter increased by %d, want 1", buildermetrics.ExecOutputTruncatedCounterID, got)
+	}
+}
+
+func TestExecRedactsTruncatedOutput(t *testing.T) {
+	const secret = "zq9xk7wvmj"
+	ctx := secretsContext(t, map[string]string{"NPM_TOKEN": secret}, nil)
+
+	// Every line contains the secret, so the head and the tail of the truncated output are cut
+	// within a line that contains it.
+	cmd := []string{"/bin/bash", "-c", "echo start $NPM_TOKEN; for i in $(seq 1 1000); do echo line $i $NPM_TOKEN; done; echo failed $NPM_TOKEN >&2; exit 1"}
+	result, err := ctx.Exec(cmd, WithOutputLimit(200), WithUserAttribution)
+	if err == nil {
+		t.Fatalf("Exec(%v) got err=nil, want error", cmd)
+	}
+
+	if want := "start " + redactedSecret + "\nline 1 " + redactedSecret + "\n"; !strings.HasPrefix(result.Stdout, want) {
+		t.Errorf("stdout = %q, want prefix %q", result.Stdout, want)
+	}
+	if want := "line 1000 " + redactedSecret; !strings.HasSuffix(result.Stdout, want) {
+		t.Errorf("stdout = %q, want suffix %q", result.Stdout, want)
+	}
+	if want := "failed " + redactedSecret; result.Stderr != want {
+		t.Errorf("stderr = %q, want %q", result.Stderr, want)
+	}
+	outputs := map[string]string{
+		"stdout":   result.Stdout,
+		"stderr":   result.Stderr,
+		"combined": result.Combined,
+		"error":    err.Error(),
+	}
+	for name, out := range outputs {
+		if name != "stderr" && !strings.Contains(out, "KB truncated ...]") {
+			t.Errorf("%s = %q, want a truncation marker", name, out)
+		}
+		// Parts of the secret would be left if it were redacted after the output was truncated.
+		for i := 0; i+3 <= len(secret); i++ {
+			if part := secret[i : i+3]; strings.Contains(out, part) {
+				t.Errorf("%s = %q, contains %q of the secret", name, out, part)
+			}
+		}
+	}
+}
+
+func TestPrefixWriter(t *testing.T) {
+	testCases := []struct {
+		name   string
+		writes []string
+		// wantBeforeFlush is written before flush is called.
+		wantBeforeFlush string
+		want            string
+	}{
+		{
+			name:            "partial lines",
+			writes:          []string{"added 10 pack", "ages\nup to date\nfound 0 vulnerabilities"},
+			wantBeforeFlush: "[npm] added 10 packages\n[npm] up to date\n",
+			want:            "[npm] added 10 packages\n[npm] up to date\n[npm] found 0 vulnerabilities\n",
+		},
+		{
+			name:            "partial line is held",
+			writes:          []string{"added 10 pack"},
+			wantBeforeFlush: "",
+			want:            "[npm] added 10 pack\n",
+		},
+		{
+			name:            "terminated lines",
+			writes:          []string{"up to date\n", "\n"},
+			wantBeforeFlush: "[npm] up to date\n[npm] \n",
+			want:            "[npm] up to date\n[npm] \n",
+		},
+		{
+			name: "no output",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			pw := newPrefixWriter(&buf, "[npm] ")
+
+			for _, w := range tc.writes {
+				if n, err := pw.Write([]byte(w)); n != len(w) || err != nil {
+					t.Fatalf("Write(%q) = %d, %v, want %d, nil", w, n, err, len(w))
+				}
+			}
+			if got := buf.String(); got != tc.wantBeforeFlush {
+				t.Errorf("prefixWriter wrote %q before flush, want %q", got, tc.wantBeforeFlush)
+			}
+			pw.flush()
+			// A second flush has nothing left to write.
+			pw.flush()
+			if got := buf.String(); got != tc.want {
+				t.Errorf("prefixWriter wrote %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
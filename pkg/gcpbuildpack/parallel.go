@@ -0,0 +1,105 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ParallelCmd is a command run concurrently with others by Parallel.
+type ParallelCmd struct {
+	// Name identifies the command in the logs, e.g. "jdk" or "dependencies".
+	Name string
+	// Cmd is the command and its arguments, as passed to Exec.
+	Cmd []string
+	// Opts configures the command, as passed to Exec.
+	Opts []ExecOption
+}
+
+// Parallel runs independent commands concurrently and waits for all of them to complete. It is
+// meant to overlap network-bound steps, e.g. downloading a runtime while resolving dependencies.
+//
+// Each logged line of a command is prefixed with its name. The results are returned in the order
+// of cmds, along with the error of the first command in that order that failed. Commands with
+// user timing attribution are accounted for the time during which at least one of them was
+// running, rather than the sum of their durations.
+func (ctx *Context) Parallel(cmds ...ParallelCmd) ([]*ExecResult, error) {
+	results := make([]*ExecResult, len(cmds))
+	errs := make([]error, len(cmds))
+	var userTime []interval
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for i, c := range cmds {
+		var params execParams
+		for _, o := range c.Opts {
+			o(&params)
+		}
+		prefix := fmt.Sprintf("[%s] ", c.Name)
+		opts := append(append([]ExecOption{}, c.Opts...), func(o *execParams) {
+			o.logPrefix = prefix
+			o.parallel = true
+		})
+
+		wg.Add(1)
+		go func(i int, cmd []string, userTiming bool, opts []ExecOption) {
+			defer wg.Done()
+			start := time.Now()
+			results[i], errs[i] = ctx.Exec(cmd, opts...)
+			if userTiming {
+				mu.Lock()
+				userTime = append(userTime, interval{start: start, end: time.Now()})
+				mu.Unlock()
+			}
+		}(i, c.Cmd, params.userTiming, opts)
+	}
+	wg.Wait()
+
+	ctx.mu.Lock()
+	ctx.stats.user += unionDuration(userTime)
+	ctx.mu.Unlock()
+
+	for _, err := range errs {
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+type interval struct {
+	start, end time.Time
+}
+
+// unionDuration returns the total duration covered by the intervals, counting overlapping
+// periods once.
+func unionDuration(intervals []interval) time.Duration {
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].start.Before(intervals[j].start) })
+	var total time.Duration
+	var end time.Time
+	for _, in := range intervals {
+		if in.start.After(end) {
+			total += in.end.Sub(in.start)
+			end = in.end
+		} else if in.end.After(end) {
+			total += in.end.Sub(end)
+			end = in.end
+		}
+	}
+	return total
+}
@@ -0,0 +1,121 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+)
+
+func TestParallel(t *testing.T) {
+	ctx, cleanUp := simpleContext(t)
+	defer cleanUp()
+
+	start := time.Now()
+	results, err := ctx.Parallel(
+		ParallelCmd{Name: "first", Cmd: []string{"/bin/bash", "-c", "sleep .5; echo one"}},
+		ParallelCmd{Name: "second", Cmd: []string{"/bin/bash", "-c", "sleep .5; echo two"}},
+	)
+	elapsed := time.Since(start)
+
+	if err != nil {
+		t.Fatalf("Parallel() got error: %v", err)
+	}
+	if got := []string{results[0].Stdout, results[1].Stdout}; got[0] != "one" || got[1] != "two" {
+		t.Errorf("Parallel() stdout = %q, want [one two]", got)
+	}
+	if elapsed >= time.Second {
+		t.Errorf("Parallel() took %v, want the commands to run concurrently", elapsed)
+	}
+	if len(ctx.stats.spans) != 2 {
+		t.Errorf("Parallel() emitted %d spans, want 2", len(ctx.stats.spans))
+	}
+}
+
+func TestParallelReturnsFirstError(t *testing.T) {
+	ctx, cleanUp := simpleContext(t)
+	defer cleanUp()
+
+	results, err := ctx.Parallel(
+		ParallelCmd{Name: "ok", Cmd: []string{"/bin/bash", "-c", "echo ok"}},
+		ParallelCmd{Name: "user", Cmd: []string{"/bin/bash", "-c", "exit 2"}, Opts: []ExecOption{WithUserAttribution}},
+		ParallelCmd{Name: "system", Cmd: []string{"/bin/bash", "-c", "exit 3"}},
+	)
+
+	if err == nil {
+		t.Fatal("Parallel() got no error, want error")
+	}
+	if be, ok := err.(*buildererror.Error); !ok || be.Status != buildererror.StatusUnknown {
+		t.Errorf("Parallel() got error %#v, want the user error of the second command", err)
+	}
+	if results[0].ExitCode != 0 || results[1].ExitCode != 2 || results[2].ExitCode != 3 {
+		t.Errorf("Parallel() exit codes = [%d %d %d], want [0 2 3]", results[0].ExitCode, results[1].ExitCode, results[2].ExitCode)
+	}
+}
+
+func TestParallelUserTiming(t *testing.T) {
+	ctx, cleanUp := simpleContext(t)
+	defer cleanUp()
+
+	ctx.Parallel(
+		ParallelCmd{Name: "a", Cmd: strings.Fields("sleep .5"), Opts: []ExecOption{WithUserTimingAttribution}},
+		ParallelCmd{Name: "b", Cmd: strings.Fields("sleep .5"), Opts: []ExecOption{WithUserAttribution}},
+		ParallelCmd{Name: "system", Cmd: strings.Fields("sleep .1")},
+	)
+
+	// The two user commands overlap, so the user time is close to the duration of one of them.
+	if got := ctx.stats.user; got < 500*time.Millisecond || got >= time.Second {
+		t.Errorf("user duration = %v, want between 500ms and 1s", got)
+	}
+}
+
+func TestUnionDuration(t *testing.T) {
+	t0 := time.Now()
+	at := func(s int) time.Time { return t0.Add(time.Duration(s) * time.Second) }
+	testCases := []struct {
+		name      string
+		intervals []interval
+		want      time.Duration
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:      "disjoint",
+			intervals: []interval{{at(5), at(6)}, {at(0), at(2)}},
+			want:      3 * time.Second,
+		},
+		{
+			name:      "overlapping",
+			intervals: []interval{{at(0), at(3)}, {at(1), at(4)}},
+			want:      4 * time.Second,
+		},
+		{
+			name:      "nested",
+			intervals: []interval{{at(0), at(10)}, {at(2), at(3)}, {at(4), at(12)}},
+			want:      12 * time.Second,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := unionDuration(tc.intervals); got != tc.want {
+				t.Errorf("unionDuration() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
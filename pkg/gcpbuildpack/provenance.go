@@ -0,0 +1,205 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/builderoutput"
+)
+
+// provenanceEnvPrefix is the prefix of the environment variables recorded in the provenance.
+const provenanceEnvPrefix = "GOOGLE_"
+
+// startProvenance computes the hash of the application source if this is the first buildpack to
+// run, before it makes any change to the source.
+func (ctx *Context) startProvenance() {
+	outputDir := os.Getenv(builderOutputEnv)
+	if outputDir == "" {
+		return
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, builderoutput.ProvenanceFilename)); err == nil {
+		return
+	}
+	digest, err := dirHash(ctx.buildContext.Application.Path)
+	if err != nil {
+		ctx.Warnf("Failed to hash the source, skipping it in the provenance: %v", err)
+		return
+	}
+	ctx.sourceDigest = digest
+}
+
+// saveProvenance adds the buildpack versions, configuration and installed dependencies of the
+// buildpack to the SLSA provenance statement in BUILDER_OUTPUT.
+func (ctx *Context) saveProvenance(start time.Time) {
+	outputDir := os.Getenv(builderOutputEnv)
+	if outputDir == "" {
+		return
+	}
+	fname := filepath.Join(outputDir, builderoutput.ProvenanceFilename)
+
+	s := builderoutput.NewStatement()
+	// Previous buildpacks have already written their part of the provenance.
+	if content, err := ioutil.ReadFile(fname); err == nil {
+		if s, err = builderoutput.StatementFromJSON(content); err != nil {
+			ctx.Warnf("Failed to unmarshal %s, skipping provenance: %v", fname, err)
+			return
+		}
+	} else if !os.IsNotExist(err) {
+		ctx.Warnf("Failed to read %s, skipping provenance: %v", fname, err)
+		return
+	}
+
+	if ctx.sourceDigest != "" {
+		s.Predicate.BuildDefinition.ExternalParameters.Source = builderoutput.ResourceDescriptor{
+			Name:   "source",
+			Digest: map[string]string{"dirHash": ctx.sourceDigest},
+		}
+	}
+	if s.Predicate.BuildDefinition.InternalParameters.StackID == "" {
+		s.Predicate.BuildDefinition.InternalParameters.StackID = ctx.StackID()
+	}
+	s.AddBuildpackRun(builderoutput.BuildpackRun{
+		ID:           ctx.BuildpackID(),
+		Version:      ctx.BuildpackVersion(),
+		Env:          ctx.provenanceEnv(),
+		Dependencies: ctx.provenanceDependencies(),
+		StartedOn:    start,
+		FinishedOn:   time.Now(),
+	})
+
+	content, err := s.JSON()
+	if err != nil {
+		ctx.Warnf("Failed to marshal provenance, skipping it: %v", err)
+		return
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		ctx.Warnf("Failed to create dir %s, skipping provenance: %v", outputDir, err)
+		return
+	}
+	if err := ioutil.WriteFile(fname, content, 0644); err != nil {
+		ctx.Warnf("Failed to write %s, skipping provenance: %v", fname, err)
+	}
+}
+
+// provenanceEnv returns the GOOGLE_* environment variables, and SOURCE_DATE_EPOCH, with the values
+// of build-time secrets redacted.
+func (ctx *Context) provenanceEnv() map[string]string {
+	result := map[string]string{}
+	for _, kv := range os.Environ() {
+		i := strings.Index(kv, "=")
+		if i < 0 {
+			continue
+		}
+		k, v := kv[:i], kv[i+1:]
+		if strings.HasPrefix(k, provenanceEnvPrefix) || k == SourceDateEpochEnv {
+			result[k] = ctx.redact(v)
+		}
+	}
+	return result
+}
+
+// provenanceDependencies returns the runtimes and tools recorded in the BOM of the buildpack.
+func (ctx *Context) provenanceDependencies() []builderoutput.ResourceDescriptor {
+	if ctx.buildResult.BOM == nil {
+		return nil
+	}
+	var deps []builderoutput.ResourceDescriptor
+	for _, e := range ctx.buildResult.BOM.Entries {
+		version, _ := e.Metadata["version"].(string)
+		d := builderoutput.ResourceDescriptor{Name: e.Name, URI: "pkg:generic/" + e.Name}
+		if version != "" {
+			d.URI += "@" + version
+			d.Annotations = map[string]string{"version": version}
+		}
+		deps = append(deps, d)
+	}
+	return deps
+}
+
+// dirHash returns the hash of the files in dir in the format of Go module hashes ("h1:"): the
+// base64-encoded SHA-256 of the sorted list of the SHA-256 and path of each file. The .git
+// directory is skipped and symlinks are hashed by their target.
+func dirHash(dir string) (string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(files)
+
+	summary := sha256.New()
+	for _, f := range files {
+		h, err := fileHash(filepath.Join(dir, filepath.FromSlash(f)))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(summary, "%x  %s\n", h, f)
+	}
+	return "h1:" + base64.StdEncoding.EncodeToString(summary.Sum(nil)), nil
+}
+
+func fileHash(path string) ([]byte, error) {
+	h := sha256.New()
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return nil, err
+		}
+		io.WriteString(h, target)
+		return h.Sum(nil), nil
+	}
+	if !info.Mode().IsRegular() {
+		return h.Sum(nil), nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
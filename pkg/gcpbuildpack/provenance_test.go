@@ -0,0 +1,119 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/builderoutput"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDirHash(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		".gitignore":  "node_modules\n",
+		"index.js":    "console.log(1)\n",
+		"lib/util.js": "module.exports = {}\n",
+		".git/HEAD":   "ref: refs/heads/main\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := dirHash(dir)
+	if err != nil {
+		t.Fatalf("dirHash() got error: %v", err)
+	}
+	// Computed with the algorithm of golang.org/x/mod/sumdb/dirhash.Hash1, without the .git files.
+	if want := "h1:96HGP2gQ/0zU75gtBIjYBSZuI4CEg/l9ZHSiPqfUblE="; got != want {
+		t.Errorf("dirHash() = %q, want %q", got, want)
+	}
+}
+
+func TestSaveProvenance(t *testing.T) {
+	outputDir := t.TempDir()
+	app := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(app, "main.py"), []byte("print('hello')\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(builderOutputEnv, outputDir)
+	t.Setenv("GOOGLE_RUNTIME_VERSION", "3.11.4")
+	t.Setenv("GOOGLE_API_TOKEN", "s3cr3t")
+
+	run := func(id string, bom ...libcnb.BOMEntry) {
+		t.Helper()
+		ctx := NewContext(WithBuildpackInfo(libcnb.BuildpackInfo{ID: id, Version: "1.0.0"}), WithStackID("google.gae.22"))
+		ctx.buildContext.Application.Path = app
+		ctx.redactor = strings.NewReplacer("s3cr3t", redactedSecret)
+		for _, e := range bom {
+			ctx.AddBOMEntry(e)
+		}
+		ctx.startProvenance()
+		ctx.saveProvenance(time.Now())
+	}
+	run("google.python.runtime", libcnb.BOMEntry{Name: "python", Metadata: map[string]interface{}{"version": "3.11.4"}})
+	run("google.python.pip")
+
+	content, err := ioutil.ReadFile(filepath.Join(outputDir, builderoutput.ProvenanceFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := builderoutput.StatementFromJSON(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	def := s.Predicate.BuildDefinition
+	if got := def.ExternalParameters.Source.Digest["dirHash"]; got == "" {
+		t.Errorf("source digest is empty, want the hash of the source")
+	}
+	if got, want := def.ExternalParameters.Env["GOOGLE_RUNTIME_VERSION"], "3.11.4"; got != want {
+		t.Errorf("env GOOGLE_RUNTIME_VERSION = %q, want %q", got, want)
+	}
+	if got := def.ExternalParameters.Env["GOOGLE_API_TOKEN"]; got == "s3cr3t" {
+		t.Errorf("env GOOGLE_API_TOKEN = %q, want the secret redacted", got)
+	}
+	if got, want := def.InternalParameters.StackID, "google.gae.22"; got != want {
+		t.Errorf("stack ID = %q, want %q", got, want)
+	}
+	var uris []string
+	for _, d := range def.ResolvedDependencies {
+		uris = append(uris, d.URI)
+	}
+	wantURIs := []string{
+		"pkg:generic/python@3.11.4",
+		"urn:cnb:buildpack:google.python.pip@1.0.0",
+		"urn:cnb:buildpack:google.python.runtime@1.0.0",
+	}
+	if diff := cmp.Diff(wantURIs, uris); diff != "" {
+		t.Errorf("resolved dependencies mismatch (-want +got):\n%s", diff)
+	}
+	wantVersions := map[string]string{"google.python.runtime": "1.0.0", "google.python.pip": "1.0.0"}
+	if diff := cmp.Diff(wantVersions, s.Predicate.RunDetails.Builder.Version); diff != "" {
+		t.Errorf("builder versions mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,277 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+const (
+	// SourceDateEpochEnv is the standard env var that build tools use as the time of the outputs they
+	// write, see https://reproducible-builds.org/specs/source-date-epoch/.
+	SourceDateEpochEnv = "SOURCE_DATE_EPOCH"
+
+	// pycHeaderSize is the size of the header of .pyc files, see PEP 552.
+	pycHeaderSize = 16
+)
+
+// normalizedTime is the modification time that lifecycle gives all the files of the image layers,
+// it is also the default SOURCE_DATE_EPOCH as zip archives cannot represent earlier times.
+var normalizedTime = time.Date(1980, time.January, 1, 0, 0, 1, 0, time.UTC)
+
+// zipTimeExtraIDs are the zip extra fields that hold file times: extended timestamp, Info-ZIP Unix
+// and NTFS.
+var zipTimeExtraIDs = map[uint16]bool{0x5455: true, 0x5855: true, 0x000a: true}
+
+// ReproducibleBuild returns true if GOOGLE_REPRODUCIBLE_BUILD is set. Buildpacks must then avoid
+// writing build times into the image and use SourceDateEpoch instead.
+func (ctx *Context) ReproducibleBuild() bool {
+	reproducible, err := env.IsPresentAndTrue(env.ReproducibleBuild)
+	return err == nil && reproducible
+}
+
+// SourceDateEpoch returns the time that build outputs must use in a reproducible build, it is set
+// with SOURCE_DATE_EPOCH and defaults to the time lifecycle gives the files of the image.
+func (ctx *Context) SourceDateEpoch() time.Time {
+	epoch, err := sourceDateEpoch()
+	if err != nil {
+		return normalizedTime
+	}
+	return epoch
+}
+
+func sourceDateEpoch() (time.Time, error) {
+	v := os.Getenv(SourceDateEpochEnv)
+	if v == "" {
+		return normalizedTime, nil
+	}
+	secs, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || secs < 0 {
+		return time.Time{}, UserErrorf("invalid %s %q, must be a number of seconds since the Unix epoch", SourceDateEpochEnv, v)
+	}
+	return time.Unix(secs, 0).UTC(), nil
+}
+
+// setupReproducibleBuild sets SOURCE_DATE_EPOCH, and a fixed hash seed for Python, for all the
+// commands of the buildpack if GOOGLE_REPRODUCIBLE_BUILD is set.
+func (ctx *Context) setupReproducibleBuild() error {
+	enabled, err := env.IsPresentAndTrue(env.ReproducibleBuild)
+	if err != nil {
+		return UserErrorf("%v", err)
+	}
+	if !enabled {
+		return nil
+	}
+	epoch, err := sourceDateEpoch()
+	if err != nil {
+		return err
+	}
+	ctx.Debugf("Reproducible build, using %s=%d", SourceDateEpochEnv, epoch.Unix())
+	if err := ctx.Setenv(SourceDateEpochEnv, strconv.FormatInt(epoch.Unix(), 10)); err != nil {
+		return err
+	}
+	return ctx.Setenv("PYTHONHASHSEED", "0")
+}
+
+// normalizeOutputs removes the build times embedded in the .pyc files and jars written since start
+// in the launch layers of the buildpack and in the application directory.
+func (ctx *Context) normalizeOutputs(start time.Time) error {
+	if !ctx.ReproducibleBuild() {
+		return nil
+	}
+	epoch := ctx.SourceDateEpoch()
+	roots := []string{ctx.ApplicationRoot()}
+	for _, c := range ctx.buildResult.Layers {
+		if lc, ok := c.(layerContributor); ok && lc.l.Launch {
+			roots = append(roots, lc.l.Path)
+		}
+	}
+	normalized := 0
+	for _, root := range roots {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil || !info.Mode().IsRegular() || info.ModTime().Before(start) {
+				return nil
+			}
+			var changed bool
+			switch filepath.Ext(path) {
+			case ".pyc":
+				changed, err = normalizePyc(path)
+			case ".jar", ".war":
+				changed, err = normalizeZip(path, epoch)
+			}
+			if changed {
+				normalized++
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	ctx.Debugf("Normalized the timestamps of %d files.", normalized)
+	return nil
+}
+
+// normalizePyc sets the source modification time recorded in a timestamp-based .pyc file to the
+// time lifecycle gives the source file in the image, so that the .pyc file is deterministic and
+// stays valid. Hash-based .pyc files do not record a time.
+func normalizePyc(path string) (bool, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return false, InternalErrorf("opening %s: %v", path, err)
+	}
+	defer f.Close()
+	header := make([]byte, pycHeaderSize)
+	if _, err := io.ReadFull(f, header); err != nil {
+		// Not a valid .pyc file, leave it as is.
+		return false, nil
+	}
+	// The flags field is 0 for timestamp-based files.
+	if binary.LittleEndian.Uint32(header[4:8]) != 0 {
+		return false, nil
+	}
+	mtime := make([]byte, 4)
+	binary.LittleEndian.PutUint32(mtime, uint32(normalizedTime.Unix()))
+	if bytes.Equal(header[8:12], mtime) {
+		return false, nil
+	}
+	if _, err := f.WriteAt(mtime, 8); err != nil {
+		return false, InternalErrorf("writing %s: %v", path, err)
+	}
+	return true, nil
+}
+
+// normalizeZip rewrites a zip archive, e.g. a jar, with its entries sorted by name and with the given
+// modification time, without recompressing them. The manifest of jars stays first. Archives with
+// data before the first entry, such as executable jars with a launch script, are left as is.
+func normalizeZip(path string, t time.Time) (bool, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return false, nil
+	}
+	defer r.Close()
+	if len(r.File) == 0 {
+		return false, nil
+	}
+	if offset, err := r.File[0].DataOffset(); err != nil || offset != int64(30+len(r.File[0].Name)+len(r.File[0].Extra)) {
+		return false, nil
+	}
+
+	files := make([]*zip.File, len(r.File))
+	copy(files, r.File)
+	sort.SliceStable(files, func(i, j int) bool {
+		if pi, pj := zipEntryPriority(files[i].Name), zipEntryPriority(files[j].Name); pi != pj {
+			return pi < pj
+		}
+		return files[i].Name < files[j].Name
+	})
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return false, InternalErrorf("creating temporary file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	w := zip.NewWriter(tmp)
+	for _, f := range files {
+		fh := f.FileHeader
+		// CreateRaw writes the MS-DOS time fields as is, Modified is only used by CreateHeader.
+		fh.Modified = time.Time{}
+		fh.ModifiedDate, fh.ModifiedTime = msDosTime(t)
+		fh.Extra = stripZipTimes(fh.Extra)
+		dst, err := w.CreateRaw(&fh)
+		if err != nil {
+			return false, InternalErrorf("writing %s: %v", path, err)
+		}
+		src, err := f.OpenRaw()
+		if err != nil {
+			return false, InternalErrorf("reading %s in %s: %v", f.Name, path, err)
+		}
+		if _, err := io.Copy(dst, src); err != nil {
+			return false, InternalErrorf("copying %s in %s: %v", f.Name, path, err)
+		}
+	}
+	if err := w.SetComment(r.Comment); err != nil {
+		return false, InternalErrorf("writing %s: %v", path, err)
+	}
+	if err := w.Close(); err != nil {
+		return false, InternalErrorf("writing %s: %v", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return false, InternalErrorf("writing %s: %v", path, err)
+	}
+	if fi, err := os.Stat(path); err == nil {
+		if err := os.Chmod(tmp.Name(), fi.Mode()); err != nil {
+			return false, InternalErrorf("setting permissions of %s: %v", tmp.Name(), err)
+		}
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return false, InternalErrorf("renaming %s to %s: %v", tmp.Name(), path, err)
+	}
+	return true, nil
+}
+
+// msDosTime returns the MS-DOS date and time fields of zip entries for t, at 2 seconds precision.
+func msDosTime(t time.Time) (uint16, uint16) {
+	t = t.UTC()
+	if t.Before(normalizedTime) {
+		t = normalizedTime
+	}
+	date := uint16(t.Day() + int(t.Month())<<5 + (t.Year()-1980)<<9)
+	tod := uint16(t.Second()/2 + t.Minute()<<5 + t.Hour()<<11)
+	return date, tod
+}
+
+// zipEntryPriority returns the position of the jar entries that must precede all the others.
+func zipEntryPriority(name string) int {
+	switch strings.ToUpper(name) {
+	case "META-INF/":
+		return 0
+	case "META-INF/MANIFEST.MF":
+		return 1
+	default:
+		return 2
+	}
+}
+
+// stripZipTimes removes the extra fields that hold file times from the extra data of a zip entry.
+func stripZipTimes(extra []byte) []byte {
+	var result []byte
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra[0:2])
+		size := int(binary.LittleEndian.Uint16(extra[2:4]))
+		if 4+size > len(extra) {
+			// Malformed fields are kept as is.
+			return append(result, extra...)
+		}
+		if !zipTimeExtraIDs[id] {
+			result = append(result, extra[:4+size]...)
+		}
+		extra = extra[4+size:]
+	}
+	return append(result, extra...)
+}
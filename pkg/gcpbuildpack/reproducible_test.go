@@ -0,0 +1,274 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSetupReproducibleBuild(t *testing.T) {
+	testCases := []struct {
+		name             string
+		reproducible     string
+		sourceDateEpoch  string
+		wantReproducible bool
+		wantEpoch        string
+		wantErr          bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:         "false",
+			reproducible: "false",
+		},
+		{
+			name:             "default epoch",
+			reproducible:     "true",
+			wantReproducible: true,
+			wantEpoch:        "315532801",
+		},
+		{
+			name:             "custom epoch",
+			reproducible:     "true",
+			sourceDateEpoch:  "1700000000",
+			wantReproducible: true,
+			wantEpoch:        "1700000000",
+		},
+		{
+			name:            "invalid epoch",
+			reproducible:    "true",
+			sourceDateEpoch: "yesterday",
+			wantErr:         true,
+		},
+		{
+			name:         "invalid value",
+			reproducible: "maybe",
+			wantErr:      true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(env.ReproducibleBuild, tc.reproducible)
+			if tc.reproducible == "" {
+				os.Unsetenv(env.ReproducibleBuild)
+			}
+			t.Setenv(SourceDateEpochEnv, tc.sourceDateEpoch)
+			t.Setenv("PYTHONHASHSEED", "")
+			ctx := NewContext()
+
+			err := ctx.setupReproducibleBuild()
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("setupReproducibleBuild() got error: %v, want error? %v", err, tc.wantErr)
+			}
+			if !tc.wantReproducible {
+				if got := os.Getenv(SourceDateEpochEnv); got != tc.sourceDateEpoch {
+					t.Errorf("%s = %q, want it unchanged", SourceDateEpochEnv, got)
+				}
+				return
+			}
+			if !ctx.ReproducibleBuild() {
+				t.Errorf("ReproducibleBuild() = false, want true")
+			}
+			if got := os.Getenv(SourceDateEpochEnv); got != tc.wantEpoch {
+				t.Errorf("%s = %q, want %q", SourceDateEpochEnv, got, tc.wantEpoch)
+			}
+			if got := os.Getenv("PYTHONHASHSEED"); got != "0" {
+				t.Errorf("PYTHONHASHSEED = %q, want %q", got, "0")
+			}
+		})
+	}
+}
+
+func TestNormalizePyc(t *testing.T) {
+	testCases := []struct {
+		name        string
+		flags       uint32
+		wantChanged bool
+		wantMtime   uint32
+	}{
+		{
+			name:        "timestamp-based",
+			wantChanged: true,
+			wantMtime:   uint32(normalizedTime.Unix()),
+		},
+		{
+			name:      "hash-based",
+			flags:     1,
+			wantMtime: 1234,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			header := make([]byte, pycHeaderSize)
+			copy(header, []byte{0x6f, 0x0d, 0x0d, 0x0a})
+			binary.LittleEndian.PutUint32(header[4:8], tc.flags)
+			binary.LittleEndian.PutUint32(header[8:12], 1234)
+			path := filepath.Join(t.TempDir(), "main.cpython-311.pyc")
+			if err := ioutil.WriteFile(path, append(header, "code"...), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			changed, err := normalizePyc(path)
+			if err != nil {
+				t.Fatalf("normalizePyc() got error: %v", err)
+			}
+			if changed != tc.wantChanged {
+				t.Errorf("normalizePyc() = %t, want %t", changed, tc.wantChanged)
+			}
+			got, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mtime := binary.LittleEndian.Uint32(got[8:12]); mtime != tc.wantMtime {
+				t.Errorf("source mtime = %d, want %d", mtime, tc.wantMtime)
+			}
+			if !bytes.HasSuffix(got, []byte("code")) {
+				t.Errorf("normalizePyc() changed the code object: %q", got)
+			}
+		})
+	}
+}
+
+func TestNormalizeZip(t *testing.T) {
+	epoch := time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC)
+	write := func(path string, modified time.Time) {
+		t.Helper()
+		var buf bytes.Buffer
+		w := zip.NewWriter(&buf)
+		for _, name := range []string{"com/example/Main.class", "META-INF/MANIFEST.MF", "application.properties", "META-INF/"} {
+			f, err := w.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.HasSuffix(name, "/") {
+				continue
+			}
+			if _, err := f.Write([]byte("content of " + name)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first.jar"), filepath.Join(dir, "second.jar")
+	write(first, time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC))
+	write(second, time.Date(2023, 6, 2, 8, 30, 0, 0, time.UTC))
+
+	for _, path := range []string{first, second} {
+		if changed, err := normalizeZip(path, epoch); err != nil || !changed {
+			t.Fatalf("normalizeZip(%q) = %t, %v, want true, nil", path, changed, err)
+		}
+	}
+
+	a, err := ioutil.ReadFile(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(a, b) {
+		t.Errorf("normalizeZip() produced different archives for the same content")
+	}
+	r, err := zip.OpenReader(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var names []string
+	for _, f := range r.File {
+		names = append(names, f.Name)
+		if !f.Modified.Equal(epoch) {
+			t.Errorf("%s modified = %v, want %v", f.Name, f.Modified, epoch)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("reading %s: %v", f.Name, err)
+		}
+		if want := "content of " + f.Name; !strings.HasSuffix(f.Name, "/") && string(content) != want {
+			t.Errorf("%s content = %q, want %q", f.Name, content, want)
+		}
+	}
+	wantNames := []string{"META-INF/", "META-INF/MANIFEST.MF", "application.properties", "com/example/Main.class"}
+	if diff := cmp.Diff(wantNames, names); diff != "" {
+		t.Errorf("entries mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestNormalizeZipSkipsPrefixedArchives(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("#!/bin/sh\nexec java -jar \"$0\" \"$@\"\n")
+	w := zip.NewWriter(&buf)
+	if _, err := w.Create("META-INF/MANIFEST.MF"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "app.jar")
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := normalizeZip(path, normalizedTime)
+	if err != nil {
+		t.Fatalf("normalizeZip() got error: %v", err)
+	}
+	if changed {
+		t.Errorf("normalizeZip() = true, want false for an archive with a launch script")
+	}
+}
+
+func TestStripZipTimes(t *testing.T) {
+	field := func(id uint16, data string) []byte {
+		b := make([]byte, 4)
+		binary.LittleEndian.PutUint16(b[0:2], id)
+		binary.LittleEndian.PutUint16(b[2:4], uint16(len(data)))
+		return append(b, data...)
+	}
+	var extra []byte
+	extra = append(extra, field(0x5455, "\x01abcd")...)
+	extra = append(extra, field(0xcafe, "")...)
+	extra = append(extra, field(0x000a, "ntfs-times")...)
+	extra = append(extra, field(0x0001, "zip64zip")...)
+
+	want := append(field(0xcafe, ""), field(0x0001, "zip64zip")...)
+	if got := stripZipTimes(extra); !bytes.Equal(got, want) {
+		t.Errorf("stripZipTimes() = %v, want %v", got, want)
+	}
+}
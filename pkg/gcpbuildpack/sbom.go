@@ -0,0 +1,268 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	cdxSpecVersion  = "1.4"
+	spdxSpecVersion = "SPDX-2.3"
+	// sbomNamespace is the prefix of the unique URI that identifies each SPDX document.
+	sbomNamespace = "https://github.com/GoogleCloudPlatform/buildpacks/sbom"
+	// launchSBOMName is the name used for the SBOM of launch-time dependencies that are not
+	// associated with a layer, e.g. dependencies installed in the application directory.
+	launchSBOMName = "launch"
+)
+
+// sbomFormats are the formats in which each SBOM is written. They must match the sbom-formats
+// declared in buildpack.toml, libcnb rejects SBOM files of other formats.
+var sbomFormats = []libcnb.SBOMFormat{libcnb.CycloneDXJSON, libcnb.SPDXJSON}
+
+// sbomTime returns the creation time of SPDX documents, it is a var for testing.
+var sbomTime = time.Now
+
+// SBOMEntry describes a software component in a software bill of materials (SBOM).
+type SBOMEntry struct {
+	// Name is the name of the component, e.g. "express" or "@google-cloud/storage".
+	Name string
+	// Version is the version of the component, e.g. "4.18.2".
+	Version string
+	// PURLType is the package URL type of the component, e.g. "npm", "pypi" or "golang". The
+	// component has no package URL if it is empty.
+	PURLType string
+}
+
+// PURL returns the package URL (https://github.com/package-url/purl-spec) of the component.
+func (e SBOMEntry) PURL() string {
+	if e.PURLType == "" {
+		return ""
+	}
+	var segments []string
+	for _, s := range strings.Split(e.Name, "/") {
+		// The "@" of npm scopes must be encoded in the namespace.
+		segments = append(segments, strings.ReplaceAll(url.PathEscape(s), "@", "%40"))
+	}
+	purl := fmt.Sprintf("pkg:%s/%s", e.PURLType, strings.Join(segments, "/"))
+	if e.Version != "" {
+		purl += "@" + url.PathEscape(e.Version)
+	}
+	return purl
+}
+
+// layerSBOM holds the SBOM entries of a layer, or of the launch image if layer is nil.
+type layerSBOM struct {
+	layer   *libcnb.Layer
+	entries []SBOMEntry
+}
+
+func (s *layerSBOM) name() string {
+	if s.layer == nil {
+		return launchSBOMName
+	}
+	return s.layer.Name
+}
+
+// AddSBOMEntries adds components to the SBOM of the given layer. The SBOM is written next to the
+// layer in each of the supported formats when the build succeeds, as described in
+// https://github.com/buildpacks/spec/blob/main/buildpack.md#bills-of-materials.
+func (ctx *Context) AddSBOMEntries(l *libcnb.Layer, entries ...SBOMEntry) {
+	for _, s := range ctx.sboms {
+		if s.layer != nil && s.layer.Name == l.Name {
+			s.entries = append(s.entries, entries...)
+			return
+		}
+	}
+	ctx.sboms = append(ctx.sboms, &layerSBOM{layer: l, entries: entries})
+}
+
+// AddLaunchSBOMEntries adds components that are part of the launch image but are not contained in
+// a layer, e.g. dependencies installed in the application directory.
+func (ctx *Context) AddLaunchSBOMEntries(entries ...SBOMEntry) {
+	for _, s := range ctx.sboms {
+		if s.layer == nil {
+			s.entries = append(s.entries, entries...)
+			return
+		}
+	}
+	ctx.sboms = append(ctx.sboms, &layerSBOM{entries: entries})
+}
+
+// writeSBOMs writes the SBOM files of all the layers that have SBOM entries.
+func (ctx *Context) writeSBOMs() error {
+	for _, s := range ctx.sboms {
+		entries := sortedSBOMEntries(s.entries)
+		for _, format := range sbomFormats {
+			path := ctx.buildContext.Layers.LaunchSBOMPath(format)
+			if s.layer != nil {
+				path = s.layer.SBOMPath(format)
+			}
+			var doc interface{}
+			switch format {
+			case libcnb.CycloneDXJSON:
+				doc = ctx.cycloneDXDocument(entries)
+			case libcnb.SPDXJSON:
+				doc = ctx.spdxDocument(s.name(), entries)
+			}
+			b, err := json.MarshalIndent(doc, "", "  ")
+			if err != nil {
+				return InternalErrorf("encoding %s SBOM of %s: %v", format, s.name(), err)
+			}
+			if err := ioutil.WriteFile(path, b, 0644); err != nil {
+				return InternalErrorf("writing SBOM %s: %v", path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// sortedSBOMEntries returns the entries sorted by name and version without duplicates so that the
+// SBOM does not depend on the order in which entries were added.
+func sortedSBOMEntries(entries []SBOMEntry) []SBOMEntry {
+	sorted := make([]SBOMEntry, len(entries))
+	copy(sorted, entries)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Name != sorted[j].Name {
+			return sorted[i].Name < sorted[j].Name
+		}
+		if sorted[i].Version != sorted[j].Version {
+			return sorted[i].Version < sorted[j].Version
+		}
+		return sorted[i].PURLType < sorted[j].PURLType
+	})
+	var result []SBOMEntry
+	for i, e := range sorted {
+		if i == 0 || e != sorted[i-1] {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+type cdxDocument struct {
+	BOMFormat   string         `json:"bomFormat"`
+	SpecVersion string         `json:"specVersion"`
+	Version     int            `json:"version"`
+	Metadata    cdxMetadata    `json:"metadata"`
+	Components  []cdxComponent `json:"components"`
+}
+
+type cdxMetadata struct {
+	Tools []cdxTool `json:"tools"`
+}
+
+type cdxTool struct {
+	Vendor  string `json:"vendor"`
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type cdxComponent struct {
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	PURL    string `json:"purl,omitempty"`
+}
+
+func (ctx *Context) cycloneDXDocument(entries []SBOMEntry) cdxDocument {
+	doc := cdxDocument{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: cdxSpecVersion,
+		Version:     1,
+		Metadata:    cdxMetadata{Tools: []cdxTool{{Vendor: "Google", Name: ctx.BuildpackID(), Version: ctx.BuildpackVersion()}}},
+		Components:  []cdxComponent{},
+	}
+	for _, e := range entries {
+		doc.Components = append(doc.Components, cdxComponent{Type: "library", Name: e.Name, Version: e.Version, PURL: e.PURL()})
+	}
+	return doc
+}
+
+type spdxDocument struct {
+	SPDXVersion       string           `json:"spdxVersion"`
+	DataLicense       string           `json:"dataLicense"`
+	SPDXID            string           `json:"SPDXID"`
+	Name              string           `json:"name"`
+	DocumentNamespace string           `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo `json:"creationInfo"`
+	Packages          []spdxPackage    `json:"packages"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name             string            `json:"name"`
+	SPDXID           string            `json:"SPDXID"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+func (ctx *Context) spdxDocument(name string, entries []SBOMEntry) spdxDocument {
+	// The namespace must be unique for each document, it is derived from its content so that the
+	// same dependencies always produce the same namespace.
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n", ctx.BuildpackID(), name)
+	created := sbomTime()
+	if ctx.ReproducibleBuild() {
+		created = ctx.SourceDateEpoch()
+	}
+	doc := spdxDocument{
+		SPDXVersion: spdxSpecVersion,
+		DataLicense: "CC0-1.0",
+		SPDXID:      "SPDXRef-DOCUMENT",
+		Name:        fmt.Sprintf("%s/%s", ctx.BuildpackID(), name),
+		CreationInfo: spdxCreationInfo{
+			Created:  created.UTC().Format(time.RFC3339),
+			Creators: []string{fmt.Sprintf("Tool: %s-%s", ctx.BuildpackID(), ctx.BuildpackVersion())},
+		},
+		Packages: []spdxPackage{},
+	}
+	for i, e := range entries {
+		fmt.Fprintf(h, "%s@%s %s\n", e.Name, e.Version, e.PURLType)
+		p := spdxPackage{
+			Name:             e.Name,
+			SPDXID:           fmt.Sprintf("SPDXRef-Package-%d", i+1),
+			VersionInfo:      e.Version,
+			DownloadLocation: "NOASSERTION",
+		}
+		if purl := e.PURL(); purl != "" {
+			p.ExternalRefs = []spdxExternalRef{{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl", ReferenceLocator: purl}}
+		}
+		doc.Packages = append(doc.Packages, p)
+	}
+	doc.DocumentNamespace = fmt.Sprintf("%s/%s/%s-%x", sbomNamespace, ctx.BuildpackID(), name, h.Sum(nil)[:8])
+	return doc
+}
@@ -0,0 +1,170 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSBOMEntryPURL(t *testing.T) {
+	testCases := []struct {
+		entry SBOMEntry
+		want  string
+	}{
+		{
+			entry: SBOMEntry{Name: "express", Version: "4.18.2", PURLType: "npm"},
+			want:  "pkg:npm/express@4.18.2",
+		},
+		{
+			entry: SBOMEntry{Name: "@google-cloud/storage", Version: "6.9.0", PURLType: "npm"},
+			want:  "pkg:npm/%40google-cloud/storage@6.9.0",
+		},
+		{
+			entry: SBOMEntry{Name: "golang.org/x/sys", Version: "v0.5.0", PURLType: "golang"},
+			want:  "pkg:golang/golang.org/x/sys@v0.5.0",
+		},
+		{
+			entry: SBOMEntry{Name: "flask", PURLType: "pypi"},
+			want:  "pkg:pypi/flask",
+		},
+		{
+			entry: SBOMEntry{Name: "nodejs", Version: "18.16.0"},
+			want:  "",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.want, func(t *testing.T) {
+			if got := tc.entry.PURL(); got != tc.want {
+				t.Errorf("PURL() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWriteSBOMs(t *testing.T) {
+	layers := t.TempDir()
+	created := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	origTime := sbomTime
+	sbomTime = func() time.Time { return created }
+	t.Cleanup(func() { sbomTime = origTime })
+
+	ctx := NewContext(
+		WithBuildpackInfo(libcnb.BuildpackInfo{ID: "google.nodejs.npm", Version: "1.0.0"}),
+		WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layers}}),
+	)
+	l, err := ctx.Layer("deps")
+	if err != nil {
+		t.Fatalf("Layer() got error: %v", err)
+	}
+	ctx.AddSBOMEntries(l, SBOMEntry{Name: "express", Version: "4.18.2", PURLType: "npm"})
+	ctx.AddSBOMEntries(l,
+		SBOMEntry{Name: "accepts", Version: "1.3.8", PURLType: "npm"},
+		SBOMEntry{Name: "express", Version: "4.18.2", PURLType: "npm"})
+	ctx.AddLaunchSBOMEntries(SBOMEntry{Name: "left-pad", Version: "1.3.0", PURLType: "npm"})
+
+	if err := ctx.writeSBOMs(); err != nil {
+		t.Fatalf("writeSBOMs() got error: %v", err)
+	}
+
+	var cdx cdxDocument
+	readJSON(t, filepath.Join(layers, "deps.sbom.cdx.json"), &cdx)
+	wantCDX := cdxDocument{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.4",
+		Version:     1,
+		Metadata:    cdxMetadata{Tools: []cdxTool{{Vendor: "Google", Name: "google.nodejs.npm", Version: "1.0.0"}}},
+		Components: []cdxComponent{
+			{Type: "library", Name: "accepts", Version: "1.3.8", PURL: "pkg:npm/accepts@1.3.8"},
+			{Type: "library", Name: "express", Version: "4.18.2", PURL: "pkg:npm/express@4.18.2"},
+		},
+	}
+	if diff := cmp.Diff(wantCDX, cdx); diff != "" {
+		t.Errorf("CycloneDX SBOM mismatch (-want +got):\n%s", diff)
+	}
+
+	var spdx spdxDocument
+	readJSON(t, filepath.Join(layers, "deps.sbom.spdx.json"), &spdx)
+	if got, want := spdx.CreationInfo, (spdxCreationInfo{Created: "2023-06-01T12:00:00Z", Creators: []string{"Tool: google.nodejs.npm-1.0.0"}}); !cmp.Equal(got, want) {
+		t.Errorf("SPDX creationInfo = %+v, want %+v", got, want)
+	}
+	wantPackages := []spdxPackage{
+		{
+			Name:             "accepts",
+			SPDXID:           "SPDXRef-Package-1",
+			VersionInfo:      "1.3.8",
+			DownloadLocation: "NOASSERTION",
+			ExternalRefs:     []spdxExternalRef{{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl", ReferenceLocator: "pkg:npm/accepts@1.3.8"}},
+		},
+		{
+			Name:             "express",
+			SPDXID:           "SPDXRef-Package-2",
+			VersionInfo:      "4.18.2",
+			DownloadLocation: "NOASSERTION",
+			ExternalRefs:     []spdxExternalRef{{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl", ReferenceLocator: "pkg:npm/express@4.18.2"}},
+		},
+	}
+	if diff := cmp.Diff(wantPackages, spdx.Packages); diff != "" {
+		t.Errorf("SPDX packages mismatch (-want +got):\n%s", diff)
+	}
+
+	var launch cdxDocument
+	readJSON(t, filepath.Join(layers, "launch.sbom.cdx.json"), &launch)
+	if diff := cmp.Diff([]cdxComponent{{Type: "library", Name: "left-pad", Version: "1.3.0", PURL: "pkg:npm/left-pad@1.3.0"}}, launch.Components); diff != "" {
+		t.Errorf("launch SBOM components mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSPDXNamespaceIsDeterministic(t *testing.T) {
+	ctx := NewContext(WithBuildpackInfo(libcnb.BuildpackInfo{ID: "google.go.build", Version: "1.0.0"}))
+	entries := []SBOMEntry{{Name: "golang.org/x/sys", Version: "v0.5.0", PURLType: "golang"}}
+
+	first := ctx.spdxDocument("bin", entries).DocumentNamespace
+	if second := ctx.spdxDocument("bin", entries).DocumentNamespace; first != second {
+		t.Errorf("spdxDocument() namespaces differ for the same entries: %q and %q", first, second)
+	}
+	other := []SBOMEntry{{Name: "golang.org/x/sys", Version: "v0.6.0", PURLType: "golang"}}
+	if got := ctx.spdxDocument("bin", other).DocumentNamespace; got == first {
+		t.Errorf("spdxDocument() namespaces are equal for different entries: %q", got)
+	}
+}
+
+func readJSON(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		t.Fatalf("decoding %s: %v", path, err)
+	}
+}
+
+func TestSPDXCreatedIsSourceDateEpochInReproducibleBuild(t *testing.T) {
+	ctx := NewContext(WithBuildpackInfo(libcnb.BuildpackInfo{ID: "google.go.build", Version: "1.0.0"}))
+	t.Setenv(env.ReproducibleBuild, "true")
+	t.Setenv(SourceDateEpochEnv, "")
+
+	if got, want := ctx.spdxDocument("bin", nil).CreationInfo.Created, "1980-01-01T00:00:01Z"; got != want {
+		t.Errorf("spdxDocument() created = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+const (
+	// SecretBindingType is the type of the service bindings whose entries are exposed as build-time
+	// secrets, see https://github.com/buildpacks/spec/blob/main/extensions/bindings.md.
+	SecretBindingType = "build-secret"
+
+	// redactedSecret replaces the value of secrets in logs and layer metadata.
+	redactedSecret = "[REDACTED]"
+)
+
+var (
+	secretNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// secretKeyRegexp matches the characters of binding keys that are not valid in env var names.
+	secretKeyRegexp = regexp.MustCompile(`[^A-Za-z0-9_]`)
+)
+
+// loadSecrets reads the build-time secrets from the service bindings of type SecretBindingType and
+// from the directory specified by GOOGLE_BUILD_SECRETS_DIR, which takes precedence. The secrets are
+// set as environment variables of every command run with Exec and are redacted from the logs.
+func (ctx *Context) loadSecrets() error {
+	secrets := make(map[string]string)
+	for _, b := range ctx.buildContext.Platform.Bindings {
+		if b.Type != SecretBindingType {
+			continue
+		}
+		for k, v := range b.Secret {
+			secrets[strings.ToUpper(secretKeyRegexp.ReplaceAllString(k, "_"))] = v
+		}
+	}
+
+	if dir := os.Getenv(env.BuildSecretsDir); dir != "" {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return UserErrorf("reading %s %q: %v", env.BuildSecretsDir, dir, err)
+		}
+		for _, f := range files {
+			// Mounted secret volumes contain hidden bookkeeping entries such as "..data".
+			if strings.HasPrefix(f.Name(), ".") {
+				continue
+			}
+			path := filepath.Join(dir, f.Name())
+			// Secret files are commonly symlinks, stat follows them.
+			fi, err := os.Stat(path)
+			if err != nil {
+				return UserErrorf("reading secret %q: %v", path, err)
+			}
+			if fi.IsDir() {
+				continue
+			}
+			if !secretNameRegexp.MatchString(f.Name()) {
+				return UserErrorf("invalid secret file %q in %s, the file name must be a valid environment variable name", f.Name(), env.BuildSecretsDir)
+			}
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				return UserErrorf("reading secret %q: %v", path, err)
+			}
+			secrets[f.Name()] = strings.TrimRight(string(b), "\r\n")
+		}
+	}
+
+	ctx.secrets = nil
+	var oldnew []string
+	for name, value := range secrets {
+		ctx.secrets = append(ctx.secrets, name+"="+value)
+		// Multi-line values are also redacted line by line because output is logged line by line.
+		for _, v := range append(strings.Split(value, "\n"), value) {
+			if v = strings.TrimSpace(v); v != "" {
+				oldnew = append(oldnew, v, redactedSecret)
+			}
+		}
+	}
+	sort.Strings(ctx.secrets)
+	ctx.redactor = nil
+	if len(oldnew) > 0 {
+		// Longer values are replaced first so that secrets containing other secrets are fully redacted.
+		sortByLengthDesc(oldnew)
+		ctx.redactor = strings.NewReplacer(oldnew...)
+		ctx.Logf("Loaded %d build-time secret(s)", len(ctx.secrets))
+	}
+	return nil
+}
+
+// sortByLengthDesc sorts the old, new pairs passed to strings.NewReplacer by decreasing length of old.
+func sortByLengthDesc(oldnew []string) {
+	pairs := make([][2]string, 0, len(oldnew)/2)
+	for i := 0; i < len(oldnew); i += 2 {
+		pairs = append(pairs, [2]string{oldnew[i], oldnew[i+1]})
+	}
+	sort.SliceStable(pairs, func(i, j int) bool { return len(pairs[i][0]) > len(pairs[j][0]) })
+	for i, p := range pairs {
+		oldnew[2*i], oldnew[2*i+1] = p[0], p[1]
+	}
+}
+
+// Secret returns the value of the build-time secret with the given name.
+func (ctx *Context) Secret(name string) (string, bool) {
+	prefix := name + "="
+	for _, s := range ctx.secrets {
+		if strings.HasPrefix(s, prefix) {
+			return strings.TrimPrefix(s, prefix), true
+		}
+	}
+	return "", false
+}
+
+// redact replaces the values of build-time secrets in s.
+func (ctx *Context) redact(s string) string {
+	if ctx.redactor == nil {
+		return s
+	}
+	return ctx.redactor.Replace(s)
+}
+
+// newRedactingWriter returns a lineWriter that writes each line to w with the values of build-time
+// secrets replaced. Output is redacted by line because secrets may be split across writes.
+func (ctx *Context) newRedactingWriter(w io.Writer) *lineWriter {
+	return &lineWriter{emit: func(line []byte) {
+		w.Write(append([]byte(ctx.redact(string(line))), '\n'))
+	}}
+}
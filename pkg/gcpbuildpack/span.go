@@ -28,6 +28,9 @@ type spanInfo struct {
 	end        time.Time
 	attributes map[string]interface{}
 	status     buildererror.Status
+	// phase is true for the span of the buildpack detect or build phase, which is the parent of the
+	// other spans in exported traces.
+	phase bool
 }
 
 func newSpanInfo(name string, start, end time.Time, attributes map[string]interface{}, status buildererror.Status) (*spanInfo, error) {
@@ -50,6 +53,42 @@ func newSpanInfo(name string, start, end time.Time, attributes map[string]interf
 	}, nil
 }
 
+// phaseSpan is the span of the detect or build phase that is in progress.
+type phaseSpan struct {
+	label string
+	start time.Time
+}
+
+// startPhaseSpan starts the span of the current buildpack phase.
+func (ctx *Context) startPhaseSpan(label string) {
+	ctx.phaseSpan = &phaseSpan{label: label, start: time.Now()}
+}
+
+// endPhaseSpan records the span of the current buildpack phase, if it has not been recorded yet,
+// and exports the trace of the phase if GOOGLE_BUILD_OTLP_ENDPOINT is set.
+func (ctx *Context) endPhaseSpan(status buildererror.Status) {
+	if ps := ctx.phaseSpan; ps != nil {
+		ctx.phaseSpan = nil
+		attributes := ctx.spanAttributes()
+		if len(ctx.stats.cacheHits) > 0 {
+			attributes["/cache_hits"] = ctx.stats.cacheHits
+		}
+		if len(ctx.stats.cacheMisses) > 0 {
+			attributes["/cache_misses"] = ctx.stats.cacheMisses
+		}
+		if tracesEnabled() {
+			// Computing layer sizes requires walking the layers, so it is only done for exported traces.
+			for name, size := range ctx.layerSizes() {
+				attributes["/layer_size_bytes/"+name] = size
+			}
+		}
+		if si := ctx.recordSpan(ps.label, ps.start, attributes, status); si != nil {
+			si.phase = true
+		}
+	}
+	ctx.exportTrace()
+}
+
 func (ctx *Context) createSpanName(cmd []string) string {
 	var trimmed []string
 	for _, c := range cmd {
@@ -0,0 +1,69 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+// WarningCategory identifies a class of warnings that GOOGLE_STRICT_BUILD can promote to build
+// failures.
+type WarningCategory string
+
+const (
+	// WarningDeprecatedRuntime is emitted when the runtime version has reached its end of life.
+	WarningDeprecatedRuntime WarningCategory = "deprecated-runtime"
+	// WarningNPMAuditCritical is emitted when npm audit reports critical vulnerabilities.
+	WarningNPMAuditCritical WarningCategory = "npm-audit-critical"
+	// WarningEOLBaseImage is emitted when the operating system of the stack has reached its end of life.
+	WarningEOLBaseImage WarningCategory = "eol-base-image"
+)
+
+// StrictWarnf emits a warning of the given category, or returns a user error that fails the build
+// if GOOGLE_STRICT_BUILD enables the category. Callers must return the error.
+func (ctx *Context) StrictWarnf(category WarningCategory, format string, args ...interface{}) error {
+	if !strictCategory(category) {
+		ctx.Warnf(format, args...)
+		return nil
+	}
+	msg := fmt.Sprintf(format, args...)
+	return UserErrorf("%s (%s warnings fail the build because %s=%q)", msg, category, env.StrictBuild, os.Getenv(env.StrictBuild)).
+		WithCode(buildererror.CodeStrictBuildWarning).
+		WithHint(fmt.Sprintf("Fix the cause of the warning. To allow it, set %s to a list of categories that does not include %q.", env.StrictBuild, category), "")
+}
+
+// strictCategory returns true if GOOGLE_STRICT_BUILD enables the warning category. The value is
+// either a boolean that enables all categories or a comma-separated list of categories.
+func strictCategory(category WarningCategory) bool {
+	v := strings.TrimSpace(os.Getenv(env.StrictBuild))
+	if v == "" {
+		return false
+	}
+	if all, err := strconv.ParseBool(v); err == nil {
+		return all
+	}
+	for _, c := range strings.Split(v, ",") {
+		if WarningCategory(strings.TrimSpace(c)) == category {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+)
+
+func TestStrictWarnf(t *testing.T) {
+	testCases := []struct {
+		name     string
+		strict   string
+		category WarningCategory
+		wantErr  bool
+	}{
+		{
+			name:     "not set",
+			category: WarningDeprecatedRuntime,
+		},
+		{
+			name:     "false",
+			strict:   "false",
+			category: WarningDeprecatedRuntime,
+		},
+		{
+			name:     "true",
+			strict:   "true",
+			category: WarningDeprecatedRuntime,
+			wantErr:  true,
+		},
+		{
+			name:     "selected category",
+			strict:   "eol-base-image, deprecated-runtime",
+			category: WarningDeprecatedRuntime,
+			wantErr:  true,
+		},
+		{
+			name:     "other category",
+			strict:   "eol-base-image",
+			category: WarningNPMAuditCritical,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("GOOGLE_STRICT_BUILD", tc.strict)
+			ctx := NewContext()
+
+			err := ctx.StrictWarnf(tc.category, "nodejs %s has reached its end of life", "16.20.2")
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("StrictWarnf() got error: %v, want error? %t", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				if got := buildererror.CodeOf(err); got != buildererror.CodeStrictBuildWarning {
+					t.Errorf("StrictWarnf() error code = %q, want %q", got, buildererror.CodeStrictBuildWarning)
+				}
+				if len(ctx.warnings) != 0 {
+					t.Errorf("StrictWarnf() recorded warnings %v, want none for a strict failure", ctx.warnings)
+				}
+				return
+			}
+			if want := []string{"nodejs 16.20.2 has reached its end of life"}; len(ctx.warnings) != 1 || ctx.warnings[0] != want[0] {
+				t.Errorf("StrictWarnf() recorded warnings %v, want %v", ctx.warnings, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+// goCacheLayerName is the name of the layer where GOCACHE is stored.
+const goCacheLayerName = "gocache"
+
+// ClearCacheRequested returns true if the user asked to discard the cached Go build and module
+// caches by setting GOOGLE_GO_CLEAR_CACHE.
+func ClearCacheRequested() (bool, error) {
+	clear, err := env.IsPresentAndTrue(env.GoClearCache)
+	if err != nil {
+		return false, gcp.UserErrorf("invalid %s: %v", env.GoClearCache, err)
+	}
+	return clear, nil
+}
+
+// NewGoBuildCacheLayer returns a layer for GOCACHE that is kept across builds so that packages
+// that did not change are not compiled again. The build cache does not need to be invalidated:
+// its entries are keyed on the content of their inputs and unused entries are trimmed by Go.
+func NewGoBuildCacheLayer(ctx *gcp.Context) (*libcnb.Layer, error) {
+	// Keep GOCACHE in Devmode for faster rebuilds.
+	l, err := ctx.Layer(goCacheLayerName, gcp.BuildLayer, gcp.CacheLayer, gcp.LaunchLayerIfDevMode)
+	if err != nil {
+		return nil, fmt.Errorf("creating %v layer: %w", goCacheLayerName, err)
+	}
+	clear, err := ClearCacheRequested()
+	if err != nil {
+		return nil, err
+	}
+	if clear {
+		ctx.Logf("%s is set: clearing the Go build cache", env.GoClearCache)
+		if err := ctx.ClearLayer(l); err != nil {
+			return nil, fmt.Errorf("clearing layer %q: %w", l.Name, err)
+		}
+	}
+	return l, nil
+}
@@ -39,6 +39,9 @@ const (
 	goPathLayerName = "gopath"
 	// The key used when a layers' cache is keyed off of the go mod
 	goModCacheKey = "go-mod-sha"
+	// goPathCacheFormatVersion identifies the layout of the cached GOPATH layer. Bump it whenever the
+	// way the layer is populated changes.
+	goPathCacheFormatVersion = "v1"
 )
 
 var (
@@ -131,15 +134,20 @@ func GoVersion(ctx *gcp.Context) (string, error) {
 	return match[1], nil
 }
 
-// GoModVersion reads the version of Go from a go.mod file if present.
-// If not present or if version isn't there returns an empty string.
+// GoModVersion reads the version of Go from a go.mod file if present, or from the go.work file of a
+// workspace without a go.mod at its root. If not present or if version isn't there returns an empty string.
 func GoModVersion(ctx *gcp.Context) (string, error) {
 	v, err := readGoMod(ctx)
 	if err != nil {
 		return "", fmt.Errorf("reading go.mod: %w", err)
 	}
 	if v == "" {
-		return v, nil
+		// A workspace without a go.mod at its root declares the Go version in go.work.
+		w, err := readGoWork(ctx)
+		if err != nil || w == nil {
+			return "", err
+		}
+		return w.Go, nil
 	}
 
 	match := goModVersionRegexp.FindStringSubmatch(v)
@@ -187,6 +195,10 @@ var readGoMod = func(ctx *gcp.Context) (string, error) {
 	return string(bytes), nil
 }
 
+// readGoWork returns the go.work file of the application.
+// It can be overridden for testing.
+var readGoWork = ReadGoWork
+
 // NewGoWorkspaceLayer returns a new layer for `go env GOPATH` or the go workspace. The
 // layer is configured for caching if possible. It only supports caching for "go mod"
 // based builds.
@@ -210,7 +222,11 @@ func NewGoWorkspaceLayer(ctx *gcp.Context) (*libcnb.Layer, error) {
 		return l, nil
 	}
 
-	sha, err := cache.Hash(ctx, cache.WithFiles(goModPath(ctx)))
+	keyFiles, err := goModCacheKeyFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	hit, sha, err := cache.CheckCache(ctx, l, cache.WithFormatVersion(goPathCacheFormatVersion), goModCacheKey, cache.WithFiles(keyFiles...))
 	if err != nil {
 		if os.IsNotExist(err) {
 			// when go.mod doesn't exist, clear any previously cached bits and return an empty layer
@@ -220,18 +236,60 @@ func NewGoWorkspaceLayer(ctx *gcp.Context) (*libcnb.Layer, error) {
 		}
 		return nil, err
 	}
-	shaStr := fmt.Sprintf("%x", sha)
-	if shaStr == ctx.GetMetadata(l, goModCacheKey) {
+	clearCache, err := ClearCacheRequested()
+	if err != nil {
+		return nil, err
+	}
+	if hit && !clearCache {
 		ctx.Logf("GOPATH layer cache hit")
 		ctx.CacheHit(goPathLayerName)
 		return l, nil
 	}
-	ctx.Debugf("go.mod SHA has changed: clearing GOPATH layer's cache")
+	ctx.CacheMiss(goPathLayerName)
+	if clearCache {
+		ctx.Logf("%s is set: clearing GOPATH layer's cache", env.GoClearCache)
+	} else {
+		ctx.Debugf("go.mod, go.sum or go.work SHA has changed: clearing GOPATH layer's cache")
+	}
 	cleanModCache(ctx)
-	ctx.SetMetadata(l, goModCacheKey, shaStr)
+	ctx.SetMetadata(l, goModCacheKey, sha)
+	if _, err := cache.RestoreRemote(ctx, l, sha); err != nil {
+		return nil, err
+	}
 	return l, nil
 }
 
+// SaveRemoteCache uploads the GOPATH layer created by NewGoWorkspaceLayer to the remote cache
+// configured by GOOGLE_REMOTE_CACHE, if any, once the modules are downloaded.
+func SaveRemoteCache(ctx *gcp.Context, l *libcnb.Layer) error {
+	if !l.Cache {
+		return nil
+	}
+	return cache.SaveRemote(ctx, l, ctx.GetMetadata(l, goModCacheKey))
+}
+
+// goModCacheKeyFiles returns the files that determine the content of the module cache.
+func goModCacheKeyFiles(ctx *gcp.Context) ([]string, error) {
+	w, err := ReadGoWork(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if w != nil {
+		return w.CacheKeyFiles(ctx)
+	}
+	// go.sum pins the content of every module, it is not always present before `go mod tidy` runs.
+	keyFiles := []string{goModPath(ctx)}
+	goSumPath := filepath.Join(ctx.ApplicationRoot(), "go.sum")
+	goSumExists, err := ctx.FileExists(goSumPath)
+	if err != nil {
+		return nil, err
+	}
+	if goSumExists {
+		keyFiles = append(keyFiles, goSumPath)
+	}
+	return keyFiles, nil
+}
+
 func goModPath(ctx *gcp.Context) string {
 	return filepath.Join(ctx.ApplicationRoot(), "go.mod")
 }
@@ -240,7 +298,19 @@ func goModPath(ctx *gcp.Context) string {
 // Before Go 1.14, Go would fall back to direct only if a 404 or 410 error ocurred, for those
 // versions, we explictly disable GOPROXY and try again on any error.
 // For newer versions of Go, we take advantage of the "pipe" character which has the same effect.
+//
+// A GOPROXY set by the user is honored, modules matching GOPRIVATE are fetched directly from their
+// origin with the credentials specified by GOOGLE_GIT_CREDENTIALS or GOOGLE_NETRC.
 func ExecWithGoproxyFallback(ctx *gcp.Context, cmd []string, opts ...gcp.ExecOption) (*gcp.ExecResult, error) {
+	privateEnv, err := privateModulesEnv(ctx)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, gcp.WithEnv(privateEnv...))
+	// GOPROXY=off is set by the GOPATH layer for the build of the application.
+	if p := os.Getenv("GOPROXY"); p != "" && p != "off" {
+		return ctx.Exec(cmd, opts...)
+	}
 	supportsGoProxy, err := SupportsGoProxyFallback(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("checking for go proxy support: %w", err)
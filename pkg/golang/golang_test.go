@@ -21,6 +21,9 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/GoogleCloudPlatform/buildpacks/internal/cacheformat"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/testdata"
 	"github.com/buildpacks/libcnb"
 
@@ -517,12 +520,15 @@ func mockReadGoVersion(t *testing.T, goVer string) {
 	})
 }
 
-// mockReadGoMod mocks the readGoMod
+// mockReadGoMod mocks the readGoMod, the application has no go.work file
 func mockReadGoMod(t *testing.T, goMod string) {
 	origReadGoMod := readGoMod
+	origReadGoWork := readGoWork
 	readGoMod = func(*gcp.Context) (string, error) { return goMod, nil }
+	readGoWork = func(*gcp.Context) (*GoWork, error) { return nil, nil }
 	t.Cleanup(func() {
 		readGoMod = origReadGoMod
+		readGoWork = origReadGoWork
 	})
 }
 
@@ -534,3 +540,63 @@ func mockCleanModCache(t *testing.T) {
 		cleanModCache = origCleanModCache
 	})
 }
+
+func TestCacheFormatVersion(t *testing.T) {
+	cacheformat.Check(t, "testdata/cache_format.golden", cache.WithFormatVersion(goPathCacheFormatVersion), "golang.go")
+}
+
+func TestNewGoBuildCacheLayer(t *testing.T) {
+	testCases := []struct {
+		name      string
+		clear     string
+		wantKept  bool
+		wantError bool
+	}{
+		{
+			name:     "cached across builds",
+			wantKept: true,
+		},
+		{
+			name:  "clear cache",
+			clear: "true",
+		},
+		{
+			name:      "invalid clear cache",
+			clear:     "maybe",
+			wantError: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.clear != "" {
+				t.Setenv(env.GoClearCache, tc.clear)
+			}
+			layers := t.TempDir()
+			cached := filepath.Join(layers, goCacheLayerName, "00", "entry-a")
+			if err := os.MkdirAll(filepath.Dir(cached), 0755); err != nil {
+				t.Fatalf("creating cache entry dir: %v", err)
+			}
+			if err := ioutil.WriteFile(cached, []byte("a"), 0644); err != nil {
+				t.Fatalf("writing cache entry: %v", err)
+			}
+			ctx := gcp.NewContext(gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layers}}))
+
+			l, err := NewGoBuildCacheLayer(ctx)
+			if tc.wantError {
+				if err == nil {
+					t.Fatalf("NewGoBuildCacheLayer() got no error, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewGoBuildCacheLayer() got error: %v", err)
+			}
+			if !l.Cache || !l.Build {
+				t.Errorf("NewGoBuildCacheLayer() layer cache=%t build=%t, want both true", l.Cache, l.Build)
+			}
+			if _, err := os.Stat(cached); (err == nil) != tc.wantKept {
+				t.Errorf("cache entry exists = %t, want %t", err == nil, tc.wantKept)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// GoWorkFile is the name of the file that declares a Go workspace, see
+// https://go.dev/ref/mod#workspaces.
+const GoWorkFile = "go.work"
+
+// GoWork is the subset of a go.work file used by the buildpacks.
+type GoWork struct {
+	// Go is the Go version of the go directive.
+	Go string
+	// Toolchain is the Go toolchain of the toolchain directive, e.g. "go1.22.3".
+	Toolchain string
+	// Use are the directories of the workspace modules, relative to the application root.
+	Use []string
+}
+
+// ReadGoWork returns the go.work file at the application root, or nil if the application is not a
+// Go workspace. Workspace mode is disabled when GOWORK is set to "off".
+func ReadGoWork(ctx *gcp.Context) (*GoWork, error) {
+	if os.Getenv("GOWORK") == "off" {
+		return nil, nil
+	}
+	path := filepath.Join(ctx.ApplicationRoot(), GoWorkFile)
+	exists, err := ctx.FileExists(path)
+	if err != nil || !exists {
+		return nil, err
+	}
+	content, err := ctx.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGoWork(string(content))
+}
+
+// ParseGoWork parses the go, toolchain and use directives of a go.work file.
+func ParseGoWork(content string) (*GoWork, error) {
+	w := &GoWork{}
+	inUseBlock := false
+	for i, line := range strings.Split(content, "\n") {
+		if c := strings.Index(line, "//"); c >= 0 {
+			line = line[:c]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if inUseBlock {
+			if fields[0] == ")" {
+				inUseBlock = false
+			} else {
+				w.Use = append(w.Use, useDir(fields[0]))
+			}
+			continue
+		}
+		switch fields[0] {
+		case "go":
+			if len(fields) != 2 {
+				return nil, gcp.UserErrorf("parsing %s: line %d: invalid go directive", GoWorkFile, i+1).WithCode(buildererror.CodeGoWorkParseError)
+			}
+			w.Go = fields[1]
+		case "toolchain":
+			if len(fields) != 2 {
+				return nil, gcp.UserErrorf("parsing %s: line %d: invalid toolchain directive", GoWorkFile, i+1).WithCode(buildererror.CodeGoWorkParseError)
+			}
+			w.Toolchain = fields[1]
+		case "use":
+			switch {
+			case len(fields) == 2 && fields[1] == "(":
+				inUseBlock = true
+			case len(fields) == 2:
+				w.Use = append(w.Use, useDir(fields[1]))
+			default:
+				return nil, gcp.UserErrorf("parsing %s: line %d: invalid use directive", GoWorkFile, i+1).WithCode(buildererror.CodeGoWorkParseError)
+			}
+		}
+	}
+	if inUseBlock {
+		return nil, gcp.UserErrorf("parsing %s: unterminated use block", GoWorkFile).WithCode(buildererror.CodeGoWorkParseError)
+	}
+	return w, nil
+}
+
+// useDir returns the cleaned directory of a use directive, which may be quoted.
+func useDir(dir string) string {
+	return filepath.Clean(strings.Trim(dir, "\"`"))
+}
+
+// UsesRoot returns whether the module at the application root is part of the workspace.
+func (w *GoWork) UsesRoot() bool {
+	for _, dir := range w.Use {
+		if dir == "." {
+			return true
+		}
+	}
+	return false
+}
+
+// Patterns returns the `go list` patterns that match all the packages of the workspace modules.
+func (w *GoWork) Patterns() []string {
+	var patterns []string
+	for _, dir := range w.Use {
+		patterns = append(patterns, "./"+filepath.ToSlash(filepath.Join(dir, "...")))
+	}
+	return patterns
+}
+
+// CacheKeyFiles returns the files that determine the modules downloaded for the workspace: go.work,
+// go.work.sum and the go.mod and go.sum files of the workspace modules, if they exist.
+func (w *GoWork) CacheKeyFiles(ctx *gcp.Context) ([]string, error) {
+	candidates := []string{GoWorkFile, GoWorkFile + ".sum"}
+	for _, dir := range w.Use {
+		candidates = append(candidates, filepath.Join(dir, "go.mod"), filepath.Join(dir, "go.sum"))
+	}
+	var files []string
+	for _, f := range candidates {
+		path := filepath.Join(ctx.ApplicationRoot(), f)
+		exists, err := ctx.FileExists(path)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
+
+// ResolveBuildable resolves a package directory that is given relative to one of the workspace
+// modules, e.g. "./cmd/server" of the module in "services/api", to a path relative to the
+// application root. It returns the buildable unchanged if it exists relative to the application
+// root, is not a relative path, or does not match exactly one workspace module.
+func (w *GoWork) ResolveBuildable(ctx *gcp.Context, buildable string) (string, error) {
+	if !strings.HasPrefix(buildable, ".") {
+		// An import path, e.g. example.com/api/cmd/server.
+		return buildable, nil
+	}
+	exists, err := ctx.FileExists(ctx.ApplicationRoot(), buildable)
+	if err != nil || exists {
+		return buildable, err
+	}
+	var matches []string
+	for _, dir := range w.Use {
+		candidate := filepath.Join(dir, buildable)
+		exists, err := ctx.FileExists(ctx.ApplicationRoot(), candidate)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			matches = append(matches, "./"+filepath.ToSlash(candidate))
+		}
+	}
+	if len(matches) != 1 {
+		return buildable, nil
+	}
+	return matches[0], nil
+}
+
+// Env returns the environment of go commands run in workspace mode. -mod flags in GOFLAGS are
+// dropped unless set to readonly or vendor since workspace mode rejects them, e.g. "go: -mod may
+// only be set to readonly or vendor when in workspace mode".
+func (w *GoWork) Env() []string {
+	goflags, changed := workspaceGoFlags(os.Getenv("GOFLAGS"))
+	if !changed {
+		return nil
+	}
+	return []string{"GOFLAGS=" + goflags}
+}
+
+func workspaceGoFlags(goflags string) (string, bool) {
+	var kept []string
+	changed := false
+	for _, f := range strings.Fields(goflags) {
+		if strings.HasPrefix(f, "-mod=") && f != "-mod=readonly" && f != "-mod=vendor" {
+			changed = true
+			continue
+		}
+		kept = append(kept, f)
+	}
+	return strings.Join(kept, " "), changed
+}
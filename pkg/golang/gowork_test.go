@@ -0,0 +1,230 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseGoWork(t *testing.T) {
+	testCases := []struct {
+		name    string
+		gowork  string
+		want    *GoWork
+		wantErr bool
+	}{
+		{
+			name:   "single use",
+			gowork: "go 1.21\n\nuse ./api\n",
+			want:   &GoWork{Go: "1.21", Use: []string{"api"}},
+		},
+		{
+			name: "use block with comments",
+			gowork: `go 1.22.1 // minimum version
+
+toolchain go1.22.3
+
+use (
+	. // the root module
+	./services/api
+	"./services/worker"
+)
+
+replace example.com/lib => ./lib
+`,
+			want: &GoWork{Go: "1.22.1", Toolchain: "go1.22.3", Use: []string{".", "services/api", "services/worker"}},
+		},
+		{
+			name:    "unterminated use block",
+			gowork:  "go 1.21\nuse (\n\t./api\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid use directive",
+			gowork:  "use ./api ./worker\n",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseGoWork(tc.gowork)
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("ParseGoWork() got error: %v, want error? %v", err, tc.wantErr)
+			}
+			if tc.wantErr && buildererror.CodeOf(err) != buildererror.CodeGoWorkParseError {
+				t.Errorf("ParseGoWork() error code = %q, want %q", buildererror.CodeOf(err), buildererror.CodeGoWorkParseError)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("ParseGoWork() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestReadGoWork(t *testing.T) {
+	testCases := []struct {
+		name   string
+		gowork string
+		envs   map[string]string
+		want   *GoWork
+	}{
+		{
+			name: "no go.work",
+		},
+		{
+			name:   "go.work",
+			gowork: "go 1.21\nuse ./api\n",
+			want:   &GoWork{Go: "1.21", Use: []string{"api"}},
+		},
+		{
+			name:   "GOWORK=off",
+			gowork: "go 1.21\nuse ./api\n",
+			envs:   map[string]string{"GOWORK": "off"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.envs {
+				t.Setenv(k, v)
+			}
+			dir := t.TempDir()
+			if tc.gowork != "" {
+				if err := os.WriteFile(filepath.Join(dir, GoWorkFile), []byte(tc.gowork), 0644); err != nil {
+					t.Fatalf("writing go.work: %v", err)
+				}
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+
+			got, err := ReadGoWork(ctx)
+			if err != nil {
+				t.Fatalf("ReadGoWork() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("ReadGoWork() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGoModVersionFromGoWork(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, GoWorkFile), []byte("go 1.21\nuse ./api\n"), 0644); err != nil {
+		t.Fatalf("writing go.work: %v", err)
+	}
+	ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+
+	got, err := GoModVersion(ctx)
+	if err != nil {
+		t.Fatalf("GoModVersion() got error: %v", err)
+	}
+	if got != "1.21" {
+		t.Errorf("GoModVersion() = %q, want %q", got, "1.21")
+	}
+}
+
+func TestGoWorkResolveBuildable(t *testing.T) {
+	testCases := []struct {
+		name      string
+		files     []string
+		buildable string
+		want      string
+	}{
+		{
+			name:      "relative to a workspace module",
+			files:     []string{"api/cmd/server/main.go", "worker/main.go"},
+			buildable: "./cmd/server",
+			want:      "./api/cmd/server",
+		},
+		{
+			name:      "relative to the application root",
+			files:     []string{"api/cmd/server/main.go"},
+			buildable: "./api/cmd/server",
+			want:      "./api/cmd/server",
+		},
+		{
+			name:      "ambiguous",
+			files:     []string{"api/cmd/server/main.go", "worker/cmd/server/main.go"},
+			buildable: "./cmd/server",
+			want:      "./cmd/server",
+		},
+		{
+			name:      "import path",
+			files:     []string{"api/cmd/server/main.go"},
+			buildable: "example.com/api/cmd/server",
+			want:      "example.com/api/cmd/server",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range tc.files {
+				path := filepath.Join(dir, f)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("creating directory: %v", err)
+				}
+				if err := os.WriteFile(path, nil, 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+			w := &GoWork{Use: []string{"api", "worker"}}
+
+			got, err := w.ResolveBuildable(ctx, tc.buildable)
+			if err != nil {
+				t.Fatalf("ResolveBuildable(%q) got error: %v", tc.buildable, err)
+			}
+			if got != tc.want {
+				t.Errorf("ResolveBuildable(%q) = %q, want %q", tc.buildable, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGoWorkEnv(t *testing.T) {
+	testCases := []struct {
+		name    string
+		goflags string
+		want    []string
+	}{
+		{
+			name: "no GOFLAGS",
+		},
+		{
+			name:    "-mod=mod is dropped",
+			goflags: "-mod=mod -trimpath",
+			want:    []string{"GOFLAGS=-trimpath"},
+		},
+		{
+			name:    "-mod=readonly is kept",
+			goflags: "-mod=readonly -trimpath",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("GOFLAGS", tc.goflags)
+			w := &GoWork{Use: []string{"api"}}
+
+			if diff := cmp.Diff(tc.want, w.Env()); diff != "" {
+				t.Errorf("Env() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
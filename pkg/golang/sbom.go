@@ -0,0 +1,88 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"fmt"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// GoModSBOMEntries returns the SBOM entries of the modules required by the go.mod file, taking
+// replace directives into account. Modules replaced by a local directory are omitted.
+func GoModSBOMEntries(ctx *gcp.Context) ([]gcp.SBOMEntry, error) {
+	v, err := readGoMod(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading go.mod: %w", err)
+	}
+	requires := map[string]string{}
+	var order []string
+	replaces := map[string][]string{}
+	// block is the directive of the enclosing "directive (...)" block, if any.
+	var block string
+	for _, line := range strings.Split(v, "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		directive := block
+		switch {
+		case block != "":
+			if fields[0] == ")" {
+				block = ""
+				continue
+			}
+		case len(fields) == 2 && fields[1] == "(":
+			block = fields[0]
+			continue
+		default:
+			directive, fields = fields[0], fields[1:]
+		}
+		switch directive {
+		case "require":
+			if len(fields) < 2 {
+				continue
+			}
+			if _, ok := requires[fields[0]]; !ok {
+				order = append(order, fields[0])
+			}
+			requires[fields[0]] = fields[1]
+		case "replace":
+			// Either "old => new [version]" or "old version => new [version]".
+			for i, f := range fields {
+				if f == "=>" && i > 0 {
+					replaces[fields[0]] = fields[i+1:]
+				}
+			}
+		}
+	}
+	var entries []gcp.SBOMEntry
+	for _, mod := range order {
+		name, version := mod, requires[mod]
+		if r, ok := replaces[mod]; ok {
+			if len(r) < 2 {
+				// A replacement without a version is a local directory.
+				continue
+			}
+			name, version = r[0], r[1]
+		}
+		entries = append(entries, gcp.SBOMEntry{Name: name, Version: version, PURLType: "golang"})
+	}
+	return entries, nil
+}
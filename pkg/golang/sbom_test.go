@@ -0,0 +1,91 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGoModSBOMEntries(t *testing.T) {
+	testCases := []struct {
+		name  string
+		gomod string
+		want  []gcp.SBOMEntry
+	}{
+		{
+			name: "no go.mod",
+		},
+		{
+			name: "require block",
+			gomod: `
+module example.com/app
+
+go 1.20
+
+require (
+	cloud.google.com/go/storage v1.30.1
+	github.com/google/uuid v1.3.0 // indirect
+)
+`,
+			want: []gcp.SBOMEntry{
+				{Name: "cloud.google.com/go/storage", Version: "v1.30.1", PURLType: "golang"},
+				{Name: "github.com/google/uuid", Version: "v1.3.0", PURLType: "golang"},
+			},
+		},
+		{
+			name: "single line requires and replaces",
+			gomod: `
+module example.com/app
+
+require github.com/google/uuid v1.3.0
+require golang.org/x/sys v0.5.0
+require example.com/lib v1.0.0
+
+replace golang.org/x/sys v0.5.0 => golang.org/x/sys v0.6.0
+replace (
+	example.com/lib => ../lib
+)
+`,
+			want: []gcp.SBOMEntry{
+				{Name: "github.com/google/uuid", Version: "v1.3.0", PURLType: "golang"},
+				{Name: "golang.org/x/sys", Version: "v0.6.0", PURLType: "golang"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tc.gomod != "" {
+				if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte(tc.gomod), 0644); err != nil {
+					t.Fatalf("writing go.mod: %v", err)
+				}
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+
+			got, err := GoModSBOMEntries(ctx)
+			if err != nil {
+				t.Fatalf("GoModSBOMEntries() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("GoModSBOMEntries() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// ToolchainEnv is the environment variable that selects the Go toolchain, see
+// https://go.dev/doc/toolchain#select.
+const ToolchainEnv = "GOTOOLCHAIN"
+
+var (
+	// goModToolchainRegexp is used to get the toolchain directive from a go.mod file.
+	goModToolchainRegexp = regexp.MustCompile(`(?m)^\s*toolchain\s+(\S+)\s*(//.*)?$`)
+
+	// toolchainVersionRegexp matches the versions of Go toolchains, e.g. "1.22", "1.22rc1" or
+	// "1.22.3", capturing the major, minor and patch numbers and the pre-release kind and number.
+	toolchainVersionRegexp = regexp.MustCompile(`^(\d+)\.(\d+)(?:\.(\d+)|(beta|rc)(\d+))?$`)
+)
+
+// ToolchainVersion returns the version of the Go toolchain requested by the application, or an
+// empty string if it does not request one. It follows the toolchain selection of Go 1.21 and newer:
+//   - GOTOOLCHAIN=go1.22.3 selects that version, ignoring go.mod.
+//   - GOTOOLCHAIN=go1.22.3+auto selects the newest of that version and the versions required by the
+//     go and toolchain directives of go.mod, or go.work for a workspace.
+//   - GOTOOLCHAIN=auto, or unset, selects the newest of the versions required by the go and
+//     toolchain directives if go.mod has a toolchain directive.
+//   - GOTOOLCHAIN=local does not request a version.
+func ToolchainVersion(ctx *gcp.Context) (string, error) {
+	name, mode := os.Getenv(ToolchainEnv), ""
+	if i := strings.Index(name, "+"); i >= 0 {
+		name, mode = name[:i], name[i+1:]
+	}
+	var minimum string
+	switch {
+	case name == "" || name == "auto" || name == "path":
+	case name == "local":
+		ctx.Debugf("%s=local, ignoring the toolchain directive of go.mod.", ToolchainEnv)
+		return "", nil
+	case strings.HasPrefix(name, "go") && isToolchainVersion(strings.TrimPrefix(name, "go")):
+		minimum = strings.TrimPrefix(name, "go")
+		if mode == "" {
+			ctx.Logf("Using Go toolchain from %s: %s", ToolchainEnv, name)
+			return minimum, nil
+		}
+	default:
+		return "", gcp.UserErrorf("invalid %s %q, must be local, auto, path or a Go toolchain such as go1.22.3 with an optional +auto or +path suffix", ToolchainEnv, os.Getenv(ToolchainEnv))
+	}
+
+	goVersion, toolchain, err := goModDirectives(ctx)
+	if err != nil {
+		return "", err
+	}
+	if toolchain == "" && minimum == "" {
+		return "", nil
+	}
+	version := minimum
+	for _, v := range []string{toolchain, goVersion} {
+		if isToolchainVersion(v) && (version == "" || compareToolchainVersions(v, version) > 0) {
+			version = v
+		}
+	}
+	// Since Go 1.21 the go directive is a language version, e.g. "1.22", which is first released
+	// as the "1.22.0" toolchain.
+	if m := toolchainVersionRegexp.FindStringSubmatch(version); m != nil && m[3] == "" && m[4] == "" {
+		if minor, _ := strconv.Atoi(m[2]); m[1] == "1" && minor >= 21 {
+			version += ".0"
+		}
+	}
+	if minimum != "" {
+		ctx.Logf("Using Go toolchain required by go.mod and %s: go%s", ToolchainEnv, version)
+	} else {
+		ctx.Logf("Using Go toolchain required by go.mod: go%s", version)
+	}
+	return version, nil
+}
+
+// goModDirectives returns the versions of the go and toolchain directives of the go.mod file, or of
+// the go.work file of a workspace without a go.mod at its root, without the "go" prefix.
+func goModDirectives(ctx *gcp.Context) (string, string, error) {
+	goMod, err := readGoMod(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	if goMod == "" {
+		w, err := readGoWork(ctx)
+		if err != nil || w == nil {
+			return "", "", err
+		}
+		return w.Go, strings.TrimPrefix(w.Toolchain, "go"), nil
+	}
+	var goVersion, toolchain string
+	if m := goModVersionRegexp.FindStringSubmatch(goMod); len(m) > 1 {
+		goVersion = m[1]
+	}
+	if m := goModToolchainRegexp.FindStringSubmatch(goMod); len(m) > 1 {
+		toolchain = strings.TrimPrefix(m[1], "go")
+	}
+	return goVersion, toolchain, nil
+}
+
+func isToolchainVersion(v string) bool {
+	return toolchainVersionRegexp.MatchString(v)
+}
+
+// compareToolchainVersions returns -1, 0 or 1 if the Go version a is older, equal or newer than b.
+// Language versions are older than their pre-releases, which are older than their releases, e.g.
+// 1.22 < 1.22rc1 < 1.22.0 < 1.22.3.
+func compareToolchainVersions(a, b string) int {
+	ka, kb := toolchainVersionKey(a), toolchainVersionKey(b)
+	for i := range ka {
+		if ka[i] < kb[i] {
+			return -1
+		}
+		if ka[i] > kb[i] {
+			return 1
+		}
+	}
+	return 0
+}
+
+func toolchainVersionKey(v string) [5]int {
+	m := toolchainVersionRegexp.FindStringSubmatch(v)
+	if m == nil {
+		return [5]int{}
+	}
+	atoi := func(s string) int {
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	// The third element orders language versions, betas, release candidates and releases.
+	key := [5]int{atoi(m[1]), atoi(m[2]), 0, 0, 0}
+	switch {
+	case m[3] != "":
+		key[2], key[3] = 3, atoi(m[3])
+	case m[4] == "beta":
+		key[2], key[4] = 1, atoi(m[5])
+	case m[4] == "rc":
+		key[2], key[4] = 2, atoi(m[5])
+	}
+	return key
+}
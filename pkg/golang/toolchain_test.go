@@ -0,0 +1,128 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestToolchainVersion(t *testing.T) {
+	testCases := []struct {
+		name        string
+		goMod       string
+		goWork      *GoWork
+		goToolchain string
+		want        string
+		wantErr     bool
+	}{
+		{
+			name:  "no toolchain directive",
+			goMod: "module example.com/app\n\ngo 1.22\n",
+		},
+		{
+			name:  "toolchain directive",
+			goMod: "module example.com/app\n\ngo 1.21\n\ntoolchain go1.22.3\n",
+			want:  "1.22.3",
+		},
+		{
+			name:  "go directive newer than toolchain directive",
+			goMod: "module example.com/app\n\ngo 1.22.4\n\ntoolchain go1.22.3\n",
+			want:  "1.22.4",
+		},
+		{
+			name:  "language version is released as .0",
+			goMod: "module example.com/app\n\ngo 1.23\n\ntoolchain go1.22.3\n",
+			want:  "1.23.0",
+		},
+		{
+			name:  "toolchain release candidate",
+			goMod: "module example.com/app\n\ngo 1.23\n\ntoolchain go1.23rc2\n",
+			want:  "1.23rc2",
+		},
+		{
+			name:        "GOTOOLCHAIN version ignores go.mod",
+			goMod:       "module example.com/app\n\ngo 1.21\n\ntoolchain go1.22.3\n",
+			goToolchain: "go1.21.5",
+			want:        "1.21.5",
+		},
+		{
+			name:        "GOTOOLCHAIN auto minimum older than toolchain directive",
+			goMod:       "module example.com/app\n\ngo 1.21\n\ntoolchain go1.22.3\n",
+			goToolchain: "go1.21.5+auto",
+			want:        "1.22.3",
+		},
+		{
+			name:        "GOTOOLCHAIN auto minimum newer than go.mod",
+			goMod:       "module example.com/app\n\ngo 1.21\n",
+			goToolchain: "go1.22.1+auto",
+			want:        "1.22.1",
+		},
+		{
+			name:        "GOTOOLCHAIN local",
+			goMod:       "module example.com/app\n\ngo 1.21\n\ntoolchain go1.22.3\n",
+			goToolchain: "local",
+		},
+		{
+			name:        "GOTOOLCHAIN auto",
+			goMod:       "module example.com/app\n\ngo 1.21\n\ntoolchain go1.22.3\n",
+			goToolchain: "auto",
+			want:        "1.22.3",
+		},
+		{
+			name:   "go.work toolchain directive",
+			goWork: &GoWork{Go: "1.21", Toolchain: "go1.22.3"},
+			want:   "1.22.3",
+		},
+		{
+			name:        "invalid GOTOOLCHAIN",
+			goToolchain: "latest",
+			wantErr:     true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockReadGoMod(t, tc.goMod)
+			readGoWork = func(*gcp.Context) (*GoWork, error) { return tc.goWork, nil }
+			t.Setenv(ToolchainEnv, tc.goToolchain)
+
+			got, err := ToolchainVersion(gcp.NewContext())
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("ToolchainVersion() got error: %v, want error? %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("ToolchainVersion() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCompareToolchainVersions(t *testing.T) {
+	ordered := []string{"1.21", "1.21rc1", "1.21.0", "1.21.9", "1.22beta1", "1.22rc1", "1.22rc2", "1.22.0", "1.22.10", "2.0"}
+	for i := range ordered {
+		for j := range ordered {
+			want := 0
+			if i < j {
+				want = -1
+			} else if i > j {
+				want = 1
+			}
+			if got := compareToolchainVersions(ordered[i], ordered[j]); got != want {
+				t.Errorf("compareToolchainVersions(%q, %q) = %d, want %d", ordered[i], ordered[j], got, want)
+			}
+		}
+	}
+}
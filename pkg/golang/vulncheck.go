@@ -0,0 +1,295 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/audit"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/Masterminds/semver"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// VulncheckEnv is an environment variable that enables the govulncheck scan of the application
+	// after it is compiled, see audit.ParseConfig for its values. govulncheck does not report
+	// severities, so any value that fails the build fails it on any called vulnerability.
+	VulncheckEnv = "GOOGLE_GO_VULNCHECK"
+
+	govulncheckVersion = "v1.0.4"
+	govulncheckLayer   = "govulncheck"
+	vulnDBLayer        = "govulndb"
+	versionKey         = "version"
+
+	// stdlibModule is the module path govulncheck uses for the Go standard library.
+	stdlibModule = "stdlib"
+)
+
+var (
+	// govulncheckPackage is the package installed to run govulncheck.
+	govulncheckPackage = "golang.org/x/vuln/cmd/govulncheck"
+	// vulnDBURL is the URL of the archive of the Go vulnerability database, it is a var for testing.
+	vulnDBURL = "https://vuln.go.dev/vulndb.zip"
+	// vulnURL is the URL of the vulnerability details on https://pkg.go.dev.
+	vulnURL = "https://pkg.go.dev/vuln/%s"
+)
+
+// Vulncheck scans the packages matching the given patterns with govulncheck if
+// GOOGLE_GO_VULNCHECK enables it. Only vulnerabilities in functions that the application calls are
+// reported. goCache is the GOCACHE used to compile govulncheck, the options configure the
+// environment and working directory in which the patterns are loaded.
+func Vulncheck(ctx *gcp.Context, goCache string, patterns []string, opts ...gcp.ExecOption) error {
+	cfg, err := audit.ParseConfig(VulncheckEnv)
+	if err != nil || !cfg.Enabled {
+		return err
+	}
+	bin, err := installGovulncheck(ctx, goCache)
+	if err != nil {
+		return err
+	}
+	db, err := vulnDB(ctx)
+	if err != nil {
+		return err
+	}
+	ctx.Logf("Checking for vulnerabilities with govulncheck.")
+	cmd := append([]string{bin, "-json", "-db", "file://" + db}, patterns...)
+	result, err := ctx.Exec(cmd, append(opts, gcp.WithUserAttribution)...)
+	if err != nil {
+		return err
+	}
+	res, err := parseGovulncheck(strings.NewReader(result.Stdout))
+	if err != nil {
+		return gcp.InternalErrorf("parsing govulncheck output: %v", err)
+	}
+	if res.uncalled > 0 {
+		ctx.Logf("govulncheck found %d vulnerabilities in imported packages or required modules that the application does not call.", res.uncalled)
+	}
+	return cfg.Check(ctx, audit.Report{Scanner: "govulncheck", FixCommand: res.fixCommand(), Findings: res.findings})
+}
+
+// installGovulncheck installs govulncheck in a build-only layer if it is not already cached and
+// returns the path of the executable.
+func installGovulncheck(ctx *gcp.Context, goCache string) (string, error) {
+	l, err := ctx.Layer(govulncheckLayer, gcp.BuildLayer, gcp.CacheLayer)
+	if err != nil {
+		return "", fmt.Errorf("creating %v layer: %w", govulncheckLayer, err)
+	}
+	binDir := filepath.Join(l.Path, "bin")
+	if ctx.GetMetadata(l, versionKey) == govulncheckVersion {
+		ctx.CacheHit(govulncheckLayer)
+	} else {
+		ctx.CacheMiss(govulncheckLayer)
+		if err := ctx.ClearLayer(l); err != nil {
+			return "", fmt.Errorf("clearing layer %q: %w", govulncheckLayer, err)
+		}
+		ctx.Logf("Installing govulncheck %s", govulncheckVersion)
+		// The module cache is only needed to compile govulncheck, it is not kept.
+		modCache, err := ctx.TempDir("govulncheck-mod")
+		if err != nil {
+			return "", err
+		}
+		if _, err := ctx.Exec([]string{"go", "install", govulncheckPackage + "@" + govulncheckVersion},
+			gcp.WithEnv("GOBIN="+binDir, "GOCACHE="+goCache, "GOMODCACHE="+modCache, "GOFLAGS=-modcacherw", "GOWORK=off"),
+			gcp.WithUserTimingAttribution); err != nil {
+			return "", err
+		}
+		ctx.SetMetadata(l, versionKey, govulncheckVersion)
+	}
+	ctx.AddBOMEntry(libcnb.BOMEntry{
+		Name:     govulncheckLayer,
+		Metadata: map[string]interface{}{"version": govulncheckVersion},
+		Build:    true,
+	})
+	return filepath.Join(binDir, "govulncheck"), nil
+}
+
+// vulnDB returns the directory of a local copy of the Go vulnerability database. It is kept in a
+// cached layer and only downloaded again when it changed since the previous build.
+func vulnDB(ctx *gcp.Context) (string, error) {
+	l, err := ctx.Layer(vulnDBLayer, gcp.BuildLayer, gcp.CacheLayer)
+	if err != nil {
+		return "", fmt.Errorf("creating %v layer: %w", vulnDBLayer, err)
+	}
+	archive := filepath.Join(l.Path, "vulndb.zip")
+	db := filepath.Join(l.Path, "db")
+	before := modTime(archive)
+	if err := ctx.Download(vulnDBURL, archive, gcp.WithETagCache(l)); err != nil {
+		return "", err
+	}
+	dbExists, err := ctx.FileExists(db)
+	if err != nil {
+		return "", err
+	}
+	if dbExists && modTime(archive) == before {
+		ctx.CacheHit(vulnDBLayer)
+		return db, nil
+	}
+	ctx.CacheMiss(vulnDBLayer)
+	if err := ctx.RemoveAll(db); err != nil {
+		return "", err
+	}
+	if _, err := ctx.Exec([]string{"unzip", "-q", archive, "-d", db}); err != nil {
+		return "", err
+	}
+	return db, nil
+}
+
+// modTime returns the modification time of a file in nanoseconds, or 0 if it does not exist.
+func modTime(path string) int64 {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return fi.ModTime().UnixNano()
+}
+
+// govulncheckFrame is a frame of the call stack from the application to a vulnerable symbol.
+type govulncheckFrame struct {
+	Module   string `json:"module"`
+	Version  string `json:"version"`
+	Package  string `json:"package"`
+	Function string `json:"function"`
+	Receiver string `json:"receiver"`
+}
+
+// symbol returns the name of the function of the frame, qualified by its package and receiver.
+func (f govulncheckFrame) symbol() string {
+	name := f.Function
+	if f.Receiver != "" {
+		name = strings.TrimPrefix(f.Receiver, "*") + "." + name
+	}
+	return f.Package + "." + name
+}
+
+// govulncheckMessage is a message of the JSON stream written by `govulncheck -json`.
+type govulncheckMessage struct {
+	OSV *struct {
+		ID      string `json:"id"`
+		Summary string `json:"summary"`
+	} `json:"osv"`
+	Finding *struct {
+		OSV          string             `json:"osv"`
+		FixedVersion string             `json:"fixed_version"`
+		Trace        []govulncheckFrame `json:"trace"`
+	} `json:"finding"`
+}
+
+// govulncheckResult holds the vulnerabilities reported by govulncheck.
+type govulncheckResult struct {
+	// findings are the vulnerabilities that the application calls.
+	findings []audit.Finding
+	// uncalled is the number of vulnerabilities in packages or modules that the application imports
+	// or requires but does not call.
+	uncalled int
+	// fixes maps each module of the findings to the version that fixes all of them.
+	fixes map[string]string
+}
+
+// parseGovulncheck parses the JSON stream written by `govulncheck -json`.
+func parseGovulncheck(r io.Reader) (*govulncheckResult, error) {
+	summaries := map[string]string{}
+	byID := map[string]*audit.Finding{}
+	var ids []string
+	all := map[string]bool{}
+	res := &govulncheckResult{fixes: map[string]string{}}
+	d := json.NewDecoder(r)
+	for {
+		var m govulncheckMessage
+		if err := d.Decode(&m); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if m.OSV != nil {
+			summaries[m.OSV.ID] = m.OSV.Summary
+		}
+		if m.Finding == nil || len(m.Finding.Trace) == 0 {
+			continue
+		}
+		all[m.Finding.OSV] = true
+		// The first frame is the vulnerable symbol, it only has a function if the vulnerability is
+		// called rather than only imported or required.
+		vuln := m.Finding.Trace[0]
+		if vuln.Function == "" {
+			continue
+		}
+		f, ok := byID[m.Finding.OSV]
+		if !ok {
+			f = &audit.Finding{ID: m.Finding.OSV, Package: vuln.Module, Version: vuln.Version, Severity: audit.SeverityUnknown, URL: fmt.Sprintf(vulnURL, m.Finding.OSV)}
+			byID[m.Finding.OSV] = f
+			ids = append(ids, m.Finding.OSV)
+		}
+		if s := vuln.symbol(); !containsString(f.Symbols, s) {
+			f.Symbols = append(f.Symbols, s)
+		}
+		if fixed := m.Finding.FixedVersion; fixed != "" && isNewerVersion(fixed, res.fixes[vuln.Module]) {
+			res.fixes[vuln.Module] = fixed
+		}
+	}
+	for _, id := range ids {
+		f := byID[id]
+		f.Title = summaries[id]
+		sort.Strings(f.Symbols)
+		res.findings = append(res.findings, *f)
+	}
+	res.uncalled = len(all) - len(res.findings)
+	return res, nil
+}
+
+// fixCommand returns the command that upgrades the vulnerable modules to the fixed versions.
+// Vulnerabilities of the standard library are fixed by upgrading Go instead.
+func (r *govulncheckResult) fixCommand() string {
+	var mods []string
+	for mod, v := range r.fixes {
+		if mod != stdlibModule {
+			mods = append(mods, mod+"@"+v)
+		}
+	}
+	if len(mods) == 0 {
+		return ""
+	}
+	sort.Strings(mods)
+	return "go get " + strings.Join(mods, " ")
+}
+
+// isNewerVersion returns true if the module version v is newer than the current one, or if there
+// is no current version.
+func isNewerVersion(v, current string) bool {
+	if current == "" {
+		return true
+	}
+	sv, err := semver.NewVersion(v)
+	if err != nil {
+		return false
+	}
+	cv, err := semver.NewVersion(current)
+	return err != nil || sv.GreaterThan(cv)
+}
+
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golang
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/audit"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseGovulncheck(t *testing.T) {
+	out := `{"config": {"protocol_version": "v1.0.0", "scanner_name": "govulncheck", "scan_level": "symbol"}}
+{"progress": {"message": "Scanning your code and 46 packages across 1 dependent module for known vulnerabilities..."}}
+{"osv": {"id": "GO-2022-1059", "summary": "Denial of service via crafted Accept-Language header in golang.org/x/text/language"}}
+{"osv": {"id": "GO-2023-1571", "summary": "Denial of service via crafted HTTP/2 stream in net/http and golang.org/x/net"}}
+{"osv": {"id": "GO-2023-1988", "summary": "Improper rendering of text nodes in golang.org/x/net/html"}}
+{"finding": {"osv": "GO-2022-1059", "fixed_version": "v0.3.8", "trace": [{"module": "golang.org/x/text", "version": "v0.3.7"}]}}
+{"finding": {"osv": "GO-2022-1059", "fixed_version": "v0.3.8", "trace": [{"module": "golang.org/x/text", "version": "v0.3.7", "package": "golang.org/x/text/language"}]}}
+{"finding": {"osv": "GO-2022-1059", "fixed_version": "v0.3.8", "trace": [{"module": "golang.org/x/text", "version": "v0.3.7", "package": "golang.org/x/text/language", "function": "Parse"}, {"module": "example.com/app", "package": "example.com/app", "function": "main"}]}}
+{"finding": {"osv": "GO-2022-1059", "fixed_version": "v0.3.8", "trace": [{"module": "golang.org/x/text", "version": "v0.3.7", "package": "golang.org/x/text/language", "function": "MatchStrings"}, {"module": "example.com/app", "package": "example.com/app", "function": "handler"}]}}
+{"finding": {"osv": "GO-2023-1571", "fixed_version": "v1.20.1", "trace": [{"module": "stdlib", "version": "v1.20.0", "package": "net/http", "function": "ServeHTTP", "receiver": "*Server"}]}}
+{"finding": {"osv": "GO-2023-1988", "fixed_version": "v0.13.0", "trace": [{"module": "golang.org/x/net", "version": "v0.7.0", "package": "golang.org/x/net/html"}]}}
+`
+	wantFindings := []audit.Finding{
+		{
+			ID:       "GO-2022-1059",
+			Package:  "golang.org/x/text",
+			Version:  "v0.3.7",
+			Severity: audit.SeverityUnknown,
+			Title:    "Denial of service via crafted Accept-Language header in golang.org/x/text/language",
+			URL:      "https://pkg.go.dev/vuln/GO-2022-1059",
+			Symbols:  []string{"golang.org/x/text/language.MatchStrings", "golang.org/x/text/language.Parse"},
+		},
+		{
+			ID:       "GO-2023-1571",
+			Package:  "stdlib",
+			Version:  "v1.20.0",
+			Severity: audit.SeverityUnknown,
+			Title:    "Denial of service via crafted HTTP/2 stream in net/http and golang.org/x/net",
+			URL:      "https://pkg.go.dev/vuln/GO-2023-1571",
+			Symbols:  []string{"net/http.Server.ServeHTTP"},
+		},
+	}
+
+	got, err := parseGovulncheck(strings.NewReader(out))
+	if err != nil {
+		t.Fatalf("parseGovulncheck() got error: %v", err)
+	}
+	if diff := cmp.Diff(wantFindings, got.findings); diff != "" {
+		t.Errorf("parseGovulncheck() findings mismatch (-want +got):\n%s", diff)
+	}
+	if got.uncalled != 1 {
+		t.Errorf("parseGovulncheck() uncalled = %d, want 1", got.uncalled)
+	}
+	if want := "go get golang.org/x/text@v0.3.8"; got.fixCommand() != want {
+		t.Errorf("fixCommand() = %q, want %q", got.fixCommand(), want)
+	}
+}
+
+func TestParseGovulncheckInvalid(t *testing.T) {
+	if _, err := parseGovulncheck(strings.NewReader(`govulncheck: loading packages: no Go files`)); err == nil {
+		t.Error("parseGovulncheck() got no error, want error")
+	}
+}
+
+func TestGovulncheckFixCommand(t *testing.T) {
+	testCases := []struct {
+		name  string
+		fixes map[string]string
+		want  string
+	}{
+		{
+			name: "no fixes",
+		},
+		{
+			name:  "standard library only",
+			fixes: map[string]string{"stdlib": "v1.20.1"},
+		},
+		{
+			name:  "sorted modules",
+			fixes: map[string]string{"golang.org/x/text": "v0.3.8", "golang.org/x/net": "v0.17.0", "stdlib": "v1.20.1"},
+			want:  "go get golang.org/x/net@v0.17.0 golang.org/x/text@v0.3.8",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &govulncheckResult{fixes: tc.fixes}
+			if got := r.fixCommand(); got != tc.want {
+				t.Errorf("fixCommand() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIsNewerVersion(t *testing.T) {
+	testCases := []struct {
+		v, current string
+		want       bool
+	}{
+		{v: "v0.3.8", current: "", want: true},
+		{v: "v0.17.0", current: "v0.7.0", want: true},
+		{v: "v0.7.0", current: "v0.17.0", want: false},
+		{v: "v0.7.0", current: "v0.7.0", want: false},
+	}
+	for _, tc := range testCases {
+		if got := isNewerVersion(tc.v, tc.current); got != tc.want {
+			t.Errorf("isNewerVersion(%q, %q) = %t, want %t", tc.v, tc.current, got, tc.want)
+		}
+	}
+}
@@ -38,6 +38,10 @@ const (
 	// ManifestPath specifies the path of MANIFEST.MF relative to the working directory.
 	ManifestPath = "META-INF/MANIFEST.MF"
 	mainClassKey = "Main-Class"
+	classPathKey = "Class-Path"
+	// springBootLayersIndexKey is the manifest entry of Spring Boot layered jars that holds the path
+	// of the layers index within the jar.
+	springBootLayersIndexKey = "Spring-Boot-Layers-Index"
 	// manifestRegexTemplate is a regexp template that matches lines in the manifest for a given entry.
 	manifestRegexTemplate = `(?m)^%s: \S+`
 	expiryTimestampKey    = "expiry_timestamp"
@@ -55,15 +59,25 @@ var (
 		// An empty file path searches the application root for jars.
 		[]string{},
 	}
+	// layersIndexRegexp matches the lines of a Spring Boot layers index that declare a layer.
+	layersIndexRegexp = regexp.MustCompile(`^- "([^"]+)":$`)
 )
 
 // ExecutableJar looks for the jar with a Main-Class manifest. If there is not exactly 1 of these jars, throw an error.
 func ExecutableJar(ctx *gcp.Context) (string, error) {
+	paths := jarPaths
 	var buildable = os.Getenv(env.Buildable)
 	if buildable != "" {
-		jarPaths = append([][]string{[]string{buildable, "target"}}, jarPaths...)
+		paths = append([][]string{[]string{buildable, "target"}}, paths...)
 	}
-	for i, path := range jarPaths {
+	module, err := moduleDir()
+	if err != nil {
+		return "", err
+	}
+	if module != "" {
+		paths = append([][]string{[]string{module, "target"}, []string{module, "build", "libs"}}, paths...)
+	}
+	for i, path := range paths {
 		path = append([]string{ctx.ApplicationRoot()}, path...)
 		path = append(path, "*.jar")
 		jars, err := ctx.Glob(filepath.Join(path...))
@@ -77,7 +91,7 @@ func ExecutableJar(ctx *gcp.Context) (string, error) {
 		if len(executables) == 1 {
 			return executables[0], nil
 		} else if len(executables) > 1 {
-			return "", gcp.UserErrorf("found more than one jar with a Main-Class manifest entry in %s: %v, please specify an entrypoint", jarPaths[i], executables)
+			return "", gcp.UserErrorf("found more than one jar with a Main-Class manifest entry in %s: %v, please specify an entrypoint", paths[i], executables)
 		}
 	}
 	return "", gcp.UserErrorf("did not find any jar files with a Main-Class manifest entry")
@@ -103,26 +117,84 @@ func MainManifestEntry(jar string) (string, error) {
 
 // FindManifestValueFromJar returns a manifest entry value from a JAR if found, or empty otherwise.
 func FindManifestValueFromJar(jarPath, key string) (string, error) {
+	content, err := readManifestFromJar(jarPath)
+	if err != nil || content == nil {
+		return "", err
+	}
+	return findValueFromManifest(content, key)
+}
+
+// ClassPathFromJar returns the paths of the Class-Path manifest entry of a JAR, resolved relative
+// to the directory of the JAR.
+func ClassPathFromJar(jarPath string) ([]string, error) {
+	content, err := readManifestFromJar(jarPath)
+	if err != nil || content == nil {
+		return nil, err
+	}
+	// Long values are wrapped on continuation lines that start with a single space.
+	unwrapped := strings.ReplaceAll(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n ", "")
+	var paths []string
+	for _, line := range strings.Split(unwrapped, "\n") {
+		if !strings.HasPrefix(line, classPathKey+": ") {
+			continue
+		}
+		for _, p := range strings.Fields(strings.TrimPrefix(line, classPathKey+": ")) {
+			paths = append(paths, filepath.Join(filepath.Dir(jarPath), filepath.FromSlash(p)))
+		}
+	}
+	return paths, nil
+}
+
+// SpringBootLayers returns the names of the layers of a Spring Boot layered JAR in the order in
+// which they are declared in its layers index, or nil if the JAR is not layered.
+func SpringBootLayers(jarPath string) ([]string, error) {
+	index, err := FindManifestValueFromJar(jarPath, springBootLayersIndexKey)
+	if err != nil || index == "" {
+		return nil, err
+	}
+	content, err := readFileFromJar(jarPath, index)
+	if err != nil {
+		return nil, err
+	}
+	if content == nil {
+		return nil, gcp.UserErrorf("layers index %s declared in the manifest of %s does not exist", index, jarPath)
+	}
+	// The index lists each layer followed by the paths it contains, e.g.
+	// - "dependencies":
+	//   - "BOOT-INF/lib/"
+	var layers []string
+	for _, line := range strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n") {
+		if m := layersIndexRegexp.FindStringSubmatch(line); m != nil {
+			layers = append(layers, m[1])
+		}
+	}
+	return layers, nil
+}
+
+// readManifestFromJar returns the content of the manifest of a JAR, or nil if it has none.
+func readManifestFromJar(jarPath string) ([]byte, error) {
+	return readFileFromJar(jarPath, ManifestPath)
+}
+
+// readFileFromJar returns the content of a file in a JAR, or nil if the JAR does not contain it.
+func readFileFromJar(jarPath, name string) ([]byte, error) {
 	r, err := zip.OpenReader(jarPath)
 	if err != nil {
-		return "", gcp.UserErrorf("unzipping jar %s: %v", jarPath, err)
+		return nil, gcp.UserErrorf("unzipping jar %s: %v", jarPath, err)
 	}
 	defer r.Close()
 	for _, f := range r.File {
-		if f.Name != ManifestPath {
+		if f.Name != name {
 			continue
 		}
 		rc, err := f.Open()
 		if err != nil {
-			return "", fmt.Errorf("opening file %s in jar %s: %v", f.FileInfo().Name(), jarPath, err)
-		}
-		content, err := ioutil.ReadAll(rc)
-		if err != nil {
-			return "", err
+			return nil, fmt.Errorf("opening file %s in jar %s: %v", f.FileInfo().Name(), jarPath, err)
 		}
-		return findValueFromManifest(content, key)
+		defer rc.Close()
+		return ioutil.ReadAll(rc)
 	}
-	return "", nil
+	return nil, nil
 }
 
 // MainFromManifest returns the main class specified in the manifest at the input path.
@@ -202,3 +274,13 @@ func GradleCmd(ctx *gcp.Context) (string, error) {
 	}
 	return "gradle", nil
 }
+
+// JlinkEnabled returns true if the application runs on a custom Java runtime created with jlink
+// instead of the JDK.
+func JlinkEnabled() (bool, error) {
+	enabled, err := env.IsPresentAndTrue(env.JavaJlink)
+	if err != nil {
+		return false, gcp.UserErrorf("%v", err)
+	}
+	return enabled, nil
+}
@@ -25,6 +25,7 @@ import (
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestFindManifestValueFromJar(t *testing.T) {
@@ -98,6 +99,103 @@ another: example`,
 	}
 }
 
+func TestClassPathFromJar(t *testing.T) {
+	testCases := []struct {
+		name             string
+		manifestContents string
+		want             []string
+	}{
+		{
+			name:             "single entry",
+			manifestContents: "Main-Class: example\nClass-Path: lib/a.jar\n",
+			want:             []string{"lib/a.jar"},
+		},
+		{
+			name: "multiple entries with line continuation",
+			// The manifest spec states that a continuation line must start with a single space.
+			manifestContents: "Manifest-Version: 1.0\r\nClass-Path: lib/a.jar lib/b\r\n .jar ../shared/c.jar\r\nMain-Class: example\r\n",
+			want:             []string{"lib/a.jar", "lib/b.jar", "../shared/c.jar"},
+		},
+		{
+			name:             "no class path",
+			manifestContents: "Main-Class: example\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			jarPath := setupTestJar(t, []byte(tc.manifestContents))
+			got, err := ClassPathFromJar(jarPath)
+			if err != nil {
+				t.Fatalf("ClassPathFromJar() errored: %v", err)
+			}
+
+			var want []string
+			for _, p := range tc.want {
+				want = append(want, filepath.Join(filepath.Dir(jarPath), p))
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("ClassPathFromJar() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSpringBootLayers(t *testing.T) {
+	const index = `- "dependencies":
+  - "BOOT-INF/lib/"
+- "spring-boot-loader":
+  - "org/"
+- "snapshot-dependencies":
+- "application":
+  - "BOOT-INF/classes/"
+  - "BOOT-INF/classpath.idx"
+  - "BOOT-INF/layers.idx"
+  - "META-INF/"
+`
+	testCases := []struct {
+		name    string
+		files   map[string]string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "layered jar",
+			files: map[string]string{
+				ManifestPath:          "Main-Class: org.springframework.boot.loader.JarLauncher\nSpring-Boot-Layers-Index: BOOT-INF/layers.idx\n",
+				"BOOT-INF/layers.idx": index,
+			},
+			want: []string{"dependencies", "spring-boot-loader", "snapshot-dependencies", "application"},
+		},
+		{
+			name: "not layered",
+			files: map[string]string{
+				ManifestPath: "Main-Class: org.springframework.boot.loader.JarLauncher\n",
+			},
+		},
+		{
+			name: "missing index",
+			files: map[string]string{
+				ManifestPath: "Spring-Boot-Layers-Index: BOOT-INF/layers.idx\n",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			jarPath := setupTestJarWithFiles(t, tc.files)
+			got, err := SpringBootLayers(jarPath)
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("SpringBootLayers() got error: %v, want error? %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("SpringBootLayers() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
 func TestMainFromManifest(t *testing.T) {
 	testCases := []struct {
 		name             string
@@ -305,3 +403,27 @@ func setupTestJar(t *testing.T, mfContent []byte) string {
 	}
 	return jarPath
 }
+
+func setupTestJarWithFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	var buff bytes.Buffer
+	w := zip.NewWriter(&buff)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("creating zip entry: %v", err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatalf("writing bytes: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("closing zip writer: %v", err)
+	}
+
+	jarPath := filepath.Join(t.TempDir(), "test.jar")
+	if err := ioutil.WriteFile(jarPath, buff.Bytes(), 0644); err != nil {
+		t.Fatalf("writing to file %s: %v", jarPath, err)
+	}
+	return jarPath
+}
@@ -17,6 +17,7 @@ package java
 import (
 	"encoding/xml"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 )
 
@@ -39,19 +40,28 @@ type MavenPlugin struct {
 	GroupID       string                   `xml:"groupId"`
 	ArtifactID    string                   `xml:"artifactId"`
 	Configuration MavenPluginConfiguration `xml:"configuration"`
+	Executions    []MavenPluginExecution   `xml:"executions>execution"`
+}
+
+// MavenPluginExecution describes an execution of a plugin defined in the pom.xml.
+type MavenPluginExecution struct {
+	ID            string                   `xml:"id"`
+	Configuration MavenPluginConfiguration `xml:"configuration"`
 }
 
 // MavenPluginConfiguration describes plugin settings that are parsed from the pom.xml.
 type MavenPluginConfiguration struct {
 	MainClass string `xml:"mainClass"`
 	BuildArgs string `xml:"buildArgs"`
+	// RequireJavaVersion is the version range of the maven-enforcer-plugin requireJavaVersion rule.
+	RequireJavaVersion string `xml:"rules>requireJavaVersion>version"`
 }
 
 // ParsePomFile unmarshals the provided pom.xml into a MavenProject.
 func ParsePomFile(pomFile []byte) (*MavenProject, error) {
 	var proj MavenProject
 	if err := xml.Unmarshal(pomFile, &proj); err != nil {
-		return nil, gcp.UserErrorf("parsing pom.xml: %v", err)
+		return nil, gcp.UserErrorf("parsing pom.xml: %v", err).WithCode(buildererror.CodeJavaPomParseError)
 	}
 
 	return &proj, nil
@@ -48,6 +48,7 @@ func TestParseValidPom(t *testing.T) {
 									MainClass: "com.example.Driver",
 									BuildArgs: "--no-server",
 								},
+								Executions: []MavenPluginExecution{{}},
 							},
 						},
 					},
@@ -0,0 +1,131 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// mavenSettingsLayer is the build-only layer that holds the settings.xml specified by
+	// GOOGLE_MAVEN_SETTINGS. It is not cached because the settings usually contain credentials.
+	mavenSettingsLayer = "maven_settings"
+
+	// arWagonArtifactID is the artifact id of the Maven wagon that resolves artifactregistry:// URLs
+	// with the Application Default Credentials.
+	arWagonArtifactID = "artifactregistry-maven-wagon"
+	arWagonGroupID    = "com.google.cloud.artifactregistry"
+	arWagonVersion    = "2.2.1"
+	arScheme          = "artifactregistry://"
+)
+
+// MavenSettingsArgs materializes the settings.xml specified by GOOGLE_MAVEN_SETTINGS and returns the
+// Maven arguments that use it, or nil if it is not set. If the settings reference Artifact Registry
+// repositories, the Artifact Registry wagon is added as a core extension of the project rooted at
+// projectDir, relative to the application root.
+func MavenSettingsArgs(ctx *gcp.Context, projectDir string) ([]string, error) {
+	settings, err := readMavenSettings(ctx)
+	if err != nil || settings == nil {
+		return nil, err
+	}
+	l, err := ctx.Layer(mavenSettingsLayer, gcp.BuildLayer)
+	if err != nil {
+		return nil, fmt.Errorf("creating %v layer: %w", mavenSettingsLayer, err)
+	}
+	path := filepath.Join(l.Path, "settings.xml")
+	if err := ctx.WriteFile(path, settings, 0600); err != nil {
+		return nil, err
+	}
+	if strings.Contains(string(settings), arScheme) {
+		if err := addARWagonExtension(ctx, filepath.Join(ctx.ApplicationRoot(), projectDir, ".mvn", "extensions.xml")); err != nil {
+			return nil, err
+		}
+	}
+	ctx.Logf("Using Maven settings from %s", env.MavenSettings)
+	return []string{"--settings", path}, nil
+}
+
+// readMavenSettings returns the content of the settings.xml specified by GOOGLE_MAVEN_SETTINGS,
+// which is looked up as a build-time secret first.
+func readMavenSettings(ctx *gcp.Context) ([]byte, error) {
+	v, ok := ctx.Secret(env.MavenSettings)
+	if !ok {
+		v = os.Getenv(env.MavenSettings)
+	}
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return nil, nil
+	}
+	path := v
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(ctx.ApplicationRoot(), path)
+	}
+	exists, err := ctx.FileExists(path)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return ctx.ReadFile(path)
+	}
+	settings, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(v), ""))
+	if err != nil || !strings.Contains(string(settings), "<settings") {
+		// The value is not included in the error message because it may contain credentials.
+		return nil, gcp.UserErrorf("%s must be the path of a settings.xml file or its base64-encoded content", env.MavenSettings)
+	}
+	return settings, nil
+}
+
+// addARWagonExtension declares the Artifact Registry wagon as a Maven core extension in the given
+// extensions.xml file, unless it is already declared.
+func addARWagonExtension(ctx *gcp.Context, extXML string) error {
+	extension := fmt.Sprintf(`  <extension>
+    <groupId>%s</groupId>
+    <artifactId>%s</artifactId>
+    <version>%s</version>
+  </extension>
+`, arWagonGroupID, arWagonArtifactID, arWagonVersion)
+	content := "<extensions>\n" + extension + "</extensions>\n"
+
+	exists, err := ctx.FileExists(extXML)
+	if err != nil {
+		return err
+	}
+	if exists {
+		b, err := ctx.ReadFile(extXML)
+		if err != nil {
+			return err
+		}
+		existing := string(b)
+		if strings.Contains(existing, arWagonArtifactID) {
+			return nil
+		}
+		i := strings.LastIndex(existing, "</extensions>")
+		if i < 0 {
+			return gcp.UserErrorf("adding the Artifact Registry wagon to %s: missing </extensions>", extXML)
+		}
+		content = existing[:i] + extension + existing[i:]
+	} else if err := ctx.MkdirAll(filepath.Dir(extXML), 0755); err != nil {
+		return err
+	}
+	ctx.Logf("Adding %s:%s:%s to %s", arWagonGroupID, arWagonArtifactID, arWagonVersion, extXML)
+	return ctx.WriteFile(extXML, []byte(content), 0644)
+}
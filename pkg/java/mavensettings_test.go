@@ -0,0 +1,126 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const testSettings = `<settings><servers><server><id>private</id></server></servers></settings>`
+
+func TestMavenSettingsArgs(t *testing.T) {
+	testCases := []struct {
+		name         string
+		settings     string
+		files        map[string]string
+		wantSettings string
+		wantExt      string
+		wantErr      bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:         "path",
+			settings:     "config/settings.xml",
+			files:        map[string]string{"config/settings.xml": testSettings},
+			wantSettings: testSettings,
+		},
+		{
+			name:         "base64",
+			settings:     base64.StdEncoding.EncodeToString([]byte(testSettings)),
+			wantSettings: testSettings,
+		},
+		{
+			name:     "neither path nor base64",
+			settings: "missing.xml",
+			wantErr:  true,
+		},
+		{
+			name:         "artifact registry adds wagon",
+			settings:     "settings.xml",
+			files:        map[string]string{"settings.xml": `<settings><url>artifactregistry://us-maven.pkg.dev/p/r</url></settings>`},
+			wantSettings: `<settings><url>artifactregistry://us-maven.pkg.dev/p/r</url></settings>`,
+			wantExt:      "<extensions>\n  <extension>\n    <groupId>com.google.cloud.artifactregistry</groupId>\n    <artifactId>artifactregistry-maven-wagon</artifactId>\n    <version>2.2.1</version>\n  </extension>\n</extensions>\n",
+		},
+		{
+			name:     "artifact registry merges existing extensions",
+			settings: "settings.xml",
+			files: map[string]string{
+				"settings.xml":        `<settings><url>artifactregistry://us-maven.pkg.dev/p/r</url></settings>`,
+				".mvn/extensions.xml": "<extensions>\n  <extension><artifactId>other</artifactId></extension>\n</extensions>\n",
+			},
+			wantSettings: `<settings><url>artifactregistry://us-maven.pkg.dev/p/r</url></settings>`,
+			wantExt:      "<extensions>\n  <extension><artifactId>other</artifactId></extension>\n  <extension>\n    <groupId>com.google.cloud.artifactregistry</groupId>\n    <artifactId>artifactregistry-maven-wagon</artifactId>\n    <version>2.2.1</version>\n  </extension>\n</extensions>\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(env.MavenSettings, tc.settings)
+			app := t.TempDir()
+			for name, content := range tc.files {
+				path := filepath.Join(app, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			layers := t.TempDir()
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(app), gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layers}}))
+
+			args, err := MavenSettingsArgs(ctx, ".")
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("MavenSettingsArgs() got error: %v, want error? %v", err, tc.wantErr)
+			}
+			if tc.wantSettings == "" {
+				if len(args) != 0 {
+					t.Errorf("MavenSettingsArgs() = %v, want no args", args)
+				}
+				return
+			}
+			settingsPath := filepath.Join(layers, mavenSettingsLayer, "settings.xml")
+			if got, want := strings.Join(args, " "), "--settings "+settingsPath; got != want {
+				t.Errorf("MavenSettingsArgs() = %q, want %q", got, want)
+			}
+			if got := readFile(t, settingsPath); got != tc.wantSettings {
+				t.Errorf("settings.xml = %q, want %q", got, tc.wantSettings)
+			}
+			if got := readFile(t, filepath.Join(app, ".mvn", "extensions.xml")); got != tc.wantExt {
+				t.Errorf("extensions.xml = %q, want %q", got, tc.wantExt)
+			}
+		})
+	}
+}
+
+// readFile returns the content of the file, or "" if it does not exist.
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
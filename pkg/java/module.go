@@ -0,0 +1,100 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// Module is a module of a multi-module project selected with GOOGLE_JAVA_MODULE.
+type Module struct {
+	// Dir is the module directory, relative to the application root.
+	Dir string
+	// ProjectDir is the directory of the project that contains the module, relative to the
+	// application root. It is the nearest directory enclosing Dir that contains the build wrapper, or
+	// the application root if there is none.
+	ProjectDir string
+}
+
+// SelectedModule returns the module selected with GOOGLE_JAVA_MODULE, or nil if it is not set.
+// wrapper is the name of the build tool wrapper script, e.g. "mvnw", that marks the project
+// directory.
+func SelectedModule(ctx *gcp.Context, wrapper string) (*Module, error) {
+	dir, err := moduleDir()
+	if err != nil || dir == "" {
+		return nil, err
+	}
+	exists, err := ctx.FileExists(ctx.ApplicationRoot(), dir)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, gcp.UserErrorf("%s directory %q does not exist", env.JavaModule, dir)
+	}
+	m := &Module{Dir: dir, ProjectDir: "."}
+	for d := dir; d != "."; d = filepath.Dir(d) {
+		found, err := ctx.FileExists(ctx.ApplicationRoot(), d, wrapper)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			m.ProjectDir = d
+			break
+		}
+	}
+	return m, nil
+}
+
+// moduleDir returns the cleaned value of GOOGLE_JAVA_MODULE.
+func moduleDir() (string, error) {
+	v := strings.TrimSpace(os.Getenv(env.JavaModule))
+	if v == "" {
+		return "", nil
+	}
+	dir := filepath.Clean(v)
+	if filepath.IsAbs(dir) || dir == ".." || strings.HasPrefix(dir, "../") {
+		return "", gcp.UserErrorf("%s %q must be a directory within the application", env.JavaModule, v)
+	}
+	if dir == "." {
+		return "", nil
+	}
+	return dir, nil
+}
+
+// Path returns the path of the module relative to its project directory, e.g. "services/api".
+func (m *Module) Path() string {
+	rel, err := filepath.Rel(m.ProjectDir, m.Dir)
+	if err != nil {
+		// Dir is always within ProjectDir.
+		return m.Dir
+	}
+	return rel
+}
+
+// GradlePath returns the Gradle project path of the module, e.g. ":services:api", assuming the
+// project name matches its directory as is the default in settings.gradle. It returns an empty
+// string if the module is the root project.
+func (m *Module) GradlePath() string {
+	p := m.Path()
+	if p == "." {
+		return ""
+	}
+	return ":" + strings.ReplaceAll(filepath.ToSlash(p), "/", ":")
+}
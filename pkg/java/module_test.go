@@ -0,0 +1,146 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestSelectedModule(t *testing.T) {
+	testCases := []struct {
+		name      string
+		module    string
+		files     []string
+		want      *Module
+		wantError bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:   "application root",
+			module: "./",
+		},
+		{
+			name:   "wrapper in application root",
+			module: "services/api/",
+			files:  []string{"mvnw", "services/api/pom.xml"},
+			want:   &Module{Dir: "services/api", ProjectDir: "."},
+		},
+		{
+			name:   "wrapper in enclosing directory",
+			module: "backend/services/api",
+			files:  []string{"backend/mvnw", "backend/services/api/pom.xml"},
+			want:   &Module{Dir: "backend/services/api", ProjectDir: "backend"},
+		},
+		{
+			name:   "wrapper in module",
+			module: "api",
+			files:  []string{"mvnw", "api/mvnw", "api/pom.xml"},
+			want:   &Module{Dir: "api", ProjectDir: "api"},
+		},
+		{
+			name:   "no wrapper",
+			module: "api",
+			files:  []string{"api/pom.xml"},
+			want:   &Module{Dir: "api", ProjectDir: "."},
+		},
+		{
+			name:      "missing directory",
+			module:    "api",
+			wantError: true,
+		},
+		{
+			name:      "outside the application",
+			module:    "../api",
+			wantError: true,
+		},
+		{
+			name:      "absolute path",
+			module:    "/api",
+			wantError: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			for _, f := range tc.files {
+				path := filepath.Join(root, f)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("creating %s: %v", filepath.Dir(path), err)
+				}
+				if err := os.WriteFile(path, nil, 0644); err != nil {
+					t.Fatalf("writing %s: %v", path, err)
+				}
+			}
+			t.Setenv(env.JavaModule, tc.module)
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(root))
+
+			got, err := SelectedModule(ctx, "mvnw")
+			if tc.wantError {
+				if err == nil {
+					t.Fatalf("SelectedModule() got no error, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SelectedModule() got error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("SelectedModule() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestModulePaths(t *testing.T) {
+	testCases := []struct {
+		module         Module
+		wantPath       string
+		wantGradlePath string
+	}{
+		{
+			module:         Module{Dir: "services/api", ProjectDir: "."},
+			wantPath:       "services/api",
+			wantGradlePath: ":services:api",
+		},
+		{
+			module:         Module{Dir: "backend/services/api", ProjectDir: "backend"},
+			wantPath:       "services/api",
+			wantGradlePath: ":services:api",
+		},
+		{
+			module:         Module{Dir: "api", ProjectDir: "api"},
+			wantPath:       ".",
+			wantGradlePath: "",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.module.Dir, func(t *testing.T) {
+			if got := tc.module.Path(); got != tc.wantPath {
+				t.Errorf("Path() = %q, want %q", got, tc.wantPath)
+			}
+			if got := tc.module.GradlePath(); got != tc.wantGradlePath {
+				t.Errorf("GradlePath() = %q, want %q", got, tc.wantGradlePath)
+			}
+		})
+	}
+}
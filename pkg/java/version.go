@@ -0,0 +1,174 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/version"
+)
+
+const (
+	sdkmanrcFile     = ".sdkmanrc"
+	toolVersionsFile = ".tool-versions"
+	pomFile          = "pom.xml"
+	enforcerPlugin   = "maven-enforcer-plugin"
+)
+
+// featureVersionRegexp matches the leading feature version of a Java version, e.g. "17" in
+// "17.0.9-tem" or "temurin-17.0.9+9", and "8" in the legacy "1.8.0_392" scheme.
+var featureVersionRegexp = regexp.MustCompile(`(?:^|[^\d.])(?:1\.)?(\d+)`)
+
+// legacyVersionRegexp matches the "1." prefix of legacy Java versions in a Maven version range,
+// e.g. in "[1.8,11)", which is dropped to match the versioning scheme of the runtime releases.
+var legacyVersionRegexp = regexp.MustCompile(`(^|[\[(,])1\.(\d)`)
+
+// RuntimeVersion returns the Java version constraint requested by the application's version files,
+// in order of precedence: .sdkmanrc, asdf .tool-versions and the requireJavaVersion rule of the
+// maven-enforcer-plugin in pom.xml. It returns an empty string if none of them specifies a version.
+func RuntimeVersion(ctx *gcp.Context) (string, error) {
+	for _, f := range []struct {
+		name  string
+		parse func(string) string
+	}{
+		{sdkmanrcFile, parseSdkmanrc},
+		{toolVersionsFile, parseToolVersions},
+	} {
+		content, err := readAppFile(ctx, f.name)
+		if err != nil {
+			return "", err
+		}
+		if v := f.parse(content); v != "" {
+			ctx.Logf("Using Java feature version from %s: %s", f.name, v)
+			return v, nil
+		}
+	}
+	return versionFromEnforcer(ctx)
+}
+
+// readAppFile returns the content of a file in the application root, or an empty string if it
+// does not exist.
+func readAppFile(ctx *gcp.Context, name string) (string, error) {
+	path := filepath.Join(ctx.ApplicationRoot(), name)
+	exists, err := ctx.FileExists(path)
+	if err != nil || !exists {
+		return "", err
+	}
+	raw, err := ctx.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
+
+// parseSdkmanrc returns the Java feature version of the java entry in an SDKMAN! .sdkmanrc file,
+// e.g. "17" for "java=17.0.9-tem". Only the feature version is used because the distribution and
+// patch release pinned for SDKMAN! are not necessarily available as a runtime.
+func parseSdkmanrc(content string) string {
+	for _, line := range strings.Split(content, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) == 2 && strings.TrimSpace(parts[0]) == "java" {
+			return featureVersion(strings.TrimSpace(parts[1]))
+		}
+	}
+	return ""
+}
+
+// parseToolVersions returns the Java feature version of the java entry in an asdf .tool-versions
+// file, e.g. "17" for "java temurin-17.0.9+9". The first of several listed versions is used.
+func parseToolVersions(content string) string {
+	for _, line := range strings.Split(content, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "java" {
+			return featureVersion(fields[1])
+		}
+	}
+	return ""
+}
+
+// featureVersion returns the feature version of a Java version optionally prefixed by a
+// distribution name, e.g. "17" for "temurin-17.0.9+9" and "8" for "1.8.0_392".
+func featureVersion(v string) string {
+	if m := featureVersionRegexp.FindStringSubmatch(v); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// versionFromEnforcer returns the Java version constraint of the requireJavaVersion rule of the
+// maven-enforcer-plugin in pom.xml, from either the plugin or one of its executions.
+func versionFromEnforcer(ctx *gcp.Context) (string, error) {
+	content, err := readAppFile(ctx, pomFile)
+	if err != nil || content == "" {
+		return "", err
+	}
+	proj, err := ParsePomFile([]byte(content))
+	if err != nil {
+		return "", err
+	}
+	for _, p := range proj.Plugins {
+		if p.ArtifactID != enforcerPlugin {
+			continue
+		}
+		configs := []MavenPluginConfiguration{p.Configuration}
+		for _, e := range p.Executions {
+			configs = append(configs, e.Configuration)
+		}
+		for _, c := range configs {
+			spec := strings.TrimSpace(c.RequireJavaVersion)
+			if spec == "" {
+				continue
+			}
+			if strings.Contains(spec, "${") {
+				ctx.Warnf("Ignoring requireJavaVersion %q of %s, properties are not supported.", spec, enforcerPlugin)
+				continue
+			}
+			constraint, err := mavenRangeConstraint(spec)
+			if err != nil {
+				return "", gcp.UserErrorf("parsing requireJavaVersion %q of %s in %s: %v", spec, enforcerPlugin, pomFile, err).WithCode(buildererror.CodeInvalidRuntimeVersion)
+			}
+			ctx.Logf("Using Java version from the requireJavaVersion rule of %s: %s", enforcerPlugin, spec)
+			return constraint, nil
+		}
+	}
+	return "", nil
+}
+
+// mavenRangeConstraint converts a Maven version range, e.g. "[11,18)" or "(,1.8],[11,)", to a
+// semver constraint that can be resolved against the available runtime releases. A plain version,
+// which the enforcer treats as a minimum, selects that feature version since it is the one the
+// project is known to build with, e.g. "1.8" returns "8".
+func mavenRangeConstraint(spec string) (string, error) {
+	spec = strings.Join(strings.Fields(spec), "")
+	if !strings.ContainsAny(spec, "[]()") {
+		v := featureVersion(spec)
+		if v == "" {
+			return "", fmt.Errorf("invalid version")
+		}
+		return v, nil
+	}
+	return version.Constraint(version.Maven, legacyVersionRegexp.ReplaceAllString(spec, "${1}${2}"))
+}
@@ -0,0 +1,204 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const enforcerPom = `<project>
+  <build>
+    <plugins>
+      <plugin>
+        <groupId>org.apache.maven.plugins</groupId>
+        <artifactId>maven-enforcer-plugin</artifactId>
+        <executions>
+          <execution>
+            <id>enforce-java</id>
+            <goals><goal>enforce</goal></goals>
+            <configuration>
+              <rules>
+                <requireJavaVersion>
+                  <version>%s</version>
+                </requireJavaVersion>
+              </rules>
+            </configuration>
+          </execution>
+        </executions>
+      </plugin>
+    </plugins>
+  </build>
+</project>`
+
+func TestRuntimeVersion(t *testing.T) {
+	testCases := []struct {
+		name    string
+		files   map[string]string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "no version files",
+			want: "",
+		},
+		{
+			name:  "sdkmanrc",
+			files: map[string]string{".sdkmanrc": "# Enable auto-env through the sdkman_auto_env config\njava=17.0.9-tem\nmaven=3.9.5\n"},
+			want:  "17",
+		},
+		{
+			name:  "tool-versions",
+			files: map[string]string{".tool-versions": "nodejs 20.9.0\njava temurin-21.0.1+12.0.LTS\n"},
+			want:  "21",
+		},
+		{
+			name: "enforcer range",
+			files: map[string]string{
+				"pom.xml": sprintfPom("[11,18)"),
+			},
+			want: ">=11, <18.0.0",
+		},
+		{
+			name: "enforcer plugin configuration",
+			files: map[string]string{
+				"pom.xml": `<project><build><plugins><plugin>
+  <artifactId>maven-enforcer-plugin</artifactId>
+  <configuration><rules><requireJavaVersion><version>1.8</version></requireJavaVersion></rules></configuration>
+</plugin></plugins></build></project>`,
+			},
+			want: "8",
+		},
+		{
+			name: "enforcer property is ignored",
+			files: map[string]string{
+				"pom.xml": sprintfPom("${java.version}"),
+			},
+			want: "",
+		},
+		{
+			name: "sdkmanrc takes precedence",
+			files: map[string]string{
+				".sdkmanrc":      "java=17.0.9-tem",
+				".tool-versions": "java temurin-21.0.1+12.0.LTS",
+				"pom.xml":        sprintfPom("[11,)"),
+			},
+			want: "17",
+		},
+		{
+			name: "tool-versions takes precedence over enforcer",
+			files: map[string]string{
+				".tool-versions": "java openjdk-11.0.2",
+				"pom.xml":        sprintfPom("[17,)"),
+			},
+			want: "11",
+		},
+		{
+			name: "no java entry",
+			files: map[string]string{
+				".tool-versions": "nodejs 20.9.0",
+				"pom.xml":        "<project></project>",
+			},
+			want: "",
+		},
+		{
+			name: "invalid enforcer range",
+			files: map[string]string{
+				"pom.xml": sprintfPom("[11,17"),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tc.files {
+				if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatalf("writing %s: %v", name, err)
+				}
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+
+			got, err := RuntimeVersion(ctx)
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("RuntimeVersion() got error: %v, want error? %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("RuntimeVersion() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFeatureVersion(t *testing.T) {
+	testCases := []struct {
+		version string
+		want    string
+	}{
+		{version: "17.0.9-tem", want: "17"},
+		{version: "temurin-17.0.9+9", want: "17"},
+		{version: "1.8.0_392", want: "8"},
+		{version: "adoptopenjdk-8.0.392+8", want: "8"},
+		{version: "openjdk-1.8", want: "8"},
+		{version: "11", want: "11"},
+		{version: "system", want: ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.version, func(t *testing.T) {
+			if got := featureVersion(tc.version); got != tc.want {
+				t.Errorf("featureVersion(%q) = %q, want %q", tc.version, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMavenRangeConstraint(t *testing.T) {
+	testCases := []struct {
+		spec    string
+		want    string
+		wantErr bool
+	}{
+		{spec: "17", want: "17"},
+		{spec: "1.8", want: "8"},
+		{spec: "[17]", want: "17"},
+		{spec: "[11,)", want: ">=11"},
+		{spec: "[1.8,11]", want: ">=8, <=11"},
+		{spec: "(11, 17)", want: ">11, <17.0.0"},
+		{spec: "(,1.8],[11,)", want: "<=8 || >=11"},
+		{spec: "[11,17", wantErr: true},
+		{spec: "(,)", wantErr: true},
+		{spec: "(17)", wantErr: true},
+		{spec: "latest", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.spec, func(t *testing.T) {
+			got, err := mavenRangeConstraint(tc.spec)
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("mavenRangeConstraint(%q) got error: %v, want error? %v", tc.spec, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("mavenRangeConstraint(%q) = %q, want %q", tc.spec, got, tc.want)
+			}
+		})
+	}
+}
+
+func sprintfPom(version string) string {
+	return fmt.Sprintf(enforcerPom, version)
+}
@@ -19,6 +19,10 @@ import (
 	"text/template"
 )
 
+// MimeTypesPath is the path of the nginx MIME types configuration in the image built with the
+// google.utils.nginx buildpack.
+const MimeTypesPath = "/layers/google.utils.nginx/nginx/conf/mime.types"
+
 // PHPFpmTemplate is a template that produces a snippet of php-fpm config that sets up the PHP with Nginx.
 var PHPFpmTemplate = template.Must(template.New("phpfpm").Parse(`
 ; Send errors to stderr.
@@ -133,6 +137,64 @@ server {
 }
 `))
 
+// StaticTemplate is a template that produces a complete nginx config that serves the files of a
+// static site. The config only writes to /tmp because the layers are read-only at runtime.
+var StaticTemplate = template.Must(template.New("static").Parse(`
+daemon off;
+worker_processes auto;
+pid /tmp/nginx.pid;
+error_log stderr warn;
+
+events {
+	worker_connections 1024;
+}
+
+http {
+	include	{{.MimeTypesPath}};
+	default_type	application/octet-stream;
+
+	access_log	/dev/stdout;
+	client_body_temp_path	/tmp/client_body;
+	proxy_temp_path	/tmp/proxy;
+	fastcgi_temp_path	/tmp/fastcgi;
+	uwsgi_temp_path	/tmp/uwsgi;
+	scgi_temp_path	/tmp/scgi;
+
+	sendfile	on;
+	server_tokens	off;
+	gzip	on;
+	gzip_types	text/plain text/css text/xml application/javascript application/json application/xml image/svg+xml;
+
+	server {
+		listen	{{.Port}} default_server;
+		listen	[::]:{{.Port}} default_server;
+		server_name	"";
+		root	{{.Root}};
+		index	index.html;
+
+		# Redirects must be relative because TLS is terminated in front of the container.
+		absolute_redirect	off;
+
+		location	/	{
+{{- if .SPA}}
+			try_files	$uri $uri/ /index.html;
+{{- else}}
+			try_files	$uri $uri/ $uri.html =404;
+{{- end}}
+		}
+	}
+}
+`))
+
+// StaticConfig represents the content values of a static site nginx config file.
+type StaticConfig struct {
+	// Port is the port to listen on, it may be a placeholder that is replaced at startup.
+	Port          string
+	Root          string
+	MimeTypesPath string
+	SPA           bool
+}
+
 // FPMConfig represents the content values of a php-fpm config file.
 type FPMConfig struct {
 	PidPath        string
@@ -0,0 +1,273 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/audit"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/Masterminds/semver"
+)
+
+// AuditEnv is the env var that enables the vulnerability audit of the production dependencies
+// after they are installed, see audit.ParseConfig for its values.
+// Example: `high` fails the build on high and critical vulnerabilities.
+const AuditEnv = "GOOGLE_NODEJS_AUDIT"
+
+// minNPMOmitVersion is the first npm version that supports the --omit flag.
+var minNPMOmitVersion = semver.MustParse("7.0.0")
+
+// NPMAudit runs `npm audit` on the production dependencies if GOOGLE_NODEJS_AUDIT enables it.
+func NPMAudit(ctx *gcp.Context) error {
+	cfg, err := audit.ParseConfig(AuditEnv)
+	if err != nil || !cfg.Enabled {
+		return err
+	}
+	omitDev := "--omit=dev"
+	if v, err := npmVersion(ctx); err == nil {
+		if sv, err := semver.NewVersion(v); err == nil && sv.LessThan(minNPMOmitVersion) {
+			omitDev = "--production"
+		}
+	}
+	out, err := auditOutput(ctx, []string{"npm", "audit", "--json", omitDev})
+	if err != nil {
+		return err
+	}
+	findings, err := parseNPMAudit(out)
+	if err != nil {
+		return gcp.InternalErrorf("parsing npm audit output: %v", err)
+	}
+	return cfg.Check(ctx, audit.Report{Scanner: "npm audit", FixCommand: "npm audit fix", StrictCategory: gcp.WarningNPMAuditCritical, Findings: findings})
+}
+
+// YarnAudit runs `yarn npm audit` for Yarn 2+, or `yarn audit` for Yarn 1, on the production
+// dependencies if GOOGLE_NODEJS_AUDIT enables it.
+func YarnAudit(ctx *gcp.Context, yarn2 bool) error {
+	cfg, err := audit.ParseConfig(AuditEnv)
+	if err != nil || !cfg.Enabled {
+		return err
+	}
+	cmd := []string{"yarn", "audit", "--json", "--groups", "dependencies"}
+	if yarn2 {
+		cmd = []string{"yarn", "npm", "audit", "--json", "--all", "--recursive", "--environment", "production"}
+	}
+	out, err := auditOutput(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	parse := parseYarnAudit
+	if yarn2 {
+		parse = parseYarnNPMAudit
+	}
+	findings, err := parse(out)
+	if err != nil {
+		return gcp.InternalErrorf("parsing %s output: %v", strings.Join(cmd[:len(cmd)-1], " "), err)
+	}
+	return cfg.Check(ctx, audit.Report{Scanner: "yarn audit", StrictCategory: gcp.WarningNPMAuditCritical, Findings: findings})
+}
+
+// auditOutput returns the stdout of the audit command. The audit commands exit with a non-zero
+// status when they find vulnerabilities, which is only an error if there is no report.
+func auditOutput(ctx *gcp.Context, cmd []string) (string, error) {
+	result, err := ctx.Exec(cmd, gcp.WithUserAttribution)
+	if err != nil && (result == nil || strings.TrimSpace(result.Stdout) == "") {
+		return "", err
+	}
+	return result.Stdout, nil
+}
+
+// npmAdvisory is an advisory of the npm audit report version 1, also used by Yarn 1.
+type npmAdvisory struct {
+	ID         int    `json:"id"`
+	GitHubID   string `json:"github_advisory_id"`
+	ModuleName string `json:"module_name"`
+	Severity   string `json:"severity"`
+	Title      string `json:"title"`
+	URL        string `json:"url"`
+	Findings   []struct {
+		Version string `json:"version"`
+	} `json:"findings"`
+}
+
+func (a npmAdvisory) findings() []audit.Finding {
+	id := a.GitHubID
+	if id == "" {
+		id = advisoryID(a.URL, fmt.Sprint(a.ID))
+	}
+	f := audit.Finding{ID: id, Package: a.ModuleName, Severity: audit.ParseSeverity(a.Severity), Title: a.Title, URL: a.URL}
+	if len(a.Findings) == 0 {
+		return []audit.Finding{f}
+	}
+	var result []audit.Finding
+	for _, af := range a.Findings {
+		f.Version = af.Version
+		result = append(result, f)
+	}
+	return result
+}
+
+// npmAuditVia is an advisory that affects a package in the npm audit report version 2. Packages
+// that are only vulnerable through their dependencies list the names of the dependencies instead.
+type npmAuditVia struct {
+	Source   int    `json:"source"`
+	Name     string `json:"name"`
+	Title    string `json:"title"`
+	URL      string `json:"url"`
+	Severity string `json:"severity"`
+}
+
+// parseNPMAudit returns the findings of an npm audit JSON report. Both the version 2 report of
+// npm 7+ and the version 1 report of npm 6 are supported.
+func parseNPMAudit(out string) ([]audit.Finding, error) {
+	var report struct {
+		Vulnerabilities map[string]struct {
+			Via []json.RawMessage `json:"via"`
+		} `json:"vulnerabilities"`
+		Advisories map[string]npmAdvisory `json:"advisories"`
+		Error      *struct {
+			Summary string `json:"summary"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		return nil, err
+	}
+	if report.Error != nil {
+		return nil, fmt.Errorf("%s", report.Error.Summary)
+	}
+	var findings []audit.Finding
+	for _, a := range report.Advisories {
+		findings = append(findings, a.findings()...)
+	}
+	for _, v := range report.Vulnerabilities {
+		for _, raw := range v.Via {
+			var via npmAuditVia
+			// Entries that are not objects name vulnerable dependencies, which are reported separately.
+			if err := json.Unmarshal(raw, &via); err != nil {
+				continue
+			}
+			findings = append(findings, audit.Finding{
+				ID:       advisoryID(via.URL, fmt.Sprint(via.Source)),
+				Package:  via.Name,
+				Severity: audit.ParseSeverity(via.Severity),
+				Title:    via.Title,
+				URL:      via.URL,
+			})
+		}
+	}
+	return dedupeFindings(findings), nil
+}
+
+// parseYarnAudit returns the findings of the newline-delimited JSON output of `yarn audit --json`.
+func parseYarnAudit(out string) ([]audit.Finding, error) {
+	var findings []audit.Finding
+	s := bufio.NewScanner(strings.NewReader(out))
+	s.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for s.Scan() {
+		var line struct {
+			Type string `json:"type"`
+			Data struct {
+				Advisory npmAdvisory `json:"advisory"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(s.Bytes(), &line); err != nil {
+			return nil, err
+		}
+		if line.Type == "auditAdvisory" {
+			findings = append(findings, line.Data.Advisory.findings()...)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return dedupeFindings(findings), nil
+}
+
+// parseYarnNPMAudit returns the findings of the newline-delimited JSON output of
+// `yarn npm audit --json`.
+func parseYarnNPMAudit(out string) ([]audit.Finding, error) {
+	var findings []audit.Finding
+	s := bufio.NewScanner(strings.NewReader(out))
+	s.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for s.Scan() {
+		if strings.TrimSpace(s.Text()) == "" {
+			continue
+		}
+		var line struct {
+			Value    string `json:"value"`
+			Children struct {
+				ID           json.RawMessage `json:"ID"`
+				Issue        string          `json:"Issue"`
+				URL          string          `json:"URL"`
+				Severity     string          `json:"Severity"`
+				TreeVersions []string        `json:"Tree Versions"`
+			} `json:"children"`
+		}
+		if err := json.Unmarshal(s.Bytes(), &line); err != nil {
+			return nil, err
+		}
+		c := line.Children
+		f := audit.Finding{
+			ID:       advisoryID(c.URL, strings.Trim(string(c.ID), `"`)),
+			Package:  line.Value,
+			Severity: audit.ParseSeverity(c.Severity),
+			Title:    c.Issue,
+			URL:      c.URL,
+		}
+		if len(c.TreeVersions) == 0 {
+			findings = append(findings, f)
+		}
+		for _, v := range c.TreeVersions {
+			f.Version = v
+			findings = append(findings, f)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return dedupeFindings(findings), nil
+}
+
+// advisoryID returns the GitHub advisory ID at the end of the advisory URL, e.g.
+// https://github.com/advisories/GHSA-xvch-5gv4-984h, or the fallback ID.
+func advisoryID(url, fallback string) string {
+	if id := path.Base(url); strings.HasPrefix(id, "GHSA-") {
+		return id
+	}
+	return fallback
+}
+
+// dedupeFindings removes duplicate findings, which are reported once for each path to the package.
+func dedupeFindings(findings []audit.Finding) []audit.Finding {
+	seen := map[string]bool{}
+	var result []audit.Finding
+	for _, f := range findings {
+		key := f.ID + " " + f.Package + "@" + f.Version
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, f)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Package+result[i].ID < result[j].Package+result[j].ID
+	})
+	return result
+}
@@ -0,0 +1,164 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/audit"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseNPMAudit(t *testing.T) {
+	testCases := []struct {
+		name    string
+		out     string
+		want    []audit.Finding
+		wantErr bool
+	}{
+		{
+			name: "report version 2",
+			out: `{
+  "auditReportVersion": 2,
+  "vulnerabilities": {
+    "express": {
+      "name": "express",
+      "severity": "high",
+      "via": ["qs"]
+    },
+    "qs": {
+      "name": "qs",
+      "severity": "high",
+      "via": [
+        {
+          "source": 1088280,
+          "name": "qs",
+          "dependency": "qs",
+          "title": "qs vulnerable to Prototype Pollution",
+          "url": "https://github.com/advisories/GHSA-hrpp-h998-j3pp",
+          "severity": "high",
+          "range": ">=6.7.0 <6.7.3"
+        }
+      ]
+    },
+    "minimist": {
+      "name": "minimist",
+      "severity": "critical",
+      "via": [
+        {
+          "source": 1097677,
+          "name": "minimist",
+          "title": "Prototype Pollution in minimist",
+          "url": "https://github.com/advisories/GHSA-xvch-5gv4-984h",
+          "severity": "critical"
+        },
+        {
+          "source": 1097677,
+          "name": "minimist",
+          "title": "Prototype Pollution in minimist",
+          "url": "https://github.com/advisories/GHSA-xvch-5gv4-984h",
+          "severity": "critical"
+        }
+      ]
+    }
+  }
+}`,
+			want: []audit.Finding{
+				{ID: "GHSA-xvch-5gv4-984h", Package: "minimist", Severity: audit.SeverityCritical, Title: "Prototype Pollution in minimist", URL: "https://github.com/advisories/GHSA-xvch-5gv4-984h"},
+				{ID: "GHSA-hrpp-h998-j3pp", Package: "qs", Severity: audit.SeverityHigh, Title: "qs vulnerable to Prototype Pollution", URL: "https://github.com/advisories/GHSA-hrpp-h998-j3pp"},
+			},
+		},
+		{
+			name: "report version 1",
+			out: `{
+  "advisories": {
+    "1179": {
+      "id": 1179,
+      "module_name": "minimist",
+      "severity": "low",
+      "title": "Prototype Pollution",
+      "url": "https://npmjs.com/advisories/1179",
+      "findings": [{"version": "0.0.8"}, {"version": "1.2.0"}]
+    }
+  }
+}`,
+			want: []audit.Finding{
+				{ID: "1179", Package: "minimist", Version: "0.0.8", Severity: audit.SeverityLow, Title: "Prototype Pollution", URL: "https://npmjs.com/advisories/1179"},
+				{ID: "1179", Package: "minimist", Version: "1.2.0", Severity: audit.SeverityLow, Title: "Prototype Pollution", URL: "https://npmjs.com/advisories/1179"},
+			},
+		},
+		{
+			name: "no vulnerabilities",
+			out:  `{"auditReportVersion": 2, "vulnerabilities": {}}`,
+		},
+		{
+			name:    "audit error",
+			out:     `{"error": {"code": "ENOLOCK", "summary": "This command requires an existing lockfile."}}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid json",
+			out:     `npm ERR! audit endpoint returned an error`,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseNPMAudit(tc.out)
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("parseNPMAudit() got error: %v, want error? %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("parseNPMAudit() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseYarnAudit(t *testing.T) {
+	out := `{"type":"auditAdvisory","data":{"resolution":{"id":1097677,"path":"mkdirp>minimist"},"advisory":{"id":1097677,"github_advisory_id":"GHSA-xvch-5gv4-984h","module_name":"minimist","severity":"critical","title":"Prototype Pollution in minimist","url":"https://github.com/advisories/GHSA-xvch-5gv4-984h","findings":[{"version":"1.2.5","paths":["mkdirp>minimist"]}]}}}
+{"type":"auditAdvisory","data":{"resolution":{"id":1097677,"path":"optimist>minimist"},"advisory":{"id":1097677,"github_advisory_id":"GHSA-xvch-5gv4-984h","module_name":"minimist","severity":"critical","title":"Prototype Pollution in minimist","url":"https://github.com/advisories/GHSA-xvch-5gv4-984h","findings":[{"version":"1.2.5","paths":["optimist>minimist"]}]}}}
+{"type":"auditSummary","data":{"vulnerabilities":{"info":0,"low":0,"moderate":0,"high":0,"critical":2},"dependencies":12}}
+`
+	want := []audit.Finding{
+		{ID: "GHSA-xvch-5gv4-984h", Package: "minimist", Version: "1.2.5", Severity: audit.SeverityCritical, Title: "Prototype Pollution in minimist", URL: "https://github.com/advisories/GHSA-xvch-5gv4-984h"},
+	}
+
+	got, err := parseYarnAudit(out)
+	if err != nil {
+		t.Fatalf("parseYarnAudit() got error: %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("parseYarnAudit() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseYarnNPMAudit(t *testing.T) {
+	out := `{"value":"minimist","children":{"ID":1097677,"Issue":"Prototype Pollution in minimist","URL":"https://github.com/advisories/GHSA-xvch-5gv4-984h","Severity":"critical","Vulnerable Versions":"<1.2.6","Tree Versions":["1.2.5"],"Dependents":["mkdirp@npm:0.5.5"]}}
+{"value":"qs","children":{"ID":"GHSA-hrpp-h998-j3pp","Issue":"qs vulnerable to Prototype Pollution","URL":"https://example.com/advisory","Severity":"high","Vulnerable Versions":">=6.7.0 <6.7.3","Tree Versions":["6.7.0"],"Dependents":["express@npm:4.17.1"]}}
+`
+	want := []audit.Finding{
+		{ID: "GHSA-xvch-5gv4-984h", Package: "minimist", Version: "1.2.5", Severity: audit.SeverityCritical, Title: "Prototype Pollution in minimist", URL: "https://github.com/advisories/GHSA-xvch-5gv4-984h"},
+		{ID: "GHSA-hrpp-h998-j3pp", Package: "qs", Version: "6.7.0", Severity: audit.SeverityHigh, Title: "qs vulnerable to Prototype Pollution", URL: "https://example.com/advisory"},
+	}
+
+	got, err := parseYarnNPMAudit(out)
+	if err != nil {
+		t.Fatalf("parseYarnNPMAudit() got error: %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("parseYarnNPMAudit() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/Masterminds/semver"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// BunLock is the name of the Bun lock file.
+	BunLock = "bun.lockb"
+	// BunConfig is the name of the Bun configuration file.
+	BunConfig = "bunfig.toml"
+	// defaultBunVersion is the version of Bun installed when the application does not specify one,
+	// so that builds are reproducible and do not depend on the latest release.
+	defaultBunVersion = "1.1.38"
+)
+
+// detectBunVersion determines the version of Bun that should be installed in a Node.js project.
+// GOOGLE_BUN_VERSION takes precedence, then the "engines.bun" constraint of package.json, which is
+// resolved against the versions published in the NPM registry, then an exact version in the
+// "packageManager" field. It returns defaultBunVersion if none of them is set.
+func detectBunVersion(pjs *PackageJSON) (string, error) {
+	if version := os.Getenv(env.BunVersion); version != "" {
+		if _, err := semver.NewVersion(version); err != nil {
+			return "", gcp.UserErrorf("parsing %s=%q: %v", env.BunVersion, version, err)
+		}
+		return version, nil
+	}
+	if pjs == nil {
+		return defaultBunVersion, nil
+	}
+	if requested := pjs.Engines.Bun; requested != "" {
+		version, err := resolvePackageVersion("bun", requested)
+		if err != nil {
+			return "", gcp.UserErrorf("finding bun version that matched %q: %v", requested, err)
+		}
+		return version, nil
+	}
+	pm, err := ParsePackageManager(pjs)
+	if err != nil {
+		return "", err
+	}
+	if pm != nil && pm.Name == "bun" {
+		if _, err := semver.NewVersion(pm.Version); err != nil {
+			return "", gcp.UserErrorf("parsing bun version %q from packageManager in package.json: %v", pm.Version, err)
+		}
+		return pm.Version, nil
+	}
+	return defaultBunVersion, nil
+}
+
+// InstallBunLayer installs Bun in the given layer if it is not already cached and returns the
+// installed version.
+func InstallBunLayer(ctx *gcp.Context, bunLayer *libcnb.Layer, pjs *PackageJSON) (string, error) {
+	layerName := bunLayer.Name
+	version, err := detectBunVersion(pjs)
+	if err != nil {
+		return "", err
+	}
+
+	// Check the metadata in the cache layer to determine if we need to proceed.
+	metaVersion := ctx.GetMetadata(bunLayer, versionKey)
+	if version == metaVersion {
+		ctx.CacheHit(layerName)
+		ctx.Logf("bun cache hit: %q, %q, skipping installation.", version, metaVersion)
+	} else {
+		ctx.CacheMiss(layerName)
+		if err := ctx.ClearLayer(bunLayer); err != nil {
+			return "", fmt.Errorf("clearing layer %q: %w", layerName, err)
+		}
+		// The bun package on the NPM registry installs the prebuilt binary for the current platform.
+		ctx.Logf("Installing bun v%s", version)
+		prefix := fmt.Sprintf("--prefix=%s", bunLayer.Path)
+		pkg := fmt.Sprintf("bun@%s", version)
+		if _, err := ctx.Exec([]string{"npm", "install", "--global", "--quiet", prefix, pkg}, gcp.WithUserAttribution); err != nil {
+			return "", err
+		}
+	}
+
+	// Store layer flags and metadata.
+	ctx.SetMetadata(bunLayer, versionKey, version)
+	// We need to update the path here to ensure the version we just installed take precendence over
+	// anything pre-installed in the base image.
+	if err := ctx.Setenv("PATH", filepath.Join(bunLayer.Path, "bin")+":"+os.Getenv("PATH")); err != nil {
+		return "", err
+	}
+	ctx.AddBOMEntry(libcnb.BOMEntry{
+		Name:     layerName,
+		Metadata: map[string]interface{}{"version": version},
+		Launch:   bunLayer.Launch,
+		Build:    bunLayer.Build,
+	})
+	return version, nil
+}
@@ -0,0 +1,130 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+func TestDetectBunVersion(t *testing.T) {
+	registry := `{
+		"name": "bun",
+		"dist-tags": {
+			"latest": "1.0.3",
+			"canary": "1.0.4-canary.20231001"
+		},
+		"versions": {
+			"0.8.1": {},
+			"1.0.0": {},
+			"1.0.3": {},
+			"1.0.4-canary.20231001": {}
+		}
+	}`
+	testCases := []struct {
+		name       string
+		pjs        *PackageJSON
+		envVersion string
+		httpStatus int
+		want       string
+		wantError  bool
+	}{
+		{
+			name: "no package.json",
+			want: defaultBunVersion,
+		},
+		{
+			name: "no engines.bun",
+			pjs:  &PackageJSON{},
+			want: defaultBunVersion,
+		},
+		{
+			name:       "registry is not used for the default version",
+			pjs:        &PackageJSON{},
+			httpStatus: http.StatusNotFound,
+			want:       defaultBunVersion,
+		},
+		{
+			name:       "env var",
+			pjs:        &PackageJSON{Engines: packageEnginesJSON{Bun: "^1.0.0"}, PackageManager: "bun@1.0.0"},
+			envVersion: "1.0.4",
+			want:       "1.0.4",
+		},
+		{
+			name:       "invalid env var",
+			envVersion: "latest",
+			wantError:  true,
+		},
+		{
+			name: "packageManager",
+			pjs:  &PackageJSON{PackageManager: "bun@1.0.0+sha256.abc"},
+			want: "1.0.0",
+		},
+		{
+			name: "packageManager of another package manager",
+			pjs:  &PackageJSON{PackageManager: "pnpm@8.6.2"},
+			want: defaultBunVersion,
+		},
+		{
+			name:      "invalid packageManager version",
+			pjs:       &PackageJSON{PackageManager: "bun@canary"},
+			wantError: true,
+		},
+		{
+			name: "engines.bun takes precedence over packageManager",
+			pjs:  &PackageJSON{Engines: packageEnginesJSON{Bun: "1.0.3"}, PackageManager: "bun@1.0.0"},
+			want: "1.0.3",
+		},
+		{
+			name: "exact version",
+			pjs:  &PackageJSON{Engines: packageEnginesJSON{Bun: "1.0.0"}},
+			want: "1.0.0",
+		},
+		{
+			name: "version range",
+			pjs:  &PackageJSON{Engines: packageEnginesJSON{Bun: "^1.0.0"}},
+			want: "1.0.3",
+		},
+		{
+			name:      "unsatisfiable range",
+			pjs:       &PackageJSON{Engines: packageEnginesJSON{Bun: ">=2.0.0"}},
+			wantError: true,
+		},
+		{
+			name:       "registry error",
+			pjs:        &PackageJSON{Engines: packageEnginesJSON{Bun: "^1.0.0"}},
+			httpStatus: http.StatusNotFound,
+			wantError:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stubNPMRegistry(t, registry, tc.httpStatus)
+			t.Setenv(env.BunVersion, tc.envVersion)
+
+			got, err := detectBunVersion(tc.pjs)
+
+			if tc.wantError == (err == nil) {
+				t.Fatalf("detectBunVersion(%v) got error: %v, want error? %v", tc.pjs, err, tc.wantError)
+			}
+			if got != tc.want {
+				t.Errorf("detectBunVersion(%v) = %q, want %q", tc.pjs, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// EnvConcurrencyLint enables build warnings for function code that is known to misbehave when
+	// an instance handles concurrent requests.
+	// Example: `true`
+	EnvConcurrencyLint = "GOOGLE_NODEJS_CONCURRENCY_LINT"
+
+	concurrencyDocURL = "https://cloud.google.com/functions/docs/configuring/concurrency"
+)
+
+// ConcurrencyFinding is a code pattern reported by the concurrency lint script.
+type ConcurrencyFinding struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ConcurrencyLintEnabled returns true if the concurrency lint was requested by the user.
+func ConcurrencyLintEnabled() (bool, error) {
+	return env.IsPresentAndTrue(EnvConcurrencyLint)
+}
+
+// LintConcurrency runs the concurrency lint script over the given files, relative to the
+// application root, and emits each finding as a build warning. The lint is advisory: problems
+// running the script are logged and never fail the build.
+func LintConcurrency(ctx *gcp.Context, script string, files ...string) {
+	cmd := append([]string{"node", "--expose-internals", script}, files...)
+	result, err := ctx.Exec(cmd, gcp.WithWorkDir(ctx.ApplicationRoot()))
+	if err != nil {
+		ctx.Warnf("Skipping concurrency checks: %v", err)
+		return
+	}
+	findings, err := parseConcurrencyFindings(result.Stdout)
+	if err != nil {
+		ctx.Warnf("Skipping concurrency checks: %v", err)
+		return
+	}
+	for _, f := range findings {
+		ctx.Warnf("%s:%d:%d: %s (%s)", f.File, f.Line, f.Column, f.Message, f.Rule)
+	}
+	if len(findings) > 0 {
+		ctx.Warnf("The function may not be safe to run with concurrency greater than 1. These warnings do not fail the build; see %s.", concurrencyDocURL)
+	}
+}
+
+// parseConcurrencyFindings parses the JSON output of the concurrency lint script.
+func parseConcurrencyFindings(out string) ([]ConcurrencyFinding, error) {
+	var findings []ConcurrencyFinding
+	if err := json.Unmarshal([]byte(out), &findings); err != nil {
+		return nil, fmt.Errorf("parsing concurrency lint output %q: %v", out, err)
+	}
+	return findings, nil
+}
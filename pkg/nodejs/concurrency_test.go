@@ -0,0 +1,59 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseConcurrencyFindings(t *testing.T) {
+	testCases := []struct {
+		name    string
+		out     string
+		want    []ConcurrencyFinding
+		wantErr bool
+	}{
+		{
+			name: "no findings",
+			out:  "[]",
+			want: []ConcurrencyFinding{},
+		},
+		{
+			name: "findings",
+			out:  `[{"file":"index.js","line":4,"column":3,"rule":"process-chdir","message":"process.chdir is shared"}]`,
+			want: []ConcurrencyFinding{
+				{File: "index.js", Line: 4, Column: 3, Rule: "process-chdir", Message: "process.chdir is shared"},
+			},
+		},
+		{
+			name:    "invalid output",
+			out:     "Skipping index.js",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseConcurrencyFindings(tc.out)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("parseConcurrencyFindings(%q) got err=%v, want err=%t", tc.out, err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("parseConcurrencyFindings(%q) returned unexpected findings (-want, +got):\n%s", tc.out, diff)
+			}
+		})
+	}
+}
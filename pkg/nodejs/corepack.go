@@ -0,0 +1,167 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+	"github.com/Masterminds/semver"
+)
+
+// corepackMinNodeVersion is the first version of Node.js that includes corepack.
+var corepackMinNodeVersion = semver.MustParse("16.9.0")
+
+// PackageManager is the package manager pinned in the "packageManager" field of package.json, see
+// https://nodejs.org/api/corepack.html.
+type PackageManager struct {
+	// Name is the name of the package manager, e.g. "yarn" or "pnpm".
+	Name string
+	// Version is the version of the package manager, e.g. "4.1.0".
+	Version string
+	// Hash is the optional hash of the package manager archive, e.g. "sha224.abc123".
+	Hash string
+}
+
+// String returns the package manager in the format of the "packageManager" field.
+func (pm PackageManager) String() string {
+	if pm.Hash == "" {
+		return pm.Name + "@" + pm.Version
+	}
+	return pm.Name + "@" + pm.Version + "+" + pm.Hash
+}
+
+// ParsePackageManager parses the "packageManager" field of package.json, e.g. "yarn@4.1.0" or
+// "pnpm@8.6.2+sha224.abc123". It returns nil if the field is not set.
+func ParsePackageManager(pjs *PackageJSON) (*PackageManager, error) {
+	if pjs == nil || pjs.PackageManager == "" {
+		return nil, nil
+	}
+	parts := strings.SplitN(pjs.PackageManager, "@", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, gcp.UserErrorf("invalid packageManager %q in package.json, must be <name>@<version>", pjs.PackageManager)
+	}
+	pm := &PackageManager{Name: parts[0], Version: parts[1]}
+	if i := strings.Index(pm.Version, "+"); i >= 0 {
+		pm.Version, pm.Hash = pm.Version[:i], pm.Version[i+1:]
+	}
+	return pm, nil
+}
+
+// corepackManager returns the package manager to install with corepack, or nil if the
+// "packageManager" field of package.json does not pin the given package manager. The version
+// constraint in engines takes precedence over the packageManager field.
+func corepackManager(pjs *PackageJSON, name string) (*PackageManager, error) {
+	if pjs == nil {
+		return nil, nil
+	}
+	if engines := map[string]string{"yarn": pjs.Engines.Yarn, "pnpm": pjs.Engines.PNPM}; engines[name] != "" {
+		return nil, nil
+	}
+	pm, err := ParsePackageManager(pjs)
+	if err != nil || pm == nil || pm.Name != name {
+		return nil, err
+	}
+	return pm, nil
+}
+
+// supportsCorepack returns true if the installed version of Node.js includes corepack.
+func supportsCorepack(ctx *gcp.Context) (bool, error) {
+	nodeVer, err := nodeVersion(ctx)
+	if err != nil {
+		return false, err
+	}
+	version, err := semver.NewVersion(strings.TrimSpace(nodeVer))
+	if err != nil {
+		return false, gcp.InternalErrorf("failed to detect valid Node.js version %s: %v", nodeVer, err)
+	}
+	return !version.LessThan(corepackMinNodeVersion), nil
+}
+
+// installCorepackLayer installs the package manager pinned in package.json with corepack, so that
+// the build uses exactly the same version as the developer tooling. The corepack shims are
+// installed in the bin directory of the layer and the package manager is downloaded in the layer,
+// which is kept in the cache while the packageManager field does not change.
+func installCorepackLayer(ctx *gcp.Context, l *libcnb.Layer, pm *PackageManager) error {
+	home := filepath.Join(l.Path, "corepack")
+	bin := filepath.Join(l.Path, "bin")
+	// The metadata is prefixed so that the layer is not reused by an installation without corepack.
+	metaVersion := "corepack:" + pm.String()
+	corepackEnv := []string{"COREPACK_HOME=" + home, "COREPACK_ENABLE_DOWNLOAD_PROMPT=0"}
+
+	if ctx.GetMetadata(l, versionKey) == metaVersion {
+		ctx.CacheHit(l.Name)
+		ctx.Logf("%s cache hit, skipping installation.", pm)
+	} else {
+		ctx.CacheMiss(l.Name)
+		if err := ctx.ClearLayer(l); err != nil {
+			return fmt.Errorf("clearing layer %q: %w", l.Name, err)
+		}
+		if err := ctx.MkdirAll(bin, 0755); err != nil {
+			return err
+		}
+		ctx.Logf("Installing %s with corepack", pm)
+		if _, err := ctx.Exec([]string{"corepack", "enable", "--install-directory", bin, pm.Name}, gcp.WithEnv(corepackEnv...), gcp.WithUserAttribution); err != nil {
+			return err
+		}
+		if _, err := ctx.Exec([]string{"corepack", "prepare", pm.Name + "@" + pm.Version}, gcp.WithEnv(corepackEnv...), gcp.WithNetworkRetry, gcp.WithUserAttribution); err != nil {
+			return err
+		}
+	}
+
+	ctx.SetMetadata(l, versionKey, metaVersion)
+	for _, e := range corepackEnv {
+		kv := strings.SplitN(e, "=", 2)
+		if err := ctx.Setenv(kv[0], kv[1]); err != nil {
+			return err
+		}
+		l.SharedEnvironment.Default(kv[0], kv[1])
+	}
+	// We need to update the path here to ensure the version we just installed take precendence over
+	// anything pre-installed in the base image.
+	if err := ctx.Setenv("PATH", bin+":"+os.Getenv("PATH")); err != nil {
+		return err
+	}
+	ctx.AddBOMEntry(libcnb.BOMEntry{
+		Name:     l.Name,
+		Metadata: map[string]interface{}{"version": pm.Version, "corepack": true},
+		Launch:   l.Launch,
+		Build:    l.Build,
+	})
+	return nil
+}
+
+// installWithCorepack installs the package manager with corepack if it is pinned in the
+// "packageManager" field of package.json and the installed Node.js includes corepack. It returns
+// false if the package manager must be installed without corepack.
+func installWithCorepack(ctx *gcp.Context, l *libcnb.Layer, pjs *PackageJSON, name string) (bool, error) {
+	pm, err := corepackManager(pjs, name)
+	if err != nil || pm == nil {
+		return false, err
+	}
+	supported, err := supportsCorepack(ctx)
+	if err != nil {
+		return false, err
+	}
+	if !supported {
+		ctx.Warnf("Ignoring packageManager %q in package.json, corepack requires Node.js %s or newer.", pm, corepackMinNodeVersion)
+		return false, nil
+	}
+	return true, installCorepackLayer(ctx, l, pm)
+}
@@ -0,0 +1,136 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParsePackageManager(t *testing.T) {
+	testCases := []struct {
+		name           string
+		packageManager string
+		want           *PackageManager
+		wantErr        bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:           "yarn",
+			packageManager: "yarn@4.1.0",
+			want:           &PackageManager{Name: "yarn", Version: "4.1.0"},
+		},
+		{
+			name:           "with hash",
+			packageManager: "pnpm@8.6.2+sha224.abc123",
+			want:           &PackageManager{Name: "pnpm", Version: "8.6.2", Hash: "sha224.abc123"},
+		},
+		{
+			name:           "missing version",
+			packageManager: "yarn",
+			wantErr:        true,
+		},
+		{
+			name:           "empty version",
+			packageManager: "pnpm@",
+			wantErr:        true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParsePackageManager(&PackageJSON{PackageManager: tc.packageManager})
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("ParsePackageManager(%q) got error: %v, want error? %v", tc.packageManager, err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("ParsePackageManager(%q) mismatch (-want +got):\n%s", tc.packageManager, diff)
+			}
+			if got != nil && got.String() != tc.packageManager {
+				t.Errorf("String() = %q, want %q", got.String(), tc.packageManager)
+			}
+		})
+	}
+}
+
+func TestCorepackManager(t *testing.T) {
+	testCases := []struct {
+		name    string
+		pjs     *PackageJSON
+		manager string
+		want    *PackageManager
+	}{
+		{
+			name:    "no package.json",
+			manager: "yarn",
+		},
+		{
+			name:    "matching package manager",
+			pjs:     &PackageJSON{PackageManager: "yarn@4.1.0"},
+			manager: "yarn",
+			want:    &PackageManager{Name: "yarn", Version: "4.1.0"},
+		},
+		{
+			name:    "other package manager",
+			pjs:     &PackageJSON{PackageManager: "pnpm@9.1.0"},
+			manager: "yarn",
+		},
+		{
+			name:    "engines takes precedence",
+			pjs:     &PackageJSON{PackageManager: "pnpm@9.1.0", Engines: packageEnginesJSON{PNPM: "8.x"}},
+			manager: "pnpm",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := corepackManager(tc.pjs, tc.manager)
+			if err != nil {
+				t.Fatalf("corepackManager(%v, %q) got error: %v", tc.pjs, tc.manager, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("corepackManager(%v, %q) mismatch (-want +got):\n%s", tc.pjs, tc.manager, diff)
+			}
+		})
+	}
+}
+
+func TestSupportsCorepack(t *testing.T) {
+	testCases := []struct {
+		nodeVersion string
+		want        bool
+	}{
+		{nodeVersion: "v14.21.3", want: false},
+		{nodeVersion: "v16.8.0", want: false},
+		{nodeVersion: "v16.9.0", want: true},
+		{nodeVersion: "v20.5.0\n", want: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.nodeVersion, func(t *testing.T) {
+			defer func(fn func(*gcp.Context) (string, error)) { nodeVersion = fn }(nodeVersion)
+			nodeVersion = func(*gcp.Context) (string, error) { return tc.nodeVersion, nil }
+
+			got, err := supportsCorepack(gcp.NewContext())
+			if err != nil {
+				t.Fatalf("supportsCorepack() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("supportsCorepack() with Node.js %s = %t, want %t", tc.nodeVersion, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// NextJS is the package of the Next.js framework.
+	NextJS = "next"
+	// Nuxt is the package of the Nuxt framework.
+	Nuxt = "nuxt"
+	// Remix is the package of the Remix compiler, a dependency of all Remix applications.
+	Remix = "@remix-run/dev"
+	// SvelteKit is the package of the SvelteKit framework.
+	SvelteKit = "@sveltejs/kit"
+	// NestJS is the package of the NestJS framework.
+	NestJS = "@nestjs/core"
+
+	gcpBuildScript = "gcp-build"
+	buildScript    = "build"
+
+	remixServe       = "@remix-run/serve"
+	remixNode        = "@remix-run/node"
+	svelteKitAdapter = "@sveltejs/adapter-node"
+)
+
+// builtFrameworks are the packages of the frameworks whose applications must be built before they
+// are started, in order of precedence.
+var builtFrameworks = []string{NextJS, Nuxt, Remix, SvelteKit, NestJS}
+
+var (
+	// nitroServerPresets are the Nitro presets that build a Node.js server.
+	nitroServerPresets = map[string]bool{"node-server": true, "node": true, "node-cluster": true}
+	nitroPresetRegexp  = regexp.MustCompile(`\bpreset\s*:\s*['"]([\w-]+)['"]`)
+	nuxtConfigs        = []string{"nuxt.config.ts", "nuxt.config.js", "nuxt.config.mjs"}
+
+	// remixAdapters are the Remix runtime adapters for platforms other than Node.js.
+	remixAdapters = []string{"@remix-run/cloudflare", "@remix-run/cloudflare-pages", "@remix-run/deno", "@remix-run/architect", "@vercel/remix", "@netlify/remix-adapter"}
+	// svelteKitAdapters are the SvelteKit adapters that do not build a Node.js server.
+	svelteKitAdapters = []string{"@sveltejs/adapter-auto", "@sveltejs/adapter-static", "@sveltejs/adapter-vercel", "@sveltejs/adapter-netlify", "@sveltejs/adapter-cloudflare"}
+)
+
+// Framework returns the package of the framework the application is built with, or an empty
+// string if it does not depend on one of the frameworks supported by the buildpacks.
+func Framework(p *PackageJSON) string {
+	if p == nil {
+		return ""
+	}
+	for _, f := range builtFrameworks {
+		if HasDependency(p, f) {
+			return f
+		}
+	}
+	return ""
+}
+
+// HasDependency returns whether the package is a dependency or a devDependency.
+func HasDependency(p *PackageJSON, pkg string) bool {
+	if p == nil {
+		return false
+	}
+	if _, ok := p.Dependencies[pkg]; ok {
+		return true
+	}
+	_, ok := p.DevDependencies[pkg]
+	return ok
+}
+
+// BuildScript returns the package.json script that builds the application: the gcp-build script
+// if there is one, otherwise the build script of applications using a framework that requires a
+// build, e.g. `next build`. It returns an empty string if the application is not built.
+func BuildScript(p *PackageJSON) string {
+	if HasGCPBuild(p) {
+		return gcpBuildScript
+	}
+	if p != nil && p.Scripts.Build != "" && Framework(p) != "" {
+		return buildScript
+	}
+	return ""
+}
+
+// FrameworkServer returns the command that starts the server built by a Nuxt, Remix or SvelteKit
+// application, or nil if the application is started with its start script, e.g. a Remix
+// application with a custom server.
+func FrameworkServer(ctx *gcp.Context, p *PackageJSON) ([]string, error) {
+	var cmd []string
+	var entries []string
+	switch Framework(p) {
+	case Nuxt:
+		entries = []string{".output/server/index.mjs"}
+		cmd = []string{"node"}
+	case Remix:
+		if !HasDependency(p, remixServe) {
+			return nil, nil
+		}
+		// Remix applications built with Vite write the server build to build/server.
+		entries = []string{"build/server/index.js", "build/index.js"}
+		cmd = []string{"remix-serve"}
+	case SvelteKit:
+		if !HasDependency(p, svelteKitAdapter) {
+			return nil, nil
+		}
+		entries = []string{"build/index.js"}
+		cmd = []string{"node"}
+	default:
+		return nil, nil
+	}
+	for _, e := range entries {
+		exists, err := ctx.FileExists(ctx.ApplicationRoot(), e)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return append(cmd, e), nil
+		}
+	}
+	return nil, gcp.UserErrorf("%s server entry not found, expected one of %s after the build, make sure the build script builds the application", Framework(p), strings.Join(entries, ", "))
+}
+
+// AdapterWarning returns a warning if the framework of the application is configured to build for
+// a platform other than a Node.js server, or an empty string otherwise.
+func AdapterWarning(ctx *gcp.Context, p *PackageJSON) (string, error) {
+	switch Framework(p) {
+	case Nuxt:
+		preset, err := nitroPreset(ctx)
+		if err != nil || preset == "" || nitroServerPresets[preset] {
+			return "", err
+		}
+		return fmt.Sprintf("Nuxt is configured with the Nitro preset %q, which does not build a Node.js server. Use the node-server preset.", preset), nil
+	case Remix:
+		if HasDependency(p, remixNode) {
+			return "", nil
+		}
+		for _, a := range remixAdapters {
+			if HasDependency(p, a) {
+				return fmt.Sprintf("Remix is configured with %s, which does not run on Node.js. Use %s and %s.", a, remixNode, remixServe), nil
+			}
+		}
+	case SvelteKit:
+		if HasDependency(p, svelteKitAdapter) {
+			return "", nil
+		}
+		for _, a := range svelteKitAdapters {
+			if HasDependency(p, a) {
+				return fmt.Sprintf("SvelteKit is configured with %s, which does not build a Node.js server. Use %s.", a, svelteKitAdapter), nil
+			}
+		}
+		return fmt.Sprintf("SvelteKit is not configured with %s, the application is started with its start script.", svelteKitAdapter), nil
+	}
+	return "", nil
+}
+
+// nitroPreset returns the Nitro preset set with NITRO_PRESET or in the Nuxt configuration file, or
+// an empty string if the default node-server preset is used.
+func nitroPreset(ctx *gcp.Context) (string, error) {
+	if preset := os.Getenv("NITRO_PRESET"); preset != "" {
+		return preset, nil
+	}
+	for _, c := range nuxtConfigs {
+		path := filepath.Join(ctx.ApplicationRoot(), c)
+		exists, err := ctx.FileExists(path)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			continue
+		}
+		content, err := ctx.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		if m := nitroPresetRegexp.FindSubmatch(content); m != nil {
+			return string(m[1]), nil
+		}
+		return "", nil
+	}
+	return "", nil
+}
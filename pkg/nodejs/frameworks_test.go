@@ -0,0 +1,306 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestFramework(t *testing.T) {
+	testCases := []struct {
+		name        string
+		packageJSON *PackageJSON
+		want        string
+	}{
+		{
+			name: "nil package",
+		},
+		{
+			name:        "next dependency",
+			packageJSON: &PackageJSON{Dependencies: map[string]string{"next": "^13.4.0", "react": "^18.2.0"}},
+			want:        NextJS,
+		},
+		{
+			name:        "next devDependency",
+			packageJSON: &PackageJSON{DevDependencies: map[string]string{"next": "^13.4.0"}},
+			want:        NextJS,
+		},
+		{
+			name:        "nuxt",
+			packageJSON: &PackageJSON{DevDependencies: map[string]string{"nuxt": "^3.8.0"}},
+			want:        Nuxt,
+		},
+		{
+			name:        "remix",
+			packageJSON: &PackageJSON{Dependencies: map[string]string{"@remix-run/node": "^2.2.0"}, DevDependencies: map[string]string{"@remix-run/dev": "^2.2.0"}},
+			want:        Remix,
+		},
+		{
+			name:        "sveltekit",
+			packageJSON: &PackageJSON{DevDependencies: map[string]string{"@sveltejs/kit": "^1.27.0", "@sveltejs/adapter-node": "^1.3.1"}},
+			want:        SvelteKit,
+		},
+		{
+			name:        "nestjs",
+			packageJSON: &PackageJSON{Dependencies: map[string]string{"@nestjs/common": "^10.0.0", "@nestjs/core": "^10.0.0"}},
+			want:        NestJS,
+		},
+		{
+			name:        "no framework",
+			packageJSON: &PackageJSON{Dependencies: map[string]string{"express": "^4.18.2"}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Framework(tc.packageJSON); got != tc.want {
+				t.Errorf("Framework() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBuildScript(t *testing.T) {
+	testCases := []struct {
+		name        string
+		packageJSON *PackageJSON
+		want        string
+	}{
+		{
+			name: "nil package",
+		},
+		{
+			name: "gcp-build",
+			packageJSON: &PackageJSON{
+				Scripts:      packageScriptsJSON{GCPBuild: "tsc", Build: "next build"},
+				Dependencies: map[string]string{"next": "^13.4.0"},
+			},
+			want: "gcp-build",
+		},
+		{
+			name: "next build",
+			packageJSON: &PackageJSON{
+				Scripts:      packageScriptsJSON{Build: "next build"},
+				Dependencies: map[string]string{"next": "^13.4.0"},
+			},
+			want: "build",
+		},
+		{
+			name: "nest build",
+			packageJSON: &PackageJSON{
+				Scripts:      packageScriptsJSON{Build: "nest build", Start: "nest start"},
+				Dependencies: map[string]string{"@nestjs/core": "^10.0.0"},
+			},
+			want: "build",
+		},
+		{
+			name:        "next without build script",
+			packageJSON: &PackageJSON{Dependencies: map[string]string{"next": "^13.4.0"}},
+		},
+		{
+			name: "build script without framework",
+			packageJSON: &PackageJSON{
+				Scripts:      packageScriptsJSON{Build: "tsc"},
+				Dependencies: map[string]string{"express": "^4.18.2"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := BuildScript(tc.packageJSON); got != tc.want {
+				t.Errorf("BuildScript() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFrameworkServer(t *testing.T) {
+	testCases := []struct {
+		name        string
+		packageJSON *PackageJSON
+		files       []string
+		want        []string
+		wantErr     bool
+	}{
+		{
+			name:        "nuxt",
+			packageJSON: &PackageJSON{DevDependencies: map[string]string{"nuxt": "^3.8.0"}},
+			files:       []string{".output/server/index.mjs"},
+			want:        []string{"node", ".output/server/index.mjs"},
+		},
+		{
+			name:        "nuxt without output",
+			packageJSON: &PackageJSON{DevDependencies: map[string]string{"nuxt": "^3.8.0"}},
+			wantErr:     true,
+		},
+		{
+			name: "remix vite",
+			packageJSON: &PackageJSON{
+				Dependencies:    map[string]string{"@remix-run/node": "^2.2.0", "@remix-run/serve": "^2.2.0"},
+				DevDependencies: map[string]string{"@remix-run/dev": "^2.2.0"},
+			},
+			files: []string{"build/server/index.js", "build/client/favicon.ico"},
+			want:  []string{"remix-serve", "build/server/index.js"},
+		},
+		{
+			name: "remix classic",
+			packageJSON: &PackageJSON{
+				Dependencies:    map[string]string{"@remix-run/node": "^2.2.0", "@remix-run/serve": "^2.2.0"},
+				DevDependencies: map[string]string{"@remix-run/dev": "^2.2.0"},
+			},
+			files: []string{"build/index.js"},
+			want:  []string{"remix-serve", "build/index.js"},
+		},
+		{
+			name: "remix custom server",
+			packageJSON: &PackageJSON{
+				Dependencies:    map[string]string{"@remix-run/express": "^2.2.0", "express": "^4.18.2"},
+				DevDependencies: map[string]string{"@remix-run/dev": "^2.2.0"},
+			},
+			files: []string{"build/index.js"},
+		},
+		{
+			name:        "sveltekit adapter-node",
+			packageJSON: &PackageJSON{DevDependencies: map[string]string{"@sveltejs/kit": "^1.27.0", "@sveltejs/adapter-node": "^1.3.1"}},
+			files:       []string{"build/index.js"},
+			want:        []string{"node", "build/index.js"},
+		},
+		{
+			name:        "sveltekit adapter-auto",
+			packageJSON: &PackageJSON{DevDependencies: map[string]string{"@sveltejs/kit": "^1.27.0", "@sveltejs/adapter-auto": "^2.0.0"}},
+		},
+		{
+			name:        "next",
+			packageJSON: &PackageJSON{Dependencies: map[string]string{"next": "^13.4.0"}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			for _, f := range tc.files {
+				path := filepath.Join(root, f)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("creating directory for %s: %v", f, err)
+				}
+				if err := os.WriteFile(path, nil, 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(root))
+
+			got, err := FrameworkServer(ctx, tc.packageJSON)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("FrameworkServer() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("FrameworkServer() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAdapterWarning(t *testing.T) {
+	testCases := []struct {
+		name        string
+		packageJSON *PackageJSON
+		files       map[string]string
+		nitroPreset string
+		want        string
+	}{
+		{
+			name:        "nuxt default preset",
+			packageJSON: &PackageJSON{DevDependencies: map[string]string{"nuxt": "^3.8.0"}},
+			files:       map[string]string{"nuxt.config.ts": "export default defineNuxtConfig({})"},
+		},
+		{
+			name:        "nuxt node-server preset",
+			packageJSON: &PackageJSON{DevDependencies: map[string]string{"nuxt": "^3.8.0"}},
+			files:       map[string]string{"nuxt.config.ts": "export default defineNuxtConfig({nitro: {preset: 'node-server'}})"},
+		},
+		{
+			name:        "nuxt vercel preset",
+			packageJSON: &PackageJSON{DevDependencies: map[string]string{"nuxt": "^3.8.0"}},
+			files:       map[string]string{"nuxt.config.ts": "export default defineNuxtConfig({\n  nitro: {\n    preset: \"vercel\",\n  },\n})"},
+			want:        `Nitro preset "vercel"`,
+		},
+		{
+			name:        "nuxt NITRO_PRESET",
+			packageJSON: &PackageJSON{DevDependencies: map[string]string{"nuxt": "^3.8.0"}},
+			nitroPreset: "cloudflare",
+			want:        `Nitro preset "cloudflare"`,
+		},
+		{
+			name: "remix node",
+			packageJSON: &PackageJSON{
+				Dependencies:    map[string]string{"@remix-run/node": "^2.2.0", "@remix-run/serve": "^2.2.0"},
+				DevDependencies: map[string]string{"@remix-run/dev": "^2.2.0"},
+			},
+		},
+		{
+			name: "remix cloudflare",
+			packageJSON: &PackageJSON{
+				Dependencies:    map[string]string{"@remix-run/cloudflare": "^2.2.0"},
+				DevDependencies: map[string]string{"@remix-run/dev": "^2.2.0"},
+			},
+			want: "Remix is configured with @remix-run/cloudflare",
+		},
+		{
+			name:        "sveltekit adapter-node",
+			packageJSON: &PackageJSON{DevDependencies: map[string]string{"@sveltejs/kit": "^1.27.0", "@sveltejs/adapter-node": "^1.3.1"}},
+		},
+		{
+			name:        "sveltekit adapter-auto",
+			packageJSON: &PackageJSON{DevDependencies: map[string]string{"@sveltejs/kit": "^1.27.0", "@sveltejs/adapter-auto": "^2.0.0"}},
+			want:        "SvelteKit is configured with @sveltejs/adapter-auto",
+		},
+		{
+			name:        "sveltekit without adapter",
+			packageJSON: &PackageJSON{DevDependencies: map[string]string{"@sveltejs/kit": "^1.27.0"}},
+			want:        "SvelteKit is not configured with @sveltejs/adapter-node",
+		},
+		{
+			name:        "express",
+			packageJSON: &PackageJSON{Dependencies: map[string]string{"express": "^4.18.2"}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("NITRO_PRESET", tc.nitroPreset)
+			root := t.TempDir()
+			for f, content := range tc.files {
+				if err := os.WriteFile(filepath.Join(root, f), []byte(content), 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(root))
+
+			got, err := AdapterWarning(ctx, tc.packageJSON)
+			if err != nil {
+				t.Fatalf("AdapterWarning() got error: %v", err)
+			}
+			if tc.want == "" && got != "" {
+				t.Errorf("AdapterWarning() = %q, want no warning", got)
+			}
+			if !strings.Contains(got, tc.want) {
+				t.Errorf("AdapterWarning() = %q, want it to contain %q", got, tc.want)
+			}
+		})
+	}
+}
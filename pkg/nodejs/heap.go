@@ -0,0 +1,62 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/execd"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// HeapHeadroomMB is the amount of memory set aside for everything but the V8 heap when the
+	// heap size is derived from the memory available to the container.
+	HeapHeadroomMB = 64
+
+	// heapSizeExecD is the name of the exec.d executable that sets the heap size at launch, it is
+	// built from cmd/nodejs/heap_size.
+	heapSizeExecD = "heap-size"
+)
+
+// HeapSizeEnv returns NODE_OPTIONS with --max-old-space-size set to the memory limit of the
+// container minus HeapHeadroomMB. It is run at launch by the heap-size exec.d executable.
+// NODE_OPTIONS is left untouched if it already sets the heap size or if the container has no
+// memory limit.
+func HeapSizeEnv() (map[string]string, error) {
+	if strings.Contains(os.Getenv("NODE_OPTIONS"), "--max-old-space-size") {
+		return nil, nil
+	}
+	limit, ok := execd.MemoryLimit()
+	if !ok {
+		return nil, nil
+	}
+	heap := limit/1024/1024 - HeapHeadroomMB
+	if heap <= 0 {
+		return nil, nil
+	}
+	return map[string]string{
+		"NODE_OPTIONS": execd.Append("NODE_OPTIONS", " ", fmt.Sprintf("--max-old-space-size=%d", heap)),
+	}, nil
+}
+
+// AddHeapSizeExecD adds the heap-size exec.d executable to the image, which sets the maximum heap
+// size of Node.js in NODE_OPTIONS at launch, see HeapSizeEnv.
+func AddHeapSizeExecD(ctx *gcp.Context) error {
+	return ctx.AddExecD(heapSizeExecD, filepath.Join(gcp.ExecDDir, heapSizeExecD))
+}
@@ -0,0 +1,118 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHeapSizeEnv(t *testing.T) {
+	testCases := []struct {
+		name        string
+		files       map[string]string
+		nodeOptions string
+		want        map[string]string
+	}{
+		{
+			name:  "cgroup v2 limit",
+			files: map[string]string{"memory.max": "536870912\n"},
+			want:  map[string]string{"NODE_OPTIONS": "--max-old-space-size=448"},
+		},
+		{
+			name:  "cgroup v1 limit",
+			files: map[string]string{"memory/memory.limit_in_bytes": "1073741824\n"},
+			want:  map[string]string{"NODE_OPTIONS": "--max-old-space-size=960"},
+		},
+		{
+			name:        "appends to existing options",
+			files:       map[string]string{"memory.max": "536870912\n"},
+			nodeOptions: `--require "./tracing.js"`,
+			want:        map[string]string{"NODE_OPTIONS": `--require "./tracing.js" --max-old-space-size=448`},
+		},
+		{
+			name:        "heap size already set",
+			files:       map[string]string{"memory.max": "536870912\n"},
+			nodeOptions: "--max-old-space-size=100",
+		},
+		{
+			name:  "cgroup v2 without limit",
+			files: map[string]string{"memory.max": "max\n"},
+		},
+		{
+			name:  "limit below headroom",
+			files: map[string]string{"memory.max": "33554432\n"},
+		},
+		{
+			name: "no cgroup",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cgroup := t.TempDir()
+			for name, content := range tc.files {
+				path := filepath.Join(cgroup, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("creating %s: %v", filepath.Dir(path), err)
+				}
+				if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatalf("writing %s: %v", path, err)
+				}
+			}
+			t.Setenv("X_GOOGLE_CGROUP_ROOT", cgroup)
+			t.Setenv("NODE_OPTIONS", tc.nodeOptions)
+
+			got, err := HeapSizeEnv()
+			if err != nil {
+				t.Fatalf("HeapSizeEnv() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("HeapSizeEnv() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAddHeapSizeExecD(t *testing.T) {
+	bpRoot := t.TempDir()
+	layers := t.TempDir()
+	binary := filepath.Join(bpRoot, gcp.ExecDDir, heapSizeExecD)
+	if err := os.MkdirAll(filepath.Dir(binary), 0755); err != nil {
+		t.Fatalf("creating %s: %v", filepath.Dir(binary), err)
+	}
+	if err := ioutil.WriteFile(binary, []byte("heap-size binary"), 0755); err != nil {
+		t.Fatalf("writing %s: %v", binary, err)
+	}
+	ctx := gcp.NewContext(gcp.WithBuildpackRoot(bpRoot), gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layers}}))
+
+	if err := AddHeapSizeExecD(ctx); err != nil {
+		t.Fatalf("AddHeapSizeExecD() got error: %v", err)
+	}
+
+	path := filepath.Join(layers, "execd", "exec.d", heapSizeExecD)
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+	if string(got) != "heap-size binary" {
+		t.Errorf("%s content = %q, want the heap-size binary of the buildpack", path, got)
+	}
+}
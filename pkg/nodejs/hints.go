@@ -0,0 +1,84 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/version"
+)
+
+// lockfileOutOfSyncMessages are printed by npm, Yarn, pnpm and bun when an install that must not
+// modify the lockfile fails because the lockfile does not match package.json.
+var lockfileOutOfSyncMessages = []string{
+	"can only install packages when your package.json and package-lock.json",
+	"Your lockfile needs to be updated",
+	"The lockfile would have been modified by this install",
+	"ERR_PNPM_OUTDATED_LOCKFILE",
+	"lockfile had changes, but lockfile is frozen",
+}
+
+// enginesHintCommand sets engines.node to the latest LTS release line.
+var enginesHintCommand = fmt.Sprintf("npm pkg set engines.node=%s.x", latestLTS)
+
+// LockfileError attaches a remediation hint to the error of a failed dependency installation if
+// the output shows that the lockfile is out of sync with package.json. installCmd is the command
+// that updates the lockfile, e.g. "npm install".
+func LockfileError(result *gcp.ExecResult, err error, lockfile, installCmd string) error {
+	var be *buildererror.Error
+	if result == nil || !errors.As(err, &be) {
+		return err
+	}
+	for _, msg := range lockfileOutOfSyncMessages {
+		if strings.Contains(result.Combined, msg) {
+			return be.WithCode(buildererror.CodeNodejsLockfileOutOfSync).
+				WithHint(fmt.Sprintf("%s does not match the dependencies in package.json. Update it locally and commit the result.", lockfile), installCmd)
+		}
+	}
+	return err
+}
+
+// GCPBuildError attaches a remediation hint to the error of a failed build script, see BuildScript.
+// runCmd is the command that runs the script locally, e.g. "npm run gcp-build".
+func GCPBuildError(err error, runCmd string) error {
+	var be *buildererror.Error
+	if !errors.As(err, &be) {
+		return err
+	}
+	return be.WithCode(buildererror.CodeNodejsGCPBuildFailed).
+		WithHint("The build script in package.json failed, see its output above. Reproduce the failure locally after installing the dependencies.", runCmd)
+}
+
+// EnginesError attaches a remediation hint to the error of a failed Node.js installation if the
+// requested version is the engines.node range in package.json.
+func EnginesError(err error, pjs *PackageJSON, requested string) error {
+	var be *buildererror.Error
+	if pjs == nil || pjs.Engines.Node == "" || !errors.As(err, &be) {
+		return err
+	}
+	if c, cerr := version.Constraint(version.NPM, pjs.Engines.Node); cerr != nil || c != requested {
+		return err
+	}
+	return enginesHint(be)
+}
+
+// enginesHint attaches the remediation hint for an unsupported engines.node range to be.
+func enginesHint(be *buildererror.Error) *buildererror.Error {
+	return be.WithHint("No available Node.js version matches engines.node in package.json. Set it to a supported range.", enginesHintCommand)
+}
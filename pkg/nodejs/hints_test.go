@@ -0,0 +1,138 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestLockfileError(t *testing.T) {
+	testCases := []struct {
+		name     string
+		output   string
+		err      error
+		wantHint bool
+	}{
+		{
+			name:     "npm ci",
+			output:   "npm ERR! `npm ci` can only install packages when your package.json and package-lock.json or npm-shrinkwrap.json are in sync.",
+			err:      gcp.UserErrorf("npm ci failed"),
+			wantHint: true,
+		},
+		{
+			name:     "yarn classic",
+			output:   "error Your lockfile needs to be updated, but yarn was run with `--frozen-lockfile`.",
+			err:      gcp.UserErrorf("yarn install failed"),
+			wantHint: true,
+		},
+		{
+			name:     "yarn berry",
+			output:   "YN0028: The lockfile would have been modified by this install, which is explicitly forbidden.",
+			err:      gcp.UserErrorf("yarn install failed"),
+			wantHint: true,
+		},
+		{
+			name:     "pnpm",
+			output:   "ERR_PNPM_OUTDATED_LOCKFILE  Cannot install with \"frozen-lockfile\" because pnpm-lock.yaml is not up to date",
+			err:      gcp.UserErrorf("pnpm install failed"),
+			wantHint: true,
+		},
+		{
+			name:   "other failure",
+			output: "npm ERR! 404 Not Found - GET https://registry.npmjs.org/missing",
+			err:    gcp.UserErrorf("npm ci failed"),
+		},
+		{
+			name:   "not a builder error",
+			output: "npm ERR! `npm ci` can only install packages when your package.json and package-lock.json are in sync.",
+			err:    fmt.Errorf("npm ci failed"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := LockfileError(&gcp.ExecResult{Combined: tc.output}, tc.err, "package-lock.json", "npm install")
+
+			hint := buildererror.HintOf(err)
+			if got := hint != nil; got != tc.wantHint {
+				t.Fatalf("LockfileError() hint = %+v, want hint? %t", hint, tc.wantHint)
+			}
+			if !tc.wantHint {
+				return
+			}
+			if hint.Command != "npm install" {
+				t.Errorf("LockfileError() hint command = %q, want %q", hint.Command, "npm install")
+			}
+			if got, want := buildererror.CodeOf(err), buildererror.CodeNodejsLockfileOutOfSync; got != want {
+				t.Errorf("LockfileError() code = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestGCPBuildError(t *testing.T) {
+	err := GCPBuildError(gcp.UserErrorf("tsc failed"), "npm run gcp-build")
+
+	if hint := buildererror.HintOf(err); hint == nil || hint.Command != "npm run gcp-build" {
+		t.Errorf("GCPBuildError() hint = %+v, want command %q", hint, "npm run gcp-build")
+	}
+	if got, want := buildererror.CodeOf(err), buildererror.CodeNodejsGCPBuildFailed; got != want {
+		t.Errorf("GCPBuildError() code = %q, want %q", got, want)
+	}
+}
+
+func TestEnginesError(t *testing.T) {
+	testCases := []struct {
+		name      string
+		engine    string
+		requested string
+		wantHint  bool
+	}{
+		{
+			name:      "version from engines.node",
+			engine:    "^8",
+			requested: "^8",
+			wantHint:  true,
+		},
+		{
+			name:      "version from elsewhere",
+			engine:    "^8",
+			requested: "16.x",
+		},
+		{
+			name:      "no engines.node",
+			requested: "16.x",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pjs := &PackageJSON{}
+			pjs.Engines.Node = tc.engine
+
+			err := EnginesError(gcp.UserErrorf("invalid Node.js version specified"), pjs, tc.requested)
+
+			hint := buildererror.HintOf(err)
+			if got := hint != nil; got != tc.wantHint {
+				t.Fatalf("EnginesError() hint = %+v, want hint? %t", hint, tc.wantHint)
+			}
+			if tc.wantHint && hint.Command != enginesHintCommand {
+				t.Errorf("EnginesError() hint command = %q, want %q", hint.Command, enginesHintCommand)
+			}
+		})
+	}
+}
@@ -23,9 +23,12 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildcommand"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/version"
 	"github.com/buildpacks/libcnb"
 	"github.com/Masterminds/semver"
 )
@@ -53,15 +56,19 @@ type packageEnginesJSON struct {
 	Node string `json:"node"`
 	NPM  string `json:"npm"`
 	Yarn string `json:"yarn"`
+	PNPM string `json:"pnpm"`
+	Bun  string `json:"bun"`
 }
 
 type packageScriptsJSON struct {
 	Start    string `json:"start"`
+	Build    string `json:"build"`
 	GCPBuild string `json:"gcp-build"`
 }
 
 // PackageJSON represents the contents of a package.json file.
 type PackageJSON struct {
+	Name            string             `json:"name"`
 	Main            string             `json:"main"`
 	Type            string             `json:"type"`
 	Version         string             `json:"version"`
@@ -69,6 +76,8 @@ type PackageJSON struct {
 	Scripts         packageScriptsJSON `json:"scripts"`
 	Dependencies    map[string]string  `json:"dependencies"`
 	DevDependencies map[string]string  `json:"devDependencies"`
+	Workspaces      packageWorkspaces  `json:"workspaces"`
+	PackageManager  string             `json:"packageManager"`
 }
 
 // ReadPackageJSONIfExists returns deserialized package.json from the given dir. If the provided dir
@@ -87,7 +96,7 @@ func ReadPackageJSONIfExists(dir string) (*PackageJSON, error) {
 
 	var pjs PackageJSON
 	if err := json.Unmarshal(rawpjs, &pjs); err != nil {
-		return nil, gcp.UserErrorf("unmarshalling package.json: %v", err)
+		return nil, gcp.UserErrorf("unmarshalling package.json: %v", err).WithCode(buildererror.CodeNodejsPackageJSONParseError)
 	}
 	return &pjs, nil
 }
@@ -98,6 +107,37 @@ func HasGCPBuild(p *PackageJSON) bool {
 	return p != nil && p.Scripts.GCPBuild != ""
 }
 
+// RemoveModulesCache deletes node_modules/.cache in a reproducible build. Build tools such as
+// Babel, webpack and ESLint write their caches there, keyed by time and absolute paths, and they are
+// not needed at runtime.
+func RemoveModulesCache(ctx *gcp.Context) error {
+	if !ctx.ReproducibleBuild() {
+		return nil
+	}
+	return ctx.RemoveAll(filepath.Join(ctx.ApplicationRoot(), "node_modules", ".cache"))
+}
+
+// BuildCommandConfig returns the configuration used to run a user-provided build command in place
+// of the gcp-build script. The npm cache is kept in the build command cache layer.
+func BuildCommandConfig() buildcommand.Config {
+	return buildcommand.Config{
+		CacheEnv: map[string]string{"npm_config_cache": "npm"},
+	}
+}
+
+// RunGCPBuild runs the user-provided build command if set, otherwise the given build script with
+// the package manager, e.g. `pnpm run gcp-build`.
+func RunGCPBuild(ctx *gcp.Context, packageManager, script string) error {
+	if _, ok := buildcommand.Command(); ok {
+		_, err := buildcommand.Run(ctx, BuildCommandConfig())
+		return err
+	}
+	if _, err := ctx.Exec([]string{packageManager, "run", script}, gcp.WithUserAttribution); err != nil {
+		return GCPBuildError(err, packageManager+" run "+script)
+	}
+	return nil
+}
+
 // HasDevDependencies returns true if the given directory contains a package.json file that lists
 // more one or more devDependencies.
 func HasDevDependencies(p *PackageJSON) bool {
@@ -105,20 +145,36 @@ func HasDevDependencies(p *PackageJSON) bool {
 }
 
 // RequestedNodejsVersion returns any customer provided Node.js version constraint by inspecting the
-// environment and the package.json.
+// environment, the version files and the package.json, in this order of precedence:
+//  1. GOOGLE_NODEJS_VERSION
+//  2. GOOGLE_RUNTIME_VERSION
+//  3. .node-version
+//  4. .nvmrc
+//  5. engines.node in package.json
 func RequestedNodejsVersion(ctx *gcp.Context, pjs *PackageJSON) (string, error) {
-	if version := os.Getenv(EnvNodeVersion); version != "" {
-		ctx.Logf("Using runtime version from %s: %s", EnvNodeVersion, version)
-		return version, nil
+	if v := os.Getenv(EnvNodeVersion); v != "" {
+		ctx.Logf("Using runtime version from %s: %s", EnvNodeVersion, v)
+		return v, nil
 	}
-	if version := os.Getenv(env.RuntimeVersion); version != "" {
-		ctx.Logf("Using runtime version from %s: %s", env.RuntimeVersion, version)
-		return version, nil
+	if v := os.Getenv(env.RuntimeVersion); v != "" {
+		ctx.Logf("Using runtime version from %s: %s", env.RuntimeVersion, v)
+		return v, nil
+	}
+	v, err := versionFromFiles(ctx)
+	if err != nil {
+		return "", err
+	}
+	if v != "" {
+		return v, nil
 	}
 	if pjs == nil {
 		return "", nil
 	}
-	return pjs.Engines.Node, nil
+	constraint, err := version.Constraint(version.NPM, pjs.Engines.Node)
+	if err != nil {
+		return "", enginesHint(gcp.UserErrorf("parsing engines.node in package.json: %v", err).WithCode(buildererror.CodeInvalidRuntimeVersion))
+	}
+	return constraint, nil
 }
 
 // nodeVersion returns the installed version of Node.js.
@@ -154,30 +210,50 @@ func NodeEnv() string {
 	return nodeEnv
 }
 
+// PruneDevDependencies returns true if devDependencies should be removed from node_modules after
+// the build. GOOGLE_NODE_PRUNE_DEV takes precedence over the default of removing them when NODE_ENV
+// is production.
+func PruneDevDependencies(ctx *gcp.Context) (bool, error) {
+	if os.Getenv(env.NodePruneDev) == "" {
+		if nodeEnv := NodeEnv(); nodeEnv != EnvProduction {
+			ctx.Logf("Retaining devDependencies because NODE_ENV=%q", nodeEnv)
+			return false, nil
+		}
+		return true, nil
+	}
+	prune, err := env.IsPresentAndTrue(env.NodePruneDev)
+	if err != nil {
+		return false, gcp.UserErrorf("%v", err)
+	}
+	if !prune {
+		ctx.Logf("Retaining devDependencies because %s=%q", env.NodePruneDev, os.Getenv(env.NodePruneDev))
+	}
+	return prune, nil
+}
+
 // CheckOrClearCache checks whether cached dependencies exist and match. If they do not match, the
-// layer is cleared and the layer metadata is updated with the new cache key.
-func CheckOrClearCache(ctx *gcp.Context, l *libcnb.Layer, opts ...cache.Option) (bool, error) {
+// layer is cleared and the layer metadata is updated with the new cache key, then the layer is
+// restored from the remote cache if there is one. The format version must be bumped whenever the
+// layout of the cached layer changes.
+func CheckOrClearCache(ctx *gcp.Context, l *libcnb.Layer, version cache.FormatVersion, opts ...cache.Option) (bool, error) {
 	currentNodeVersion, err := nodeVersion(ctx)
 	if err != nil {
 		return false, err
 	}
 	opts = append(opts, cache.WithStrings(currentNodeVersion))
-	currentDependencyHash, err := cache.Hash(ctx, opts...)
+	hit, currentDependencyHash, err := cache.CheckCache(ctx, l, version, dependencyHashKey, opts...)
 	if err != nil {
 		return false, fmt.Errorf("computing dependency hash: %v", err)
 	}
 
 	// Perform install, skipping if the dependency hash matches existing metadata.
-	metaDependencyHash := ctx.GetMetadata(l, dependencyHashKey)
-	ctx.Debugf("Current dependency hash: %q", currentDependencyHash)
-	ctx.Debugf("  Cache dependency hash: %q", metaDependencyHash)
-	if currentDependencyHash == metaDependencyHash {
+	if hit {
 		ctx.CacheHit(l.Name)
 		ctx.Logf("Dependencies cache hit, skipping installation.")
 		return true, nil
 	}
 
-	if metaDependencyHash == "" {
+	if ctx.GetMetadata(l, dependencyHashKey) == "" {
 		ctx.Debugf("No metadata found from a previous build, skipping cache.")
 	}
 
@@ -190,7 +266,13 @@ func CheckOrClearCache(ctx *gcp.Context, l *libcnb.Layer, opts ...cache.Option)
 	ctx.SetMetadata(l, dependencyHashKey, currentDependencyHash)
 	ctx.SetMetadata(l, nodeVersionKey, currentNodeVersion)
 
-	return false, nil
+	return cache.RestoreRemote(ctx, l, currentDependencyHash)
+}
+
+// SaveRemoteCache uploads the dependencies layer checked by CheckOrClearCache to the remote cache
+// configured by GOOGLE_REMOTE_CACHE, if any, once it is populated.
+func SaveRemoteCache(ctx *gcp.Context, l *libcnb.Layer) error {
+	return cache.SaveRemote(ctx, l, ctx.GetMetadata(l, dependencyHashKey))
 }
 
 // SkipSyntaxCheck returns true if we should skip checking the user's function file for syntax errors
@@ -207,10 +289,19 @@ func SkipSyntaxCheck(ctx *gcp.Context, file string, pjs *PackageJSON) (bool, err
 	if version.Major() != 16 {
 		return false, nil
 	}
+	return IsESModule(file, pjs), nil
+}
+
+// IsESModule returns true if the given file is loaded as an ECMAScript module rather than as a
+// CommonJS module, see https://nodejs.org/api/packages.html#determining-module-system.
+func IsESModule(file string, pjs *PackageJSON) bool {
 	if strings.HasSuffix(file, ".mjs") {
-		return true, nil
+		return true
+	}
+	if strings.HasSuffix(file, ".cjs") {
+		return false
 	}
-	return (pjs != nil && pjs.Type == "module"), nil
+	return pjs != nil && pjs.Type == "module"
 }
 
 // IsNodeJS8Runtime returns true when the GOOGLE_RUNTIME is nodejs8. This will be
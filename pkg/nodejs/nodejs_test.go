@@ -16,12 +16,18 @@ package nodejs
 
 import (
 	"encoding/json"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/testdata"
+	"github.com/buildpacks/libcnb"
 )
 
 func TestReadPackageJSONIfExists(t *testing.T) {
@@ -61,6 +67,67 @@ func TestReadPackageJSONIfExistsDoesNotExist(t *testing.T) {
 	}
 }
 
+func TestReadPackageJSONIfExistsInvalid(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "package.json"), []byte(`{"name": `), 0644); err != nil {
+		t.Fatalf("writing package.json: %v", err)
+	}
+
+	_, err := ReadPackageJSONIfExists(dir)
+	if err == nil {
+		t.Fatal("ReadPackageJSONIfExists got no error, want error")
+	}
+	if got, want := buildererror.CodeOf(err), buildererror.CodeNodejsPackageJSONParseError; got != want {
+		t.Errorf("ReadPackageJSONIfExists error code = %q, want %q", got, want)
+	}
+}
+
+func TestIsESModule(t *testing.T) {
+	testCases := []struct {
+		name string
+		file string
+		pjs  *PackageJSON
+		want bool
+	}{
+		{
+			name: "mjs file",
+			file: "index.mjs",
+			want: true,
+		},
+		{
+			name: "module type",
+			file: "index.js",
+			pjs:  &PackageJSON{Type: "module"},
+			want: true,
+		},
+		{
+			name: "cjs file in module package",
+			file: "index.cjs",
+			pjs:  &PackageJSON{Type: "module"},
+			want: false,
+		},
+		{
+			name: "commonjs type",
+			file: "index.js",
+			pjs:  &PackageJSON{Type: "commonjs"},
+			want: false,
+		},
+		{
+			name: "without package.json",
+			file: "index.js",
+			want: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := IsESModule(tc.file, tc.pjs); got != tc.want {
+				t.Errorf("IsESModule(%q) = %t, want %t", tc.file, got, tc.want)
+			}
+		})
+	}
+}
+
 func TestSkipSyntaxCheck(t *testing.T) {
 	testCases := []struct {
 		name        string
@@ -204,12 +271,64 @@ func TestHasDevDependencies(t *testing.T) {
 	}
 }
 
+func TestPruneDevDependencies(t *testing.T) {
+	testCases := []struct {
+		name     string
+		nodeEnv  string
+		pruneDev string
+		want     bool
+		wantErr  bool
+	}{
+		{
+			name: "default",
+			want: true,
+		},
+		{
+			name:    "development",
+			nodeEnv: "development",
+			want:    false,
+		},
+		{
+			name:     "explicitly enabled in development",
+			nodeEnv:  "development",
+			pruneDev: "true",
+			want:     true,
+		},
+		{
+			name:     "explicitly disabled in production",
+			nodeEnv:  "production",
+			pruneDev: "false",
+			want:     false,
+		},
+		{
+			name:     "invalid value",
+			pruneDev: "sometimes",
+			wantErr:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("NODE_ENV", tc.nodeEnv)
+			t.Setenv(env.NodePruneDev, tc.pruneDev)
+
+			got, err := PruneDevDependencies(gcp.NewContext())
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("PruneDevDependencies() got error: %v, want error? %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("PruneDevDependencies() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
 func TestRequestedNodejsVersion(t *testing.T) {
 	testCases := []struct {
 		name        string
 		nodeEnv     string
 		runtimeEnv  string
 		packageJSON string
+		files       map[string]string
 		want        string
 		wantErr     bool
 	}{
@@ -238,18 +357,71 @@ func TestRequestedNodejsVersion(t *testing.T) {
 			packageJSON: `{"engines": {"node": "2.2.2"}}`,
 			want:        "2.2.2",
 		},
+		{
+			name:        "engines.nodejs npm range",
+			packageJSON: `{"engines": {"node": ">= 18 <21"}}`,
+			want:        ">=18, <21.0.0",
+		},
+		{
+			name:        "invalid engines.nodejs",
+			packageJSON: `{"engines": {"node": "lts"}}`,
+			wantErr:     true,
+		},
 		{
 			name:        "GOOGLE_RUNTIME_VERSION and engines.nodejs set",
 			packageJSON: `{"engines": {"node": "2.2.2"}}`,
 			runtimeEnv:  "3.3.3",
 			want:        "3.3.3",
 		},
+		{
+			name:  ".nvmrc",
+			files: map[string]string{".nvmrc": "v18.17.0\n"},
+			want:  "18.17.0",
+		},
+		{
+			name:  ".node-version",
+			files: map[string]string{".node-version": "20"},
+			want:  "20",
+		},
+		{
+			name:  ".node-version takes precedence over .nvmrc",
+			files: map[string]string{".node-version": "20", ".nvmrc": "18"},
+			want:  "20",
+		},
+		{
+			name:        ".nvmrc takes precedence over engines.node",
+			packageJSON: `{"engines": {"node": ">=16"}}`,
+			files:       map[string]string{".nvmrc": "lts/hydrogen"},
+			want:        "18",
+		},
+		{
+			name:        ".nvmrc latest alias falls back to engines.node",
+			packageJSON: `{"engines": {"node": ">=16"}}`,
+			files:       map[string]string{".nvmrc": "node"},
+			want:        ">=16",
+		},
+		{
+			name:       "GOOGLE_RUNTIME_VERSION takes precedence over .nvmrc",
+			runtimeEnv: "3.3.3",
+			files:      map[string]string{".nvmrc": "18"},
+			want:       "3.3.3",
+		},
+		{
+			name:    "empty .nvmrc",
+			files:   map[string]string{".nvmrc": "\n"},
+			wantErr: true,
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 
 			dir := t.TempDir()
+			for name, content := range tc.files {
+				if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatalf("writing %s: %v", name, err)
+				}
+			}
 			var pjs *PackageJSON
 			if tc.packageJSON != "" {
 				if err := json.Unmarshal([]byte(tc.packageJSON), &pjs); err != nil {
@@ -263,7 +435,7 @@ func TestRequestedNodejsVersion(t *testing.T) {
 				t.Setenv("GOOGLE_RUNTIME_VERSION", tc.runtimeEnv)
 			}
 
-			ctx := gcp.NewContext()
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
 			got, err := RequestedNodejsVersion(ctx, pjs)
 			if tc.wantErr == (err == nil) {
 				t.Errorf("RequestedNodejsVersion(ctx, %q) got error: %v, want err? %t", dir, err, tc.wantErr)
@@ -320,3 +492,61 @@ func setGoogleRuntime(t *testing.T, value string) {
 		t.Errorf("Error setting environment variable %q: %v", googleRuntimeEnv, err)
 	}
 }
+
+func TestRunGCPBuild(t *testing.T) {
+	testCases := []struct {
+		name         string
+		buildCommand string
+		fail         bool
+		want         string
+		wantErr      bool
+	}{
+		{
+			name: "build script",
+			want: "pnpm run gcp-build",
+		},
+		{
+			name:    "build script fails",
+			fail:    true,
+			wantErr: true,
+		},
+		{
+			name:         "build command",
+			buildCommand: "echo custom >> log",
+			want:         "custom",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			app := t.TempDir()
+			log := filepath.Join(app, "log")
+			// Stub the package manager with a script that records its arguments.
+			bin := t.TempDir()
+			script := "#!/bin/sh\necho \"pnpm $@\" >> " + log + "\n"
+			if tc.fail {
+				script += "exit 1\n"
+			}
+			if err := ioutil.WriteFile(filepath.Join(bin, "pnpm"), []byte(script), 0755); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+			t.Setenv(env.BuildCommand, tc.buildCommand)
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(app), gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}))
+
+			err := RunGCPBuild(ctx, "pnpm", "gcp-build")
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("RunGCPBuild() got error: %v, want error? %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			got, err := ioutil.ReadFile(log)
+			if err != nil {
+				t.Fatalf("reading %s: %v", log, err)
+			}
+			if strings.TrimSpace(string(got)) != tc.want {
+				t.Errorf("RunGCPBuild() ran %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
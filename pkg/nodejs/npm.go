@@ -15,6 +15,9 @@
 package nodejs
 
 import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
 	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
@@ -34,6 +37,8 @@ var (
 	minPruneVersion = semver.MustParse("5.7.0")
 	// minNpmCIVersion is the first npm version that suports the ci command.
 	minNpmCIVersion = semver.MustParse("6.14.0")
+	// minOmitVersion is the first npm version that supports the --omit flag.
+	minOmitVersion = semver.MustParse("7.0.0")
 )
 
 // RequestedNPMVersion returns any customer provided NPM version constraint configured in the
@@ -124,3 +129,86 @@ func SupportsNPMPrune(ctx *gcp.Context) (bool, error) {
 	}
 	return !version.LessThan(minPruneVersion), nil
 }
+
+// NPMPruneCommands returns the commands that remove the devDependencies from node_modules, or nil
+// if the version of npm installed in the system does not support the prune command. The remaining
+// dependencies are also deduplicated if dedupe is true and npm supports it.
+func NPMPruneCommands(ctx *gcp.Context, dedupe bool) ([][]string, error) {
+	npmVer, err := npmVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	version, err := semver.NewVersion(npmVer)
+	if err != nil {
+		return nil, gcp.InternalErrorf("parsing npm version: %v", err)
+	}
+	if version.LessThan(minPruneVersion) {
+		return nil, nil
+	}
+	// --production is deprecated in favor of --omit=dev since npm 7.
+	if version.LessThan(minOmitVersion) {
+		return [][]string{{"npm", "prune", "--production"}}, nil
+	}
+	cmds := [][]string{{"npm", "prune", "--omit=dev"}}
+	if dedupe {
+		cmds = append(cmds, []string{"npm", "dedupe", "--omit=dev", "--no-audit", "--no-fund"})
+	}
+	return cmds, nil
+}
+
+// npmLockfile is the subset of package-lock.json and npm-shrinkwrap.json used to list the
+// installed dependencies.
+type npmLockfile struct {
+	// Packages is set by lockfile version 2 and 3, keyed by the path of the package, e.g.
+	// "node_modules/express".
+	Packages map[string]npmLockPackage `json:"packages"`
+	// Dependencies is set by lockfile version 1 and 2, keyed by the name of the package.
+	Dependencies map[string]npmLockPackage `json:"dependencies"`
+}
+
+type npmLockPackage struct {
+	Version      string                    `json:"version"`
+	Dev          bool                      `json:"dev"`
+	Link         bool                      `json:"link"`
+	Dependencies map[string]npmLockPackage `json:"dependencies"`
+}
+
+// NPMLockSBOMEntries returns the SBOM entries of the packages listed in the given npm lockfile.
+// devDependencies are only included if includeDev is true.
+func NPMLockSBOMEntries(path string, includeDev bool) ([]gcp.SBOMEntry, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, gcp.InternalErrorf("reading %s: %v", filepath.Base(path), err)
+	}
+	var lock npmLockfile
+	if err := json.Unmarshal(raw, &lock); err != nil {
+		return nil, gcp.UserErrorf("parsing %s: %v", filepath.Base(path), err)
+	}
+	var entries []gcp.SBOMEntry
+	if len(lock.Packages) > 0 {
+		for p, pkg := range lock.Packages {
+			i := strings.LastIndex(p, "node_modules/")
+			// The root package has an empty key and workspace packages are not under node_modules.
+			if i < 0 || pkg.Link || pkg.Version == "" || (pkg.Dev && !includeDev) {
+				continue
+			}
+			entries = append(entries, gcp.SBOMEntry{Name: p[i+len("node_modules/"):], Version: pkg.Version, PURLType: "npm"})
+		}
+		return entries, nil
+	}
+	var walk func(deps map[string]npmLockPackage)
+	walk = func(deps map[string]npmLockPackage) {
+		for name, pkg := range deps {
+			if pkg.Dev && !includeDev {
+				continue
+			}
+			// Version 1 lockfiles use the target of links and git dependencies as the version.
+			if !strings.Contains(pkg.Version, ":") {
+				entries = append(entries, gcp.SBOMEntry{Name: name, Version: pkg.Version, PURLType: "npm"})
+			}
+			walk(pkg.Dependencies)
+		}
+	}
+	walk(lock.Dependencies)
+	return entries, nil
+}
@@ -16,10 +16,14 @@ package nodejs
 
 import (
 	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
 	"testing"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestRequestedNPMVersion(t *testing.T) {
@@ -152,3 +156,133 @@ func TestSupportsNPMPrune(t *testing.T) {
 		})
 	}
 }
+
+func TestNPMPruneCommands(t *testing.T) {
+	testCases := []struct {
+		name    string
+		version string
+		dedupe  bool
+		want    [][]string
+	}{
+		{
+			name:    "npm 9",
+			version: "9.6.7",
+			want:    [][]string{{"npm", "prune", "--omit=dev"}},
+		},
+		{
+			name:    "npm 9 with dedupe",
+			version: "9.6.7",
+			dedupe:  true,
+			want:    [][]string{{"npm", "prune", "--omit=dev"}, {"npm", "dedupe", "--omit=dev", "--no-audit", "--no-fund"}},
+		},
+		{
+			name:    "npm 6",
+			version: "6.14.18",
+			dedupe:  true,
+			want:    [][]string{{"npm", "prune", "--production"}},
+		},
+		{
+			name:    "prune not supported",
+			version: "5.0.1",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func(fn func(*gcpbuildpack.Context) (string, error)) { npmVersion = fn }(npmVersion)
+			npmVersion = func(*gcpbuildpack.Context) (string, error) { return tc.version, nil }
+
+			got, err := NPMPruneCommands(nil, tc.dedupe)
+			if err != nil {
+				t.Fatalf("NPMPruneCommands(nil, %v) got error: %v", tc.dedupe, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("NPMPruneCommands(nil, %v) mismatch (-want +got):\n%s", tc.dedupe, diff)
+			}
+		})
+	}
+}
+
+func TestNPMLockSBOMEntries(t *testing.T) {
+	testCases := []struct {
+		name       string
+		lockfile   string
+		includeDev bool
+		want       []gcpbuildpack.SBOMEntry
+	}{
+		{
+			name: "lockfile version 3",
+			lockfile: `{
+  "lockfileVersion": 3,
+  "packages": {
+    "": {"name": "app", "version": "1.0.0"},
+    "node_modules/@google-cloud/storage": {"version": "6.9.0"},
+    "node_modules/express": {"version": "4.18.2"},
+    "node_modules/express/node_modules/debug": {"version": "2.6.9"},
+    "node_modules/mocha": {"version": "10.2.0", "dev": true},
+    "node_modules/lib": {"resolved": "packages/lib", "link": true},
+    "packages/lib": {"version": "0.0.1"}
+  }
+}`,
+			want: []gcpbuildpack.SBOMEntry{
+				{Name: "@google-cloud/storage", Version: "6.9.0", PURLType: "npm"},
+				{Name: "debug", Version: "2.6.9", PURLType: "npm"},
+				{Name: "express", Version: "4.18.2", PURLType: "npm"},
+			},
+		},
+		{
+			name: "include devDependencies",
+			lockfile: `{
+  "lockfileVersion": 2,
+  "packages": {
+    "node_modules/express": {"version": "4.18.2"},
+    "node_modules/mocha": {"version": "10.2.0", "dev": true}
+  },
+  "dependencies": {
+    "express": {"version": "4.18.2"},
+    "mocha": {"version": "10.2.0", "dev": true}
+  }
+}`,
+			includeDev: true,
+			want: []gcpbuildpack.SBOMEntry{
+				{Name: "express", Version: "4.18.2", PURLType: "npm"},
+				{Name: "mocha", Version: "10.2.0", PURLType: "npm"},
+			},
+		},
+		{
+			name: "lockfile version 1",
+			lockfile: `{
+  "lockfileVersion": 1,
+  "dependencies": {
+    "express": {
+      "version": "4.18.2",
+      "dependencies": {"debug": {"version": "2.6.9"}}
+    },
+    "lib": {"version": "file:packages/lib"},
+    "mocha": {"version": "10.2.0", "dev": true}
+  }
+}`,
+			want: []gcpbuildpack.SBOMEntry{
+				{Name: "debug", Version: "2.6.9", PURLType: "npm"},
+				{Name: "express", Version: "4.18.2", PURLType: "npm"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), PackageLock)
+			if err := os.WriteFile(path, []byte(tc.lockfile), 0644); err != nil {
+				t.Fatalf("writing %s: %v", path, err)
+			}
+
+			got, err := NPMLockSBOMEntries(path, tc.includeDev)
+			if err != nil {
+				t.Fatalf("NPMLockSBOMEntries() got error: %v", err)
+			}
+			sort.Slice(got, func(i, j int) bool { return got[i].Name < got[j].Name })
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("NPMLockSBOMEntries() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
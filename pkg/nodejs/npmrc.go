@@ -0,0 +1,167 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	defaultNPMRegistry = "https://registry.npmjs.org/"
+	npmrcName          = ".npmrc"
+	yarnrcYAMLName     = ".yarnrc.yml"
+)
+
+// privateRegistry holds the registry settings specified by GOOGLE_NPM_REGISTRY and
+// GOOGLE_NPM_AUTH_TOKEN.
+type privateRegistry struct {
+	// url is the registry URL with a trailing slash, it is empty if the default registry is used.
+	url string
+	// authenticated is true if an auth token is available.
+	authenticated bool
+}
+
+// readPrivateRegistry returns the registry settings, or nil if none are specified.
+func readPrivateRegistry(ctx *gcp.Context) (*privateRegistry, error) {
+	_, secret := ctx.Secret(env.NPMAuthToken)
+	r := &privateRegistry{authenticated: secret || os.Getenv(env.NPMAuthToken) != ""}
+	if v := strings.TrimSpace(os.Getenv(env.NPMRegistry)); v != "" {
+		u, err := url.Parse(v)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return nil, gcp.UserErrorf("invalid %s %q, must be an http(s) URL", env.NPMRegistry, v)
+		}
+		r.url = strings.TrimSuffix(v, "/") + "/"
+	}
+	if r.url == "" && !r.authenticated {
+		return nil, nil
+	}
+	return r, nil
+}
+
+// authKey returns the "nerf-darted" registry URL, without scheme, that prefixes the credentials of
+// the registry in .npmrc.
+func (r *privateRegistry) authKey() string {
+	u := r.url
+	if u == "" {
+		u = defaultNPMRegistry
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(u, "https:"), "http:")
+}
+
+// displayURL returns the registry URL for logging.
+func (r *privateRegistry) displayURL() string {
+	if r.url == "" {
+		return "(default)"
+	}
+	return r.url
+}
+
+// tokenRef is the reference to the auth token that npm, pnpm and Yarn expand from the environment,
+// the token itself is never written to disk.
+func tokenRef() string {
+	return "${" + env.NPMAuthToken + "}"
+}
+
+// ConfigureNPMRegistry adds the registry and auth token specified by GOOGLE_NPM_REGISTRY and
+// GOOGLE_NPM_AUTH_TOKEN to the .npmrc file in the user's HOME directory, which is read by npm,
+// pnpm and Yarn 1 but is not part of the application image. Settings of a project-level .npmrc
+// take precedence.
+func ConfigureNPMRegistry(ctx *gcp.Context) error {
+	r, err := readPrivateRegistry(ctx)
+	if err != nil || r == nil {
+		return err
+	}
+	var lines []string
+	if r.url != "" {
+		lines = append(lines, "registry="+r.url)
+	}
+	if r.authenticated {
+		lines = append(lines, fmt.Sprintf("%s:_authToken=%s", r.authKey(), tokenRef()))
+	}
+
+	userConfig := filepath.Join(ctx.HomeDir(), npmrcName)
+	content, err := readFileIfExists(ctx, userConfig)
+	if err != nil {
+		return err
+	}
+	if len(content) > 0 && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	content += strings.Join(lines, "\n") + "\n"
+	ctx.Logf("Configuring npm registry %s (authenticated: %t)", r.displayURL(), r.authenticated)
+	return ctx.WriteFile(userConfig, []byte(content), 0600)
+}
+
+// ConfigureYarnRegistry adds the registry and auth token specified by GOOGLE_NPM_REGISTRY and
+// GOOGLE_NPM_AUTH_TOKEN to the .yarnrc.yml file in the user's HOME directory, which is read by
+// Yarn 2+ but is not part of the application image.
+func ConfigureYarnRegistry(ctx *gcp.Context) error {
+	r, err := readPrivateRegistry(ctx)
+	if err != nil || r == nil {
+		return err
+	}
+	userConfig := filepath.Join(ctx.HomeDir(), yarnrcYAMLName)
+	content, err := readFileIfExists(ctx, userConfig)
+	if err != nil {
+		return err
+	}
+	cfg := yaml.MapSlice{}
+	if err := yaml.Unmarshal([]byte(content), &cfg); err != nil {
+		return gcp.InternalErrorf("parsing %s: %v", userConfig, err)
+	}
+	if r.url != "" {
+		cfg = setYAMLKey(cfg, "npmRegistryServer", r.url)
+	}
+	if r.authenticated {
+		cfg = setYAMLKey(cfg, "npmAlwaysAuth", true)
+		cfg = setYAMLKey(cfg, "npmAuthToken", tokenRef())
+	}
+	out, err := yaml.Marshal(cfg)
+	if err != nil {
+		return gcp.InternalErrorf("marshalling %s: %v", userConfig, err)
+	}
+	ctx.Logf("Configuring Yarn registry %s (authenticated: %t)", r.displayURL(), r.authenticated)
+	return ctx.WriteFile(userConfig, out, 0600)
+}
+
+func setYAMLKey(cfg yaml.MapSlice, key string, value interface{}) yaml.MapSlice {
+	for i := range cfg {
+		if cfg[i].Key == key {
+			cfg[i].Value = value
+			return cfg
+		}
+	}
+	return append(cfg, yaml.MapItem{Key: key, Value: value})
+}
+
+func readFileIfExists(ctx *gcp.Context, path string) (string, error) {
+	exists, err := ctx.FileExists(path)
+	if err != nil || !exists {
+		return "", err
+	}
+	b, err := ctx.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,150 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestConfigureNPMRegistry(t *testing.T) {
+	testCases := []struct {
+		name     string
+		registry string
+		token    string
+		existing string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name: "not configured",
+		},
+		{
+			name:     "registry",
+			registry: "https://us-npm.pkg.dev/my-project/my-repo",
+			want:     "registry=https://us-npm.pkg.dev/my-project/my-repo/\n",
+		},
+		{
+			name:     "registry and token",
+			registry: "https://us-npm.pkg.dev/my-project/my-repo/",
+			token:    "npm-secret",
+			want:     "registry=https://us-npm.pkg.dev/my-project/my-repo/\n//us-npm.pkg.dev/my-project/my-repo/:_authToken=${GOOGLE_NPM_AUTH_TOKEN}\n",
+		},
+		{
+			name:  "token for the default registry",
+			token: "npm-secret",
+			want:  "//registry.npmjs.org/:_authToken=${GOOGLE_NPM_AUTH_TOKEN}\n",
+		},
+		{
+			name:     "appends to existing config",
+			registry: "https://npm.example.com",
+			existing: "//us-npm.pkg.dev/p/r/:_authToken=ar-token",
+			want:     "//us-npm.pkg.dev/p/r/:_authToken=ar-token\nregistry=https://npm.example.com/\n",
+		},
+		{
+			name:     "invalid registry",
+			registry: "npm.example.com",
+			wantErr:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			home := registryTestHome(t, tc.registry, tc.token)
+			if tc.existing != "" {
+				writeTestFile(t, filepath.Join(home, ".npmrc"), tc.existing)
+			}
+
+			err := ConfigureNPMRegistry(gcp.NewContext())
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("ConfigureNPMRegistry() got error: %v, want error? %v", err, tc.wantErr)
+			}
+			if got := readTestFile(t, filepath.Join(home, ".npmrc")); got != tc.want {
+				t.Errorf("ConfigureNPMRegistry() wrote %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestConfigureYarnRegistry(t *testing.T) {
+	testCases := []struct {
+		name     string
+		registry string
+		token    string
+		existing string
+		want     string
+	}{
+		{
+			name: "not configured",
+		},
+		{
+			name:     "registry and token",
+			registry: "https://npm.example.com",
+			token:    "npm-secret",
+			want:     "npmRegistryServer: https://npm.example.com/\nnpmAlwaysAuth: true\nnpmAuthToken: ${GOOGLE_NPM_AUTH_TOKEN}\n",
+		},
+		{
+			name:     "merges with existing config",
+			registry: "https://npm.example.com",
+			existing: "npmRegistries:\n  //us-npm.pkg.dev/p/r/:\n    npmAuthToken: ar-token\nnpmRegistryServer: https://old.example.com\n",
+			want:     "npmRegistries:\n  //us-npm.pkg.dev/p/r/:\n    npmAuthToken: ar-token\nnpmRegistryServer: https://npm.example.com/\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			home := registryTestHome(t, tc.registry, tc.token)
+			if tc.existing != "" {
+				writeTestFile(t, filepath.Join(home, ".yarnrc.yml"), tc.existing)
+			}
+
+			if err := ConfigureYarnRegistry(gcp.NewContext()); err != nil {
+				t.Fatalf("ConfigureYarnRegistry() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, readTestFile(t, filepath.Join(home, ".yarnrc.yml"))); diff != "" {
+				t.Errorf("ConfigureYarnRegistry() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func registryTestHome(t *testing.T, registry, token string) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(env.NPMRegistry, registry)
+	t.Setenv(env.NPMAuthToken, token)
+	return home
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("writing %s: %v", path, err)
+	}
+}
+
+// readTestFile returns the content of the file, or "" if it does not exist.
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
@@ -0,0 +1,116 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+	"github.com/Masterminds/semver"
+)
+
+const (
+	// PNPMLock is the name of the pnpm lock file.
+	PNPMLock = "pnpm-lock.yaml"
+)
+
+// detectPNPMVersion determines the version of pnpm that should be installed in a Node.js project
+// by examining the "engines.pnpm" constraint specified in package.json and comparing it against all
+// published versions in the NPM registry. If the package.json does not include "engines.pnpm" it
+// returns the latest stable version available.
+func detectPNPMVersion(pjs *PackageJSON) (string, error) {
+	if pjs == nil || pjs.Engines.PNPM == "" {
+		version, err := latestPackageVersion("pnpm")
+		if err != nil {
+			return "", gcp.InternalErrorf("fetching available pnpm versions: %v", err)
+		}
+		return version, nil
+	}
+
+	requested := pjs.Engines.PNPM
+	version, err := resolvePackageVersion("pnpm", requested)
+	if err != nil {
+		return "", gcp.UserErrorf("finding pnpm version that matched %q: %v", requested, err)
+	}
+	return version, nil
+}
+
+// InstallPNPMLayer installs pnpm in the given layer if it is not already cached and returns the
+// installed version. pnpm is installed with corepack if its version is pinned in the
+// "packageManager" field of package.json.
+func InstallPNPMLayer(ctx *gcp.Context, pnpmLayer *libcnb.Layer, pjs *PackageJSON) (string, error) {
+	if corepack, err := installWithCorepack(ctx, pnpmLayer, pjs, "pnpm"); err != nil {
+		return "", err
+	} else if corepack {
+		// The packageManager field may contain a version range, e.g. "pnpm@9".
+		result, err := ctx.Exec([]string{"pnpm", "--version"}, gcp.WithUserAttribution)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(result.Stdout), nil
+	}
+	layerName := pnpmLayer.Name
+	version, err := detectPNPMVersion(pjs)
+	if err != nil {
+		return "", err
+	}
+
+	// Check the metadata in the cache layer to determine if we need to proceed.
+	metaVersion := ctx.GetMetadata(pnpmLayer, versionKey)
+	if version == metaVersion {
+		ctx.CacheHit(layerName)
+		ctx.Logf("pnpm cache hit: %q, %q, skipping installation.", version, metaVersion)
+	} else {
+		ctx.CacheMiss(layerName)
+		if err := ctx.ClearLayer(pnpmLayer); err != nil {
+			return "", fmt.Errorf("clearing layer %q: %w", layerName, err)
+		}
+		ctx.Logf("Installing pnpm v%s", version)
+		prefix := fmt.Sprintf("--prefix=%s", pnpmLayer.Path)
+		pkg := fmt.Sprintf("pnpm@%s", version)
+		if _, err := ctx.Exec([]string{"npm", "install", "--global", "--quiet", prefix, pkg}, gcp.WithUserAttribution); err != nil {
+			return "", err
+		}
+	}
+
+	// Store layer flags and metadata.
+	ctx.SetMetadata(pnpmLayer, versionKey, version)
+	// We need to update the path here to ensure the version we just installed take precendence over
+	// anything pre-installed in the base image.
+	if err := ctx.Setenv("PATH", filepath.Join(pnpmLayer.Path, "bin")+":"+os.Getenv("PATH")); err != nil {
+		return "", err
+	}
+	ctx.AddBOMEntry(libcnb.BOMEntry{
+		Name:     layerName,
+		Metadata: map[string]interface{}{"version": version},
+		Launch:   pnpmLayer.Launch,
+		Build:    pnpmLayer.Build,
+	})
+	return version, nil
+}
+
+// PNPMMajorVersion returns the major version of the given pnpm version, e.g. "8" for "8.6.2". Each
+// major version of pnpm uses its own store layout.
+func PNPMMajorVersion(version string) (string, error) {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return "", gcp.InternalErrorf("parsing pnpm version %q: %v", version, err)
+	}
+	return fmt.Sprintf("%d", v.Major()), nil
+}
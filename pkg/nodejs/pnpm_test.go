@@ -0,0 +1,114 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDetectPNPMVersion(t *testing.T) {
+	registry := `{
+		"name": "pnpm",
+		"dist-tags": {
+			"latest": "8.6.2",
+			"next-8": "8.7.0-0"
+		},
+		"versions": {
+			"7.33.1": {},
+			"8.5.1": {},
+			"8.6.2": {},
+			"8.7.0-0": {}
+		}
+	}`
+	testCases := []struct {
+		name       string
+		pjs        *PackageJSON
+		httpStatus int
+		want       string
+		wantError  bool
+	}{
+		{
+			name: "no package.json",
+			want: "8.6.2",
+		},
+		{
+			name: "no engines.pnpm",
+			pjs:  &PackageJSON{},
+			want: "8.6.2",
+		},
+		{
+			name: "exact version",
+			pjs:  &PackageJSON{Engines: packageEnginesJSON{PNPM: "7.33.1"}},
+			want: "7.33.1",
+		},
+		{
+			name: "version range",
+			pjs:  &PackageJSON{Engines: packageEnginesJSON{PNPM: "~8.5.0"}},
+			want: "8.5.1",
+		},
+		{
+			name:      "unsatisfiable range",
+			pjs:       &PackageJSON{Engines: packageEnginesJSON{PNPM: "^9.0.0"}},
+			wantError: true,
+		},
+		{
+			name:       "registry error",
+			httpStatus: http.StatusNotFound,
+			wantError:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stubNPMRegistry(t, registry, tc.httpStatus)
+
+			got, err := detectPNPMVersion(tc.pjs)
+
+			if tc.wantError == (err == nil) {
+				t.Fatalf("detectPNPMVersion(%v) got error: %v, want error? %v", tc.pjs, err, tc.wantError)
+			}
+			if got != tc.want {
+				t.Errorf("detectPNPMVersion(%v) = %q, want %q", tc.pjs, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPNPMMajorVersion(t *testing.T) {
+	testCases := []struct {
+		version   string
+		want      string
+		wantError bool
+	}{
+		{version: "8.6.2", want: "8"},
+		{version: "7.33.1", want: "7"},
+		{version: "9.0.0-rc.0", want: "9"},
+		{version: "latest", wantError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.version, func(t *testing.T) {
+			got, err := PNPMMajorVersion(tc.version)
+
+			if tc.wantError == (err == nil) {
+				t.Fatalf("PNPMMajorVersion(%q) got error: %v, want error? %v", tc.version, err, tc.wantError)
+			}
+			if got != tc.want {
+				t.Errorf("PNPMMajorVersion(%q) = %q, want %q", tc.version, got, tc.want)
+			}
+		})
+	}
+}
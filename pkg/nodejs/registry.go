@@ -59,7 +59,7 @@ func latestPackageVersion(pkg string) (string, error) {
 }
 
 // resolvePackageVersion returns the newest available version of an NPM package that satisfies the
-// provided version constraint.
+// provided npm version range.
 func resolvePackageVersion(pkg, verConstraint string) (string, error) {
 	if version.IsExactSemver(verConstraint) {
 		return verConstraint, nil
@@ -89,7 +89,7 @@ func resolvePackageVersion(pkg, verConstraint string) (string, error) {
 		versions = append(versions, v)
 	}
 
-	return version.ResolveVersion(verConstraint, versions)
+	return version.Resolve(version.NPM, verConstraint, versions)
 }
 
 // fetchYarnTags fetches metadata available Yarn versions >=2.0.0.
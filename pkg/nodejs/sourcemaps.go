@@ -0,0 +1,92 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const enableSourceMaps = "--enable-source-maps"
+
+var (
+	// sourceMapExts are the extensions of the source maps that compilers write next to JavaScript files.
+	sourceMapExts = []string{".js.map", ".mjs.map", ".cjs.map"}
+
+	errSourceMapFound = errors.New("source map found")
+)
+
+// ConfigureSourceMaps adds --enable-source-maps to NODE_OPTIONS in the launch environment of the
+// given layer, so that stack traces reference the original sources, e.g. TypeScript, if the
+// application contains source maps. GOOGLE_NODEJS_SOURCE_MAPS takes precedence over the detection.
+func ConfigureSourceMaps(ctx *gcp.Context, l *libcnb.Layer) error {
+	if v := os.Getenv(env.NodeSourceMaps); v != "" {
+		enabled, err := env.IsPresentAndTrue(env.NodeSourceMaps)
+		if err != nil {
+			return gcp.UserErrorf("%v", err)
+		}
+		if !enabled {
+			ctx.Debugf("Source maps disabled by %s=%q.", env.NodeSourceMaps, v)
+			return nil
+		}
+	} else {
+		found, err := hasSourceMaps(ctx.ApplicationRoot())
+		if err != nil {
+			return err
+		}
+		if !found {
+			return nil
+		}
+	}
+	ctx.Logf("Enabling source maps for stack traces with NODE_OPTIONS=%s.", enableSourceMaps)
+	l.LaunchEnvironment.Append("NODE_OPTIONS", " ", enableSourceMaps)
+	return nil
+}
+
+// hasSourceMaps returns true if the given directory contains a source map of a JavaScript file
+// outside of node_modules, whose packages ship their own source maps.
+func hasSourceMaps(dir string) (bool, error) {
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if name := d.Name(); name == "node_modules" || name == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		for _, ext := range sourceMapExts {
+			if strings.HasSuffix(d.Name(), ext) {
+				return errSourceMapFound
+			}
+		}
+		return nil
+	})
+	if err == errSourceMapFound {
+		return true, nil
+	}
+	if err != nil {
+		return false, gcp.InternalErrorf("searching for source maps in %s: %v", dir, err)
+	}
+	return false, nil
+}
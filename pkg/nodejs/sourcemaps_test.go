@@ -0,0 +1,96 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestConfigureSourceMaps(t *testing.T) {
+	testCases := []struct {
+		name       string
+		files      []string
+		sourceMaps string
+		want       bool
+		wantErr    bool
+	}{
+		{
+			name:  "compiled typescript",
+			files: []string{"src/index.ts", "dist/index.js", "dist/index.js.map"},
+			want:  true,
+		},
+		{
+			name:  "es module source map",
+			files: []string{"build/server.mjs", "build/server.mjs.map"},
+			want:  true,
+		},
+		{
+			name:  "no source maps",
+			files: []string{"index.js", "styles.css.map"},
+		},
+		{
+			name:  "source maps in node_modules",
+			files: []string{"index.js", "node_modules/express/index.js.map"},
+		},
+		{
+			name:       "disabled",
+			files:      []string{"dist/index.js", "dist/index.js.map"},
+			sourceMaps: "false",
+		},
+		{
+			name:       "enabled without source maps",
+			files:      []string{"index.js"},
+			sourceMaps: "true",
+			want:       true,
+		},
+		{
+			name:       "invalid value",
+			sourceMaps: "sometimes",
+			wantErr:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(env.NodeSourceMaps, tc.sourceMaps)
+			root := t.TempDir()
+			for _, f := range tc.files {
+				path := filepath.Join(root, f)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("creating %s: %v", filepath.Dir(path), err)
+				}
+				if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+					t.Fatalf("writing %s: %v", path, err)
+				}
+			}
+			l := &libcnb.Layer{LaunchEnvironment: libcnb.Environment{}}
+
+			err := ConfigureSourceMaps(gcp.NewContext(gcp.WithApplicationRoot(root)), l)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ConfigureSourceMaps() got error: %v, want error: %t", err, tc.wantErr)
+			}
+			got := l.LaunchEnvironment["NODE_OPTIONS.append"] == enableSourceMaps
+			if got != tc.want {
+				t.Errorf("ConfigureSourceMaps() launch environment = %v, want %s: %t", l.LaunchEnvironment, enableSourceMaps, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// nodeVersionFiles are the files read by version managers such as nvm, fnm and nodenv to select
+// the Node.js version of an application, in order of precedence.
+var nodeVersionFiles = []string{".node-version", ".nvmrc"}
+
+// ltsCodenames maps the codenames of the Node.js long-term support releases to their major
+// versions, for `lts/<codename>` aliases in .nvmrc files.
+var ltsCodenames = map[string]string{
+	"argon":    "4",
+	"boron":    "6",
+	"carbon":   "8",
+	"dubnium":  "10",
+	"erbium":   "12",
+	"fermium":  "14",
+	"gallium":  "16",
+	"hydrogen": "18",
+	"iron":     "20",
+}
+
+// latestLTS is the major version of the most recent long-term support release, used for the
+// `lts/*` alias.
+const latestLTS = "20"
+
+// versionFromFiles returns the Node.js version from the first version file present in the
+// application directory, or an empty string if there is none.
+func versionFromFiles(ctx *gcp.Context) (string, error) {
+	for _, name := range nodeVersionFiles {
+		path := filepath.Join(ctx.ApplicationRoot(), name)
+		exists, err := ctx.FileExists(path)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			continue
+		}
+		raw, err := ctx.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		version, err := parseVersionFile(string(raw))
+		if err != nil {
+			return "", gcp.UserErrorf("parsing %s: %v", name, err)
+		}
+		if version != "" {
+			ctx.Logf("Using runtime version from %s: %s", name, version)
+		}
+		return version, nil
+	}
+	return "", nil
+}
+
+// parseVersionFile returns the version constraint in the content of a .node-version or .nvmrc
+// file. The `node`, `stable` and `latest` aliases select the latest version and return an empty
+// string.
+func parseVersionFile(content string) (string, error) {
+	var version string
+	for _, line := range strings.Split(content, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			version = line
+			break
+		}
+	}
+	lower := strings.ToLower(version)
+	switch {
+	case version == "":
+		return "", fmt.Errorf("the file does not specify a version")
+	case lower == "node" || lower == "stable" || lower == "latest" || lower == "current":
+		return "", nil
+	case lower == "lts/*":
+		return latestLTS, nil
+	case strings.HasPrefix(lower, "lts/"):
+		major, ok := ltsCodenames[strings.TrimPrefix(lower, "lts/")]
+		if !ok {
+			return "", fmt.Errorf("unknown LTS alias %q", version)
+		}
+		return major, nil
+	}
+	return strings.TrimPrefix(lower, "v"), nil
+}
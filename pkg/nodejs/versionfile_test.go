@@ -0,0 +1,88 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import "testing"
+
+func TestParseVersionFile(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "exact version",
+			content: "18.17.0",
+			want:    "18.17.0",
+		},
+		{
+			name:    "v prefix and newline",
+			content: "v20.5.1\n",
+			want:    "20.5.1",
+		},
+		{
+			name:    "major version",
+			content: "18",
+			want:    "18",
+		},
+		{
+			name:    "comments and blank lines",
+			content: "# Node.js version\n\n  v16 # LTS\n",
+			want:    "16",
+		},
+		{
+			name:    "lts codename",
+			content: "lts/Gallium",
+			want:    "16",
+		},
+		{
+			name:    "latest lts",
+			content: "lts/*",
+			want:    latestLTS,
+		},
+		{
+			name:    "node alias",
+			content: "node",
+			want:    "",
+		},
+		{
+			name:    "stable alias",
+			content: "stable",
+			want:    "",
+		},
+		{
+			name:    "unknown lts codename",
+			content: "lts/unknown",
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			content: " \n# comment\n",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseVersionFile(tc.content)
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("parseVersionFile(%q) got error: %v, want error? %v", tc.content, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("parseVersionFile(%q) = %q, want %q", tc.content, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// WorkspaceEnv is an env var that selects the npm workspace that is built and started, either by
+// its package name or by its directory relative to the application root.
+// Example: `@acme/api` or `packages/api`.
+const WorkspaceEnv = "GOOGLE_NODEJS_WORKSPACE"
+
+// packageWorkspaces is the list of workspace patterns of a package.json file. npm declares it as an
+// array, Yarn also accepts an object with a "packages" array.
+type packageWorkspaces []string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (w *packageWorkspaces) UnmarshalJSON(b []byte) error {
+	var patterns []string
+	if err := json.Unmarshal(b, &patterns); err == nil {
+		*w = patterns
+		return nil
+	}
+	var obj struct {
+		Packages []string `json:"packages"`
+	}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return err
+	}
+	*w = obj.Packages
+	return nil
+}
+
+// Workspace is a package of an npm workspaces project.
+type Workspace struct {
+	// Dir is the directory of the workspace relative to the application root, e.g. "packages/api".
+	Dir string
+	// PackageJSON is the package.json of the workspace.
+	PackageJSON *PackageJSON
+}
+
+// Flag returns the npm flag that runs a command in the context of the workspace.
+func (w *Workspace) Flag() string {
+	return "--workspace=" + w.Dir
+}
+
+// SelectedWorkspace returns the workspace selected with GOOGLE_NODEJS_WORKSPACE among the
+// workspaces declared in the given package.json, or nil if none is selected.
+func SelectedWorkspace(ctx *gcp.Context, pjs *PackageJSON) (*Workspace, error) {
+	selected := strings.TrimSpace(os.Getenv(WorkspaceEnv))
+	if selected == "" {
+		return nil, nil
+	}
+	if pjs == nil || len(pjs.Workspaces) == 0 {
+		return nil, gcp.UserErrorf("%s=%q is set but package.json does not declare workspaces", WorkspaceEnv, selected)
+	}
+	dirs, err := workspaceDirs(ctx.ApplicationRoot(), pjs.Workspaces)
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range dirs {
+		wpjs, err := ReadPackageJSONIfExists(filepath.Join(ctx.ApplicationRoot(), dir))
+		if err != nil {
+			return nil, err
+		}
+		if dir == filepath.Clean(selected) || wpjs.Name == selected {
+			ctx.Logf("Using npm workspace %s.", dir)
+			return &Workspace{Dir: dir, PackageJSON: wpjs}, nil
+		}
+	}
+	return nil, gcp.UserErrorf("%s=%q does not match the name or directory of any workspace in %v", WorkspaceEnv, selected, dirs)
+}
+
+// workspaceDirs returns the directories, relative to root, that match the workspace patterns and
+// contain a package.json file.
+func workspaceDirs(root string, patterns []string) ([]string, error) {
+	seen := map[string]bool{}
+	var dirs []string
+	for _, p := range patterns {
+		matches, err := filepath.Glob(filepath.Join(root, p, "package.json"))
+		if err != nil {
+			return nil, gcp.UserErrorf("invalid workspace pattern %q in package.json: %v", p, err)
+		}
+		for _, m := range matches {
+			dir, err := filepath.Rel(root, filepath.Dir(m))
+			if err != nil {
+				return nil, gcp.InternalErrorf("finding relative path of %s: %v", m, err)
+			}
+			if !seen[dir] {
+				seen[dir] = true
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPackageWorkspacesUnmarshal(t *testing.T) {
+	testCases := []struct {
+		name string
+		pjs  string
+		want packageWorkspaces
+	}{
+		{
+			name: "no workspaces",
+			pjs:  `{"name": "app"}`,
+		},
+		{
+			name: "array",
+			pjs:  `{"workspaces": ["packages/*", "tools/cli"]}`,
+			want: packageWorkspaces{"packages/*", "tools/cli"},
+		},
+		{
+			name: "object",
+			pjs:  `{"workspaces": {"packages": ["packages/*"], "nohoist": ["**/react-native"]}}`,
+			want: packageWorkspaces{"packages/*"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var pjs PackageJSON
+			if err := json.Unmarshal([]byte(tc.pjs), &pjs); err != nil {
+				t.Fatalf("json.Unmarshal() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, pjs.Workspaces); diff != "" {
+				t.Errorf("Workspaces mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSelectedWorkspace(t *testing.T) {
+	files := map[string]string{
+		"packages/api/package.json":   `{"name": "@acme/api"}`,
+		"packages/web/package.json":   `{"name": "@acme/web"}`,
+		"packages/docs/README.md":     "",
+		"tools/cli/package.json":      `{"name": "cli"}`,
+		"unlisted/other/package.json": `{"name": "other"}`,
+	}
+	pjs := &PackageJSON{Workspaces: packageWorkspaces{"packages/*", "tools/cli"}}
+	testCases := []struct {
+		name     string
+		selected string
+		pjs      *PackageJSON
+		wantDir  string
+		wantErr  bool
+	}{
+		{
+			name: "not selected",
+			pjs:  pjs,
+		},
+		{
+			name:     "by package name",
+			selected: "@acme/web",
+			pjs:      pjs,
+			wantDir:  "packages/web",
+		},
+		{
+			name:     "by directory",
+			selected: "./tools/cli/",
+			pjs:      pjs,
+			wantDir:  "tools/cli",
+		},
+		{
+			name:     "directory without package.json",
+			selected: "packages/docs",
+			pjs:      pjs,
+			wantErr:  true,
+		},
+		{
+			name:     "directory not in workspaces",
+			selected: "unlisted/other",
+			pjs:      pjs,
+			wantErr:  true,
+		},
+		{
+			name:     "no workspaces",
+			selected: "@acme/api",
+			pjs:      &PackageJSON{},
+			wantErr:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			for name, content := range files {
+				path := filepath.Join(root, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("creating dir for %s: %v", name, err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatalf("writing %s: %v", name, err)
+				}
+			}
+			t.Setenv(WorkspaceEnv, tc.selected)
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(root))
+
+			got, err := SelectedWorkspace(ctx, tc.pjs)
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("SelectedWorkspace() got error: %v, want error? %v", err, tc.wantErr)
+			}
+			gotDir := ""
+			if got != nil {
+				gotDir = got.Dir
+			}
+			if gotDir != tc.wantDir {
+				t.Errorf("SelectedWorkspace() dir = %q, want %q", gotDir, tc.wantDir)
+			}
+		})
+	}
+}
@@ -38,8 +38,22 @@ var (
 const (
 	// YarnLock is the name of the yarn lock file.
 	YarnLock = "yarn.lock"
+	// YarnRC is the name of the configuration file of Yarn 2 and newer.
+	YarnRC = ".yarnrc.yml"
+
+	// yarnLinkerPnP is the Plug'n'Play nodeLinker, the default of Yarn 2 and newer.
+	yarnLinkerPnP = "pnp"
+	// yarnDefaultCacheFolder is the default location of the project cache of Yarn 2 and newer.
+	yarnDefaultCacheFolder = ".yarn/cache"
 )
 
+// YarnRCConfig is the subset of the .yarnrc.yml settings used by the buildpacks, see
+// https://yarnpkg.com/configuration/yarnrc.
+type YarnRCConfig struct {
+	NodeLinker  string `yaml:"nodeLinker"`
+	CacheFolder string `yaml:"cacheFolder"`
+}
+
 type yarn2Lock struct {
 	Metadata struct {
 		Version string `yaml:"version"`
@@ -70,6 +84,64 @@ func IsYarn2(rootDir string) (bool, error) {
 	return manifest.Metadata.Version != "", nil
 }
 
+// ReadYarnRC returns the settings of the .yarnrc.yml file in the given directory. It returns the
+// default settings if the file does not exist.
+func ReadYarnRC(dir string) (*YarnRCConfig, error) {
+	var rc YarnRCConfig
+	data, err := ioutil.ReadFile(filepath.Join(dir, YarnRC))
+	if os.IsNotExist(err) {
+		return &rc, nil
+	}
+	if err != nil {
+		return nil, gcp.InternalErrorf("reading %s: %v", YarnRC, err)
+	}
+	if err := yaml.Unmarshal(data, &rc); err != nil {
+		return nil, gcp.UserErrorf("parsing %s: %v", YarnRC, err)
+	}
+	return &rc, nil
+}
+
+// IsPnP returns true if dependencies are installed in Plug'n'Play mode rather than in a
+// node_modules directory, see https://yarnpkg.com/features/pnp.
+func (rc *YarnRCConfig) IsPnP() bool {
+	return rc.NodeLinker == "" || rc.NodeLinker == yarnLinkerPnP
+}
+
+// CacheDir returns the project cache folder, relative to the project root unless it is absolute.
+func (rc *YarnRCConfig) CacheDir() string {
+	if rc.CacheFolder != "" {
+		return rc.CacheFolder
+	}
+	return yarnDefaultCacheFolder
+}
+
+// PnPNodeOptions returns the NODE_OPTIONS that make Node.js resolve dependencies with the
+// Plug'n'Play runtime of the project in dir, which is what `yarn node` does. This lets processes
+// that are not started by Yarn, e.g. a custom entrypoint, load the dependencies.
+func PnPNodeOptions(dir string) (string, error) {
+	var opts []string
+	// Yarn 2 writes .pnp.js, Yarn 3 and newer write .pnp.cjs.
+	for _, name := range []string{".pnp.cjs", ".pnp.js"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			opts = append(opts, "--require "+filepath.Join(dir, name))
+			break
+		} else if !os.IsNotExist(err) {
+			return "", gcp.InternalErrorf("checking %s: %v", name, err)
+		}
+	}
+	if len(opts) == 0 {
+		return "", gcp.UserErrorf("yarn install did not generate the Plug'n'Play runtime .pnp.cjs")
+	}
+	// The ESM loader is generated when the project uses ES modules.
+	loader := filepath.Join(dir, ".pnp.loader.mjs")
+	if _, err := os.Stat(loader); err == nil {
+		opts = append(opts, "--experimental-loader "+loader)
+	} else if !os.IsNotExist(err) {
+		return "", gcp.InternalErrorf("checking %s: %v", loader, err)
+	}
+	return strings.Join(opts, " "), nil
+}
+
 // HasYarnWorkspacePlugin returns true if this project has Yarn2's workspaces plugin installed.
 func HasYarnWorkspacePlugin(ctx *gcp.Context) (bool, error) {
 	res, err := ctx.Exec([]string{"yarn", "plugin", "runtime"})
@@ -82,8 +154,17 @@ func HasYarnWorkspacePlugin(ctx *gcp.Context) (bool, error) {
 // detectYarnVersion determines the version of Yarn that should be installed in a Node.js project
 // by examining the "engines.yarn" constraint specified in package.json and comparing it against all
 // published versions in the NPM registry. If the package.json does not include "engines.yarn" it
-// returns the latest stable version available.
+// returns the version of its "packageManager" field, else the latest stable version available.
 func detectYarnVersion(pjs *PackageJSON) (string, error) {
+	// Yarn 2 and newer projects pin the exact version in the packageManager field.
+	if pjs != nil && pjs.Engines.Yarn == "" && strings.HasPrefix(pjs.PackageManager, "yarn@") {
+		// The version may be followed by a hash, e.g. "yarn@3.6.1+sha224.abc".
+		version := strings.SplitN(strings.TrimPrefix(pjs.PackageManager, "yarn@"), "+", 2)[0]
+		if _, err := semver.NewVersion(version); err != nil {
+			return "", gcp.UserErrorf("parsing Yarn version %q from packageManager in package.json: %v", version, err)
+		}
+		return version, nil
+	}
 	if pjs == nil || pjs.Engines.Yarn == "" {
 		version, err := latestPackageVersion("yarn")
 		if err != nil {
@@ -100,8 +181,12 @@ func detectYarnVersion(pjs *PackageJSON) (string, error) {
 	return version, nil
 }
 
-// InstallYarnLayer installs Yarn in the given layer if it is not already cached.
+// InstallYarnLayer installs Yarn in the given layer if it is not already cached. Yarn is installed
+// with corepack if its version is pinned in the "packageManager" field of package.json.
 func InstallYarnLayer(ctx *gcp.Context, yarnLayer *libcnb.Layer, pjs *PackageJSON) error {
+	if corepack, err := installWithCorepack(ctx, yarnLayer, pjs, "yarn"); err != nil || corepack {
+		return err
+	}
 	layerName := yarnLayer.Name
 	version, err := detectYarnVersion(pjs)
 	if err != nil {
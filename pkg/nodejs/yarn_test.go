@@ -21,6 +21,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/GoogleCloudPlatform/buildpacks/internal/testserver"
@@ -214,3 +215,161 @@ func TestInstallYarn(t *testing.T) {
 		})
 	}
 }
+
+func TestReadYarnRC(t *testing.T) {
+	testCases := []struct {
+		name         string
+		content      string
+		wantPnP      bool
+		wantCacheDir string
+		wantError    bool
+	}{
+		{
+			name:         "missing file",
+			wantPnP:      true,
+			wantCacheDir: ".yarn/cache",
+		},
+		{
+			name:         "pnp linker",
+			content:      "nodeLinker: pnp\n",
+			wantPnP:      true,
+			wantCacheDir: ".yarn/cache",
+		},
+		{
+			name:         "node-modules linker",
+			content:      "nodeLinker: node-modules\ncacheFolder: ./deps/cache\n",
+			wantPnP:      false,
+			wantCacheDir: "./deps/cache",
+		},
+		{
+			name:      "invalid yaml",
+			content:   "nodeLinker: [",
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tc.content != "" {
+				if err := ioutil.WriteFile(filepath.Join(dir, YarnRC), []byte(tc.content), 0644); err != nil {
+					t.Fatalf("writing %s: %v", YarnRC, err)
+				}
+			}
+
+			rc, err := ReadYarnRC(dir)
+			if tc.wantError {
+				if err == nil {
+					t.Fatalf("ReadYarnRC(%q) got no error, want error", dir)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadYarnRC(%q) got error: %v", dir, err)
+			}
+			if got := rc.IsPnP(); got != tc.wantPnP {
+				t.Errorf("IsPnP() = %t, want %t", got, tc.wantPnP)
+			}
+			if got := rc.CacheDir(); got != tc.wantCacheDir {
+				t.Errorf("CacheDir() = %q, want %q", got, tc.wantCacheDir)
+			}
+		})
+	}
+}
+
+func TestPnPNodeOptions(t *testing.T) {
+	testCases := []struct {
+		name      string
+		files     []string
+		want      string
+		wantError bool
+	}{
+		{
+			name:  "Yarn 3",
+			files: []string{".pnp.cjs"},
+			want:  "--require {dir}/.pnp.cjs",
+		},
+		{
+			name:  "Yarn 2",
+			files: []string{".pnp.js"},
+			want:  "--require {dir}/.pnp.js",
+		},
+		{
+			name:  "ESM loader",
+			files: []string{".pnp.cjs", ".pnp.loader.mjs"},
+			want:  "--require {dir}/.pnp.cjs --experimental-loader {dir}/.pnp.loader.mjs",
+		},
+		{
+			name:      "missing runtime",
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range tc.files {
+				if err := ioutil.WriteFile(filepath.Join(dir, f), nil, 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+
+			got, err := PnPNodeOptions(dir)
+			if tc.wantError {
+				if err == nil {
+					t.Fatalf("PnPNodeOptions(%q) got no error, want error", dir)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PnPNodeOptions(%q) got error: %v", dir, err)
+			}
+			if want := strings.ReplaceAll(tc.want, "{dir}", dir); got != want {
+				t.Errorf("PnPNodeOptions(%q) = %q, want %q", dir, got, want)
+			}
+		})
+	}
+}
+
+func TestDetectYarnVersionPackageManager(t *testing.T) {
+	testCases := []struct {
+		name           string
+		packageManager string
+		want           string
+		wantError      bool
+	}{
+		{
+			name:           "exact version",
+			packageManager: "yarn@3.6.1",
+			want:           "3.6.1",
+		},
+		{
+			name:           "version with hash",
+			packageManager: "yarn@4.0.2+sha224.abc123",
+			want:           "4.0.2",
+		},
+		{
+			name:           "invalid version",
+			packageManager: "yarn@latest",
+			wantError:      true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := detectYarnVersion(&PackageJSON{PackageManager: tc.packageManager})
+			if tc.wantError {
+				if err == nil {
+					t.Fatalf("detectYarnVersion() got no error, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("detectYarnVersion() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("detectYarnVersion() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
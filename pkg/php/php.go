@@ -21,12 +21,15 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"unicode"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/appengine"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/Masterminds/semver"
 	"github.com/buildpacks/libcnb"
 )
 
@@ -41,6 +44,13 @@ const (
 	phpVersionKey     = "php_version"
 	dependencyHashKey = "dependency_hash"
 
+	// composerCacheLayer holds the Composer download cache, see
+	// https://getcomposer.org/doc/03-cli.md#composer-cache-dir.
+	composerCacheLayer = "composer_cache"
+	// composerMaxParallelHTTP is the number of packages that Composer downloads in parallel, see
+	// https://getcomposer.org/doc/03-cli.md#composer-max-parallel-http.
+	composerMaxParallelHTTP = "24"
+
 	composerVersionKey = "php"
 
 	// PHPIni is the content of the php.ini config file
@@ -82,6 +92,14 @@ post_max_size = 32M
 `
 )
 
+var (
+	// composerMinorTildeRegexp matches Composer tilde constraints with a major and minor version, e.g.
+	// "~7.4", which allow any later minor version unlike semver tilde constraints.
+	composerMinorTildeRegexp = regexp.MustCompile(`~\s*v?\d+\.\d+([^.\d]|$)`)
+	// composerStabilityRegexp matches Composer stability flags, e.g. "@stable".
+	composerStabilityRegexp = regexp.MustCompile(`@[a-zA-Z]+$`)
+)
+
 type composerScriptsJSON struct {
 	GCPBuild string `json:"gcp-build"`
 }
@@ -140,21 +158,48 @@ func composerDependencyHash(ctx *gcp.Context) (string, error) {
 }
 
 // composerInstall runs `composer install` with the given flags.
-func composerInstall(ctx *gcp.Context, flags []string) error {
+func composerInstall(ctx *gcp.Context, flags []string, composerEnv []string) error {
 	cmd := append([]string{"composer", "install"}, flags...)
-	if _, err := ctx.Exec(cmd, gcp.WithUserAttribution); err != nil {
+	if _, err := ctx.Exec(cmd, gcp.WithEnv(composerEnv...), gcp.WithUserAttribution); err != nil {
 		return err
 	}
 	return nil
 }
 
+// composerCache creates the layer that holds the Composer download cache and returns the
+// environment that Composer must be run with to use it and to download packages in parallel. When
+// lockHash is not empty, the cache is cleared if composer.lock changed since the previous build so
+// that it does not accumulate packages that are no longer used.
+func composerCache(ctx *gcp.Context, lockHash string) ([]string, error) {
+	l, err := ctx.Layer(composerCacheLayer, gcp.BuildLayer, gcp.CacheLayer)
+	if err != nil {
+		return nil, fmt.Errorf("creating %v layer: %w", composerCacheLayer, err)
+	}
+	if lockHash != "" {
+		if ctx.GetMetadata(l, dependencyHashKey) == lockHash {
+			ctx.CacheHit(composerCacheLayer)
+		} else {
+			ctx.CacheMiss(composerCacheLayer)
+			if err := ctx.ClearLayer(l); err != nil {
+				return nil, fmt.Errorf("clearing layer %q: %w", l.Name, err)
+			}
+			ctx.SetMetadata(l, dependencyHashKey, lockHash)
+		}
+	}
+	composerEnv := []string{"COMPOSER_CACHE_DIR=" + l.Path}
+	if os.Getenv("COMPOSER_MAX_PARALLEL_HTTP") == "" {
+		composerEnv = append(composerEnv, "COMPOSER_MAX_PARALLEL_HTTP="+composerMaxParallelHTTP)
+	}
+	return composerEnv, nil
+}
+
 // ComposerInstall runs `composer install`, using the cache iff a lock file is present.
 // It creates a layer, so it returns the layer so that the caller may further modify it
 // if they desire.
 func ComposerInstall(ctx *gcp.Context, cacheTag string) (*libcnb.Layer, error) {
 	var flags []string
 	if composerArgs := os.Getenv(env.ComposerArgsEnv); composerArgs != "" {
-		flags = strings.Split(composerArgs, " ")
+		flags = strings.Fields(composerArgs)
 	} else {
 		// We don't install dev dependencies (i.e. we pass --no-dev to composer) because doing so has caused
 		// problems for customers in the past. For more information see these links:
@@ -182,7 +227,11 @@ func ComposerInstall(ctx *gcp.Context, cacheTag string) (*libcnb.Layer, error) {
 	// to newer versions in the future.
 	if !composerLockExists {
 		ctx.Logf("*** Improve build performance by generating and committing %s.", composerLock)
-		if err := composerInstall(ctx, flags); err != nil {
+		composerEnv, err := composerCache(ctx, "")
+		if err != nil {
+			return nil, err
+		}
+		if err := composerInstall(ctx, flags, composerEnv); err != nil {
 			return nil, err
 		}
 		return l, nil
@@ -216,7 +265,15 @@ func ComposerInstall(ctx *gcp.Context, cacheTag string) (*libcnb.Layer, error) {
 		if err := ctx.ClearLayer(l); err != nil {
 			return nil, fmt.Errorf("clearing layer %q: %w", l.Name, err)
 		}
-		if err := composerInstall(ctx, flags); err != nil {
+		lockHash, err := cache.Hash(ctx, cache.WithFiles(composerLock))
+		if err != nil {
+			return nil, fmt.Errorf("computing %s hash: %w", composerLock, err)
+		}
+		composerEnv, err := composerCache(ctx, lockHash)
+		if err != nil {
+			return nil, err
+		}
+		if err := composerInstall(ctx, flags, composerEnv); err != nil {
 			return nil, err
 		}
 
@@ -237,10 +294,14 @@ func ComposerInstall(ctx *gcp.Context, cacheTag string) (*libcnb.Layer, error) {
 
 // ComposerRequire runs `composer require` with the given packages. It expects packages to
 // be specified as `composer require` would expect them on the command line, for example
-// "myorg/mypackage:^0.7". It does no caching.
+// "myorg/mypackage:^0.7". It only caches the downloaded packages.
 func ComposerRequire(ctx *gcp.Context, packages []string) error {
+	composerEnv, err := composerCache(ctx, "")
+	if err != nil {
+		return err
+	}
 	cmd := append([]string{"composer", "require", "--no-progress", "--no-interaction"}, packages...)
-	if _, err := ctx.Exec(cmd, gcp.WithUserAttribution); err != nil {
+	if _, err := ctx.Exec(cmd, gcp.WithEnv(composerEnv...), gcp.WithUserAttribution); err != nil {
 		return err
 	}
 	return nil
@@ -289,5 +350,59 @@ func composerFileVersion(ctx *gcp.Context) (string, error) {
 		return "", nil
 	}
 
-	return v, nil
+	c := composerConstraint(v)
+	if _, err := semver.NewConstraint(c); err != nil {
+		return "", gcp.UserErrorf("invalid php version constraint %q in %s: %v", v, composerJSON, err)
+	}
+	if c != v {
+		ctx.Debugf("Converted %s php version constraint %q to %q", composerJSON, v, c)
+	}
+	return c, nil
+}
+
+// composerConstraint converts a Composer version constraint, see
+// https://getcomposer.org/doc/articles/versions.md, to the equivalent semver constraint so that the
+// newest available PHP version satisfying it can be resolved. Constraints that are already valid
+// semver constraints with the same meaning are returned unchanged.
+func composerConstraint(constraint string) string {
+	if _, err := semver.NewConstraint(constraint); err == nil && !composerMinorTildeRegexp.MatchString(constraint) {
+		return constraint
+	}
+	var alternatives []string
+	// Composer accepts both "|" and "||" as the logical OR operator.
+	for _, alt := range strings.Split(strings.ReplaceAll(constraint, "||", "|"), "|") {
+		alt = strings.TrimSpace(alt)
+		if strings.Contains(alt, " - ") {
+			// Hyphenated ranges have the same syntax in both.
+			alternatives = append(alternatives, alt)
+			continue
+		}
+		var ranges []string
+		op := ""
+		// Composer separates the constraints of a logical AND with either spaces or commas.
+		for _, f := range strings.FieldsFunc(alt, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+			// Composer allows a space between an operator and its version, e.g. ">= 7.4".
+			if strings.Trim(f, "<>=!") == "" {
+				op += f
+				continue
+			}
+			ranges = append(ranges, composerRange(op+composerStabilityRegexp.ReplaceAllString(f, "")))
+			op = ""
+		}
+		alternatives = append(alternatives, strings.Join(ranges, ", "))
+	}
+	return strings.Join(alternatives, " || ")
+}
+
+// composerRange converts a single Composer version range to the equivalent semver range.
+func composerRange(r string) string {
+	if !strings.HasPrefix(r, "~") {
+		return r
+	}
+	v, err := semver.NewVersion(strings.TrimPrefix(r, "~"))
+	if err != nil || strings.Count(r, ".") != 1 {
+		return r
+	}
+	// "~7.4" means ">=7.4 <8.0" in Composer but ">=7.4 <7.5" in semver.
+	return fmt.Sprintf(">=%d.%d, <%d.0.0", v.Major(), v.Minor(), v.Major()+1)
 }
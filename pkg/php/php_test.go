@@ -15,6 +15,7 @@
 package php
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -24,6 +25,8 @@ import (
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	bpversion "github.com/GoogleCloudPlatform/buildpacks/pkg/version"
+	"github.com/buildpacks/libcnb"
 )
 
 func TestReadComposerJSON(t *testing.T) {
@@ -152,6 +155,28 @@ func TestExtractVersion(t *testing.T) {
 `),
 			want: ">= 7.1.3, < 7.4.4",
 		},
+		{
+			name: "composer.json with composer specific version constraint",
+			composerJSON: strings.TrimSpace(`
+{
+  "require": {
+    "php": "~7.4 | ^8.0"
+  }
+}
+`),
+			want: ">=7.4, <8.0.0 || ^8.0",
+		},
+		{
+			name: "composer.json with invalid version constraint",
+			composerJSON: strings.TrimSpace(`
+{
+  "require": {
+    "php": "latest"
+  }
+}
+`),
+			wantErr: true,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -187,3 +212,144 @@ func TestExtractVersion(t *testing.T) {
 	}
 
 }
+
+func TestComposerConstraint(t *testing.T) {
+	versions := []string{"7.3.33", "7.4.30", "7.4.33", "8.0.30", "8.1.27", "8.2.15", "8.3.2"}
+	testCases := []struct {
+		constraint  string
+		want        string
+		wantVersion string
+	}{
+		{
+			constraint:  "^8.1",
+			want:        "^8.1",
+			wantVersion: "8.3.2",
+		},
+		{
+			constraint:  "~8.1.0",
+			want:        "~8.1.0",
+			wantVersion: "8.1.27",
+		},
+		{
+			constraint:  "~7.4",
+			want:        ">=7.4, <8.0.0",
+			wantVersion: "7.4.33",
+		},
+		{
+			constraint:  ">=7.3 <8.1",
+			want:        ">=7.3, <8.1",
+			wantVersion: "8.0.30",
+		},
+		{
+			constraint:  ">= 7.3 < 8.1",
+			want:        ">=7.3, <8.1",
+			wantVersion: "8.0.30",
+		},
+		{
+			constraint:  "^7.3 | ~8.0.0",
+			want:        "^7.3 || ~8.0.0",
+			wantVersion: "8.0.30",
+		},
+		{
+			constraint:  "^7.4 || ^8.0",
+			want:        "^7.4 || ^8.0",
+			wantVersion: "8.3.2",
+		},
+		{
+			constraint:  "8.2.*@stable",
+			want:        "8.2.*",
+			wantVersion: "8.2.15",
+		},
+		{
+			constraint:  "7.3 - 7.4",
+			want:        "7.3 - 7.4",
+			wantVersion: "7.3.33",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.constraint, func(t *testing.T) {
+			got := composerConstraint(tc.constraint)
+			if got != tc.want {
+				t.Errorf("composerConstraint(%q) = %q, want %q", tc.constraint, got, tc.want)
+			}
+			gotVersion, err := bpversion.ResolveVersion(got, versions)
+			if err != nil {
+				t.Fatalf("ResolveVersion(%q) got error: %v", got, err)
+			}
+			if gotVersion != tc.wantVersion {
+				t.Errorf("ResolveVersion(%q) = %q, want %q", got, gotVersion, tc.wantVersion)
+			}
+		})
+	}
+}
+
+func TestComposerCache(t *testing.T) {
+	testCases := []struct {
+		name      string
+		lockHash  string
+		metaHash  string
+		parallel  string
+		wantEnv   []string
+		wantClear bool
+	}{
+		{
+			name:     "no composer.lock",
+			metaHash: "abc",
+			wantEnv:  []string{"COMPOSER_CACHE_DIR=%s", "COMPOSER_MAX_PARALLEL_HTTP=24"},
+		},
+		{
+			name:     "composer.lock unchanged",
+			lockHash: "abc",
+			metaHash: "abc",
+			wantEnv:  []string{"COMPOSER_CACHE_DIR=%s", "COMPOSER_MAX_PARALLEL_HTTP=24"},
+		},
+		{
+			name:      "composer.lock changed",
+			lockHash:  "def",
+			metaHash:  "abc",
+			wantEnv:   []string{"COMPOSER_CACHE_DIR=%s", "COMPOSER_MAX_PARALLEL_HTTP=24"},
+			wantClear: true,
+		},
+		{
+			name:     "user parallel downloads",
+			parallel: "4",
+			wantEnv:  []string{"COMPOSER_CACHE_DIR=%s"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("COMPOSER_MAX_PARALLEL_HTTP", tc.parallel)
+			layers := t.TempDir()
+			// Simulate the layer restored from a previous build.
+			metadata := fmt.Sprintf("[metadata]\n%s = %q\n", dependencyHashKey, tc.metaHash)
+			if err := ioutil.WriteFile(filepath.Join(layers, composerCacheLayer+".toml"), []byte(metadata), 0644); err != nil {
+				t.Fatalf("writing layer metadata: %v", err)
+			}
+			layerPath := filepath.Join(layers, composerCacheLayer)
+			cached := filepath.Join(layerPath, "files", "package.zip")
+			if err := os.MkdirAll(filepath.Dir(cached), 0755); err != nil {
+				t.Fatalf("creating cache dir: %v", err)
+			}
+			if err := ioutil.WriteFile(cached, nil, 0644); err != nil {
+				t.Fatalf("writing %s: %v", cached, err)
+			}
+
+			ctx := gcp.NewContext(gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layers}}))
+			got, err := composerCache(ctx, tc.lockHash)
+			if err != nil {
+				t.Fatalf("composerCache() got error: %v", err)
+			}
+
+			var want []string
+			for _, e := range tc.wantEnv {
+				want = append(want, strings.ReplaceAll(e, "%s", layerPath))
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("composerCache() = %v, want %v", got, want)
+			}
+			if _, err := os.Stat(cached); os.IsNotExist(err) != tc.wantClear {
+				t.Errorf("cached file exists = %t, want %t", !os.IsNotExist(err), !tc.wantClear)
+			}
+		})
+	}
+}
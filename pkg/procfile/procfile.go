@@ -0,0 +1,94 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package procfile parses Procfiles, see https://devcenter.heroku.com/articles/procfile.
+package procfile
+
+import (
+	"regexp"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// File is the name of the file that declares the process types of an application.
+const File = "Procfile"
+
+var (
+	// processRe matches a process declaration. Lines that start with whitespace are not process
+	// declarations, they are usually continuations or commented-out processes.
+	processRe = regexp.MustCompile(`^([A-Za-z0-9_-]+):(.*)$`)
+)
+
+// Process is a process type declared in a Procfile.
+type Process struct {
+	// Type is the name of the process type, e.g. "web" or "worker".
+	Type string
+	// Command is the shell command that starts the process.
+	Command string
+}
+
+// Parse returns the processes declared in the given Procfile content in order of declaration.
+// Comments and blank lines are ignored. Malformed lines and later declarations of the same process
+// type are skipped with a warning.
+func Parse(ctx *gcp.Context, content string) ([]Process, error) {
+	var processes []Process
+	found := make(map[string]bool)
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		match := processRe.FindStringSubmatch(line)
+		if match == nil {
+			ctx.Warnf("Skipping line %d of %s, it is not of the form <process type>: <command>: %s", i+1, File, line)
+			continue
+		}
+		name, command := match[1], strings.TrimSpace(match[2])
+		if command == "" {
+			return nil, gcp.UserErrorf("%s process on line %d of %s has no command", name, i+1, File)
+		}
+		if found[name] {
+			ctx.Warnf("Skipping duplicate %s process: %s", name, command)
+			continue
+		}
+		found[name] = true
+		processes = append(processes, Process{Type: name, Command: command})
+	}
+	if len(processes) == 0 {
+		return nil, gcp.UserErrorf("did not find any processes in %s", File)
+	}
+	return processes, nil
+}
+
+// Validate checks that the command of the process is valid shell syntax, so that a typo fails the
+// build instead of the container at startup.
+func Validate(ctx *gcp.Context, p Process) error {
+	if _, err := ctx.Exec([]string{"bash", "-n", "-c", p.Command}, gcp.WithUserFailureAttribution); err != nil {
+		return gcp.UserErrorf("invalid shell syntax in the %s process of %s: %v", p.Type, File, err)
+	}
+	return nil
+}
+
+// Find returns the process of the given type, or an error if it is not declared.
+func Find(processes []Process, processType string) (Process, error) {
+	var types []string
+	for _, p := range processes {
+		if p.Type == processType {
+			return p, nil
+		}
+		types = append(types, p.Type)
+	}
+	return Process{}, gcp.UserErrorf("%s process not found in %s, found %q", processType, File, types)
+}
@@ -0,0 +1,171 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package procfile
+
+import (
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		want    []Process
+	}{
+		{
+			name:    "single process",
+			content: "web: gunicorn main:app",
+			want:    []Process{{Type: "web", Command: "gunicorn main:app"}},
+		},
+		{
+			name: "multiple process types",
+			content: `web: bundle exec puma
+worker: bundle exec sidekiq
+release: bundle exec rake db:migrate
+cron: bundle exec clockwork clock.rb
+`,
+			want: []Process{
+				{Type: "web", Command: "bundle exec puma"},
+				{Type: "worker", Command: "bundle exec sidekiq"},
+				{Type: "release", Command: "bundle exec rake db:migrate"},
+				{Type: "cron", Command: "bundle exec clockwork clock.rb"},
+			},
+		},
+		{
+			name:    "process type with hyphens and digits",
+			content: "web: node server.js\nqueue-worker2: node worker.js",
+			want: []Process{
+				{Type: "web", Command: "node server.js"},
+				{Type: "queue-worker2", Command: "node worker.js"},
+			},
+		},
+		{
+			name: "comments and blank lines",
+			content: `# The web server.
+web: node server.js
+
+# web: node old-server.js
+worker: node worker.js
+`,
+			want: []Process{
+				{Type: "web", Command: "node server.js"},
+				{Type: "worker", Command: "node worker.js"},
+			},
+		},
+		{
+			name:    "malformed lines are skipped",
+			content: "web: node server.js\n  worker: node worker.js\nnot a process",
+			want:    []Process{{Type: "web", Command: "node server.js"}},
+		},
+		{
+			name:    "duplicate process type uses first",
+			content: "web: foo\nweb: bar\n",
+			want:    []Process{{Type: "web", Command: "foo"}},
+		},
+		{
+			name:    "carriage returns",
+			content: "web: foo\r\nworker: bar\r\n",
+			want: []Process{
+				{Type: "web", Command: "foo"},
+				{Type: "worker", Command: "bar"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Parse(gcp.NewContext(), tc.content)
+			if err != nil {
+				t.Fatalf("Parse() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Parse() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseError(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:    "only comments",
+			content: "# web: foo",
+		},
+		{
+			name:    "empty command",
+			content: "web: foo\nworker:   \n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, err := Parse(gcp.NewContext(), tc.content); err == nil {
+				t.Errorf("Parse(%q) = %v, want error", tc.content, got)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		command string
+		wantErr bool
+	}{
+		{
+			command: "exec gunicorn --bind :$PORT main:app",
+		},
+		{
+			command: `cd api && node "server.js" | tee log.txt`,
+		},
+		{
+			command: "echo 'unterminated",
+			wantErr: true,
+		},
+		{
+			command: "if true; then echo missing fi",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.command, func(t *testing.T) {
+			err := Validate(gcp.NewContext(), Process{Type: "web", Command: tc.command})
+			if tc.wantErr == (err == nil) {
+				t.Errorf("Validate(%q) got error: %v, want error? %v", tc.command, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	processes := []Process{{Type: "web", Command: "foo"}, {Type: "worker", Command: "bar"}}
+
+	got, err := Find(processes, "worker")
+	if err != nil {
+		t.Fatalf("Find() got error: %v", err)
+	}
+	if want := (Process{Type: "worker", Command: "bar"}); got != want {
+		t.Errorf("Find() = %v, want %v", got, want)
+	}
+	if _, err := Find(processes, "release"); err == nil {
+		t.Error("Find() got no error for a missing process type, want error")
+	}
+}
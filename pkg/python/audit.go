@@ -0,0 +1,195 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/audit"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// AuditEnv is an environment variable that enables the pip-audit scan of the installed
+	// dependencies: true or warn to report the findings, fail to fail the build on any of them.
+	// pip-audit does not report severities, so the severity thresholds of audit.ParseConfig are
+	// rejected rather than failing the build on every vulnerability.
+	AuditEnv = "GOOGLE_PYTHON_AUDIT"
+
+	pipAuditPackage = "pip-audit"
+	pipAuditVersion = "2.7.3"
+	pipAuditLayer   = "pip_audit"
+
+	// osvURL is the URL of the vulnerability details on https://osv.dev, which covers the PYSEC,
+	// GHSA and CVE identifiers reported by pip-audit.
+	osvURL = "https://osv.dev/vulnerability/%s"
+	// maxTitleLength is the length after which vulnerability descriptions are truncated.
+	maxTitleLength = 100
+)
+
+// Audit scans the given installed distributions for known vulnerabilities with pip-audit if
+// GOOGLE_PYTHON_AUDIT enables it.
+func Audit(ctx *gcp.Context, sbom []gcp.SBOMEntry) error {
+	cfg, err := parseAuditConfig()
+	if err != nil || !cfg.Enabled {
+		return err
+	}
+	report := audit.Report{Scanner: "pip-audit"}
+	if len(sbom) == 0 {
+		return cfg.Check(ctx, report)
+	}
+	libDir, err := installPipAudit(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The installed versions are audited rather than the requirements files, which may not pin
+	// transitive dependencies. They are already installed so pip is not needed to resolve them.
+	dir, err := ctx.TempDir(pipAuditLayer)
+	if err != nil {
+		return err
+	}
+	reqs := filepath.Join(dir, "requirements.txt")
+	if err := ctx.WriteFile(reqs, []byte(pinnedRequirements(sbom)), 0644); err != nil {
+		return err
+	}
+	result, err := ctx.Exec([]string{
+		"python3", "-m", "pip_audit",
+		"--requirement", reqs,
+		"--no-deps",
+		"--disable-pip",
+		"--format", "json",
+		"--progress-spinner", "off",
+	}, gcp.WithEnv("PYTHONPATH="+libDir), gcp.WithUserAttribution)
+	// pip-audit exits with a non-zero status when it finds vulnerabilities.
+	if err != nil && (result == nil || strings.TrimSpace(result.Stdout) == "") {
+		return err
+	}
+	report.Findings, err = parsePipAudit(result.Stdout)
+	if err != nil {
+		return gcp.InternalErrorf("parsing pip-audit output: %v", err)
+	}
+	return cfg.Check(ctx, report)
+}
+
+// parseAuditConfig reads the audit configuration from GOOGLE_PYTHON_AUDIT.
+func parseAuditConfig() (audit.Config, error) {
+	cfg, err := audit.ParseConfig(AuditEnv)
+	if err != nil {
+		return audit.Config{}, err
+	}
+	if cfg.Threshold != audit.SeverityUnknown {
+		return audit.Config{}, gcp.UserErrorf("invalid %s %q, pip-audit does not report severities: only true or false are supported, or fail to fail the build on any vulnerability", AuditEnv, os.Getenv(AuditEnv))
+	}
+	return cfg, nil
+}
+
+// installPipAudit installs pip-audit in a build-only layer if it is not already cached and returns
+// the directory that must be added to PYTHONPATH to run it.
+func installPipAudit(ctx *gcp.Context) (string, error) {
+	al, err := ctx.Layer(pipAuditLayer, gcp.BuildLayer, gcp.CacheLayer)
+	if err != nil {
+		return "", fmt.Errorf("creating %v layer: %w", pipAuditLayer, err)
+	}
+	libDir := filepath.Join(al.Path, "lib")
+	if ctx.GetMetadata(al, versionKey) == pipAuditVersion {
+		ctx.CacheHit(pipAuditLayer)
+	} else {
+		ctx.CacheMiss(pipAuditLayer)
+		if err := ctx.ClearLayer(al); err != nil {
+			return "", fmt.Errorf("clearing layer %q: %w", pipAuditLayer, err)
+		}
+		ctx.Logf("Installing %s v%s", pipAuditPackage, pipAuditVersion)
+		if _, err := ctx.Exec([]string{
+			"python3", "-m", "pip", "install",
+			"--target", libDir,
+			"--disable-pip-version-check",
+			"--no-warn-script-location",
+			"--no-cache-dir",
+			fmt.Sprintf("%s==%s", pipAuditPackage, pipAuditVersion),
+		}, gcp.WithUserTimingAttribution); err != nil {
+			return "", err
+		}
+		ctx.SetMetadata(al, versionKey, pipAuditVersion)
+	}
+	ctx.AddBOMEntry(libcnb.BOMEntry{
+		Name:     pipAuditPackage,
+		Metadata: map[string]interface{}{"version": pipAuditVersion},
+		Build:    true,
+	})
+	return libDir, nil
+}
+
+// pinnedRequirements returns a requirements file that pins the given distributions.
+func pinnedRequirements(sbom []gcp.SBOMEntry) string {
+	var b strings.Builder
+	for _, e := range sbom {
+		if e.Version == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "%s==%s\n", e.Name, e.Version)
+	}
+	return b.String()
+}
+
+// parsePipAudit returns the findings of the JSON output of pip-audit.
+func parsePipAudit(out string) ([]audit.Finding, error) {
+	var report struct {
+		Dependencies []struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+			Vulns   []struct {
+				ID          string   `json:"id"`
+				FixVersions []string `json:"fix_versions"`
+				Description string   `json:"description"`
+			} `json:"vulns"`
+		} `json:"dependencies"`
+	}
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		return nil, err
+	}
+	var findings []audit.Finding
+	for _, d := range report.Dependencies {
+		for _, v := range d.Vulns {
+			title := vulnTitle(v.Description)
+			if len(v.FixVersions) > 0 {
+				title = strings.TrimSpace(fmt.Sprintf("%s (fixed in %s)", title, strings.Join(v.FixVersions, ", ")))
+			}
+			findings = append(findings, audit.Finding{
+				ID:       v.ID,
+				Package:  d.Name,
+				Version:  d.Version,
+				Severity: audit.SeverityUnknown,
+				Title:    title,
+				URL:      fmt.Sprintf(osvURL, v.ID),
+			})
+		}
+	}
+	return findings, nil
+}
+
+// vulnTitle returns the first line of the vulnerability description, truncated to maxTitleLength.
+func vulnTitle(description string) string {
+	title := strings.TrimSpace(strings.SplitN(strings.TrimSpace(description), "\n", 2)[0])
+	if r := []rune(title); len(r) > maxTitleLength {
+		title = strings.TrimSpace(string(r[:maxTitleLength])) + "..."
+	}
+	return title
+}
@@ -0,0 +1,130 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/audit"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPinnedRequirements(t *testing.T) {
+	sbom := []gcp.SBOMEntry{
+		{Name: "flask", Version: "2.0.1", PURLType: "pypi"},
+		{Name: "zope-interface", Version: "5.4.0", PURLType: "pypi"},
+		{Name: "unversioned", PURLType: "pypi"},
+	}
+	want := "flask==2.0.1\nzope-interface==5.4.0\n"
+
+	if got := pinnedRequirements(sbom); got != want {
+		t.Errorf("pinnedRequirements() = %q, want %q", got, want)
+	}
+}
+
+func TestParsePipAudit(t *testing.T) {
+	testCases := []struct {
+		name    string
+		out     string
+		want    []audit.Finding
+		wantErr bool
+	}{
+		{
+			name: "vulnerabilities",
+			out: `{
+  "dependencies": [
+    {"name": "flask", "version": "0.5", "vulns": [
+      {"id": "PYSEC-2019-179", "fix_versions": ["1.0"], "aliases": ["CVE-2019-1010083"], "description": "The Pallets Project Flask before 1.0 is affected by: unexpected memory usage.\nThe impact is: denial of service."}
+    ]},
+    {"name": "jinja2", "version": "3.1.2", "vulns": [
+      {"id": "GHSA-h5c8-rqwp-cp95", "fix_versions": [], "description": "` + strings.Repeat("x", 120) + `"}
+    ]},
+    {"name": "requests", "version": "2.31.0", "vulns": []},
+    {"name": "private-lib", "skip_reason": "Dependency not found on PyPI and could not be audited: private-lib (1.0)"}
+  ],
+  "fixes": []
+}`,
+			want: []audit.Finding{
+				{
+					ID:       "PYSEC-2019-179",
+					Package:  "flask",
+					Version:  "0.5",
+					Severity: audit.SeverityUnknown,
+					Title:    "The Pallets Project Flask before 1.0 is affected by: unexpected memory usage. (fixed in 1.0)",
+					URL:      "https://osv.dev/vulnerability/PYSEC-2019-179",
+				},
+				{
+					ID:       "GHSA-h5c8-rqwp-cp95",
+					Package:  "jinja2",
+					Version:  "3.1.2",
+					Severity: audit.SeverityUnknown,
+					Title:    strings.Repeat("x", 100) + "...",
+					URL:      "https://osv.dev/vulnerability/GHSA-h5c8-rqwp-cp95",
+				},
+			},
+		},
+		{
+			name: "no vulnerabilities",
+			out:  `{"dependencies": [{"name": "requests", "version": "2.31.0", "vulns": []}], "fixes": []}`,
+		},
+		{
+			name:    "invalid json",
+			out:     `ERROR:pip_audit._cli:connection error`,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parsePipAudit(tc.out)
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("parsePipAudit() got error: %v, want error? %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("parsePipAudit() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseAuditConfig(t *testing.T) {
+	testCases := []struct {
+		value   string
+		want    audit.Config
+		wantErr bool
+	}{
+		{value: ""},
+		{value: "false"},
+		{value: "true", want: audit.Config{Enabled: true}},
+		{value: "fail", want: audit.Config{Enabled: true, Fail: true}},
+		{value: "high", wantErr: true},
+		{value: "critical", wantErr: true},
+		{value: "always", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			t.Setenv(AuditEnv, tc.value)
+
+			got, err := parseAuditConfig()
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("parseAuditConfig() with %s=%q got error: %v, want error? %t", AuditEnv, tc.value, err, tc.wantErr)
+			}
+			if got.Enabled != tc.want.Enabled || got.Fail != tc.want.Fail {
+				t.Errorf("parseAuditConfig() with %s=%q = %+v, want %+v", AuditEnv, tc.value, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// CompileAllEnv is an environment variable that enables the bytecode precompilation of the
+	// application source, either "true", or "1" or "2" to also select the optimization level of
+	// python -O and -OO, which is then set as PYTHONOPTIMIZE at launch.
+	CompileAllEnv = "GOOGLE_PYTHON_COMPILEALL"
+
+	// sourceExcludes matches the directories of the application that are not compiled: hidden
+	// directories such as .git and virtual environments, and node_modules.
+	sourceExcludes = `(^|/)(\.[^/]*|node_modules|__pycache__)(/|$)`
+)
+
+// compileAllSetting returns whether GOOGLE_PYTHON_COMPILEALL is enabled and the optimization
+// level it selects.
+func compileAllSetting() (bool, int, error) {
+	switch v := strings.ToLower(strings.TrimSpace(os.Getenv(CompileAllEnv))); v {
+	case "", "false":
+		return false, 0, nil
+	case "true":
+		return true, 0, nil
+	case "1", "2":
+		level, _ := strconv.Atoi(v)
+		return true, level, nil
+	default:
+		return false, 0, gcp.UserErrorf("invalid %s %q, must be one of %q, %q, %q or %q", CompileAllEnv, os.Getenv(CompileAllEnv), "true", "false", "1", "2")
+	}
+}
+
+// optimizationLevel returns the optimization level selected by GOOGLE_PYTHON_COMPILEALL, or 0 if
+// it is not enabled.
+func optimizationLevel() (int, error) {
+	_, level, err := compileAllSetting()
+	return level, err
+}
+
+// CompileApplication precompiles the bytecode of the application source with the given python
+// interpreter if GOOGLE_PYTHON_COMPILEALL is enabled, so that imports at startup do not compile
+// it. The optimization level is set as PYTHONOPTIMIZE in the launch environment of the layer.
+func CompileApplication(ctx *gcp.Context, python string, l *libcnb.Layer) error {
+	enabled, level, err := compileAllSetting()
+	if err != nil || !enabled {
+		return err
+	}
+	if level > 0 {
+		l.LaunchEnvironment.Default("PYTHONOPTIMIZE", strconv.Itoa(level))
+	}
+	ctx.Logf("Precompiling the application bytecode.")
+	return compileAll(ctx, python, level, "-x", sourceExcludes, ctx.ApplicationRoot())
+}
+
+// compileAll generates deterministic hash-based pycs (https://www.python.org/dev/peps/pep-0552/)
+// for the given compileall arguments. The unchecked version skips hash validation at run time
+// (for faster startup), the pycs are only generated for the given optimization level.
+func compileAll(ctx *gcp.Context, python string, level int, args ...string) error {
+	cmd := []string{python}
+	if level > 0 {
+		cmd = append(cmd, "-"+strings.Repeat("O", level))
+	}
+	cmd = append(cmd, "-m", "compileall",
+		"--invalidation-mode", "unchecked-hash",
+		"-qq", // Do not print any message (matches `pip install` behavior).
+	)
+	result, err := ctx.Exec(append(cmd, args...), gcp.WithUserAttribution)
+	if err != nil {
+		if result != nil {
+			if result.ExitCode == 1 {
+				// Ignore file compilation errors (matches `pip install` behavior).
+				return nil
+			}
+			return fmt.Errorf("compileall: %s", result.Combined)
+		}
+		return fmt.Errorf("compileall: %v", err)
+	}
+	return nil
+}
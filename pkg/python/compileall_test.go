@@ -0,0 +1,107 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"os/exec"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCompileAllSetting(t *testing.T) {
+	testCases := []struct {
+		env         string
+		wantEnabled bool
+		wantLevel   int
+		wantErr     bool
+	}{
+		{env: ""},
+		{env: "false"},
+		{env: "true", wantEnabled: true},
+		{env: " True ", wantEnabled: true},
+		{env: "1", wantEnabled: true, wantLevel: 1},
+		{env: "2", wantEnabled: true, wantLevel: 2},
+		{env: "3", wantErr: true},
+		{env: "yes", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.env, func(t *testing.T) {
+			t.Setenv(CompileAllEnv, tc.env)
+
+			enabled, level, err := compileAllSetting()
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("compileAllSetting() got error: %v, want error? %v", err, tc.wantErr)
+			}
+			if enabled != tc.wantEnabled || level != tc.wantLevel {
+				t.Errorf("compileAllSetting() = (%v, %d), want (%v, %d)", enabled, level, tc.wantEnabled, tc.wantLevel)
+			}
+		})
+	}
+}
+
+func TestCompileApplication(t *testing.T) {
+	testCases := []struct {
+		name         string
+		env          string
+		wantCmd      []string
+		wantOptimize string
+	}{
+		{
+			name: "disabled",
+		},
+		{
+			name:    "enabled",
+			env:     "true",
+			wantCmd: []string{"/python/bin/python3", "-m", "compileall", "--invalidation-mode", "unchecked-hash", "-qq", "-x", sourceExcludes, "/workspace"},
+		},
+		{
+			name:         "optimization level",
+			env:          "2",
+			wantCmd:      []string{"/python/bin/python3", "-OO", "-m", "compileall", "--invalidation-mode", "unchecked-hash", "-qq", "-x", sourceExcludes, "/workspace"},
+			wantOptimize: "2",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(CompileAllEnv, tc.env)
+			var gotCmd []string
+			ctx := gcp.NewContext(
+				gcp.WithApplicationRoot("/workspace"),
+				gcp.WithExecCmd(func(name string, args ...string) *exec.Cmd {
+					gotCmd = append([]string{name}, args...)
+					return exec.Command("true")
+				}),
+			)
+			l := &libcnb.Layer{LaunchEnvironment: libcnb.Environment{}}
+
+			if err := CompileApplication(ctx, "/python/bin/python3", l); err != nil {
+				t.Fatalf("CompileApplication() got error: %v", err)
+			}
+			if diff := cmp.Diff(tc.wantCmd, gotCmd); diff != "" {
+				t.Errorf("CompileApplication() command mismatch (-want +got):\n%s", diff)
+			}
+			var gotOptimize string
+			if v, ok := l.LaunchEnvironment["PYTHONOPTIMIZE.default"]; ok {
+				gotOptimize = v
+			}
+			if gotOptimize != tc.wantOptimize {
+				t.Errorf("PYTHONOPTIMIZE = %q, want %q", gotOptimize, tc.wantOptimize)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// PipIndexURLEnv is an environment variable, or a build-time secret, that specifies the base URL
+	// of the package index from which requirements are installed instead of PyPI.
+	PipIndexURLEnv = "GOOGLE_PYTHON_PIP_INDEX_URL"
+	// PipExtraIndexURLEnv is an environment variable, or a build-time secret, that specifies a
+	// whitespace-separated list of additional package index URLs.
+	PipExtraIndexURLEnv = "GOOGLE_PYTHON_PIP_EXTRA_INDEX_URL"
+
+	// keyringPackage is the keyring backend that authenticates pip to Artifact Registry with the
+	// Application Default Credentials.
+	keyringPackage = "keyrings.google-artifactregistry-auth"
+	keyringVersion = "1.1.2"
+	keyringLayer   = "ar_keyring"
+
+	// arPythonHostSuffix is the suffix of the hosts of Artifact Registry Python repositories.
+	arPythonHostSuffix = "-python.pkg.dev"
+)
+
+// packageIndex holds the package index settings of the build.
+type packageIndex struct {
+	url   string
+	extra []string
+	// keyringDir is the directory that contains the Artifact Registry keyring backend, it is empty
+	// if no Artifact Registry repository is used.
+	keyringDir string
+}
+
+// lookupSecretOrEnv returns the value of the build-time secret with the given name, or of the
+// environment variable if there is no such secret.
+func lookupSecretOrEnv(ctx *gcp.Context, name string) string {
+	if v, ok := ctx.Secret(name); ok {
+		return v
+	}
+	return os.Getenv(name)
+}
+
+// readPackageIndex returns the package index settings specified by GOOGLE_PYTHON_PIP_INDEX_URL and
+// GOOGLE_PYTHON_PIP_EXTRA_INDEX_URL.
+func readPackageIndex(ctx *gcp.Context) (*packageIndex, error) {
+	idx := &packageIndex{url: strings.TrimSpace(lookupSecretOrEnv(ctx, PipIndexURLEnv))}
+	idx.extra = strings.Fields(lookupSecretOrEnv(ctx, PipExtraIndexURLEnv))
+	for _, u := range idx.urls() {
+		if err := validateIndexURL(u); err != nil {
+			return nil, err
+		}
+	}
+	return idx, nil
+}
+
+// urls returns all the index URLs.
+func (idx *packageIndex) urls() []string {
+	var urls []string
+	if idx.url != "" {
+		urls = append(urls, idx.url)
+	}
+	return append(urls, idx.extra...)
+}
+
+// usesArtifactRegistry returns true if one of the indexes is an Artifact Registry repository.
+func (idx *packageIndex) usesArtifactRegistry() bool {
+	for _, u := range idx.urls() {
+		if pu, err := url.Parse(u); err == nil && strings.HasSuffix(pu.Hostname(), arPythonHostSuffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheKey returns a string that changes when the index settings change.
+func (idx *packageIndex) cacheKey() string {
+	return "index:" + strings.Join(idx.urls(), " ")
+}
+
+// pipEnv returns the environment that pip must be run with.
+func (idx *packageIndex) pipEnv() []string {
+	var e []string
+	if idx.url != "" {
+		e = append(e, "PIP_INDEX_URL="+idx.url)
+	}
+	if len(idx.extra) > 0 {
+		e = append(e, "PIP_EXTRA_INDEX_URL="+strings.Join(idx.extra, " "))
+	}
+	if idx.keyringDir != "" {
+		e = append(e, "PYTHONPATH="+idx.keyringDir)
+	}
+	return e
+}
+
+// uvEnv returns the environment that uv must be run with. uv only queries keyring with the
+// subprocess provider, which requires the keyring command on the PATH.
+func (idx *packageIndex) uvEnv() []string {
+	var e []string
+	if idx.url != "" {
+		e = append(e, "UV_INDEX_URL="+idx.url)
+	}
+	if len(idx.extra) > 0 {
+		e = append(e, "UV_EXTRA_INDEX_URL="+strings.Join(idx.extra, " "))
+	}
+	if idx.keyringDir != "" {
+		e = append(e,
+			"PYTHONPATH="+idx.keyringDir,
+			"PATH="+filepath.Join(idx.keyringDir, "bin")+string(os.PathListSeparator)+os.Getenv("PATH"),
+			"UV_KEYRING_PROVIDER=subprocess")
+	}
+	return e
+}
+
+func validateIndexURL(u string) error {
+	pu, err := url.Parse(u)
+	if err != nil || (pu.Scheme != "https" && pu.Scheme != "http") || pu.Host == "" {
+		// The URL is not included in the error message because it may contain credentials.
+		return gcp.UserErrorf("invalid package index URL in %s or %s, must be an http(s) URL", PipIndexURLEnv, PipExtraIndexURLEnv)
+	}
+	return nil
+}
+
+// installKeyring installs the Artifact Registry keyring backend in a build-only layer if it is not
+// already cached, so that pip can authenticate to private Artifact Registry repositories.
+func installKeyring(ctx *gcp.Context, idx *packageIndex) error {
+	kl, err := ctx.Layer(keyringLayer, gcp.BuildLayer, gcp.CacheLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", keyringLayer, err)
+	}
+	libDir := filepath.Join(kl.Path, "lib")
+	if ctx.GetMetadata(kl, versionKey) == keyringVersion {
+		ctx.CacheHit(keyringLayer)
+	} else {
+		ctx.CacheMiss(keyringLayer)
+		if err := ctx.ClearLayer(kl); err != nil {
+			return fmt.Errorf("clearing layer %q: %w", keyringLayer, err)
+		}
+		ctx.Logf("Installing %s v%s", keyringPackage, keyringVersion)
+		// The backend is installed from PyPI, the private index may require it to authenticate.
+		if _, err := ctx.Exec([]string{
+			"python3", "-m", "pip", "install",
+			"--target", libDir,
+			"--disable-pip-version-check",
+			"--no-warn-script-location",
+			"--no-cache-dir",
+			"--index-url", "https://pypi.org/simple",
+			fmt.Sprintf("%s==%s", keyringPackage, keyringVersion),
+		}, gcp.WithUserTimingAttribution); err != nil {
+			return err
+		}
+		ctx.SetMetadata(kl, versionKey, keyringVersion)
+	}
+	ctx.AddBOMEntry(libcnb.BOMEntry{
+		Name:     keyringPackage,
+		Metadata: map[string]interface{}{"version": keyringVersion},
+		Build:    true,
+	})
+	idx.keyringDir = libDir
+	return nil
+}
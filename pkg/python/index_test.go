@@ -0,0 +1,104 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadPackageIndex(t *testing.T) {
+	testCases := []struct {
+		name       string
+		indexURL   string
+		extraURLs  string
+		wantPipEnv []string
+		wantUVEnv  []string
+		wantAR     bool
+		wantErr    bool
+	}{
+		{
+			name: "not configured",
+		},
+		{
+			name:       "index url",
+			indexURL:   " https://pypi.example.com/simple ",
+			wantPipEnv: []string{"PIP_INDEX_URL=https://pypi.example.com/simple"},
+			wantUVEnv:  []string{"UV_INDEX_URL=https://pypi.example.com/simple"},
+		},
+		{
+			name:       "extra index urls",
+			extraURLs:  "https://a.example.com/simple\thttps://b.example.com/simple",
+			wantPipEnv: []string{"PIP_EXTRA_INDEX_URL=https://a.example.com/simple https://b.example.com/simple"},
+			wantUVEnv:  []string{"UV_EXTRA_INDEX_URL=https://a.example.com/simple https://b.example.com/simple"},
+		},
+		{
+			name:       "artifact registry",
+			extraURLs:  "https://us-python.pkg.dev/my-project/my-repo/simple/",
+			wantPipEnv: []string{"PIP_EXTRA_INDEX_URL=https://us-python.pkg.dev/my-project/my-repo/simple/"},
+			wantUVEnv:  []string{"UV_EXTRA_INDEX_URL=https://us-python.pkg.dev/my-project/my-repo/simple/"},
+			wantAR:     true,
+		},
+		{
+			name:     "invalid url",
+			indexURL: "pypi.example.com",
+			wantErr:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(PipIndexURLEnv, tc.indexURL)
+			t.Setenv(PipExtraIndexURLEnv, tc.extraURLs)
+
+			idx, err := readPackageIndex(gcp.NewContext())
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("readPackageIndex() got error: %v, want error? %v", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.wantPipEnv, idx.pipEnv()); diff != "" {
+				t.Errorf("pipEnv() mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantUVEnv, idx.uvEnv()); diff != "" {
+				t.Errorf("uvEnv() mismatch (-want +got):\n%s", diff)
+			}
+			if got := idx.usesArtifactRegistry(); got != tc.wantAR {
+				t.Errorf("usesArtifactRegistry() = %t, want %t", got, tc.wantAR)
+			}
+		})
+	}
+}
+
+func TestPackageIndexKeyringEnv(t *testing.T) {
+	t.Setenv("PATH", "/usr/bin")
+	idx := &packageIndex{url: "https://us-python.pkg.dev/p/r/simple/", keyringDir: "/layers/ar_keyring/lib"}
+
+	wantPip := []string{"PIP_INDEX_URL=https://us-python.pkg.dev/p/r/simple/", "PYTHONPATH=/layers/ar_keyring/lib"}
+	if diff := cmp.Diff(wantPip, idx.pipEnv()); diff != "" {
+		t.Errorf("pipEnv() mismatch (-want +got):\n%s", diff)
+	}
+	wantUV := []string{
+		"UV_INDEX_URL=https://us-python.pkg.dev/p/r/simple/",
+		"PYTHONPATH=/layers/ar_keyring/lib",
+		"PATH=/layers/ar_keyring/lib/bin:/usr/bin",
+		"UV_KEYRING_PROVIDER=subprocess",
+	}
+	if diff := cmp.Diff(wantUV, idx.uvEnv()); diff != "" {
+		t.Errorf("uvEnv() mismatch (-want +got):\n%s", diff)
+	}
+}
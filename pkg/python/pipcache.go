@@ -0,0 +1,119 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// pipCacheLayer is the name of the cache-only layer that holds the pip wheel cache.
+	pipCacheLayer = "pip_cache"
+	// requirementsHashKey is the metadata key of the hash of the requirements files and the Python
+	// version that the pip cache was last saved for.
+	requirementsHashKey = "requirements_hash"
+)
+
+// pipCache is the pip cache directory kept in a cache-only layer across builds, so that the
+// packages with native extensions published as source distributions, e.g. numpy or pandas on
+// platforms without a matching binary wheel, are not built again when they did not change.
+type pipCache struct {
+	layer *libcnb.Layer
+	// key is the hash of the requirements files and the Python version, set by restore.
+	key string
+}
+
+// newPipCache creates the pip cache layer. It is created before the dependencies cache is checked
+// so that it is kept when the dependencies are not installed.
+func newPipCache(ctx *gcp.Context) (*pipCache, error) {
+	l, err := ctx.Layer(pipCacheLayer, gcp.CacheLayer)
+	if err != nil {
+		return nil, fmt.Errorf("creating %v layer: %w", pipCacheLayer, err)
+	}
+	return &pipCache{layer: l}, nil
+}
+
+// env returns the environment that pip must be run with to use the cache.
+func (c *pipCache) env() []string {
+	return []string{"PIP_CACHE_DIR=" + c.layer.Path}
+}
+
+// restore prepares the cache for an installation of the given requirements files with the given
+// Python version. The local cache is kept when the requirements change, pip only builds the wheels
+// that are missing from it, but it is cleared when the Python version changes since the wheels
+// built for another version are never used again. On workers that do not have a local copy of the
+// cache, it is restored from the remote cache, if there is one, for the same requirements.
+func (c *pipCache) restore(ctx *gcp.Context, pythonVersion string, reqs ...string) error {
+	key, err := cache.Hash(ctx, cache.WithFiles(reqs...), cache.WithStrings(pythonVersion))
+	if err != nil {
+		return err
+	}
+	c.key = key
+	if v := ctx.GetMetadata(c.layer, pythonVersionKey); v != "" && v != pythonVersion {
+		ctx.Debugf("Clearing %s, it was created with %s.", pipCacheLayer, v)
+		if err := ctx.ClearLayer(c.layer); err != nil {
+			return fmt.Errorf("clearing layer %q: %w", c.layer.Name, err)
+		}
+	}
+	ctx.SetMetadata(c.layer, pythonVersionKey, pythonVersion)
+	ctx.SetMetadata(c.layer, requirementsHashKey, key)
+	entries, err := ioutil.ReadDir(c.layer.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return gcp.InternalErrorf("reading %s: %v", c.layer.Path, err)
+	}
+	if len(entries) > 0 {
+		return nil
+	}
+	_, err = cache.RestoreRemote(ctx, c.layer, key)
+	return err
+}
+
+// save removes the HTTP cache, prunes the least recently used wheels if the cache exceeds
+// GOOGLE_CACHE_MAX_SIZE and saves it to the remote cache, if there is one.
+func (c *pipCache) save(ctx *gcp.Context) error {
+	// Only the wheels are kept: we used to save the whole cache to a layer, but it made builds
+	// slower because it includes http caching of pypi requests.
+	httpDirs, err := filepath.Glob(filepath.Join(c.layer.Path, "http*"))
+	if err != nil {
+		return gcp.InternalErrorf("finding the pip HTTP cache: %v", err)
+	}
+	for _, dir := range httpDirs {
+		if err := ctx.RemoveAll(dir); err != nil {
+			return err
+		}
+	}
+	if _, err := cache.Prune(ctx, c.layer, pipCacheUnit); err != nil {
+		return err
+	}
+	return cache.SaveRemote(ctx, c.layer, c.key)
+}
+
+// pipCacheUnit groups the files of the pip cache into entries that are pruned together: the
+// wheels built from the same source distribution share a directory under wheels/, other files are
+// independent.
+func pipCacheUnit(rel string) string {
+	if strings.HasPrefix(rel, "wheels/") {
+		return cache.ByDirectory(rel)
+	}
+	return cache.ByFile(rel)
+}
@@ -0,0 +1,165 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+func TestPipCacheUnit(t *testing.T) {
+	testCases := map[string]string{
+		"wheels/3f/a2/9b/0c1d/numpy-1.26.4-cp311-cp311-linux_x86_64.whl": "wheels/3f/a2/9b/0c1d",
+		"wheels/3f/a2/9b/0c1d/origin.json":                               "wheels/3f/a2/9b/0c1d",
+		"http-v2/a/b/c/d/e/abcdef":                                       "http-v2/a/b/c/d/e/abcdef",
+		"selfcheck/abc":                                                  "selfcheck/abc",
+	}
+	for rel, want := range testCases {
+		if got := pipCacheUnit(rel); got != want {
+			t.Errorf("pipCacheUnit(%q) = %q, want %q", rel, got, want)
+		}
+	}
+}
+
+func TestPipCacheRestore(t *testing.T) {
+	testCases := []struct {
+		name          string
+		cachedVersion string
+		wantKept      bool
+	}{
+		{
+			name:     "new cache",
+			wantKept: true,
+		},
+		{
+			name:          "same python version",
+			cachedVersion: "Python 3.11.4",
+			wantKept:      true,
+		},
+		{
+			name:          "python version changed",
+			cachedVersion: "Python 3.10.12",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			layers := t.TempDir()
+			ctx := gcp.NewContext(gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: layers}}))
+			pc, err := newPipCache(ctx)
+			if err != nil {
+				t.Fatalf("newPipCache() got error: %v", err)
+			}
+			if tc.cachedVersion != "" {
+				ctx.SetMetadata(pc.layer, pythonVersionKey, tc.cachedVersion)
+			}
+			wheel := filepath.Join(pc.layer.Path, "wheels", "numpy.whl")
+			if err := ctx.MkdirAll(filepath.Dir(wheel), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(wheel, []byte("wheel"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := pc.restore(ctx, "Python 3.11.4"); err != nil {
+				t.Fatalf("restore() got error: %v", err)
+			}
+
+			kept, err := ctx.FileExists(wheel)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if kept != tc.wantKept {
+				t.Errorf("restore() kept cached wheel = %v, want %v", kept, tc.wantKept)
+			}
+			if got, want := ctx.GetMetadata(pc.layer, pythonVersionKey), "Python 3.11.4"; got != want {
+				t.Errorf("%s metadata = %q, want %q", pythonVersionKey, got, want)
+			}
+		})
+	}
+}
+
+func TestPipCacheKey(t *testing.T) {
+	dir := t.TempDir()
+	reqs := filepath.Join(dir, "requirements.txt")
+	ctx := gcp.NewContext(gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}))
+	pc, err := newPipCache(ctx)
+	if err != nil {
+		t.Fatalf("newPipCache() got error: %v", err)
+	}
+	key := func(content, pythonVersion string) string {
+		t.Helper()
+		if err := ioutil.WriteFile(reqs, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := pc.restore(ctx, pythonVersion, reqs); err != nil {
+			t.Fatalf("restore() got error: %v", err)
+		}
+		if got := ctx.GetMetadata(pc.layer, requirementsHashKey); got != pc.key {
+			t.Errorf("%s metadata = %q, want %q", requirementsHashKey, got, pc.key)
+		}
+		return pc.key
+	}
+
+	numpy := key("numpy==1.26.4\n", "Python 3.11.4")
+	if got := key("numpy==1.26.4\n", "Python 3.11.4"); got != numpy {
+		t.Errorf("key of the same requirements = %q, want %q", got, numpy)
+	}
+	if got := key("numpy==1.26.4\npandas==2.2.0\n", "Python 3.11.4"); got == numpy {
+		t.Errorf("key of changed requirements = %q, want a different key", got)
+	}
+	if got := key("numpy==1.26.4\n", "Python 3.12.1"); got == numpy {
+		t.Errorf("key of another Python version = %q, want a different key", got)
+	}
+}
+
+func TestPipCacheSave(t *testing.T) {
+	ctx := gcp.NewContext(gcp.WithBuildContext(libcnb.BuildContext{Layers: libcnb.Layers{Path: t.TempDir()}}))
+	pc, err := newPipCache(ctx)
+	if err != nil {
+		t.Fatalf("newPipCache() got error: %v", err)
+	}
+	files := map[string]bool{
+		"wheels/3f/a2/numpy.whl": true,
+		"http/a/b/response":      false,
+		"http-v2/a/b/response":   false,
+	}
+	for rel := range files {
+		path := filepath.Join(pc.layer.Path, rel)
+		if err := ctx.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte("content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := pc.save(ctx); err != nil {
+		t.Fatalf("save() got error: %v", err)
+	}
+
+	for rel, wantKept := range files {
+		kept, err := ctx.FileExists(pc.layer.Path, rel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kept != wantKept {
+			t.Errorf("save() kept %s = %v, want %v", rel, kept, wantKept)
+		}
+	}
+}
@@ -16,6 +16,7 @@
 package python
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -23,6 +24,7 @@ import (
 	"time"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/ar"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
@@ -63,6 +65,9 @@ var (
 	RequirementsProvidesPlan = libcnb.BuildPlan{Provides: RequirementsProvides}
 	// RequirementsProvidesRequiresPlan is a build plan returned by buildpacks that consume requirements.txt.
 	RequirementsProvidesRequiresPlan = libcnb.BuildPlan{Provides: RequirementsProvides, Requires: RequirementsRequires}
+
+	// requirementsParseMessages are printed by pip and uv when a requirements file is malformed.
+	requirementsParseMessages = []string{"Invalid requirement", "Couldn't parse requirement"}
 )
 
 // Version returns the installed version of Python.
@@ -74,8 +79,14 @@ func Version(ctx *gcp.Context) (string, error) {
 	return strings.TrimSpace(result.Stdout), nil
 }
 
-// RuntimeVersion validate and returns the customer requested Python version by inspecting the
-// environment variables and .python-version file.
+// RuntimeVersion validate and returns the customer requested Python version by inspecting, in this
+// order of precedence:
+//  1. GOOGLE_PYTHON_VERSION
+//  2. GOOGLE_RUNTIME_VERSION
+//  3. the .python-version file
+//  4. project.requires-python or tool.poetry.dependencies.python in pyproject.toml
+//
+// Versions without a patch number, e.g. "3.11", resolve to the newest matching release.
 func RuntimeVersion(ctx *gcp.Context, dir string) (string, error) {
 	if v := os.Getenv(versionEnv); v != "" {
 		ctx.Logf("Using Python version from %s: %s", versionEnv, v)
@@ -92,6 +103,13 @@ func RuntimeVersion(ctx *gcp.Context, dir string) (string, error) {
 	if v != "" {
 		return v, nil
 	}
+	v, err = versionFromPyproject(ctx, dir)
+	if err != nil {
+		return "", err
+	}
+	if v != "" {
+		return v, nil
+	}
 
 	// This will use the highest listed at https://dl.google.com/runtimes/python/version.json.
 	ctx.Logf("Python version not specified, using the latest available version.")
@@ -109,7 +127,7 @@ func versionFromFile(ctx *gcp.Context, dir string) (string, error) {
 		if err != nil {
 			return "", err
 		}
-		v := strings.TrimSpace(string(raw))
+		v := parseVersionFile(string(raw))
 		if v != "" {
 			ctx.Logf("Using Python version from %s: %s", vf, v)
 			return v, nil
@@ -138,8 +156,41 @@ func InstallRequirements(ctx *gcp.Context, l *libcnb.Layer, reqs ...string) erro
 		return nil
 	}
 
+	inst, err := installer()
+	if err != nil {
+		return err
+	}
+	cacheOpts := []cache.Option{cache.WithFiles(reqs...)}
+	var uv *uvInstaller
+	var pc *pipCache
+	// The installer caches are created before the cache check so that their layers are kept on a
+	// cache hit.
+	if inst == installerUV {
+		if uv, err = installUV(ctx); err != nil {
+			return fmt.Errorf("installing uv: %w", err)
+		}
+		cacheOpts = append(cacheOpts, cache.WithStrings("installer:"+inst))
+	} else if pc, err = newPipCache(ctx); err != nil {
+		return err
+	}
+	// The dependencies are compiled for the optimization level the application runs with.
+	level, err := optimizationLevel()
+	if err != nil {
+		return err
+	}
+	if level > 0 {
+		cacheOpts = append(cacheOpts, cache.WithStrings(fmt.Sprintf("optimize:%d", level)))
+	}
+	idx, err := readPackageIndex(ctx)
+	if err != nil {
+		return err
+	}
+	if len(idx.urls()) > 0 {
+		cacheOpts = append(cacheOpts, cache.WithStrings(idx.cacheKey()))
+	}
+
 	// Check if we can use the cached-layer as is without reinstalling dependencies.
-	cached, err := checkCache(ctx, l, cache.WithFiles(reqs...))
+	cached, err := checkCache(ctx, l, cacheOpts...)
 	if err != nil {
 		return fmt.Errorf("checking cache: %w", err)
 	}
@@ -148,10 +199,26 @@ func InstallRequirements(ctx *gcp.Context, l *libcnb.Layer, reqs ...string) erro
 		return nil
 	}
 	ctx.CacheMiss(l.Name)
+	dependencyHash := ctx.GetMetadata(l, dependencyHashKey)
+	if uv != nil {
+		if err := uv.restoreCache(ctx, dependencyHash); err != nil {
+			return err
+		}
+	}
+	if pc != nil {
+		if err := pc.restore(ctx, ctx.GetMetadata(l, pythonVersionKey), reqs...); err != nil {
+			return err
+		}
+	}
 
 	if err := ar.GeneratePythonConfig(ctx); err != nil {
 		return fmt.Errorf("generating Artifact Registry credentials: %w", err)
 	}
+	if idx.usesArtifactRegistry() {
+		if err := installKeyring(ctx, idx); err != nil {
+			return fmt.Errorf("installing Artifact Registry keyring: %w", err)
+		}
+	}
 
 	// History of the logic below:
 	//
@@ -168,7 +235,8 @@ func InstallRequirements(ctx *gcp.Context, l *libcnb.Layer, reqs ...string) erro
 	// the functions-framework-pinned package).
 
 	// HACK: For backwards compatibility with Python 3.7 and 3.8 on App Engine and Cloud Functions.
-	virtualEnv := requiresVirtualEnv()
+	// uv cannot install into user site-packages so it always uses a virtual environment.
+	virtualEnv := requiresVirtualEnv() || uv != nil
 	if virtualEnv {
 		// --without-pip and --system-site-packages allow us to use `pip` and other packages from the
 		// build image and avoid reinstalling them, saving about 10MB.
@@ -197,49 +265,68 @@ func InstallRequirements(ctx *gcp.Context, l *libcnb.Layer, reqs ...string) erro
 		}
 	}
 
+	toolchain := &buildToolchain{}
+	if packages := systemPackages(); len(packages) > 0 {
+		if err := toolchain.install(ctx, packages); err != nil {
+			return err
+		}
+	}
 	for _, req := range reqs {
-		cmd := []string{
-			"python3", "-m", "pip", "install",
-			"--requirement", req,
-			"--upgrade",
-			"--upgrade-strategy", "only-if-needed",
-			"--no-warn-script-location",   // bin is added at run time by lifecycle.
-			"--no-warn-conflicts",         // Needed for python37 which allowed users to override dependencies. For newer versions, we do a separate `pip check`.
-			"--force-reinstall",           // Some dependencies may be in the build image but not run image. Later requirements.txt should override earlier.
-			"--no-compile",                // Prevent default timestamp-based bytecode compilation. Deterministic pycs are generated in a second step below.
-			"--disable-pip-version-check", // If we were going to upgrade pip, we would have done it already in the runtime buildpack.
-			"--no-cache-dir",              // We used to save this to a layer, but it made builds slower because it includes http caching of pypi requests.
+		var cmd, cmdEnv []string
+		if uv != nil {
+			cmd, cmdEnv = uv.installCommand(req), append(uv.env(), idx.uvEnv()...)
+		} else {
+			cmd = []string{
+				"python3", "-m", "pip", "install",
+				"--requirement", req,
+				"--upgrade",
+				"--upgrade-strategy", "only-if-needed",
+				"--no-warn-script-location",   // bin is added at run time by lifecycle.
+				"--no-warn-conflicts",         // Needed for python37 which allowed users to override dependencies. For newer versions, we do a separate `pip check`.
+				"--force-reinstall",           // Some dependencies may be in the build image but not run image. Later requirements.txt should override earlier.
+				"--no-compile",                // Prevent default timestamp-based bytecode compilation. Deterministic pycs are generated in a second step below.
+				"--disable-pip-version-check", // If we were going to upgrade pip, we would have done it already in the runtime buildpack.
+			}
+			if !virtualEnv {
+				cmd = append(cmd, "--user") // Install into user site-packages directory.
+			}
+			cmdEnv = append(pc.env(), idx.pipEnv()...)
 		}
-		if !virtualEnv {
-			cmd = append(cmd, "--user") // Install into user site-packages directory.
+		install := func() (*gcp.ExecResult, error) {
+			return ctx.Exec(cmd, gcp.WithEnv(append(cmdEnv, toolchain.env...)...), gcp.WithNetworkRetry, gcp.WithUserAttribution)
 		}
-		if _, err := ctx.Exec(cmd,
-			gcp.WithUserAttribution); err != nil {
-			return err
+		result, err := install()
+		// Source distributions are built with the C toolchain, which is installed on demand.
+		if err != nil && isCompilerError(result) && !toolchain.hasCompiler() {
+			ctx.Logf("Building the dependencies requires a C compiler.")
+			if terr := toolchain.install(ctx, systemPackages()); terr != nil {
+				return terr
+			}
+			result, err = install()
+		}
+		if err != nil {
+			if isMissingHeaderError(result) {
+				ctx.Tipf("A dependency is built from source and requires C headers that are not installed, set %s to the packages that provide them, e.g. libpq-dev for psycopg2.", env.PythonSystemPackages)
+			}
+			return requirementsError(result, err)
 		}
 	}
 
-	// Generate deterministic hash-based pycs (https://www.python.org/dev/peps/pep-0552/).
-	// Use the unchecked version to skip hash validation at run time (for faster startup).
-	result, cerr := ctx.Exec([]string{
-		"python3", "-m", "compileall",
-		"--invalidation-mode", "unchecked-hash",
-		"-qq", // Do not print any message (matches `pip install` behavior).
-		l.Path,
-	},
-		gcp.WithUserAttribution)
-	if cerr != nil {
-		if result != nil {
-			if result.ExitCode == 1 {
-				// Ignore file compilation errors (matches `pip install` behavior).
-				return nil
-			}
-			return fmt.Errorf("compileall: %s", result.Combined)
+	if uv != nil {
+		if err := uv.pruneCache(ctx); err != nil {
+			return err
+		}
+		if err := cache.SaveRemote(ctx, uv.cacheLayer, dependencyHash); err != nil {
+			return err
+		}
+	}
+	if pc != nil {
+		if err := pc.save(ctx); err != nil {
+			return err
 		}
-		return fmt.Errorf("compileall: %v", cerr)
 	}
 
-	return nil
+	return compileAll(ctx, "python3", level, l.Path)
 }
 
 // checkCache checks whether cached dependencies exist, match, and have not expired.
@@ -308,6 +395,21 @@ func requiresVirtualEnv() bool {
 	return runtime == "python37" || runtime == "python38"
 }
 
+// requirementsError tags the error of a failed requirements installation with an error code if
+// the output shows that the requirements file could not be parsed.
+func requirementsError(result *gcp.ExecResult, err error) error {
+	var be *buildererror.Error
+	if result == nil || !errors.As(err, &be) {
+		return err
+	}
+	for _, msg := range requirementsParseMessages {
+		if strings.Contains(result.Combined, msg) {
+			return be.WithCode(buildererror.CodePythonRequirementsParseError)
+		}
+	}
+	return err
+}
+
 // copySharedLibs moves the shared libs from the runtime layer into pip layer. This is required to
 // support building native extensions in python37 and python38 because virtual env does not copy
 // the correctly.
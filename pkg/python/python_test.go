@@ -27,6 +27,7 @@ func TestRuntimeVersion(t *testing.T) {
 		version        string
 		runtimeVersion string
 		versionFile    string
+		pyproject      string
 		want           string
 		wantErr        bool
 	}{
@@ -66,6 +67,28 @@ func TestRuntimeVersion(t *testing.T) {
 			versionFile:    "3.8.1",
 			want:           "3.8.0",
 		},
+		{
+			name:        "partial version from .python-version file",
+			versionFile: "3.11\n",
+			want:        "3.11.*",
+		},
+		{
+			name:      "version from pyproject.toml",
+			pyproject: "[project]\nrequires-python = \">=3.10\"\n",
+			want:      ">=3.10",
+		},
+		{
+			name:        ".python-version takes precedence over pyproject.toml",
+			versionFile: "3.11.4",
+			pyproject:   "[project]\nrequires-python = \">=3.10\"\n",
+			want:        "3.11.4",
+		},
+		{
+			name:      "GOOGLE_PYTHON_VERSION takes precedence over pyproject.toml",
+			version:   "3.9.0",
+			pyproject: "[project]\nrequires-python = \">=3.10\"\n",
+			want:      "3.9.0",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -86,6 +109,12 @@ func TestRuntimeVersion(t *testing.T) {
 				}
 			}
 
+			if tc.pyproject != "" {
+				if err := os.WriteFile(filepath.Join(dir, "pyproject.toml"), []byte(tc.pyproject), 0644); err != nil {
+					t.Fatalf("writing pyproject.toml: %v", err)
+				}
+			}
+
 			got, err := RuntimeVersion(ctx, dir)
 			if tc.wantErr == (err == nil) {
 				t.Errorf("RuntimeVersion(ctx, %q) got error: %v, want err? %t", dir, err, tc.wantErr)
@@ -0,0 +1,84 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// nameSeparators matches the runs of characters that are equivalent in package names, see
+// https://peps.python.org/pep-0503/#normalized-names.
+var nameSeparators = regexp.MustCompile(`[-_.]+`)
+
+// SBOMEntries returns the SBOM entries of the distributions installed in the site-packages of the
+// given layer, either a virtual environment or a user base directory. It is the equivalent of
+// `pip freeze` but does not depend on the environment of the layer being active.
+func SBOMEntries(l string) ([]gcp.SBOMEntry, error) {
+	metadataFiles, err := filepath.Glob(filepath.Join(l, "lib", "python*", "site-packages", "*.dist-info", "METADATA"))
+	if err != nil {
+		return nil, gcp.InternalErrorf("listing installed distributions: %v", err)
+	}
+	var entries []gcp.SBOMEntry
+	for _, f := range metadataFiles {
+		name, version, err := readDistMetadata(f)
+		if err != nil {
+			return nil, err
+		}
+		if name == "" {
+			continue
+		}
+		entries = append(entries, gcp.SBOMEntry{
+			Name:     nameSeparators.ReplaceAllString(strings.ToLower(name), "-"),
+			Version:  version,
+			PURLType: "pypi",
+		})
+	}
+	return entries, nil
+}
+
+// readDistMetadata returns the name and version from the header of a distribution METADATA file,
+// see https://packaging.python.org/en/latest/specifications/core-metadata/.
+func readDistMetadata(path string) (string, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", gcp.InternalErrorf("opening %s: %v", path, err)
+	}
+	defer f.Close()
+	var name, version string
+	s := bufio.NewScanner(f)
+	// The header ends at the first empty line, the description follows.
+	for s.Scan() && s.Text() != "" {
+		kv := strings.SplitN(s.Text(), ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "Name":
+			name = strings.TrimSpace(kv[1])
+		case "Version":
+			version = strings.TrimSpace(kv[1])
+		}
+	}
+	if err := s.Err(); err != nil {
+		return "", "", gcp.InternalErrorf("reading %s: %v", path, err)
+	}
+	return name, version, nil
+}
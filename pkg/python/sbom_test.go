@@ -0,0 +1,57 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSBOMEntries(t *testing.T) {
+	l := t.TempDir()
+	files := map[string]string{
+		"lib/python3.11/site-packages/Flask-2.3.2.dist-info/METADATA":             "Metadata-Version: 2.1\nName: Flask\nVersion: 2.3.2\n\nName: not-a-header\n",
+		"lib/python3.11/site-packages/typing_extensions-4.7.1.dist-info/METADATA": "Metadata-Version: 2.1\nName: typing_extensions\nVersion: 4.7.1\n",
+		"lib/python3.11/site-packages/zope.interface-6.0.dist-info/METADATA":      "Metadata-Version: 2.1\nName: zope.interface\nVersion: 6.0\n",
+		"lib/python3.11/site-packages/broken-1.0.dist-info/METADATA":              "Metadata-Version: 2.1\n",
+		"lib/python3.11/site-packages/flask/__init__.py":                          "",
+	}
+	for name, content := range files {
+		path := filepath.Join(l, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("creating dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("writing %s: %v", name, err)
+		}
+	}
+
+	got, err := SBOMEntries(l)
+	if err != nil {
+		t.Fatalf("SBOMEntries() got error: %v", err)
+	}
+	want := []gcp.SBOMEntry{
+		{Name: "flask", Version: "2.3.2", PURLType: "pypi"},
+		{Name: "typing-extensions", Version: "4.7.1", PURLType: "pypi"},
+		{Name: "zope-interface", Version: "6.0", PURLType: "pypi"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("SBOMEntries() mismatch (-want +got):\n%s", diff)
+	}
+}
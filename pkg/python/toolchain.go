@@ -0,0 +1,103 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/apt"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// toolchainLayer holds the C toolchain and the packages of GOOGLE_PYTHON_SYSTEM_PACKAGES, it is
+// only needed to build the dependencies so it is not part of the image.
+const toolchainLayer = "build_toolchain"
+
+// toolchainPackages are the packages needed to compile and link C and C++ extensions.
+var toolchainPackages = []string{"build-essential", "pkg-config"}
+
+// compilerErrorRegexp matches the errors of setuptools when the C or C++ compiler is missing, e.g.
+// "error: command 'gcc' failed: No such file or directory" or "unable to execute 'x86_64-linux-gnu-gcc'".
+var compilerErrorRegexp = regexp.MustCompile(`(command|execute) '[^']*\b(gcc|cc|g\+\+|c\+\+)'`)
+
+// missingHeaderRegexp matches the errors of the C compiler when a header is missing, e.g.
+// "fatal error: libpq-fe.h: No such file or directory".
+var missingHeaderRegexp = regexp.MustCompile(`fatal error: [^:\s]+\.h: No such file or directory`)
+
+// lookPath finds executables on the build image, it is a var for testing.
+var lookPath = exec.LookPath
+
+// buildToolchain provisions the build layer with the C toolchain and the system packages needed to
+// build the dependencies published as source distributions.
+type buildToolchain struct {
+	// env is the environment the installers run with to find the toolchain, it is nil until the
+	// toolchain is installed.
+	env []string
+	// compiler is true if the C toolchain was installed into the build layer.
+	compiler bool
+}
+
+// install installs the packages of GOOGLE_PYTHON_SYSTEM_PACKAGES, and the C toolchain if the build
+// image does not provide one, into the build layer.
+func (t *buildToolchain) install(ctx *gcp.Context, packages []string) error {
+	if _, err := lookPath("gcc"); err != nil {
+		ctx.Logf("gcc not found in the build image, installing %v", toolchainPackages)
+		packages = apt.ParsePackages(strings.Join(append(append([]string{}, packages...), toolchainPackages...), " "))
+		t.compiler = true
+	}
+	if len(packages) == 0 {
+		return nil
+	}
+	l, err := ctx.Layer(toolchainLayer, gcp.BuildLayer, gcp.CacheLayer)
+	if err != nil {
+		return fmt.Errorf("creating %v layer: %w", toolchainLayer, err)
+	}
+	if err := apt.InstallCached(ctx, l, packages); err != nil {
+		return err
+	}
+	t.env = apt.ExecEnv(ctx, l)
+	return nil
+}
+
+// hasCompiler returns true if the C compiler is provided by the build image or was installed.
+func (t *buildToolchain) hasCompiler() bool {
+	if t.compiler {
+		return true
+	}
+	_, err := lookPath("gcc")
+	return err == nil
+}
+
+// systemPackages returns the packages of GOOGLE_PYTHON_SYSTEM_PACKAGES.
+func systemPackages() []string {
+	return apt.ParsePackages(os.Getenv(env.PythonSystemPackages))
+}
+
+// isCompilerError returns true if the output of a failed installation shows that a dependency
+// could not be built because the C or C++ compiler is missing.
+func isCompilerError(result *gcp.ExecResult) bool {
+	return result != nil && compilerErrorRegexp.MatchString(result.Combined)
+}
+
+// isMissingHeaderError returns true if the output of a failed installation shows that a dependency
+// could not be built because a C header is missing.
+func isMissingHeaderError(result *gcp.ExecResult) bool {
+	return result != nil && missingHeaderRegexp.MatchString(result.Combined)
+}
@@ -0,0 +1,79 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"errors"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestIsCompilerError(t *testing.T) {
+	testCases := []struct {
+		output string
+		want   bool
+	}{
+		{output: "error: command 'gcc' failed: No such file or directory", want: true},
+		{output: "unable to execute 'x86_64-linux-gnu-gcc': No such file or directory", want: true},
+		{output: "error: command '/usr/bin/g++' failed with exit status 1", want: true},
+		{output: "fatal error: Python.h: No such file or directory"},
+		{output: "ERROR: No matching distribution found for numpy==99"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.output, func(t *testing.T) {
+			if got := isCompilerError(&gcp.ExecResult{Combined: tc.output}); got != tc.want {
+				t.Errorf("isCompilerError(%q) = %v, want %v", tc.output, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIsMissingHeaderError(t *testing.T) {
+	testCases := []struct {
+		output string
+		want   bool
+	}{
+		{output: "psycopg/psycopg.h:35:10: fatal error: libpq-fe.h: No such file or directory", want: true},
+		{output: "fatal error: libxml/xmlversion.h: No such file or directory", want: true},
+		{output: "error: command 'gcc' failed: No such file or directory"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.output, func(t *testing.T) {
+			if got := isMissingHeaderError(&gcp.ExecResult{Combined: tc.output}); got != tc.want {
+				t.Errorf("isMissingHeaderError(%q) = %v, want %v", tc.output, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHasCompiler(t *testing.T) {
+	defer func(fn func(string) (string, error)) { lookPath = fn }(lookPath)
+	lookPath = func(string) (string, error) { return "", errors.New("not found") }
+
+	toolchain := &buildToolchain{}
+	if toolchain.hasCompiler() {
+		t.Errorf("hasCompiler() = true without gcc, want false")
+	}
+	toolchain.compiler = true
+	if !toolchain.hasCompiler() {
+		t.Errorf("hasCompiler() = false after installing the toolchain, want true")
+	}
+
+	lookPath = func(string) (string, error) { return "/usr/bin/gcc", nil }
+	if !(&buildToolchain{}).hasCompiler() {
+		t.Errorf("hasCompiler() = false with gcc in the build image, want true")
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/fetch"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpacks/libcnb"
+)
+
+const (
+	// InstallerEnv is an environment variable that selects the tool used to install requirements,
+	// either "pip" (the default) or "uv".
+	InstallerEnv = "GOOGLE_PYTHON_INSTALLER"
+
+	installerPIP = "pip"
+	installerUV  = "uv"
+
+	// uvVersion is the version of uv used to install requirements.
+	uvVersion    = "0.4.18"
+	uvLayer      = "uv"
+	uvCacheLayer = "uv_cache"
+)
+
+// uvURL is the download URL of uv release archives, it is a var for testing.
+var uvURL = "https://github.com/astral-sh/uv/releases/download/%[1]s/uv-%[2]s-unknown-linux-gnu.tar.gz"
+
+// pipToUVEnv maps the pip settings that are commonly used to configure a private package index
+// to their uv equivalent, uv does not read the pip configuration.
+var pipToUVEnv = [][2]string{
+	{"PIP_INDEX_URL", "UV_INDEX_URL"},
+	{"PIP_EXTRA_INDEX_URL", "UV_EXTRA_INDEX_URL"},
+}
+
+// installer returns the tool selected to install requirements.
+func installer() (string, error) {
+	switch v := strings.ToLower(strings.TrimSpace(os.Getenv(InstallerEnv))); v {
+	case "", installerPIP:
+		return installerPIP, nil
+	case installerUV:
+		return installerUV, nil
+	default:
+		return "", gcp.UserErrorf("invalid %s %q, must be one of %q or %q", InstallerEnv, os.Getenv(InstallerEnv), installerPIP, installerUV)
+	}
+}
+
+// uvInstaller holds the paths needed to run uv.
+type uvInstaller struct {
+	bin        string
+	cacheDir   string
+	cacheLayer *libcnb.Layer
+}
+
+// installUV installs uv in a build-only layer if it is not already cached and creates the cached
+// layer that holds the uv wheel cache.
+func installUV(ctx *gcp.Context) (*uvInstaller, error) {
+	ul, err := ctx.Layer(uvLayer, gcp.BuildLayer, gcp.CacheLayer)
+	if err != nil {
+		return nil, fmt.Errorf("creating %v layer: %w", uvLayer, err)
+	}
+	if ctx.GetMetadata(ul, versionKey) == uvVersion {
+		ctx.CacheHit(uvLayer)
+	} else {
+		ctx.CacheMiss(uvLayer)
+		if err := ctx.ClearLayer(ul); err != nil {
+			return nil, fmt.Errorf("clearing layer %q: %w", uvLayer, err)
+		}
+		ctx.Logf("Installing uv v%s", uvVersion)
+		binDir := filepath.Join(ul.Path, "bin")
+		if err := fetch.Tarball(fmt.Sprintf(uvURL, uvVersion, ctx.UnameArch()), binDir, 1); err != nil {
+			return nil, gcp.InternalErrorf("fetching uv v%s: %v", uvVersion, err)
+		}
+		ctx.SetMetadata(ul, versionKey, uvVersion)
+	}
+	ctx.AddBOMEntry(libcnb.BOMEntry{
+		Name:     uvLayer,
+		Metadata: map[string]interface{}{"version": uvVersion},
+		Build:    true,
+	})
+
+	// The wheel cache is kept across builds regardless of the requirements, uv only downloads and
+	// builds the wheels missing from it.
+	cl, err := ctx.Layer(uvCacheLayer, gcp.BuildLayer, gcp.CacheLayer)
+	if err != nil {
+		return nil, fmt.Errorf("creating %v layer: %w", uvCacheLayer, err)
+	}
+	return &uvInstaller{bin: filepath.Join(ul.Path, "bin", "uv"), cacheDir: cl.Path, cacheLayer: cl}, nil
+}
+
+// restoreCache restores the wheel cache from the remote cache, if there is one, on workers that
+// do not have a local copy of it.
+func (u *uvInstaller) restoreCache(ctx *gcp.Context, key string) error {
+	entries, err := ioutil.ReadDir(u.cacheDir)
+	if err != nil && !os.IsNotExist(err) {
+		return gcp.InternalErrorf("reading %s: %v", u.cacheDir, err)
+	}
+	if len(entries) > 0 {
+		return nil
+	}
+	_, err = cache.RestoreRemote(ctx, u.cacheLayer, key)
+	return err
+}
+
+// uvCacheUnit groups the files of the uv cache into entries that are pruned together: unpacked
+// archives are directories of the archive bucket, other buckets hold a directory per package.
+func uvCacheUnit(rel string) string {
+	if strings.HasPrefix(rel, "archive-") {
+		return cache.ByPrefix(2)(rel)
+	}
+	return cache.ByPrefix(3)(rel)
+}
+
+// installCommand returns the command that installs the given requirements file into the active
+// virtual environment.
+func (u *uvInstaller) installCommand(req string) []string {
+	return []string{
+		u.bin, "pip", "install",
+		"--requirement", req,
+		"--upgrade",
+		"--reinstall",         // Some dependencies may be in the build image but not run image. Later requirements.txt should override earlier.
+		"--link-mode", "copy", // The cache and the virtual environment are in different layers.
+	}
+}
+
+// env returns the environment that uv must be run with.
+func (u *uvInstaller) env() []string {
+	e := []string{"UV_CACHE_DIR=" + u.cacheDir, "UV_NO_PROGRESS=1"}
+	for _, m := range pipToUVEnv {
+		if v, ok := os.LookupEnv(m[0]); ok && os.Getenv(m[1]) == "" {
+			e = append(e, m[1]+"="+v)
+		}
+	}
+	return e
+}
+
+// pruneCache removes unused entries from the wheel cache so that it does not grow unbounded, and
+// the least recently used ones if it exceeds GOOGLE_CACHE_MAX_SIZE.
+func (u *uvInstaller) pruneCache(ctx *gcp.Context) error {
+	if _, err := ctx.Exec([]string{u.bin, "cache", "prune"}, gcp.WithEnv(u.env()...), gcp.WithUserTimingAttribution); err != nil {
+		return err
+	}
+	_, err := cache.Prune(ctx, u.cacheLayer, uvCacheUnit)
+	return err
+}
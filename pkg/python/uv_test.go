@@ -0,0 +1,114 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestInstaller(t *testing.T) {
+	testCases := []struct {
+		name    string
+		env     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "default to pip",
+			want: "pip",
+		},
+		{
+			name: "pip",
+			env:  "pip",
+			want: "pip",
+		},
+		{
+			name: "uv",
+			env:  "uv",
+			want: "uv",
+		},
+		{
+			name: "case insensitive",
+			env:  " UV ",
+			want: "uv",
+		},
+		{
+			name:    "unknown installer",
+			env:     "poetry",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(InstallerEnv, tc.env)
+
+			got, err := installer()
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("installer() got error: %v, want error? %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("installer() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUVEnv(t *testing.T) {
+	testCases := []struct {
+		name string
+		env  map[string]string
+		want []string
+	}{
+		{
+			name: "cache dir",
+			want: []string{"UV_CACHE_DIR=/layers/uv_cache", "UV_NO_PROGRESS=1"},
+		},
+		{
+			name: "pip index settings",
+			env: map[string]string{
+				"PIP_INDEX_URL":       "https://example.com/simple",
+				"PIP_EXTRA_INDEX_URL": "https://extra.example.com/simple",
+			},
+			want: []string{
+				"UV_CACHE_DIR=/layers/uv_cache",
+				"UV_NO_PROGRESS=1",
+				"UV_INDEX_URL=https://example.com/simple",
+				"UV_EXTRA_INDEX_URL=https://extra.example.com/simple",
+			},
+		},
+		{
+			name: "uv settings take precedence",
+			env: map[string]string{
+				"PIP_INDEX_URL": "https://example.com/simple",
+				"UV_INDEX_URL":  "https://uv.example.com/simple",
+			},
+			want: []string{"UV_CACHE_DIR=/layers/uv_cache", "UV_NO_PROGRESS=1"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			uv := &uvInstaller{bin: "/layers/uv/bin/uv", cacheDir: "/layers/uv_cache"}
+
+			if diff := cmp.Diff(tc.want, uv.env()); diff != "" {
+				t.Errorf("env() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/version"
+)
+
+const pyprojectFile = "pyproject.toml"
+
+// partialVersionRegexp matches versions without a patch number, e.g. "3" or "3.11".
+var partialVersionRegexp = regexp.MustCompile(`^\d+(\.\d+)?$`)
+
+type pyproject struct {
+	Project struct {
+		RequiresPython string `toml:"requires-python"`
+	} `toml:"project"`
+	Tool struct {
+		Poetry struct {
+			Dependencies map[string]interface{} `toml:"dependencies"`
+		} `toml:"poetry"`
+	} `toml:"tool"`
+}
+
+// parseVersionFile returns the version in the content of a pyenv-style .python-version file: the
+// first version listed, ignoring comments. Versions without a patch number are returned as a
+// wildcard constraint so that they resolve to the newest matching release, e.g. "3.11" returns
+// "3.11.*".
+func parseVersionFile(content string) string {
+	for _, line := range strings.Split(content, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if fields := strings.Fields(line); len(fields) > 0 {
+			v := fields[0]
+			if partialVersionRegexp.MatchString(v) {
+				return v + ".*"
+			}
+			return v
+		}
+	}
+	return ""
+}
+
+// versionFromPyproject returns the Python version constraint of project.requires-python in the
+// pyproject.toml file, or of tool.poetry.dependencies.python for Poetry projects.
+func versionFromPyproject(ctx *gcp.Context, dir string) (string, error) {
+	path := filepath.Join(dir, pyprojectFile)
+	exists, err := ctx.FileExists(path)
+	if err != nil || !exists {
+		return "", err
+	}
+	raw, err := ctx.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	var p pyproject
+	if err := toml.Unmarshal(raw, &p); err != nil {
+		return "", gcp.UserErrorf("parsing %s: %v", pyprojectFile, err)
+	}
+	field, spec := "project.requires-python", p.Project.RequiresPython
+	if spec == "" {
+		if v, ok := p.Tool.Poetry.Dependencies["python"].(string); ok {
+			field, spec = "tool.poetry.dependencies.python", v
+		}
+	}
+	if strings.TrimSpace(spec) == "" {
+		return "", nil
+	}
+	constraint, err := version.Constraint(version.PEP440, spec)
+	if err != nil {
+		return "", gcp.UserErrorf("parsing %s in %s: %v", field, pyprojectFile, err).WithCode(buildererror.CodeInvalidRuntimeVersion)
+	}
+	ctx.Logf("Using Python version from %s in %s: %s", field, pyprojectFile, spec)
+	return constraint, nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package python
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestParseVersionFile(t *testing.T) {
+	testCases := []struct {
+		content string
+		want    string
+	}{
+		{content: "3.11.4", want: "3.11.4"},
+		{content: "3.11\n", want: "3.11.*"},
+		{content: "3", want: "3.*"},
+		{content: "# pyenv\n\n3.12 3.11\n", want: "3.12.*"},
+		{content: "3.10.2 # pinned", want: "3.10.2"},
+		{content: " \n# comment only\n", want: ""},
+	}
+	for _, tc := range testCases {
+		if got := parseVersionFile(tc.content); got != tc.want {
+			t.Errorf("parseVersionFile(%q) = %q, want %q", tc.content, got, tc.want)
+		}
+	}
+}
+
+func TestVersionFromPyproject(t *testing.T) {
+	testCases := []struct {
+		name      string
+		pyproject string
+		want      string
+		wantErr   bool
+	}{
+		{
+			name: "no pyproject.toml",
+		},
+		{
+			name:      "requires-python",
+			pyproject: "[project]\nname = \"app\"\nrequires-python = \">=3.10\"\n",
+			want:      ">=3.10",
+		},
+		{
+			name:      "poetry",
+			pyproject: "[tool.poetry.dependencies]\npython = \"^3.11\"\nflask = \"^3.0\"\n",
+			want:      "^3.11",
+		},
+		{
+			name:      "requires-python takes precedence over poetry",
+			pyproject: "[project]\nrequires-python = \"~=3.12\"\n[tool.poetry.dependencies]\npython = \"^3.11\"\n",
+			want:      ">=3.12, <4.0.0",
+		},
+		{
+			name:      "no python version",
+			pyproject: "[project]\nname = \"app\"\n",
+		},
+		{
+			name:      "invalid toml",
+			pyproject: "[project\n",
+			wantErr:   true,
+		},
+		{
+			name:      "invalid specifier",
+			pyproject: "[project]\nrequires-python = \">=three\"\n",
+			wantErr:   true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tc.pyproject != "" {
+				if err := os.WriteFile(filepath.Join(dir, "pyproject.toml"), []byte(tc.pyproject), 0644); err != nil {
+					t.Fatalf("writing pyproject.toml: %v", err)
+				}
+			}
+
+			got, err := versionFromPyproject(gcp.NewContext(gcp.WithApplicationRoot(dir)), dir)
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("versionFromPyproject() got error: %v, want error? %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("versionFromPyproject() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -66,6 +66,29 @@ func ParseBundlerVersion(path string) (string, error) {
 	return fmt.Sprintf("%d.%d.%d", semver.Major(), semver.Minor(), semver.Patch()), nil
 }
 
+// HasGem returns true if the gem is locked in Gemfile.lock or gems.locked, either as a dependency
+// of the application or of another gem. It returns false if the file does not exist.
+func HasGem(path, gem string) (bool, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	// Gems are indented under GEM specs and DEPENDENCIES, followed by their version or requirements.
+	gemRe := regexp.MustCompile(`^\s+` + regexp.QuoteMeta(gem) + `(\s|!|$)`)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if gemRe.MatchString(scanner.Text()) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
 func readLineAfter(path string, token string) (string, error) {
 	file, err := os.Open(path)
 	if err != nil {
@@ -188,3 +188,74 @@ BUNDLED WITH
 	}
 
 }
+
+func TestHasGem(t *testing.T) {
+	const lockFile = `
+GEM
+  remote: https://rubygems.org/
+  specs:
+    bootsnap (1.17.0)
+      msgpack (~> 1.2)
+    msgpack (1.7.2)
+    rails (7.1.2)
+
+PLATFORMS
+  x86_64-linux
+
+DEPENDENCIES
+  bootsnap
+  rails (~> 7.1.2)
+`
+	testCases := []struct {
+		name     string
+		lockFile string
+		gem      string
+		want     bool
+	}{
+		{
+			name:     "gem in specs",
+			lockFile: lockFile,
+			gem:      "msgpack",
+			want:     true,
+		},
+		{
+			name:     "gem in dependencies",
+			lockFile: lockFile,
+			gem:      "bootsnap",
+			want:     true,
+		},
+		{
+			name:     "gem with the same prefix",
+			lockFile: lockFile,
+			gem:      "boot",
+		},
+		{
+			name:     "missing gem",
+			lockFile: lockFile,
+			gem:      "sprockets",
+		},
+		{
+			name: "no lock file",
+			gem:  "bootsnap",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "Gemfile.lock")
+			if tc.lockFile != "" {
+				if err := ioutil.WriteFile(path, []byte(tc.lockFile), 0644); err != nil {
+					t.Fatalf("writing file %s: %v", path, err)
+				}
+			}
+
+			got, err := HasGem(path, tc.gem)
+			if err != nil {
+				t.Fatalf("HasGem(%q, %q) got error: %v", path, tc.gem, err)
+			}
+			if got != tc.want {
+				t.Errorf("HasGem(%q, %q) = %t, want %t", path, tc.gem, got, tc.want)
+			}
+		})
+	}
+}
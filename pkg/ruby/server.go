@@ -0,0 +1,106 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ruby
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// Application servers supported without an explicit entrypoint.
+const (
+	Puma    = "puma"
+	Unicorn = "unicorn"
+	Falcon  = "falcon"
+)
+
+// serverConfigs are the configuration files of each application server, in order of precedence.
+var serverConfigs = []struct {
+	server string
+	files  []string
+}{
+	{Puma, []string{"config/puma.rb", "puma.rb"}},
+	{Unicorn, []string{"config/unicorn.rb", "unicorn.rb"}},
+	{Falcon, []string{"falcon.rb"}},
+}
+
+// Server is an application server used to start the web process.
+type Server struct {
+	// Name is the name of the server, e.g. "puma".
+	Name string
+	// Config is the configuration file of the server relative to the application root, if any.
+	Config string
+}
+
+// DetectServer returns the application server selected with GOOGLE_RUBY_SERVER or the one whose
+// configuration file is in the application, or nil if there is none.
+func DetectServer(ctx *gcp.Context) (*Server, error) {
+	name := strings.ToLower(strings.TrimSpace(os.Getenv(env.RubyServer)))
+	switch name {
+	case "", Puma, Unicorn, Falcon:
+	default:
+		return nil, gcp.UserErrorf("invalid %s %q, must be one of %q, %q or %q", env.RubyServer, os.Getenv(env.RubyServer), Puma, Unicorn, Falcon)
+	}
+	for _, sc := range serverConfigs {
+		if name != "" && name != sc.server {
+			continue
+		}
+		for _, f := range sc.files {
+			exists, err := ctx.FileExists(ctx.ApplicationRoot(), f)
+			if err != nil {
+				return nil, err
+			}
+			if exists {
+				return &Server{Name: sc.server, Config: f}, nil
+			}
+		}
+	}
+	if name != "" {
+		return &Server{Name: name}, nil
+	}
+	return nil, nil
+}
+
+// Command returns the shell command that starts the server on $PORT. Puma runs in cluster mode
+// when its configuration file, or WEB_CONCURRENCY without one, sets the number of workers.
+func (s *Server) Command() string {
+	var cmd string
+	switch s.Name {
+	case Puma:
+		cmd = "puma --port $PORT"
+		if s.Config != "" {
+			cmd += " --config " + s.Config
+		} else {
+			cmd += " --workers ${WEB_CONCURRENCY:-0}"
+		}
+	case Unicorn:
+		cmd = "unicorn --port $PORT"
+		if s.Config != "" {
+			cmd += " --config-file " + s.Config
+		}
+	case Falcon:
+		if s.Config != "" {
+			// falcon.rb defines the services to host, including their endpoints.
+			cmd = "falcon host " + s.Config
+		} else {
+			cmd = "falcon serve --bind http://0.0.0.0:$PORT"
+		}
+	}
+	return fmt.Sprintf("bundle exec %s", cmd)
+}
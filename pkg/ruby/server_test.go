@@ -0,0 +1,128 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ruby
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDetectServer(t *testing.T) {
+	testCases := []struct {
+		name    string
+		files   []string
+		env     string
+		want    *Server
+		wantErr bool
+	}{
+		{
+			name: "no server",
+		},
+		{
+			name:  "puma config",
+			files: []string{"config/puma.rb"},
+			want:  &Server{Name: Puma, Config: "config/puma.rb"},
+		},
+		{
+			name:  "unicorn config at root",
+			files: []string{"unicorn.rb"},
+			want:  &Server{Name: Unicorn, Config: "unicorn.rb"},
+		},
+		{
+			name:  "falcon config",
+			files: []string{"falcon.rb"},
+			want:  &Server{Name: Falcon, Config: "falcon.rb"},
+		},
+		{
+			name: "env without config",
+			env:  "Puma",
+			want: &Server{Name: Puma},
+		},
+		{
+			name:  "env selects among configs",
+			files: []string{"config/puma.rb", "config/unicorn.rb"},
+			env:   "unicorn",
+			want:  &Server{Name: Unicorn, Config: "config/unicorn.rb"},
+		},
+		{
+			name:    "invalid env",
+			env:     "webrick",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("GOOGLE_RUBY_SERVER", tc.env)
+			dir := t.TempDir()
+			for _, f := range tc.files {
+				path := filepath.Join(dir, f)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("creating directory: %v", err)
+				}
+				if err := os.WriteFile(path, nil, 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+
+			got, err := DetectServer(ctx)
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("DetectServer() got error: %v, want error? %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("DetectServer() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestServerCommand(t *testing.T) {
+	testCases := []struct {
+		server Server
+		want   string
+	}{
+		{
+			server: Server{Name: Puma, Config: "config/puma.rb"},
+			want:   "bundle exec puma --port $PORT --config config/puma.rb",
+		},
+		{
+			server: Server{Name: Puma},
+			want:   "bundle exec puma --port $PORT --workers ${WEB_CONCURRENCY:-0}",
+		},
+		{
+			server: Server{Name: Unicorn, Config: "config/unicorn.rb"},
+			want:   "bundle exec unicorn --port $PORT --config-file config/unicorn.rb",
+		},
+		{
+			server: Server{Name: Falcon, Config: "falcon.rb"},
+			want:   "bundle exec falcon host falcon.rb",
+		},
+		{
+			server: Server{Name: Falcon},
+			want:   "bundle exec falcon serve --bind http://0.0.0.0:$PORT",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.want, func(t *testing.T) {
+			if got := tc.server.Command(); got != tc.want {
+				t.Errorf("Command() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
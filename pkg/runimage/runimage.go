@@ -0,0 +1,203 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package runimage selects the run image variant of the application image and validates that
+// applications built for the minimal variant do not depend on system packages.
+package runimage
+
+import (
+	"debug/elf"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// Default is the run image variant of the stack, it is used when GOOGLE_RUN_IMAGE_VARIANT is not set.
+	Default = "default"
+	// Min is the distroless run image variant. It contains the C runtime libraries, CA certificates
+	// and time zone data but no shell or package manager.
+	Min = "min"
+)
+
+// minLibraries are the shared libraries provided by the minimal run image, see
+// stacks/google_22/run-min.Dockerfile.
+var minLibraries = map[string]bool{
+	"ld-linux-aarch64.so.1": true,
+	"ld-linux-x86-64.so.2":  true,
+	"libc.so.6":             true,
+	"libcrypto.so.3":        true,
+	"libdl.so.2":            true,
+	"libgcc_s.so.1":         true,
+	"libm.so.6":             true,
+	"libnss_dns.so.2":       true,
+	"libnss_files.so.2":     true,
+	"libpthread.so.0":       true,
+	"libresolv.so.2":        true,
+	"librt.so.1":            true,
+	"libssl.so.3":           true,
+	"libstdc++.so.6":        true,
+	"libutil.so.1":          true,
+	"libz.so.1":             true,
+}
+
+// Variant returns the requested run image variant, from GOOGLE_RUN_IMAGE_VARIANT.
+func Variant() (string, error) {
+	v := strings.ToLower(strings.TrimSpace(os.Getenv(env.RunImageVariant)))
+	switch v {
+	case "", Default:
+		return Default, nil
+	case Min:
+		return Min, nil
+	}
+	return "", gcp.UserErrorf("invalid %s %q, must be one of %q or %q", env.RunImageVariant, v, Default, Min)
+}
+
+// IsMin returns true if the application image is built for the minimal run image variant.
+func IsMin() (bool, error) {
+	v, err := Variant()
+	if err != nil {
+		return false, err
+	}
+	return v == Min, nil
+}
+
+// CheckDynamicDeps returns a user error if any of the given ELF executables or shared libraries
+// require a shared library that the minimal run image does not provide. Libraries found in the
+// RPATH or RUNPATH of a file, e.g. the libraries bundled with a JDK, are checked recursively.
+func CheckDynamicDeps(paths ...string) error {
+	seen := map[string]bool{}
+	missing := map[string][]string{}
+	for _, p := range paths {
+		if err := checkFile(p, seen, missing); err != nil {
+			return err
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	var msgs []string
+	for lib, users := range missing {
+		msgs = append(msgs, fmt.Sprintf("%s (required by %s)", lib, strings.Join(users, ", ")))
+	}
+	sort.Strings(msgs)
+	return gcp.UserErrorf("the following shared libraries are not available in the %s=%s run image: %s. Link them statically or use the %s run image variant", env.RunImageVariant, Min, strings.Join(msgs, "; "), Default)
+}
+
+func checkFile(path string, seen map[string]bool, missing map[string][]string) error {
+	if seen[path] {
+		return nil
+	}
+	seen[path] = true
+	deps, err := readDynamicDeps(path)
+	if err != nil {
+		return err
+	}
+	bundled, unavailable := deps.resolve(filepath.Dir(path), fileExists)
+	for _, lib := range unavailable {
+		missing[lib] = append(missing[lib], path)
+	}
+	for _, lib := range bundled {
+		if err := checkFile(lib, seen, missing); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dynamicDeps describes how an ELF file is dynamically linked.
+type dynamicDeps struct {
+	// interpreter is the program interpreter (dynamic loader) of an executable.
+	interpreter string
+	// needed are the DT_NEEDED shared libraries of the file.
+	needed []string
+	// searchPaths are the RPATH and RUNPATH directories of the file.
+	searchPaths []string
+}
+
+func readDynamicDeps(path string) (dynamicDeps, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		if _, ok := err.(*elf.FormatError); ok {
+			return dynamicDeps{}, gcp.UserErrorf("%s is not an ELF executable, %s=%s does not provide a shell or an interpreter to run it", path, env.RunImageVariant, Min)
+		}
+		return dynamicDeps{}, gcp.InternalErrorf("reading %s: %v", path, err)
+	}
+	defer f.Close()
+	var deps dynamicDeps
+	for _, p := range f.Progs {
+		if p.Type != elf.PT_INTERP {
+			continue
+		}
+		b := make([]byte, p.Filesz)
+		if _, err := p.ReadAt(b, 0); err != nil {
+			return dynamicDeps{}, gcp.InternalErrorf("reading program interpreter of %s: %v", path, err)
+		}
+		deps.interpreter = strings.TrimRight(string(b), "\x00")
+	}
+	// Statically linked files have no dynamic section.
+	if f.Section(".dynamic") == nil {
+		return deps, nil
+	}
+	if deps.needed, err = f.ImportedLibraries(); err != nil {
+		return dynamicDeps{}, gcp.InternalErrorf("reading shared libraries of %s: %v", path, err)
+	}
+	for _, tag := range []elf.DynTag{elf.DT_RPATH, elf.DT_RUNPATH} {
+		paths, err := f.DynString(tag)
+		if err != nil {
+			return dynamicDeps{}, gcp.InternalErrorf("reading %v of %s: %v", tag, path, err)
+		}
+		for _, p := range paths {
+			deps.searchPaths = append(deps.searchPaths, strings.Split(p, ":")...)
+		}
+	}
+	return deps, nil
+}
+
+// resolve returns the paths of the needed libraries found in the search paths of the file, with
+// $ORIGIN replaced by origin, and the names of the needed libraries that cannot be found there or
+// in the minimal run image.
+func (d dynamicDeps) resolve(origin string, exists func(string) bool) (bundled, unavailable []string) {
+	if d.interpreter != "" && !minLibraries[filepath.Base(d.interpreter)] {
+		unavailable = append(unavailable, d.interpreter)
+	}
+	for _, lib := range d.needed {
+		if minLibraries[lib] {
+			continue
+		}
+		found := false
+		for _, dir := range d.searchPaths {
+			dir = strings.NewReplacer("${ORIGIN}", origin, "$ORIGIN", origin).Replace(dir)
+			if p := filepath.Join(dir, lib); exists(p) {
+				bundled = append(bundled, p)
+				found = true
+				break
+			}
+		}
+		if !found {
+			unavailable = append(unavailable, lib)
+		}
+	}
+	return bundled, unavailable
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
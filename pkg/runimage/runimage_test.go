@@ -0,0 +1,160 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runimage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestVariant(t *testing.T) {
+	testCases := []struct {
+		name    string
+		env     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "not set",
+			want: Default,
+		},
+		{
+			name: "default",
+			env:  "default",
+			want: Default,
+		},
+		{
+			name: "min",
+			env:  " MIN ",
+			want: Min,
+		},
+		{
+			name:    "invalid",
+			env:     "distroless",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(env.RunImageVariant, tc.env)
+
+			got, err := Variant()
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("Variant() got error: %v, want error? %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("Variant() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	testCases := []struct {
+		name            string
+		deps            dynamicDeps
+		files           []string
+		wantBundled     []string
+		wantUnavailable []string
+	}{
+		{
+			name: "static",
+		},
+		{
+			name: "system libraries",
+			deps: dynamicDeps{
+				interpreter: "/lib64/ld-linux-x86-64.so.2",
+				needed:      []string{"libc.so.6", "libpthread.so.0", "libstdc++.so.6"},
+			},
+		},
+		{
+			name: "missing libraries",
+			deps: dynamicDeps{
+				interpreter: "/lib64/ld-linux-x86-64.so.2",
+				needed:      []string{"libc.so.6", "libicuuc.so.70", "libpq.so.5"},
+			},
+			wantUnavailable: []string{"libicuuc.so.70", "libpq.so.5"},
+		},
+		{
+			name: "musl interpreter",
+			deps: dynamicDeps{
+				interpreter: "/lib/ld-musl-x86_64.so.1",
+			},
+			wantUnavailable: []string{"/lib/ld-musl-x86_64.so.1"},
+		},
+		{
+			name: "bundled with origin",
+			deps: dynamicDeps{
+				needed:      []string{"libc.so.6", "libjli.so"},
+				searchPaths: []string{"$ORIGIN/../lib"},
+			},
+			files:       []string{"/jdk/lib/libjli.so"},
+			wantBundled: []string{"/jdk/lib/libjli.so"},
+		},
+		{
+			name: "bundled with braced origin",
+			deps: dynamicDeps{
+				needed:      []string{"libjli.so", "libfoo.so"},
+				searchPaths: []string{"/opt/lib", "${ORIGIN}/../lib"},
+			},
+			files:           []string{"/jdk/lib/libjli.so"},
+			wantBundled:     []string{"/jdk/lib/libjli.so"},
+			wantUnavailable: []string{"libfoo.so"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			exists := func(p string) bool {
+				for _, f := range tc.files {
+					if filepath.Clean(p) == f {
+						return true
+					}
+				}
+				return false
+			}
+
+			bundled, unavailable := tc.deps.resolve("/jdk/bin", exists)
+			if diff := cmp.Diff(tc.wantBundled, bundled); diff != "" {
+				t.Errorf("resolve() bundled mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantUnavailable, unavailable); diff != "" {
+				t.Errorf("resolve() unavailable mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCheckDynamicDeps(t *testing.T) {
+	// Test binaries only depend on the C runtime libraries, if they are dynamically linked at all.
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("os.Executable() got error: %v", err)
+	}
+	if err := CheckDynamicDeps(exe); err != nil {
+		t.Errorf("CheckDynamicDeps(%q) got error: %v", exe, err)
+	}
+
+	script := filepath.Join(t.TempDir(), "start.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\nexec ./app\n"), 0755); err != nil {
+		t.Fatalf("writing %s: %v", script, err)
+	}
+	if err := CheckDynamicDeps(exe, script); err == nil {
+		t.Errorf("CheckDynamicDeps(%q) got no error, want error for a shell script", script)
+	}
+}
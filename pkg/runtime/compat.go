@@ -0,0 +1,126 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/Masterminds/semver"
+)
+
+// osLibraries are the versions of the system libraries of an OS that runtimes link against.
+type osLibraries struct {
+	glibc   string
+	openSSL string
+}
+
+var libraries = map[string]osLibraries{
+	ubuntu1804: {glibc: "2.27", openSSL: "1.1.1"},
+	ubuntu2204: {glibc: "2.35", openSSL: "3.0.2"},
+	ubuntu2404: {glibc: "2.39", openSSL: "3.0.13"},
+}
+
+// compatibilityIssue is a known incompatibility between versions of a runtime and the system
+// libraries of some stacks.
+type compatibilityIssue struct {
+	runtime InstallableRuntime
+	// versions is the semver constraint of the affected runtime versions.
+	versions string
+	// minGlibc is the oldest glibc that the runtime versions run on, if the issue is about glibc.
+	minGlibc string
+	// maxOpenSSLMajor is the newest major version of OpenSSL that the runtime versions support, if
+	// the issue is about OpenSSL.
+	maxOpenSSLMajor int64
+	message         string
+}
+
+var compatibilityIssues = []compatibilityIssue{
+	{
+		runtime:  Nodejs,
+		versions: ">= 18.0.0",
+		minGlibc: "2.28",
+		message:  "Node.js 18 and later require glibc 2.28 or later. Use a builder based on a newer stack.",
+	},
+	{
+		runtime:         Ruby,
+		versions:        "< 3.1.0",
+		maxOpenSSLMajor: 1,
+		message:         "Ruby versions before 3.1 do not support OpenSSL 3, gems that use the openssl extension fail to load. Upgrade to Ruby 3.1 or later.",
+	},
+	{
+		runtime:         PHP,
+		versions:        "< 8.1.0",
+		maxOpenSSLMajor: 1,
+		message:         "PHP versions before 8.1 do not support OpenSSL 3, the openssl extension is not available. Upgrade to PHP 8.1 or later.",
+	},
+	{
+		runtime:         DotnetSDK,
+		versions:        "< 6.0.0",
+		maxOpenSSLMajor: 1,
+		message:         ".NET versions before 6 do not support OpenSSL 3, HTTPS requests and cryptography APIs fail at runtime. Upgrade to .NET 6 or later.",
+	},
+}
+
+// WithStackCompatibilityCheck wraps the detect function of a runtime buildpack to warn about known
+// incompatibilities between the runtime version set in GOOGLE_RUNTIME_VERSION and the system
+// libraries of the stack, e.g. when migrating to a stack based on a newer Ubuntu release. The
+// check runs only if the buildpack opts in, it does not fail detection.
+func WithStackCompatibilityCheck(runtime InstallableRuntime, detectFn gcp.DetectFn) gcp.DetectFn {
+	return func(ctx *gcp.Context) (gcp.DetectResult, error) {
+		result, err := detectFn(ctx)
+		if err != nil || result == nil || !result.Result().Pass {
+			return result, err
+		}
+		version := strings.TrimSpace(os.Getenv(env.RuntimeVersion))
+		for _, msg := range stackCompatibilityIssues(ctx.StackID(), runtime, version) {
+			ctx.Warnf("%s", msg)
+		}
+		return result, nil
+	}
+}
+
+// stackCompatibilityIssues returns the messages of the compatibility issues of a runtime version on
+// the stack. Versions that are not semver, e.g. constraints like "3.x", are not checked.
+func stackCompatibilityIssues(stackID string, runtime InstallableRuntime, version string) []string {
+	libs, ok := libraries[stackToOS[stackID]]
+	if !ok || version == "" {
+		return nil
+	}
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return nil
+	}
+	glibc := semver.MustParse(libs.glibc)
+	openSSL := semver.MustParse(libs.openSSL)
+	var msgs []string
+	for _, issue := range compatibilityIssues {
+		if issue.runtime != runtime {
+			continue
+		}
+		if c, err := semver.NewConstraint(issue.versions); err != nil || !c.Check(v) {
+			continue
+		}
+		affected := (issue.minGlibc != "" && glibc.LessThan(semver.MustParse(issue.minGlibc))) ||
+			(issue.maxOpenSSLMajor != 0 && openSSL.Major() > issue.maxOpenSSLMajor)
+		if affected {
+			msgs = append(msgs, fmt.Sprintf("%s %s may not work on the stack %q, which is based on %s with glibc %s and OpenSSL %s: %s", runtimeNames[runtime], version, stackID, stackToOS[stackID], libs.glibc, libs.openSSL, issue.message))
+		}
+	}
+	return msgs
+}
@@ -0,0 +1,144 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestStackCompatibilityIssues(t *testing.T) {
+	testCases := []struct {
+		name    string
+		stackID string
+		runtime InstallableRuntime
+		version string
+		want    string
+	}{
+		{
+			name:    "ruby without OpenSSL 3 support",
+			stackID: "google.24",
+			runtime: Ruby,
+			version: "3.0.6",
+			want:    "Ruby versions before 3.1 do not support OpenSSL 3",
+		},
+		{
+			name:    "ruby with OpenSSL 3 support",
+			stackID: "google.24",
+			runtime: Ruby,
+			version: "3.2.2",
+		},
+		{
+			name:    "ruby on stack with OpenSSL 1.1",
+			stackID: "google",
+			runtime: Ruby,
+			version: "2.7.8",
+		},
+		{
+			name:    "dotnet without OpenSSL 3 support",
+			stackID: "google.22",
+			runtime: DotnetSDK,
+			version: "5.0.408",
+			want:    ".NET versions before 6 do not support OpenSSL 3",
+		},
+		{
+			name:    "nodejs on old glibc",
+			stackID: "google",
+			runtime: Nodejs,
+			version: "18.18.0",
+			want:    "Node.js 18 and later require glibc 2.28",
+		},
+		{
+			name:    "nodejs on new glibc",
+			stackID: "google.24",
+			runtime: Nodejs,
+			version: "20.9.0",
+		},
+		{
+			name:    "version constraint is not checked",
+			stackID: "google.24",
+			runtime: PHP,
+			version: "8.0.x",
+		},
+		{
+			name:    "no version",
+			stackID: "google.24",
+			runtime: PHP,
+		},
+		{
+			name:    "unknown stack",
+			stackID: "unknown",
+			runtime: Ruby,
+			version: "2.7.8",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := stackCompatibilityIssues(tc.stackID, tc.runtime, tc.version)
+
+			if tc.want == "" {
+				if len(got) != 0 {
+					t.Errorf("stackCompatibilityIssues(%q, %q, %q) = %q, want none", tc.stackID, tc.runtime, tc.version, got)
+				}
+				return
+			}
+			if len(got) != 1 || !strings.Contains(got[0], tc.want) {
+				t.Errorf("stackCompatibilityIssues(%q, %q, %q) = %q, want one issue containing %q", tc.stackID, tc.runtime, tc.version, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWithStackCompatibilityCheck(t *testing.T) {
+	testCases := []struct {
+		name     string
+		result   gcp.DetectResult
+		wantWarn bool
+	}{
+		{
+			name:     "opt in",
+			result:   gcp.OptIn("found Gemfile"),
+			wantWarn: true,
+		},
+		{
+			name:   "opt out",
+			result: gcp.OptOut("no .rb files found"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("GOOGLE_RUNTIME_VERSION", "3.0.6")
+			var out bytes.Buffer
+			ctx := gcp.NewContext(gcp.WithStackID("google.24"), gcp.WithOutput(&out))
+			detectFn := WithStackCompatibilityCheck(Ruby, func(*gcp.Context) (gcp.DetectResult, error) {
+				return tc.result, nil
+			})
+
+			got, err := detectFn(ctx)
+			if err != nil {
+				t.Fatalf("detectFn() got error: %v", err)
+			}
+			if got != tc.result {
+				t.Errorf("detectFn() = %v, want %v", got, tc.result)
+			}
+			if warned := strings.Contains(out.String(), "WARNING: Ruby Runtime 3.0.6 may not work"); warned != tc.wantWarn {
+				t.Errorf("detectFn() warned = %t, want %t, output:\n%s", warned, tc.wantWarn, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"time"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/Masterminds/semver"
+)
+
+// release is a release line of a runtime or an operating system and the date on which it stops
+// receiving security updates upstream.
+type release struct {
+	// version is the first version of the release line for runtimes, or the OS.
+	version string
+	// endOfLife is the date in the format YYYY-MM-DD.
+	endOfLife string
+}
+
+// runtimeReleases are the release lines of each runtime, oldest first. Versions older than the
+// oldest line that has not reached its end of life are deprecated. New release lines must be added
+// here as they are released, otherwise a warning is logged once all the listed lines have reached
+// their end of life.
+var runtimeReleases = map[InstallableRuntime][]release{
+	Nodejs: {
+		{"18.0.0", "2025-04-30"},
+		{"20.0.0", "2026-04-30"},
+		{"22.0.0", "2027-04-30"},
+		{"24.0.0", "2028-04-30"},
+	},
+	PHP: {
+		{"8.1.0", "2025-12-31"},
+		{"8.2.0", "2026-12-31"},
+		{"8.3.0", "2027-12-31"},
+		{"8.4.0", "2028-12-31"},
+	},
+	Python: {
+		{"3.8.0", "2024-10-07"},
+		{"3.9.0", "2025-10-31"},
+		{"3.10.0", "2026-10-31"},
+		{"3.11.0", "2027-10-31"},
+		{"3.12.0", "2028-10-31"},
+		{"3.13.0", "2029-10-31"},
+	},
+	Ruby: {
+		{"3.0.0", "2024-04-23"},
+		{"3.1.0", "2025-03-26"},
+		{"3.2.0", "2026-03-31"},
+		{"3.3.0", "2027-03-31"},
+		{"3.4.0", "2028-03-31"},
+	},
+}
+
+// osReleases are the operating systems of the stacks, oldest first, with the end of their standard
+// security updates.
+var osReleases = []release{
+	{ubuntu1804, "2023-05-31"},
+	{ubuntu2204, "2027-06-01"},
+	{ubuntu2404, "2029-05-31"},
+}
+
+// eolTime returns the time against which end of life dates are compared. It is a variable so that
+// tests do not depend on the current date.
+var eolTime = time.Now
+
+// firstSupported returns the version of the oldest release that has not reached its end of life at
+// now, or "" if all of them have.
+func firstSupported(releases []release, now time.Time) (string, error) {
+	for _, r := range releases {
+		eol, err := time.Parse("2006-01-02", r.endOfLife)
+		if err != nil {
+			return "", fmt.Errorf("parsing the end of life date of %s: %v", r.version, err)
+		}
+		if now.Before(eol) {
+			return r.version, nil
+		}
+	}
+	return "", nil
+}
+
+// checkEndOfLife warns if the runtime version or the operating system of the stack has reached its
+// end of life. The warnings fail the build if GOOGLE_STRICT_BUILD enables them. The check is
+// skipped with a warning if the end of life dates cannot be parsed.
+func checkEndOfLife(ctx *gcp.Context, runtime InstallableRuntime, version string) error {
+	now := eolTime()
+	if err := checkOSEndOfLife(ctx, now); err != nil {
+		return err
+	}
+	releases, ok := runtimeReleases[runtime]
+	if !ok {
+		return nil
+	}
+	min, err := firstSupported(releases, now)
+	if err != nil {
+		ctx.Warnf("Skipping the end of life check of %s: %v", runtimeNames[runtime], err)
+		return nil
+	}
+	if min == "" {
+		ctx.Warnf("Unable to check whether %s %s has reached its end of life, all the release lines known to this builder have. Use a newer builder.", runtimeNames[runtime], version)
+		return nil
+	}
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		// Versions that are not semver, e.g. pre-releases of some runtimes, are not checked.
+		return nil
+	}
+	if v.LessThan(semver.MustParse(min)) {
+		return ctx.StrictWarnf(gcp.WarningDeprecatedRuntime, "%s %s has reached its end of life and no longer receives security updates. Upgrade to %s or later.", runtimeNames[runtime], version, min)
+	}
+	return nil
+}
+
+// checkOSEndOfLife warns if the operating system of the stack has reached its end of life.
+func checkOSEndOfLife(ctx *gcp.Context, now time.Time) error {
+	os := stackToOS[ctx.StackID()]
+	if os == "" {
+		return nil
+	}
+	supported, err := firstSupported(osReleases, now)
+	if err != nil {
+		ctx.Warnf("Skipping the end of life check of the stack: %v", err)
+		return nil
+	}
+	if supported == "" {
+		return ctx.StrictWarnf(gcp.WarningEOLBaseImage, "The stack %q is based on %s, which has reached its end of life. Use a newer builder.", ctx.StackID(), os)
+	}
+	if osEndOfLife(os, supported) {
+		return ctx.StrictWarnf(gcp.WarningEOLBaseImage, "The stack %q is based on %s, which has reached its end of life. Use a builder based on %s.", ctx.StackID(), os, supported)
+	}
+	return nil
+}
+
+// osEndOfLife returns true if os is released before supported, the oldest OS that has not reached
+// its end of life.
+func osEndOfLife(os, supported string) bool {
+	for _, r := range osReleases {
+		if r.version == supported {
+			return false
+		}
+		if r.version == os {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,211 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/Masterminds/semver"
+)
+
+func TestCheckEndOfLife(t *testing.T) {
+	testCases := []struct {
+		name    string
+		runtime InstallableRuntime
+		version string
+		stackID string
+		strict  string
+		now     string
+		// releases replaces the release lines of the runtime.
+		releases    []release
+		wantErr     bool
+		wantWarning string
+	}{
+		{
+			name:    "supported version",
+			runtime: Nodejs,
+			version: "22.11.0",
+			stackID: "google.22",
+			strict:  "true",
+		},
+		{
+			name:    "end of life version",
+			runtime: Nodejs,
+			version: "16.20.2",
+			stackID: "google.22",
+		},
+		{
+			name:    "version that reached its end of life",
+			runtime: Nodejs,
+			version: "20.9.0",
+			stackID: "google.22",
+			strict:  "deprecated-runtime",
+			wantErr: true,
+		},
+		{
+			name:    "version before its end of life",
+			runtime: Nodejs,
+			version: "20.9.0",
+			stackID: "google.22",
+			strict:  "deprecated-runtime",
+			now:     "2026-04-29",
+		},
+		{
+			name:    "version newer than the listed releases",
+			runtime: Python,
+			version: "3.14.0",
+			stackID: "google.24",
+			strict:  "true",
+		},
+		{
+			name:        "all listed releases reached their end of life",
+			runtime:     Ruby,
+			version:     "2.7.8",
+			stackID:     "google.24",
+			strict:      "deprecated-runtime",
+			now:         "2030-01-01",
+			wantWarning: "all the release lines known to this builder have",
+		},
+		{
+			name:        "all listed stacks reached their end of life",
+			runtime:     Ruby,
+			version:     "3.4.1",
+			stackID:     "google.24",
+			strict:      "eol-base-image",
+			now:         "2030-01-01",
+			wantErr:     true,
+			wantWarning: "Use a newer builder",
+		},
+		{
+			name:        "malformed end of life date",
+			runtime:     Nodejs,
+			version:     "16.20.2",
+			stackID:     "google.22",
+			strict:      "deprecated-runtime",
+			releases:    []release{{"18.0.0", "2025-04-31"}, {"20.0.0", "2026-04-30"}},
+			wantWarning: "Skipping the end of life check of Node.js",
+		},
+		{
+			name:    "end of life version in strict build",
+			runtime: Python,
+			version: "3.7.17",
+			stackID: "google.22",
+			strict:  "deprecated-runtime",
+			wantErr: true,
+		},
+		{
+			name:    "end of life stack in strict build",
+			runtime: Nodejs,
+			version: "22.11.0",
+			stackID: "google",
+			strict:  "eol-base-image",
+			wantErr: true,
+		},
+		{
+			name:    "stack that reached its end of life in strict build",
+			runtime: Nodejs,
+			version: "24.11.0",
+			stackID: "google.22",
+			strict:  "eol-base-image",
+			now:     "2027-07-01",
+			wantErr: true,
+		},
+		{
+			name:    "end of life stack not selected",
+			runtime: Ruby,
+			version: "2.7.8",
+			stackID: "google",
+			strict:  "npm-audit-critical",
+		},
+		{
+			name:    "runtime without end of life versions",
+			runtime: Nginx,
+			version: "1.0.0",
+			stackID: "google.22",
+			strict:  "true",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("GOOGLE_STRICT_BUILD", tc.strict)
+			now := tc.now
+			if now == "" {
+				now = "2026-10-16"
+			}
+			defer func(f func() time.Time) { eolTime = f }(eolTime)
+			eolTime = func() time.Time {
+				tm, err := time.Parse("2006-01-02", now)
+				if err != nil {
+					t.Fatalf("parsing %q: %v", now, err)
+				}
+				return tm
+			}
+			if tc.releases != nil {
+				defer func(r []release) { runtimeReleases[tc.runtime] = r }(runtimeReleases[tc.runtime])
+				runtimeReleases[tc.runtime] = tc.releases
+			}
+			var logs bytes.Buffer
+			ctx := gcp.NewContext(gcp.WithStackID(tc.stackID), gcp.WithLogger(log.New(&logs, "", 0)))
+
+			err := checkEndOfLife(ctx, tc.runtime, tc.version)
+			if tc.wantErr == (err == nil) {
+				t.Errorf("checkEndOfLife(%q, %q) got error: %v, want error? %t", tc.runtime, tc.version, err, tc.wantErr)
+			}
+			// Strict warnings are returned as errors instead of being logged.
+			got := logs.String()
+			if err != nil {
+				got += err.Error()
+			}
+			if !strings.Contains(got, tc.wantWarning) {
+				t.Errorf("checkEndOfLife(%q, %q) warned %q, want it to contain %q", tc.runtime, tc.version, got, tc.wantWarning)
+			}
+		})
+	}
+}
+
+func TestReleaseDates(t *testing.T) {
+	tables := map[string][]release{"OS": osReleases}
+	for runtime, releases := range runtimeReleases {
+		tables[string(runtime)] = releases
+	}
+	for name, releases := range tables {
+		var prev time.Time
+		for _, r := range releases {
+			eol, err := time.Parse("2006-01-02", r.endOfLife)
+			if err != nil {
+				t.Errorf("end of life of %s %s: %v", name, r.version, err)
+				continue
+			}
+			if eol.Before(prev) {
+				t.Errorf("end of life of %s %s is %s, want the releases oldest first", name, r.version, r.endOfLife)
+			}
+			prev = eol
+			if name == "OS" {
+				continue
+			}
+			if _, err := semver.NewVersion(r.version); err != nil {
+				t.Errorf("version of %s %s: %v", name, r.version, err)
+			}
+		}
+	}
+	if _, err := firstSupported([]release{{"1.0.0", "2024-13-01"}}, time.Now()); err == nil {
+		t.Error("firstSupported() got no error, want error for a malformed date")
+	}
+}
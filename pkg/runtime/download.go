@@ -0,0 +1,149 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/fetch"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// googleRuntimesURL is the URL prefix of runtimes hosted on dl.google.com, it is replaced by
+// the value of GOOGLE_RUNTIME_MIRROR if set.
+const googleRuntimesURL = "https://dl.google.com/runtimes"
+
+var (
+	// The checksum files contain the hex-encoded SHA-256 checksum of the archive, optionally
+	// followed by its file name as produced by sha256sum.
+	googleTarballChecksumURL = "https://dl.google.com/runtimes/%s/%[2]s/%[2]s-%s.tar.gz.sha256"
+	dartSdkChecksumURL       = "https://storage.googleapis.com/dart-archive/channels/stable/release/%s/sdk/dartsdk-linux-%s-release.zip.sha256sum"
+
+	sha256Regexp = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+)
+
+// archive describes a runtime archive to download.
+type archive struct {
+	// url is the URL of the archive.
+	url string
+	// checksumURL is the URL of the checksum file of the archive. The download fails if the
+	// checksum file does not exist, unless GOOGLE_RUNTIME_SKIP_CHECKSUM is set.
+	checksumURL string
+}
+
+// mirrorURL returns the URL from which the given runtime URL is downloaded.
+func mirrorURL(url string) string {
+	mirror := strings.TrimSuffix(os.Getenv(env.RuntimeMirror), "/")
+	if mirror == "" || !strings.HasPrefix(url, googleRuntimesURL+"/") {
+		return url
+	}
+	return mirror + strings.TrimPrefix(url, googleRuntimesURL)
+}
+
+// download downloads a runtime archive to the given path, verifying its checksum and its
+// signature if GOOGLE_RUNTIME_SIGNING_KEY is set.
+func download(ctx *gcp.Context, a archive, path string) error {
+	url := mirrorURL(a.url)
+	checksumURL := mirrorURL(a.checksumURL)
+	checksum, err := publishedChecksum(ctx, checksumURL)
+	if err != nil {
+		return err
+	}
+	if checksum == "" {
+		skip, err := env.IsPresentAndTrue(env.RuntimeSkipChecksum)
+		if err != nil {
+			return err
+		}
+		if !skip {
+			return gcp.UserErrorf("no checksum published for %s at %q, set %s=true to install it without verifying its checksum", url, checksumURL, env.RuntimeSkipChecksum)
+		}
+		ctx.Warnf("No checksum published for %s, installing it without verifying its checksum because %s is set.", url, env.RuntimeSkipChecksum)
+	}
+	if err := fetch.File(url, path, checksum); err != nil {
+		return err
+	}
+	return verifySignature(ctx, url, path)
+}
+
+// DownloadTarball downloads a gzipped runtime tarball and extracts it into the given directory.
+// The tarball is verified against the checksum file at checksumURL, which must exist unless
+// GOOGLE_RUNTIME_SKIP_CHECKSUM is set, and against its signature if GOOGLE_RUNTIME_SIGNING_KEY is set.
+func DownloadTarball(ctx *gcp.Context, url, checksumURL, dir string, stripComponents int) error {
+	return downloadTarball(ctx, archive{url: url, checksumURL: checksumURL}, dir, stripComponents)
+}
+
+func downloadTarball(ctx *gcp.Context, a archive, dir string, stripComponents int) error {
+	f, err := ioutil.TempFile("", "runtime-*.tar.gz")
+	if err != nil {
+		return gcp.InternalErrorf("creating temporary file: %v", err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	if err := download(ctx, a, f.Name()); err != nil {
+		return err
+	}
+	return fetch.ExtractTarball(f.Name(), dir, stripComponents)
+}
+
+// publishedChecksum returns the hex-encoded SHA-256 checksum contained in the checksum file at
+// the given URL, or an empty string if there is no such file.
+func publishedChecksum(ctx *gcp.Context, url string) (string, error) {
+	if url == "" {
+		return "", nil
+	}
+	status, err := ctx.HTTPStatus(url)
+	if err != nil {
+		return "", err
+	}
+	if status == http.StatusNotFound || status == http.StatusForbidden {
+		return "", nil
+	}
+	var buf bytes.Buffer
+	if err := fetch.GetURL(url, &buf); err != nil {
+		return "", err
+	}
+	fields := strings.Fields(buf.String())
+	if len(fields) == 0 || !sha256Regexp.MatchString(fields[0]) {
+		return "", gcp.InternalErrorf("invalid checksum file %s: want a hex-encoded SHA-256 checksum, got %q", url, buf.String())
+	}
+	return fields[0], nil
+}
+
+// verifySignature verifies the Sigstore signature of the archive downloaded from url to path if
+// GOOGLE_RUNTIME_SIGNING_KEY is set. The signature is downloaded from <url>.sig.
+func verifySignature(ctx *gcp.Context, url, path string) error {
+	key := os.Getenv(env.RuntimeSigningKey)
+	if key == "" {
+		return nil
+	}
+	sig := filepath.Join(filepath.Dir(path), filepath.Base(path)+".sig")
+	if err := fetch.File(url+".sig", sig, ""); err != nil {
+		return fmt.Errorf("downloading signature of %s, which is required when %s is set: %w", url, env.RuntimeSigningKey, err)
+	}
+	defer os.Remove(sig)
+	if _, err := ctx.Exec([]string{"cosign", "verify-blob", "--key", key, "--signature", sig, path}, gcp.WithUserAttribution); err != nil {
+		return fmt.Errorf("verifying signature of %s with cosign: %w", url, err)
+	}
+	ctx.Logf("Verified signature of %s.", url)
+	return nil
+}
@@ -0,0 +1,252 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/internal/testserver"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestMirrorURL(t *testing.T) {
+	testCases := []struct {
+		name   string
+		url    string
+		mirror string
+		want   string
+	}{
+		{
+			name: "no mirror",
+			url:  "https://dl.google.com/runtimes/ubuntu2204/nodejs/version.json",
+			want: "https://dl.google.com/runtimes/ubuntu2204/nodejs/version.json",
+		},
+		{
+			name:   "mirror",
+			url:    "https://dl.google.com/runtimes/ubuntu2204/nodejs/nodejs-18.16.0.tar.gz",
+			mirror: "https://mirror.example.com/runtimes",
+			want:   "https://mirror.example.com/runtimes/ubuntu2204/nodejs/nodejs-18.16.0.tar.gz",
+		},
+		{
+			name:   "mirror with trailing slash",
+			url:    "https://dl.google.com/runtimes/ubuntu2204/nodejs/version.json",
+			mirror: "https://mirror.example.com/runtimes/",
+			want:   "https://mirror.example.com/runtimes/ubuntu2204/nodejs/version.json",
+		},
+		{
+			name:   "other hosts are not mirrored",
+			url:    "https://storage.googleapis.com/dart-archive/channels/stable/release/2.15.1/sdk/dartsdk-linux-x64-release.zip",
+			mirror: "https://mirror.example.com/runtimes",
+			want:   "https://storage.googleapis.com/dart-archive/channels/stable/release/2.15.1/sdk/dartsdk-linux-x64-release.zip",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(env.RuntimeMirror, tc.mirror)
+
+			if got := mirrorURL(tc.url); got != tc.want {
+				t.Errorf("mirrorURL(%q) = %q, want %q", tc.url, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPublishedChecksum(t *testing.T) {
+	testCases := []struct {
+		name       string
+		httpStatus int
+		response   string
+		want       string
+		wantErr    bool
+	}{
+		{
+			name:     "checksum",
+			response: "fd9c9c45077d43db68deeaf210401b427efe4634b7a176fa84e9414f3790fa29\n",
+			want:     "fd9c9c45077d43db68deeaf210401b427efe4634b7a176fa84e9414f3790fa29",
+		},
+		{
+			name:     "sha256sum output",
+			response: "fd9c9c45077d43db68deeaf210401b427efe4634b7a176fa84e9414f3790fa29  ruby-3.2.2.tar.gz\n",
+			want:     "fd9c9c45077d43db68deeaf210401b427efe4634b7a176fa84e9414f3790fa29",
+		},
+		{
+			name:       "not published",
+			httpStatus: http.StatusNotFound,
+		},
+		{
+			name:     "invalid checksum",
+			response: "<html>not a checksum</html>",
+			wantErr:  true,
+		},
+		{
+			name:       "server error",
+			httpStatus: http.StatusInternalServerError,
+			wantErr:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := testserver.New(t, testserver.WithStatus(tc.httpStatus), testserver.WithJSON(tc.response))
+
+			got, err := publishedChecksum(gcp.NewContext(), server.URL)
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("publishedChecksum(ctx, %q) got error: %v, want error? %v", server.URL, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("publishedChecksum(ctx, %q) = %q, want %q", server.URL, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDownloadChecksum(t *testing.T) {
+	const (
+		content = "runtime"
+		// checksum is the SHA-256 checksum of content.
+		checksum = "d92c6a81b2ff50096bcda80885427d1f59a25b5f483f7055523504925d16ab23"
+		url      = "https://dl.google.com/runtimes/ubuntu2204/ruby/ruby-3.2.2.tar.gz"
+	)
+	testCases := []struct {
+		name         string
+		checksum     string
+		skipChecksum string
+		wantErr      bool
+	}{
+		{
+			name:     "checksum published",
+			checksum: checksum,
+		},
+		{
+			name:     "checksum mismatch",
+			checksum: strings.Repeat("0", 64),
+			wantErr:  true,
+		},
+		{
+			name:    "checksum not published",
+			wantErr: true,
+		},
+		{
+			name:         "checksum not published and skipped",
+			skipChecksum: "true",
+		},
+		{
+			name:         "checksum not skipped",
+			skipChecksum: "false",
+			wantErr:      true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// The mirror serves the archive, and its checksum file if tc.checksum is set.
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !strings.HasSuffix(r.URL.Path, ".sha256") {
+					w.Write([]byte(content))
+					return
+				}
+				if tc.checksum == "" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				w.Write([]byte(tc.checksum + "  ruby-3.2.2.tar.gz\n"))
+			}))
+			t.Cleanup(server.Close)
+			t.Setenv(env.RuntimeMirror, server.URL+"/runtimes")
+			t.Setenv(env.RuntimeSkipChecksum, tc.skipChecksum)
+			path := filepath.Join(t.TempDir(), "runtime.tar.gz")
+
+			err := download(gcp.NewContext(), archive{url: url, checksumURL: url + ".sha256"}, path)
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("download(ctx, %q, %q) got error: %v, want error? %v", url, path, err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if got, err := ioutil.ReadFile(path); err != nil || string(got) != content {
+				t.Errorf("download(ctx, %q, %q) wrote %q (err: %v), want %q", url, path, got, err, content)
+			}
+		})
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	testCases := []struct {
+		name      string
+		key       string
+		sigStatus int
+		cosignErr bool
+		wantErr   bool
+	}{
+		{
+			name: "no signing key",
+		},
+		{
+			name: "valid signature",
+			key:  "/workspace/cosign.pub",
+		},
+		{
+			name:      "signature not published",
+			key:       "/workspace/cosign.pub",
+			sigStatus: http.StatusNotFound,
+			wantErr:   true,
+		},
+		{
+			name:      "invalid signature",
+			key:       "/workspace/cosign.pub",
+			cosignErr: true,
+			wantErr:   true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(env.RuntimeSigningKey, tc.key)
+			// Stub cosign with a script that records its arguments.
+			bin := t.TempDir()
+			args := filepath.Join(bin, "args")
+			script := "#!/bin/sh\necho \"$@\" > " + args + "\n"
+			if tc.cosignErr {
+				script += "exit 1\n"
+			}
+			if err := ioutil.WriteFile(filepath.Join(bin, "cosign"), []byte(script), 0755); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+			server := testserver.New(t, testserver.WithStatus(tc.sigStatus), testserver.WithJSON("signature"))
+			url := server.URL + "/runtime.tar.gz"
+			path := filepath.Join(t.TempDir(), "runtime.tar.gz")
+
+			err := verifySignature(gcp.NewContext(), url, path)
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("verifySignature(ctx, %q, %q) got error: %v, want error? %v", url, path, err, tc.wantErr)
+			}
+			got, err := ioutil.ReadFile(args)
+			if tc.key == "" || tc.sigStatus != 0 {
+				if err == nil {
+					t.Errorf("verifySignature(ctx, %q, %q) ran cosign %q, want it not to run", url, path, got)
+				}
+				return
+			}
+			if want := "verify-blob --key " + tc.key + " --signature " + path + ".sig " + path + "\n"; string(got) != want {
+				t.Errorf("verifySignature(ctx, %q, %q) ran cosign %q, want %q", url, path, got, want)
+			}
+		})
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/buildererror"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/fetch"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/version"
@@ -29,7 +30,7 @@ import (
 )
 
 var (
-	dartSdkURL         = "https://storage.googleapis.com/dart-archive/channels/stable/release/%s/sdk/dartsdk-linux-x64-release.zip"
+	dartSdkURL         = "https://storage.googleapis.com/dart-archive/channels/stable/release/%s/sdk/dartsdk-linux-%s-release.zip"
 	googleTarballURL   = "https://dl.google.com/runtimes/%s/%[2]s/%[2]s-%s.tar.gz"
 	runtimeVersionsURL = "https://dl.google.com/runtimes/%s/%s/version.json"
 )
@@ -51,6 +52,7 @@ const (
 
 	ubuntu1804 string = "ubuntu1804"
 	ubuntu2204 string = "ubuntu2204"
+	ubuntu2404 string = "ubuntu2404"
 )
 
 // User friendly display name of all runtime (e.g. for use in error message).
@@ -71,11 +73,20 @@ var stackToOS = map[string]string{
 	"google.22":     ubuntu2204,
 	"google.gae.22": ubuntu2204,
 	"google.min.22": ubuntu2204,
+	"google.24":     ubuntu2404,
+}
+
+// fallbackOS maps an OS to an older OS whose runtime tarballs are compatible with it. Runtimes that
+// are not built for the newer OS yet are installed from the tarballs of the fallback OS, e.g. the
+// libraries that the Ubuntu 22.04 builds link against are ABI compatible in Ubuntu 24.04.
+var fallbackOS = map[string]string{
+	ubuntu2404: ubuntu2204,
 }
 
 const (
 	versionKey = "version"
 	stackKey   = "stack"
+	archKey    = "arch"
 	// gcpUserAgent is required for the Ruby runtime, but used for others for simplicity.
 	gcpUserAgent = "GCPBuildpacks"
 )
@@ -89,15 +100,48 @@ func OSForStack(stackID string) string {
 func IsCached(ctx *gcp.Context, layer *libcnb.Layer, version string) bool {
 	metaVersion := ctx.GetMetadata(layer, versionKey)
 	metaStack := ctx.GetMetadata(layer, stackKey)
-	return metaVersion == version && metaStack == ctx.StackID()
+	metaArch := ctx.GetMetadata(layer, archKey)
+	if metaArch == "" {
+		// Layers cached before arm64 support were all installed for amd64.
+		metaArch = gcp.ArchAMD64
+	}
+	return metaVersion == version && metaStack == ctx.StackID() && metaArch == ctx.Arch()
+}
+
+// tarballOS returns the directory of the runtime tarballs built for the OS and the architecture of
+// the image, the tarballs of architectures other than amd64 are in a directory suffixed with the
+// architecture, e.g. "ubuntu2204-arm64".
+func tarballOS(ctx *gcp.Context, os string) string {
+	if arch := ctx.Arch(); arch != gcp.ArchAMD64 {
+		return os + "-" + arch
+	}
+	return os
+}
+
+// tarballFallbackOS returns the directory of the runtime tarballs of the fallback OS of os, or "" if
+// os has no fallback.
+func tarballFallbackOS(ctx *gcp.Context, os string) string {
+	fallback, ok := fallbackOS[os]
+	if !ok {
+		return ""
+	}
+	return tarballOS(ctx, fallback)
+}
+
+// dartArch returns the name of the architecture of the image in Dart SDK archives.
+func dartArch(ctx *gcp.Context) string {
+	if arch := ctx.Arch(); arch != gcp.ArchAMD64 {
+		return arch
+	}
+	return "x64"
 }
 
 // InstallDartSDK downloads a given version of the dart SDK to the specified layer.
 func InstallDartSDK(ctx *gcp.Context, layer *libcnb.Layer, version string) error {
 	if err := ctx.ClearLayer(layer); err != nil {
-		return fmt.Errorf("clearing layer %q: %w", layer.Name, err)
+		return fmt.Errorf("clearing layer %q: %v", layer.Name, err)
 	}
-	sdkURL := fmt.Sprintf(dartSdkURL, version)
+	sdkURL := fmt.Sprintf(dartSdkURL, version, dartArch(ctx))
 
 	zip, err := ioutil.TempFile(layer.Path, "dart-sdk-*.zip")
 	if err != nil {
@@ -105,7 +149,8 @@ func InstallDartSDK(ctx *gcp.Context, layer *libcnb.Layer, version string) error
 	}
 	defer os.Remove(zip.Name())
 
-	if err := fetch.GetURL(sdkURL, zip); err != nil {
+	zip.Close()
+	if err := download(ctx, archive{url: sdkURL, checksumURL: fmt.Sprintf(dartSdkChecksumURL, version, dartArch(ctx))}, zip.Name()); err != nil {
 		ctx.Warnf("Failed to download Dart SDK from %s. You can specify the verison by setting the GOOGLE_RUNTIME_VERSION environment variable", sdkURL)
 		return err
 	}
@@ -130,6 +175,7 @@ func InstallDartSDK(ctx *gcp.Context, layer *libcnb.Layer, version string) error
 
 	ctx.SetMetadata(layer, stackKey, ctx.StackID())
 	ctx.SetMetadata(layer, versionKey, version)
+	ctx.SetMetadata(layer, archKey, ctx.Arch())
 
 	return nil
 }
@@ -147,11 +193,20 @@ func InstallTarballIfNotCached(ctx *gcp.Context, runtime InstallableRuntime, ver
 		ctx.Warnf("unknown stack ID %q, falling back to Ubuntu 18.04", stackID)
 		os = ubuntu1804
 	}
+	fallback := tarballFallbackOS(ctx, os)
+	os = tarballOS(ctx, os)
 
-	version, err := ResolveVersion(runtime, versionConstraint, os)
+	version, resolvedOS, err := resolveVersionWithFallback(runtime, versionConstraint, os, fallback)
 	if err != nil {
 		return false, err
 	}
+	if resolvedOS != os {
+		ctx.Warnf("%s %s is not available for %s, installing the build for %s.", runtimeName, version, os, resolvedOS)
+		os, fallback = resolvedOS, ""
+	}
+	if err := checkEndOfLife(ctx, runtime, version); err != nil {
+		return false, err
+	}
 	ctx.AddBOMEntry(libcnb.BOMEntry{
 		Name:     runtimeID,
 		Metadata: map[string]interface{}{"version": version},
@@ -169,27 +224,45 @@ func InstallTarballIfNotCached(ctx *gcp.Context, runtime InstallableRuntime, ver
 	}
 
 	if err := ctx.ClearLayer(layer); err != nil {
-		return false, gcp.InternalErrorf("clearing layer %q: %w", layer.Name, err)
+		return false, gcp.InternalErrorf("clearing layer %q: %v", layer.Name, err)
 	}
 	ctx.Logf("Installing %s v%s.", runtimeName, version)
 
-	runtimeURL := fmt.Sprintf(googleTarballURL, os, runtime, strings.ReplaceAll(version, "+", "_"))
-
 	stripComponents := 0
 	if runtime == OpenJDK {
 		stripComponents = 1
 	}
-	if err := fetch.Tarball(runtimeURL, layer.Path, stripComponents); err != nil {
+	err = downloadTarball(ctx, runtimeArchive(runtime, version, os), layer.Path, stripComponents)
+	if err != nil && fallback != "" {
+		// Exact versions are not resolved against the versions available for the OS.
+		ctx.Warnf("%s %s is not available for %s, installing the build for %s.", runtimeName, version, os, fallback)
+		if err := ctx.ClearLayer(layer); err != nil {
+			return false, gcp.InternalErrorf("clearing layer %q: %v", layer.Name, err)
+		}
+		os = fallback
+		err = downloadTarball(ctx, runtimeArchive(runtime, version, os), layer.Path, stripComponents)
+	}
+	if err != nil {
 		ctx.Warnf("Failed to download %s version %s os %s. You can specify the verison by setting the GOOGLE_RUNTIME_VERSION environment variable", runtimeName, version, os)
 		return false, err
 	}
 
 	ctx.SetMetadata(layer, stackKey, stackID)
 	ctx.SetMetadata(layer, versionKey, version)
+	ctx.SetMetadata(layer, archKey, ctx.Arch())
 
 	return false, nil
 }
 
+// runtimeArchive returns the tarball of a runtime version built for the OS.
+func runtimeArchive(runtime InstallableRuntime, version, os string) archive {
+	tarballVersion := strings.ReplaceAll(version, "+", "_")
+	return archive{
+		url:         fmt.Sprintf(googleTarballURL, os, runtime, tarballVersion),
+		checksumURL: fmt.Sprintf(googleTarballChecksumURL, os, runtime, tarballVersion),
+	}
+}
+
 // PinGemAndBundlerVersion pins the RubyGems versions for GAE and GCF runtime versions to prevent
 // unexpected behaviors with new versions. This is only expected to be called if the target
 // platform is GAE or GCF.
@@ -245,6 +318,30 @@ func PinGemAndBundlerVersion(ctx *gcp.Context, version string, layer *libcnb.Lay
 	return nil
 }
 
+// ResolveStackVersion returns the newest version of a runtime that satisfies the provided version
+// constraint and is available for the OS and architecture of the stack, or of its fallback OS.
+func ResolveStackVersion(ctx *gcp.Context, runtime InstallableRuntime, verConstraint string) (string, error) {
+	os := OSForStack(ctx.StackID())
+	v, _, err := resolveVersionWithFallback(runtime, verConstraint, tarballOS(ctx, os), tarballFallbackOS(ctx, os))
+	return v, err
+}
+
+// resolveVersionWithFallback resolves a version constraint against the versions available for os,
+// or for fallback if none of them match. It returns the version and the OS it was resolved for.
+func resolveVersionWithFallback(runtime InstallableRuntime, verConstraint, os, fallback string) (string, string, error) {
+	v, err := ResolveVersion(runtime, verConstraint, os)
+	if err == nil {
+		return v, os, nil
+	}
+	if fallback == "" {
+		return "", "", err
+	}
+	if fv, ferr := ResolveVersion(runtime, verConstraint, fallback); ferr == nil {
+		return fv, fallback, nil
+	}
+	return "", "", err
+}
+
 // ResolveVersion returns the newest available version of a runtime that satisfies the provided
 // version constraint.
 func ResolveVersion(runtime InstallableRuntime, verConstraint, os string) (string, error) {
@@ -252,7 +349,7 @@ func ResolveVersion(runtime InstallableRuntime, verConstraint, os string) (strin
 		return verConstraint, nil
 	}
 
-	url := fmt.Sprintf(runtimeVersionsURL, os, runtime)
+	url := mirrorURL(fmt.Sprintf(runtimeVersionsURL, os, runtime))
 
 	var versions []string
 	if err := fetch.JSON(url, &versions); err != nil {
@@ -261,7 +358,7 @@ func ResolveVersion(runtime InstallableRuntime, verConstraint, os string) (strin
 
 	v, err := version.ResolveVersion(verConstraint, versions)
 	if err != nil {
-		return "", gcp.UserErrorf("invalid %s version specified: %v, , You may need to use a different builder. Please check if the language version specified is supported by the os: %v. You can refer to https://cloud.google.com/docs/buildpacks/builders for a list of compatible runtime languages per builder", runtimeNames[runtime], err, os)
+		return "", gcp.UserErrorf("invalid %s version specified: %v, , You may need to use a different builder. Please check if the language version specified is supported by the os: %v. You can refer to https://cloud.google.com/docs/buildpacks/builders for a list of compatible runtime languages per builder", runtimeNames[runtime], err, os).WithCode(buildererror.CodeInvalidRuntimeVersion)
 	}
 	return v, nil
 }
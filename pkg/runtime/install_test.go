@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
@@ -26,6 +27,7 @@ import (
 
 	"github.com/GoogleCloudPlatform/buildpacks/internal/mockprocess"
 	"github.com/GoogleCloudPlatform/buildpacks/internal/testserver"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/testdata"
@@ -37,6 +39,7 @@ func TestInstallDartSDK(t *testing.T) {
 		name         string
 		httpStatus   int
 		responseFile string
+		checksum     string
 		wantFile     string
 		wantError    bool
 	}{
@@ -45,6 +48,18 @@ func TestInstallDartSDK(t *testing.T) {
 			responseFile: "testdata/dummy-dart-sdk.zip",
 			wantFile:     "lib/foo.txt",
 		},
+		{
+			name:         "successful install with checksum",
+			responseFile: "testdata/dummy-dart-sdk.zip",
+			checksum:     "6613f9fd52082461e4627d4bc6067cf7c97ea04e06fb198572b562652ef2e581 *dartsdk-linux-x64-release.zip",
+			wantFile:     "lib/foo.txt",
+		},
+		{
+			name:         "checksum mismatch",
+			responseFile: "testdata/dummy-dart-sdk.zip",
+			checksum:     "fd9c9c45077d43db68deeaf210401b427efe4634b7a176fa84e9414f3790fa29 *dartsdk-linux-x64-release.zip",
+			wantError:    true,
+		},
 		{
 			name:       "invalid version",
 			httpStatus: http.StatusNotFound,
@@ -69,6 +84,7 @@ func TestInstallDartSDK(t *testing.T) {
 				testserver.WithStatus(tc.httpStatus),
 				testserver.WithFile(testdata.MustGetPath(tc.responseFile)),
 				testserver.WithMockURL(&dartSdkURL))
+			stubChecksum(t, tc.checksum, &dartSdkChecksumURL)
 
 			version := "2.15.1"
 			err := InstallDartSDK(ctx, l, version)
@@ -101,6 +117,7 @@ func TestInstallRuby(t *testing.T) {
 		httpStatus   int
 		stackID      string
 		responseFile string
+		checksum     string
 		wantFile     string
 		wantVersion  string
 		wantError    bool
@@ -113,6 +130,21 @@ func TestInstallRuby(t *testing.T) {
 			wantFile:     "lib/foo.txt",
 			wantVersion:  "2.2.2",
 		},
+		{
+			name:         "successful install with checksum",
+			version:      "2.x.x",
+			responseFile: "testdata/dummy-ruby-runtime.tar.gz",
+			checksum:     "fd9c9c45077d43db68deeaf210401b427efe4634b7a176fa84e9414f3790fa29",
+			wantFile:     "lib/foo.txt",
+			wantVersion:  "2.2.2",
+		},
+		{
+			name:         "checksum mismatch",
+			version:      "2.x.x",
+			responseFile: "testdata/dummy-ruby-runtime.tar.gz",
+			checksum:     "6613f9fd52082461e4627d4bc6067cf7c97ea04e06fb198572b562652ef2e581",
+			wantError:    true,
+		},
 		{
 			name:         "successful cached install",
 			version:      "2.2.2",
@@ -161,6 +193,7 @@ func TestInstallRuby(t *testing.T) {
 				testserver.WithStatus(tc.httpStatus),
 				testserver.WithFile(testdata.MustGetPath(tc.responseFile)),
 				testserver.WithMockURL(&googleTarballURL))
+			stubChecksum(t, tc.checksum, &googleTarballChecksumURL)
 
 			// stub the version manifest
 			testserver.New(
@@ -204,6 +237,18 @@ func TestInstallRuby(t *testing.T) {
 	}
 }
 
+// stubChecksum stubs the checksum file at url. If checksum is empty the file does not exist and
+// the archive is installed without verifying its checksum.
+func stubChecksum(t *testing.T, checksum string, url *string) {
+	t.Helper()
+	status := http.StatusOK
+	if checksum == "" {
+		status = http.StatusNotFound
+		t.Setenv(env.RuntimeSkipChecksum, "true")
+	}
+	testserver.New(t, testserver.WithStatus(status), testserver.WithJSON(checksum), testserver.WithMockURL(url))
+}
+
 func TestPinGemAndBundlerVersion(t *testing.T) {
 	testCases := []struct {
 		name         string
@@ -317,3 +362,136 @@ func TestPinGemAndBundlerVersion(t *testing.T) {
 	}
 
 }
+
+func TestTarballOS(t *testing.T) {
+	testCases := []struct {
+		arch string
+		want string
+	}{
+		{arch: "amd64", want: "ubuntu2204"},
+		{arch: "arm64", want: "ubuntu2204-arm64"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.arch, func(t *testing.T) {
+			t.Setenv("CNB_TARGET_ARCH", tc.arch)
+			if got := tarballOS(gcp.NewContext(), ubuntu2204); got != tc.want {
+				t.Errorf("tarballOS(%q) = %q, want %q", ubuntu2204, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIsCachedChecksArch(t *testing.T) {
+	testCases := []struct {
+		name     string
+		metaArch string
+		arch     string
+		want     bool
+	}{
+		{name: "same arch", metaArch: "arm64", arch: "arm64", want: true},
+		{name: "other arch", metaArch: "amd64", arch: "arm64", want: false},
+		{name: "layer cached before arm64 support", arch: "amd64", want: true},
+		{name: "layer cached before arm64 support on arm64", arch: "arm64", want: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("CNB_TARGET_ARCH", tc.arch)
+			ctx := gcp.NewContext(gcp.WithStackID("google.22"))
+			layer := &libcnb.Layer{Metadata: map[string]interface{}{}}
+			ctx.SetMetadata(layer, versionKey, "18.0.0")
+			ctx.SetMetadata(layer, stackKey, "google.22")
+			if tc.metaArch != "" {
+				ctx.SetMetadata(layer, archKey, tc.metaArch)
+			}
+
+			if got := IsCached(ctx, layer, "18.0.0"); got != tc.want {
+				t.Errorf("IsCached() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestResolveVersionWithFallback(t *testing.T) {
+	versions := map[string]string{
+		"/ubuntu2404/ruby/version.json": `["3.2.2"]`,
+		"/ubuntu2204/ruby/version.json": `["3.0.6", "3.2.2"]`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v, ok := versions[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, v)
+	}))
+	t.Cleanup(srv.Close)
+	origURL := runtimeVersionsURL
+	runtimeVersionsURL = srv.URL + "/%s/%s/version.json"
+	t.Cleanup(func() { runtimeVersionsURL = origURL })
+
+	testCases := []struct {
+		name       string
+		constraint string
+		fallback   string
+		wantVer    string
+		wantOS     string
+		wantErr    bool
+	}{
+		{
+			name:       "available for os",
+			constraint: "3.x.x",
+			fallback:   ubuntu2204,
+			wantVer:    "3.2.2",
+			wantOS:     ubuntu2404,
+		},
+		{
+			name:       "available for fallback os",
+			constraint: "3.0.x",
+			fallback:   ubuntu2204,
+			wantVer:    "3.0.6",
+			wantOS:     ubuntu2204,
+		},
+		{
+			name:       "no fallback os",
+			constraint: "3.0.x",
+			wantErr:    true,
+		},
+		{
+			name:       "not available",
+			constraint: "2.7.x",
+			fallback:   ubuntu2204,
+			wantErr:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotVer, gotOS, err := resolveVersionWithFallback(Ruby, tc.constraint, ubuntu2404, tc.fallback)
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("resolveVersionWithFallback(%q) got error: %v, want error? %t", tc.constraint, err, tc.wantErr)
+			}
+			if gotVer != tc.wantVer || gotOS != tc.wantOS {
+				t.Errorf("resolveVersionWithFallback(%q) = (%q, %q), want (%q, %q)", tc.constraint, gotVer, gotOS, tc.wantVer, tc.wantOS)
+			}
+		})
+	}
+}
+
+func TestTarballFallbackOS(t *testing.T) {
+	testCases := []struct {
+		os   string
+		arch string
+		want string
+	}{
+		{os: ubuntu2404, arch: "amd64", want: "ubuntu2204"},
+		{os: ubuntu2404, arch: "arm64", want: "ubuntu2204-arm64"},
+		{os: ubuntu2204, arch: "amd64", want: ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.os+"-"+tc.arch, func(t *testing.T) {
+			t.Setenv("CNB_TARGET_ARCH", tc.arch)
+			if got := tarballFallbackOS(gcp.NewContext(), tc.os); got != tc.want {
+				t.Errorf("tarballFallbackOS(%q) = %q, want %q", tc.os, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package static
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+)
+
+const angularConfig = "angular.json"
+
+var (
+	// clientFrameworks are the client-side frameworks, by name, and the package that identifies them.
+	clientFrameworks = []struct {
+		name string
+		pkg  string
+	}{
+		{"Angular", "@angular/core"},
+		{"React", "react"},
+		{"Vue", "vue"},
+	}
+
+	// serverPackages are the packages of applications that start a server, along with the frameworks
+	// of nodejs.Framework.
+	serverPackages = []string{"express", "fastify", "koa", "@hapi/hapi", "@angular/ssr", "@nguniversal/express-engine"}
+
+	// devServerRegexp matches start scripts that run the development server of a client-side
+	// framework, which is not meant to serve a production build.
+	devServerRegexp = regexp.MustCompile(`^\s*(ng\s+serve|(react-scripts|craco|react-app-rewired)\s+start|vue-cli-service\s+serve|vite)\b`)
+)
+
+// ClientApp returns the name of the client-side framework of the application, e.g. "Angular", if
+// package.json only builds a client-side bundle and does not start a server, or an empty string
+// otherwise. Such applications are served as static sites, they would exit immediately if started
+// with `npm start`.
+func ClientApp(ctx *gcp.Context) (string, error) {
+	pjs, err := nodejs.ReadPackageJSONIfExists(ctx.ApplicationRoot())
+	if err != nil || pjs == nil || pjs.Scripts.Build == "" {
+		return "", err
+	}
+	if nodejs.Framework(pjs) != "" {
+		return "", nil
+	}
+	for _, p := range serverPackages {
+		if nodejs.HasDependency(pjs, p) {
+			return "", nil
+		}
+	}
+	if pjs.Scripts.Start != "" && !devServerRegexp.MatchString(pjs.Scripts.Start) {
+		return "", nil
+	}
+	// Without a start script, `npm start` runs `node server.js`.
+	server, err := ctx.FileExists(ctx.ApplicationRoot(), "server.js")
+	if err != nil || server {
+		return "", err
+	}
+	for _, f := range clientFrameworks {
+		if nodejs.HasDependency(pjs, f.pkg) {
+			return f.name, nil
+		}
+	}
+	return "", nil
+}
+
+// angularJSON is the part of angular.json that configures where the application is built.
+type angularJSON struct {
+	DefaultProject string `json:"defaultProject"`
+	Projects       map[string]struct {
+		ProjectType string `json:"projectType"`
+		Architect   struct {
+			Build struct {
+				Options struct {
+					OutputPath json.RawMessage `json:"outputPath"`
+				} `json:"options"`
+			} `json:"build"`
+		} `json:"architect"`
+	} `json:"projects"`
+}
+
+// angularOutputDirs returns the directories that `ng build` writes the application to, according to
+// angular.json, or nil if it does not exist.
+func angularOutputDirs(ctx *gcp.Context) ([]string, error) {
+	exists, err := ctx.FileExists(ctx.ApplicationRoot(), angularConfig)
+	if err != nil || !exists {
+		return nil, err
+	}
+	b, err := ctx.ReadFile(filepath.Join(ctx.ApplicationRoot(), angularConfig))
+	if err != nil {
+		return nil, err
+	}
+	var cfg angularJSON
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, gcp.UserErrorf("parsing %s: %v", angularConfig, err)
+	}
+
+	names := []string{cfg.DefaultProject}
+	for name := range cfg.Projects {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+	for _, name := range names {
+		p, ok := cfg.Projects[name]
+		if !ok || (p.ProjectType != "" && p.ProjectType != "application") {
+			continue
+		}
+		out := angularOutputPath(p.Architect.Build.Options.OutputPath)
+		if out == "" {
+			out = filepath.Join("dist", name)
+		}
+		// The application builder of Angular 17 writes the client bundle to the browser directory.
+		return []string{filepath.Join(out, "browser"), out}, nil
+	}
+	return nil, nil
+}
+
+// angularOutputPath returns the path of an outputPath option, which is either a path, or an object
+// whose base is the path since Angular 17.
+func angularOutputPath(raw json.RawMessage) string {
+	var out string
+	if err := json.Unmarshal(raw, &out); err == nil {
+		return out
+	}
+	var obj struct {
+		Base string `json:"base"`
+	}
+	if err := json.Unmarshal(raw, &obj); err == nil {
+		return obj.Base
+	}
+	return ""
+}
@@ -0,0 +1,147 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package static
+
+import (
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestClientApp(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name:  "create react app",
+			files: map[string]string{"package.json": `{"scripts": {"start": "react-scripts start", "build": "react-scripts build"}, "dependencies": {"react": "^18.2.0", "react-scripts": "5.0.1"}}`},
+			want:  "React",
+		},
+		{
+			name:  "angular",
+			files: map[string]string{"package.json": `{"scripts": {"start": "ng serve", "build": "ng build"}, "dependencies": {"@angular/core": "^17.0.0"}}`},
+			want:  "Angular",
+		},
+		{
+			name:  "vue with vite",
+			files: map[string]string{"package.json": `{"scripts": {"dev": "vite", "build": "vite build"}, "dependencies": {"vue": "^3.3.4"}, "devDependencies": {"vite": "^4.4.9"}}`},
+			want:  "Vue",
+		},
+		{
+			name:  "no build script",
+			files: map[string]string{"package.json": `{"scripts": {"start": "react-scripts start"}, "dependencies": {"react": "^18.2.0"}}`},
+		},
+		{
+			name:  "server start script",
+			files: map[string]string{"package.json": `{"scripts": {"start": "node dist/server.js", "build": "vite build"}, "dependencies": {"react": "^18.2.0"}}`},
+		},
+		{
+			name: "server.js",
+			files: map[string]string{
+				"package.json": `{"scripts": {"build": "vite build"}, "dependencies": {"vue": "^3.3.4"}}`,
+				"server.js":    "",
+			},
+		},
+		{
+			name:  "server package",
+			files: map[string]string{"package.json": `{"scripts": {"build": "ng build"}, "dependencies": {"@angular/core": "^17.0.0", "@angular/ssr": "^17.0.0"}}`},
+		},
+		{
+			name:  "server-side framework",
+			files: map[string]string{"package.json": `{"scripts": {"build": "next build"}, "dependencies": {"next": "^13.4.0", "react": "^18.2.0"}}`},
+		},
+		{
+			name:  "no client-side framework",
+			files: map[string]string{"package.json": `{"scripts": {"build": "tsc"}}`},
+		},
+		{
+			name:  "no package.json",
+			files: map[string]string{"index.html": ""},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+
+			got, err := ClientApp(ctx)
+			if err != nil {
+				t.Fatalf("ClientApp() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("ClientApp() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAngularOutputDirs(t *testing.T) {
+	testCases := []struct {
+		name    string
+		config  string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "output path",
+			config: `{"projects": {"app": {"projectType": "application", "architect": {"build": {"options": {"outputPath": "dist/app"}}}}}}`,
+			want:   []string{"dist/app/browser", "dist/app"},
+		},
+		{
+			name:   "output path object",
+			config: `{"projects": {"app": {"projectType": "application", "architect": {"build": {"options": {"outputPath": {"base": "out"}}}}}}}`,
+			want:   []string{"out/browser", "out"},
+		},
+		{
+			name:   "default output path",
+			config: `{"projects": {"app": {"projectType": "application", "architect": {"build": {"options": {}}}}}}`,
+			want:   []string{"dist/app/browser", "dist/app"},
+		},
+		{
+			name:   "default project",
+			config: `{"defaultProject": "web", "projects": {"admin": {"projectType": "application"}, "lib": {"projectType": "library"}, "web": {"projectType": "application"}}}`,
+			want:   []string{"dist/web/browser", "dist/web"},
+		},
+		{
+			name:   "first application",
+			config: `{"projects": {"lib": {"projectType": "library"}, "web": {"projectType": "application"}}}`,
+			want:   []string{"dist/web/browser", "dist/web"},
+		},
+		{
+			name:    "invalid angular.json",
+			config:  `{"projects": [`,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{angularConfig: tc.config})
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+
+			got, err := angularOutputDirs(ctx)
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("angularOutputDirs() got error: %v, want error? %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("angularOutputDirs() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package static contains static site buildpack library code.
+package static
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// ConfigFile is the name of the file that configures how a static site is built and served.
+	ConfigFile = "static.yaml"
+
+	// NPM builds the site with the build script of package.json.
+	NPM = "npm"
+	// Hugo builds the site with https://gohugo.io.
+	Hugo = "hugo"
+	// Jekyll builds the site with https://jekyllrb.com.
+	Jekyll = "jekyll"
+)
+
+var (
+	// npmOutputDirs are the directories that the build scripts of popular frontend frameworks write
+	// to, in order of preference.
+	npmOutputDirs = []string{"dist", "build", "out", "public"}
+	// hugoConfigFiles are the names of the Hugo site configuration file.
+	hugoConfigFiles = []string{"hugo.toml", "hugo.yaml", "hugo.json"}
+)
+
+// Config is the content of static.yaml.
+type Config struct {
+	// Build is a shell command that builds the site, it replaces the build of the detected framework.
+	Build string `yaml:"build"`
+	// Output is the directory, relative to the application root, that contains the site to serve.
+	Output string `yaml:"output"`
+	// SPA serves index.html for the paths that do not match a file so that a single-page application
+	// can handle client-side routing.
+	SPA bool `yaml:"spa"`
+}
+
+// Framework describes how a static site is built.
+type Framework struct {
+	// Name is the name of the framework, e.g. "npm".
+	Name string
+	// Command is the command that builds the site.
+	Command []string
+	// Env is the environment that Command must be run with.
+	Env []string
+	// OutputDirs are the directories that the framework may write the site to, in order of preference.
+	OutputDirs []string
+}
+
+// Enabled returns true if the application is a static site, i.e. it has a static.yaml file,
+// GOOGLE_STATIC_OUTPUT_DIR is set or it is a client-side application, see ClientApp.
+func Enabled(ctx *gcp.Context) (bool, error) {
+	if os.Getenv(env.StaticOutputDir) != "" {
+		return true, nil
+	}
+	exists, err := ctx.FileExists(ctx.ApplicationRoot(), ConfigFile)
+	if err != nil || exists {
+		return exists, err
+	}
+	client, err := ClientApp(ctx)
+	return client != "", err
+}
+
+// ReadConfig returns the content of static.yaml in the given directory, or an empty config if it
+// does not exist. GOOGLE_STATIC_OUTPUT_DIR takes precedence over the output directory of the file.
+func ReadConfig(dir string) (*Config, error) {
+	var cfg Config
+	b, err := ioutil.ReadFile(filepath.Join(dir, ConfigFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, gcp.InternalErrorf("reading %s: %v", ConfigFile, err)
+	}
+	if err := yaml.UnmarshalStrict(b, &cfg); err != nil {
+		return nil, gcp.UserErrorf("parsing %s: %v", ConfigFile, err)
+	}
+	if v := os.Getenv(env.StaticOutputDir); v != "" {
+		cfg.Output = v
+	}
+	if filepath.IsAbs(cfg.Output) {
+		return nil, gcp.UserErrorf("invalid output directory %q, it must be relative to the application root", cfg.Output)
+	}
+	return &cfg, nil
+}
+
+// DetectFramework returns the framework used to build the site, or nil if the site does not need
+// to be built.
+func DetectFramework(ctx *gcp.Context) (*Framework, error) {
+	pjs, err := nodejs.ReadPackageJSONIfExists(ctx.ApplicationRoot())
+	if err != nil {
+		return nil, err
+	}
+	if pjs != nil && pjs.Scripts.Build != "" {
+		angular, err := angularOutputDirs(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &Framework{
+			Name:       NPM,
+			Command:    []string{"npm", "run", "build"},
+			OutputDirs: append(angular, npmOutputDirs...),
+		}, nil
+	}
+
+	hugo, err := isHugoSite(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if hugo {
+		return &Framework{
+			Name:       Hugo,
+			Command:    []string{"hugo", "--minify"},
+			Env:        []string{"HUGO_ENVIRONMENT=production"},
+			OutputDirs: []string{"public"},
+		}, nil
+	}
+
+	jekyll, err := ctx.FileExists(ctx.ApplicationRoot(), "_config.yml")
+	if err != nil {
+		return nil, err
+	}
+	if jekyll {
+		return &Framework{
+			Name:       Jekyll,
+			Command:    []string{"bundle", "exec", "jekyll", "build"},
+			Env:        []string{"JEKYLL_ENV=production"},
+			OutputDirs: []string{"_site"},
+		}, nil
+	}
+	return nil, nil
+}
+
+// isHugoSite returns true if the application contains a Hugo site configuration. Older sites use a
+// config.toml file which is only considered along with the Hugo content directory.
+func isHugoSite(ctx *gcp.Context) (bool, error) {
+	for _, f := range hugoConfigFiles {
+		exists, err := ctx.FileExists(ctx.ApplicationRoot(), f)
+		if err != nil || exists {
+			return exists, err
+		}
+	}
+	config, err := ctx.FileExists(ctx.ApplicationRoot(), "config.toml")
+	if err != nil || !config {
+		return false, err
+	}
+	return ctx.FileExists(ctx.ApplicationRoot(), "content")
+}
+
+// OutputDir returns the directory that contains the site to serve, relative to the application
+// root. The output directory of the config takes precedence over the directories of the framework,
+// the first one that exists is used. The application root is served if neither is set.
+func OutputDir(ctx *gcp.Context, cfg *Config, fw *Framework) (string, error) {
+	candidates := []string{"."}
+	if cfg.Output != "" {
+		candidates = []string{cfg.Output}
+	} else if fw != nil {
+		candidates = fw.OutputDirs
+	}
+	for _, c := range candidates {
+		fi, err := os.Stat(filepath.Join(ctx.ApplicationRoot(), c))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", gcp.InternalErrorf("finding output directory %s: %v", c, err)
+		}
+		if !fi.IsDir() {
+			return "", gcp.UserErrorf("output %s is not a directory", c)
+		}
+		return filepath.Clean(c), nil
+	}
+	if len(candidates) == 1 {
+		return "", gcp.UserErrorf("output directory %s not found, set %s or the output of %s to the directory that contains the site", candidates[0], env.StaticOutputDir, ConfigFile)
+	}
+	return "", gcp.UserErrorf("none of the output directories %v found, set %s or the output of %s to the directory that contains the site", candidates, env.StaticOutputDir, ConfigFile)
+}
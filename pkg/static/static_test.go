@@ -0,0 +1,225 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package static
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadConfig(t *testing.T) {
+	testCases := []struct {
+		name    string
+		config  string
+		env     string
+		want    Config
+		wantErr bool
+	}{
+		{
+			name: "no static.yaml",
+			want: Config{},
+		},
+		{
+			name:   "all fields",
+			config: "build: npm run build:prod\noutput: dist/app\nspa: true\n",
+			want:   Config{Build: "npm run build:prod", Output: "dist/app", SPA: true},
+		},
+		{
+			name:   "output directory env",
+			config: "output: dist\n",
+			env:    "public",
+			want:   Config{Output: "public"},
+		},
+		{
+			name:    "unknown field",
+			config:  "outptu: dist\n",
+			wantErr: true,
+		},
+		{
+			name:    "absolute output directory",
+			config:  "output: /srv/www\n",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(env.StaticOutputDir, tc.env)
+			dir := t.TempDir()
+			if tc.config != "" {
+				writeFiles(t, dir, map[string]string{ConfigFile: tc.config})
+			}
+
+			got, err := ReadConfig(dir)
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("ReadConfig() got error: %v, want error? %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tc.want, *got); diff != "" {
+				t.Errorf("ReadConfig() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDetectFramework(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name:  "plain site",
+			files: map[string]string{"index.html": ""},
+		},
+		{
+			name:  "npm build script",
+			files: map[string]string{"package.json": `{"scripts": {"build": "vite build"}}`},
+			want:  NPM,
+		},
+		{
+			name:  "package.json without build script",
+			files: map[string]string{"package.json": `{"scripts": {"start": "serve"}}`},
+		},
+		{
+			name:  "hugo config",
+			files: map[string]string{"hugo.yaml": ""},
+			want:  Hugo,
+		},
+		{
+			name:  "legacy hugo config",
+			files: map[string]string{"config.toml": "", "content/_index.md": ""},
+			want:  Hugo,
+		},
+		{
+			name:  "config.toml without content",
+			files: map[string]string{"config.toml": ""},
+		},
+		{
+			name:  "jekyll",
+			files: map[string]string{"_config.yml": "", "Gemfile": ""},
+			want:  Jekyll,
+		},
+		{
+			name: "build script takes precedence",
+			files: map[string]string{
+				"package.json": `{"scripts": {"build": "hugo"}}`,
+				"hugo.toml":    "",
+			},
+			want: NPM,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+
+			fw, err := DetectFramework(ctx)
+			if err != nil {
+				t.Fatalf("DetectFramework() got error: %v", err)
+			}
+			got := ""
+			if fw != nil {
+				got = fw.Name
+			}
+			if got != tc.want {
+				t.Errorf("DetectFramework() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestOutputDir(t *testing.T) {
+	testCases := []struct {
+		name    string
+		files   map[string]string
+		config  Config
+		fw      *Framework
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "application root",
+			files: map[string]string{"index.html": ""},
+			want:  ".",
+		},
+		{
+			name:   "config output",
+			files:  map[string]string{"dist/index.html": "", "site/index.html": ""},
+			config: Config{Output: "site/"},
+			fw:     &Framework{OutputDirs: npmOutputDirs},
+			want:   "site",
+		},
+		{
+			name:  "first existing framework output",
+			files: map[string]string{"build/index.html": "", "public/favicon.ico": ""},
+			fw:    &Framework{OutputDirs: npmOutputDirs},
+			want:  "build",
+		},
+		{
+			name:    "missing framework output",
+			files:   map[string]string{"src/index.js": ""},
+			fw:      &Framework{OutputDirs: npmOutputDirs},
+			wantErr: true,
+		},
+		{
+			name:    "missing config output",
+			config:  Config{Output: "dist"},
+			wantErr: true,
+		},
+		{
+			name:    "output is a file",
+			files:   map[string]string{"dist": ""},
+			config:  Config{Output: "dist"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+			ctx := gcp.NewContext(gcp.WithApplicationRoot(dir))
+
+			got, err := OutputDir(ctx, &tc.config, tc.fw)
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("OutputDir() got error: %v, want error? %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("OutputDir() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("creating directory for %s: %v", name, err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("writing %s: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package version
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver"
+)
+
+// Syntax is a version range syntax that can be converted to a semver constraint.
+type Syntax int
+
+const (
+	// Semver is the constraint syntax of github.com/Masterminds/semver, e.g. ">= 1.2, < 2".
+	Semver Syntax = iota
+	// NPM is the range syntax of npm, e.g. ">=18 <21 || ^16.14", see
+	// https://docs.npmjs.com/cli/v10/configuring-npm/package-json#dependencies.
+	NPM
+	// PEP440 is the version specifier syntax of Python packaging, e.g. ">=3.9,<3.13" or "~=3.10",
+	// see https://peps.python.org/pep-0440/#version-specifiers.
+	PEP440
+	// Maven is the version range syntax of Maven, e.g. "[11,18)" or "(,1.8],[11,)", see
+	// https://maven.apache.org/enforcer/enforcer-rules/versionRanges.html.
+	Maven
+)
+
+// String returns the name of the syntax.
+func (s Syntax) String() string {
+	switch s {
+	case Semver:
+		return "semver"
+	case NPM:
+		return "npm"
+	case PEP440:
+		return "PEP 440"
+	case Maven:
+		return "Maven"
+	}
+	return fmt.Sprintf("Syntax(%d)", int(s))
+}
+
+// Constraint converts a version range of the given syntax to the equivalent semver constraint. An
+// empty range is returned unchanged and matches any version.
+func Constraint(syntax Syntax, spec string) (string, error) {
+	if strings.TrimSpace(spec) == "" {
+		return "", nil
+	}
+	var constraint string
+	var err error
+	switch syntax {
+	case Semver:
+		constraint = spec
+	case NPM:
+		constraint, err = npmConstraint(spec)
+	case PEP440:
+		constraint, err = pep440Constraint(spec)
+	case Maven:
+		constraint, err = mavenConstraint(spec)
+	default:
+		return "", fmt.Errorf("unknown version range syntax %v", syntax)
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid %v version range %q: %v", syntax, spec, err)
+	}
+	if _, err := semver.NewConstraint(constraint); err != nil {
+		return "", fmt.Errorf("invalid %v version range %q: %v", syntax, spec, err)
+	}
+	return constraint, nil
+}
+
+// exclusiveUpperBound pads the partial version of a "<" comparator with zeros, e.g. "<18" returns
+// "<18.0.0". The semver library treats partial versions as wildcards so "<18" would otherwise
+// match 18.1.0, unlike in the npm, PEP 440 and Maven syntaxes.
+func exclusiveUpperBound(c string) string {
+	if !strings.HasPrefix(c, "<") || strings.HasPrefix(c, "<=") {
+		return c
+	}
+	v := strings.TrimPrefix(c, "<")
+	parts := strings.Split(v, ".")
+	if len(parts) >= 3 {
+		return c
+	}
+	for _, p := range parts {
+		if _, err := strconv.Atoi(p); err != nil {
+			return c
+		}
+	}
+	for len(parts) < 3 {
+		parts = append(parts, "0")
+	}
+	return "<" + strings.Join(parts, ".")
+}
+
+// Resolve finds the largest version in a list of semantic versions that satisfies the version
+// range of the given syntax.
+func Resolve(syntax Syntax, spec string, versions []string) (string, error) {
+	constraint, err := Constraint(syntax, spec)
+	if err != nil {
+		return "", err
+	}
+	return ResolveVersion(constraint, versions)
+}
+
+// npmComparators are the operators that npm allows to be separated from their version by spaces.
+var npmComparators = map[string]bool{"<": true, "<=": true, ">": true, ">=": true, "=": true, "~": true, "^": true}
+
+// npmConstraint converts an npm range. Space separated comparators, e.g. ">=18 <21", are joined
+// with commas and operators separated from their version, e.g. ">= 18", are merged.
+func npmConstraint(spec string) (string, error) {
+	var alternatives []string
+	for _, alt := range strings.Split(spec, "||") {
+		alt = strings.TrimSpace(alt)
+		if alt == "" {
+			// An empty alternative matches any version.
+			return "*", nil
+		}
+		if strings.Contains(alt, " - ") {
+			// Hyphen ranges have the same syntax in both.
+			alternatives = append(alternatives, alt)
+			continue
+		}
+		var comparators []string
+		op := ""
+		for _, f := range strings.Fields(alt) {
+			if npmComparators[f] {
+				op += f
+				continue
+			}
+			comparators = append(comparators, exclusiveUpperBound(op+f))
+			op = ""
+		}
+		if op != "" {
+			return "", fmt.Errorf("operator %q without a version", op)
+		}
+		alternatives = append(alternatives, strings.Join(comparators, ", "))
+	}
+	return strings.Join(alternatives, " || "), nil
+}
+
+// pep440Constraint converts PEP 440 version specifiers. The compatible release operator "~=" is
+// expanded to a range and the equality operators are replaced with "=".
+func pep440Constraint(spec string) (string, error) {
+	var clauses []string
+	for _, c := range strings.Split(spec, ",") {
+		c = strings.Join(strings.Fields(c), "")
+		switch {
+		case c == "":
+			continue
+		case strings.HasPrefix(c, "~="):
+			// The compatible release operator, e.g. "~=3.10" is ">=3.10, <4" and "~=3.10.2" is
+			// ">=3.10.2, <3.11".
+			v := strings.TrimPrefix(c, "~=")
+			parts := strings.Split(v, ".")
+			if len(parts) < 2 {
+				return "", fmt.Errorf("%q requires at least a major and a minor version", c)
+			}
+			n, err := strconv.Atoi(parts[len(parts)-2])
+			if err != nil {
+				return "", fmt.Errorf("invalid version in %q", c)
+			}
+			upper := append(parts[:len(parts)-2:len(parts)-2], strconv.Itoa(n+1))
+			clauses = append(clauses, ">="+v, exclusiveUpperBound("<"+strings.Join(upper, ".")))
+		case strings.HasPrefix(c, "==="):
+			clauses = append(clauses, "="+strings.TrimPrefix(c, "==="))
+		case strings.HasPrefix(c, "=="):
+			clauses = append(clauses, "="+strings.TrimPrefix(c, "=="))
+		default:
+			clauses = append(clauses, exclusiveUpperBound(c))
+		}
+	}
+	return strings.Join(clauses, ", "), nil
+}
+
+// mavenConstraint converts a Maven version range, or several comma separated ones. A plain version
+// is a minimum, e.g. "1.2" is ">=1.2".
+func mavenConstraint(spec string) (string, error) {
+	spec = strings.Join(strings.Fields(spec), "")
+	if !strings.ContainsAny(spec, "[]()") {
+		return ">=" + spec, nil
+	}
+	var ranges []string
+	for spec != "" {
+		end := strings.IndexAny(spec, "])")
+		if end < 0 || !strings.ContainsAny(spec[:1], "[(") {
+			return "", fmt.Errorf("unterminated range")
+		}
+		r, err := mavenRange(spec[:end+1])
+		if err != nil {
+			return "", err
+		}
+		ranges = append(ranges, r)
+		spec = strings.TrimPrefix(spec[end+1:], ",")
+	}
+	return strings.Join(ranges, " || "), nil
+}
+
+// mavenRange converts a single Maven version range, e.g. "[11,18)".
+func mavenRange(r string) (string, error) {
+	inclusiveLower, inclusiveUpper := r[0] == '[', r[len(r)-1] == ']'
+	bounds := strings.Split(r[1:len(r)-1], ",")
+	switch len(bounds) {
+	case 1:
+		// An exact version, e.g. "[17]".
+		if !inclusiveLower || !inclusiveUpper || bounds[0] == "" {
+			return "", fmt.Errorf("invalid range %q", r)
+		}
+		return bounds[0], nil
+	case 2:
+	default:
+		return "", fmt.Errorf("invalid range %q", r)
+	}
+	var clauses []string
+	if lower := bounds[0]; lower != "" {
+		op := ">"
+		if inclusiveLower {
+			op = ">="
+		}
+		clauses = append(clauses, op+lower)
+	}
+	if upper := bounds[1]; upper != "" {
+		op := "<"
+		if inclusiveUpper {
+			op = "<="
+		}
+		clauses = append(clauses, exclusiveUpperBound(op+upper))
+	}
+	if len(clauses) == 0 {
+		return "", fmt.Errorf("invalid range %q", r)
+	}
+	return strings.Join(clauses, ", "), nil
+}
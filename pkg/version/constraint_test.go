@@ -0,0 +1,101 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package version
+
+import (
+	"testing"
+)
+
+func TestConstraint(t *testing.T) {
+	testCases := []struct {
+		syntax  Syntax
+		spec    string
+		want    string
+		wantErr bool
+	}{
+		{syntax: Semver, spec: ">= 1.2, < 2", want: ">= 1.2, < 2"},
+		{syntax: Semver, spec: "", want: ""},
+		{syntax: Semver, spec: "xyz", wantErr: true},
+		{syntax: NPM, spec: "", want: ""},
+		{syntax: NPM, spec: "18.x", want: "18.x"},
+		{syntax: NPM, spec: "^16.14", want: "^16.14"},
+		{syntax: NPM, spec: ">=18 <21", want: ">=18, <21.0.0"},
+		{syntax: NPM, spec: ">= 18 < 21", want: ">=18, <21.0.0"},
+		{syntax: NPM, spec: "^14.17 || >=16 <19", want: "^14.17 || >=16, <19.0.0"},
+		{syntax: NPM, spec: "16 - 18", want: "16 - 18"},
+		{syntax: NPM, spec: "14 || ", want: "*"},
+		{syntax: NPM, spec: ">=", wantErr: true},
+		{syntax: NPM, spec: "latest", wantErr: true},
+		{syntax: PEP440, spec: ">=3.9", want: ">=3.9"},
+		{syntax: PEP440, spec: ">= 3.9, <3.13", want: ">=3.9, <3.13.0"},
+		{syntax: PEP440, spec: "~=3.10", want: ">=3.10, <4.0.0"},
+		{syntax: PEP440, spec: "~=3.10.2", want: ">=3.10.2, <3.11.0"},
+		{syntax: PEP440, spec: "==3.11.*", want: "=3.11.*"},
+		{syntax: PEP440, spec: "===3.11.4", want: "=3.11.4"},
+		{syntax: PEP440, spec: ">=3.8,!=3.9.0", want: ">=3.8, !=3.9.0"},
+		{syntax: PEP440, spec: "^3.10", want: "^3.10"},
+		{syntax: PEP440, spec: "~=3", wantErr: true},
+		{syntax: PEP440, spec: ">=three", wantErr: true},
+		{syntax: PEP440, spec: ",", wantErr: true},
+		{syntax: Maven, spec: "1.2", want: ">=1.2"},
+		{syntax: Maven, spec: "[17]", want: "17"},
+		{syntax: Maven, spec: "[11,)", want: ">=11"},
+		{syntax: Maven, spec: "[8,11]", want: ">=8, <=11"},
+		{syntax: Maven, spec: "(11, 17)", want: ">11, <17.0.0"},
+		{syntax: Maven, spec: "(,8],[11,)", want: "<=8 || >=11"},
+		{syntax: Maven, spec: "[11,17", wantErr: true},
+		{syntax: Maven, spec: "11,17]", wantErr: true},
+		{syntax: Maven, spec: "(,)", wantErr: true},
+		{syntax: Maven, spec: "(17)", wantErr: true},
+		{syntax: Syntax(42), spec: "1.2", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.syntax.String()+" "+tc.spec, func(t *testing.T) {
+			got, err := Constraint(tc.syntax, tc.spec)
+			if tc.wantErr == (err == nil) {
+				t.Fatalf("Constraint(%v, %q) got error: %v, want error? %v", tc.syntax, tc.spec, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("Constraint(%v, %q) = %q, want %q", tc.syntax, tc.spec, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	versions := []string{"16.20.2", "18.18.2", "20.9.0", "21.1.0"}
+	testCases := []struct {
+		syntax Syntax
+		spec   string
+		want   string
+	}{
+		{syntax: NPM, spec: ">=18 <21", want: "20.9.0"},
+		{syntax: NPM, spec: "^16 || ^18", want: "18.18.2"},
+		{syntax: PEP440, spec: "~=16.0", want: "16.20.2"},
+		{syntax: Maven, spec: "[18,20)", want: "18.18.2"},
+		{syntax: Maven, spec: "", want: "21.1.0"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.syntax.String()+" "+tc.spec, func(t *testing.T) {
+			got, err := Resolve(tc.syntax, tc.spec, versions)
+			if err != nil {
+				t.Fatalf("Resolve(%v, %q) got error: %v", tc.syntax, tc.spec, err)
+			}
+			if got != tc.want {
+				t.Errorf("Resolve(%v, %q) = %q, want %q", tc.syntax, tc.spec, got, tc.want)
+			}
+		})
+	}
+}
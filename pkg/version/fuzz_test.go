@@ -0,0 +1,79 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.18
+// +build go1.18
+
+package version
+
+import (
+	"testing"
+
+	"github.com/Masterminds/semver"
+)
+
+// fuzzConstraint checks that converting any input either fails or yields a valid semver constraint.
+func fuzzConstraint(f *testing.F, syntax Syntax, seeds ...string) {
+	for _, s := range seeds {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, spec string) {
+		c, err := Constraint(syntax, spec)
+		if err != nil || c == "" {
+			return
+		}
+		if _, err := semver.NewConstraint(c); err != nil {
+			t.Errorf("Constraint(%v, %q) = %q, which is not a valid constraint: %v", syntax, spec, c, err)
+		}
+	})
+}
+
+func FuzzNPMConstraint(f *testing.F) {
+	fuzzConstraint(f, NPM, "18.x", ">=18 <21", ">= 18 || ^16.14", "16 - 18", "||", ">=")
+}
+
+func FuzzPEP440Constraint(f *testing.F) {
+	fuzzConstraint(f, PEP440, ">=3.9,<3.13", "~=3.10.2", "===3.11.4", "~=", ",")
+}
+
+func FuzzMavenConstraint(f *testing.F) {
+	fuzzConstraint(f, Maven, "[11,18)", "(,8],[11,)", "[17]", "1.2", "[", "(,)")
+}
+
+func FuzzResolve(f *testing.F) {
+	f.Add(int(NPM), ">=18 <21")
+	f.Add(int(PEP440), "~=16.0")
+	f.Add(int(Maven), "[18,20)")
+	versions := []string{"16.20.2", "18.18.2", "20.9.0", "21.1.0"}
+	f.Fuzz(func(t *testing.T, syntax int, spec string) {
+		got, err := Resolve(Syntax(syntax), spec, versions)
+		if err != nil {
+			return
+		}
+		c, err := Constraint(Syntax(syntax), spec)
+		if err != nil {
+			t.Fatalf("Constraint(%v, %q) got error: %v, but Resolve succeeded", Syntax(syntax), spec, err)
+		}
+		if c == "" {
+			c = "*"
+		}
+		sc, err := semver.NewConstraint(c)
+		if err != nil {
+			t.Fatalf("semver.NewConstraint(%q) got error: %v", c, err)
+		}
+		if !sc.Check(semver.MustParse(got)) {
+			t.Errorf("Resolve(%v, %q) = %q, which does not satisfy %q", Syntax(syntax), spec, got, c)
+		}
+	})
+}
@@ -0,0 +1,46 @@
+#!/bin/bash
+# Copyright 2023 Google LLC
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# The build-multiarch-stack.sh script builds and pushes the run and build
+# images of a stack for several platforms under a single manifest list.
+#
+# Usage:
+#   ./build-multiarch-stack.sh <stack dir> <repository> <candidate> [platforms]
+#
+# For example:
+#   ./build-multiarch-stack.sh stacks/google_22 gcr.io/my-project/google-22 test
+#
+# Pushes <repository>/run:<candidate> and <repository>/build:<candidate> for
+# the comma separated [platforms], linux/amd64,linux/arm64 by default. Requires
+# docker buildx with a builder that supports the platforms, e.g. QEMU emulation
+# set up with `docker run --privileged --rm tonistiigi/binfmt --install arm64`.
+
+set -euo pipefail
+
+readonly stack="${1:?stack directory missing}"
+readonly repository="${2:?image repository missing}"
+readonly candidate="${3:?candidate name missing}"
+readonly platforms="${4:-linux/amd64,linux/arm64}"
+
+for image in run build; do
+  echo "Building ${repository}/${image}:${candidate} for ${platforms}"
+  docker buildx build "${stack}" \
+    --platform "${platforms}" \
+    --build-arg CANDIDATE_NAME="${candidate}" \
+    --file "${stack}/${image}.Dockerfile" \
+    --tag "${repository}/${image}:${candidate}" \
+    --push
+  docker buildx imagetools inspect "${repository}/${image}:${candidate}"
+done
@@ -23,7 +23,7 @@ import (
 
 func main() {
 	log.Printf("Checking tools")
-	if err := checktools.Installed(); err != nil {
+	if err := checktools.Installed("docker"); err != nil {
 		log.Fatalf("Error: %v", err)
 	}
 	log.Printf("Checking pack version")
@@ -25,7 +25,7 @@ import (
 )
 
 func TestInstalled(t *testing.T) {
-	if err := checktools.Installed(); err != nil {
+	if err := checktools.Installed("docker"); err != nil {
 		t.Fatalf("Checking tools: %v", err)
 	}
 }
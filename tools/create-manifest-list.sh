@@ -0,0 +1,41 @@
+#!/bin/bash
+# Copyright 2023 Google LLC
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# The create-manifest-list.sh script stitches single-architecture images into
+# a multi-architecture image.
+#
+# Usage:
+#   ./create-manifest-list.sh <image> <source image>...
+#
+# For example:
+#   ./create-manifest-list.sh gcr.io/my-project/builder:v1 \
+#     gcr.io/my-project/builder:v1-amd64 gcr.io/my-project/builder:v1-arm64
+#
+# Pushes <image> as a manifest list of the <source image>s, which must already
+# be pushed to a registry. The platform of each entry is read from the config
+# of its source image.
+
+set -euo pipefail
+
+readonly image="${1:?image name missing}"
+shift
+if [[ $# -eq 0 ]]; then
+  echo "Source images missing" >&2
+  exit 1
+fi
+
+echo "Creating manifest list ${image} from $*"
+docker buildx imagetools create --tag "${image}" "$@"
+docker buildx imagetools inspect "${image}"
@@ -0,0 +1,70 @@
+#!/bin/bash
+# Copyright 2023 Google LLC
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# The create-multiarch-builder.sh script creates a builder image for several
+# architectures from the builder tars built for each of them.
+#
+# Usage:
+#   ./create-multiarch-builder.sh <image> <platform>=<builder tar>...
+#
+# For example:
+#   ./create-multiarch-builder.sh gcr.io/my-project/builder:v1 \
+#     linux/amd64=amd64/builder.tar linux/arm64=arm64/builder.tar
+#
+# Creates and pushes a builder <image>-<arch> for each builder tar, e.g. built
+# with `bazel build --platforms=@io_bazel_rules_go//go/toolchain:linux_arm64`,
+# and stitches them into the manifest list <image> like create-manifest-list.sh.
+# The stack images in the builder.toml must be available for each platform, see
+# build-multiarch-stack.sh.
+
+set -euo pipefail
+
+readonly image="${1:?image name missing}"
+shift
+if [[ $# -eq 0 ]]; then
+  echo "Builder tars missing" >&2
+  exit 1
+fi
+
+readonly temp="$(mktemp -d)"
+trap "rm -rf $temp" EXIT
+
+images=()
+for arg in "$@"; do
+  platform="${arg%%=*}"
+  tar="${arg#*=}"
+  if [[ "${platform}" == "${arg}" ]]; then
+    echo "Invalid argument ${arg}, want <platform>=<builder tar>" >&2
+    exit 1
+  fi
+  arch="$(echo "${platform}" | cut -d/ -f2)"
+  dir="${temp}/${arch}"
+  mkdir -p "${dir}"
+  tar xf "${tar}" -C "${dir}"
+
+  # The builder is created from the stack images of the platform, pulled from
+  # their manifest lists.
+  build_image="$(sed -n 's/^ *build-image *= *"\(.*\)"/\1/p' "${dir}/builder.toml")"
+  docker pull --platform "${platform}" "${build_image}"
+
+  echo "Creating builder ${image}-${arch} for ${platform}"
+  pack builder create "${image}-${arch}" --config="${dir}/builder.toml" --pull-policy=never
+  docker push "${image}-${arch}"
+  images+=("${image}-${arch}")
+done
+
+echo "Creating manifest list ${image} from ${images[*]}"
+docker buildx imagetools create --tag "${image}" "${images[@]}"
+docker buildx imagetools inspect "${image}"
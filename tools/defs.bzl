@@ -5,7 +5,7 @@ load("@io_bazel_rules_go//go:def.bzl", "go_test")
 load("@rules_pkg//pkg:mappings.bzl", "pkg_mklink")
 load("@rules_pkg//pkg:tar.bzl", "pkg_tar")
 
-def buildpack(name, executables, prefix, version, api = "0.8", srcs = None, extension = "tgz", strip_prefix = ".", visibility = None):
+def buildpack(name, executables, prefix, version, api = "0.8", srcs = None, execd = None, extension = "tgz", strip_prefix = ".", visibility = None):
     """Macro to create a single buildpack as a tgz or tar archive.
 
     The result is a tar or tgz archive with a buildpack descriptor
@@ -21,6 +21,8 @@ def buildpack(name, executables, prefix, version, api = "0.8", srcs = None, exte
       version: the version of the buildpack
       api: the buildpacks API version
       executables: list of labels of buildpack binaries
+      execd: dict(name -> label) of exec.d binaries, placed in the exec.d directory of the buildpack
+        and added to the image with ctx.AddExecD
       strip_prefix: by default preserves the paths of srcs
       extension: tgz by default
       visibility: the visibility
@@ -50,6 +52,10 @@ def buildpack(name, executables, prefix, version, api = "0.8", srcs = None, exte
 
     if not srcs:
         srcs = []
+    files = {executables[0]: "/bin/main"}
+    if execd:
+        for (k, v) in execd.items():
+            files[v] = "/exec.d/" + k
     pkg_tar(
         name = name,
         extension = extension,
@@ -58,9 +64,7 @@ def buildpack(name, executables, prefix, version, api = "0.8", srcs = None, exte
             "_link_build" + name,
             "_link_detect" + name,
         ] + srcs,
-        files = {
-            executables[0]: "/bin/main",
-        },
+        files = files,
         strip_prefix = strip_prefix,
         visibility = visibility,
     )